@@ -17,6 +17,7 @@ package main
 import (
 	"github.com/samber/lo"
 
+	awssettings "github.com/aws/karpenter/pkg/apis/config/settings"
 	"github.com/aws/karpenter/pkg/cloudprovider"
 	"github.com/aws/karpenter/pkg/context"
 	"github.com/aws/karpenter/pkg/controllers"
@@ -43,6 +44,9 @@ func main() {
 	})
 	awsCloudProvider := cloudprovider.New(awsCtx)
 	lo.Must0(operator.AddHealthzCheck("cloud-provider", awsCloudProvider.LivenessProbe))
+	if awssettings.FromContext(ctx).EnableOfferingsAPI {
+		lo.Must0(operator.AddMetricsExtraHandler(cloudprovider.OfferingsPath, cloudprovider.NewOfferingsHandler(awsCloudProvider, operator.SettingsStore)), "setting up offerings api")
+	}
 	cloudProvider := metrics.Decorate(awsCloudProvider)
 
 	operator.
@@ -59,7 +63,8 @@ func main() {
 		WithWebhooks(corewebhooks.NewWebhooks()...).
 		WithControllers(ctx, controllers.NewControllers(
 			awsCtx,
+			awsCloudProvider,
 		)...).
-		WithWebhooks(webhooks.NewWebhooks()...).
+		WithWebhooks(webhooks.NewWebhooks(awsCtx, operator.SettingsStore)...).
 		Start(ctx)
 }
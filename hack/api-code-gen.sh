@@ -31,6 +31,17 @@ vpcLimits() {
   checkForUpdates "${GIT_DIFF}" "${NO_UPDATE}" "${SUBJECT}" "${GENERATED_FILE}"
 }
 
+instanceTypes() {
+  GENERATED_FILE="pkg/cloudprovider/zz_generated.instancetypes.json"
+  NO_UPDATE=''
+  SUBJECT="Instance Types"
+
+  go run hack/code/instancetypes_gen.go -- "${GENERATED_FILE}"
+
+  GIT_DIFF=$(git diff --stat "${GENERATED_FILE}")
+  checkForUpdates "${GIT_DIFF}" "${NO_UPDATE}" "${SUBJECT}" "${GENERATED_FILE}"
+}
+
 checkForUpdates() {
   GIT_DIFF=$1
   NO_UPDATE=$2
@@ -72,3 +83,4 @@ fi
 
 pricing
 vpcLimits
+instanceTypes
@@ -0,0 +1,74 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 1 {
+		log.Fatalf("Usage: %s pkg/cloudprovider/zz_generated.instancetypes.json", os.Args[0])
+	}
+
+	const region = "us-east-1"
+	os.Setenv("AWS_SDK_LOAD_CONFIG", "true")
+	os.Setenv("AWS_REGION", region)
+	ctx := context.Background()
+	ec2api := ec2.New(session.Must(session.NewSession()))
+
+	// Instance types are described without filters, since the controller applies its filters to the dataset itself
+	var instanceTypes []*ec2.InstanceTypeInfo
+	if err := ec2api.DescribeInstanceTypesPagesWithContext(ctx, &ec2.DescribeInstanceTypesInput{}, func(page *ec2.DescribeInstanceTypesOutput, lastPage bool) bool {
+		instanceTypes = append(instanceTypes, page.InstanceTypes...)
+		return true
+	}); err != nil {
+		log.Fatalf("describing instance types, %s", err)
+	}
+	sort.Slice(instanceTypes, func(i, j int) bool {
+		return aws.StringValue(instanceTypes[i].InstanceType) < aws.StringValue(instanceTypes[j].InstanceType)
+	})
+
+	// Each instance type is written on its own line, which keeps the diffs of updates to the dataset small
+	src := &bytes.Buffer{}
+	src.WriteString("[\n")
+	for i, instanceType := range instanceTypes {
+		raw, err := json.Marshal(instanceType)
+		if err != nil {
+			log.Fatalf("marshaling instance type %s, %s", aws.StringValue(instanceType.InstanceType), err)
+		}
+		src.Write(raw)
+		if i < len(instanceTypes)-1 {
+			src.WriteString(",")
+		}
+		src.WriteString("\n")
+	}
+	src.WriteString("]\n")
+
+	if err := os.WriteFile(flag.Arg(0), src.Bytes(), 0644); err != nil {
+		log.Fatalf("writing output, %s", err)
+	}
+}
@@ -54,7 +54,7 @@ func main() {
 	sess := session.Must(session.NewSession())
 	ec2 := ec22.New(sess)
 	updateStarted := time.Now()
-	pricingProvider := awscloudprovider.NewPricingProvider(ctx, awscloudprovider.NewPricingAPI(sess, region), ec2, region, false, make(chan struct{}))
+	pricingProvider := awscloudprovider.NewPricingProvider(ctx, awscloudprovider.NewPricingAPI(sess, region), ec2, region, false, 12*time.Hour, make(chan struct{}))
 
 	for {
 		if pricingProvider.OnDemandLastUpdated().After(updateStarted) && pricingProvider.SpotLastUpdated().After(updateStarted) {
@@ -255,6 +255,7 @@ func getIdentMapping(identName string) (string, error) {
 		"interruptionSubsystem":   "interruption",
 		"nodeTemplateSubsystem":   "nodetemplate",
 		"deprovisioningSubsystem": "deprovisioning",
+		"cacheSubsystem":          "cache",
 	}
 	if v, ok := identMapping[identName]; ok {
 		return v, nil
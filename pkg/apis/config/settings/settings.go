@@ -19,12 +19,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"path"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/samber/lo"
 	"go.uber.org/multierr"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"knative.dev/pkg/configmap"
 
 	"github.com/aws/karpenter-core/pkg/apis/config"
@@ -37,6 +41,80 @@ const (
 	ResourceName NodeNameConvention = "resource-name"
 )
 
+// SpotUnavailableBehavior is what happens to a launch that allows spot when every spot offering is unavailable
+type SpotUnavailableBehavior string
+
+const (
+	// FallbackToOnDemand launches on-demand capacity instead, if the provisioner allows it
+	FallbackToOnDemand SpotUnavailableBehavior = "FallbackToOnDemand"
+	// WaitForSpot fails the launch until spot offerings become available again
+	WaitForSpot SpotUnavailableBehavior = "WaitForSpot"
+)
+
+// InterruptionMessageTypes are the types of interruption messages that aws.interruptionIgnoredMessageTypes can list.
+// Spot interruptions and rebalance recommendations are only sent for spot instances, while scheduled changes and state
+// changes are sent for instances of either capacity type.
+var InterruptionMessageTypes = []string{"SpotInterruption", "RebalanceRecommendation", "ScheduledChange", "StateChange"}
+
+// UnmatchedNodeBehavior is what happens to an interruption message when none of its instances match a node
+type UnmatchedNodeBehavior string
+
+const (
+	// DeleteMessage deletes the message without acting on it
+	DeleteMessage UnmatchedNodeBehavior = "Delete"
+	// RequeueMessage leaves the message in the queue to be received again, in case its nodes haven't registered yet,
+	// until the InterruptionUnmatchedNodeGracePeriod has passed since its event
+	RequeueMessage UnmatchedNodeBehavior = "Requeue"
+)
+
+// NodeTemplateValidation is how strictly AWSNodeTemplates are validated when they're admitted
+type NodeTemplateValidation string
+
+const (
+	// LenientValidation admits AWSNodeTemplates whose subnets, security groups, AMIs or instance profile can't be
+	// resolved, and surfaces the failures in their status conditions
+	LenientValidation NodeTemplateValidation = "Lenient"
+	// StrictValidation rejects AWSNodeTemplates whose subnets, security groups, AMIs or instance profile can't be
+	// resolved when they're admitted
+	StrictValidation NodeTemplateValidation = "Strict"
+)
+
+// InstanceTypeDataSource is where the instance types that Karpenter launches are described
+type InstanceTypeDataSource string
+
+const (
+	// APIInstanceTypeData describes instance types with the EC2 DescribeInstanceTypes API
+	APIInstanceTypeData InstanceTypeDataSource = "API"
+	// StaticInstanceTypeData describes instance types with the dataset that's embedded in the controller, which is
+	// generated from the DescribeInstanceTypes API when the controller is built
+	StaticInstanceTypeData InstanceTypeDataSource = "Static"
+)
+
+// ProvisioningDecisionSinkLog writes provisioning decisions to the controller log
+const ProvisioningDecisionSinkLog = "log"
+
+// NameTagTemplateVariables are the variables that may be referenced as "{variable}" in the NameTagTemplate
+var NameTagTemplateVariables = []string{"cluster", "provisioner", "capacity-type", "instance-id", "instance-type", "zone"}
+
+var nameTagTemplateVariable = regexp.MustCompile(`\{([^{}]*)\}`)
+
+var vpcIDPattern = regexp.MustCompile(`^vpc-[0-9a-f]+$`)
+
+var regionPattern = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-[0-9]+$`)
+
+// launchTemplateNamePrefixPattern matches the characters that EC2 allows in launch template names
+var launchTemplateNamePrefixPattern = regexp.MustCompile(`^[a-zA-Z0-9().\-/_]+$`)
+
+// eventBusPattern matches the name or the ARN of an EventBridge event bus
+var eventBusPattern = regexp.MustCompile(`^(arn:[a-z-]+:events:[a-z0-9-]+:[0-9]{12}:event-bus/)?[a-zA-Z0-9/._\-]{1,256}$`)
+
+const (
+	// launchTemplateNameMaxLength is the longest launch template name that EC2 accepts
+	launchTemplateNameMaxLength = 128
+	// launchTemplateHashMaxLength is the number of digits of the largest 64-bit hash of a launch template
+	launchTemplateHashMaxLength = 20
+)
+
 var ContextKey = Registration
 
 var Registration = &config.Registration{
@@ -46,31 +124,149 @@ var Registration = &config.Registration{
 }
 
 var defaultSettings = Settings{
-	ClusterName:                "",
-	ClusterEndpoint:            "",
-	DefaultInstanceProfile:     "",
-	EnablePodENI:               false,
-	EnableENILimitedPodDensity: true,
-	IsolatedVPC:                false,
-	NodeNameConvention:         IPName,
-	VMMemoryOverheadPercent:    0.075,
-	EnableInterruptionHandling: false,
-	Tags:                       map[string]string{},
+	ClusterName:                          "",
+	ClusterEndpoint:                      "",
+	ClusterVPCID:                         "",
+	DefaultInstanceProfile:               "",
+	NodeTemplateValidation:               LenientValidation,
+	LaunchTemplateNamePrefix:             "Karpenter",
+	EnablePodENI:                         false,
+	EnableENILimitedPodDensity:           true,
+	IsolatedVPC:                          false,
+	NodeNameConvention:                   IPName,
+	VMMemoryOverheadPercent:              0.075,
+	EnableInterruptionHandling:           false,
+	InterruptionMessageMaxAge:            metav1.Duration{},
+	InterruptionUnmatchedNodeBehavior:    DeleteMessage,
+	InterruptionUnmatchedNodeGracePeriod: metav1.Duration{Duration: 2 * time.Minute},
+	InterruptionEventPath:                "",
+	InterruptionReceiveMaxBackoff:        metav1.Duration{Duration: time.Minute},
+	InterruptionInfrastructureInterval:   metav1.Duration{Duration: 5 * time.Minute},
+	InstanceTypeDataSource:               APIInstanceTypeData,
+	InstanceTypeOfferingsParallelism:     5,
+	InstanceTypeOfferingsLocationType:    "availability-zone",
+	CreateFleetTimeout:                   metav1.Duration{Duration: time.Minute},
+	CreateFleetBatchSize:                 1000,
+	SSMRetryAttempts:                     3,
+	SSMRetryDelay:                        metav1.Duration{Duration: time.Second},
+	NameTagTemplate:                      "",
+	EnableOfferingsAPI:                   false,
+	EnableQuotaCheck:                     false,
+	VCPULimitBackoff:                     metav1.Duration{Duration: 5 * time.Minute},
+	SpreadSubnetsWithinZone:              false,
+	SpotUnavailableBehavior:              FallbackToOnDemand,
+	EnableAllocationAnnotations:          false,
+	EnableAMIDriftDetection:              false,
+	ProvisioningDecisionSink:             "",
+	ProvisioningDecisionsPerMinute:       60,
+	InstanceEventBus:                     "",
+	InstanceEventsPerMinute:              60,
+	DescribeInstancesBatchSize:           1000,
+	DescribeSubnetsPageSize:              1000,
+	MaxSubnetsPerSelector:                500,
+	PricingRefreshPeriod:                 metav1.Duration{Duration: 12 * time.Hour},
+	EnableVersionTags:                    false,
+	CapacityOverrides:                    map[string]v1.ResourceList{},
+	ReservedHeadroom:                     v1.ResourceList{},
+	InstanceTypeFilters:                  map[string][]string{},
+	Tags:                                 map[string]string{},
+	InterruptionInfrastructureTags:       map[string]string{},
 }
 
 type Settings struct {
-	ClusterName                string             `json:"aws.clusterName" validate:"required"`
-	ClusterEndpoint            string             `json:"aws.clusterEndpoint" validate:"required"`
-	DefaultInstanceProfile     string             `json:"aws.defaultInstanceProfile"`
+	ClusterName     string `json:"aws.clusterName" validate:"required"`
+	ClusterEndpoint string `json:"aws.clusterEndpoint" validate:"required"`
+	// ClusterVPCID is the VPC that selected subnets and security groups must belong to
+	ClusterVPCID           string `json:"aws.clusterVPCID"`
+	DefaultInstanceProfile string `json:"aws.defaultInstanceProfile"`
+	// NodeTemplateValidation is whether unresolvable AWSNodeTemplates are rejected or admitted with failing conditions
+	NodeTemplateValidation NodeTemplateValidation `json:"aws.nodeTemplateValidation" validate:"required,oneof=Lenient Strict"`
+	// LaunchTemplateNamePrefix is the prefix of the names of generated launch templates
+	LaunchTemplateNamePrefix   string             `json:"aws.launchTemplateNamePrefix"`
 	EnablePodENI               bool               `json:"aws.enablePodENI,string"`
 	EnableENILimitedPodDensity bool               `json:"aws.enableENILimitedPodDensity,string"`
 	IsolatedVPC                bool               `json:"aws.isolatedVPC,string"`
 	NodeNameConvention         NodeNameConvention `json:"aws.nodeNameConvention" validate:"required"`
 	VMMemoryOverheadPercent    float64            `json:"aws.vmMemoryOverheadPercent,string" validate:"min=0"`
 	EnableInterruptionHandling bool               `json:"aws.enableInterruptionHandling,string"`
-	Tags                       map[string]string  `json:"aws.tags,omitempty"`
+	InterruptionMessageMaxAge  metav1.Duration    `json:"aws.interruptionMessageMaxAge"`
+	// InterruptionUnmatchedNodeBehavior is whether interruption messages without a matching node are deleted or requeued
+	InterruptionUnmatchedNodeBehavior    UnmatchedNodeBehavior `json:"aws.interruptionUnmatchedNodeBehavior" validate:"required,oneof=Delete Requeue"`
+	InterruptionUnmatchedNodeGracePeriod metav1.Duration       `json:"aws.interruptionUnmatchedNodeGracePeriod"`
+	// InterruptionDrainLastPriorityClasses are priority classes whose nodes are drained last on concurrent interruptions
+	InterruptionDrainLastPriorityClasses []string `json:"aws.interruptionDrainLastPriorityClasses,omitempty"`
+	// InterruptionIgnoredMessageTypes are interruption message types that are deleted without acting on their nodes
+	InterruptionIgnoredMessageTypes []string `json:"aws.interruptionIgnoredMessageTypes,omitempty"`
+	// InterruptionEventPath is the dot-separated path of the EventBridge event in interruption messages
+	InterruptionEventPath string `json:"aws.interruptionEventPath"`
+	// InterruptionReceiveMaxBackoff caps the backoff of throttled receives from the interruption queue
+	InterruptionReceiveMaxBackoff metav1.Duration `json:"aws.interruptionReceiveMaxBackoff"`
+	// InterruptionInfrastructureInterval is how often the interruption infrastructure is re-verified
+	InterruptionInfrastructureInterval metav1.Duration `json:"aws.interruptionInfrastructureInterval"`
+	// InstanceTypeDataSource is whether instance types are described by the EC2 API or the embedded static dataset
+	InstanceTypeDataSource InstanceTypeDataSource `json:"aws.instanceTypeDataSource" validate:"required,oneof=API Static"`
+	// InstanceTypeOfferingsParallelism is how many DescribeInstanceTypeOfferings calls run concurrently on a cold start
+	InstanceTypeOfferingsParallelism int `json:"aws.instanceTypeOfferingsParallelism,string" validate:"min=1"`
+	// InstanceTypeOfferingsLocationType is the location type that instance type offerings are requested by
+	InstanceTypeOfferingsLocationType string `json:"aws.instanceTypeOfferingsLocationType" validate:"required,oneof=availability-zone availability-zone-id"`
+	// InstanceTypeDiscoveryRegions are other regions whose offerings are served by the offerings API
+	InstanceTypeDiscoveryRegions []string        `json:"aws.instanceTypeDiscoveryRegions,omitempty"`
+	CreateFleetTimeout           metav1.Duration `json:"aws.createFleetTimeout"`
+	// CreateFleetBatchSize is the maximum number of identical launches that are batched into a CreateFleet call
+	CreateFleetBatchSize int `json:"aws.createFleetBatchSize,string" validate:"min=1,max=1000"`
+	// SSMRetryAttempts is the number of attempts of SSM parameter lookups that fail with a transient error
+	SSMRetryAttempts int             `json:"aws.ssmRetryAttempts,string" validate:"min=1"`
+	SSMRetryDelay    metav1.Duration `json:"aws.ssmRetryDelay"`
+	// NameTagTemplate renders the Name tag of launched instances
+	NameTagTemplate string `json:"aws.nameTagTemplate"`
+	// EnableOfferingsAPI serves the discovered instance type offerings from the metrics endpoint
+	EnableOfferingsAPI bool `json:"aws.enableOfferingsAPI,string"`
+	// EnableQuotaCheck skips instance types that would exceed the remaining vCPU service quota
+	EnableQuotaCheck bool `json:"aws.enableQuotaCheck,string"`
+	// VCPULimitBackoff is how long instance types that failed with VcpuLimitExceeded are skipped
+	VCPULimitBackoff metav1.Duration `json:"aws.vcpuLimitBackoff"`
+	// SpreadSubnetsWithinZone spreads launches across the subnets of a zone by their available IP addresses
+	SpreadSubnetsWithinZone bool `json:"aws.spreadSubnetsWithinZone,string"`
+	// SpotUnavailableBehavior is what happens to spot launches when no spot offerings are available
+	SpotUnavailableBehavior SpotUnavailableBehavior `json:"aws.spotUnavailableBehavior" validate:"required,oneof=FallbackToOnDemand WaitForSpot"`
+	// EnableAllocationAnnotations annotates launched nodes with their allocation strategy and offering price
+	EnableAllocationAnnotations bool `json:"aws.enableAllocationAnnotations,string"`
+	// EnableAMIDriftDetection marks nodes whose AMI their node template no longer resolves to as drifted
+	EnableAMIDriftDetection bool `json:"aws.enableAMIDriftDetection,string"`
+	// ProvisioningDecisionSink is where records of launches are written, either "log" or the path of a file
+	ProvisioningDecisionSink string `json:"aws.provisioningDecisionSink"`
+	// ProvisioningDecisionsPerMinute bounds the number of provisioning decisions that are recorded
+	ProvisioningDecisionsPerMinute int `json:"aws.provisioningDecisionsPerMinute,string" validate:"min=1"`
+	// InstanceEventBus is the EventBridge event bus that instance launches and terminations are published to
+	InstanceEventBus string `json:"aws.instanceEventBus"`
+	// InstanceEventsPerMinute bounds the number of instance events that are published
+	InstanceEventsPerMinute int `json:"aws.instanceEventsPerMinute,string" validate:"min=1"`
+	// DescribeInstancesBatchSize is the maximum number of instance IDs per DescribeInstances call
+	DescribeInstancesBatchSize int `json:"aws.describeInstancesBatchSize,string" validate:"min=1,max=1000"`
+	// DescribeSubnetsPageSize is the number of subnets that are requested per DescribeSubnets call
+	DescribeSubnetsPageSize int `json:"aws.describeSubnetsPageSize,string" validate:"min=5,max=1000"`
+	// MaxSubnetsPerSelector is the maximum number of subnets that a subnet selector discovers
+	MaxSubnetsPerSelector int `json:"aws.maxSubnetsPerSelector,string" validate:"min=1"`
+	// PricingRefreshPeriod is how often on-demand and spot prices are refreshed
+	PricingRefreshPeriod metav1.Duration `json:"aws.pricingRefreshPeriod"`
+	// EnableVersionTags tags launched resources with the Karpenter version and the node template generation
+	EnableVersionTags bool `json:"aws.enableVersionTags,string"`
+	// CapacityOverrides maps instance types or families to the capacity that their instances advertise
+	CapacityOverrides map[string]v1.ResourceList `json:"aws.capacityOverrides,omitempty"`
+	// ReservedHeadroom is the capacity that's reserved on every node on top of the kubelet reservations
+	ReservedHeadroom v1.ResourceList `json:"aws.reservedHeadroom,omitempty"`
+	// ExcludedInstanceTypes are glob patterns of instance types that are never launched
+	ExcludedInstanceTypes []string `json:"aws.excludedInstanceTypes,omitempty"`
+	// InstanceTypeFilters are DescribeInstanceTypes filters that narrow down the discovered instance types
+	InstanceTypeFilters map[string][]string `json:"aws.instanceTypeFilters,omitempty"`
+	Tags                map[string]string   `json:"aws.tags,omitempty"`
+	// InterruptionInfrastructureTags are tags that are applied to the interruption infrastructure in addition to Tags
+	InterruptionInfrastructureTags map[string]string `json:"aws.interruptionInfrastructureTags,omitempty"`
 }
 
+// reservedInstanceTypeFilters are the DescribeInstanceTypes filters that instance types are always discovered with
+var reservedInstanceTypeFilters = []string{"supported-virtualization-type", "processor-info.supported-architecture"}
+
 // NewSettingsFromConfigMap creates a Settings from the supplied ConfigMap
 func NewSettingsFromConfigMap(cm *v1.ConfigMap) (Settings, error) {
 	s := defaultSettings
@@ -78,14 +274,55 @@ func NewSettingsFromConfigMap(cm *v1.ConfigMap) (Settings, error) {
 	if err := configmap.Parse(cm.Data,
 		configmap.AsString("aws.clusterName", &s.ClusterName),
 		configmap.AsString("aws.clusterEndpoint", &s.ClusterEndpoint),
+		configmap.AsString("aws.clusterVPCID", &s.ClusterVPCID),
 		configmap.AsString("aws.defaultInstanceProfile", &s.DefaultInstanceProfile),
+		AsTypedString("aws.nodeTemplateValidation", &s.NodeTemplateValidation),
+		configmap.AsString("aws.launchTemplateNamePrefix", &s.LaunchTemplateNamePrefix),
 		configmap.AsBool("aws.enablePodENI", &s.EnablePodENI),
 		configmap.AsBool("aws.enableENILimitedPodDensity", &s.EnableENILimitedPodDensity),
 		configmap.AsBool("aws.isolatedVPC", &s.IsolatedVPC),
 		AsTypedString("aws.nodeNameConvention", &s.NodeNameConvention),
 		configmap.AsFloat64("aws.vmMemoryOverheadPercent", &s.VMMemoryOverheadPercent),
 		configmap.AsBool("aws.enableInterruptionHandling", &s.EnableInterruptionHandling),
+		AsMetaDuration("aws.interruptionMessageMaxAge", &s.InterruptionMessageMaxAge),
+		AsTypedString("aws.interruptionUnmatchedNodeBehavior", &s.InterruptionUnmatchedNodeBehavior),
+		AsMetaDuration("aws.interruptionUnmatchedNodeGracePeriod", &s.InterruptionUnmatchedNodeGracePeriod),
+		AsStringSlice("aws.interruptionDrainLastPriorityClasses", &s.InterruptionDrainLastPriorityClasses),
+		AsStringSlice("aws.interruptionIgnoredMessageTypes", &s.InterruptionIgnoredMessageTypes),
+		configmap.AsString("aws.interruptionEventPath", &s.InterruptionEventPath),
+		AsMetaDuration("aws.interruptionReceiveMaxBackoff", &s.InterruptionReceiveMaxBackoff),
+		AsMetaDuration("aws.interruptionInfrastructureInterval", &s.InterruptionInfrastructureInterval),
+		AsTypedString("aws.instanceTypeDataSource", &s.InstanceTypeDataSource),
+		configmap.AsInt("aws.instanceTypeOfferingsParallelism", &s.InstanceTypeOfferingsParallelism),
+		configmap.AsString("aws.instanceTypeOfferingsLocationType", &s.InstanceTypeOfferingsLocationType),
+		AsStringSlice("aws.instanceTypeDiscoveryRegions", &s.InstanceTypeDiscoveryRegions),
+		AsMetaDuration("aws.createFleetTimeout", &s.CreateFleetTimeout),
+		configmap.AsInt("aws.createFleetBatchSize", &s.CreateFleetBatchSize),
+		configmap.AsInt("aws.ssmRetryAttempts", &s.SSMRetryAttempts),
+		AsMetaDuration("aws.ssmRetryDelay", &s.SSMRetryDelay),
+		configmap.AsString("aws.nameTagTemplate", &s.NameTagTemplate),
+		configmap.AsBool("aws.enableOfferingsAPI", &s.EnableOfferingsAPI),
+		configmap.AsBool("aws.enableQuotaCheck", &s.EnableQuotaCheck),
+		AsMetaDuration("aws.vcpuLimitBackoff", &s.VCPULimitBackoff),
+		configmap.AsBool("aws.spreadSubnetsWithinZone", &s.SpreadSubnetsWithinZone),
+		AsTypedString("aws.spotUnavailableBehavior", &s.SpotUnavailableBehavior),
+		configmap.AsBool("aws.enableAllocationAnnotations", &s.EnableAllocationAnnotations),
+		configmap.AsBool("aws.enableAMIDriftDetection", &s.EnableAMIDriftDetection),
+		configmap.AsString("aws.provisioningDecisionSink", &s.ProvisioningDecisionSink),
+		configmap.AsInt("aws.provisioningDecisionsPerMinute", &s.ProvisioningDecisionsPerMinute),
+		configmap.AsString("aws.instanceEventBus", &s.InstanceEventBus),
+		configmap.AsInt("aws.instanceEventsPerMinute", &s.InstanceEventsPerMinute),
+		configmap.AsInt("aws.describeInstancesBatchSize", &s.DescribeInstancesBatchSize),
+		configmap.AsInt("aws.describeSubnetsPageSize", &s.DescribeSubnetsPageSize),
+		configmap.AsInt("aws.maxSubnetsPerSelector", &s.MaxSubnetsPerSelector),
+		AsMetaDuration("aws.pricingRefreshPeriod", &s.PricingRefreshPeriod),
+		configmap.AsBool("aws.enableVersionTags", &s.EnableVersionTags),
+		AsJSON("aws.capacityOverrides", &s.CapacityOverrides),
+		AsJSON("aws.reservedHeadroom", &s.ReservedHeadroom),
+		AsStringSlice("aws.excludedInstanceTypes", &s.ExcludedInstanceTypes),
+		AsJSON("aws.instanceTypeFilters", &s.InstanceTypeFilters),
 		AsMap("aws.tags", &s.Tags),
+		AsMap("aws.interruptionInfrastructureTags", &s.InterruptionInfrastructureTags),
 	); err != nil {
 		// Failing to parse means that there is some error in the Settings, so we should crash
 		panic(fmt.Sprintf("parsing settings, %v", err))
@@ -101,9 +338,25 @@ func (s Settings) MarshalJSON() ([]byte, error) {
 	type internal Settings
 	d := map[string]string{}
 
-	// Store a value of tags locally, so we can marshal the rest of the struct
+	// Store a value of tags, capacity overrides, the reserved headroom and string slices locally, so we can marshal the rest of the struct
 	tags := s.Tags
 	s.Tags = nil
+	interruptionInfrastructureTags := s.InterruptionInfrastructureTags
+	s.InterruptionInfrastructureTags = nil
+	capacityOverrides := s.CapacityOverrides
+	s.CapacityOverrides = nil
+	reservedHeadroom := s.ReservedHeadroom
+	s.ReservedHeadroom = nil
+	excludedInstanceTypes := s.ExcludedInstanceTypes
+	s.ExcludedInstanceTypes = nil
+	instanceTypeFilters := s.InstanceTypeFilters
+	s.InstanceTypeFilters = nil
+	drainLastPriorityClasses := s.InterruptionDrainLastPriorityClasses
+	s.InterruptionDrainLastPriorityClasses = nil
+	ignoredMessageTypes := s.InterruptionIgnoredMessageTypes
+	s.InterruptionIgnoredMessageTypes = nil
+	discoveryRegions := s.InstanceTypeDiscoveryRegions
+	s.InstanceTypeDiscoveryRegions = nil
 
 	raw, err := json.Marshal(internal(s))
 	if err != nil {
@@ -116,6 +369,42 @@ func (s Settings) MarshalJSON() ([]byte, error) {
 	if err = FromMap(tags)("aws.tags", &d); err != nil {
 		return nil, fmt.Errorf("rewinding tags into map, %w", err)
 	}
+	if err = FromMap(interruptionInfrastructureTags)("aws.interruptionInfrastructureTags", &d); err != nil {
+		return nil, fmt.Errorf("rewinding interruption infrastructure tags into map, %w", err)
+	}
+	if len(capacityOverrides) > 0 {
+		raw, err = json.Marshal(capacityOverrides)
+		if err != nil {
+			return nil, fmt.Errorf("marshaling capacity overrides, %w", err)
+		}
+		d["aws.capacityOverrides"] = string(raw)
+	}
+	if len(reservedHeadroom) > 0 {
+		raw, err = json.Marshal(reservedHeadroom)
+		if err != nil {
+			return nil, fmt.Errorf("marshaling reserved headroom, %w", err)
+		}
+		d["aws.reservedHeadroom"] = string(raw)
+	}
+	if len(excludedInstanceTypes) > 0 {
+		d["aws.excludedInstanceTypes"] = strings.Join(excludedInstanceTypes, ",")
+	}
+	if len(instanceTypeFilters) > 0 {
+		raw, err = json.Marshal(instanceTypeFilters)
+		if err != nil {
+			return nil, fmt.Errorf("marshaling instance type filters, %w", err)
+		}
+		d["aws.instanceTypeFilters"] = string(raw)
+	}
+	if len(drainLastPriorityClasses) > 0 {
+		d["aws.interruptionDrainLastPriorityClasses"] = strings.Join(drainLastPriorityClasses, ",")
+	}
+	if len(ignoredMessageTypes) > 0 {
+		d["aws.interruptionIgnoredMessageTypes"] = strings.Join(ignoredMessageTypes, ",")
+	}
+	if len(discoveryRegions) > 0 {
+		d["aws.instanceTypeDiscoveryRegions"] = strings.Join(discoveryRegions, ",")
+	}
 	return json.Marshal(d)
 }
 
@@ -142,6 +431,26 @@ func (s Settings) Validate() error {
 	validate := validator.New()
 	return multierr.Combine(
 		s.validateEndpoint(),
+		s.validateClusterVPCID(),
+		s.validateLaunchTemplateNamePrefix(),
+		s.validateInterruptionMessageMaxAge(),
+		s.validateInterruptionUnmatchedNodeGracePeriod(),
+		s.validateInterruptionEventPath(),
+		s.validateInterruptionIgnoredMessageTypes(),
+		s.validateInterruptionReceiveMaxBackoff(),
+		s.validateInterruptionInfrastructureInterval(),
+		s.validateInstanceTypeDiscoveryRegions(),
+		s.validateCreateFleetTimeout(),
+		s.validateSSMRetryDelay(),
+		s.validateVCPULimitBackoff(),
+		s.validatePricingRefreshPeriod(),
+		s.validateNameTagTemplate(),
+		s.validateCapacityOverrides(),
+		s.validateReservedHeadroom(),
+		s.validateExcludedInstanceTypes(),
+		s.validateInstanceTypeFilters(),
+		s.validateProvisioningDecisionSink(),
+		s.validateInstanceEventBus(),
 		validate.Struct(s),
 	)
 }
@@ -156,6 +465,197 @@ func (s Settings) validateEndpoint() error {
 	return nil
 }
 
+func (s Settings) validateClusterVPCID() error {
+	if s.ClusterVPCID != "" && !vpcIDPattern.MatchString(s.ClusterVPCID) {
+		return fmt.Errorf("clusterVPCID %q is not a VPC ID", s.ClusterVPCID)
+	}
+	return nil
+}
+
+func (s Settings) validateLaunchTemplateNamePrefix() error {
+	if !launchTemplateNamePrefixPattern.MatchString(s.LaunchTemplateNamePrefix) {
+		return fmt.Errorf("launchTemplateNamePrefix %q must be non-empty and contain only letters, digits and the characters ().-/_", s.LaunchTemplateNamePrefix)
+	}
+	// Names are "<prefix>-<cluster name>-<hash>"
+	if length := len(s.LaunchTemplateNamePrefix) + len(s.ClusterName) + launchTemplateHashMaxLength + 2; length > launchTemplateNameMaxLength {
+		return fmt.Errorf("launchTemplateNamePrefix %q with clusterName %q makes launch template names up to %d characters long, which exceeds the limit of %d",
+			s.LaunchTemplateNamePrefix, s.ClusterName, length, launchTemplateNameMaxLength)
+	}
+	return nil
+}
+
+func (s Settings) validateInterruptionMessageMaxAge() error {
+	if s.InterruptionMessageMaxAge.Duration < 0 {
+		return fmt.Errorf("interruptionMessageMaxAge cannot be negative")
+	}
+	return nil
+}
+
+func (s Settings) validateInterruptionUnmatchedNodeGracePeriod() error {
+	if s.InterruptionUnmatchedNodeGracePeriod.Duration < 0 {
+		return fmt.Errorf("interruptionUnmatchedNodeGracePeriod cannot be negative")
+	}
+	return nil
+}
+
+func (s Settings) validateInterruptionEventPath() error {
+	if s.InterruptionEventPath != "" && lo.Contains(strings.Split(s.InterruptionEventPath, "."), "") {
+		return fmt.Errorf("interruptionEventPath %q has an empty field", s.InterruptionEventPath)
+	}
+	return nil
+}
+
+func (s Settings) validateInterruptionIgnoredMessageTypes() (err error) {
+	for _, messageType := range s.InterruptionIgnoredMessageTypes {
+		if !lo.Contains(InterruptionMessageTypes, messageType) {
+			err = multierr.Append(err, fmt.Errorf("interruptionIgnoredMessageTypes has an invalid message type %q, must be one of %v", messageType, InterruptionMessageTypes))
+		}
+	}
+	return err
+}
+
+func (s Settings) validateInterruptionReceiveMaxBackoff() error {
+	if s.InterruptionReceiveMaxBackoff.Duration <= 0 {
+		return fmt.Errorf("interruptionReceiveMaxBackoff must be positive")
+	}
+	return nil
+}
+
+func (s Settings) validateInterruptionInfrastructureInterval() error {
+	if s.InterruptionInfrastructureInterval.Duration <= 0 {
+		return fmt.Errorf("interruptionInfrastructureInterval must be positive")
+	}
+	return nil
+}
+
+func (s Settings) validateInstanceTypeDiscoveryRegions() (err error) {
+	for _, region := range s.InstanceTypeDiscoveryRegions {
+		if !regionPattern.MatchString(region) {
+			err = multierr.Append(err, fmt.Errorf("instanceTypeDiscoveryRegions has an invalid region %q", region))
+		}
+	}
+	return err
+}
+
+func (s Settings) validateCreateFleetTimeout() error {
+	if s.CreateFleetTimeout.Duration < 0 {
+		return fmt.Errorf("createFleetTimeout cannot be negative")
+	}
+	return nil
+}
+
+func (s Settings) validateSSMRetryDelay() error {
+	if s.SSMRetryDelay.Duration < 0 {
+		return fmt.Errorf("ssmRetryDelay cannot be negative")
+	}
+	return nil
+}
+
+func (s Settings) validateVCPULimitBackoff() error {
+	if s.VCPULimitBackoff.Duration < 0 {
+		return fmt.Errorf("vcpuLimitBackoff cannot be negative")
+	}
+	return nil
+}
+
+// validatePricingRefreshPeriod keeps refreshes from hammering the Pricing API
+func (s Settings) validatePricingRefreshPeriod() error {
+	if s.PricingRefreshPeriod.Duration < time.Minute {
+		return fmt.Errorf("pricingRefreshPeriod must be at least 1m")
+	}
+	return nil
+}
+
+func (s Settings) validateNameTagTemplate() (err error) {
+	for _, match := range nameTagTemplateVariable.FindAllStringSubmatch(s.NameTagTemplate, -1) {
+		if !lo.Contains(NameTagTemplateVariables, match[1]) {
+			err = multierr.Append(err, fmt.Errorf("nameTagTemplate has unsupported variable %q, must be one of %v", match[0], NameTagTemplateVariables))
+		}
+	}
+	return err
+}
+
+// RenderNameTagTemplate substitutes the values of variables into the NameTagTemplate. Variables without a value are
+// left in place and returned, so that callers can defer rendering until they are known.
+func (s Settings) RenderNameTagTemplate(values map[string]string) (name string, unresolved []string) {
+	name = nameTagTemplateVariable.ReplaceAllStringFunc(s.NameTagTemplate, func(variable string) string {
+		if value, ok := values[strings.Trim(variable, "{}")]; ok {
+			return value
+		}
+		unresolved = append(unresolved, variable)
+		return variable
+	})
+	return name, unresolved
+}
+
+func (s Settings) validateCapacityOverrides() (err error) {
+	for name, resources := range s.CapacityOverrides {
+		for resourceName, quantity := range resources {
+			if !lo.Contains([]v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory, v1.ResourcePods}, resourceName) {
+				err = multierr.Append(err, fmt.Errorf("capacityOverrides for %q has unsupported resource %q", name, resourceName))
+			}
+			if quantity.Sign() < 0 {
+				err = multierr.Append(err, fmt.Errorf("capacityOverrides for %q has negative %s", name, resourceName))
+			}
+		}
+	}
+	return err
+}
+
+func (s Settings) validateReservedHeadroom() (err error) {
+	for resourceName, quantity := range s.ReservedHeadroom {
+		if !lo.Contains([]v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory, v1.ResourceEphemeralStorage}, resourceName) {
+			err = multierr.Append(err, fmt.Errorf("reservedHeadroom has unsupported resource %q", resourceName))
+		}
+		if quantity.Sign() < 0 {
+			err = multierr.Append(err, fmt.Errorf("reservedHeadroom has negative %s", resourceName))
+		}
+	}
+	return err
+}
+
+func (s Settings) validateExcludedInstanceTypes() (err error) {
+	for _, pattern := range s.ExcludedInstanceTypes {
+		if _, e := path.Match(pattern, ""); e != nil {
+			err = multierr.Append(err, fmt.Errorf("excludedInstanceTypes has an invalid pattern %q, %w", pattern, e))
+		}
+	}
+	return err
+}
+
+func (s Settings) validateInstanceTypeFilters() (err error) {
+	// Filters are applied by the DescribeInstanceTypes API, so they can't narrow down the static dataset
+	if len(s.InstanceTypeFilters) > 0 && s.InstanceTypeDataSource == StaticInstanceTypeData {
+		err = multierr.Append(err, fmt.Errorf("instanceTypeFilters can't be used with the %s instanceTypeDataSource", StaticInstanceTypeData))
+	}
+	for name, values := range s.InstanceTypeFilters {
+		if name == "" {
+			err = multierr.Append(err, fmt.Errorf("instanceTypeFilters has a filter without a name"))
+		}
+		if lo.Contains(reservedInstanceTypeFilters, name) {
+			err = multierr.Append(err, fmt.Errorf("instanceTypeFilters can't override the %q filter", name))
+		}
+		if len(values) == 0 {
+			err = multierr.Append(err, fmt.Errorf("instanceTypeFilters has no values for the %q filter", name))
+		}
+	}
+	return err
+}
+
+func (s Settings) validateProvisioningDecisionSink() error {
+	if s.ProvisioningDecisionSink != "" && s.ProvisioningDecisionSink != ProvisioningDecisionSinkLog && !path.IsAbs(s.ProvisioningDecisionSink) {
+		return fmt.Errorf("provisioningDecisionSink %q must be %q or an absolute file path", s.ProvisioningDecisionSink, ProvisioningDecisionSinkLog)
+	}
+	return nil
+}
+
+func (s Settings) validateInstanceEventBus() error {
+	if s.InstanceEventBus != "" && !eventBusPattern.MatchString(s.InstanceEventBus) {
+		return fmt.Errorf("instanceEventBus %q is not the name or ARN of an event bus", s.InstanceEventBus)
+	}
+	return nil
+}
+
 func ToContext(ctx context.Context, s Settings) context.Context {
 	return context.WithValue(ctx, ContextKey, s)
 }
@@ -179,6 +679,46 @@ func AsTypedString[T ~string](key string, target *T) configmap.ParseFunc {
 	}
 }
 
+// AsMetaDuration parses the value at key as a time.Duration into the target, if it exists.
+func AsMetaDuration(key string, target *metav1.Duration) configmap.ParseFunc {
+	return func(data map[string]string) error {
+		if raw, ok := data[key]; ok {
+			val, err := time.ParseDuration(raw)
+			if err != nil {
+				return fmt.Errorf("failed to parse %q: %w", key, err)
+			}
+			*target = metav1.Duration{Duration: val}
+		}
+		return nil
+	}
+}
+
+// AsStringSlice parses the comma-separated value at key into the target, dropping empty entries, if it exists.
+func AsStringSlice(key string, target *[]string) configmap.ParseFunc {
+	return func(data map[string]string) error {
+		if raw, ok := data[key]; ok {
+			*target = lo.Filter(lo.Map(strings.Split(raw, ","), func(v string, _ int) string { return strings.TrimSpace(v) }),
+				func(v string, _ int) bool { return v != "" })
+		}
+		return nil
+	}
+}
+
+// AsJSON unmarshals the JSON value at key into the target, if it exists. The target is replaced rather than merged
+// into, so that maps shared with the default settings are never modified.
+func AsJSON[T any](key string, target *T) configmap.ParseFunc {
+	return func(data map[string]string) error {
+		if raw, ok := data[key]; ok {
+			var value T
+			if err := json.Unmarshal([]byte(raw), &value); err != nil {
+				return fmt.Errorf("failed to parse %q: %w", key, err)
+			}
+			*target = value
+		}
+		return nil
+	}
+}
+
 // AsMap parses any value with the prefix key into a map with suffixes as keys and values as values in the target map.
 // e.g. {"aws.tags.tag1":"value1"} gets parsed into the map Tags as {"tag1": "value1"}
 func AsMap(key string, target *map[string]string) configmap.ParseFunc {
@@ -16,7 +16,9 @@ package settings_test
 
 import (
 	"context"
+	"strings"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -46,39 +48,175 @@ var _ = Describe("Validation", func() {
 			},
 		}
 		s, _ := settings.NewSettingsFromConfigMap(cm)
+		Expect(s.ClusterVPCID).To(BeEmpty())
 		Expect(s.DefaultInstanceProfile).To(Equal(""))
+		Expect(s.NodeTemplateValidation).To(Equal(settings.LenientValidation))
+		Expect(s.LaunchTemplateNamePrefix).To(Equal("Karpenter"))
 		Expect(s.EnablePodENI).To(BeFalse())
 		Expect(s.EnableENILimitedPodDensity).To(BeTrue())
 		Expect(s.IsolatedVPC).To(BeFalse())
 		Expect(s.NodeNameConvention).To(Equal(settings.IPName))
 		Expect(s.VMMemoryOverheadPercent).To(Equal(0.075))
+		Expect(s.InterruptionMessageMaxAge.Duration).To(BeZero())
+		Expect(s.InterruptionUnmatchedNodeBehavior).To(Equal(settings.DeleteMessage))
+		Expect(s.InterruptionUnmatchedNodeGracePeriod.Duration).To(Equal(time.Minute * 2))
+		Expect(s.InterruptionDrainLastPriorityClasses).To(BeEmpty())
+		Expect(s.InterruptionIgnoredMessageTypes).To(BeEmpty())
+		Expect(s.InterruptionEventPath).To(BeEmpty())
+		Expect(s.InterruptionReceiveMaxBackoff.Duration).To(Equal(time.Minute))
+		Expect(s.InterruptionInfrastructureInterval.Duration).To(Equal(time.Minute * 5))
+		Expect(s.InstanceTypeDataSource).To(Equal(settings.APIInstanceTypeData))
+		Expect(s.InstanceTypeOfferingsParallelism).To(Equal(5))
+		Expect(s.InstanceTypeOfferingsLocationType).To(Equal("availability-zone"))
+		Expect(s.InstanceTypeDiscoveryRegions).To(BeEmpty())
+		Expect(s.CreateFleetTimeout.Duration).To(Equal(time.Minute))
+		Expect(s.CreateFleetBatchSize).To(Equal(1000))
+		Expect(s.SSMRetryAttempts).To(Equal(3))
+		Expect(s.SSMRetryDelay.Duration).To(Equal(time.Second))
+		Expect(s.NameTagTemplate).To(BeEmpty())
+		Expect(s.EnableOfferingsAPI).To(BeFalse())
+		Expect(s.EnableQuotaCheck).To(BeFalse())
+		Expect(s.VCPULimitBackoff.Duration).To(Equal(5 * time.Minute))
+		Expect(s.SpreadSubnetsWithinZone).To(BeFalse())
+		Expect(s.SpotUnavailableBehavior).To(Equal(settings.FallbackToOnDemand))
+		Expect(s.EnableAllocationAnnotations).To(BeFalse())
+		Expect(s.EnableAMIDriftDetection).To(BeFalse())
+		Expect(s.ProvisioningDecisionSink).To(BeEmpty())
+		Expect(s.ProvisioningDecisionsPerMinute).To(Equal(60))
+		Expect(s.InstanceEventBus).To(BeEmpty())
+		Expect(s.InstanceEventsPerMinute).To(Equal(60))
+		Expect(s.DescribeInstancesBatchSize).To(Equal(1000))
+		Expect(s.DescribeSubnetsPageSize).To(Equal(1000))
+		Expect(s.MaxSubnetsPerSelector).To(Equal(500))
+		Expect(s.PricingRefreshPeriod.Duration).To(Equal(12 * time.Hour))
+		Expect(s.EnableVersionTags).To(BeFalse())
+		Expect(s.CapacityOverrides).To(BeEmpty())
+		Expect(s.ReservedHeadroom).To(BeEmpty())
+		Expect(s.ExcludedInstanceTypes).To(BeEmpty())
+		Expect(s.InstanceTypeFilters).To(BeEmpty())
 		Expect(len(s.Tags)).To(BeZero())
+		Expect(s.InterruptionInfrastructureTags).To(BeEmpty())
 	})
 	It("should succeed to set custom values", func() {
 		cm := &v1.ConfigMap{
 			Data: map[string]string{
-				"aws.clusterEndpoint":            "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
-				"aws.clusterName":                "my-cluster",
-				"aws.defaultInstanceProfile":     "karpenter",
-				"aws.enablePodENI":               "true",
-				"aws.enableENILimitedPodDensity": "false",
-				"aws.isolatedVPC":                "true",
-				"aws.nodeNameConvention":         "resource-name",
-				"aws.vmMemoryOverheadPercent":    "0.1",
-				"aws.tags.tag1":                  "value1",
-				"aws.tags.tag2":                  "value2",
+				"aws.clusterEndpoint":                      "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":                          "my-cluster",
+				"aws.clusterVPCID":                         "vpc-0123456789abcdef0",
+				"aws.defaultInstanceProfile":               "karpenter",
+				"aws.nodeTemplateValidation":               "Strict",
+				"aws.launchTemplateNamePrefix":             "team-a/karpenter",
+				"aws.enablePodENI":                         "true",
+				"aws.enableENILimitedPodDensity":           "false",
+				"aws.isolatedVPC":                          "true",
+				"aws.nodeNameConvention":                   "resource-name",
+				"aws.vmMemoryOverheadPercent":              "0.1",
+				"aws.interruptionMessageMaxAge":            "10m",
+				"aws.interruptionUnmatchedNodeBehavior":    "Requeue",
+				"aws.interruptionUnmatchedNodeGracePeriod": "5m",
+				"aws.interruptionDrainLastPriorityClasses": "high-priority, system-cluster-critical",
+				"aws.interruptionIgnoredMessageTypes":      "SpotInterruption, RebalanceRecommendation",
+				"aws.interruptionEventPath":                "detail.event",
+				"aws.interruptionReceiveMaxBackoff":        "30s",
+				"aws.interruptionInfrastructureInterval":   "1m",
+				"aws.instanceTypeOfferingsParallelism":     "10",
+				"aws.instanceTypeOfferingsLocationType":    "availability-zone-id",
+				"aws.instanceTypeDiscoveryRegions":         "us-east-1, eu-west-1",
+				"aws.createFleetTimeout":                   "30s",
+				"aws.createFleetBatchSize":                 "10",
+				"aws.ssmRetryAttempts":                     "5",
+				"aws.ssmRetryDelay":                        "2s",
+				"aws.nameTagTemplate":                      "karpenter-{provisioner}-{instance-id}",
+				"aws.enableOfferingsAPI":                   "true",
+				"aws.enableQuotaCheck":                     "true",
+				"aws.vcpuLimitBackoff":                     "10m",
+				"aws.spreadSubnetsWithinZone":              "true",
+				"aws.spotUnavailableBehavior":              "WaitForSpot",
+				"aws.enableAllocationAnnotations":          "true",
+				"aws.enableAMIDriftDetection":              "true",
+				"aws.provisioningDecisionSink":             "/var/log/karpenter/decisions.jsonl",
+				"aws.provisioningDecisionsPerMinute":       "10",
+				"aws.instanceEventBus":                     "arn:aws:events:us-west-2:111222333444:event-bus/karpenter",
+				"aws.instanceEventsPerMinute":              "30",
+				"aws.describeInstancesBatchSize":           "500",
+				"aws.describeSubnetsPageSize":              "100",
+				"aws.maxSubnetsPerSelector":                "50",
+				"aws.pricingRefreshPeriod":                 "1h",
+				"aws.enableVersionTags":                    "true",
+				"aws.capacityOverrides":                    `{"m5.large":{"memory":"6Gi"},"c5":{"cpu":"1","pods":"20"}}`,
+				"aws.reservedHeadroom":                     `{"cpu":"250m","memory":"512Mi"}`,
+				"aws.excludedInstanceTypes":                "t2.*, m5.metal",
+				"aws.instanceTypeFilters":                  `{"current-generation":["true"],"instance-type":["m5.*","c6g.*"]}`,
+				"aws.tags.tag1":                            "value1",
+				"aws.tags.tag2":                            "value2",
+				"aws.interruptionInfrastructureTags.team":  "platform",
 			},
 		}
 		s, _ := settings.NewSettingsFromConfigMap(cm)
+		Expect(s.ClusterVPCID).To(Equal("vpc-0123456789abcdef0"))
 		Expect(s.DefaultInstanceProfile).To(Equal("karpenter"))
+		Expect(s.NodeTemplateValidation).To(Equal(settings.StrictValidation))
+		Expect(s.LaunchTemplateNamePrefix).To(Equal("team-a/karpenter"))
 		Expect(s.EnablePodENI).To(BeTrue())
 		Expect(s.EnableENILimitedPodDensity).To(BeFalse())
 		Expect(s.IsolatedVPC).To(BeTrue())
 		Expect(s.NodeNameConvention).To(Equal(settings.ResourceName))
 		Expect(s.VMMemoryOverheadPercent).To(Equal(0.1))
+		Expect(s.InterruptionMessageMaxAge.Duration).To(Equal(time.Minute * 10))
+		Expect(s.InterruptionUnmatchedNodeBehavior).To(Equal(settings.RequeueMessage))
+		Expect(s.InterruptionUnmatchedNodeGracePeriod.Duration).To(Equal(time.Minute * 5))
+		Expect(s.InterruptionDrainLastPriorityClasses).To(Equal([]string{"high-priority", "system-cluster-critical"}))
+		Expect(s.InterruptionIgnoredMessageTypes).To(Equal([]string{"SpotInterruption", "RebalanceRecommendation"}))
+		Expect(s.InterruptionEventPath).To(Equal("detail.event"))
+		Expect(s.InterruptionReceiveMaxBackoff.Duration).To(Equal(time.Second * 30))
+		Expect(s.InterruptionInfrastructureInterval.Duration).To(Equal(time.Minute))
+		Expect(s.InstanceTypeOfferingsParallelism).To(Equal(10))
+		Expect(s.InstanceTypeOfferingsLocationType).To(Equal("availability-zone-id"))
+		Expect(s.InstanceTypeDiscoveryRegions).To(Equal([]string{"us-east-1", "eu-west-1"}))
+		Expect(s.CreateFleetTimeout.Duration).To(Equal(time.Second * 30))
+		Expect(s.CreateFleetBatchSize).To(Equal(10))
+		Expect(s.SSMRetryAttempts).To(Equal(5))
+		Expect(s.SSMRetryDelay.Duration).To(Equal(time.Second * 2))
+		Expect(s.NameTagTemplate).To(Equal("karpenter-{provisioner}-{instance-id}"))
+		Expect(s.EnableOfferingsAPI).To(BeTrue())
+		Expect(s.EnableQuotaCheck).To(BeTrue())
+		Expect(s.VCPULimitBackoff.Duration).To(Equal(10 * time.Minute))
+		Expect(s.SpreadSubnetsWithinZone).To(BeTrue())
+		Expect(s.SpotUnavailableBehavior).To(Equal(settings.WaitForSpot))
+		Expect(s.EnableAllocationAnnotations).To(BeTrue())
+		Expect(s.EnableAMIDriftDetection).To(BeTrue())
+		Expect(s.ProvisioningDecisionSink).To(Equal("/var/log/karpenter/decisions.jsonl"))
+		Expect(s.ProvisioningDecisionsPerMinute).To(Equal(10))
+		Expect(s.InstanceEventBus).To(Equal("arn:aws:events:us-west-2:111222333444:event-bus/karpenter"))
+		Expect(s.InstanceEventsPerMinute).To(Equal(30))
+		Expect(s.DescribeInstancesBatchSize).To(Equal(500))
+		Expect(s.DescribeSubnetsPageSize).To(Equal(100))
+		Expect(s.MaxSubnetsPerSelector).To(Equal(50))
+		Expect(s.PricingRefreshPeriod.Duration).To(Equal(time.Hour))
+		Expect(s.EnableVersionTags).To(BeTrue())
+		Expect(s.CapacityOverrides).To(HaveLen(2))
+		Expect(lo.ToPtr(s.CapacityOverrides["m5.large"][v1.ResourceMemory]).String()).To(Equal("6Gi"))
+		Expect(lo.ToPtr(s.CapacityOverrides["c5"][v1.ResourceCPU]).String()).To(Equal("1"))
+		Expect(lo.ToPtr(s.CapacityOverrides["c5"][v1.ResourcePods]).String()).To(Equal("20"))
+		Expect(s.ReservedHeadroom.Cpu().String()).To(Equal("250m"))
+		Expect(s.ReservedHeadroom.Memory().String()).To(Equal("512Mi"))
+		Expect(s.ExcludedInstanceTypes).To(Equal([]string{"t2.*", "m5.metal"}))
+		Expect(s.InstanceTypeFilters).To(Equal(map[string][]string{"current-generation": {"true"}, "instance-type": {"m5.*", "c6g.*"}}))
 		Expect(len(s.Tags)).To(Equal(2))
 		Expect(s.Tags).To(HaveKeyWithValue("tag1", "value1"))
 		Expect(s.Tags).To(HaveKeyWithValue("tag2", "value2"))
+		Expect(s.InterruptionInfrastructureTags).To(Equal(map[string]string{"team": "platform"}))
+	})
+	It("should succeed to set the static instance type data source", func() {
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":        "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":            "my-cluster",
+				"aws.instanceTypeDataSource": "Static",
+			},
+		}
+		s, _ := settings.NewSettingsFromConfigMap(cm)
+		Expect(s.InstanceTypeDataSource).To(Equal(settings.StaticInstanceTypeData))
 	})
 	It("should fail validation with panic when clusterName not included", func() {
 		defer ExpectPanic()
@@ -119,6 +257,447 @@ var _ = Describe("Validation", func() {
 		}
 		_, _ = settings.NewSettingsFromConfigMap(cm)
 	})
+	It("should fail validation with panic when interruptionMessageMaxAge is negative", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":           "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":               "my-cluster",
+				"aws.interruptionMessageMaxAge": "-1m",
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when nodeTemplateValidation is unsupported", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":        "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":            "my-cluster",
+				"aws.nodeTemplateValidation": "Permissive",
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when interruptionUnmatchedNodeBehavior is unsupported", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":                   "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":                       "my-cluster",
+				"aws.interruptionUnmatchedNodeBehavior": "Ignore",
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when interruptionUnmatchedNodeGracePeriod is negative", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":                      "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":                          "my-cluster",
+				"aws.interruptionUnmatchedNodeGracePeriod": "-1m",
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when provisioningDecisionSink is a relative path", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":          "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":              "my-cluster",
+				"aws.provisioningDecisionSink": "decisions.jsonl",
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when provisioningDecisionsPerMinute is less than one", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":                "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":                    "my-cluster",
+				"aws.provisioningDecisionsPerMinute": "0",
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when instanceEventBus is not an event bus", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":  "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":      "my-cluster",
+				"aws.instanceEventBus": "arn:aws:sqs:us-west-2:111222333444:karpenter",
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when instanceEventsPerMinute is less than one", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":         "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":             "my-cluster",
+				"aws.instanceEventsPerMinute": "0",
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when instanceTypeOfferingsParallelism is less than one", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":                  "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":                      "my-cluster",
+				"aws.instanceTypeOfferingsParallelism": "0",
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when createFleetBatchSize is less than 1", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":      "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":          "my-cluster",
+				"aws.createFleetBatchSize": "0",
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when describeSubnetsPageSize is less than 5", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":         "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":             "my-cluster",
+				"aws.describeSubnetsPageSize": "4",
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when describeSubnetsPageSize is more than 1000", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":         "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":             "my-cluster",
+				"aws.describeSubnetsPageSize": "1001",
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when maxSubnetsPerSelector is less than one", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":       "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":           "my-cluster",
+				"aws.maxSubnetsPerSelector": "0",
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when describeInstancesBatchSize is more than 1000", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":            "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":                "my-cluster",
+				"aws.describeInstancesBatchSize": "1001",
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when instanceTypeOfferingsLocationType is not a supported location type", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":                   "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":                       "my-cluster",
+				"aws.instanceTypeOfferingsLocationType": "region",
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when instanceTypeDiscoveryRegions has an invalid region", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":              "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":                  "my-cluster",
+				"aws.instanceTypeDiscoveryRegions": "us-east-1,us-east-1a",
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when createFleetTimeout is negative", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":    "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":        "my-cluster",
+				"aws.createFleetTimeout": "-1s",
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when ssmRetryAttempts is less than 1", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":  "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":      "my-cluster",
+				"aws.ssmRetryAttempts": "0",
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when ssmRetryDelay is negative", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint": "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":     "my-cluster",
+				"aws.ssmRetryDelay":   "-1s",
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when launchTemplateNamePrefix has an invalid character", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":          "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":              "my-cluster",
+				"aws.launchTemplateNamePrefix": "team a",
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when launchTemplateNamePrefix makes launch template names too long", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":          "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":              "my-cluster",
+				"aws.launchTemplateNamePrefix": strings.Repeat("a", 100),
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when clusterVPCID isn't a VPC ID", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint": "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":     "my-cluster",
+				"aws.clusterVPCID":    "subnet-0123456789abcdef0",
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when interruptionEventPath has an empty field", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":       "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":           "my-cluster",
+				"aws.interruptionEventPath": "detail..event",
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when interruptionIgnoredMessageTypes has an invalid message type", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":                 "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":                     "my-cluster",
+				"aws.interruptionIgnoredMessageTypes": "SpotInterruption,SpotInterruptionKind",
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when interruptionReceiveMaxBackoff is not positive", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":               "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":                   "my-cluster",
+				"aws.interruptionReceiveMaxBackoff": "0s",
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when interruptionInfrastructureInterval is not positive", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":                    "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":                        "my-cluster",
+				"aws.interruptionInfrastructureInterval": "-5m",
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when vcpuLimitBackoff is negative", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":  "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":      "my-cluster",
+				"aws.vcpuLimitBackoff": "-1s",
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when pricingRefreshPeriod is less than a minute", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":      "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":          "my-cluster",
+				"aws.pricingRefreshPeriod": "30s",
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when nameTagTemplate has an unsupported variable", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint": "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":     "my-cluster",
+				"aws.nameTagTemplate": "karpenter-{provisioner}-{hostname}",
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when capacityOverrides is not valid json", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":   "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":       "my-cluster",
+				"aws.capacityOverrides": `{"m5.large":`,
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when capacityOverrides has an unsupported resource", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":   "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":       "my-cluster",
+				"aws.capacityOverrides": `{"m5.large":{"ephemeral-storage":"10Gi"}}`,
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when capacityOverrides has a negative quantity", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":   "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":       "my-cluster",
+				"aws.capacityOverrides": `{"m5":{"memory":"-1Gi"}}`,
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when reservedHeadroom has an unsupported resource", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":  "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":      "my-cluster",
+				"aws.reservedHeadroom": `{"pods":"5"}`,
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when reservedHeadroom has a negative quantity", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":  "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":      "my-cluster",
+				"aws.reservedHeadroom": `{"cpu":"-100m"}`,
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when spotUnavailableBehavior is unsupported", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":         "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":             "my-cluster",
+				"aws.spotUnavailableBehavior": "Ignore",
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when excludedInstanceTypes has an invalid pattern", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":       "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":           "my-cluster",
+				"aws.excludedInstanceTypes": "m5.[large",
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when instanceTypeFilters has a filter without values", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":     "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":         "my-cluster",
+				"aws.instanceTypeFilters": `{"current-generation":[]}`,
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when instanceTypeFilters overrides a filter that's always applied", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":     "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":         "my-cluster",
+				"aws.instanceTypeFilters": `{"processor-info.supported-architecture":["arm64"]}`,
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when instanceTypeDataSource is unsupported", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":        "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":            "my-cluster",
+				"aws.instanceTypeDataSource": "File",
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
+	It("should fail validation with panic when instanceTypeFilters are used with the static instance type data source", func() {
+		defer ExpectPanic()
+		cm := &v1.ConfigMap{
+			Data: map[string]string{
+				"aws.clusterEndpoint":        "https://00000000000000000000000.gr7.us-west-2.eks.amazonaws.com",
+				"aws.clusterName":            "my-cluster",
+				"aws.instanceTypeDataSource": "Static",
+				"aws.instanceTypeFilters":    `{"current-generation":["true"]}`,
+			},
+		}
+		_, _ = settings.NewSettingsFromConfigMap(cm)
+	})
 })
 
 var _ = Describe("Unmarshalling", func() {
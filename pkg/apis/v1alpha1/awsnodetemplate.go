@@ -15,21 +15,149 @@ limitations under the License.
 package v1alpha1
 
 import (
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+)
+
+const (
+	// AMIReady indicates whether the default AMIs of the AWSNodeTemplate could be resolved from SSM
+	AMIReady apis.ConditionType = "AMIReady"
+	// BaseTemplateResolved indicates whether the chain of base templates of the AWSNodeTemplate could be resolved
+	BaseTemplateResolved apis.ConditionType = "BaseTemplateResolved"
+	// NetworkReady indicates whether the subnets and security groups of the AWSNodeTemplate belong to a single VPC,
+	// and to the VPC of the cluster when it's configured
+	NetworkReady apis.ConditionType = "NetworkReady"
+	// QueueReady indicates whether the interruption queue exists with all of its attributes configured, while
+	// interruption handling is enabled
+	QueueReady apis.ConditionType = "QueueReady"
+	// AMISelectionUnambiguous indicates whether each of the AMIs that the amiSelector selects has requirements, e.g.
+	// an architecture, that no other selected AMI has. It's informational and doesn't affect readiness, since the
+	// newest of the AMIs with the same requirements is launched.
+	AMISelectionUnambiguous apis.ConditionType = "AMISelectionUnambiguous"
 )
 
 // AWSNodeTemplateSpec is the top level specification for the AWS Karpenter Provider.
 // This will contain configuration necessary to launch instances in AWS.
 type AWSNodeTemplateSpec struct {
+	// BaseTemplateRef references an AWSNodeTemplate that this template inherits its settings from. Settings that
+	// aren't set on this template are taken from the base template, while tags, reserved resources and eviction
+	// thresholds are merged key by key with the values of this template taking precedence.
+	// +optional
+	BaseTemplateRef *BaseTemplateRef `json:"baseTemplateRef,omitempty"`
 	// UserData to be applied to the provisioned nodes.
 	// It must be in the appropriate format based on the AMIFamily in use. Karpenter will merge certain fields into
 	// this UserData to ensure nodes are being provisioned with the correct configuration.
 	// +optional
 	UserData *string `json:"userData,omitempty"`
 	AWS      `json:",inline"`
-	// AMISelector discovers AMIs to be used by Amazon EC2 tags.
+	// AMISelector discovers AMIs to be used by Amazon EC2 tags. The "aws-owners" key scopes the discovered AMIs
+	// to a comma-separated list of owners, which defaults to the account itself and Amazon.
 	// +optional
 	AMISelector map[string]string `json:"amiSelector,omitempty"`
+	// AMISelectorTerms discovers AMIs that match any of the terms, each of which is interpreted like the
+	// AMISelector. It can't be combined with the AMISelector.
+	// +optional
+	AMISelectorTerms []map[string]string `json:"amiSelectorTerms,omitempty"`
+	// IncludeDeprecatedAMIs allows AMIs discovered by the AMISelector to be used after their deprecation time has passed.
+	// +optional
+	IncludeDeprecatedAMIs *bool `json:"includeDeprecatedAMIs,omitempty"`
+	// PinAMI records the AMIs resolved from SSM in the status and keeps launching nodes with them until the
+	// AMIs are rolled forward with the karpenter.k8s.aws/ami-rollforward annotation.
+	// +optional
+	PinAMI *bool `json:"pinAMI,omitempty"`
+	// FallbackAMIIDs are used in place of the default AMIs when they can't be resolved from SSM. Each instance type
+	// falls back to the newest of these AMIs that is compatible with it (e.g. by architecture).
+	// +optional
+	FallbackAMIIDs []string `json:"fallbackAMIIDs,omitempty"`
+	// RegistrationTaints are applied to nodes launched from this template when they register, in addition to the
+	// taints of the provisioner. Karpenter doesn't remove them, so they're meant to be removed by the component that
+	// prepares the node, e.g. a daemonset that has to run before any other pod.
+	// +optional
+	RegistrationTaints []v1.Taint `json:"registrationTaints,omitempty"`
+	// ContainerRuntime that the kubelet of nodes launched from this template uses, one of "containerd" or "dockerd"
+	// as supported by the AMI family. Takes precedence over the provisioner's kubelet configuration. Defaults to
+	// containerd, unless the instance types require docker.
+	// +optional
+	ContainerRuntime *string `json:"containerRuntime,omitempty"`
+	// SystemReserved overrides the resources reserved for OS system daemons and kernel memory on nodes launched
+	// from this template. Values take precedence over the provisioner's kubelet configuration.
+	// +optional
+	SystemReserved v1.ResourceList `json:"systemReserved,omitempty"`
+	// KubeReserved overrides the resources reserved for Kubernetes system components on nodes launched from this
+	// template. Values take precedence over the provisioner's kubelet configuration.
+	// +optional
+	KubeReserved v1.ResourceList `json:"kubeReserved,omitempty"`
+	// EvictionThreshold overrides the hard eviction thresholds (e.g. memory.available) of the kubelet on nodes
+	// launched from this template. Values take precedence over the provisioner's kubelet configuration.
+	// +optional
+	EvictionThreshold map[string]string `json:"evictionThreshold,omitempty"`
+	// EvictionSoft overrides the soft eviction thresholds of the kubelet on nodes launched from this template. Every
+	// soft threshold needs a grace period in EvictionSoftGracePeriod. Values take precedence over the provisioner's
+	// kubelet configuration.
+	// +optional
+	EvictionSoft map[string]string `json:"evictionSoft,omitempty"`
+	// EvictionSoftGracePeriod overrides how long the soft eviction thresholds of the kubelet have to be met before
+	// pods are evicted on nodes launched from this template. Values take precedence over the provisioner's kubelet
+	// configuration.
+	// +optional
+	EvictionSoftGracePeriod map[string]metav1.Duration `json:"evictionSoftGracePeriod,omitempty"`
+	// EvictionMaxPodGracePeriod overrides the maximum grace period (in seconds) of pods that are terminated because
+	// a soft eviction threshold was met on nodes launched from this template.
+	// +optional
+	EvictionMaxPodGracePeriod *int32 `json:"evictionMaxPodGracePeriod,omitempty"`
+}
+
+// BaseTemplateRef references the AWSNodeTemplate that an AWSNodeTemplate inherits from
+type BaseTemplateRef struct {
+	// Name of the base AWSNodeTemplate
+	Name string `json:"name"`
+}
+
+// AWSNodeTemplateStatus contains the resolved state of the AWSNodeTemplate
+type AWSNodeTemplateStatus struct {
+	// PinnedAMIs maps the SSM parameters that were queried for default AMIs to the AMI IDs that they resolved to.
+	// +optional
+	PinnedAMIs map[string]string `json:"pinnedAMIs,omitempty"`
+	// AMIRollforward is the value of the karpenter.k8s.aws/ami-rollforward annotation when the AMIs were last pinned.
+	// +optional
+	AMIRollforward string `json:"amiRollforward,omitempty"`
+	// EffectiveConfiguration is the configuration that nodes are launched with, after the base templates are merged
+	// and the selectors are resolved.
+	// +optional
+	EffectiveConfiguration *EffectiveConfiguration `json:"effectiveConfiguration,omitempty"`
+	// Conditions contains signals for the health of the AWSNodeTemplate
+	// +optional
+	Conditions apis.Conditions `json:"conditions,omitempty"`
+}
+
+// EffectiveConfiguration is the resolved configuration of an AWSNodeTemplate. The user data is only recorded as a
+// hash, since it may contain secrets.
+type EffectiveConfiguration struct {
+	// AMIFamily is the AMI family that nodes are launched with.
+	// +optional
+	AMIFamily string `json:"amiFamily,omitempty"`
+	// AMIs are the IDs of the AMIs selected by the amiSelector, or of the pinned AMIs when the default AMIs are pinned.
+	// +optional
+	AMIs []string `json:"amis,omitempty"`
+	// Subnets are the IDs of the subnets selected by the subnetSelector.
+	// +optional
+	Subnets []string `json:"subnets,omitempty"`
+	// SecurityGroups are the IDs of the security groups selected by the securityGroupSelector.
+	// +optional
+	SecurityGroups []string `json:"securityGroups,omitempty"`
+	// InstanceProfile is the instance profile that nodes are launched with.
+	// +optional
+	InstanceProfile string `json:"instanceProfile,omitempty"`
+	// LaunchTemplateName is the launch template that nodes are launched from, in place of a generated one.
+	// +optional
+	LaunchTemplateName string `json:"launchTemplateName,omitempty"`
+	// BlockDeviceMappings are the block devices that nodes are launched with.
+	// +optional
+	BlockDeviceMappings []*BlockDeviceMapping `json:"blockDeviceMappings,omitempty"`
+	// UserDataHash is a hash of the custom user data.
+	// +optional
+	UserDataHash string `json:"userDataHash,omitempty"`
 }
 
 // AWSNodeTemplate is the Schema for the AWSNodeTemplate API
@@ -40,7 +168,33 @@ type AWSNodeTemplate struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
-	Spec AWSNodeTemplateSpec `json:"spec,omitempty"`
+	Spec   AWSNodeTemplateSpec   `json:"spec,omitempty"`
+	Status AWSNodeTemplateStatus `json:"status,omitempty"`
+}
+
+// AMISelectors returns the terms that discover the AMIs of the template, an AMI is used if it matches any of them
+func (a *AWSNodeTemplateSpec) AMISelectors() []map[string]string {
+	if len(a.AMISelector) != 0 {
+		return []map[string]string{a.AMISelector}
+	}
+	return a.AMISelectorTerms
+}
+
+func (a *AWSNodeTemplate) StatusConditions() apis.ConditionManager {
+	return apis.NewLivingConditionSet(
+		AMIReady,
+		BaseTemplateResolved,
+		NetworkReady,
+		QueueReady,
+	).Manage(a)
+}
+
+func (a *AWSNodeTemplate) GetConditions() apis.Conditions {
+	return a.Status.Conditions
+}
+
+func (a *AWSNodeTemplate) SetConditions(conditions apis.Conditions) {
+	a.Status.Conditions = conditions
 }
 
 // AWSNodeTemplateList contains a list of AWSNodeTemplate
@@ -0,0 +1,124 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+// Inherit merges the settings of a base template into the spec, with the settings of the spec taking precedence.
+// Tags, sysctls, reserved resources, eviction thresholds and their grace periods are merged key by key and the metadata
+// options field by field, while any other setting that is set on the spec replaces the setting of the base template
+// as a whole. The BaseTemplateRef of the spec is left as is.
+func (a *AWSNodeTemplateSpec) Inherit(base *AWSNodeTemplateSpec) {
+	if base == nil {
+		return
+	}
+	base = base.DeepCopy()
+	a.UserData = inheritPtr(a.UserData, base.UserData)
+	a.AMIFamily = inheritPtr(a.AMIFamily, base.AMIFamily)
+	a.Context = inheritPtr(a.Context, base.Context)
+	a.InstanceProfile = inheritPtr(a.InstanceProfile, base.InstanceProfile)
+	a.SubnetSelector = inheritSelector(a.SubnetSelector, base.SubnetSelector)
+	a.SecurityGroupSelector = inheritSelector(a.SecurityGroupSelector, base.SecurityGroupSelector)
+	a.Tags = inheritMap(a.Tags, base.Tags)
+	a.PlacementGroup = inheritPtr(a.PlacementGroup, base.PlacementGroup)
+	a.RequireNitroTPM = inheritPtr(a.RequireNitroTPM, base.RequireNitroTPM)
+	a.PreferNewerGenerations = inheritPtr(a.PreferNewerGenerations, base.PreferNewerGenerations)
+	a.SpotPriceBiasPercent = inheritPtr(a.SpotPriceBiasPercent, base.SpotPriceBiasPercent)
+	if len(a.CapacityTypes) == 0 {
+		a.CapacityTypes = base.CapacityTypes
+	}
+	a.MaxPrice = inheritPtr(a.MaxPrice, base.MaxPrice)
+	if len(a.PreferredZones) == 0 {
+		a.PreferredZones = base.PreferredZones
+	}
+	a.PrefixDelegation = inheritPtr(a.PrefixDelegation, base.PrefixDelegation)
+	a.InstanceStorePolicy = inheritPtr(a.InstanceStorePolicy, base.InstanceStorePolicy)
+	a.HostContainers = inheritPtr(a.HostContainers, base.HostContainers)
+	a.Sysctls = inheritMap(a.Sysctls, base.Sysctls)
+	a.ContainerLogMaxSize = inheritPtr(a.ContainerLogMaxSize, base.ContainerLogMaxSize)
+	a.ContainerLogMaxFiles = inheritPtr(a.ContainerLogMaxFiles, base.ContainerLogMaxFiles)
+	if len(a.ImagesToPull) == 0 {
+		a.ImagesToPull = base.ImagesToPull
+	}
+	a.PostBootstrapCommand = inheritPtr(a.PostBootstrapCommand, base.PostBootstrapCommand)
+	a.ClusterEndpoint = inheritPtr(a.ClusterEndpoint, base.ClusterEndpoint)
+	a.LaunchTemplateName = inheritPtr(a.LaunchTemplateName, base.LaunchTemplateName)
+	a.MetadataOptions = inheritMetadataOptions(a.MetadataOptions, base.MetadataOptions)
+	if len(a.BlockDeviceMappings) == 0 {
+		a.BlockDeviceMappings = base.BlockDeviceMappings
+	}
+	a.KeyName = inheritPtr(a.KeyName, base.KeyName)
+	a.DisableAPITermination = inheritPtr(a.DisableAPITermination, base.DisableAPITermination)
+	a.InstanceInitiatedShutdownBehavior = inheritPtr(a.InstanceInitiatedShutdownBehavior, base.InstanceInitiatedShutdownBehavior)
+	// The amiSelector and amiSelectorTerms are a single setting, since they can't be combined
+	if len(a.AMISelectors()) == 0 {
+		a.AMISelector = base.AMISelector
+		a.AMISelectorTerms = base.AMISelectorTerms
+	}
+	a.IncludeDeprecatedAMIs = inheritPtr(a.IncludeDeprecatedAMIs, base.IncludeDeprecatedAMIs)
+	a.PinAMI = inheritPtr(a.PinAMI, base.PinAMI)
+	if len(a.FallbackAMIIDs) == 0 {
+		a.FallbackAMIIDs = base.FallbackAMIIDs
+	}
+	if len(a.RegistrationTaints) == 0 {
+		a.RegistrationTaints = base.RegistrationTaints
+	}
+	a.ContainerRuntime = inheritPtr(a.ContainerRuntime, base.ContainerRuntime)
+	a.SystemReserved = inheritMap(a.SystemReserved, base.SystemReserved)
+	a.KubeReserved = inheritMap(a.KubeReserved, base.KubeReserved)
+	a.EvictionThreshold = inheritMap(a.EvictionThreshold, base.EvictionThreshold)
+	a.EvictionSoft = inheritMap(a.EvictionSoft, base.EvictionSoft)
+	a.EvictionSoftGracePeriod = inheritMap(a.EvictionSoftGracePeriod, base.EvictionSoftGracePeriod)
+	a.EvictionMaxPodGracePeriod = inheritPtr(a.EvictionMaxPodGracePeriod, base.EvictionMaxPodGracePeriod)
+}
+
+func inheritPtr[T any](value, base *T) *T {
+	if value != nil {
+		return value
+	}
+	return base
+}
+
+// inheritSelector doesn't merge selectors key by key, since every additional key narrows down what the selector matches
+func inheritSelector(selector, base map[string]string) map[string]string {
+	if len(selector) != 0 {
+		return selector
+	}
+	return base
+}
+
+func inheritMap[M ~map[K]V, K comparable, V any](values, base M) M {
+	if len(base) == 0 {
+		return values
+	}
+	merged := make(M, len(values)+len(base))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range values {
+		merged[k] = v
+	}
+	return merged
+}
+
+func inheritMetadataOptions(metadataOptions, base *MetadataOptions) *MetadataOptions {
+	if metadataOptions == nil || base == nil {
+		return inheritPtr(metadataOptions, base)
+	}
+	return &MetadataOptions{
+		HTTPEndpoint:            inheritPtr(metadataOptions.HTTPEndpoint, base.HTTPEndpoint),
+		HTTPProtocolIPv6:        inheritPtr(metadataOptions.HTTPProtocolIPv6, base.HTTPProtocolIPv6),
+		HTTPPutResponseHopLimit: inheritPtr(metadataOptions.HTTPPutResponseHopLimit, base.HTTPPutResponseHopLimit),
+		HTTPTokens:              inheritPtr(metadataOptions.HTTPTokens, base.HTTPTokens),
+	}
+}
@@ -18,37 +18,93 @@ import (
 	"context"
 	"fmt"
 	"regexp"
+	"strconv"
+	"strings"
 
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"knative.dev/pkg/apis"
 
+	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
 	"github.com/aws/karpenter-core/pkg/utils/functional"
 )
 
 const (
-	userDataPath    = "userData"
-	amiSelectorPath = "amiSelector"
+	userDataPath                  = "userData"
+	amiSelectorPath               = "amiSelector"
+	amiSelectorTermsPath          = "amiSelectorTerms"
+	fallbackAMIIDsPath            = "fallbackAMIIDs"
+	containerRuntimePath          = "containerRuntime"
+	registrationTaintsPath        = "registrationTaints"
+	systemReservedPath            = "systemReserved"
+	kubeReservedPath              = "kubeReserved"
+	evictionThresholdPath         = "evictionThreshold"
+	evictionSoftPath              = "evictionSoft"
+	evictionSoftGracePeriodPath   = "evictionSoftGracePeriod"
+	evictionMaxPodGracePeriodPath = "evictionMaxPodGracePeriod"
+	baseTemplateRefPath           = "baseTemplateRef"
 )
 
 var (
 	amiRegex = regexp.MustCompile("ami-[0-9a-z]+")
+	// amiOwnerRegex matches the owner aliases and account IDs that DescribeImages accepts
+	amiOwnerRegex = regexp.MustCompile("^(self|amazon|aws-marketplace|[0-9]{12})$")
+	// amiNamePatternRegex matches AMI names, which are 3 to 128 letters, numbers and ()[] ./-'@_, with the * and ?
+	// wildcards of the DescribeImages name filter
+	amiNamePatternRegex = regexp.MustCompile(`^[a-zA-Z0-9()\[\] ./\-'@_*?]{1,128}$`)
 )
 
 func (a *AWSNodeTemplate) Validate(ctx context.Context) (errs *apis.FieldError) {
 	return errs.Also(
 		apis.ValidateObjectMetadata(a).ViaField("metadata"),
 		a.Spec.validate(ctx).ViaField("spec"),
+		a.validateBaseTemplateRef().ViaField("spec"),
 	)
 }
 
 func (a *AWSNodeTemplateSpec) validate(_ context.Context) (errs *apis.FieldError) {
 	return errs.Also(
-		a.AWS.Validate(),
+		a.validateAWS(),
 		a.validateUserData(),
 		a.validateAMISelector(),
+		a.validateAMISelectorTerms(),
 		a.validateAMIFamily(),
+		a.validateRequireNitroTPM(),
+		a.validateFallbackAMIIDs(),
+		a.validateContainerRuntime(),
+		a.validateRegistrationTaints(),
+		validateReservedResources(a.SystemReserved, systemReservedPath),
+		validateReservedResources(a.KubeReserved, kubeReservedPath),
+		validateEvictionThresholds(a.EvictionThreshold, evictionThresholdPath),
+		validateEvictionThresholds(a.EvictionSoft, evictionSoftPath),
+		a.validateEvictionSoftGracePeriod(),
+		a.validateEvictionMaxPodGracePeriod(),
 	)
 }
 
+func (a *AWSNodeTemplate) validateBaseTemplateRef() (errs *apis.FieldError) {
+	if a.Spec.BaseTemplateRef == nil {
+		return nil
+	}
+	if a.Spec.BaseTemplateRef.Name == "" {
+		return errs.Also(apis.ErrMissingField(fmt.Sprintf("%s.name", baseTemplateRefPath)))
+	}
+	// Cycles through other templates can only be detected once the base templates are resolved by the controller
+	if a.Spec.BaseTemplateRef.Name == a.Name {
+		errs = errs.Also(apis.ErrInvalidValue(a.Spec.BaseTemplateRef.Name, fmt.Sprintf("%s.name", baseTemplateRefPath), "AWSNodeTemplate cannot inherit from itself"))
+	}
+	return errs
+}
+
+func (a *AWSNodeTemplateSpec) validateAWS() *apis.FieldError {
+	if a.BaseTemplateRef != nil {
+		return a.AWS.validateInheriting()
+	}
+	return a.AWS.Validate()
+}
+
 func (a *AWSNodeTemplateSpec) validateUserData() (errs *apis.FieldError) {
 	if a.UserData == nil {
 		return nil
@@ -63,7 +119,16 @@ func (a *AWSNodeTemplateSpec) validateAMIFamily() (errs *apis.FieldError) {
 	if a.AMIFamily == nil {
 		return nil
 	}
-	if *a.AMIFamily == AMIFamilyCustom && a.AMISelector == nil {
+	// The amiSelector of a Custom template may be inherited from its base template
+	if *a.AMIFamily == AMIFamilyCustom && len(a.AMISelectors()) == 0 && a.BaseTemplateRef == nil {
+		errs = errs.Also(apis.ErrMissingField(amiSelectorPath))
+	}
+	return errs
+}
+
+func (a *AWSNodeTemplateSpec) validateRequireNitroTPM() (errs *apis.FieldError) {
+	// The default AMIs don't enable NitroTPM, so the AMIs have to be selected unless they're inherited
+	if lo.FromPtr(a.RequireNitroTPM) && len(a.AMISelectors()) == 0 && a.BaseTemplateRef == nil {
 		errs = errs.Also(apis.ErrMissingField(amiSelectorPath))
 	}
 	return errs
@@ -76,19 +141,208 @@ func (a *AWSNodeTemplateSpec) validateAMISelector() (errs *apis.FieldError) {
 	if a.LaunchTemplateName != nil {
 		errs = errs.Also(apis.ErrMultipleOneOf(amiSelectorPath, launchTemplatePath))
 	}
-	for key, value := range a.AMISelector {
+	if a.AMISelectorTerms != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf(amiSelectorPath, amiSelectorTermsPath))
+	}
+	return errs.Also(validateAMISelectorTerm(a.AMISelector, amiSelectorPath))
+}
+
+func (a *AWSNodeTemplateSpec) validateAMISelectorTerms() (errs *apis.FieldError) {
+	if a.AMISelectorTerms == nil {
+		return nil
+	}
+	if a.LaunchTemplateName != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf(amiSelectorTermsPath, launchTemplatePath))
+	}
+	for i, term := range a.AMISelectorTerms {
+		path := fmt.Sprintf("%s[%d]", amiSelectorTermsPath, i)
+		// An empty term would match every AMI of the owners
+		if len(term) == 0 {
+			errs = errs.Also(apis.ErrMissingField(path))
+		}
+		errs = errs.Also(validateAMISelectorTerm(term, path))
+	}
+	return errs
+}
+
+func validateAMISelectorTerm(term map[string]string, path string) (errs *apis.FieldError) {
+	for key, value := range term {
 		if key == "" || value == "" {
-			errs = errs.Also(apis.ErrInvalidValue("\"\"", fmt.Sprintf("%s['%s']", amiSelectorPath, key)))
+			errs = errs.Also(apis.ErrInvalidValue("\"\"", fmt.Sprintf("%s['%s']", path, key)))
 		}
 		if key == "aws-ids" {
 			for _, amiID := range functional.SplitCommaSeparatedString(value) {
 				if !amiRegex.MatchString(amiID) {
 					fieldValue := fmt.Sprintf("\"%s\"", amiID)
-					message := fmt.Sprintf("%s['%s'] must be a valid ami-id (regex: %s)", amiSelectorPath, key, amiRegex.String())
+					message := fmt.Sprintf("%s['%s'] must be a valid ami-id (regex: %s)", path, key, amiRegex.String())
 					errs = errs.Also(apis.ErrInvalidValue(fieldValue, message))
 				}
 			}
 		}
+		if key == "aws-name" {
+			for _, pattern := range functional.SplitCommaSeparatedString(value) {
+				if !amiNamePatternRegex.MatchString(pattern) {
+					fieldValue := fmt.Sprintf("\"%s\"", pattern)
+					message := fmt.Sprintf("%s['%s'] must be an ami name, optionally with * and ? wildcards", path, key)
+					errs = errs.Also(apis.ErrInvalidValue(fieldValue, message))
+				}
+			}
+		}
+		if key == "aws-owners" {
+			for _, owner := range functional.SplitCommaSeparatedString(value) {
+				if !amiOwnerRegex.MatchString(owner) {
+					fieldValue := fmt.Sprintf("\"%s\"", owner)
+					message := fmt.Sprintf("%s['%s'] must be self, amazon, aws-marketplace or an account id", path, key)
+					errs = errs.Also(apis.ErrInvalidValue(fieldValue, message))
+				}
+			}
+		}
+	}
+	return errs
+}
+
+func (a *AWSNodeTemplateSpec) validateFallbackAMIIDs() (errs *apis.FieldError) {
+	if a.FallbackAMIIDs == nil {
+		return nil
+	}
+	// Fallback AMIs are only used when the default AMIs can't be resolved from SSM
+	if a.AMISelector != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf(fallbackAMIIDsPath, amiSelectorPath))
+	}
+	if a.AMISelectorTerms != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf(fallbackAMIIDsPath, amiSelectorTermsPath))
+	}
+	if a.LaunchTemplateName != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf(fallbackAMIIDsPath, launchTemplatePath))
+	}
+	for i, amiID := range a.FallbackAMIIDs {
+		if !amiRegex.MatchString(amiID) {
+			errs = errs.Also(apis.ErrInvalidArrayValue(amiID, fallbackAMIIDsPath, i))
+		}
+	}
+	return errs
+}
+
+func (a *AWSNodeTemplateSpec) validateContainerRuntime() (errs *apis.FieldError) {
+	if a.ContainerRuntime == nil {
+		return nil
+	}
+	// The container runtime is passed to the bootstrap script of the generated user data
+	if a.LaunchTemplateName != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf(containerRuntimePath, launchTemplatePath))
+	}
+	// The AMI family of a template that inherits may be set by its base template
+	if a.AMIFamily == nil && a.BaseTemplateRef != nil {
+		return errs
+	}
+	amiFamily := lo.FromPtrOr(a.AMIFamily, AMIFamilyAL2)
+	supported, ok := SupportedContainerRuntimesByAMIFamily[amiFamily]
+	if !ok {
+		return errs.Also(apis.ErrInvalidValue(*a.ContainerRuntime, containerRuntimePath, fmt.Sprintf("The %s AMI family doesn't support selecting a container runtime", amiFamily)))
+	}
+	if !supported.Has(*a.ContainerRuntime) {
+		errs = errs.Also(apis.ErrInvalidValue(fmt.Sprintf("%s not in %v", *a.ContainerRuntime, strings.Join(supported.List(), ", ")), containerRuntimePath))
+	}
+	return errs
+}
+
+func (a *AWSNodeTemplateSpec) validateRegistrationTaints() (errs *apis.FieldError) {
+	if len(a.RegistrationTaints) == 0 {
+		return nil
+	}
+	// The taints are passed to the kubelet in the generated user data
+	if a.LaunchTemplateName != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf(registrationTaintsPath, launchTemplatePath))
+	}
+	if lo.FromPtr(a.AMIFamily) == AMIFamilyCustom {
+		errs = errs.Also(apis.ErrInvalidValue(AMIFamilyCustom, amiFamilyPath, fmt.Sprintf("%s aren't supported by the %s AMI family", registrationTaintsPath, AMIFamilyCustom)))
+	}
+	existing := map[string]struct{}{}
+	for i, taint := range a.RegistrationTaints {
+		for _, err := range validation.IsQualifiedName(taint.Key) {
+			errs = errs.Also(apis.ErrInvalidArrayValue(err, registrationTaintsPath, i))
+		}
+		if taint.Value != "" {
+			for _, err := range validation.IsValidLabelValue(taint.Value) {
+				errs = errs.Also(apis.ErrInvalidArrayValue(err, registrationTaintsPath, i))
+			}
+		}
+		// The kubelet requires an effect for every taint it registers with
+		switch taint.Effect {
+		case v1.TaintEffectNoSchedule, v1.TaintEffectPreferNoSchedule, v1.TaintEffectNoExecute:
+		default:
+			errs = errs.Also(apis.ErrInvalidArrayValue(fmt.Sprintf("%q not in %s, %s, %s", taint.Effect, v1.TaintEffectNoSchedule, v1.TaintEffectPreferNoSchedule, v1.TaintEffectNoExecute), registrationTaintsPath, i))
+		}
+		key := fmt.Sprintf("%s:%s", taint.Key, taint.Effect)
+		if _, ok := existing[key]; ok {
+			errs = errs.Also(apis.ErrInvalidArrayValue(fmt.Sprintf("duplicate taint key/effect pair %s", key), registrationTaintsPath, i))
+		}
+		existing[key] = struct{}{}
+	}
+	return errs
+}
+
+func validateReservedResources(reserved v1.ResourceList, fieldName string) (errs *apis.FieldError) {
+	for name, quantity := range reserved {
+		if !v1alpha5.SupportedReservedResources.Has(name.String()) {
+			errs = errs.Also(apis.ErrInvalidKeyName(name.String(), fieldName))
+		}
+		if quantity.Sign() < 0 {
+			errs = errs.Also(apis.ErrInvalidValue(quantity.String(), fmt.Sprintf("%s['%s']", fieldName, name), "Value cannot be a negative resource quantity"))
+		}
+	}
+	return errs
+}
+
+func validateEvictionThresholds(thresholds map[string]string, fieldName string) (errs *apis.FieldError) {
+	for signal, value := range thresholds {
+		if !v1alpha5.SupportedEvictionSignals.Has(signal) {
+			errs = errs.Also(apis.ErrInvalidKeyName(signal, fieldName))
+		}
+		fieldPath := fmt.Sprintf("%s['%s']", fieldName, signal)
+		if strings.HasSuffix(value, "%") {
+			p, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+			if err != nil || p < 0 || p > 100 {
+				errs = errs.Also(apis.ErrInvalidValue(value, fieldPath, "Value must be a percentage between 0% and 100%"))
+			}
+			continue
+		}
+		if _, err := resource.ParseQuantity(value); err != nil {
+			errs = errs.Also(apis.ErrInvalidValue(value, fieldPath, fmt.Sprintf("Value could not be parsed as a resource quantity, %s", err)))
+		}
+	}
+	return errs
+}
+
+func (a *AWSNodeTemplateSpec) validateEvictionSoftGracePeriod() (errs *apis.FieldError) {
+	for signal, gracePeriod := range a.EvictionSoftGracePeriod {
+		if !v1alpha5.SupportedEvictionSignals.Has(signal) {
+			errs = errs.Also(apis.ErrInvalidKeyName(signal, evictionSoftGracePeriodPath))
+		}
+		if gracePeriod.Duration < 0 {
+			errs = errs.Also(apis.ErrInvalidValue(gracePeriod.Duration.String(), fmt.Sprintf("%s['%s']", evictionSoftGracePeriodPath, signal), "Value cannot be a negative duration"))
+		}
+	}
+	// The thresholds and grace periods of a template that inherits may be completed by its base template
+	if a.BaseTemplateRef != nil {
+		return errs
+	}
+	for signal := range a.EvictionSoft {
+		if _, ok := a.EvictionSoftGracePeriod[signal]; !ok {
+			errs = errs.Also(apis.ErrInvalidKeyName(signal, evictionSoftPath, "Key does not have a matching evictionSoftGracePeriod"))
+		}
+	}
+	for signal := range a.EvictionSoftGracePeriod {
+		if _, ok := a.EvictionSoft[signal]; !ok {
+			errs = errs.Also(apis.ErrInvalidKeyName(signal, evictionSoftGracePeriodPath, "Key does not have a matching evictionSoft threshold value"))
+		}
+	}
+	return errs
+}
+
+func (a *AWSNodeTemplateSpec) validateEvictionMaxPodGracePeriod() (errs *apis.FieldError) {
+	if a.EvictionMaxPodGracePeriod != nil && *a.EvictionMaxPodGracePeriod < 0 {
+		errs = errs.Also(apis.ErrInvalidValue(*a.EvictionMaxPodGracePeriod, evictionMaxPodGracePeriodPath, "Value cannot be negative"))
 	}
 	return errs
 }
@@ -49,6 +49,87 @@ type AWS struct {
 	// Tags to be applied on ec2 resources like instances and launch templates.
 	// +optional
 	Tags map[string]string `json:"tags,omitempty"`
+	// PlacementGroup that instances are launched into. Only instance types that support the strategy of the
+	// placement group are launched.
+	// +optional
+	PlacementGroup *PlacementGroup `json:"placementGroup,omitempty"`
+	// RequireNitroTPM only launches instance types that support NitroTPM, from AMIs that enable NitroTPM. The AMIs
+	// must be selected with an amiSelector, since the default AMIs don't enable NitroTPM.
+	// +optional
+	RequireNitroTPM *bool `json:"requireNitroTPM,omitempty"`
+	// PreferNewerGenerations launches newer generations of an instance category (e.g. m6i over m5) ahead of older
+	// generations whose price is comparable. Cheaper instance types are still launched first when the price of the
+	// newer generation exceeds the tolerance.
+	// +optional
+	PreferNewerGenerations *GenerationPreference `json:"preferNewerGenerations,omitempty"`
+	// SpotPriceBiasPercent picks between spot and on-demand capacity, when a provisioner allows both, by comparing the
+	// prices of their cheapest offerings after discounting spot prices by the bias. A positive bias favors spot and a
+	// negative bias favors on-demand, while a bias of 0 compares the prices as they are. Without a bias, spot is
+	// launched whenever it's available.
+	// +kubebuilder:validation:Minimum=-100
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	SpotPriceBiasPercent *int64 `json:"spotPriceBiasPercent,omitempty"`
+	// CapacityTypes are the capacity types, "spot" or "on-demand", that instances are launched with, in addition to
+	// the capacity types that provisioners allow, e.g. so that stateful workloads only ever run on on-demand capacity.
+	// Provisioners that don't allow any of them can't launch nodes. Defaults to both.
+	// +optional
+	CapacityTypes []string `json:"capacityTypes,omitempty"`
+	// MaxPrice is the highest hourly price, in US dollars, that instances are launched at, e.g. "0.50". Spot and
+	// on-demand offerings that cost more are never launched, which caps the cost of each node.
+	// +optional
+	MaxPrice *string `json:"maxPrice,omitempty"`
+	// PreferredZones are zones, most preferred first, that instances are launched into ahead of other zones when their
+	// offerings are otherwise equal, e.g. to keep nodes close to a database. Cheaper offerings in other zones are still
+	// launched first, and the zones that provisioners require always apply. Zones must be those of the subnets.
+	// +optional
+	PreferredZones []string `json:"preferredZones,omitempty"`
+	// PrefixDelegation indicates that the VPC CNI assigns /28 IPv4 prefixes rather than individual addresses to the
+	// ENIs of nodes, so that their max pods is derived from the prefixes that their ENIs hold, up to 110 pods on
+	// instance types with fewer than 30 vCPUs and 250 pods otherwise. An explicit maxPods in the kubelet configuration
+	// of the provisioner takes precedence.
+	// +optional
+	PrefixDelegation *bool `json:"prefixDelegation,omitempty"`
+	// InstanceStorePolicy prepares the NVMe instance store volumes of provisioned nodes before the kubelet starts, so
+	// that nodes don't become ready before their ephemeral storage exists. With "RAID0", the volumes are combined into
+	// a RAID0 array that backs the kubelet's root directory, and the ephemeral storage capacity of instance types
+	// with an instance store is the size of the instance store. Only the AL2 and Ubuntu AMI families support it.
+	// +optional
+	InstanceStorePolicy *string `json:"instanceStorePolicy,omitempty"`
+	// HostContainers configures the admin and control host containers of Bottlerocket nodes. The generated user data
+	// renders them into settings.host-containers, in place of the same settings in the userData of the node template.
+	// Only the Bottlerocket AMI family supports it.
+	// +optional
+	HostContainers *HostContainers `json:"hostContainers,omitempty"`
+	// Sysctls are kernel parameters, e.g. net.core.somaxconn, that are applied when nodes boot, before the kubelet
+	// starts. The AL2 and Ubuntu AMI families write them to /etc/sysctl.d, and the Bottlerocket AMI family renders them
+	// into settings.kernel.sysctl. The Custom AMI family doesn't support them.
+	// +optional
+	Sysctls map[string]string `json:"sysctls,omitempty"`
+	// ContainerLogMaxSize is the size, e.g. 10Mi, that container log files grow to before the kubelet rotates them.
+	// The generated user data renders it into the kubelet configuration, except for nodes that run the dockerd
+	// container runtime, whose logs are rotated by docker. The Custom AMI family doesn't support it.
+	// +optional
+	ContainerLogMaxSize *string `json:"containerLogMaxSize,omitempty"`
+	// ContainerLogMaxFiles is the number of log files, including the current one, that the kubelet keeps for each
+	// container. Like containerLogMaxSize, it doesn't apply to the dockerd container runtime or the Custom AMI family.
+	// +kubebuilder:validation:Minimum=2
+	// +optional
+	ContainerLogMaxFiles *int32 `json:"containerLogMaxFiles,omitempty"`
+	// ImagesToPull are the images that nodes pull into their container runtime when they boot, so that pods that use
+	// them start without waiting for the images. The pulls run alongside the kubelet, and images of ECR repositories are
+	// pulled with the credentials of the instance profile. Only the AL2 and Ubuntu AMI families support it.
+	// +optional
+	ImagesToPull []string `json:"imagesToPull,omitempty"`
+	// PostBootstrapCommand is a command that nodes run as the final step of the generated user data, once the kubelet
+	// is started, e.g. to validate the node or to register it with an inventory. Only the AL2 and Ubuntu AMI families
+	// support it.
+	// +optional
+	PostBootstrapCommand *PostBootstrapCommand `json:"postBootstrapCommand,omitempty"`
+	// ClusterEndpoint is the endpoint of the API server that nodes bootstrap against, in place of the
+	// aws.clusterEndpoint setting, e.g. a private endpoint or a proxy that's reachable from the subnets of the nodes.
+	// +optional
+	ClusterEndpoint *string `json:"clusterEndpoint,omitempty"`
 	// LaunchTemplate parameters to use when generating an LT
 	LaunchTemplate `json:",inline,omitempty"`
 }
@@ -78,6 +159,70 @@ type LaunchTemplate struct {
 	// BlockDeviceMappings to be applied to provisioned nodes.
 	// +optionals
 	BlockDeviceMappings []*BlockDeviceMapping `json:"blockDeviceMappings,omitempty"`
+	// KeyName of an existing EC2 key pair that allows SSH access to provisioned nodes. Anyone holding the private
+	// key can log in to every node launched with it, so prefer SSM Session Manager where possible. Defaults to none.
+	// +optional
+	KeyName *string `json:"keyName,omitempty"`
+	// DisableAPITermination enables termination protection of provisioned nodes, so that they can't be terminated
+	// through the EC2 API, console or CLI by accident. Karpenter lifts the protection of a node when it terminates it
+	// itself, e.g. when the node is deleted, consolidated or expires.
+	// +optional
+	DisableAPITermination *bool `json:"disableAPITermination,omitempty"`
+	// InstanceInitiatedShutdownBehavior is what happens to provisioned nodes when they are shut down from within the
+	// instance, one of "stop" or "terminate". Stopping requires an EBS-backed root volume, and EC2 doesn't support it
+	// for spot instances that aren't persistent requests. Defaults to terminate.
+	// +optional
+	InstanceInitiatedShutdownBehavior *string `json:"instanceInitiatedShutdownBehavior,omitempty"`
+}
+
+// PlacementGroup is an existing EC2 placement group that instances are launched into.
+type PlacementGroup struct {
+	// Name of the placement group.
+	Name string `json:"name"`
+	// Strategy of the placement group, one of "cluster", "partition" or "spread".
+	Strategy string `json:"strategy"`
+}
+
+// HostContainers are the Bottlerocket host containers that can be configured.
+type HostContainers struct {
+	// Admin is the admin container, which provides shell access to the host. Bottlerocket disables it by default.
+	// +optional
+	Admin *HostContainer `json:"admin,omitempty"`
+	// Control is the control container, which connects the host to AWS Systems Manager. Bottlerocket enables it by
+	// default.
+	// +optional
+	Control *HostContainer `json:"control,omitempty"`
+}
+
+// HostContainer is a Bottlerocket host container.
+type HostContainer struct {
+	// Enabled starts the host container when the node boots.
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+	// Source is the image reference of the host container, e.g. a mirror of the default image in a private registry.
+	// +optional
+	Source *string `json:"source,omitempty"`
+}
+
+// PostBootstrapCommand is a command that runs after the bootstrap script.
+type PostBootstrapCommand struct {
+	// Command is run by bash after the bootstrap script starts the kubelet, and after the pulls of imagesToPull are
+	// started. It may be up to 4096 characters.
+	Command string `json:"command"`
+	// FailurePolicy is what happens when the command fails. With "Fail", the kubelet is stopped and the user data
+	// fails, so that the node doesn't stay ready. With "Ignore", the failure is logged. Defaults to Fail.
+	// +optional
+	FailurePolicy *string `json:"failurePolicy,omitempty"`
+}
+
+// GenerationPreference is a soft preference for newer instance generations.
+type GenerationPreference struct {
+	// PriceTolerancePercent is how much more expensive, per generation, an instance type may be than one of an older
+	// generation of the same category and still be launched ahead of it. Defaults to 5.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	PriceTolerancePercent *int64 `json:"priceTolerancePercent,omitempty"`
 }
 
 // MetadataOptions contains parameters for specifying the exposure of the
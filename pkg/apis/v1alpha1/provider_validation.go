@@ -16,13 +16,19 @@ package v1alpha1
 
 import (
 	"fmt"
+	"math"
+	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/samber/lo"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"knative.dev/pkg/apis"
 
+	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
 	"github.com/aws/karpenter-core/pkg/utils/functional"
 )
 
@@ -34,13 +40,43 @@ const (
 	metadataOptionsPath         = "metadataOptions"
 	instanceProfilePath         = "instanceProfile"
 	blockDeviceMappingsPath     = "blockDeviceMappings"
+	placementGroupPath          = "placementGroup"
+	keyNamePath                 = "keyName"
+	disableAPITerminationPath   = "disableAPITermination"
+	shutdownBehaviorPath        = "instanceInitiatedShutdownBehavior"
+	instanceStorePolicyPath     = "instanceStorePolicy"
+	hostContainersPath          = "hostContainers"
+	sysctlsPath                 = "sysctls"
+	containerLogMaxSizePath     = "containerLogMaxSize"
+	containerLogMaxFilesPath    = "containerLogMaxFiles"
+	imagesToPullPath            = "imagesToPull"
+	postBootstrapCommandPath    = "postBootstrapCommand"
+	clusterEndpointPath         = "clusterEndpoint"
+	preferNewerGenerationsPath  = "preferNewerGenerations"
+	spotPriceBiasPercentPath    = "spotPriceBiasPercent"
+	capacityTypesPath           = "capacityTypes"
+	maxPricePath                = "maxPrice"
+	preferredZonesPath          = "preferredZones"
 )
 
 var (
-	minVolumeSize      = *resource.NewScaledQuantity(1, resource.Giga)
-	maxVolumeSize      = *resource.NewScaledQuantity(64, resource.Tera)
-	subnetRegex        = regexp.MustCompile("subnet-[0-9a-z]+")
+	minVolumeSize = *resource.NewScaledQuantity(1, resource.Giga)
+	maxVolumeSize = *resource.NewScaledQuantity(64, resource.Tera)
+	subnetRegex   = regexp.MustCompile("subnet-[0-9a-z]+")
+	// subnetPrefixRegex matches the subnet ID prefixes, e.g. subnet-0abc*, that select every subnet whose ID starts
+	// with the prefix
+	subnetPrefixRegex  = regexp.MustCompile(`^subnet-[0-9a-z]*\*$`)
 	securityGroupRegex = regexp.MustCompile("sg-[0-9a-z]+")
+	accountIDRegex     = regexp.MustCompile("^[0-9]{12}$")
+	// maxPostBootstrapCommandLength keeps the post bootstrap command well within the 16KB that user data is limited to
+	maxPostBootstrapCommandLength = 4096
+	// imageReferenceRegex matches the image references, optionally with a registry, tag and digest, that Bottlerocket
+	// accepts as the source of a host container and that nodes pull as imagesToPull
+	imageReferenceRegex = regexp.MustCompile(`^(?:[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?)*(?::[0-9]+)?/)?` +
+		`[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*(?::[a-zA-Z0-9_][a-zA-Z0-9_.-]{0,127})?(?:@sha256:[a-f0-9]{64})?$`)
+	// sysctlNameRegex matches kernel parameter names, which are dot separated, e.g. net.ipv4.tcp_keepalive_time, and
+	// may contain slashes in place of dots, e.g. net/ipv4/ip_forward
+	sysctlNameRegex = regexp.MustCompile(`^[a-z][a-z0-9_]*(?:[./][a-zA-Z0-9_-]+)+$`)
 )
 
 func (a *AWS) Validate() (errs *apis.FieldError) {
@@ -49,15 +85,54 @@ func (a *AWS) Validate() (errs *apis.FieldError) {
 	)
 }
 
+// validateInheriting validates the settings of an AWSNodeTemplate that inherits from a base template, which may set
+// the required selectors in its place
+func (a *AWS) validateInheriting() (errs *apis.FieldError) {
+	return errs.Also(
+		a.validateSettings().ViaField("provider"),
+	)
+}
+
 func (a *AWS) validate() (errs *apis.FieldError) {
+	return errs.Also(
+		a.validateRequiredSelectors(),
+		a.validateSettings(),
+	)
+}
+
+func (a *AWS) validateRequiredSelectors() (errs *apis.FieldError) {
+	if a.SubnetSelector == nil {
+		errs = errs.Also(apis.ErrMissingField(fieldPathSubnetSelectorPath))
+	}
+	if a.LaunchTemplateName == nil && a.SecurityGroupSelector == nil {
+		errs = errs.Also(apis.ErrMissingField(securityGroupSelectorPath))
+	}
+	return errs
+}
+
+func (a *AWS) validateSettings() (errs *apis.FieldError) {
 	return errs.Also(
 		a.validateLaunchTemplate(),
 		a.validateSubnets(),
 		a.validateSecurityGroups(),
 		a.validateTags(),
 		a.validateMetadataOptions(),
+		a.validateInstanceInitiatedShutdownBehavior(),
 		a.validateAMIFamily(),
 		a.validateBlockDeviceMappings(),
+		a.validatePlacementGroup(),
+		a.validatePreferNewerGenerations(),
+		a.validateSpotPriceBiasPercent(),
+		a.validateCapacityTypes(),
+		a.validateMaxPrice(),
+		a.validatePreferredZones(),
+		a.validateInstanceStorePolicy(),
+		a.validateHostContainers(),
+		a.validateSysctls(),
+		a.validateContainerLogRotation(),
+		a.validateImagesToPull(),
+		a.validatePostBootstrapCommand(),
+		a.validateClusterEndpoint(),
 	)
 }
 
@@ -80,19 +155,33 @@ func (a *AWS) validateLaunchTemplate() (errs *apis.FieldError) {
 	if len(a.BlockDeviceMappings) != 0 {
 		errs = errs.Also(apis.ErrMultipleOneOf(launchTemplatePath, blockDeviceMappingsPath))
 	}
+	if a.KeyName != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf(launchTemplatePath, keyNamePath))
+	}
+	if a.DisableAPITermination != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf(launchTemplatePath, disableAPITerminationPath))
+	}
+	if a.InstanceInitiatedShutdownBehavior != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf(launchTemplatePath, shutdownBehaviorPath))
+	}
 	return errs
 }
 
 func (a *AWS) validateSubnets() (errs *apis.FieldError) {
-	if a.SubnetSelector == nil {
-		errs = errs.Also(apis.ErrMissingField(fieldPathSubnetSelectorPath))
-	}
 	for key, value := range a.SubnetSelector {
 		if key == "" || value == "" {
 			errs = errs.Also(apis.ErrInvalidValue("\"\"", fmt.Sprintf("%s['%s']", fieldPathSubnetSelectorPath, key)))
 		}
 		if key == "aws-ids" {
 			for _, subnetID := range functional.SplitCommaSeparatedString(value) {
+				if strings.Contains(subnetID, "*") {
+					if !subnetPrefixRegex.MatchString(subnetID) {
+						fieldValue := fmt.Sprintf("\"%s\"", subnetID)
+						message := fmt.Sprintf("%s['%s'] must be a subnet-id prefix with a trailing wildcard (regex: %s)", fieldPathSubnetSelectorPath, key, subnetPrefixRegex.String())
+						errs = errs.Also(apis.ErrInvalidValue(fieldValue, message))
+					}
+					continue
+				}
 				if !subnetRegex.MatchString(subnetID) {
 					fieldValue := fmt.Sprintf("\"%s\"", subnetID)
 					message := fmt.Sprintf("%s['%s'] must be a valid subnet-id (regex: %s)", fieldPathSubnetSelectorPath, key, subnetRegex.String())
@@ -100,6 +189,15 @@ func (a *AWS) validateSubnets() (errs *apis.FieldError) {
 				}
 			}
 		}
+		if key == "aws-owners" {
+			for _, owner := range functional.SplitCommaSeparatedString(value) {
+				if !accountIDRegex.MatchString(owner) {
+					fieldValue := fmt.Sprintf("\"%s\"", owner)
+					message := fmt.Sprintf("%s['%s'] must be a valid account ID (regex: %s)", fieldPathSubnetSelectorPath, key, accountIDRegex.String())
+					errs = errs.Also(apis.ErrInvalidValue(fieldValue, message))
+				}
+			}
+		}
 	}
 	return errs
 }
@@ -108,9 +206,6 @@ func (a *AWS) validateSecurityGroups() (errs *apis.FieldError) {
 	if a.LaunchTemplateName != nil {
 		return nil
 	}
-	if a.SecurityGroupSelector == nil {
-		errs = errs.Also(apis.ErrMissingField(securityGroupSelectorPath))
-	}
 	for key, value := range a.SecurityGroupSelector {
 		if key == "" || value == "" {
 			errs = errs.Also(apis.ErrInvalidValue("\"\"", fmt.Sprintf("%s['%s']", securityGroupSelectorPath, key)))
@@ -184,6 +279,13 @@ func (a *AWS) validateHTTPTokens() *apis.FieldError {
 	return a.validateStringEnum(*a.MetadataOptions.HTTPTokens, "httpTokens", ec2.LaunchTemplateHttpTokensState_Values())
 }
 
+func (a *AWS) validateInstanceInitiatedShutdownBehavior() *apis.FieldError {
+	if a.InstanceInitiatedShutdownBehavior == nil {
+		return nil
+	}
+	return a.validateStringEnum(*a.InstanceInitiatedShutdownBehavior, shutdownBehaviorPath, ec2.ShutdownBehavior_Values())
+}
+
 func (a *AWS) validateAMIFamily() *apis.FieldError {
 	if a.AMIFamily == nil {
 		return nil
@@ -191,6 +293,217 @@ func (a *AWS) validateAMIFamily() *apis.FieldError {
 	return a.validateStringEnum(*a.AMIFamily, amiFamilyPath, SupportedAMIFamilies)
 }
 
+func (a *AWS) validatePlacementGroup() (errs *apis.FieldError) {
+	if a.PlacementGroup == nil {
+		return nil
+	}
+	if a.PlacementGroup.Name == "" {
+		errs = errs.Also(apis.ErrMissingField("name").ViaField(placementGroupPath))
+	}
+	return errs.Also(a.validateStringEnum(a.PlacementGroup.Strategy, "strategy", ec2.PlacementGroupStrategy_Values()).ViaField(placementGroupPath))
+}
+
+func (a *AWS) validatePreferNewerGenerations() (errs *apis.FieldError) {
+	if a.PreferNewerGenerations == nil || a.PreferNewerGenerations.PriceTolerancePercent == nil {
+		return nil
+	}
+	if tolerance := *a.PreferNewerGenerations.PriceTolerancePercent; tolerance < 0 || tolerance > 100 {
+		return apis.ErrOutOfBoundsValue(tolerance, 0, 100, "priceTolerancePercent").ViaField(preferNewerGenerationsPath)
+	}
+	return nil
+}
+
+func (a *AWS) validateSpotPriceBiasPercent() (errs *apis.FieldError) {
+	if a.SpotPriceBiasPercent == nil {
+		return nil
+	}
+	if bias := *a.SpotPriceBiasPercent; bias < -100 || bias > 100 {
+		return apis.ErrOutOfBoundsValue(bias, -100, 100, spotPriceBiasPercentPath)
+	}
+	return nil
+}
+
+func (a *AWS) validateCapacityTypes() (errs *apis.FieldError) {
+	for i, capacityType := range a.CapacityTypes {
+		if capacityType != v1alpha5.CapacityTypeSpot && capacityType != v1alpha5.CapacityTypeOnDemand {
+			errs = errs.Also(apis.ErrInvalidArrayValue(capacityType, capacityTypesPath, i))
+		}
+	}
+	return errs
+}
+
+func (a *AWS) validateMaxPrice() (errs *apis.FieldError) {
+	if a.MaxPrice == nil {
+		return nil
+	}
+	if price, err := strconv.ParseFloat(*a.MaxPrice, 64); err != nil || math.IsNaN(price) || math.IsInf(price, 0) || price <= 0 {
+		return apis.ErrInvalidValue(fmt.Sprintf("%s, must be a positive hourly price", *a.MaxPrice), maxPricePath)
+	}
+	return nil
+}
+
+func (a *AWS) validatePreferredZones() (errs *apis.FieldError) {
+	seen := map[string]bool{}
+	for i, zone := range a.PreferredZones {
+		if zone == "" || seen[zone] {
+			errs = errs.Also(apis.ErrInvalidArrayValue(zone, preferredZonesPath, i))
+		}
+		seen[zone] = true
+	}
+	return errs
+}
+
+func (a *AWS) validateInstanceStorePolicy() (errs *apis.FieldError) {
+	if a.InstanceStorePolicy == nil {
+		return nil
+	}
+	// The instance store is prepared by the generated user data
+	if a.LaunchTemplateName != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf(launchTemplatePath, instanceStorePolicyPath))
+	}
+	if a.AMIFamily != nil && *a.AMIFamily != AMIFamilyAL2 && *a.AMIFamily != AMIFamilyUbuntu {
+		errs = errs.Also(apis.ErrInvalidValue(*a.AMIFamily, amiFamilyPath, fmt.Sprintf("%s is only supported by the %s and %s AMI families", instanceStorePolicyPath, AMIFamilyAL2, AMIFamilyUbuntu)))
+	}
+	return errs.Also(a.validateStringEnum(*a.InstanceStorePolicy, instanceStorePolicyPath, SupportedInstanceStorePolicies))
+}
+
+func (a *AWS) validateHostContainers() (errs *apis.FieldError) {
+	if a.HostContainers == nil {
+		return nil
+	}
+	// The host containers are rendered into the generated user data
+	if a.LaunchTemplateName != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf(launchTemplatePath, hostContainersPath))
+	}
+	if a.AMIFamily != nil && *a.AMIFamily != AMIFamilyBottlerocket {
+		errs = errs.Also(apis.ErrInvalidValue(*a.AMIFamily, amiFamilyPath, fmt.Sprintf("%s is only supported by the %s AMI family", hostContainersPath, AMIFamilyBottlerocket)))
+	}
+	return errs.Also(
+		validateHostContainer(a.HostContainers.Admin).ViaField("admin").ViaField(hostContainersPath),
+		validateHostContainer(a.HostContainers.Control).ViaField("control").ViaField(hostContainersPath),
+	)
+}
+
+func validateHostContainer(hostContainer *HostContainer) (errs *apis.FieldError) {
+	if hostContainer == nil {
+		return nil
+	}
+	if hostContainer.Enabled == nil && hostContainer.Source == nil {
+		return apis.ErrMissingOneOf("enabled", "source")
+	}
+	if hostContainer.Source != nil && !imageReferenceRegex.MatchString(*hostContainer.Source) {
+		errs = errs.Also(apis.ErrInvalidValue(*hostContainer.Source, "source", "must be an image reference"))
+	}
+	return errs
+}
+
+func (a *AWS) validateSysctls() (errs *apis.FieldError) {
+	if len(a.Sysctls) == 0 {
+		return nil
+	}
+	// The sysctls are applied by the generated user data
+	if a.LaunchTemplateName != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf(launchTemplatePath, sysctlsPath))
+	}
+	if a.AMIFamily != nil && *a.AMIFamily == AMIFamilyCustom {
+		errs = errs.Also(apis.ErrInvalidValue(*a.AMIFamily, amiFamilyPath, fmt.Sprintf("%s aren't supported by the %s AMI family", sysctlsPath, AMIFamilyCustom)))
+	}
+	for name, value := range a.Sysctls {
+		if !sysctlNameRegex.MatchString(name) {
+			errs = errs.Also(apis.ErrInvalidKeyName(name, sysctlsPath, "must be a kernel parameter, e.g. net.core.somaxconn"))
+		}
+		if strings.TrimSpace(value) == "" || strings.IndexFunc(value, unicode.IsControl) >= 0 {
+			errs = errs.Also(apis.ErrInvalidValue(value, fmt.Sprintf("%s['%s']", sysctlsPath, name), "must be a non-empty value on a single line"))
+		}
+	}
+	return errs
+}
+
+func (a *AWS) validateContainerLogRotation() (errs *apis.FieldError) {
+	if a.ContainerLogMaxSize == nil && a.ContainerLogMaxFiles == nil {
+		return nil
+	}
+	// The log rotation is rendered into the kubelet configuration of the generated user data
+	if a.LaunchTemplateName != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf(launchTemplatePath, containerLogMaxSizePath, containerLogMaxFilesPath))
+	}
+	if a.AMIFamily != nil && *a.AMIFamily == AMIFamilyCustom {
+		errs = errs.Also(apis.ErrInvalidValue(*a.AMIFamily, amiFamilyPath, fmt.Sprintf("%s and %s aren't supported by the %s AMI family", containerLogMaxSizePath, containerLogMaxFilesPath, AMIFamilyCustom)))
+	}
+	if a.ContainerLogMaxSize != nil {
+		if size, err := resource.ParseQuantity(*a.ContainerLogMaxSize); err != nil || size.Sign() <= 0 {
+			errs = errs.Also(apis.ErrInvalidValue(*a.ContainerLogMaxSize, containerLogMaxSizePath, "must be a positive quantity, e.g. 10Mi"))
+		}
+	}
+	// The kubelet keeps the current log file and at least one rotated file
+	if a.ContainerLogMaxFiles != nil && *a.ContainerLogMaxFiles < 2 {
+		errs = errs.Also(apis.ErrInvalidValue(*a.ContainerLogMaxFiles, containerLogMaxFilesPath, "must be at least 2"))
+	}
+	return errs
+}
+
+func (a *AWS) validateImagesToPull() (errs *apis.FieldError) {
+	if len(a.ImagesToPull) == 0 {
+		return nil
+	}
+	// The images are pulled by the generated user data
+	if a.LaunchTemplateName != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf(launchTemplatePath, imagesToPullPath))
+	}
+	if a.AMIFamily != nil && *a.AMIFamily != AMIFamilyAL2 && *a.AMIFamily != AMIFamilyUbuntu {
+		errs = errs.Also(apis.ErrInvalidValue(*a.AMIFamily, amiFamilyPath, fmt.Sprintf("%s is only supported by the %s and %s AMI families", imagesToPullPath, AMIFamilyAL2, AMIFamilyUbuntu)))
+	}
+	seen := map[string]bool{}
+	for i, image := range a.ImagesToPull {
+		if !imageReferenceRegex.MatchString(image) || seen[image] {
+			errs = errs.Also(apis.ErrInvalidArrayValue(image, imagesToPullPath, i))
+		}
+		seen[image] = true
+	}
+	return errs
+}
+
+func (a *AWS) validatePostBootstrapCommand() (errs *apis.FieldError) {
+	if a.PostBootstrapCommand == nil {
+		return nil
+	}
+	// The command is run by the generated user data, after the bootstrap script
+	if a.LaunchTemplateName != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf(launchTemplatePath, postBootstrapCommandPath))
+	}
+	if a.AMIFamily != nil && *a.AMIFamily != AMIFamilyAL2 && *a.AMIFamily != AMIFamilyUbuntu {
+		errs = errs.Also(apis.ErrInvalidValue(*a.AMIFamily, amiFamilyPath, fmt.Sprintf("%s is only supported by the %s and %s AMI families", postBootstrapCommandPath, AMIFamilyAL2, AMIFamilyUbuntu)))
+	}
+	if strings.TrimSpace(a.PostBootstrapCommand.Command) == "" {
+		errs = errs.Also(apis.ErrMissingField("command").ViaField(postBootstrapCommandPath))
+	} else if len(a.PostBootstrapCommand.Command) > maxPostBootstrapCommandLength {
+		errs = errs.Also(apis.ErrOutOfBoundsValue(len(a.PostBootstrapCommand.Command), 1, maxPostBootstrapCommandLength, "command").ViaField(postBootstrapCommandPath))
+	}
+	if a.PostBootstrapCommand.FailurePolicy != nil {
+		errs = errs.Also(a.validateStringEnum(*a.PostBootstrapCommand.FailurePolicy, "failurePolicy", SupportedPostBootstrapCommandFailurePolicies).ViaField(postBootstrapCommandPath))
+	}
+	return errs
+}
+
+func (a *AWS) validateClusterEndpoint() (errs *apis.FieldError) {
+	if a.ClusterEndpoint == nil {
+		return nil
+	}
+	// The endpoint is passed to the nodes in the generated user data
+	if a.LaunchTemplateName != nil {
+		errs = errs.Also(apis.ErrMultipleOneOf(launchTemplatePath, clusterEndpointPath))
+	}
+	if lo.FromPtr(a.AMIFamily) == AMIFamilyCustom {
+		errs = errs.Also(apis.ErrInvalidValue(*a.AMIFamily, amiFamilyPath, fmt.Sprintf("%s isn't supported by the %s AMI family", clusterEndpointPath, AMIFamilyCustom)))
+	}
+	// url.Parse() accepts a lot of input without error, so make sure it's an absolute URL that the kubelet can use
+	endpoint, err := url.Parse(*a.ClusterEndpoint)
+	if err != nil || !endpoint.IsAbs() || endpoint.Hostname() == "" || endpoint.Scheme != "https" {
+		errs = errs.Also(apis.ErrInvalidValue(*a.ClusterEndpoint, clusterEndpointPath, "must be an https URL"))
+	}
+	return errs
+}
+
 func (a *AWS) validateStringEnum(value, field string, validValues []string) *apis.FieldError {
 	for _, validValue := range validValues {
 		if value == validValue {
@@ -48,6 +48,16 @@ var (
 		AMIFamilyUbuntu,
 		AMIFamilyCustom,
 	}
+	InstanceStorePolicyRAID0       = "RAID0"
+	SupportedInstanceStorePolicies = []string{
+		InstanceStorePolicyRAID0,
+	}
+	PostBootstrapCommandFailurePolicyFail        = "Fail"
+	PostBootstrapCommandFailurePolicyIgnore      = "Ignore"
+	SupportedPostBootstrapCommandFailurePolicies = []string{
+		PostBootstrapCommandFailurePolicyFail,
+		PostBootstrapCommandFailurePolicyIgnore,
+	}
 	SupportedContainerRuntimesByAMIFamily = map[string]sets.String{
 		AMIFamilyBottlerocket: sets.NewString("containerd"),
 		AMIFamilyAL2:          sets.NewString("dockerd", "containerd"),
@@ -58,22 +68,40 @@ var (
 	ResourceAWSNeuron v1.ResourceName = "aws.amazon.com/neuron"
 	ResourceAWSPodENI v1.ResourceName = "vpc.amazonaws.com/pod-eni"
 
-	LabelInstanceHypervisor      = LabelDomain + "/instance-hypervisor"
-	LabelInstanceCategory        = LabelDomain + "/instance-category"
-	LabelInstanceFamily          = LabelDomain + "/instance-family"
-	LabelInstanceGeneration      = LabelDomain + "/instance-generation"
-	LabelInstanceLocalNVME       = LabelDomain + "/instance-local-nvme"
-	LabelInstanceSize            = LabelDomain + "/instance-size"
-	LabelInstanceCPU             = LabelDomain + "/instance-cpu"
-	LabelInstanceMemory          = LabelDomain + "/instance-memory"
-	LabelInstancePods            = LabelDomain + "/instance-pods"
-	LabelInstanceGPUName         = LabelDomain + "/instance-gpu-name"
-	LabelInstanceGPUManufacturer = LabelDomain + "/instance-gpu-manufacturer"
-	LabelInstanceGPUCount        = LabelDomain + "/instance-gpu-count"
-	LabelInstanceGPUMemory       = LabelDomain + "/instance-gpu-memory"
-	LabelInstanceAMIID           = LabelDomain + "/instance-ami-id"
+	LabelInstanceHypervisor             = LabelDomain + "/instance-hypervisor"
+	LabelInstanceVirtualizationType     = LabelDomain + "/instance-virtualization-type"
+	LabelInstanceBootMode               = LabelDomain + "/instance-boot-mode"
+	LabelInstanceCategory               = LabelDomain + "/instance-category"
+	LabelInstanceFamily                 = LabelDomain + "/instance-family"
+	LabelInstanceGeneration             = LabelDomain + "/instance-generation"
+	LabelInstanceLocalNVME              = LabelDomain + "/instance-local-nvme"
+	LabelInstanceSize                   = LabelDomain + "/instance-size"
+	LabelInstanceCPU                    = LabelDomain + "/instance-cpu"
+	LabelInstanceMemory                 = LabelDomain + "/instance-memory"
+	LabelInstancePods                   = LabelDomain + "/instance-pods"
+	LabelInstanceGPUName                = LabelDomain + "/instance-gpu-name"
+	LabelInstanceGPUManufacturer        = LabelDomain + "/instance-gpu-manufacturer"
+	LabelInstanceGPUCount               = LabelDomain + "/instance-gpu-count"
+	LabelInstanceGPUMemory              = LabelDomain + "/instance-gpu-memory"
+	LabelInstanceAMIID                  = LabelDomain + "/instance-ami-id"
+	LabelInstancePlacementGroupStrategy = LabelDomain + "/instance-placement-group-strategy"
+	LabelInstanceTPMSupport             = LabelDomain + "/instance-tpm-support"
+	LabelInstanceEBSBandwidth           = LabelDomain + "/instance-ebs-bandwidth"
+	LabelInstanceNetworkCards           = LabelDomain + "/instance-network-cards"
+	LabelInstanceMaxENIs                = LabelDomain + "/instance-max-enis"
+	LabelInstanceIPsPerENI              = LabelDomain + "/instance-ips-per-eni"
+	LabelInstanceDedicatedHostSupport   = LabelDomain + "/instance-dedicated-host-support"
 
 	InterruptionInfrastructureFinalizer = Group + "/interruption-infrastructure"
+
+	AnnotationAMIRollforward     = Group + "/ami-rollforward"
+	AnnotationAllocationStrategy = Group + "/allocation-strategy"
+	AnnotationOfferingPrice      = Group + "/offering-price"
+	AnnotationAMIID              = Group + "/ami-id"
+	AnnotationAMIFamily          = Group + "/ami-family"
+
+	TagKarpenterVersion       = Group + "/karpenter-version"
+	TagNodeTemplateGeneration = Group + "/nodetemplate-generation"
 )
 
 var (
@@ -96,6 +124,8 @@ func init() {
 	v1alpha5.RestrictedLabelDomains = v1alpha5.RestrictedLabelDomains.Insert(RestrictedLabelDomains...)
 	v1alpha5.WellKnownLabels = v1alpha5.WellKnownLabels.Insert(
 		LabelInstanceHypervisor,
+		LabelInstanceVirtualizationType,
+		LabelInstanceBootMode,
 		LabelInstanceCategory,
 		LabelInstanceFamily,
 		LabelInstanceGeneration,
@@ -108,5 +138,12 @@ func init() {
 		LabelInstanceGPUManufacturer,
 		LabelInstanceGPUCount,
 		LabelInstanceGPUMemory,
+		LabelInstancePlacementGroupStrategy,
+		LabelInstanceTPMSupport,
+		LabelInstanceEBSBandwidth,
+		LabelInstanceNetworkCards,
+		LabelInstanceMaxENIs,
+		LabelInstanceIPsPerENI,
+		LabelInstanceDedicatedHostSupport,
 	)
 }
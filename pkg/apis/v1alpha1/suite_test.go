@@ -18,14 +18,20 @@ import (
 	"context"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/Pallinder/go-randomdata"
+	"github.com/aws/aws-sdk-go/service/ec2"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	. "knative.dev/pkg/logging/testing"
 	"knative.dev/pkg/ptr"
 
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
 )
 
 var ctx context.Context
@@ -58,4 +64,864 @@ var _ = Describe("Validation", func() {
 			Expect(ant.Validate(ctx)).To(Not(Succeed()))
 		})
 	})
+	Context("ContainerRuntime", func() {
+		BeforeEach(func() {
+			ant.Spec.SubnetSelector = map[string]string{"foo": "bar"}
+			ant.Spec.SecurityGroupSelector = map[string]string{"foo": "bar"}
+		})
+		It("should succeed with a container runtime that the default AMI family supports", func() {
+			ant.Spec.ContainerRuntime = ptr.String("dockerd")
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with a container runtime that the AMI family doesn't support", func() {
+			ant.Spec.AMIFamily = ptr.String(AMIFamilyBottlerocket)
+			ant.Spec.ContainerRuntime = ptr.String("dockerd")
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with an unknown container runtime", func() {
+			ant.Spec.ContainerRuntime = ptr.String("cri-o")
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with the Custom AMI family", func() {
+			ant.Spec.AMIFamily = ptr.String(AMIFamilyCustom)
+			ant.Spec.AMISelector = map[string]string{"foo": "bar"}
+			ant.Spec.ContainerRuntime = ptr.String("containerd")
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail if a launch template is also specified", func() {
+			ant.Spec.SecurityGroupSelector = nil
+			ant.Spec.LaunchTemplateName = ptr.String("someLaunchTemplate")
+			ant.Spec.ContainerRuntime = ptr.String("containerd")
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("RegistrationTaints", func() {
+		BeforeEach(func() {
+			ant.Spec.SubnetSelector = map[string]string{"foo": "bar"}
+			ant.Spec.SecurityGroupSelector = map[string]string{"foo": "bar"}
+		})
+		It("should succeed with valid taints", func() {
+			ant.Spec.RegistrationTaints = []v1.Taint{
+				{Key: "example.com/not-ready", Effect: v1.TaintEffectNoSchedule},
+				{Key: "example.com/not-ready", Value: "true", Effect: v1.TaintEffectNoExecute},
+			}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with an invalid key", func() {
+			ant.Spec.RegistrationTaints = []v1.Taint{{Key: "not a key", Effect: v1.TaintEffectNoSchedule}}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with an invalid value", func() {
+			ant.Spec.RegistrationTaints = []v1.Taint{{Key: "foo", Value: "not a value", Effect: v1.TaintEffectNoSchedule}}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail without an effect", func() {
+			ant.Spec.RegistrationTaints = []v1.Taint{{Key: "foo", Value: "bar"}}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with duplicate key and effect pairs", func() {
+			ant.Spec.RegistrationTaints = []v1.Taint{
+				{Key: "foo", Value: "bar", Effect: v1.TaintEffectNoSchedule},
+				{Key: "foo", Value: "baz", Effect: v1.TaintEffectNoSchedule},
+			}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with the Custom AMI family", func() {
+			ant.Spec.AMIFamily = ptr.String(AMIFamilyCustom)
+			ant.Spec.AMISelector = map[string]string{"foo": "bar"}
+			ant.Spec.RegistrationTaints = []v1.Taint{{Key: "foo", Effect: v1.TaintEffectNoSchedule}}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail if a launch template is also specified", func() {
+			ant.Spec.SecurityGroupSelector = nil
+			ant.Spec.LaunchTemplateName = ptr.String("someLaunchTemplate")
+			ant.Spec.RegistrationTaints = []v1.Taint{{Key: "foo", Effect: v1.TaintEffectNoSchedule}}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("Reserved Resources", func() {
+		BeforeEach(func() {
+			ant.Spec.SubnetSelector = map[string]string{"foo": "bar"}
+			ant.Spec.SecurityGroupSelector = map[string]string{"foo": "bar"}
+		})
+		It("should succeed with supported reserved resources", func() {
+			ant.Spec.SystemReserved = v1.ResourceList{v1.ResourceCPU: resource.MustParse("200m"), v1.ResourceMemory: resource.MustParse("200Mi")}
+			ant.Spec.KubeReserved = v1.ResourceList{v1.ResourceEphemeralStorage: resource.MustParse("2Gi")}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with an unsupported reserved resource", func() {
+			ant.Spec.SystemReserved = v1.ResourceList{v1.ResourcePods: resource.MustParse("10")}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with a negative reserved resource", func() {
+			ant.Spec.KubeReserved = v1.ResourceList{v1.ResourceMemory: resource.MustParse("-1Gi")}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("EvictionThreshold", func() {
+		BeforeEach(func() {
+			ant.Spec.SubnetSelector = map[string]string{"foo": "bar"}
+			ant.Spec.SecurityGroupSelector = map[string]string{"foo": "bar"}
+		})
+		It("should succeed with quantity and percentage thresholds", func() {
+			ant.Spec.EvictionThreshold = map[string]string{"memory.available": "5%", "nodefs.available": "1Gi"}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with an unsupported eviction signal", func() {
+			ant.Spec.EvictionThreshold = map[string]string{"cpu.available": "5%"}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with a percentage greater than 100", func() {
+			ant.Spec.EvictionThreshold = map[string]string{"memory.available": "101%"}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with an invalid quantity", func() {
+			ant.Spec.EvictionThreshold = map[string]string{"memory.available": "lots"}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should succeed with soft thresholds that have grace periods", func() {
+			ant.Spec.EvictionSoft = map[string]string{"memory.available": "10%", "nodefs.available": "5Gi"}
+			ant.Spec.EvictionSoftGracePeriod = map[string]metav1.Duration{"memory.available": {Duration: time.Minute}, "nodefs.available": {Duration: time.Minute}}
+			ant.Spec.EvictionMaxPodGracePeriod = ptr.Int32(60)
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with an invalid soft threshold", func() {
+			ant.Spec.EvictionSoft = map[string]string{"memory.available": "-5%"}
+			ant.Spec.EvictionSoftGracePeriod = map[string]metav1.Duration{"memory.available": {Duration: time.Minute}}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with a soft threshold without a grace period", func() {
+			ant.Spec.EvictionSoft = map[string]string{"memory.available": "10%"}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with a grace period without a soft threshold", func() {
+			ant.Spec.EvictionSoftGracePeriod = map[string]metav1.Duration{"memory.available": {Duration: time.Minute}}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should succeed with a soft threshold without a grace period when inheriting", func() {
+			ant.Spec.BaseTemplateRef = &BaseTemplateRef{Name: "base"}
+			ant.Spec.EvictionSoft = map[string]string{"memory.available": "10%"}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with a negative grace period", func() {
+			ant.Spec.EvictionSoft = map[string]string{"memory.available": "10%"}
+			ant.Spec.EvictionSoftGracePeriod = map[string]metav1.Duration{"memory.available": {Duration: -time.Minute}}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with a negative max pod grace period", func() {
+			ant.Spec.EvictionMaxPodGracePeriod = ptr.Int32(-1)
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("AMISelector", func() {
+		BeforeEach(func() {
+			ant.Spec.SubnetSelector = map[string]string{"foo": "bar"}
+			ant.Spec.SecurityGroupSelector = map[string]string{"foo": "bar"}
+		})
+		It("should succeed with owner aliases and account ids", func() {
+			ant.Spec.AMISelector = map[string]string{"foo": "bar", "aws-owners": "self,amazon,aws-marketplace,123456789012"}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with an invalid owner", func() {
+			ant.Spec.AMISelector = map[string]string{"foo": "bar", "aws-owners": "self,everyone"}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with an account id that isn't 12 digits", func() {
+			ant.Spec.AMISelector = map[string]string{"foo": "bar", "aws-owners": "12345"}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should succeed with name patterns", func() {
+			ant.Spec.AMISelector = map[string]string{"aws-name": "myorg-eks-1.27-*,amazon-eks-node-1.27-v2023????,my ami (v1) [test]"}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with invalid name patterns", func() {
+			for _, pattern := range []string{"myorg-eks-1.27-*,my<ami>", "my\"ami", "ami#1", strings.Repeat("a", 129)} {
+				ant.Spec.AMISelector = map[string]string{"aws-name": pattern}
+				Expect(ant.Validate(ctx)).To(Not(Succeed()), pattern)
+			}
+		})
+	})
+	Context("AMISelectorTerms", func() {
+		BeforeEach(func() {
+			ant.Spec.SubnetSelector = map[string]string{"foo": "bar"}
+			ant.Spec.SecurityGroupSelector = map[string]string{"foo": "bar"}
+		})
+		It("should succeed with multiple terms", func() {
+			ant.Spec.AMISelectorTerms = []map[string]string{{"foo": "bar"}, {"aws-ids": "ami-123,ami-456"}}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should satisfy the amiSelector of the Custom AMI family", func() {
+			ant.Spec.AMIFamily = &AMIFamilyCustom
+			ant.Spec.AMISelectorTerms = []map[string]string{{"foo": "bar"}}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with an empty term", func() {
+			ant.Spec.AMISelectorTerms = []map[string]string{{"foo": "bar"}, {}}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with an invalid term", func() {
+			ant.Spec.AMISelectorTerms = []map[string]string{{"foo": "bar"}, {"aws-ids": "not-an-ami"}}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with an amiSelector", func() {
+			ant.Spec.AMISelector = map[string]string{"foo": "bar"}
+			ant.Spec.AMISelectorTerms = []map[string]string{{"foo": "bar"}}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with a launch template", func() {
+			ant.Spec.SecurityGroupSelector = nil
+			ant.Spec.LaunchTemplateName = ptr.String("someLaunchTemplate")
+			ant.Spec.AMISelectorTerms = []map[string]string{{"foo": "bar"}}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("FallbackAMIIDs", func() {
+		BeforeEach(func() {
+			ant.Spec.SubnetSelector = map[string]string{"foo": "bar"}
+			ant.Spec.SecurityGroupSelector = map[string]string{"foo": "bar"}
+		})
+		It("should succeed with valid ami ids", func() {
+			ant.Spec.FallbackAMIIDs = []string{"ami-123", "ami-456"}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with an invalid ami id", func() {
+			ant.Spec.FallbackAMIIDs = []string{"ami-123", "my-ami"}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail if an amiSelector is also specified", func() {
+			ant.Spec.FallbackAMIIDs = []string{"ami-123"}
+			ant.Spec.AMISelector = map[string]string{"foo": "bar"}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail if a launch template is also specified", func() {
+			ant.Spec.FallbackAMIIDs = []string{"ami-123"}
+			ant.Spec.LaunchTemplateName = ptr.String("someLaunchTemplate")
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("PlacementGroup", func() {
+		BeforeEach(func() {
+			ant.Spec.SubnetSelector = map[string]string{"foo": "bar"}
+			ant.Spec.SecurityGroupSelector = map[string]string{"foo": "bar"}
+		})
+		It("should succeed with a supported strategy", func() {
+			ant.Spec.PlacementGroup = &PlacementGroup{Name: "my-placement-group", Strategy: "cluster"}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with an unsupported strategy", func() {
+			ant.Spec.PlacementGroup = &PlacementGroup{Name: "my-placement-group", Strategy: "pack"}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail without a name", func() {
+			ant.Spec.PlacementGroup = &PlacementGroup{Strategy: "spread"}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("PreferNewerGenerations", func() {
+		BeforeEach(func() {
+			ant.Spec.SubnetSelector = map[string]string{"foo": "bar"}
+			ant.Spec.SecurityGroupSelector = map[string]string{"foo": "bar"}
+		})
+		It("should succeed without a price tolerance", func() {
+			ant.Spec.PreferNewerGenerations = &GenerationPreference{}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed with a price tolerance within bounds", func() {
+			ant.Spec.PreferNewerGenerations = &GenerationPreference{PriceTolerancePercent: ptr.Int64(10)}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with a negative price tolerance", func() {
+			ant.Spec.PreferNewerGenerations = &GenerationPreference{PriceTolerancePercent: ptr.Int64(-1)}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with a price tolerance over 100 percent", func() {
+			ant.Spec.PreferNewerGenerations = &GenerationPreference{PriceTolerancePercent: ptr.Int64(101)}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("SubnetSelector", func() {
+		BeforeEach(func() {
+			ant.Spec.SecurityGroupSelector = map[string]string{"foo": "bar"}
+		})
+		It("should succeed with owner account IDs", func() {
+			ant.Spec.SubnetSelector = map[string]string{"aws-owners": "111122223333,444455556666"}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with an owner that isn't an account ID", func() {
+			ant.Spec.SubnetSelector = map[string]string{"aws-owners": "self"}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should succeed with subnet ID prefixes", func() {
+			ant.Spec.SubnetSelector = map[string]string{"aws-ids": "subnet-0abc*,subnet-123,subnet-*"}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with a wildcard that isn't trailing", func() {
+			ant.Spec.SubnetSelector = map[string]string{"aws-ids": "subnet-*abc"}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with a wildcard that isn't a subnet ID prefix", func() {
+			ant.Spec.SubnetSelector = map[string]string{"aws-ids": "*"}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("SpotPriceBiasPercent", func() {
+		BeforeEach(func() {
+			ant.Spec.SubnetSelector = map[string]string{"foo": "bar"}
+			ant.Spec.SecurityGroupSelector = map[string]string{"foo": "bar"}
+		})
+		It("should succeed with a bias within bounds", func() {
+			for _, bias := range []int64{-100, -20, 0, 20, 100} {
+				ant.Spec.SpotPriceBiasPercent = ptr.Int64(bias)
+				Expect(ant.Validate(ctx)).To(Succeed())
+			}
+		})
+		It("should fail with a bias under -100 percent", func() {
+			ant.Spec.SpotPriceBiasPercent = ptr.Int64(-101)
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with a bias over 100 percent", func() {
+			ant.Spec.SpotPriceBiasPercent = ptr.Int64(101)
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("CapacityTypes", func() {
+		BeforeEach(func() {
+			ant.Spec.SubnetSelector = map[string]string{"foo": "bar"}
+			ant.Spec.SecurityGroupSelector = map[string]string{"foo": "bar"}
+		})
+		It("should succeed with supported capacity types", func() {
+			ant.Spec.CapacityTypes = []string{v1alpha5.CapacityTypeSpot, v1alpha5.CapacityTypeOnDemand}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with an unsupported capacity type", func() {
+			ant.Spec.CapacityTypes = []string{v1alpha5.CapacityTypeOnDemand, "reserved"}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("MaxPrice", func() {
+		BeforeEach(func() {
+			ant.Spec.SubnetSelector = map[string]string{"foo": "bar"}
+			ant.Spec.SecurityGroupSelector = map[string]string{"foo": "bar"}
+		})
+		It("should succeed with a positive price", func() {
+			for _, price := range []string{"0.5", "1", "12.75"} {
+				ant.Spec.MaxPrice = ptr.String(price)
+				Expect(ant.Validate(ctx)).To(Succeed())
+			}
+		})
+		It("should fail with a price that isn't positive", func() {
+			for _, price := range []string{"0", "-1.5", "NaN", "Inf"} {
+				ant.Spec.MaxPrice = ptr.String(price)
+				Expect(ant.Validate(ctx)).To(Not(Succeed()))
+			}
+		})
+		It("should fail with a price that isn't a number", func() {
+			ant.Spec.MaxPrice = ptr.String("$1")
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("PreferredZones", func() {
+		BeforeEach(func() {
+			ant.Spec.SubnetSelector = map[string]string{"foo": "bar"}
+			ant.Spec.SecurityGroupSelector = map[string]string{"foo": "bar"}
+		})
+		It("should succeed with zones", func() {
+			ant.Spec.PreferredZones = []string{"us-west-2a", "us-west-2b"}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with an empty zone", func() {
+			ant.Spec.PreferredZones = []string{"us-west-2a", ""}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with a duplicate zone", func() {
+			ant.Spec.PreferredZones = []string{"us-west-2a", "us-west-2a"}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("InstanceStorePolicy", func() {
+		BeforeEach(func() {
+			ant.Spec.SubnetSelector = map[string]string{"foo": "bar"}
+			ant.Spec.SecurityGroupSelector = map[string]string{"foo": "bar"}
+		})
+		It("should succeed with RAID0", func() {
+			ant.Spec.InstanceStorePolicy = ptr.String(InstanceStorePolicyRAID0)
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed with RAID0 on Ubuntu", func() {
+			ant.Spec.AMIFamily = &AMIFamilyUbuntu
+			ant.Spec.InstanceStorePolicy = ptr.String(InstanceStorePolicyRAID0)
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with an unknown policy", func() {
+			ant.Spec.InstanceStorePolicy = ptr.String("RAID1")
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with Bottlerocket", func() {
+			ant.Spec.AMIFamily = &AMIFamilyBottlerocket
+			ant.Spec.InstanceStorePolicy = ptr.String(InstanceStorePolicyRAID0)
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with a launch template", func() {
+			ant.Spec.SecurityGroupSelector = nil
+			ant.Spec.LaunchTemplateName = ptr.String("someLaunchTemplate")
+			ant.Spec.InstanceStorePolicy = ptr.String(InstanceStorePolicyRAID0)
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("HostContainers", func() {
+		BeforeEach(func() {
+			ant.Spec.SubnetSelector = map[string]string{"foo": "bar"}
+			ant.Spec.SecurityGroupSelector = map[string]string{"foo": "bar"}
+			ant.Spec.AMIFamily = &AMIFamilyBottlerocket
+		})
+		It("should succeed with enabled and source", func() {
+			ant.Spec.HostContainers = &HostContainers{
+				Admin:   &HostContainer{Enabled: ptr.Bool(true)},
+				Control: &HostContainer{Enabled: ptr.Bool(true), Source: ptr.String("111122223333.dkr.ecr.us-west-2.amazonaws.com/bottlerocket-control:v0.7.0")},
+			}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed with a digest", func() {
+			ant.Spec.HostContainers = &HostContainers{
+				Admin: &HostContainer{Source: ptr.String("public.ecr.aws/bottlerocket/bottlerocket-admin@sha256:" + strings.Repeat("a", 64))},
+			}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with a source that isn't an image reference", func() {
+			for _, source := range []string{"", "https://example.com/admin", "public.ecr.aws/Bottlerocket/admin", "admin:", "admin container"} {
+				ant.Spec.HostContainers = &HostContainers{Admin: &HostContainer{Source: ptr.String(source)}}
+				Expect(ant.Validate(ctx)).To(Not(Succeed()), source)
+			}
+		})
+		It("should fail without enabled or source", func() {
+			ant.Spec.HostContainers = &HostContainers{Control: &HostContainer{}}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with AL2", func() {
+			ant.Spec.AMIFamily = &AMIFamilyAL2
+			ant.Spec.HostContainers = &HostContainers{Admin: &HostContainer{Enabled: ptr.Bool(true)}}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with a launch template", func() {
+			ant.Spec.AMIFamily = nil
+			ant.Spec.SecurityGroupSelector = nil
+			ant.Spec.LaunchTemplateName = ptr.String("someLaunchTemplate")
+			ant.Spec.HostContainers = &HostContainers{Admin: &HostContainer{Enabled: ptr.Bool(true)}}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("Sysctls", func() {
+		BeforeEach(func() {
+			ant.Spec.SubnetSelector = map[string]string{"foo": "bar"}
+			ant.Spec.SecurityGroupSelector = map[string]string{"foo": "bar"}
+		})
+		It("should succeed with kernel parameters", func() {
+			ant.Spec.Sysctls = map[string]string{
+				"net.core.somaxconn":           "4096",
+				"net.ipv4.ip_local_port_range": "1024 65000",
+				"net/ipv4/ip_forward":          "1",
+				"net.ipv4.conf.eth0.rp_filter": "2",
+			}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed with Bottlerocket", func() {
+			ant.Spec.AMIFamily = &AMIFamilyBottlerocket
+			ant.Spec.Sysctls = map[string]string{"net.core.somaxconn": "4096"}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with invalid names", func() {
+			for _, name := range []string{"", "somaxconn", "net.core.", ".net.core.somaxconn", "net.core.somaxconn = 1", "Net.core.somaxconn"} {
+				ant.Spec.Sysctls = map[string]string{name: "1"}
+				Expect(ant.Validate(ctx)).To(Not(Succeed()), name)
+			}
+		})
+		It("should fail with invalid values", func() {
+			for _, value := range []string{"", " ", "1\nkernel.panic = 1", "1\t2\r"} {
+				ant.Spec.Sysctls = map[string]string{"net.core.somaxconn": value}
+				Expect(ant.Validate(ctx)).To(Not(Succeed()), value)
+			}
+		})
+		It("should fail with the Custom AMI family", func() {
+			ant.Spec.AMIFamily = &AMIFamilyCustom
+			ant.Spec.AMISelector = map[string]string{"foo": "bar"}
+			ant.Spec.Sysctls = map[string]string{"net.core.somaxconn": "4096"}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with a launch template", func() {
+			ant.Spec.SecurityGroupSelector = nil
+			ant.Spec.LaunchTemplateName = ptr.String("someLaunchTemplate")
+			ant.Spec.Sysctls = map[string]string{"net.core.somaxconn": "4096"}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("ContainerLogRotation", func() {
+		BeforeEach(func() {
+			ant.Spec.SubnetSelector = map[string]string{"foo": "bar"}
+			ant.Spec.SecurityGroupSelector = map[string]string{"foo": "bar"}
+		})
+		It("should succeed with a size and a number of files", func() {
+			ant.Spec.ContainerLogMaxSize = ptr.String("50Mi")
+			ant.Spec.ContainerLogMaxFiles = ptr.Int32(5)
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed with Bottlerocket", func() {
+			ant.Spec.AMIFamily = &AMIFamilyBottlerocket
+			ant.Spec.ContainerLogMaxSize = ptr.String("100M")
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with invalid sizes", func() {
+			for _, size := range []string{"", "0", "-10Mi", "10 MiB", "ten"} {
+				ant.Spec.ContainerLogMaxSize = ptr.String(size)
+				Expect(ant.Validate(ctx)).To(Not(Succeed()), size)
+			}
+		})
+		It("should fail with fewer than two files", func() {
+			for _, files := range []int32{-1, 0, 1} {
+				ant.Spec.ContainerLogMaxFiles = ptr.Int32(files)
+				Expect(ant.Validate(ctx)).To(Not(Succeed()))
+			}
+		})
+		It("should fail with the Custom AMI family", func() {
+			ant.Spec.AMIFamily = &AMIFamilyCustom
+			ant.Spec.AMISelector = map[string]string{"foo": "bar"}
+			ant.Spec.ContainerLogMaxFiles = ptr.Int32(5)
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with a launch template", func() {
+			ant.Spec.SecurityGroupSelector = nil
+			ant.Spec.LaunchTemplateName = ptr.String("someLaunchTemplate")
+			ant.Spec.ContainerLogMaxSize = ptr.String("50Mi")
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("ImagesToPull", func() {
+		BeforeEach(func() {
+			ant.Spec.SubnetSelector = map[string]string{"foo": "bar"}
+			ant.Spec.SecurityGroupSelector = map[string]string{"foo": "bar"}
+		})
+		It("should succeed with image references", func() {
+			ant.Spec.ImagesToPull = []string{
+				"nginx",
+				"public.ecr.aws/nginx/nginx:1.23",
+				"111122223333.dkr.ecr.us-west-2.amazonaws.com/app@sha256:" + strings.Repeat("a", 64),
+				"registry.example.com:5000/team/app:v1.2.3",
+			}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed with Ubuntu", func() {
+			ant.Spec.AMIFamily = &AMIFamilyUbuntu
+			ant.Spec.ImagesToPull = []string{"nginx"}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with invalid image references", func() {
+			for _, image := range []string{"", "Nginx", "nginx:", "nginx:latest; reboot", "nginx'", "registry.example.com/app:v1 app:v2"} {
+				ant.Spec.ImagesToPull = []string{image}
+				Expect(ant.Validate(ctx)).To(Not(Succeed()), image)
+			}
+		})
+		It("should fail with duplicate images", func() {
+			ant.Spec.ImagesToPull = []string{"nginx", "nginx"}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with Bottlerocket", func() {
+			ant.Spec.AMIFamily = &AMIFamilyBottlerocket
+			ant.Spec.ImagesToPull = []string{"nginx"}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with a launch template", func() {
+			ant.Spec.SecurityGroupSelector = nil
+			ant.Spec.LaunchTemplateName = ptr.String("someLaunchTemplate")
+			ant.Spec.ImagesToPull = []string{"nginx"}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("PostBootstrapCommand", func() {
+		BeforeEach(func() {
+			ant.Spec.SubnetSelector = map[string]string{"foo": "bar"}
+			ant.Spec.SecurityGroupSelector = map[string]string{"foo": "bar"}
+		})
+		It("should succeed with a command", func() {
+			ant.Spec.PostBootstrapCommand = &PostBootstrapCommand{Command: "curl -sf -X POST https://cmdb.example.com/nodes"}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed with the failure policies", func() {
+			for _, policy := range SupportedPostBootstrapCommandFailurePolicies {
+				ant.Spec.PostBootstrapCommand = &PostBootstrapCommand{Command: "/opt/validate.sh", FailurePolicy: ptr.String(policy)}
+				Expect(ant.Validate(ctx)).To(Succeed(), policy)
+			}
+		})
+		It("should succeed with Ubuntu", func() {
+			ant.Spec.AMIFamily = &AMIFamilyUbuntu
+			ant.Spec.PostBootstrapCommand = &PostBootstrapCommand{Command: "/opt/validate.sh"}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should succeed with a command of the maximum length", func() {
+			ant.Spec.PostBootstrapCommand = &PostBootstrapCommand{Command: strings.Repeat("a", 4096)}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with an empty command", func() {
+			for _, command := range []string{"", " \n\t"} {
+				ant.Spec.PostBootstrapCommand = &PostBootstrapCommand{Command: command}
+				Expect(ant.Validate(ctx)).To(Not(Succeed()))
+			}
+		})
+		It("should fail with a command that's too long", func() {
+			ant.Spec.PostBootstrapCommand = &PostBootstrapCommand{Command: strings.Repeat("a", 4097)}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with an unknown failure policy", func() {
+			ant.Spec.PostBootstrapCommand = &PostBootstrapCommand{Command: "/opt/validate.sh", FailurePolicy: ptr.String("Retry")}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with Bottlerocket", func() {
+			ant.Spec.AMIFamily = &AMIFamilyBottlerocket
+			ant.Spec.PostBootstrapCommand = &PostBootstrapCommand{Command: "/opt/validate.sh"}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with a launch template", func() {
+			ant.Spec.SecurityGroupSelector = nil
+			ant.Spec.LaunchTemplateName = ptr.String("someLaunchTemplate")
+			ant.Spec.PostBootstrapCommand = &PostBootstrapCommand{Command: "/opt/validate.sh"}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("ClusterEndpoint", func() {
+		BeforeEach(func() {
+			ant.Spec.SubnetSelector = map[string]string{"foo": "bar"}
+			ant.Spec.SecurityGroupSelector = map[string]string{"foo": "bar"}
+		})
+		It("should succeed with an https URL", func() {
+			ant.Spec.ClusterEndpoint = ptr.String("https://private.example.com:8443")
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with an http URL", func() {
+			ant.Spec.ClusterEndpoint = ptr.String("http://private.example.com")
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with a host without a scheme", func() {
+			ant.Spec.ClusterEndpoint = ptr.String("private.example.com")
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with the Custom AMI family", func() {
+			ant.Spec.AMIFamily = &AMIFamilyCustom
+			ant.Spec.AMISelector = map[string]string{"foo": "bar"}
+			ant.Spec.ClusterEndpoint = ptr.String("https://private.example.com")
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with a launch template", func() {
+			ant.Spec.SecurityGroupSelector = nil
+			ant.Spec.LaunchTemplateName = ptr.String("someLaunchTemplate")
+			ant.Spec.ClusterEndpoint = ptr.String("https://private.example.com")
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("KeyName", func() {
+		It("should succeed with a key name", func() {
+			ant.Spec.SubnetSelector = map[string]string{"foo": "bar"}
+			ant.Spec.SecurityGroupSelector = map[string]string{"foo": "bar"}
+			ant.Spec.KeyName = ptr.String("my-key-pair")
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with a launch template", func() {
+			ant.Spec.SubnetSelector = map[string]string{"foo": "bar"}
+			ant.Spec.LaunchTemplateName = ptr.String("someLaunchTemplate")
+			ant.Spec.KeyName = ptr.String("my-key-pair")
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("InstanceInitiatedShutdownBehavior", func() {
+		BeforeEach(func() {
+			ant.Spec.SubnetSelector = map[string]string{"foo": "bar"}
+			ant.Spec.SecurityGroupSelector = map[string]string{"foo": "bar"}
+		})
+		It("should succeed with stop or terminate", func() {
+			for _, behavior := range []string{"stop", "terminate"} {
+				ant.Spec.InstanceInitiatedShutdownBehavior = ptr.String(behavior)
+				Expect(ant.Validate(ctx)).To(Succeed())
+			}
+		})
+		It("should fail with an unknown behavior", func() {
+			ant.Spec.InstanceInitiatedShutdownBehavior = ptr.String("hibernate")
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail with a launch template", func() {
+			ant.Spec.SecurityGroupSelector = nil
+			ant.Spec.LaunchTemplateName = ptr.String("someLaunchTemplate")
+			ant.Spec.InstanceInitiatedShutdownBehavior = ptr.String("stop")
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("DisableAPITermination", func() {
+		It("should succeed with termination protection", func() {
+			ant.Spec.SubnetSelector = map[string]string{"foo": "bar"}
+			ant.Spec.SecurityGroupSelector = map[string]string{"foo": "bar"}
+			ant.Spec.DisableAPITermination = ptr.Bool(true)
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail with a launch template", func() {
+			ant.Spec.SubnetSelector = map[string]string{"foo": "bar"}
+			ant.Spec.LaunchTemplateName = ptr.String("someLaunchTemplate")
+			ant.Spec.DisableAPITermination = ptr.Bool(true)
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+	Context("RequireNitroTPM", func() {
+		BeforeEach(func() {
+			ant.Spec.SubnetSelector = map[string]string{"foo": "bar"}
+			ant.Spec.SecurityGroupSelector = map[string]string{"foo": "bar"}
+			ant.Spec.RequireNitroTPM = ptr.Bool(true)
+		})
+		It("should succeed with an amiSelector", func() {
+			ant.Spec.AMISelector = map[string]string{"foo": "bar"}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail without an amiSelector", func() {
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should succeed without an amiSelector when inheriting from a base template", func() {
+			ant.Spec.BaseTemplateRef = &BaseTemplateRef{Name: "base"}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+	})
+	Context("BaseTemplateRef", func() {
+		It("should succeed without selectors when inheriting from a base template", func() {
+			ant.Spec.BaseTemplateRef = &BaseTemplateRef{Name: "base"}
+			ant.Spec.Tags = map[string]string{"team": "ml"}
+			Expect(ant.Validate(ctx)).To(Succeed())
+		})
+		It("should fail without selectors when not inheriting from a base template", func() {
+			ant.Spec.Tags = map[string]string{"team": "ml"}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should still validate the settings of an inheriting template", func() {
+			ant.Spec.BaseTemplateRef = &BaseTemplateRef{Name: "base"}
+			ant.Spec.SubnetSelector = map[string]string{"aws-ids": "not-a-subnet"}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail without a name", func() {
+			ant.Spec.BaseTemplateRef = &BaseTemplateRef{}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+		It("should fail when referencing itself", func() {
+			ant.Spec.BaseTemplateRef = &BaseTemplateRef{Name: ant.Name}
+			Expect(ant.Validate(ctx)).To(Not(Succeed()))
+		})
+	})
+})
+
+var _ = Describe("Inheritance", func() {
+	var base, child *AWSNodeTemplateSpec
+
+	BeforeEach(func() {
+		base = &AWSNodeTemplateSpec{
+			AWS: AWS{
+				AMIFamily:             ptr.String(AMIFamilyBottlerocket),
+				InstanceProfile:       ptr.String("base-profile"),
+				SubnetSelector:        map[string]string{"tier": "private"},
+				SecurityGroupSelector: map[string]string{"cluster": "shared"},
+				Tags:                  map[string]string{"team": "platform", "cost-center": "1234"},
+				LaunchTemplate: LaunchTemplate{
+					MetadataOptions: &MetadataOptions{
+						HTTPEndpoint:            ptr.String(ec2.LaunchTemplateInstanceMetadataEndpointStateEnabled),
+						HTTPPutResponseHopLimit: ptr.Int64(1),
+						HTTPTokens:              ptr.String(ec2.LaunchTemplateHttpTokensStateRequired),
+					},
+					BlockDeviceMappings: []*BlockDeviceMapping{{
+						DeviceName: ptr.String("/dev/xvda"),
+						EBS:        &BlockDevice{VolumeSize: resource.NewScaledQuantity(100, resource.Giga)},
+					}},
+				},
+			},
+			KubeReserved: v1.ResourceList{v1.ResourceCPU: resource.MustParse("100m"), v1.ResourceMemory: resource.MustParse("500Mi")},
+		}
+		child = &AWSNodeTemplateSpec{BaseTemplateRef: &BaseTemplateRef{Name: "base"}}
+	})
+
+	It("should inherit all settings that aren't set", func() {
+		child.Inherit(base)
+		Expect(child.AMIFamily).To(Equal(base.AMIFamily))
+		Expect(child.InstanceProfile).To(Equal(base.InstanceProfile))
+		Expect(child.SubnetSelector).To(Equal(base.SubnetSelector))
+		Expect(child.SecurityGroupSelector).To(Equal(base.SecurityGroupSelector))
+		Expect(child.Tags).To(Equal(base.Tags))
+		Expect(child.MetadataOptions).To(Equal(base.MetadataOptions))
+		Expect(child.BlockDeviceMappings).To(Equal(base.BlockDeviceMappings))
+		Expect(child.KubeReserved).To(Equal(base.KubeReserved))
+		Expect(child.BaseTemplateRef).To(Equal(&BaseTemplateRef{Name: "base"}))
+	})
+	It("should override settings that are set", func() {
+		child.AMIFamily = ptr.String(AMIFamilyAL2)
+		child.SubnetSelector = map[string]string{"tier": "public"}
+		child.BlockDeviceMappings = []*BlockDeviceMapping{{
+			DeviceName: ptr.String("/dev/xvdb"),
+			EBS:        &BlockDevice{VolumeSize: resource.NewScaledQuantity(200, resource.Giga)},
+		}}
+		child.Inherit(base)
+		Expect(*child.AMIFamily).To(Equal(AMIFamilyAL2))
+		Expect(child.InstanceProfile).To(Equal(base.InstanceProfile))
+		Expect(child.SubnetSelector).To(Equal(map[string]string{"tier": "public"}))
+		Expect(child.BlockDeviceMappings).To(HaveLen(1))
+		Expect(*child.BlockDeviceMappings[0].DeviceName).To(Equal("/dev/xvdb"))
+	})
+	It("should merge tags with the tags of the child taking precedence", func() {
+		child.Tags = map[string]string{"team": "ml", "project": "training"}
+		child.Inherit(base)
+		Expect(child.Tags).To(Equal(map[string]string{"team": "ml", "project": "training", "cost-center": "1234"}))
+	})
+	It("should merge sysctls with the values of the child taking precedence", func() {
+		base.Sysctls = map[string]string{"net.core.somaxconn": "1024", "vm.max_map_count": "262144"}
+		child.Sysctls = map[string]string{"net.core.somaxconn": "4096"}
+		child.Inherit(base)
+		Expect(child.Sysctls).To(Equal(map[string]string{"net.core.somaxconn": "4096", "vm.max_map_count": "262144"}))
+	})
+	It("should merge reserved resources with the values of the child taking precedence", func() {
+		child.KubeReserved = v1.ResourceList{v1.ResourceMemory: resource.MustParse("1Gi")}
+		child.Inherit(base)
+		Expect(child.KubeReserved).To(Equal(v1.ResourceList{v1.ResourceCPU: resource.MustParse("100m"), v1.ResourceMemory: resource.MustParse("1Gi")}))
+	})
+	It("should merge soft eviction thresholds and grace periods with the values of the child taking precedence", func() {
+		base.EvictionSoft = map[string]string{"memory.available": "10%", "nodefs.available": "15%"}
+		base.EvictionSoftGracePeriod = map[string]metav1.Duration{"memory.available": {Duration: time.Minute}, "nodefs.available": {Duration: time.Minute}}
+		child.EvictionSoft = map[string]string{"memory.available": "5%"}
+		child.EvictionSoftGracePeriod = map[string]metav1.Duration{"memory.available": {Duration: 2 * time.Minute}}
+		child.Inherit(base)
+		Expect(child.EvictionSoft).To(Equal(map[string]string{"memory.available": "5%", "nodefs.available": "15%"}))
+		Expect(child.EvictionSoftGracePeriod).To(Equal(map[string]metav1.Duration{"memory.available": {Duration: 2 * time.Minute}, "nodefs.available": {Duration: time.Minute}}))
+	})
+	It("should merge metadata options field by field", func() {
+		child.MetadataOptions = &MetadataOptions{HTTPPutResponseHopLimit: ptr.Int64(2)}
+		child.Inherit(base)
+		Expect(child.MetadataOptions).To(Equal(&MetadataOptions{
+			HTTPEndpoint:            ptr.String(ec2.LaunchTemplateInstanceMetadataEndpointStateEnabled),
+			HTTPPutResponseHopLimit: ptr.Int64(2),
+			HTTPTokens:              ptr.String(ec2.LaunchTemplateHttpTokensStateRequired),
+		}))
+	})
+	It("should not merge selectors key by key", func() {
+		child.SecurityGroupSelector = map[string]string{"aws-ids": "sg-123"}
+		child.Inherit(base)
+		Expect(child.SecurityGroupSelector).To(Equal(map[string]string{"aws-ids": "sg-123"}))
+	})
+	It("should not inherit the ami selector of the base template when the child has ami selector terms", func() {
+		base.AMISelector = map[string]string{"foo": "bar"}
+		child.AMISelectorTerms = []map[string]string{{"aws-ids": "ami-123"}}
+		child.Inherit(base)
+		Expect(child.AMISelector).To(BeNil())
+		Expect(child.AMISelectors()).To(Equal([]map[string]string{{"aws-ids": "ami-123"}}))
+	})
+	It("should not modify the base template", func() {
+		child.Tags = map[string]string{"team": "ml"}
+		child.Inherit(base)
+		child.MetadataOptions.HTTPTokens = ptr.String(ec2.LaunchTemplateHttpTokensStateOptional)
+		Expect(base.Tags).To(Equal(map[string]string{"team": "platform", "cost-center": "1234"}))
+		Expect(*base.MetadataOptions.HTTPTokens).To(Equal(ec2.LaunchTemplateHttpTokensStateRequired))
+	})
+	It("should let the settings of closer templates take precedence across a chain", func() {
+		parent := &AWSNodeTemplateSpec{AWS: AWS{Tags: map[string]string{"team": "data"}, InstanceProfile: ptr.String("parent-profile")}}
+		child.Inherit(parent)
+		child.Inherit(base)
+		Expect(*child.InstanceProfile).To(Equal("parent-profile"))
+		Expect(child.Tags).To(Equal(map[string]string{"team": "data", "cost-center": "1234"}))
+		Expect(child.AMIFamily).To(Equal(base.AMIFamily))
+	})
 })
@@ -20,7 +20,10 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"knative.dev/pkg/apis"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -63,6 +66,88 @@ func (in *AWS) DeepCopyInto(out *AWS) {
 			(*out)[key] = val
 		}
 	}
+	if in.PlacementGroup != nil {
+		in, out := &in.PlacementGroup, &out.PlacementGroup
+		*out = new(PlacementGroup)
+		**out = **in
+	}
+	if in.RequireNitroTPM != nil {
+		in, out := &in.RequireNitroTPM, &out.RequireNitroTPM
+		*out = new(bool)
+		**out = **in
+	}
+	if in.PreferNewerGenerations != nil {
+		in, out := &in.PreferNewerGenerations, &out.PreferNewerGenerations
+		*out = new(GenerationPreference)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SpotPriceBiasPercent != nil {
+		in, out := &in.SpotPriceBiasPercent, &out.SpotPriceBiasPercent
+		*out = new(int64)
+		**out = **in
+	}
+	if in.CapacityTypes != nil {
+		in, out := &in.CapacityTypes, &out.CapacityTypes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MaxPrice != nil {
+		in, out := &in.MaxPrice, &out.MaxPrice
+		*out = new(string)
+		**out = **in
+	}
+	if in.PreferredZones != nil {
+		in, out := &in.PreferredZones, &out.PreferredZones
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PrefixDelegation != nil {
+		in, out := &in.PrefixDelegation, &out.PrefixDelegation
+		*out = new(bool)
+		**out = **in
+	}
+	if in.InstanceStorePolicy != nil {
+		in, out := &in.InstanceStorePolicy, &out.InstanceStorePolicy
+		*out = new(string)
+		**out = **in
+	}
+	if in.HostContainers != nil {
+		in, out := &in.HostContainers, &out.HostContainers
+		*out = new(HostContainers)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Sysctls != nil {
+		in, out := &in.Sysctls, &out.Sysctls
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ContainerLogMaxSize != nil {
+		in, out := &in.ContainerLogMaxSize, &out.ContainerLogMaxSize
+		*out = new(string)
+		**out = **in
+	}
+	if in.ContainerLogMaxFiles != nil {
+		in, out := &in.ContainerLogMaxFiles, &out.ContainerLogMaxFiles
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ImagesToPull != nil {
+		in, out := &in.ImagesToPull, &out.ImagesToPull
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PostBootstrapCommand != nil {
+		in, out := &in.PostBootstrapCommand, &out.PostBootstrapCommand
+		*out = new(PostBootstrapCommand)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ClusterEndpoint != nil {
+		in, out := &in.ClusterEndpoint, &out.ClusterEndpoint
+		*out = new(string)
+		**out = **in
+	}
 	in.LaunchTemplate.DeepCopyInto(&out.LaunchTemplate)
 }
 
@@ -90,6 +175,7 @@ func (in *AWSNodeTemplate) DeepCopyInto(out *AWSNodeTemplate) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSNodeTemplate.
@@ -145,6 +231,11 @@ func (in *AWSNodeTemplateList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AWSNodeTemplateSpec) DeepCopyInto(out *AWSNodeTemplateSpec) {
 	*out = *in
+	if in.BaseTemplateRef != nil {
+		in, out := &in.BaseTemplateRef, &out.BaseTemplateRef
+		*out = new(BaseTemplateRef)
+		**out = **in
+	}
 	if in.UserData != nil {
 		in, out := &in.UserData, &out.UserData
 		*out = new(string)
@@ -158,6 +249,86 @@ func (in *AWSNodeTemplateSpec) DeepCopyInto(out *AWSNodeTemplateSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.AMISelectorTerms != nil {
+		in, out := &in.AMISelectorTerms, &out.AMISelectorTerms
+		*out = make([]map[string]string, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = make(map[string]string, len(*in))
+				for key, val := range *in {
+					(*out)[key] = val
+				}
+			}
+		}
+	}
+	if in.IncludeDeprecatedAMIs != nil {
+		in, out := &in.IncludeDeprecatedAMIs, &out.IncludeDeprecatedAMIs
+		*out = new(bool)
+		**out = **in
+	}
+	if in.PinAMI != nil {
+		in, out := &in.PinAMI, &out.PinAMI
+		*out = new(bool)
+		**out = **in
+	}
+	if in.FallbackAMIIDs != nil {
+		in, out := &in.FallbackAMIIDs, &out.FallbackAMIIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RegistrationTaints != nil {
+		in, out := &in.RegistrationTaints, &out.RegistrationTaints
+		*out = make([]v1.Taint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ContainerRuntime != nil {
+		in, out := &in.ContainerRuntime, &out.ContainerRuntime
+		*out = new(string)
+		**out = **in
+	}
+	if in.SystemReserved != nil {
+		in, out := &in.SystemReserved, &out.SystemReserved
+		*out = make(v1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.KubeReserved != nil {
+		in, out := &in.KubeReserved, &out.KubeReserved
+		*out = make(v1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.EvictionThreshold != nil {
+		in, out := &in.EvictionThreshold, &out.EvictionThreshold
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.EvictionSoft != nil {
+		in, out := &in.EvictionSoft, &out.EvictionSoft
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.EvictionSoftGracePeriod != nil {
+		in, out := &in.EvictionSoftGracePeriod, &out.EvictionSoftGracePeriod
+		*out = make(map[string]metav1.Duration, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.EvictionMaxPodGracePeriod != nil {
+		in, out := &in.EvictionMaxPodGracePeriod, &out.EvictionMaxPodGracePeriod
+		*out = new(int32)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSNodeTemplateSpec.
@@ -170,6 +341,55 @@ func (in *AWSNodeTemplateSpec) DeepCopy() *AWSNodeTemplateSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AWSNodeTemplateStatus) DeepCopyInto(out *AWSNodeTemplateStatus) {
+	*out = *in
+	if in.PinnedAMIs != nil {
+		in, out := &in.PinnedAMIs, &out.PinnedAMIs
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.EffectiveConfiguration != nil {
+		in, out := &in.EffectiveConfiguration, &out.EffectiveConfiguration
+		*out = new(EffectiveConfiguration)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(apis.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSNodeTemplateStatus.
+func (in *AWSNodeTemplateStatus) DeepCopy() *AWSNodeTemplateStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AWSNodeTemplateStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BaseTemplateRef) DeepCopyInto(out *BaseTemplateRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BaseTemplateRef.
+func (in *BaseTemplateRef) DeepCopy() *BaseTemplateRef {
+	if in == nil {
+		return nil
+	}
+	out := new(BaseTemplateRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BlockDevice) DeepCopyInto(out *BlockDevice) {
 	*out = *in
@@ -250,6 +470,117 @@ func (in *BlockDeviceMapping) DeepCopy() *BlockDeviceMapping {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EffectiveConfiguration) DeepCopyInto(out *EffectiveConfiguration) {
+	*out = *in
+	if in.AMIs != nil {
+		in, out := &in.AMIs, &out.AMIs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Subnets != nil {
+		in, out := &in.Subnets, &out.Subnets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SecurityGroups != nil {
+		in, out := &in.SecurityGroups, &out.SecurityGroups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.BlockDeviceMappings != nil {
+		in, out := &in.BlockDeviceMappings, &out.BlockDeviceMappings
+		*out = make([]*BlockDeviceMapping, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(BlockDeviceMapping)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EffectiveConfiguration.
+func (in *EffectiveConfiguration) DeepCopy() *EffectiveConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(EffectiveConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GenerationPreference) DeepCopyInto(out *GenerationPreference) {
+	*out = *in
+	if in.PriceTolerancePercent != nil {
+		in, out := &in.PriceTolerancePercent, &out.PriceTolerancePercent
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GenerationPreference.
+func (in *GenerationPreference) DeepCopy() *GenerationPreference {
+	if in == nil {
+		return nil
+	}
+	out := new(GenerationPreference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostContainer) DeepCopyInto(out *HostContainer) {
+	*out = *in
+	if in.Enabled != nil {
+		in, out := &in.Enabled, &out.Enabled
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Source != nil {
+		in, out := &in.Source, &out.Source
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostContainer.
+func (in *HostContainer) DeepCopy() *HostContainer {
+	if in == nil {
+		return nil
+	}
+	out := new(HostContainer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostContainers) DeepCopyInto(out *HostContainers) {
+	*out = *in
+	if in.Admin != nil {
+		in, out := &in.Admin, &out.Admin
+		*out = new(HostContainer)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Control != nil {
+		in, out := &in.Control, &out.Control
+		*out = new(HostContainer)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostContainers.
+func (in *HostContainers) DeepCopy() *HostContainers {
+	if in == nil {
+		return nil
+	}
+	out := new(HostContainers)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LaunchTemplate) DeepCopyInto(out *LaunchTemplate) {
 	*out = *in
@@ -274,6 +605,21 @@ func (in *LaunchTemplate) DeepCopyInto(out *LaunchTemplate) {
 			}
 		}
 	}
+	if in.KeyName != nil {
+		in, out := &in.KeyName, &out.KeyName
+		*out = new(string)
+		**out = **in
+	}
+	if in.DisableAPITermination != nil {
+		in, out := &in.DisableAPITermination, &out.DisableAPITermination
+		*out = new(bool)
+		**out = **in
+	}
+	if in.InstanceInitiatedShutdownBehavior != nil {
+		in, out := &in.InstanceInitiatedShutdownBehavior, &out.InstanceInitiatedShutdownBehavior
+		*out = new(string)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LaunchTemplate.
@@ -320,3 +666,38 @@ func (in *MetadataOptions) DeepCopy() *MetadataOptions {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PlacementGroup) DeepCopyInto(out *PlacementGroup) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlacementGroup.
+func (in *PlacementGroup) DeepCopy() *PlacementGroup {
+	if in == nil {
+		return nil
+	}
+	out := new(PlacementGroup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostBootstrapCommand) DeepCopyInto(out *PostBootstrapCommand) {
+	*out = *in
+	if in.FailurePolicy != nil {
+		in, out := &in.FailurePolicy, &out.FailurePolicy
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostBootstrapCommand.
+func (in *PostBootstrapCommand) DeepCopy() *PostBootstrapCommand {
+	if in == nil {
+		return nil
+	}
+	out := new(PostBootstrapCommand)
+	in.DeepCopyInto(out)
+	return out
+}
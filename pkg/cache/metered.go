@@ -0,0 +1,56 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"github.com/patrickmn/go-cache"
+)
+
+// Names of the caches in the cache metrics
+const (
+	SubnetsCacheName              = "subnets"
+	SecurityGroupsCacheName       = "security-groups"
+	LaunchTemplatesCacheName      = "launch-templates"
+	SSMCacheName                  = "ssm"
+	ImagesCacheName               = "images"
+	InstanceTypesCacheName        = "instance-types"
+	QuotasCacheName               = "quotas"
+	UnavailableOfferingsCacheName = "unavailable-offerings"
+	SSMQueriesCacheName           = "ssm-queries"
+)
+
+// Metered is a cache that counts whether each Get finds an entry in the karpenter_cache_hits and
+// karpenter_cache_misses metrics, labeled by the name of the cache
+type Metered struct {
+	*cache.Cache
+	name string
+}
+
+func NewMetered(name string, c *cache.Cache) *Metered {
+	return &Metered{
+		Cache: c,
+		name:  name,
+	}
+}
+
+func (m *Metered) Get(k string) (interface{}, bool) {
+	v, ok := m.Cache.Get(k)
+	if ok {
+		cacheHits.WithLabelValues(m.name).Inc()
+	} else {
+		cacheMisses.WithLabelValues(m.name).Inc()
+	}
+	return v, ok
+}
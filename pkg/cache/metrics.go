@@ -0,0 +1,52 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/aws/karpenter-core/pkg/metrics"
+)
+
+const (
+	cacheSubsystem = "cache"
+	cacheNameLabel = "cache"
+)
+
+var (
+	cacheHits = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cacheSubsystem,
+			Name:      "hits",
+			Help:      "Number of cache lookups that found an entry. Labeled by cache.",
+		},
+		[]string{cacheNameLabel},
+	)
+	cacheMisses = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cacheSubsystem,
+			Name:      "misses",
+			Help:      "Number of cache lookups that didn't find an entry, which usually leads to an AWS API call. Labeled by cache.",
+		},
+		[]string{cacheNameLabel},
+	)
+)
+
+func init() {
+	crmetrics.Registry.MustRegister(cacheHits, cacheMisses)
+}
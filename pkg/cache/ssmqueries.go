@@ -0,0 +1,75 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+)
+
+const (
+	SSMQueriesTTL = time.Hour
+)
+
+// SSMQueries stores the SSM parameters that each AWSNodeTemplate resolved its default AMIs from when launching
+// nodes. The default AMIs depend on the instance types that are launched, so the AWSNodeTemplate controller can only
+// resolve them again once they've been recorded here. Queries that aren't launched with expire after the TTL.
+type SSMQueries struct {
+	// key: <nodeTemplate>/<ssmQuery>, value: struct{}{}
+	cache *Metered
+}
+
+func NewSSMQueries(c *cache.Cache) *SSMQueries {
+	return &SSMQueries{
+		cache: NewMetered(SSMQueriesCacheName, c),
+	}
+}
+
+// Record stores the SSM queries that the AWSNodeTemplate resolved, extending the TTL of those already stored
+func (s *SSMQueries) Record(nodeTemplate string, ssmQueries ...string) {
+	for _, ssmQuery := range ssmQueries {
+		s.cache.SetDefault(s.key(nodeTemplate, ssmQuery), struct{}{})
+	}
+}
+
+// List returns the sorted SSM queries that the AWSNodeTemplate resolved
+func (s *SSMQueries) List(nodeTemplate string) []string {
+	var ssmQueries []string
+	prefix := s.key(nodeTemplate, "")
+	for key := range s.cache.Items() {
+		if strings.HasPrefix(key, prefix) {
+			ssmQueries = append(ssmQueries, strings.TrimPrefix(key, prefix))
+		}
+	}
+	sort.Strings(ssmQueries)
+	return ssmQueries
+}
+
+// Delete removes the SSM queries of an AWSNodeTemplate that's being deleted
+func (s *SSMQueries) Delete(nodeTemplate string) {
+	for _, ssmQuery := range s.List(nodeTemplate) {
+		s.cache.Delete(s.key(nodeTemplate, ssmQuery))
+	}
+}
+
+// key returns the cache key of an SSM query of the AWSNodeTemplate. Names of AWSNodeTemplates can't contain a slash,
+// so the prefix is unique for each AWSNodeTemplate.
+func (s *SSMQueries) key(nodeTemplate string, ssmQuery string) string {
+	return fmt.Sprintf("%s/%s", nodeTemplate, ssmQuery)
+}
@@ -0,0 +1,117 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/patrickmn/go-cache"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/samber/lo"
+	. "knative.dev/pkg/logging/testing"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	awscache "github.com/aws/karpenter/pkg/cache"
+)
+
+func TestCache(t *testing.T) {
+	_ = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Cache")
+}
+
+// ExpectCounterValue returns the value of the counter for the cache, or 0 if the cache hasn't been counted yet
+func ExpectCounterValue(name, cacheName string) float64 {
+	families, err := crmetrics.Registry.Gather()
+	ExpectWithOffset(1, err).ToNot(HaveOccurred())
+	family, ok := lo.Find(families, func(f *dto.MetricFamily) bool { return f.GetName() == name })
+	if !ok {
+		return 0
+	}
+	metric, ok := lo.Find(family.GetMetric(), func(m *dto.Metric) bool {
+		return lo.ContainsBy(m.GetLabel(), func(l *dto.LabelPair) bool {
+			return l.GetName() == "cache" && l.GetValue() == cacheName
+		})
+	})
+	if !ok {
+		return 0
+	}
+	return metric.GetCounter().GetValue()
+}
+
+var _ = Describe("Metered", func() {
+	var metered *awscache.Metered
+	BeforeEach(func() {
+		metered = awscache.NewMetered(awscache.SubnetsCacheName, cache.New(time.Minute, time.Minute))
+	})
+	It("should count a hit when the entry is found", func() {
+		metered.SetDefault("key", "value")
+		hits := ExpectCounterValue("karpenter_cache_hits", awscache.SubnetsCacheName)
+		value, ok := metered.Get("key")
+		Expect(ok).To(BeTrue())
+		Expect(value).To(Equal("value"))
+		Expect(ExpectCounterValue("karpenter_cache_hits", awscache.SubnetsCacheName)).To(Equal(hits + 1))
+	})
+	It("should count a miss when the entry isn't found", func() {
+		metered.SetDefault("key", "value")
+		misses := ExpectCounterValue("karpenter_cache_misses", awscache.SubnetsCacheName)
+		_, ok := metered.Get("other-key")
+		Expect(ok).To(BeFalse())
+		Expect(ExpectCounterValue("karpenter_cache_misses", awscache.SubnetsCacheName)).To(Equal(misses + 1))
+	})
+	It("should count hits and misses by cache", func() {
+		other := awscache.NewMetered(awscache.SSMCacheName, cache.New(time.Minute, time.Minute))
+		other.SetDefault("key", "value")
+		subnetHits := ExpectCounterValue("karpenter_cache_hits", awscache.SubnetsCacheName)
+		ssmHits := ExpectCounterValue("karpenter_cache_hits", awscache.SSMCacheName)
+		_, ok := other.Get("key")
+		Expect(ok).To(BeTrue())
+		Expect(ExpectCounterValue("karpenter_cache_hits", awscache.SSMCacheName)).To(Equal(ssmHits + 1))
+		Expect(ExpectCounterValue("karpenter_cache_hits", awscache.SubnetsCacheName)).To(Equal(subnetHits))
+	})
+	It("should count the lookups of unavailable offerings", func() {
+		unavailableOfferings := awscache.NewUnavailableOfferings(cache.New(time.Minute, time.Minute))
+		unavailableOfferings.MarkUnavailable(TestContextWithLogger(GinkgoT()), "InsufficientInstanceCapacity", "m5.large", "test-zone-1a", "spot")
+		hits := ExpectCounterValue("karpenter_cache_hits", awscache.UnavailableOfferingsCacheName)
+		misses := ExpectCounterValue("karpenter_cache_misses", awscache.UnavailableOfferingsCacheName)
+		Expect(unavailableOfferings.IsUnavailable("m5.large", "test-zone-1a", "spot")).To(BeTrue())
+		Expect(unavailableOfferings.IsUnavailable("m5.large", "test-zone-1a", "on-demand")).To(BeFalse())
+		Expect(ExpectCounterValue("karpenter_cache_hits", awscache.UnavailableOfferingsCacheName)).To(Equal(hits + 1))
+		Expect(ExpectCounterValue("karpenter_cache_misses", awscache.UnavailableOfferingsCacheName)).To(Equal(misses + 1))
+	})
+})
+
+var _ = Describe("SSMQueries", func() {
+	var ssmQueries *awscache.SSMQueries
+	BeforeEach(func() {
+		ssmQueries = awscache.NewSSMQueries(cache.New(time.Minute, time.Minute))
+	})
+	It("should list the ssm queries recorded for each node template", func() {
+		ssmQueries.Record("default", "/query/b", "/query/a")
+		ssmQueries.Record("default-arm", "/query/c")
+		Expect(ssmQueries.List("default")).To(Equal([]string{"/query/a", "/query/b"}))
+		Expect(ssmQueries.List("default-arm")).To(Equal([]string{"/query/c"}))
+	})
+	It("should only delete the ssm queries of the node template", func() {
+		ssmQueries.Record("default", "/query/a")
+		ssmQueries.Record("default-arm", "/query/a")
+		ssmQueries.Delete("default")
+		Expect(ssmQueries.List("default")).To(BeEmpty())
+		Expect(ssmQueries.List("default-arm")).To(Equal([]string{"/query/a"}))
+	})
+})
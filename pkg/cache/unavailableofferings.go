@@ -34,12 +34,12 @@ const (
 // GetInstanceTypes responses
 type UnavailableOfferings struct {
 	// key: <capacityType>:<instanceType>:<zone>, value: struct{}{}
-	cache *cache.Cache
+	cache *Metered
 }
 
 func NewUnavailableOfferings(c *cache.Cache) *UnavailableOfferings {
 	return &UnavailableOfferings{
-		cache: c,
+		cache: NewMetered(UnavailableOfferingsCacheName, c),
 	}
 }
 
@@ -64,6 +64,12 @@ func (a AL2) UserData(kubeletConfig *v1alpha5.KubeletConfiguration, taints []v1.
 			Labels:                  labels,
 			CABundle:                caBundle,
 			CustomUserData:          customUserData,
+			InstanceStorePolicy:     a.Options.InstanceStorePolicy,
+			Sysctls:                 a.Options.Sysctls,
+			ContainerLogMaxSize:     a.Options.ContainerLogMaxSize,
+			ContainerLogMaxFiles:    a.Options.ContainerLogMaxFiles,
+			ImagesToPull:            a.Options.ImagesToPull,
+			PostBootstrapCommand:    a.Options.PostBootstrapCommand,
 		},
 	}
 }
@@ -18,8 +18,10 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"strings"
 	"time"
 
+	"github.com/avast/retry-go"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
@@ -28,28 +30,54 @@ import (
 	"github.com/mitchellh/hashstructure/v2"
 	"github.com/patrickmn/go-cache"
 	"github.com/samber/lo"
+	"go.uber.org/multierr"
 	v1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/types"
 	"knative.dev/pkg/logging"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
 
+	awssettings "github.com/aws/karpenter/pkg/apis/config/settings"
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	awscache "github.com/aws/karpenter/pkg/cache"
+	awserrors "github.com/aws/karpenter/pkg/errors"
+	"github.com/aws/karpenter/pkg/utils/nodetemplate"
 
 	"github.com/aws/karpenter-core/pkg/cloudprovider"
+	"github.com/aws/karpenter-core/pkg/events"
 	"github.com/aws/karpenter-core/pkg/scheduling"
 	"github.com/aws/karpenter-core/pkg/utils/functional"
 	"github.com/aws/karpenter-core/pkg/utils/pretty"
 )
 
+const (
+	ssmErrorCacheKeyPrefix = "error:"
+	// ssmErrorCacheTTL is how long a failed SSM lookup is remembered before it's attempted again
+	ssmErrorCacheTTL = 15 * time.Second
+)
+
 type AMIProvider struct {
-	ssmCache   *cache.Cache
-	ec2Cache   *cache.Cache
+	ssmCache   *awscache.Metered
+	ec2Cache   *awscache.Metered
 	ssm        ssmiface.SSMAPI
 	kubeClient client.Client
 	ec2api     ec2iface.EC2API
+	recorder   events.Recorder
 	cm         *pretty.ChangeMonitor
+	ssmQueries *awscache.SSMQueries
+}
+
+func NewAMIProvider(kubeClient client.Client, recorder events.Recorder, ssm ssmiface.SSMAPI, ec2api ec2iface.EC2API, ssmCache *cache.Cache, ec2Cache *cache.Cache, ssmQueries *awscache.SSMQueries) *AMIProvider {
+	return &AMIProvider{
+		ssm:        ssm,
+		ssmCache:   awscache.NewMetered(awscache.SSMCacheName, ssmCache),
+		ec2Cache:   awscache.NewMetered(awscache.ImagesCacheName, ec2Cache),
+		kubeClient: kubeClient,
+		ec2api:     ec2api,
+		recorder:   recorder,
+		cm:         pretty.NewChangeMonitor(),
+		ssmQueries: ssmQueries,
+	}
 }
 
 type AMI struct {
@@ -61,7 +89,14 @@ type AMI struct {
 // If AMI overrides are specified in the AWSNodeTemplate, then only those AMIs will be chosen.
 func (p *AMIProvider) Get(ctx context.Context, provider *v1alpha1.AWS, nodeRequest *cloudprovider.NodeRequest, options *Options, amiFamily AMIFamily) (map[string][]cloudprovider.InstanceType, error) {
 	amiIDs := map[string][]cloudprovider.InstanceType{}
-	amiRequirements, err := p.getAMIRequirements(ctx, nodeRequest.Template.ProviderRef)
+	nodeTemplate, err := p.getNodeTemplate(ctx, nodeRequest.Template.ProviderRef)
+	if err != nil {
+		return nil, err
+	}
+	if lo.FromPtr(provider.RequireNitroTPM) && (nodeTemplate == nil || len(nodeTemplate.Spec.AMISelectors()) == 0) {
+		return nil, fmt.Errorf("requireNitroTPM requires an amiSelector, the default amis don't enable NitroTPM")
+	}
+	amiRequirements, err := p.getAMIRequirements(ctx, nodeTemplate)
 	if err != nil {
 		return nil, err
 	}
@@ -80,24 +115,146 @@ func (p *AMIProvider) Get(ctx context.Context, provider *v1alpha1.AWS, nodeReque
 			return nil, fmt.Errorf("no instance types satisfy requirements of amis %v,", lo.Keys(amiRequirements))
 		}
 	} else {
+		ssmErrs := map[string]error{}
 		for _, instanceType := range nodeRequest.InstanceTypeOptions {
-			amiID, err := p.getDefaultAMIFromSSM(ctx, instanceType, amiFamily.SSMAlias(options.KubernetesVersion, instanceType))
-			if err != nil {
-				return nil, err
+			ssmQuery := amiFamily.SSMAlias(options.KubernetesVersion, instanceType)
+			// The AWSNodeTemplate controller resolves the recorded queries again to report when their AMIs change
+			if nodeTemplate != nil {
+				p.ssmQueries.Record(nodeTemplate.Name, ssmQuery)
+			}
+			amiID, ok := pinnedAMI(nodeTemplate, ssmQuery)
+			if !ok {
+				if amiID, err = p.getDefaultAMIFromSSM(ctx, ssmQuery); err != nil {
+					ssmErrs[ssmQuery] = err
+					if nodeTemplate == nil || len(nodeTemplate.Spec.FallbackAMIIDs) == 0 {
+						continue
+					}
+					fallbackAMIID, fallbackErr := p.getFallbackAMI(ctx, nodeTemplate, instanceType)
+					if fallbackErr != nil {
+						return nil, multierr.Append(err, fallbackErr)
+					}
+					// Instance types that aren't compatible with any of the fallback AMIs can't be launched
+					if fallbackAMIID != "" {
+						amiIDs[fallbackAMIID] = append(amiIDs[fallbackAMIID], instanceType)
+					}
+					continue
+				}
 			}
 			amiIDs[amiID] = append(amiIDs[amiID], instanceType)
 		}
+		if len(ssmErrs) > 0 {
+			if nodeTemplate == nil || len(nodeTemplate.Spec.FallbackAMIIDs) == 0 {
+				return nil, combineSSMErrors(ssmErrs)
+			}
+			for ssmQuery, err := range ssmErrs {
+				logging.FromContext(ctx).With("awsnodetemplate", nodeTemplate.Name).Warnf("Falling back to amis %v, resolving the default ami for query %q failed, %s", nodeTemplate.Spec.FallbackAMIIDs, ssmQuery, err)
+				p.recorder.Publish(AMIFallback(nodeTemplate, ssmQuery))
+			}
+		}
+		if len(amiIDs) == 0 {
+			if len(ssmErrs) > 0 {
+				return nil, fmt.Errorf("no instance types are compatible with fallback amis %v, %w", nodeTemplate.Spec.FallbackAMIIDs, combineSSMErrors(ssmErrs))
+			}
+			return nil, fmt.Errorf("no amis resolved, there are no instance types to resolve them for")
+		}
+	}
+	return amiIDs, nil
+}
+
+// Discover returns the sorted IDs of the AMIs that the amiSelector of the AWSNodeTemplate selects, which are empty
+// when the AWSNodeTemplate uses the default AMIs
+func (p *AMIProvider) Discover(ctx context.Context, nodeTemplate *v1alpha1.AWSNodeTemplate) ([]string, error) {
+	amis, err := p.getAMIRequirements(ctx, nodeTemplate)
+	if err != nil {
+		return nil, err
 	}
+	amiIDs := lo.Map(lo.Keys(amis), func(ami AMI, _ int) string { return ami.AmiID })
+	sort.Strings(amiIDs)
 	return amiIDs, nil
 }
 
-func (p *AMIProvider) getDefaultAMIFromSSM(ctx context.Context, _ cloudprovider.InstanceType, ssmQuery string) (string, error) {
+// Ambiguous returns the groups of AMIs that the amiSelector of the AWSNodeTemplate selects with the same
+// requirements, e.g. AMIs of the same architecture that no tag tells apart, newest first. Only the newest AMI of
+// each group is ever launched, so the others are selected without effect.
+func (p *AMIProvider) Ambiguous(ctx context.Context, nodeTemplate *v1alpha1.AWSNodeTemplate) ([][]string, error) {
+	amiRequirements, err := p.getAMIRequirements(ctx, nodeTemplate)
+	if err != nil {
+		return nil, err
+	}
+	groups := lo.GroupBy(sortAMIsByCreationDate(amiRequirements), func(ami AMI) string {
+		return requirementsKey(amiRequirements[ami])
+	})
+	var ambiguous [][]string
+	for _, key := range lo.Keys(groups) {
+		if len(groups[key]) > 1 {
+			ambiguous = append(ambiguous, lo.Map(groups[key], func(ami AMI, _ int) string { return ami.AmiID }))
+		}
+	}
+	sort.Slice(ambiguous, func(i, j int) bool { return ambiguous[i][0] < ambiguous[j][0] })
+	return ambiguous, nil
+}
+
+// requirementsKey returns a string that's equal for equal requirements, regardless of their order
+func requirementsKey(requirements scheduling.Requirements) string {
+	keys := requirements.Keys().List()
+	return strings.Join(lo.Map(keys, func(key string, _ int) string {
+		requirement := requirements.Get(key)
+		values := requirement.Values()
+		sort.Strings(values)
+		return fmt.Sprintf("%s %s %v", key, requirement.Operator(), values)
+	}), ", ")
+}
+
+// combineSSMErrors combines the errors in order of their SSM query so that the result is stable
+func combineSSMErrors(ssmErrs map[string]error) error {
+	ssmQueries := lo.Keys(ssmErrs)
+	sort.Strings(ssmQueries)
+	return multierr.Combine(lo.Map(ssmQueries, func(ssmQuery string, _ int) error { return ssmErrs[ssmQuery] })...)
+}
+
+// getFallbackAMI returns the newest of the AWSNodeTemplate's fallback AMIs that is compatible with the instance type,
+// or an empty string if there are none
+func (p *AMIProvider) getFallbackAMI(ctx context.Context, nodeTemplate *v1alpha1.AWSNodeTemplate, instanceType cloudprovider.InstanceType) (string, error) {
+	amis, err := p.selectAMIs(ctx, []map[string]string{{"aws-ids": strings.Join(nodeTemplate.Spec.FallbackAMIIDs, ",")}}, true)
+	if err != nil {
+		return "", fmt.Errorf("getting fallback amis, %w", err)
+	}
+	for _, ami := range sortAMIsByCreationDate(amis) {
+		if err := instanceType.Requirements().Compatible(amis[ami]); err == nil {
+			return ami.AmiID, nil
+		}
+	}
+	return "", nil
+}
+
+// Resolve returns the default AMI that the SSM query resolves to
+func (p *AMIProvider) Resolve(ctx context.Context, ssmQuery string) (string, error) {
+	return p.getDefaultAMIFromSSM(ctx, ssmQuery)
+}
+
+func (p *AMIProvider) getDefaultAMIFromSSM(ctx context.Context, ssmQuery string) (string, error) {
 	if id, ok := p.ssmCache.Get(ssmQuery); ok {
 		return id.(string), nil
 	}
-	output, err := p.ssm.GetParameterWithContext(ctx, &ssm.GetParameterInput{Name: aws.String(ssmQuery)})
-	if err != nil {
-		return "", fmt.Errorf("getting ssm parameter %q, %w", ssmQuery, err)
+	// Failures are cached briefly so that provisioning doesn't hammer SSM while it's unavailable
+	if err, ok := p.ssmCache.Get(ssmErrorCacheKeyPrefix + ssmQuery); ok {
+		return "", err.(error)
+	}
+	var output *ssm.GetParameterOutput
+	if err := retry.Do(
+		func() (err error) {
+			output, err = p.ssm.GetParameterWithContext(ctx, &ssm.GetParameterInput{Name: aws.String(ssmQuery)})
+			return err
+		},
+		retry.Context(ctx),
+		retry.RetryIf(awserrors.IsRetryable),
+		retry.Attempts(uint(awssettings.FromContext(ctx).SSMRetryAttempts)),
+		retry.Delay(awssettings.FromContext(ctx).SSMRetryDelay.Duration),
+		retry.LastErrorOnly(true),
+	); err != nil {
+		err = fmt.Errorf("getting ssm parameter %q, %w", ssmQuery, err)
+		p.ssmCache.Set(ssmErrorCacheKeyPrefix+ssmQuery, err, ssmErrorCacheTTL)
+		return "", err
 	}
 	ami := aws.StringValue(output.Parameter.Value)
 	p.ssmCache.SetDefault(ssmQuery, ami)
@@ -107,29 +264,75 @@ func (p *AMIProvider) getDefaultAMIFromSSM(ctx context.Context, _ cloudprovider.
 	return ami, nil
 }
 
-func (p *AMIProvider) getAMIRequirements(ctx context.Context, providerRef *v1alpha5.ProviderRef) (map[AMI]scheduling.Requirements, error) {
-	amiRequirements := map[AMI]scheduling.Requirements{}
-	if providerRef != nil {
-		var ant v1alpha1.AWSNodeTemplate
-		if err := p.kubeClient.Get(ctx, types.NamespacedName{Name: providerRef.Name}, &ant); err != nil {
-			return amiRequirements, fmt.Errorf("retrieving provider reference, %w", err)
-		}
-		if len(ant.Spec.AMISelector) == 0 {
-			return amiRequirements, nil
-		}
-		return p.selectAMIs(ctx, ant.Spec.AMISelector)
+// pinnedAMI returns the AMI that the AWSNodeTemplate controller pinned in the AWSNodeTemplate status for the SSM
+// query, as long as the AWSNodeTemplate still pins AMIs and hasn't requested a rollforward since the AMI was pinned
+func pinnedAMI(nodeTemplate *v1alpha1.AWSNodeTemplate, ssmQuery string) (string, bool) {
+	if nodeTemplate == nil || !lo.FromPtr(nodeTemplate.Spec.PinAMI) {
+		return "", false
+	}
+	if nodeTemplate.Annotations[v1alpha1.AnnotationAMIRollforward] != nodeTemplate.Status.AMIRollforward {
+		return "", false
 	}
-	return amiRequirements, nil
+	amiID, ok := nodeTemplate.Status.PinnedAMIs[ssmQuery]
+	return amiID, ok
 }
 
-func (p *AMIProvider) selectAMIs(ctx context.Context, amiSelector map[string]string) (map[AMI]scheduling.Requirements, error) {
-	ec2AMIs, err := p.fetchAMIsFromEC2(ctx, amiSelector)
+func (p *AMIProvider) getNodeTemplate(ctx context.Context, providerRef *v1alpha5.ProviderRef) (*v1alpha1.AWSNodeTemplate, error) {
+	if providerRef == nil {
+		return nil, nil
+	}
+	nodeTemplate, err := nodetemplate.Get(ctx, p.kubeClient, providerRef.Name)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("retrieving provider reference, %w", err)
+	}
+	return nodeTemplate, nil
+}
+
+func (p *AMIProvider) getAMIRequirements(ctx context.Context, nodeTemplate *v1alpha1.AWSNodeTemplate) (map[AMI]scheduling.Requirements, error) {
+	if nodeTemplate == nil || len(nodeTemplate.Spec.AMISelectors()) == 0 {
+		return map[AMI]scheduling.Requirements{}, nil
+	}
+	amis, err := p.selectAMIs(ctx, nodeTemplate.Spec.AMISelectors(), lo.FromPtr(nodeTemplate.Spec.IncludeDeprecatedAMIs))
+	if err != nil || !lo.FromPtr(nodeTemplate.Spec.RequireNitroTPM) {
+		return amis, err
+	}
+	tpmAMIs := lo.PickBy(amis, func(_ AMI, requirements scheduling.Requirements) bool {
+		return requirements.Has(v1alpha1.LabelInstanceTPMSupport)
+	})
+	if len(tpmAMIs) == 0 {
+		amiIDs := lo.Map(lo.Keys(amis), func(ami AMI, _ int) string { return ami.AmiID })
+		sort.Strings(amiIDs)
+		return nil, fmt.Errorf("none of the amis %v enable NitroTPM, which is required by AWSNodeTemplate %s", amiIDs, nodeTemplate.Name)
+	}
+	return tpmAMIs, nil
+}
+
+// selectAMIs returns the AMIs that match any of the amiSelectors
+func (p *AMIProvider) selectAMIs(ctx context.Context, amiSelectors []map[string]string, includeDeprecated bool) (map[AMI]scheduling.Requirements, error) {
+	var ec2AMIs []*ec2.Image
+	for _, amiSelector := range amiSelectors {
+		images, err := p.fetchAMIsFromEC2(ctx, amiSelector)
+		if err != nil {
+			return nil, err
+		}
+		ec2AMIs = append(ec2AMIs, images...)
 	}
+	ec2AMIs = lo.UniqBy(ec2AMIs, func(ami *ec2.Image) string { return *ami.ImageId })
 	if len(ec2AMIs) == 0 {
 		return nil, fmt.Errorf("no amis exist given constraints")
 	}
+	if !includeDeprecated {
+		now := time.Now()
+		deprecated := lo.Filter(ec2AMIs, func(ami *ec2.Image, _ int) bool { return isDeprecated(ami, now) })
+		if len(deprecated) == len(ec2AMIs) {
+			amiIDs := lo.Map(ec2AMIs, func(ami *ec2.Image, _ int) string { return *ami.ImageId })
+			if p.cm.HasChanged("deprecatedAMIIDs", amiIDs) {
+				logging.FromContext(ctx).Warnf("All images matching the amiSelector are deprecated, %s", amiIDs)
+			}
+			return nil, fmt.Errorf("all amis matching constraints are deprecated")
+		}
+		ec2AMIs = lo.Without(ec2AMIs, deprecated...)
+	}
 	var amiIDs = map[AMI]scheduling.Requirements{}
 	for _, ec2AMI := range ec2AMIs {
 		amiIDs[AMI{*ec2AMI.ImageId, *ec2AMI.CreationDate}] = p.getRequirementsFromImage(ec2AMI)
@@ -138,8 +341,8 @@ func (p *AMIProvider) selectAMIs(ctx context.Context, amiSelector map[string]str
 }
 
 func (p *AMIProvider) fetchAMIsFromEC2(ctx context.Context, amiSelector map[string]string) ([]*ec2.Image, error) {
-	filters := getFilters(amiSelector)
-	hash, err := hashstructure.Hash(filters, hashstructure.FormatV2, &hashstructure.HashOptions{SlicesAsSets: true})
+	input := &ec2.DescribeImagesInput{Filters: getFilters(amiSelector), Owners: getOwners(amiSelector)}
+	hash, err := hashstructure.Hash(input, hashstructure.FormatV2, &hashstructure.HashOptions{SlicesAsSets: true})
 	if err != nil {
 		return nil, err
 	}
@@ -147,9 +350,9 @@ func (p *AMIProvider) fetchAMIsFromEC2(ctx context.Context, amiSelector map[stri
 		return amis.([]*ec2.Image), nil
 	}
 	// This API is not paginated, so a single call suffices.
-	output, err := p.ec2api.DescribeImagesWithContext(ctx, &ec2.DescribeImagesInput{Filters: filters})
+	output, err := p.ec2api.DescribeImagesWithContext(ctx, input)
 	if err != nil {
-		return nil, fmt.Errorf("describing images %+v, %w", filters, err)
+		return nil, fmt.Errorf("describing images %+v, %w", input, err)
 	}
 
 	p.ec2Cache.SetDefault(fmt.Sprint(hash), output.Images)
@@ -169,10 +372,14 @@ func getFilters(amiSelector map[string]string) []*ec2.Filter {
 				Name:   aws.String("image-id"),
 				Values: aws.StringSlice(filterValues),
 			})
-		} else if key == "name" {
+		} else if key == "aws-owners" {
+			continue
+		} else if key == "aws-name" || key == "name" {
+			// The name key predates aws-name and is kept for the selectors that already use it. AMI names can't
+			// contain commas, so the value is a comma-separated list of name patterns either way.
 			filters = append(filters, &ec2.Filter{
 				Name:   aws.String("name"),
-				Values: []*string{aws.String(value)},
+				Values: aws.StringSlice(functional.SplitCommaSeparatedString(value)),
 			})
 		} else {
 			filters = append(filters, &ec2.Filter{
@@ -184,6 +391,31 @@ func getFilters(amiSelector map[string]string) []*ec2.Filter {
 	return filters
 }
 
+// getOwners scopes the AMIs to the accounts in the aws-owners key of the amiSelector. Without it, AMIs are only
+// discovered from the account itself and Amazon, so that public AMIs with the same tags aren't matched. AMIs that
+// are selected by ID aren't scoped, since their IDs already identify them.
+func getOwners(amiSelector map[string]string) []*string {
+	if owners, ok := amiSelector["aws-owners"]; ok {
+		return aws.StringSlice(functional.SplitCommaSeparatedString(owners))
+	}
+	if _, ok := amiSelector["aws-ids"]; ok {
+		return nil
+	}
+	return aws.StringSlice([]string{"self", "amazon"})
+}
+
+// isDeprecated returns true if the image has a deprecation time that has already passed
+func isDeprecated(ami *ec2.Image, now time.Time) bool {
+	if ami.DeprecationTime == nil {
+		return false
+	}
+	deprecationTime, err := time.Parse(time.RFC3339, *ami.DeprecationTime)
+	if err != nil {
+		return false
+	}
+	return !deprecationTime.After(now)
+}
+
 func sortAMIsByCreationDate(amiRequirements map[AMI]scheduling.Requirements) []AMI {
 	amis := lo.Keys(amiRequirements)
 
@@ -208,5 +440,18 @@ func (p *AMIProvider) getRequirementsFromImage(ec2Image *ec2.Image) scheduling.R
 		architecture = value
 	}
 	requirements.Add(scheduling.NewRequirement(v1.LabelArchStable, v1.NodeSelectorOpIn, architecture))
+	// An image only boots on instance types that support its virtualization type (e.g. hvm or paravirtual)
+	if ec2Image.VirtualizationType != nil {
+		requirements.Add(scheduling.NewRequirement(v1alpha1.LabelInstanceVirtualizationType, v1.NodeSelectorOpIn, *ec2Image.VirtualizationType))
+	}
+	// Images that boot with UEFI, e.g. for Secure Boot, fail to boot on instance types that only support legacy BIOS
+	// and vice versa. Images without a boot mode, or that only prefer UEFI, boot in the default mode of the instance type.
+	if bootMode := aws.StringValue(ec2Image.BootMode); bootMode == ec2.BootModeValuesUefi || bootMode == ec2.BootModeValuesLegacyBios {
+		requirements.Add(scheduling.NewRequirement(v1alpha1.LabelInstanceBootMode, v1.NodeSelectorOpIn, bootMode))
+	}
+	// Images that enable NitroTPM can only be launched on instance types that support it
+	if aws.StringValue(ec2Image.TpmSupport) == ec2.TpmSupportValuesV20 {
+		requirements.Add(scheduling.NewRequirement(v1alpha1.LabelInstanceTPMSupport, v1.NodeSelectorOpIn, ec2.TpmSupportValuesV20))
+	}
 	return requirements
 }
@@ -18,6 +18,7 @@ import (
 	core "k8s.io/api/core/v1"
 
 	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
 )
 
 // Options is the node bootstrapping parameters passed from Karpenter to the provisioning node
@@ -31,6 +32,13 @@ type Options struct {
 	AWSENILimitedPodDensity bool
 	ContainerRuntime        *string
 	CustomUserData          *string
+	InstanceStorePolicy     *string
+	HostContainers          *v1alpha1.HostContainers
+	Sysctls                 map[string]string
+	ContainerLogMaxSize     *string
+	ContainerLogMaxFiles    *int32
+	ImagesToPull            []string
+	PostBootstrapCommand    *v1alpha1.PostBootstrapCommand
 }
 
 // Bootstrapper can be implemented to generate a bootstrap script
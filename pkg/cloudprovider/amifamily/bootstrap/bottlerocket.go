@@ -21,6 +21,7 @@ import (
 	"knative.dev/pkg/ptr"
 
 	"github.com/aws/karpenter-core/pkg/utils/resources"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
 
 	"github.com/aws/aws-sdk-go/aws"
 )
@@ -30,6 +31,11 @@ type Bottlerocket struct {
 }
 
 func (b Bottlerocket) Script() (string, error) {
+	if b.CustomUserData != nil {
+		if format := detectUserDataFormat(*b.CustomUserData); format == userDataFormatShellScript || format == userDataFormatMIME {
+			return "", fmt.Errorf("invalid UserData, the Bottlerocket AMI family expects TOML settings but the user data is %s", format)
+		}
+	}
 	s, err := NewBottlerocketConfig(b.CustomUserData)
 	if err != nil {
 		return "", fmt.Errorf("invalid UserData %w", err)
@@ -57,6 +63,22 @@ func (b Bottlerocket) Script() (string, error) {
 		s.Settings.Kubernetes.EvictionHard = b.KubeletConfig.EvictionHard
 	}
 
+	if b.ContainerLogMaxSize != nil {
+		s.Settings.Kubernetes.ContainerLogMaxSize = b.ContainerLogMaxSize
+	}
+	if b.ContainerLogMaxFiles != nil {
+		s.Settings.Kubernetes.ContainerLogMaxFiles = aws.Int(int(*b.ContainerLogMaxFiles))
+	}
+
+	if b.HostContainers != nil {
+		s.Settings.HostContainers = withHostContainer(s.Settings.HostContainers, "admin", b.HostContainers.Admin)
+		s.Settings.HostContainers = withHostContainer(s.Settings.HostContainers, "control", b.HostContainers.Control)
+	}
+
+	if len(b.Sysctls) > 0 {
+		s.MergeSysctls(b.Sysctls)
+	}
+
 	s.Settings.Kubernetes.NodeTaints = map[string][]string{}
 	for _, taint := range b.Taints {
 		s.Settings.Kubernetes.NodeTaints[taint.Key] = append(s.Settings.Kubernetes.NodeTaints[taint.Key], fmt.Sprintf("%s:%s", taint.Value, taint.Effect))
@@ -67,3 +89,23 @@ func (b Bottlerocket) Script() (string, error) {
 	}
 	return base64.StdEncoding.EncodeToString(script), nil
 }
+
+// withHostContainer overlays the host container settings of the provider onto those of the custom user data, leaving
+// the settings that the provider doesn't configure, e.g. the user data of the admin container, as they are
+func withHostContainer(hostContainers map[string]BottlerocketHostContainer, name string, hostContainer *v1alpha1.HostContainer) map[string]BottlerocketHostContainer {
+	if hostContainer == nil {
+		return hostContainers
+	}
+	if hostContainers == nil {
+		hostContainers = map[string]BottlerocketHostContainer{}
+	}
+	merged := hostContainers[name]
+	if hostContainer.Enabled != nil {
+		merged.Enabled = hostContainer.Enabled
+	}
+	if hostContainer.Source != nil {
+		merged.Source = hostContainer.Source
+	}
+	hostContainers[name] = merged
+	return hostContainers
+}
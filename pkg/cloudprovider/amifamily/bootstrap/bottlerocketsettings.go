@@ -38,7 +38,8 @@ type BottlerocketConfig struct {
 // BottlerocketSettings is a subset of all configuration in https://github.com/bottlerocket-os/bottlerocket/blob/develop/sources/models/src/aws-k8s-1.22/mod.rs
 // These settings apply across all K8s versions that karpenter supports.
 type BottlerocketSettings struct {
-	Kubernetes BottlerocketKubernetes `toml:"kubernetes"`
+	Kubernetes     BottlerocketKubernetes               `toml:"kubernetes"`
+	HostContainers map[string]BottlerocketHostContainer `toml:"host-containers,omitempty"`
 }
 
 // BottlerocketKubernetes is k8s specific configuration for bottlerocket api
@@ -70,6 +71,14 @@ type BottlerocketKubernetes struct {
 	TopologyManagerPolicy     *string                          `toml:"topology-manager-policy,omitempty"`
 }
 
+// BottlerocketHostContainer is a host container, e.g. the admin or control container, see more here https://github.com/bottlerocket-os/bottlerocket#host-containers-settings
+type BottlerocketHostContainer struct {
+	Enabled      *bool   `toml:"enabled,omitempty"`
+	Source       *string `toml:"source,omitempty"`
+	Superpowered *bool   `toml:"superpowered,omitempty"`
+	UserData     *string `toml:"user-data,omitempty"`
+}
+
 type BottlerocketStaticPod struct {
 	Enabled  *bool   `toml:"enabled,omitempty"`
 	Manifest *string `toml:"manifest,omitempty"`
@@ -91,10 +100,34 @@ func (c *BottlerocketConfig) UnmarshalTOML(data []byte) error {
 	return nil
 }
 
+// MergeSysctls sets the kernel parameters in settings.kernel.sysctl, keeping the other kernel settings, and the
+// other kernel parameters, of the config
+func (c *BottlerocketConfig) MergeSysctls(sysctls map[string]string) {
+	if c.SettingsRaw == nil {
+		c.SettingsRaw = map[string]interface{}{}
+	}
+	kernel, ok := c.SettingsRaw["kernel"].(map[string]interface{})
+	if !ok {
+		kernel = map[string]interface{}{}
+	}
+	sysctl, ok := kernel["sysctl"].(map[string]interface{})
+	if !ok {
+		sysctl = map[string]interface{}{}
+	}
+	for key, value := range sysctls {
+		sysctl[key] = value
+	}
+	kernel["sysctl"] = sysctl
+	c.SettingsRaw["kernel"] = kernel
+}
+
 func (c *BottlerocketConfig) MarshalTOML() ([]byte, error) {
 	if c.SettingsRaw == nil {
 		c.SettingsRaw = map[string]interface{}{}
 	}
 	c.SettingsRaw["kubernetes"] = c.Settings.Kubernetes
+	if len(c.Settings.HostContainers) > 0 {
+		c.SettingsRaw["host-containers"] = c.Settings.HostContainers
+	}
 	return toml.Marshal(c)
 }
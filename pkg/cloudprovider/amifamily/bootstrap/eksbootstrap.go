@@ -35,6 +35,7 @@ import (
 
 	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
 	"github.com/aws/karpenter-core/pkg/utils/resources"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
 )
 
 type EKS struct {
@@ -58,6 +59,15 @@ func (e EKS) Script() (string, error) {
 	var kubeletExtraArgs strings.Builder
 	userData.WriteString("#!/bin/bash -xe\n")
 	userData.WriteString("exec > >(tee /var/log/user-data.log|logger -t user-data -s 2>/dev/console) 2>&1\n")
+	if lo.FromPtr(e.InstanceStorePolicy) == v1alpha1.InstanceStorePolicyRAID0 {
+		userData.WriteString(raid0InstanceStoreSetup)
+	}
+	if len(e.Sysctls) > 0 {
+		userData.WriteString(sysctlSetup(e.Sysctls))
+	}
+	if len(e.ImagesToPull) > 0 {
+		userData.WriteString(imagePullSetup(e.ImagesToPull, e.ContainerRuntime))
+	}
 	// Due to the way bootstrap.sh is written, parameters should not be passed to it with an equal sign
 	userData.WriteString(fmt.Sprintf("/etc/eks/bootstrap.sh '%s' --apiserver-endpoint '%s' %s", e.ClusterName, e.ClusterEndpoint, caBundleArg))
 
@@ -89,6 +99,15 @@ func (e EKS) Script() (string, error) {
 			kubeletExtraArgs.WriteString(fmt.Sprintf(" --eviction-max-pod-grace-period=%d", ptr.Int32Value(e.KubeletConfig.EvictionMaxPodGracePeriod)))
 		}
 	}
+	// Docker rotates the logs of its containers itself, so the kubelet only rotates them for CRI runtimes
+	if e.ContainerRuntime != "dockerd" {
+		if e.ContainerLogMaxSize != nil {
+			kubeletExtraArgs.WriteString(fmt.Sprintf(" --container-log-max-size=%s", *e.ContainerLogMaxSize))
+		}
+		if e.ContainerLogMaxFiles != nil {
+			kubeletExtraArgs.WriteString(fmt.Sprintf(" --container-log-max-files=%d", *e.ContainerLogMaxFiles))
+		}
+	}
 	if e.ContainerRuntime != "" {
 		userData.WriteString(fmt.Sprintf(" \\\n--container-runtime %s", e.ContainerRuntime))
 	}
@@ -98,6 +117,16 @@ func (e EKS) Script() (string, error) {
 	if e.KubeletConfig != nil && len(e.KubeletConfig.ClusterDNS) > 0 {
 		userData.WriteString(fmt.Sprintf(" \\\n--dns-cluster-ip '%s'", e.KubeletConfig.ClusterDNS[0]))
 	}
+	if len(e.ImagesToPull) > 0 || e.PostBootstrapCommand != nil {
+		userData.WriteString("\n")
+	}
+	if len(e.ImagesToPull) > 0 {
+		userData.WriteString(imagePullStart())
+	}
+	// The command is the final step, so that it runs against the node as it's been configured
+	if e.PostBootstrapCommand != nil {
+		userData.WriteString(postBootstrap(e.PostBootstrapCommand))
+	}
 	userDataMerged, err := e.mergeCustomUserData(&userData)
 	if err != nil {
 		return "", err
@@ -187,6 +216,20 @@ func copyCustomUserDataParts(writer *multipart.Writer, customUserData *string) e
 		// No custom user data specified, so nothing to copy over.
 		return nil
 	}
+	switch format := detectUserDataFormat(*customUserData); format {
+	case userDataFormatShellScript:
+		// A shell script is run as a part of its own, ahead of Karpenter's bootstrapping
+		partWriter, err := writer.CreatePart(textproto.MIMEHeader{"Content-Type": []string{"text/x-shellscript; charset=\"us-ascii\""}})
+		if err != nil {
+			return fmt.Errorf("parsing custom user data input %w", err)
+		}
+		if _, err := partWriter.Write([]byte(*customUserData)); err != nil {
+			return fmt.Errorf("parsing custom user data input %w", err)
+		}
+		return nil
+	case userDataFormatTOML:
+		return fmt.Errorf("parsing custom user data input, the AMI family expects a shell script or %s but the user data is %s", userDataFormatMIME, format)
+	}
 	reader, err := getMultiPartReader(*customUserData)
 	if err != nil {
 		return fmt.Errorf("parsing custom user data input %w", err)
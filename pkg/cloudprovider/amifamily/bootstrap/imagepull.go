@@ -0,0 +1,101 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrap
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// ImagePullUnit is the systemd unit that pulls the imagesToPull of the provider once the container runtime runs
+	ImagePullUnit = "karpenter-image-pull.service"
+	// ImagePullScript is the script that the image pull unit runs
+	ImagePullScript = "/usr/local/bin/karpenter-image-pull.sh"
+
+	containerdPull = `crictl --runtime-endpoint unix:///run/containerd/containerd.sock pull ${password:+--creds "AWS:${password}"} "${image}"`
+	dockerPull     = `{ [[ -z "${password}" ]] || docker login --username AWS --password-stdin "${image%%/*}" <<< "${password}"; } && docker pull "${image}"`
+)
+
+// imagePullScript pulls each image, retrying failed pulls, and fails if any of the images couldn't be pulled. Images
+// of ECR repositories are pulled with the credentials of the instance profile, the same way as the EKS optimized AMIs
+// pull the pause image, while the images of other registries are pulled as the container runtime is configured to.
+const imagePullScript = `#!/usr/bin/env bash
+set -o pipefail
+images=(%s)
+status=0
+for image in "${images[@]}"; do
+  password=""
+  if [[ "${image}" =~ ^[0-9]{12}\.dkr\.ecr(-fips)?\.([a-z0-9-]+)\.amazonaws\.com(\.cn)?/ ]]; then
+    password=$(aws ecr get-login-password --region "${BASH_REMATCH[2]}")
+  fi
+  for attempt in 1 2 3; do
+    if %s; then
+      break
+    fi
+    if [[ ${attempt} -eq 3 ]]; then
+      echo "failed to pull ${image}"
+      status=1
+    else
+      sleep 10
+    fi
+  done
+done
+exit ${status}
+`
+
+// imagePullSetup installs the script as a oneshot unit that runs after the container runtime on every boot. It's
+// written ahead of the bootstrap script, which configures the container runtime.
+func imagePullSetup(images []string, containerRuntime string) string {
+	pull := containerdPull
+	runtimeUnit := "containerd.service"
+	if containerRuntime == "dockerd" {
+		pull = dockerPull
+		runtimeUnit = "docker.service"
+	}
+	// The images are validated as image references, which can't contain quotes
+	quoted := make([]string, 0, len(images))
+	for _, image := range images {
+		quoted = append(quoted, fmt.Sprintf("'%s'", image))
+	}
+	var script strings.Builder
+	script.WriteString(fmt.Sprintf("cat <<'EOF' > %s\n", ImagePullScript))
+	script.WriteString(fmt.Sprintf(imagePullScript, strings.Join(quoted, " "), pull))
+	script.WriteString("EOF\n")
+	script.WriteString(fmt.Sprintf("chmod +x %s\n", ImagePullScript))
+	script.WriteString(fmt.Sprintf(`cat <<'EOF' > /etc/systemd/system/%s
+[Unit]
+Description=Pull the images of the node template
+Wants=network-online.target
+After=network-online.target %s
+
+[Service]
+Type=oneshot
+ExecStart=%s
+
+[Install]
+WantedBy=multi-user.target
+EOF
+systemctl daemon-reload
+systemctl enable %s
+`, ImagePullUnit, runtimeUnit, ImagePullScript, ImagePullUnit))
+	return script.String()
+}
+
+// imagePullStart starts the unit without waiting for the pulls, so that they don't delay the kubelet. It runs after
+// the bootstrap script, which restarts the container runtime with its configuration.
+func imagePullStart() string {
+	return fmt.Sprintf("systemctl start --no-block %s\n", ImagePullUnit)
+}
@@ -0,0 +1,86 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrap
+
+const (
+	// InstanceStoreUnit is the systemd unit that prepares the instance store volumes before the kubelet starts
+	InstanceStoreUnit = "karpenter-instance-store.service"
+	// InstanceStoreKubeletDropIn makes the kubelet require the instance store unit, so that the kubelet doesn't start,
+	// and the node doesn't become ready, until the instance store is prepared
+	InstanceStoreKubeletDropIn = "/etc/systemd/system/kubelet.service.d/10-karpenter-instance-store.conf"
+)
+
+// raid0InstanceStoreScript combines the NVMe instance store volumes into a RAID0 array (or uses the single volume
+// as is), formats it and mounts it as the root directory of the kubelet. The contents of the directory, such as the
+// kubeconfig of the EKS optimized AMIs, are copied onto the volume first. Instance types without an instance store
+// are left as they are.
+const raid0InstanceStoreScript = `#!/usr/bin/env bash
+set -eo pipefail
+if mountpoint -q /var/lib/kubelet; then
+  exit 0
+fi
+devices=()
+for link in /dev/disk/by-id/nvme-Amazon_EC2_NVMe_Instance_Storage_*; do
+  [[ -e "${link}" ]] || continue
+  device=$(realpath "${link}")
+  [[ " ${devices[*]} " == *" ${device} "* ]] || devices+=("${device}")
+done
+if [[ ${#devices[@]} -eq 0 ]]; then
+  exit 0
+fi
+device=${devices[0]}
+if [[ ${#devices[@]} -gt 1 ]]; then
+  device=/dev/md/kubernetes
+  if [[ ! -e "${device}" ]]; then
+    mdadm --create --force --verbose "${device}" --level=0 --name=kubernetes --raid-devices=${#devices[@]} "${devices[@]}"
+  fi
+fi
+if ! blkid "${device}" >/dev/null; then
+  mkfs.xfs -f "${device}"
+fi
+mkdir -p /mnt/k8s-disks /var/lib/kubelet
+mount "${device}" /mnt/k8s-disks
+cp -a /var/lib/kubelet/. /mnt/k8s-disks/
+umount /mnt/k8s-disks
+mount "${device}" /var/lib/kubelet
+`
+
+// raid0InstanceStoreSetup installs the script as a oneshot unit that the kubelet requires. It runs ahead of the
+// bootstrap script, which starts the kubelet.
+const raid0InstanceStoreSetup = `cat <<'EOF' > /usr/local/bin/karpenter-instance-store.sh
+` + raid0InstanceStoreScript + `EOF
+chmod +x /usr/local/bin/karpenter-instance-store.sh
+cat <<'EOF' > /etc/systemd/system/` + InstanceStoreUnit + `
+[Unit]
+Description=Prepare the instance store volumes for the kubelet
+Before=kubelet.service
+
+[Service]
+Type=oneshot
+RemainAfterExit=yes
+ExecStart=/usr/local/bin/karpenter-instance-store.sh
+
+[Install]
+WantedBy=multi-user.target
+EOF
+mkdir -p /etc/systemd/system/kubelet.service.d
+cat <<'EOF' > ` + InstanceStoreKubeletDropIn + `
+[Unit]
+Requires=` + InstanceStoreUnit + `
+After=` + InstanceStoreUnit + `
+EOF
+systemctl daemon-reload
+systemctl enable ` + InstanceStoreUnit + `
+`
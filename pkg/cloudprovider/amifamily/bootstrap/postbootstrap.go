@@ -0,0 +1,48 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrap
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/samber/lo"
+
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+)
+
+// PostBootstrapScript is the script that the post bootstrap command is written to
+const PostBootstrapScript = "/usr/local/bin/karpenter-post-bootstrap.sh"
+
+// postBootstrap runs the post bootstrap command once the bootstrap script has started the kubelet. The command is
+// written encoded, so that it's run as is regardless of the quotes and heredocs that it contains.
+func postBootstrap(command *v1alpha1.PostBootstrapCommand) string {
+	var script strings.Builder
+	script.WriteString(fmt.Sprintf("echo '%s' | base64 -d > %s\n", base64.StdEncoding.EncodeToString([]byte(command.Command)), PostBootstrapScript))
+	script.WriteString(fmt.Sprintf("chmod +x %s\n", PostBootstrapScript))
+	if lo.FromPtr(command.FailurePolicy) == v1alpha1.PostBootstrapCommandFailurePolicyIgnore {
+		script.WriteString(fmt.Sprintf("/bin/bash %s || echo \"Post bootstrap command failed with exit code $?, ignoring\"\n", PostBootstrapScript))
+		return script.String()
+	}
+	// The kubelet is already running, so it's stopped to keep a node that failed the command from staying ready
+	script.WriteString(fmt.Sprintf(`if ! /bin/bash %s; then
+  echo "Post bootstrap command failed, stopping the kubelet"
+  systemctl stop kubelet
+  exit 1
+fi
+`, PostBootstrapScript))
+	return script.String()
+}
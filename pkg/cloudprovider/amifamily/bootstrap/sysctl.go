@@ -0,0 +1,42 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrap
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+// SysctlConfig is the sysctl.d file that the sysctls of the provider are written to. It sorts after the files of the
+// AMIs, so that its parameters take precedence.
+const SysctlConfig = "/etc/sysctl.d/99-karpenter.conf"
+
+// sysctlSetup writes the sysctls to the sysctl.d file, so that they're also applied on reboot, and applies them. It
+// runs ahead of the bootstrap script, which starts the kubelet.
+func sysctlSetup(sysctls map[string]string) string {
+	keys := lo.Keys(sysctls)
+	sort.Strings(keys) // ensures the file is deterministic, so that it doesn't change the launch template
+	var script strings.Builder
+	script.WriteString(fmt.Sprintf("cat <<'EOF' > %s\n", SysctlConfig))
+	for _, key := range keys {
+		script.WriteString(fmt.Sprintf("%s = %s\n", key, sysctls[key]))
+	}
+	script.WriteString("EOF\n")
+	script.WriteString(fmt.Sprintf("sysctl -p %s\n", SysctlConfig))
+	return script.String()
+}
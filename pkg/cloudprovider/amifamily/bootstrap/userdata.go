@@ -0,0 +1,47 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrap
+
+import (
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// userDataFormat is the format of custom user data, which has to match the format that the AMI family expects
+type userDataFormat string
+
+const (
+	userDataFormatShellScript userDataFormat = "a shell script"
+	userDataFormatMIME        userDataFormat = "a MIME multi-part archive"
+	userDataFormatTOML        userDataFormat = "TOML settings"
+	userDataFormatUnknown     userDataFormat = "unknown"
+)
+
+// detectUserDataFormat tells apart the formats that the AMI families expect, so that user data that's written for
+// another AMI family, such as a shell script for Bottlerocket, is rejected with a message that names the mismatch
+// rather than failing to parse, or worse, parsing and being ignored.
+func detectUserDataFormat(userData string) userDataFormat {
+	if strings.HasPrefix(strings.TrimSpace(userData), "#!") {
+		return userDataFormatShellScript
+	}
+	if _, err := getMultiPartReader(userData); err == nil {
+		return userDataFormatMIME
+	}
+	if err := toml.Unmarshal([]byte(userData), &map[string]interface{}{}); err == nil {
+		return userDataFormatTOML
+	}
+	return userDataFormatUnknown
+}
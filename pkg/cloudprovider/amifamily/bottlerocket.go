@@ -63,6 +63,10 @@ func (b Bottlerocket) UserData(kubeletConfig *v1alpha5.KubeletConfiguration, tai
 			Labels:                  labels,
 			CABundle:                caBundle,
 			CustomUserData:          customUserData,
+			HostContainers:          b.Options.HostContainers,
+			Sysctls:                 b.Options.Sysctls,
+			ContainerLogMaxSize:     b.Options.ContainerLogMaxSize,
+			ContainerLogMaxFiles:    b.Options.ContainerLogMaxFiles,
 		},
 	}
 }
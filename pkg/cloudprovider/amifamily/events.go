@@ -0,0 +1,44 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package amifamily
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/aws/karpenter-core/pkg/events"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+)
+
+func AMIChanged(nodeTemplate *v1alpha1.AWSNodeTemplate, ssmQuery, oldAMIID, newAMIID string) events.Event {
+	return events.Event{
+		InvolvedObject: nodeTemplate,
+		Type:           v1.EventTypeNormal,
+		Reason:         "AMIChanged",
+		Message:        fmt.Sprintf("AWSNodeTemplate %s event: AMI resolved for %s changed from %s to %s", nodeTemplate.Name, ssmQuery, oldAMIID, newAMIID),
+		DedupeValues:   []string{nodeTemplate.Name, ssmQuery, newAMIID},
+	}
+}
+
+func AMIFallback(nodeTemplate *v1alpha1.AWSNodeTemplate, ssmQuery string) events.Event {
+	return events.Event{
+		InvolvedObject: nodeTemplate,
+		Type:           v1.EventTypeWarning,
+		Reason:         "AMIFallback",
+		Message:        fmt.Sprintf("AWSNodeTemplate %s event: Failed to resolve AMI for %s, using fallback AMIs %v", nodeTemplate.Name, ssmQuery, nodeTemplate.Spec.FallbackAMIIDs),
+		DedupeValues:   []string{nodeTemplate.Name, ssmQuery},
+	}
+}
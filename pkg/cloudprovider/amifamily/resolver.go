@@ -29,11 +29,13 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	awscache "github.com/aws/karpenter/pkg/cache"
 	"github.com/aws/karpenter/pkg/cloudprovider/amifamily/bootstrap"
 
 	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
 	"github.com/aws/karpenter-core/pkg/cloudprovider"
-	"github.com/aws/karpenter-core/pkg/utils/pretty"
+	"github.com/aws/karpenter-core/pkg/events"
+	"github.com/aws/karpenter-core/pkg/scheduling"
 )
 
 var DefaultEBS = v1alpha1.BlockDevice{
@@ -54,6 +56,13 @@ type Options struct {
 	ClusterEndpoint         string
 	AWSENILimitedPodDensity bool
 	InstanceProfile         string
+	InstanceStorePolicy     *string
+	HostContainers          *v1alpha1.HostContainers
+	Sysctls                 map[string]string
+	ContainerLogMaxSize     *string
+	ContainerLogMaxFiles    *int32
+	ImagesToPull            []string
+	PostBootstrapCommand    *v1alpha1.PostBootstrapCommand
 	CABundle                *string `hash:"ignore"`
 	// Level-triggered fields that may change out of sync.
 	KubernetesVersion string
@@ -66,11 +75,14 @@ type Options struct {
 // LaunchTemplate holds the dynamically generated launch template parameters
 type LaunchTemplate struct {
 	*Options
-	UserData            bootstrap.Bootstrapper
-	BlockDeviceMappings []*v1alpha1.BlockDeviceMapping
-	MetadataOptions     *v1alpha1.MetadataOptions
-	AMIID               string
-	InstanceTypes       []cloudprovider.InstanceType `hash:"ignore"`
+	UserData                          bootstrap.Bootstrapper
+	BlockDeviceMappings               []*v1alpha1.BlockDeviceMapping
+	MetadataOptions                   *v1alpha1.MetadataOptions
+	KeyName                           *string
+	DisableAPITermination             *bool
+	InstanceInitiatedShutdownBehavior *string
+	AMIID                             string
+	InstanceTypes                     []cloudprovider.InstanceType `hash:"ignore"`
 }
 
 // AMIFamily can be implemented to override the default logic for generating dynamic launch template parameters
@@ -102,16 +114,9 @@ func (d DefaultFamily) FeatureFlags() FeatureFlags {
 }
 
 // New constructs a new launch template Resolver
-func New(kubeClient client.Client, ssm ssmiface.SSMAPI, ec2api ec2iface.EC2API, ssmCache *cache.Cache, ec2Cache *cache.Cache) *Resolver {
+func New(kubeClient client.Client, recorder events.Recorder, ssm ssmiface.SSMAPI, ec2api ec2iface.EC2API, ssmCache *cache.Cache, ec2Cache *cache.Cache, ssmQueries *awscache.SSMQueries) *Resolver {
 	return &Resolver{
-		amiProvider: &AMIProvider{
-			ssm:        ssm,
-			ssmCache:   ssmCache,
-			ec2Cache:   ec2Cache,
-			kubeClient: kubeClient,
-			ec2api:     ec2api,
-			cm:         pretty.NewChangeMonitor(),
-		},
+		amiProvider:      NewAMIProvider(kubeClient, recorder, ssm, ec2api, ssmCache, ec2Cache, ssmQueries),
 		UserDataProvider: NewUserDataProvider(kubeClient),
 	}
 }
@@ -129,33 +134,77 @@ func (r Resolver) Resolve(ctx context.Context, provider *v1alpha1.AWS, nodeReque
 		return nil, err
 	}
 	var resolvedTemplates []*LaunchTemplate
-	for amiID, instanceTypes := range amiIDs {
-		resolved := &LaunchTemplate{
-			Options: options,
-			UserData: amiFamily.UserData(
-				nodeRequest.Template.KubeletConfiguration,
-				append(nodeRequest.Template.Taints, nodeRequest.Template.StartupTaints...),
-				options.Labels,
-				options.CABundle,
-				instanceTypes,
-				aws.String(userDataString),
-			),
-			BlockDeviceMappings: provider.BlockDeviceMappings,
-			MetadataOptions:     provider.MetadataOptions,
-			AMIID:               amiID,
-			InstanceTypes:       instanceTypes,
+	for amiID, amiInstanceTypes := range amiIDs {
+		for kubeletConfig, instanceTypes := range kubeletConfigurations(provider, nodeRequest.Template.KubeletConfiguration, amiInstanceTypes) {
+			resolved := &LaunchTemplate{
+				Options: options,
+				UserData: amiFamily.UserData(
+					kubeletConfig,
+					append(nodeRequest.Template.Taints, nodeRequest.Template.StartupTaints...),
+					options.Labels,
+					options.CABundle,
+					instanceTypes,
+					aws.String(userDataString),
+				),
+				BlockDeviceMappings:               provider.BlockDeviceMappings,
+				MetadataOptions:                   provider.MetadataOptions,
+				KeyName:                           provider.KeyName,
+				DisableAPITermination:             provider.DisableAPITermination,
+				InstanceInitiatedShutdownBehavior: provider.InstanceInitiatedShutdownBehavior,
+				AMIID:                             amiID,
+				InstanceTypes:                     instanceTypes,
+			}
+			if resolved.BlockDeviceMappings == nil {
+				resolved.BlockDeviceMappings = amiFamily.DefaultBlockDeviceMappings()
+			}
+			if resolved.MetadataOptions == nil {
+				resolved.MetadataOptions = amiFamily.DefaultMetadataOptions()
+			}
+			resolvedTemplates = append(resolvedTemplates, resolved)
 		}
-		if resolved.BlockDeviceMappings == nil {
-			resolved.BlockDeviceMappings = amiFamily.DefaultBlockDeviceMappings()
-		}
-		if resolved.MetadataOptions == nil {
-			resolved.MetadataOptions = amiFamily.DefaultMetadataOptions()
-		}
-		resolvedTemplates = append(resolvedTemplates, resolved)
 	}
 	return resolvedTemplates, nil
 }
 
+// ResolveAMIs returns the AMIs that the instance types of the node request are launched with, without generating
+// launch templates for them
+func (r Resolver) ResolveAMIs(ctx context.Context, provider *v1alpha1.AWS, nodeRequest *cloudprovider.NodeRequest, options *Options) (map[string][]cloudprovider.InstanceType, error) {
+	return r.amiProvider.Get(ctx, provider, nodeRequest, options, GetAMIFamily(provider.AMIFamily, options))
+}
+
+// AMIRequirements returns the requirements of each of the AMIs that the amiSelector of the AWSNodeTemplate selects,
+// keyed by AMI ID, which are empty when the AWSNodeTemplate uses the default AMIs
+func (r Resolver) AMIRequirements(ctx context.Context, nodeTemplate *v1alpha1.AWSNodeTemplate) (map[string]scheduling.Requirements, error) {
+	amis, err := r.amiProvider.getAMIRequirements(ctx, nodeTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return lo.MapKeys(amis, func(_ scheduling.Requirements, ami AMI) string { return ami.AmiID }), nil
+}
+
+// kubeletConfigurations returns the kubelet configuration that each of the instance types is bootstrapped with. With
+// prefix delegation, bootstrap.sh and Bottlerocket would derive max pods from the addresses of the ENIs, so the max
+// pods that the instance types advertise is passed explicitly instead, in one configuration per distinct value.
+func kubeletConfigurations(provider *v1alpha1.AWS, kubeletConfig *v1alpha5.KubeletConfiguration,
+	instanceTypes []cloudprovider.InstanceType) map[*v1alpha5.KubeletConfiguration][]cloudprovider.InstanceType {
+	if !lo.FromPtr(provider.PrefixDelegation) || (kubeletConfig != nil && kubeletConfig.MaxPods != nil) {
+		return map[*v1alpha5.KubeletConfiguration][]cloudprovider.InstanceType{kubeletConfig: instanceTypes}
+	}
+	configurations := map[*v1alpha5.KubeletConfiguration][]cloudprovider.InstanceType{}
+	for maxPods, group := range lo.GroupBy(instanceTypes, func(instanceType cloudprovider.InstanceType) int64 {
+		pods := instanceType.Resources()[core.ResourcePods]
+		return pods.Value()
+	}) {
+		configuration := &v1alpha5.KubeletConfiguration{}
+		if kubeletConfig != nil {
+			configuration = kubeletConfig.DeepCopy()
+		}
+		configuration.MaxPods = lo.ToPtr(int32(maxPods))
+		configurations[configuration] = group
+	}
+	return configurations
+}
+
 func GetAMIFamily(amiFamily *string, options *Options) AMIFamily {
 	switch aws.StringValue(amiFamily) {
 	case v1alpha1.AMIFamilyBottlerocket:
@@ -39,7 +39,12 @@ func (u Ubuntu) SSMAlias(version string, instanceType cloudprovider.InstanceType
 
 // UserData returns the default userdata script for the AMI Family
 func (u Ubuntu) UserData(kubeletConfig *v1alpha5.KubeletConfiguration, taints []v1.Taint, labels map[string]string, caBundle *string, _ []cloudprovider.InstanceType, customUserData *string) bootstrap.Bootstrapper {
+	var containerRuntime string
+	if kubeletConfig != nil && kubeletConfig.ContainerRuntime != nil {
+		containerRuntime = *kubeletConfig.ContainerRuntime
+	}
 	return bootstrap.EKS{
+		ContainerRuntime: containerRuntime,
 		Options: bootstrap.Options{
 			ClusterName:             u.Options.ClusterName,
 			ClusterEndpoint:         u.Options.ClusterEndpoint,
@@ -49,6 +54,12 @@ func (u Ubuntu) UserData(kubeletConfig *v1alpha5.KubeletConfiguration, taints []
 			Labels:                  labels,
 			CABundle:                caBundle,
 			CustomUserData:          customUserData,
+			InstanceStorePolicy:     u.Options.InstanceStorePolicy,
+			Sysctls:                 u.Options.Sysctls,
+			ContainerLogMaxSize:     u.Options.ContainerLogMaxSize,
+			ContainerLogMaxFiles:    u.Options.ContainerLogMaxFiles,
+			ImagesToPull:            u.Options.ImagesToPull,
+			PostBootstrapCommand:    u.Options.PostBootstrapCommand,
 		},
 	}
 }
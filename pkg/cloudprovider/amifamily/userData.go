@@ -17,13 +17,12 @@ package amifamily
 import (
 	"context"
 
-	"k8s.io/apimachinery/pkg/types"
 	"knative.dev/pkg/logging"
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
-	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/utils/nodetemplate"
 )
 
 type UserDataProvider struct {
@@ -37,13 +36,14 @@ func NewUserDataProvider(kubeClient client.Client) *UserDataProvider {
 	}
 }
 
-// Get returns the UserData from the ConfigMap specified in the provider
+// Get returns the UserData of the AWSNodeTemplate specified in the provider, including UserData inherited from its base
+// templates
 func (u *UserDataProvider) Get(ctx context.Context, providerRef *v1alpha5.ProviderRef) (string, error) {
 	if providerRef == nil {
 		return "", nil
 	}
-	var awsnodetemplate v1alpha1.AWSNodeTemplate
-	if err := u.kubeClient.Get(ctx, types.NamespacedName{Name: providerRef.Name}, &awsnodetemplate); err != nil {
+	awsnodetemplate, err := nodetemplate.Get(ctx, u.kubeClient, providerRef.Name)
+	if err != nil {
 		logging.FromContext(ctx).Errorf("retrieving provider reference, %s", err)
 		return "", err
 	}
@@ -21,17 +21,21 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
 	"github.com/aws/aws-sdk-go/service/ssm"
 	"github.com/patrickmn/go-cache"
 	"github.com/samber/lo"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/transport"
@@ -42,11 +46,17 @@ import (
 	coreapis "github.com/aws/karpenter-core/pkg/apis"
 	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
 	"github.com/aws/karpenter-core/pkg/cloudprovider"
+	"github.com/aws/karpenter-core/pkg/events"
 	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	"github.com/aws/karpenter-core/pkg/scheduling"
+	"github.com/aws/karpenter-core/pkg/utils/pretty"
+	"github.com/aws/karpenter-core/pkg/utils/project"
 	"github.com/aws/karpenter/pkg/apis"
+	awssettings "github.com/aws/karpenter/pkg/apis/config/settings"
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
 	"github.com/aws/karpenter/pkg/cloudprovider/amifamily"
 	awscontext "github.com/aws/karpenter/pkg/context"
+	"github.com/aws/karpenter/pkg/utils/nodetemplate"
 )
 
 const (
@@ -66,6 +76,8 @@ type CloudProvider struct {
 	instanceTypeProvider *InstanceTypeProvider
 	instanceProvider     *InstanceProvider
 	kubeClient           k8sClient.Client
+	recorder             events.Recorder
+	cm                   *pretty.ChangeMonitor
 }
 
 func New(ctx awscontext.Context) *CloudProvider {
@@ -83,18 +95,21 @@ func New(ctx awscontext.Context) *CloudProvider {
 	instanceTypeProvider := NewInstanceTypeProvider(ctx, ctx.Session, ec2api, subnetProvider, ctx.UnavailableOfferingsCache, ctx.StartAsync)
 	return &CloudProvider{
 		kubeClient:           ctx.KubeClient,
+		recorder:             ctx.EventRecorder,
+		cm:                   pretty.NewChangeMonitor(),
 		instanceTypeProvider: instanceTypeProvider,
-		instanceProvider: NewInstanceProvider(ctx, ec2api, instanceTypeProvider, subnetProvider,
+		instanceProvider: NewInstanceProvider(ctx, ec2api, eventbridge.New(ctx.Session), ctx.EventRecorder, instanceTypeProvider, subnetProvider,
 			NewLaunchTemplateProvider(
 				ctx,
 				ec2api,
 				ctx.KubernetesInterface,
-				amifamily.New(ctx.KubeClient, ssm.New(ctx.Session), ec2api, cache.New(awscontext.CacheTTL, awscontext.CacheCleanupInterval), cache.New(awscontext.CacheTTL, awscontext.CacheCleanupInterval)),
+				amifamily.New(ctx.KubeClient, ctx.EventRecorder, ssm.New(ctx.Session), ec2api, cache.New(awscontext.CacheTTL, awscontext.CacheCleanupInterval), cache.New(awscontext.CacheTTL, awscontext.CacheCleanupInterval), ctx.SSMQueriesCache),
 				NewSecurityGroupProvider(ec2api),
 				lo.Must(getCABundle(ctx, ctx.RESTConfig)),
 				ctx.StartAsync,
 				kubeDNSIP,
 			),
+			NewQuotaProvider(servicequotas.New(ctx.Session), ec2api, ctx.EventRecorder),
 		),
 	}
 }
@@ -112,11 +127,48 @@ func checkEC2Connectivity(ctx context.Context, api *ec2.EC2) error {
 
 // Create a node given the constraints.
 func (c *CloudProvider) Create(ctx context.Context, nodeRequest *cloudprovider.NodeRequest) (*v1.Node, error) {
-	aws, err := c.getProvider(ctx, nodeRequest.Template.Provider, nodeRequest.Template.ProviderRef)
+	nodeTemplate, err := c.getNodeTemplate(ctx, nodeRequest.Template.ProviderRef)
 	if err != nil {
 		return nil, err
 	}
-	return c.instanceProvider.Create(ctx, aws, nodeRequest)
+	aws, err := c.getProvider(nodeRequest.Template.Provider, nodeTemplate)
+	if err != nil {
+		return nil, err
+	}
+	// Bootstrap the kubelet with the same reserved resources and eviction thresholds that the instance types
+	// subtracted from their capacity
+	template := *nodeRequest.Template
+	template.KubeletConfiguration = kubeletConfiguration(nodeRequest.Template.KubeletConfiguration, nodeTemplate)
+	if nodeTemplate != nil && len(nodeTemplate.Spec.RegistrationTaints) > 0 {
+		// Registration taints are rendered into the user data alongside the startup taints
+		template.StartupTaints = append(append([]v1.Taint{}, template.StartupTaints...), nodeTemplate.Spec.RegistrationTaints...)
+	}
+	request := *nodeRequest
+	request.Template = &template
+	node, err := c.instanceProvider.Create(ctx, aws, &request, versionTags(ctx, nodeTemplate))
+	if err != nil {
+		return nil, err
+	}
+	if nodeTemplate != nil && len(nodeTemplate.Spec.RegistrationTaints) > 0 {
+		// The kubelet only registers with its taints when it creates the node itself, so the node that is created
+		// ahead of it carries them as well. The taints of the provisioner are set here too, since they aren't merged
+		// into a node that already has taints.
+		node.Spec.Taints = append(append([]v1.Taint{}, template.Taints...), template.StartupTaints...)
+	}
+	return node, nil
+}
+
+// versionTags records the version of Karpenter and the generation of the node template that launched an instance, so
+// that instances can be correlated with controller upgrades and instances launched from stale templates found
+func versionTags(ctx context.Context, nodeTemplate *v1alpha1.AWSNodeTemplate) map[string]string {
+	if !awssettings.FromContext(ctx).EnableVersionTags {
+		return nil
+	}
+	tags := map[string]string{v1alpha1.TagKarpenterVersion: project.Version}
+	if nodeTemplate != nil {
+		tags[v1alpha1.TagNodeTemplateGeneration] = strconv.FormatInt(nodeTemplate.Generation, 10)
+	}
+	return tags
 }
 
 func (c *CloudProvider) LivenessProbe(req *http.Request) error {
@@ -128,15 +180,71 @@ func (c *CloudProvider) LivenessProbe(req *http.Request) error {
 
 // GetInstanceTypes returns all available InstanceTypes
 func (c *CloudProvider) GetInstanceTypes(ctx context.Context, provisioner *v1alpha5.Provisioner) ([]cloudprovider.InstanceType, error) {
-	aws, err := c.getProvider(ctx, provisioner.Spec.Provider, provisioner.Spec.ProviderRef)
+	nodeTemplate, err := c.getNodeTemplate(ctx, provisioner.Spec.ProviderRef)
+	if err != nil {
+		return nil, err
+	}
+	aws, err := c.getProvider(provisioner.Spec.Provider, nodeTemplate)
 	if err != nil {
 		return nil, err
 	}
+	requirements := scheduling.NewNodeTemplate(provisioner).Requirements
+	if _, err := allowedCapacityTypes(aws, requirements); err != nil {
+		return nil, fmt.Errorf("provisioner %s can't launch nodes, %w", provisioner.Name, err)
+	}
 	// TODO, break this coupling
-	instanceTypes, err := c.instanceTypeProvider.Get(ctx, aws, provisioner.Spec.KubeletConfiguration)
+	kc := kubeletConfiguration(provisioner.Spec.KubeletConfiguration, nodeTemplate)
+	instanceTypes, err := c.instanceTypeProvider.Get(ctx, aws, kc)
+	if err != nil {
+		return nil, err
+	}
+	if instanceTypes, err = c.amiCompatibleInstanceTypes(ctx, aws, nodeTemplate, instanceTypes); err != nil {
+		return nil, fmt.Errorf("provisioner %s can't launch nodes, %w", provisioner.Name, err)
+	}
+	// The scheduler silently skips provisioners without instance types, so surface why none of them are usable
+	reason, err := c.explainNoInstanceTypes(ctx, provisioner, aws, instanceTypes)
 	if err != nil {
 		return nil, err
 	}
+	if reason != "" {
+		exceeded, err := c.maxPriceExceeded(ctx, provisioner, aws, kc)
+		if err != nil {
+			return nil, err
+		}
+		if exceeded {
+			reason = fmt.Sprintf("every offering costs more than the maxPrice of %s per hour", *aws.MaxPrice)
+		}
+		if c.cm.HasChanged(fmt.Sprintf("no-instance-types/%s", provisioner.Name), reason) {
+			logging.FromContext(ctx).With("provisioner", provisioner.Name).Warnf("No instance types can be launched, %s", reason)
+		}
+		if exceeded {
+			c.recorder.Publish(MaxPriceExceeded(provisioner.Name, *aws.MaxPrice))
+		} else {
+			c.recorder.Publish(NoInstanceTypes(provisioner.Name, reason))
+		}
+	}
+	// Instance types that the filters don't discover, or that are missing from the static dataset, can't be launched,
+	// even when the provisioner requires them
+	static := awssettings.FromContext(ctx).InstanceTypeDataSource == awssettings.StaticInstanceTypeData
+	if len(awssettings.FromContext(ctx).InstanceTypeFilters) != 0 || static {
+		undiscovered, err := c.instanceTypeProvider.undiscoveredInstanceTypes(ctx, requirements)
+		if err != nil {
+			return nil, err
+		}
+		if len(undiscovered) != 0 && c.cm.HasChanged(fmt.Sprintf("undiscovered-instance-types/%s", provisioner.Name), undiscovered) {
+			logging.FromContext(ctx).With("provisioner", provisioner.Name).Warnf("Requirements include instance types %s, which %s", strings.Join(undiscovered, ", "),
+				lo.Ternary(static, "aren't in the static instance type dataset", "the aws.instanceTypeFilters setting excludes"))
+		}
+	}
+	// Launches fall back to on-demand, or wait for spot, without the scheduler noticing that spot is exhausted
+	if spotUnavailable(requirements, instanceTypes) {
+		fallback := awssettings.FromContext(ctx).SpotUnavailableBehavior == awssettings.FallbackToOnDemand &&
+			(!requirements.Has(v1alpha5.LabelCapacityType) || requirements.Get(v1alpha5.LabelCapacityType).Has(v1alpha5.CapacityTypeOnDemand))
+		if c.cm.HasChanged(fmt.Sprintf("spot-unavailable/%s", provisioner.Name), fallback) {
+			logging.FromContext(ctx).With("provisioner", provisioner.Name).Warnf("All spot offerings are unavailable")
+		}
+		c.recorder.Publish(SpotUnavailable(provisioner.Name, fallback))
+	}
 	return instanceTypes, nil
 }
 
@@ -181,13 +289,149 @@ func kubeDNSIP(ctx context.Context, kubernetesInterface kubernetes.Interface) (n
 	return kubeDNSIP, nil
 }
 
-func (c *CloudProvider) getProvider(ctx context.Context, provider *runtime.RawExtension, providerRef *v1alpha5.ProviderRef) (*v1alpha1.AWS, error) {
-	if providerRef != nil {
-		var ant v1alpha1.AWSNodeTemplate
-		if err := c.kubeClient.Get(ctx, types.NamespacedName{Name: providerRef.Name}, &ant); err != nil {
-			return nil, fmt.Errorf("getting providerRef, %w", err)
+func (c *CloudProvider) getNodeTemplate(ctx context.Context, providerRef *v1alpha5.ProviderRef) (*v1alpha1.AWSNodeTemplate, error) {
+	if providerRef == nil {
+		return nil, nil
+	}
+	nodeTemplate, err := nodetemplate.Get(ctx, c.kubeClient, providerRef.Name)
+	if err != nil {
+		return nil, fmt.Errorf("getting providerRef, %w", err)
+	}
+	return nodeTemplate, nil
+}
+
+// explainNoInstanceTypes returns which constraints eliminated all instance types of the provisioner, or an empty
+// string if there is an instance type with an available offering that is compatible with the provisioner
+func (c *CloudProvider) explainNoInstanceTypes(ctx context.Context, provisioner *v1alpha5.Provisioner, provider *v1alpha1.AWS, instanceTypes []cloudprovider.InstanceType) (string, error) {
+	if len(instanceTypes) == 0 {
+		reasons, err := c.instanceTypeProvider.exclusionReasons(ctx, provider)
+		if err != nil {
+			return "", err
 		}
-		return &ant.Spec.AWS, nil
+		if len(reasons) == 0 {
+			return fmt.Sprintf("no instance types are available in region %s", c.instanceTypeProvider.region), nil
+		}
+		return fmt.Sprintf("every instance type %s", strings.Join(reasons, " or ")), nil
+	}
+	requirements := scheduling.NewNodeTemplate(provisioner).Requirements
+	compatible := lo.Filter(instanceTypes, func(it cloudprovider.InstanceType, _ int) bool {
+		return it.Requirements().Intersects(requirements) == nil
+	})
+	if len(compatible) == 0 {
+		// Point out the requirements that eliminate all instance types on their own
+		keys := lo.Filter(requirements.Keys().List(), func(key string, _ int) bool {
+			return lo.NoneBy(instanceTypes, func(it cloudprovider.InstanceType) bool {
+				return it.Requirements().Intersects(scheduling.NewRequirements(requirements.Get(key))) == nil
+			})
+		})
+		if len(keys) == 0 {
+			return "no instance type is compatible with the combination of the provisioner's requirements", nil
+		}
+		reason := fmt.Sprintf("no instance type is compatible with the provisioner's requirements on %s", strings.Join(keys, ", "))
+		if lo.Contains(keys, v1alpha5.LabelCapacityType) {
+			reason += capacityTypesReason(provider)
+		}
+		return reason, nil
+	}
+	if lo.NoneBy(compatible, func(it cloudprovider.InstanceType) bool { return hasCompatibleOffering(it, requirements) }) {
+		return fmt.Sprintf("none of the %d compatible instance types have an available offering in the provisioner's zones and capacity types%s",
+			len(compatible), capacityTypesReason(provider)), nil
+	}
+	return "", nil
+}
+
+// capacityTypesReason names the capacityTypes of the node template, which drop the offerings of other capacity types,
+// if they're configured
+func capacityTypesReason(provider *v1alpha1.AWS) string {
+	if len(provider.CapacityTypes) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" with the capacityTypes %v of the node template", provider.CapacityTypes)
+}
+
+// amiCompatibleInstanceTypes returns the instance types that can be launched with any of the AMIs that the amiSelector
+// of a node template of the Custom AMI family selects, e.g. that support the architecture and virtualization type of
+// an AMI, so that the scheduler never picks an instance type that no AMI can be launched on. The default AMIs of the
+// other AMI families are resolved for each instance type, so their instance types aren't constrained.
+func (c *CloudProvider) amiCompatibleInstanceTypes(ctx context.Context, provider *v1alpha1.AWS, nodeTemplate *v1alpha1.AWSNodeTemplate,
+	instanceTypes []cloudprovider.InstanceType) ([]cloudprovider.InstanceType, error) {
+	if lo.FromPtr(provider.AMIFamily) != v1alpha1.AMIFamilyCustom || nodeTemplate == nil || len(nodeTemplate.Spec.AMISelectors()) == 0 {
+		return instanceTypes, nil
+	}
+	amiRequirements, err := c.instanceProvider.launchTemplateProvider.amiFamily.AMIRequirements(ctx, nodeTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("resolving amis, %w", err)
+	}
+	if len(amiRequirements) == 0 {
+		return instanceTypes, nil
+	}
+	compatible := lo.Filter(instanceTypes, func(instanceType cloudprovider.InstanceType, _ int) bool {
+		return lo.SomeBy(lo.Values(amiRequirements), func(requirements scheduling.Requirements) bool {
+			return instanceType.Requirements().Compatible(requirements) == nil
+		})
+	})
+	if len(compatible) == 0 && len(instanceTypes) != 0 {
+		amiIDs := lo.Keys(amiRequirements)
+		sort.Strings(amiIDs)
+		return nil, fmt.Errorf("no instance types are compatible with the architecture or virtualization type of amis %v", amiIDs)
+	}
+	return compatible, nil
+}
+
+// maxPriceExceeded returns true if the provisioner could launch instance types if it weren't for the maxPrice of the
+// node template
+func (c *CloudProvider) maxPriceExceeded(ctx context.Context, provisioner *v1alpha5.Provisioner, provider *v1alpha1.AWS, kc *v1alpha5.KubeletConfiguration) (bool, error) {
+	if provider.MaxPrice == nil {
+		return false, nil
+	}
+	unbounded := provider.DeepCopy()
+	unbounded.MaxPrice = nil
+	instanceTypes, err := c.instanceTypeProvider.Get(ctx, unbounded, kc)
+	if err != nil {
+		return false, err
+	}
+	reason, err := c.explainNoInstanceTypes(ctx, provisioner, unbounded, instanceTypes)
+	if err != nil {
+		return false, err
+	}
+	return reason == "", nil
+}
+
+func hasCompatibleOffering(instanceType cloudprovider.InstanceType, requirements scheduling.Requirements) bool {
+	return lo.ContainsBy(cloudprovider.AvailableOfferings(instanceType), func(offering cloudprovider.Offering) bool {
+		return isCompatibleOffering(offering, requirements)
+	})
+}
+
+// isCompatibleOffering returns true if the zone and capacity type of the offering are allowed by the requirements
+func isCompatibleOffering(offering cloudprovider.Offering, requirements scheduling.Requirements) bool {
+	return (!requirements.Has(v1.LabelTopologyZone) || requirements.Get(v1.LabelTopologyZone).Has(offering.Zone)) &&
+		(!requirements.Has(v1alpha5.LabelCapacityType) || requirements.Get(v1alpha5.LabelCapacityType).Has(offering.CapacityType))
+}
+
+// spotUnavailable returns true if the requirements allow spot and the compatible instance types offer spot in the
+// allowed zones, but none of those offerings are available, e.g. since insufficient capacity errors marked them all
+// as unavailable
+func spotUnavailable(requirements scheduling.Requirements, instanceTypes []cloudprovider.InstanceType) bool {
+	if requirements.Has(v1alpha5.LabelCapacityType) && !requirements.Get(v1alpha5.LabelCapacityType).Has(v1alpha5.CapacityTypeSpot) {
+		return false
+	}
+	var offerings []cloudprovider.Offering
+	for _, instanceType := range instanceTypes {
+		if instanceType.Requirements().Intersects(requirements) != nil {
+			continue
+		}
+		offerings = append(offerings, lo.Filter(instanceType.Offerings(), func(offering cloudprovider.Offering, _ int) bool {
+			return offering.CapacityType == v1alpha5.CapacityTypeSpot &&
+				(!requirements.Has(v1.LabelTopologyZone) || requirements.Get(v1.LabelTopologyZone).Has(offering.Zone))
+		})...)
+	}
+	return len(offerings) > 0 && lo.NoneBy(offerings, func(offering cloudprovider.Offering) bool { return offering.Available })
+}
+
+func (c *CloudProvider) getProvider(provider *runtime.RawExtension, nodeTemplate *v1alpha1.AWSNodeTemplate) (*v1alpha1.AWS, error) {
+	if nodeTemplate != nil {
+		return &nodeTemplate.Spec.AWS, nil
 	}
 	aws, err := v1alpha1.Deserialize(provider)
 	if err != nil {
@@ -195,3 +439,41 @@ func (c *CloudProvider) getProvider(ctx context.Context, provider *runtime.RawEx
 	}
 	return aws, nil
 }
+
+// kubeletConfiguration overlays the reserved resources, eviction thresholds, eviction grace periods and container
+// runtime of the node template onto the provisioner's kubelet configuration. Both the capacity of the instance types
+// and the kubelet configuration in the user data are derived from the result so that they never disagree.
+func kubeletConfiguration(kc *v1alpha5.KubeletConfiguration, nodeTemplate *v1alpha1.AWSNodeTemplate) *v1alpha5.KubeletConfiguration {
+	if nodeTemplate == nil || (nodeTemplate.Spec.SystemReserved == nil && nodeTemplate.Spec.KubeReserved == nil &&
+		nodeTemplate.Spec.EvictionThreshold == nil && nodeTemplate.Spec.EvictionSoft == nil &&
+		nodeTemplate.Spec.EvictionSoftGracePeriod == nil && nodeTemplate.Spec.EvictionMaxPodGracePeriod == nil &&
+		nodeTemplate.Spec.ContainerRuntime == nil) {
+		return kc
+	}
+	merged := &v1alpha5.KubeletConfiguration{}
+	if kc != nil {
+		merged = kc.DeepCopy()
+	}
+	if nodeTemplate.Spec.SystemReserved != nil {
+		merged.SystemReserved = lo.Assign(merged.SystemReserved, nodeTemplate.Spec.SystemReserved)
+	}
+	if nodeTemplate.Spec.KubeReserved != nil {
+		merged.KubeReserved = lo.Assign(merged.KubeReserved, nodeTemplate.Spec.KubeReserved)
+	}
+	if nodeTemplate.Spec.EvictionThreshold != nil {
+		merged.EvictionHard = lo.Assign(merged.EvictionHard, nodeTemplate.Spec.EvictionThreshold)
+	}
+	if nodeTemplate.Spec.EvictionSoft != nil {
+		merged.EvictionSoft = lo.Assign(merged.EvictionSoft, nodeTemplate.Spec.EvictionSoft)
+	}
+	if nodeTemplate.Spec.EvictionSoftGracePeriod != nil {
+		merged.EvictionSoftGracePeriod = lo.Assign(merged.EvictionSoftGracePeriod, nodeTemplate.Spec.EvictionSoftGracePeriod)
+	}
+	if nodeTemplate.Spec.EvictionMaxPodGracePeriod != nil {
+		merged.EvictionMaxPodGracePeriod = nodeTemplate.Spec.EvictionMaxPodGracePeriod
+	}
+	if nodeTemplate.Spec.ContainerRuntime != nil {
+		merged.ContainerRuntime = nodeTemplate.Spec.ContainerRuntime
+	}
+	return merged
+}
@@ -26,25 +26,32 @@ import (
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/mitchellh/hashstructure/v2"
+	"github.com/samber/lo"
 	"knative.dev/pkg/logging"
+
+	awssettings "github.com/aws/karpenter/pkg/apis/config/settings"
 )
 
 // CreateFleetBatcher is used to batch CreateFleet calls from the cloud provider with identical parameters into a single
-// call that launches more instances simultaneously.
+// call that launches up to aws.createFleetBatchSize instances simultaneously. Each caller still receives its own
+// instance, so that the nodes are tracked individually.
 type CreateFleetBatcher struct {
 	ctx      context.Context
 	ec2api   ec2iface.EC2API
 	mu       sync.Mutex
 	trigger  chan struct{}
 	requests map[uint64][]*createFleetRequest
+	// terminate cleans up the instances launched for requestors that stopped waiting
+	terminate func(context.Context, *string) (*ec2.TerminateInstancesOutput, error)
 }
 
-func NewCreateFleetBatcher(ctx context.Context, ec2api ec2iface.EC2API) *CreateFleetBatcher {
+func NewCreateFleetBatcher(ctx context.Context, ec2api ec2iface.EC2API, terminate func(context.Context, *string) (*ec2.TerminateInstancesOutput, error)) *CreateFleetBatcher {
 	b := &CreateFleetBatcher{
-		ctx:      ctx,
-		ec2api:   ec2api,
-		requests: map[uint64][]*createFleetRequest{},
-		trigger:  make(chan struct{}),
+		ctx:       ctx,
+		ec2api:    ec2api,
+		requests:  map[uint64][]*createFleetRequest{},
+		trigger:   make(chan struct{}),
+		terminate: terminate,
 	}
 	go b.run()
 	return b
@@ -55,6 +62,10 @@ type createFleetRequest struct {
 	hash      uint64
 	input     *ec2.CreateFleetInput
 	requestor chan createFleetResult
+
+	// mu guards abandoned, so that a result is either delivered to a requestor that is still waiting or cleaned up
+	mu        sync.Mutex
+	abandoned bool
 }
 
 type createFleetResult struct {
@@ -71,11 +82,67 @@ func (b *CreateFleetBatcher) CreateFleet(ctx context.Context, createFleetInput *
 		logging.FromContext(ctx).Errorf("error hashing")
 	}
 
-	result := <-b.createFleet(ctx, hash, createFleetInput)
-	return result.output, result.err
+	request := b.createFleet(ctx, hash, createFleetInput)
+	select {
+	case b.trigger <- struct{}{}:
+	case <-ctx.Done():
+		// the request is already registered, so a batch triggered by another requestor may have taken it
+		return b.stopWaiting(ctx, request)
+	}
+	select {
+	case result := <-request.requestor:
+		return result.output, result.err
+	case <-ctx.Done():
+		return b.stopWaiting(ctx, request)
+	}
+}
+
+// stopWaiting withdraws the request once its requestor's context is done. A request that's still pending is removed,
+// so that no instance is launched for it. A request whose batch has already been taken is abandoned instead. The batch
+// may have delivered its result while we stopped waiting, in which case the caller still gets the instance so that
+// it's tracked.
+func (b *CreateFleetBatcher) stopWaiting(ctx context.Context, request *createFleetRequest) (*ec2.CreateFleetOutput, error) {
+	if b.withdraw(request) {
+		return nil, ctx.Err()
+	}
+	if result, ok := request.abandon(); ok {
+		return result.output, result.err
+	}
+	return nil, ctx.Err()
+}
+
+// withdraw removes the request from the pending requests, and returns false if a batch already took it
+func (b *CreateFleetBatcher) withdraw(request *createFleetRequest) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	requests := b.requests[request.hash]
+	if !lo.Contains(requests, request) {
+		return false
+	}
+	requests = lo.Without(requests, request)
+	if len(requests) == 0 {
+		delete(b.requests, request.hash)
+	} else {
+		b.requests[request.hash] = requests
+	}
+	return true
+}
+
+// abandon marks the request as no longer waiting for its result, so that the batch terminates any instance that it
+// launches for the request later. It returns the result if the batch already delivered it.
+func (r *createFleetRequest) abandon() (createFleetResult, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.abandoned = true
+	select {
+	case result := <-r.requestor:
+		return result, true
+	default:
+		return createFleetResult{}, false
+	}
 }
 
-func (b *CreateFleetBatcher) createFleet(ctx context.Context, hash uint64, createFleetInput *ec2.CreateFleetInput) chan createFleetResult {
+func (b *CreateFleetBatcher) createFleet(ctx context.Context, hash uint64, createFleetInput *ec2.CreateFleetInput) *createFleetRequest {
 	request := &createFleetRequest{
 		ctx:   ctx,
 		hash:  hash,
@@ -88,8 +155,7 @@ func (b *CreateFleetBatcher) createFleet(ctx context.Context, hash uint64, creat
 	b.mu.Lock()
 	b.requests[hash] = append(b.requests[hash], request)
 	b.mu.Unlock()
-	b.trigger <- struct{}{}
-	return request.requestor
+	return request
 }
 
 func (b *CreateFleetBatcher) run() {
@@ -126,82 +192,126 @@ func (b *CreateFleetBatcher) waitForIdle() {
 
 func (b *CreateFleetBatcher) runCalls() {
 	b.mu.Lock()
-	defer b.mu.Unlock()
 	requestMap := b.requests
 	b.requests = map[uint64][]*createFleetRequest{}
+	b.mu.Unlock()
 
-	for _, requestBatch := range requestMap {
-		// we know that these create fleet calls are identical so we can just run the first one and increase the number
-		// of instances that we request
-		call := requestBatch[0]
-		// deep copy the input we are about to modify so that we don't modify any caller's input parameter
-		input, err := deepCopy(call.input)
-		if err != nil {
-			// shouldn't occur, but if it does we log an error and just modify the caller's input so we
-			// can continue to launch instances
-			logging.FromContext(context.Background()).Infof("error copying input, %s", err)
-			input = call.input
+	for _, requests := range requestMap {
+		// launching many instances in one call is limited to aws.createFleetBatchSize, so that a large scale-up is
+		// spread over several calls rather than failing or partially fulfilling a single huge request
+		for _, requestBatch := range lo.Chunk(requests, awssettings.FromContext(requests[0].ctx).CreateFleetBatchSize) {
+			b.runCall(requestBatch)
 		}
-		input.TargetCapacitySpecification.SetTotalTargetCapacity(int64(len(requestBatch)))
-		outputs, err := b.ec2api.CreateFleetWithContext(call.ctx, input)
-
-		// error occurred at the CreateFleet call level, so notify all requestors of the same error
-		if err != nil {
-			for i := range requestBatch {
-				requestBatch[i].requestor <- createFleetResult{
-					output: nil,
-					err:    err,
-				}
-			}
-			continue
+	}
+}
+
+// runCall launches the instances of a batch of identical requests with a single CreateFleet call and delivers one
+// instance to each requestor
+func (b *CreateFleetBatcher) runCall(requestBatch []*createFleetRequest) {
+	// we know that these create fleet calls are identical so we can just run the first one and increase the number
+	// of instances that we request
+	call := requestBatch[0]
+	// deep copy the input we are about to modify so that we don't modify any caller's input parameter
+	input, err := deepCopy(call.input)
+	if err != nil {
+		// shouldn't occur, but if it does we log an error and just modify the caller's input so we
+		// can continue to launch instances
+		logging.FromContext(context.Background()).Infof("error copying input, %s", err)
+		input = call.input
+	}
+	input.TargetCapacitySpecification.SetTotalTargetCapacity(int64(len(requestBatch)))
+	// the call runs under the batcher's context rather than a requestor's, so that a requestor that stops waiting
+	// doesn't cancel the launches of the others, and it's bounded by aws.createFleetTimeout so that a hung call doesn't
+	// hold up the batches after it
+	ctx := b.ctx
+	if timeout := awssettings.FromContext(call.ctx).CreateFleetTimeout.Duration; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(b.ctx, timeout)
+		defer cancel()
+	}
+	outputs, err := b.ec2api.CreateFleetWithContext(ctx, input)
+
+	// error occurred at the CreateFleet call level, so notify all requestors of the same error
+	if err != nil {
+		for i := range requestBatch {
+			b.deliver(requestBatch[i], createFleetResult{
+				output: nil,
+				err:    err,
+			})
 		}
+		return
+	}
+
+	// we can get partial fulfillment of a CreateFleet request, so we:
+	// 1) split out the single instance IDs and deliver to each requestor
+	// 2) deliver errors to any remaining requestors for which we don't have an instance
 
-		// we can get partial fulfillment of a CreateFleet request, so we:
-		// 1) split out the single instance IDs and deliver to each requestor
-		// 2) deliver errors to any remaining requestors for which we don't have an instance
-
-		requestIdx := -1
-		for _, reservation := range outputs.Instances {
-			for _, instanceID := range reservation.InstanceIds {
-				requestIdx++
-				if requestIdx >= len(requestBatch) {
-					logging.FromContext(call.ctx).Errorf("received more instances than requested, ignoring instance %s", aws.StringValue(instanceID))
-					continue
-				}
-				// split out the single result into multiple create fleet outputs
-				requestBatch[requestIdx].requestor <- createFleetResult{
-					output: &ec2.CreateFleetOutput{
-						FleetId: outputs.FleetId,
-						Errors:  outputs.Errors,
-						Instances: []*ec2.CreateFleetInstance{
-							{
-								InstanceIds:                []*string{instanceID},
-								InstanceType:               reservation.InstanceType,
-								LaunchTemplateAndOverrides: reservation.LaunchTemplateAndOverrides,
-								Lifecycle:                  reservation.Lifecycle,
-								Platform:                   reservation.Platform,
-							},
+	requestIdx := -1
+	for _, reservation := range outputs.Instances {
+		for _, instanceID := range reservation.InstanceIds {
+			requestIdx++
+			if requestIdx >= len(requestBatch) {
+				logging.FromContext(call.ctx).Errorf("received more instances than requested, ignoring instance %s", aws.StringValue(instanceID))
+				continue
+			}
+			// split out the single result into multiple create fleet outputs
+			b.deliver(requestBatch[requestIdx], createFleetResult{
+				output: &ec2.CreateFleetOutput{
+					FleetId: outputs.FleetId,
+					Errors:  outputs.Errors,
+					Instances: []*ec2.CreateFleetInstance{
+						{
+							InstanceIds:                []*string{instanceID},
+							InstanceType:               reservation.InstanceType,
+							LaunchTemplateAndOverrides: reservation.LaunchTemplateAndOverrides,
+							Lifecycle:                  reservation.Lifecycle,
+							Platform:                   reservation.Platform,
 						},
 					},
-				}
-			}
+				},
+			})
+		}
+	}
+
+	if requestIdx < len(requestBatch)-1 {
+		// we should receive some sort of error when the fleet is partially fulfilled, but just in case. A fleet that
+		// returned no instances at all is passed on as is, so that the instance provider reports it as empty.
+		if len(outputs.Errors) == 0 && requestIdx >= 0 {
+			outputs.Errors = append(outputs.Errors, &ec2.CreateFleetError{
+				ErrorCode:    aws.String("too few instances returned"),
+				ErrorMessage: aws.String("too few instances returned"),
+			})
+		}
+		for i := requestIdx + 1; i < len(requestBatch); i++ {
+			b.deliver(requestBatch[i], createFleetResult{
+				output: &ec2.CreateFleetOutput{
+					Errors: outputs.Errors,
+				},
+			})
 		}
+	}
+}
 
-		if requestIdx != len(requestBatch) {
-			// we should receive some sort of error, but just in case
-			if len(outputs.Errors) == 0 {
-				outputs.Errors = append(outputs.Errors, &ec2.CreateFleetError{
-					ErrorCode:    aws.String("too few instances returned"),
-					ErrorMessage: aws.String("too few instances returned"),
-				})
-			}
-			for i := requestIdx + 1; i < len(requestBatch); i++ {
-				requestBatch[i].requestor <- createFleetResult{
-					output: &ec2.CreateFleetOutput{
-						Errors: outputs.Errors,
-					},
-				}
-			}
+// deliver sends the result to the requestor, or terminates the instance of the result if the requestor stopped waiting,
+// since nothing would track the instance otherwise
+func (b *CreateFleetBatcher) deliver(request *createFleetRequest, result createFleetResult) {
+	request.mu.Lock()
+	defer request.mu.Unlock()
+	if !request.abandoned {
+		request.requestor <- result
+		return
+	}
+	if result.output == nil {
+		return
+	}
+	instanceIDs := lo.FlatMap(result.output.Instances, func(instance *ec2.CreateFleetInstance, _ int) []*string { return instance.InstanceIds })
+	if len(instanceIDs) == 0 {
+		return
+	}
+	for _, id := range instanceIDs {
+		logging.FromContext(b.ctx).Infof("Terminating instance %s launched for a request that stopped waiting", aws.StringValue(id))
+		if _, err := b.terminate(b.ctx, id); err != nil {
+			logging.FromContext(b.ctx).Errorf("terminating instance %s, %s", aws.StringValue(id), err)
 		}
 	}
 }
@@ -0,0 +1,82 @@
+//go:build test_performance
+
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	awssettings "github.com/aws/karpenter/pkg/apis/config/settings"
+	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+func (c *countingEC2API) CreateFleetWithContext(ctx context.Context, input *ec2.CreateFleetInput, opts ...request.Option) (*ec2.CreateFleetOutput, error) {
+	time.Sleep(c.latency)
+	atomic.AddInt64(&c.calls, 1)
+	return c.EC2API.CreateFleetWithContext(ctx, input, opts...)
+}
+
+func BenchmarkCreateFleetPerInstance(b *testing.B) {
+	benchmarkCreateFleet(b, 1)
+}
+
+func BenchmarkCreateFleetBatched(b *testing.B) {
+	benchmarkCreateFleet(b, 1000)
+}
+
+// benchmarkCreateFleet launches many identical instances concurrently, as happens when a large batch of pending pods
+// is scheduled onto new nodes at once
+func benchmarkCreateFleet(b *testing.B, batchSize int) {
+	const launches = 500
+	ctx, cancel := context.WithCancel(coretest.SettingsStore{
+		awssettings.ContextKey: test.Settings(test.SettingOptions{CreateFleetBatchSize: &batchSize}),
+	}.InjectSettings(context.Background()))
+	defer cancel()
+	ec2api := &countingEC2API{EC2API: &fake.EC2API{}, latency: time.Millisecond * 10}
+	input := &ec2.CreateFleetInput{
+		LaunchTemplateConfigs: []*ec2.FleetLaunchTemplateConfigRequest{{
+			LaunchTemplateSpecification: &ec2.FleetLaunchTemplateSpecificationRequest{LaunchTemplateName: aws.String("my-template")},
+			Overrides:                   []*ec2.FleetLaunchTemplateOverridesRequest{{AvailabilityZone: aws.String("test-zone-1a")}},
+		}},
+		TargetCapacitySpecification: &ec2.TargetCapacitySpecificationRequest{TotalTargetCapacity: aws.Int64(1)},
+	}
+	batcher := NewCreateFleetBatcher(ctx, ec2api, (&InstanceProvider{ec2api: ec2api}).terminateInstance)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var wg sync.WaitGroup
+		for j := 0; j < launches; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := batcher.CreateFleet(ctx, input); err != nil {
+					b.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+	b.ReportMetric(float64(atomic.LoadInt64(&ec2api.calls))/float64(b.N), "calls/op")
+}
@@ -15,15 +15,21 @@ limitations under the License.
 package cloudprovider
 
 import (
+	"context"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/util/sets"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+
+	awssettings "github.com/aws/karpenter/pkg/apis/config/settings"
+	"github.com/aws/karpenter/pkg/test"
 )
 
 var _ = Describe("CreateFleet Batching", func() {
@@ -31,7 +37,7 @@ var _ = Describe("CreateFleet Batching", func() {
 
 	BeforeEach(func() {
 		fakeEC2API.Reset()
-		cfb = NewCreateFleetBatcher(ctx, fakeEC2API)
+		cfb = NewCreateFleetBatcher(ctx, fakeEC2API, (&InstanceProvider{ec2api: fakeEC2API}).terminateInstance)
 	})
 
 	It("should batch the same inputs into a single call", func() {
@@ -79,6 +85,53 @@ var _ = Describe("CreateFleet Batching", func() {
 		call := fakeEC2API.CalledWithCreateFleetInput.Pop()
 		Expect(*call.TargetCapacitySpecification.TotalTargetCapacity).To(BeNumerically("==", 5))
 	})
+	It("should split identical inputs into calls of at most the batch size", func() {
+		settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{CreateFleetBatchSize: lo.ToPtr(2)})
+		ctx = settingsStore.InjectSettings(ctx)
+		input := &ec2.CreateFleetInput{
+			LaunchTemplateConfigs: []*ec2.FleetLaunchTemplateConfigRequest{
+				{
+					LaunchTemplateSpecification: &ec2.FleetLaunchTemplateSpecificationRequest{
+						LaunchTemplateName: aws.String("my-template"),
+					},
+					Overrides: []*ec2.FleetLaunchTemplateOverridesRequest{
+						{
+							AvailabilityZone: aws.String("us-east-1"),
+						},
+					},
+				},
+			},
+			TargetCapacitySpecification: &ec2.TargetCapacitySpecificationRequest{
+				TotalTargetCapacity: aws.Int64(1),
+			},
+		}
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		instanceIDs := sets.NewString()
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				rsp, err := cfb.CreateFleet(ctx, input)
+				Expect(err).To(BeNil())
+				Expect(rsp.Instances).To(HaveLen(1))
+				Expect(rsp.Instances[0].InstanceIds).To(HaveLen(1))
+				mu.Lock()
+				defer mu.Unlock()
+				instanceIDs.Insert(*rsp.Instances[0].InstanceIds[0])
+			}()
+		}
+		wg.Wait()
+
+		// every launch is tracked with its own instance
+		Expect(instanceIDs.Len()).To(Equal(5))
+		var capacities []int64
+		for fakeEC2API.CalledWithCreateFleetInput.Len() > 0 {
+			capacities = append(capacities, *fakeEC2API.CalledWithCreateFleetInput.Pop().TargetCapacitySpecification.TotalTargetCapacity)
+		}
+		Expect(capacities).To(ConsistOf(int64(2), int64(2), int64(1)))
+	})
 	It("should batch different inputs into multiple calls", func() {
 		east1input := &ec2.CreateFleetInput{
 			LaunchTemplateConfigs: []*ec2.FleetLaunchTemplateConfigRequest{
@@ -154,6 +207,149 @@ var _ = Describe("CreateFleet Batching", func() {
 		Expect(*east1Call.TargetCapacitySpecification.TotalTargetCapacity).To(BeNumerically("==", 4))
 		Expect(*east1Call.LaunchTemplateConfigs[0].Overrides[0].AvailabilityZone).To(Equal("us-east-1"))
 	})
+	It("should launch the instances of other requestors and terminate the instance of a requestor that stopped waiting", func() {
+		input := &ec2.CreateFleetInput{
+			LaunchTemplateConfigs: []*ec2.FleetLaunchTemplateConfigRequest{
+				{
+					LaunchTemplateSpecification: &ec2.FleetLaunchTemplateSpecificationRequest{
+						LaunchTemplateName: aws.String("my-template"),
+					},
+					Overrides: []*ec2.FleetLaunchTemplateOverridesRequest{
+						{
+							AvailabilityZone: aws.String("us-east-1"),
+						},
+					},
+				},
+			},
+			TargetCapacitySpecification: &ec2.TargetCapacitySpecificationRequest{
+				TotalTargetCapacity: aws.Int64(1),
+			},
+		}
+		fakeEC2API.CreateFleetDelay.Set(lo.ToPtr(200 * time.Millisecond))
+		timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer GinkgoRecover()
+			defer wg.Done()
+			_, err := cfb.CreateFleet(timeoutCtx, input)
+			Expect(err).To(MatchError(context.DeadlineExceeded))
+		}()
+		var launched string
+		go func() {
+			defer GinkgoRecover()
+			defer wg.Done()
+			rsp, err := cfb.CreateFleet(ctx, input)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rsp.Instances).To(HaveLen(1))
+			launched = aws.StringValue(rsp.Instances[0].InstanceIds[0])
+		}()
+		wg.Wait()
+
+		Expect(fakeEC2API.CalledWithCreateFleetInput.Len()).To(Equal(1))
+		Eventually(fakeEC2API.CalledWithTerminateInstancesInput.Len).Should(Equal(1))
+		terminated := aws.StringValueSlice(fakeEC2API.CalledWithTerminateInstancesInput.Pop().InstanceIds)
+		Expect(terminated).To(HaveLen(1))
+		Expect(terminated).ToNot(ContainElement(launched))
+	})
+	It("should not launch an instance for a requestor that stopped waiting before its batch started", func() {
+		input := &ec2.CreateFleetInput{
+			LaunchTemplateConfigs: []*ec2.FleetLaunchTemplateConfigRequest{
+				{
+					LaunchTemplateSpecification: &ec2.FleetLaunchTemplateSpecificationRequest{
+						LaunchTemplateName: aws.String("my-template"),
+					},
+					Overrides: []*ec2.FleetLaunchTemplateOverridesRequest{
+						{
+							AvailabilityZone: aws.String("us-east-1"),
+						},
+					},
+				},
+			},
+			TargetCapacitySpecification: &ec2.TargetCapacitySpecificationRequest{
+				TotalTargetCapacity: aws.Int64(1),
+			},
+		}
+		cancelledCtx, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err := cfb.CreateFleet(cancelledCtx, input)
+		Expect(err).To(MatchError(context.Canceled))
+
+		rsp, err := cfb.CreateFleet(ctx, input)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rsp.Instances).To(HaveLen(1))
+
+		Expect(fakeEC2API.CalledWithCreateFleetInput.Len()).To(Equal(1))
+		Expect(*fakeEC2API.CalledWithCreateFleetInput.Pop().TargetCapacitySpecification.TotalTargetCapacity).To(BeNumerically("==", 1))
+		Consistently(fakeEC2API.CalledWithTerminateInstancesInput.Len).Should(BeZero())
+	})
+	It("should lift the termination protection of the instance of a requestor that stopped waiting", func() {
+		input := &ec2.CreateFleetInput{
+			LaunchTemplateConfigs: []*ec2.FleetLaunchTemplateConfigRequest{
+				{
+					LaunchTemplateSpecification: &ec2.FleetLaunchTemplateSpecificationRequest{
+						LaunchTemplateName: aws.String("my-template"),
+					},
+					Overrides: []*ec2.FleetLaunchTemplateOverridesRequest{
+						{
+							AvailabilityZone: aws.String("us-east-1"),
+						},
+					},
+				},
+			},
+			TargetCapacitySpecification: &ec2.TargetCapacitySpecificationRequest{
+				TotalTargetCapacity: aws.Int64(1),
+			},
+		}
+		// the instances are launched with disableAPITermination
+		for _, id := range []string{"id-1", "id-2"} {
+			fakeEC2API.Instances.Store(id, &ec2.Instance{
+				InstanceId: aws.String(id),
+				State:      &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)},
+			})
+			fakeEC2API.TerminationProtectedInstances.Store(id, true)
+		}
+		fakeEC2API.CreateFleetOutput.Set(&ec2.CreateFleetOutput{
+			FleetId: aws.String("some-id"),
+			Instances: []*ec2.CreateFleetInstance{
+				{InstanceIds: []*string{aws.String("id-1"), aws.String("id-2")}},
+			},
+		})
+		fakeEC2API.CreateFleetDelay.Set(lo.ToPtr(200 * time.Millisecond))
+		timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer GinkgoRecover()
+			defer wg.Done()
+			_, err := cfb.CreateFleet(timeoutCtx, input)
+			Expect(err).To(MatchError(context.DeadlineExceeded))
+		}()
+		var launched string
+		go func() {
+			defer GinkgoRecover()
+			defer wg.Done()
+			rsp, err := cfb.CreateFleet(ctx, input)
+			Expect(err).ToNot(HaveOccurred())
+			launched = aws.StringValue(rsp.Instances[0].InstanceIds[0])
+		}()
+		wg.Wait()
+
+		Eventually(fakeEC2API.CalledWithModifyInstanceAttributeInput.Len).Should(Equal(1))
+		Expect(aws.StringValue(fakeEC2API.CalledWithModifyInstanceAttributeInput.Pop().InstanceId)).ToNot(Equal(launched))
+		Eventually(func() []string {
+			var states []string
+			fakeEC2API.Instances.Range(func(_, v any) bool {
+				states = append(states, aws.StringValue(v.(*ec2.Instance).State.Name))
+				return true
+			})
+			return states
+		}).Should(ConsistOf(ec2.InstanceStateNameRunning, ec2.InstanceStateNameShuttingDown))
+	})
 	It("should return any errors to callers", func() {
 		input := &ec2.CreateFleetInput{
 			LaunchTemplateConfigs: []*ec2.FleetLaunchTemplateConfigRequest{
@@ -342,4 +538,58 @@ var _ = Describe("CreateFleet Batching", func() {
 		Expect(receivedInstance).To(BeNumerically("==", 3))
 		Expect(numErrors).To(BeNumerically("==", 5))
 	})
+	It("should return an error to the remaining callers of a partially fulfilled fleet that reported no errors", func() {
+		input := &ec2.CreateFleetInput{
+			LaunchTemplateConfigs: []*ec2.FleetLaunchTemplateConfigRequest{
+				{
+					LaunchTemplateSpecification: &ec2.FleetLaunchTemplateSpecificationRequest{
+						LaunchTemplateName: aws.String("my-template"),
+					},
+					Overrides: []*ec2.FleetLaunchTemplateOverridesRequest{
+						{
+							AvailabilityZone: aws.String("us-east-1"),
+						},
+					},
+				},
+			},
+			TargetCapacitySpecification: &ec2.TargetCapacitySpecificationRequest{
+				TotalTargetCapacity: aws.Int64(1),
+			},
+		}
+
+		fakeEC2API.CreateFleetOutput.Set(&ec2.CreateFleetOutput{
+			FleetId: aws.String("some-id"),
+			Instances: []*ec2.CreateFleetInstance{
+				{InstanceIds: []*string{aws.String("id-1"), aws.String("id-2")}},
+			},
+		})
+		var wg sync.WaitGroup
+		var receivedInstance int64
+		var errorCodes []string
+		var mu sync.Mutex
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				rsp, err := cfb.CreateFleet(ctx, input)
+				Expect(err).To(BeNil())
+				if len(rsp.Instances) == 1 {
+					atomic.AddInt64(&receivedInstance, 1)
+					return
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				for _, fleetErr := range rsp.Errors {
+					errorCodes = append(errorCodes, aws.StringValue(fleetErr.ErrorCode))
+				}
+			}()
+		}
+		wg.Wait()
+
+		Expect(fakeEC2API.CalledWithCreateFleetInput.Len()).To(Equal(1))
+		Expect(receivedInstance).To(BeNumerically("==", 2))
+		// the caller without an instance gets an error rather than an empty fleet
+		Expect(errorCodes).To(ConsistOf("too few instances returned"))
+	})
 })
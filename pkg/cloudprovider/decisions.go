@@ -0,0 +1,141 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/samber/lo"
+	"golang.org/x/time/rate"
+	"knative.dev/pkg/logging"
+
+	"github.com/aws/karpenter-core/pkg/cloudprovider"
+	"github.com/aws/karpenter-core/pkg/operator/injection"
+	"github.com/aws/karpenter-core/pkg/scheduling"
+	awssettings "github.com/aws/karpenter/pkg/apis/config/settings"
+)
+
+// ProvisioningDecision is a structured record of a launch, for auditing and capacity analysis. It holds the
+// requirements that the instance types were resolved for, the instance types that the fleet was asked to pick from,
+// and the offering that it picked.
+type ProvisioningDecision struct {
+	Time        time.Time `json:"time"`
+	Provisioner string    `json:"provisioner"`
+	// Requirements are the scheduling requirements of the pods, e.g. "kubernetes.io/arch In [amd64]", sorted by key
+	Requirements  []string `json:"requirements"`
+	InstanceTypes []string `json:"instanceTypes"`
+	InstanceID    string   `json:"instanceID"`
+	InstanceType  string   `json:"instanceType"`
+	CapacityType  string   `json:"capacityType"`
+	Zone          string   `json:"zone"`
+	Subnet        string   `json:"subnet"`
+	// Price is the price of the offering that was launched, if it's known
+	Price *float64 `json:"price,omitempty"`
+}
+
+// DecisionRecorder writes provisioning decisions to the sink that's configured in the settings. Decisions are
+// dropped beyond the configured rate, so that the records stay bounded in volume while nodes are launched in bulk.
+type DecisionRecorder struct {
+	mu            sync.Mutex
+	limiter       *rate.Limiter
+	perMinute     int
+	unwritableLog sync.Once
+}
+
+func NewDecisionRecorder() *DecisionRecorder {
+	return &DecisionRecorder{}
+}
+
+// Record writes the decision that launched the instance for the node request. Failing to record a decision isn't
+// fatal, since the instance has already been launched.
+func (r *DecisionRecorder) Record(ctx context.Context, nodeRequest *cloudprovider.NodeRequest, instance *ec2.Instance) {
+	settings := awssettings.FromContext(ctx)
+	if settings.ProvisioningDecisionSink == "" || !r.allow(settings.ProvisioningDecisionsPerMinute) {
+		return
+	}
+	raw, err := json.Marshal(newProvisioningDecision(ctx, nodeRequest, instance))
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Marshaling provisioning decision, %s", err)
+		return
+	}
+	if settings.ProvisioningDecisionSink == awssettings.ProvisioningDecisionSinkLog {
+		logging.FromContext(ctx).Named("decisions").With("decision", json.RawMessage(raw)).Infof("Recorded provisioning decision")
+		return
+	}
+	if err := r.append(settings.ProvisioningDecisionSink, raw); err != nil {
+		// The destination is likely misconfigured, so only the first failure is logged to avoid logging on each launch
+		r.unwritableLog.Do(func() { logging.FromContext(ctx).Errorf("Recording provisioning decisions, %s", err) })
+	}
+}
+
+// allow returns true if another decision may be recorded within the rate. The limiter is replaced when the rate in
+// the settings changes.
+func (r *DecisionRecorder) allow(perMinute int) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.limiter == nil || r.perMinute != perMinute {
+		r.limiter = rate.NewLimiter(rate.Every(time.Minute/time.Duration(perMinute)), perMinute)
+		r.perMinute = perMinute
+	}
+	return r.limiter.Allow()
+}
+
+// append writes the decision as a line of JSON to the end of the file, creating it if it doesn't exist
+func (r *DecisionRecorder) append(path string, raw []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("opening %s, %w", path, err)
+	}
+	if _, err = file.Write(append(raw, '\n')); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("writing to %s, %w", path, err)
+	}
+	return file.Close()
+}
+
+func newProvisioningDecision(ctx context.Context, nodeRequest *cloudprovider.NodeRequest, instance *ec2.Instance) ProvisioningDecision {
+	capacityType := getCapacityType(instance)
+	zone := aws.StringValue(instance.Placement.AvailabilityZone)
+	decision := ProvisioningDecision{
+		Time:          time.Now().UTC(),
+		Provisioner:   injection.GetNamespacedName(ctx).Name,
+		Requirements:  lo.Map(nodeRequest.Template.Requirements.Values(), func(requirement *scheduling.Requirement, _ int) string { return requirement.String() }),
+		InstanceTypes: lo.Map(nodeRequest.InstanceTypeOptions, func(instanceType cloudprovider.InstanceType, _ int) string { return instanceType.Name() }),
+		InstanceID:    aws.StringValue(instance.InstanceId),
+		InstanceType:  aws.StringValue(instance.InstanceType),
+		CapacityType:  capacityType,
+		Zone:          zone,
+		Subnet:        aws.StringValue(instance.SubnetId),
+	}
+	sort.Strings(decision.Requirements)
+	if instanceType, ok := lo.Find(nodeRequest.InstanceTypeOptions, func(instanceType cloudprovider.InstanceType) bool {
+		return instanceType.Name() == decision.InstanceType
+	}); ok {
+		if offering, ok := cloudprovider.GetOffering(instanceType, capacityType, zone); ok {
+			decision.Price = lo.ToPtr(offering.Price)
+		}
+	}
+	return decision
+}
@@ -0,0 +1,152 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/cloudprovider"
+	"github.com/aws/karpenter-core/pkg/controllers/provisioning"
+	"github.com/aws/karpenter-core/pkg/scheduling"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+	awssettings "github.com/aws/karpenter/pkg/apis/config/settings"
+	"github.com/aws/karpenter/pkg/test"
+	"github.com/aws/karpenter/pkg/utils"
+)
+
+var _ = Describe("Provisioning Decisions", func() {
+	var sink string
+	BeforeEach(func() {
+		dir, err := os.MkdirTemp("", "decisions")
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(func() { Expect(os.RemoveAll(dir)).To(Succeed()) })
+		sink = filepath.Join(dir, "decisions.jsonl")
+	})
+	readDecisions := func() []string {
+		raw, err := os.ReadFile(sink)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		Expect(err).ToNot(HaveOccurred())
+		return strings.Split(strings.TrimSuffix(string(raw), "\n"), "\n")
+	}
+	It("should record the decision that launched a node", func() {
+		settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{
+			ProvisioningDecisionSink: lo.ToPtr(sink),
+		})
+		ctx = settingsStore.InjectSettings(ctx)
+		prov = provisioning.NewProvisioner(ctx, env.Client, corev1.NewForConfigOrDie(env.Config), recorder, cloudProvider, cluster, coretest.SettingsStore{})
+		provisioningController := provisioning.NewController(env.Client, prov, recorder)
+		ExpectApplied(ctx, env.Client, provisioner)
+		pod := ExpectProvisioned(ctx, env.Client, recorder, provisioningController, prov, coretest.UnschedulablePod())[0]
+		node := ExpectScheduled(ctx, env.Client, pod)
+
+		decisions := readDecisions()
+		Expect(decisions).To(HaveLen(1))
+		fields := map[string]interface{}{}
+		Expect(json.Unmarshal([]byte(decisions[0]), &fields)).To(Succeed())
+		Expect(lo.Keys(fields)).To(ConsistOf("time", "provisioner", "requirements", "instanceTypes", "instanceID",
+			"instanceType", "capacityType", "zone", "subnet", "price"))
+
+		decision := ProvisioningDecision{}
+		Expect(json.Unmarshal([]byte(decisions[0]), &decision)).To(Succeed())
+		instanceID, err := utils.ParseInstanceID(node)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(decision.InstanceID).To(Equal(aws.StringValue(instanceID)))
+		Expect(decision.InstanceType).To(Equal(node.Labels[v1.LabelInstanceTypeStable]))
+		Expect(decision.InstanceTypes).To(ContainElement(decision.InstanceType))
+		Expect(decision.CapacityType).To(Equal(v1alpha5.CapacityTypeOnDemand))
+		Expect(decision.Zone).To(Equal(node.Labels[v1.LabelTopologyZone]))
+		Expect(decision.Subnet).To(HavePrefix("subnet-"))
+		Expect(decision.Requirements).To(ContainElement(HavePrefix(v1alpha5.LabelCapacityType)))
+		Expect(decision.Time).ToNot(BeZero())
+
+		instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+		Expect(err).ToNot(HaveOccurred())
+		instanceType, ok := lo.Find(instanceTypes, func(it cloudprovider.InstanceType) bool { return it.Name() == decision.InstanceType })
+		Expect(ok).To(BeTrue())
+		offering, ok := cloudprovider.GetOffering(instanceType, decision.CapacityType, decision.Zone)
+		Expect(ok).To(BeTrue())
+		Expect(decision.Price).To(Equal(lo.ToPtr(offering.Price)))
+	})
+	Context("Recorder", func() {
+		var nodeRequest *cloudprovider.NodeRequest
+		var instance *ec2.Instance
+		BeforeEach(func() {
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+			Expect(err).ToNot(HaveOccurred())
+			nodeRequest = &cloudprovider.NodeRequest{
+				Template: &scheduling.NodeTemplate{
+					Requirements: scheduling.NewRequirements(scheduling.NewRequirement(v1.LabelArchStable, v1.NodeSelectorOpIn, v1alpha5.ArchitectureAmd64)),
+				},
+				InstanceTypeOptions: instanceTypes,
+			}
+			instance = &ec2.Instance{
+				InstanceId:   aws.String("i-0123456789abcdef0"),
+				InstanceType: aws.String("m5.large"),
+				Placement:    &ec2.Placement{AvailabilityZone: aws.String("test-zone-1a")},
+				SubnetId:     aws.String("subnet-test1"),
+			}
+		})
+		It("should not record decisions without a sink", func() {
+			NewDecisionRecorder().Record(ctx, nodeRequest, instance)
+			Expect(readDecisions()).To(BeEmpty())
+		})
+		It("should drop decisions beyond the configured rate", func() {
+			settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{
+				ProvisioningDecisionSink:       lo.ToPtr(sink),
+				ProvisioningDecisionsPerMinute: lo.ToPtr(2),
+			})
+			ctx = settingsStore.InjectSettings(ctx)
+			decisionRecorder := NewDecisionRecorder()
+			for i := 0; i < 5; i++ {
+				decisionRecorder.Record(ctx, nodeRequest, instance)
+			}
+			Expect(readDecisions()).To(HaveLen(2))
+		})
+		It("should record the requirements sorted by key", func() {
+			settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{
+				ProvisioningDecisionSink: lo.ToPtr(sink),
+			})
+			ctx = settingsStore.InjectSettings(ctx)
+			nodeRequest.Template.Requirements.Add(scheduling.NewRequirement(v1alpha5.LabelCapacityType, v1.NodeSelectorOpIn, v1alpha5.CapacityTypeOnDemand))
+			NewDecisionRecorder().Record(ctx, nodeRequest, instance)
+
+			decisions := readDecisions()
+			Expect(decisions).To(HaveLen(1))
+			decision := ProvisioningDecision{}
+			Expect(json.Unmarshal([]byte(decisions[0]), &decision)).To(Succeed())
+			Expect(decision.Requirements).To(Equal([]string{
+				"karpenter.sh/capacity-type In [on-demand]",
+				"kubernetes.io/arch In [amd64]",
+			}))
+			Expect(decision.InstanceID).To(Equal("i-0123456789abcdef0"))
+			Expect(decision.Subnet).To(Equal("subnet-test1"))
+		})
+	})
+})
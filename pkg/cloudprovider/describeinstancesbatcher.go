@@ -0,0 +1,225 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/samber/lo"
+	"knative.dev/pkg/logging"
+
+	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
+
+	awssettings "github.com/aws/karpenter/pkg/apis/config/settings"
+	awserrors "github.com/aws/karpenter/pkg/errors"
+)
+
+// DescribeInstancesBatcher is used to batch the DescribeInstances calls for single instances, e.g. when confirming
+// launches and terminations, into calls that describe up to aws.describeInstancesBatchSize instances at once. The calls
+// are filtered to instances tagged by Karpenter, and instances that the filter drops are described again without it,
+// so that only instances that don't exist are reported as not found.
+type DescribeInstancesBatcher struct {
+	ctx      context.Context
+	ec2api   ec2iface.EC2API
+	mu       sync.Mutex
+	trigger  chan struct{}
+	requests []*describeInstancesRequest
+}
+
+func NewDescribeInstancesBatcher(ctx context.Context, ec2api ec2iface.EC2API) *DescribeInstancesBatcher {
+	b := &DescribeInstancesBatcher{
+		ctx:     ctx,
+		ec2api:  ec2api,
+		trigger: make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+type describeInstancesRequest struct {
+	ctx       context.Context
+	id        string
+	requestor chan describeInstancesResult
+}
+
+type describeInstancesResult struct {
+	instance *ec2.Instance
+	err      error
+}
+
+// DescribeInstance returns the instance with the ID, or an InvalidInstanceID.NotFound error if it doesn't exist
+func (b *DescribeInstancesBatcher) DescribeInstance(ctx context.Context, id string) (*ec2.Instance, error) {
+	request := &describeInstancesRequest{
+		ctx: ctx,
+		id:  id,
+		// buffered so that the batch never blocks on a requestor that stopped waiting
+		requestor: make(chan describeInstancesResult, 1),
+	}
+	b.mu.Lock()
+	b.requests = append(b.requests, request)
+	b.mu.Unlock()
+	select {
+	case b.trigger <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	select {
+	case result := <-request.requestor:
+		return result.instance, result.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (b *DescribeInstancesBatcher) run() {
+	for {
+		select {
+		// context that we started with has completed so the app is shutting down
+		case <-b.ctx.Done():
+			return
+		case <-b.trigger:
+			// wait to start the batch of describe instances calls
+		}
+		b.waitForIdle()
+		b.runCalls()
+	}
+}
+
+func (b *DescribeInstancesBatcher) waitForIdle() {
+	timeout := time.NewTimer(100 * time.Millisecond)
+	idle := time.NewTimer(10 * time.Millisecond)
+	for {
+		select {
+		case <-b.trigger:
+			if !idle.Stop() {
+				<-idle.C
+			}
+			idle.Reset(10 * time.Millisecond)
+		case <-timeout.C:
+			return
+		case <-idle.C:
+			return
+		}
+	}
+}
+
+func (b *DescribeInstancesBatcher) runCalls() {
+	b.mu.Lock()
+	requests := b.requests
+	b.requests = nil
+	b.mu.Unlock()
+	if len(requests) == 0 {
+		return
+	}
+
+	// the same instance may be requested more than once, e.g. by retries, but only needs to be described once
+	requestsByID := lo.GroupBy(requests, func(r *describeInstancesRequest) string { return r.id })
+	ids := lo.Keys(requestsByID)
+	// the calls run under the batcher's context so that a requestor that stops waiting doesn't fail the batch of the
+	// others, while the batch size comes from the latest settings that the requestors see
+	for _, batch := range lo.Chunk(ids, awssettings.FromContext(requests[0].ctx).DescribeInstancesBatchSize) {
+		instances, err := b.describeInstances(b.ctx, batch)
+		for _, id := range batch {
+			result := describeInstancesResult{err: err}
+			if err == nil {
+				result.instance, result.err = instances[id], nil
+				if result.instance == nil {
+					result.err = instanceNotFoundError(id)
+				}
+			}
+			for _, request := range requestsByID[id] {
+				request.requestor <- result
+			}
+		}
+	}
+}
+
+// describeInstances describes a batch of instances by ID. Instances that exist but aren't tagged by Karpenter, e.g.
+// because they were launched before it tagged them, are dropped by the tag filter, so they're described again without
+// the filter rather than reported as not found.
+func (b *DescribeInstancesBatcher) describeInstances(ctx context.Context, ids []string) (map[string]*ec2.Instance, error) {
+	instances, err := b.describeInstancesFiltered(ctx, ids, true)
+	if err != nil {
+		return nil, err
+	}
+	untagged := lo.Filter(ids, func(id string, _ int) bool {
+		_, ok := instances[id]
+		return !ok
+	})
+	if len(untagged) == 0 {
+		return instances, nil
+	}
+	others, err := b.describeInstancesFiltered(ctx, untagged, false)
+	if err != nil {
+		return nil, err
+	}
+	return lo.Assign(instances, others), nil
+}
+
+// describeInstancesFiltered describes a batch of instances by ID, optionally filtered to instances tagged by
+// Karpenter. DescribeInstances fails entirely if any of the IDs doesn't exist, in which case the instances are
+// described one at a time so that the others are still returned.
+func (b *DescribeInstancesBatcher) describeInstancesFiltered(ctx context.Context, ids []string, tagged bool) (map[string]*ec2.Instance, error) {
+	instances, err := b.describeInstancesPages(ctx, ids, tagged)
+	if err == nil || !awserrors.IsNotFound(err) || len(ids) == 1 {
+		return instances, lo.Ternary(awserrors.IsNotFound(err), nil, err)
+	}
+	logging.FromContext(ctx).Debugf("Describing %d instances one at a time, %s", len(ids), err)
+	instances = map[string]*ec2.Instance{}
+	for _, id := range ids {
+		instance, err := b.describeInstancesPages(ctx, []string{id}, tagged)
+		if err != nil && !awserrors.IsNotFound(err) {
+			return nil, err
+		}
+		for k, v := range instance {
+			instances[k] = v
+		}
+	}
+	return instances, nil
+}
+
+func (b *DescribeInstancesBatcher) describeInstancesPages(ctx context.Context, ids []string, tagged bool) (map[string]*ec2.Instance, error) {
+	instances := map[string]*ec2.Instance{}
+	input := &ec2.DescribeInstancesInput{InstanceIds: aws.StringSlice(ids)}
+	if tagged {
+		input.Filters = []*ec2.Filter{{
+			Name:   aws.String("tag-key"),
+			Values: aws.StringSlice([]string{v1alpha5.ProvisionerNameLabelKey}),
+		}}
+	}
+	if err := b.ec2api.DescribeInstancesPagesWithContext(ctx, input, func(output *ec2.DescribeInstancesOutput, _ bool) bool {
+		for _, reservation := range output.Reservations {
+			for _, instance := range reservation.Instances {
+				instances[aws.StringValue(instance.InstanceId)] = instance
+			}
+		}
+		return true
+	}); err != nil {
+		return nil, fmt.Errorf("describing ec2 instances, %w", err)
+	}
+	return instances, nil
+}
+
+// instanceNotFoundError mirrors the error that EC2 returns for an instance ID that doesn't exist
+func instanceNotFoundError(id string) error {
+	return awserr.New("InvalidInstanceID.NotFound", fmt.Sprintf("The instance ID '%s' does not exist", id), nil)
+}
@@ -0,0 +1,93 @@
+//go:build test_performance
+
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	awssettings "github.com/aws/karpenter/pkg/apis/config/settings"
+	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+// countingEC2API adds latency to DescribeInstances and CreateFleet calls to simulate a round trip to EC2 and counts the
+// calls
+type countingEC2API struct {
+	*fake.EC2API
+	latency time.Duration
+	calls   int64
+}
+
+func (c *countingEC2API) DescribeInstancesPagesWithContext(ctx context.Context, input *ec2.DescribeInstancesInput, fn func(*ec2.DescribeInstancesOutput, bool) bool, opts ...request.Option) error {
+	time.Sleep(c.latency)
+	atomic.AddInt64(&c.calls, 1)
+	return c.EC2API.DescribeInstancesPagesWithContext(ctx, input, fn, opts...)
+}
+
+func BenchmarkDescribeInstancesPerInstance(b *testing.B) {
+	benchmarkDescribeInstances(b, 1)
+}
+
+func BenchmarkDescribeInstancesBatched(b *testing.B) {
+	benchmarkDescribeInstances(b, 1000)
+}
+
+// benchmarkDescribeInstances looks up a large fleet of instances concurrently, as happens when many nodes are
+// launched or terminated at once
+func benchmarkDescribeInstances(b *testing.B, batchSize int) {
+	const fleetSize = 2000
+	ctx, cancel := context.WithCancel(coretest.SettingsStore{
+		awssettings.ContextKey: test.Settings(test.SettingOptions{DescribeInstancesBatchSize: &batchSize}),
+	}.InjectSettings(context.Background()))
+	defer cancel()
+	ec2api := &countingEC2API{EC2API: &fake.EC2API{}, latency: time.Millisecond * 10}
+	ids := make([]string, fleetSize)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("i-%d", i)
+		ec2api.Instances.Store(ids[i], &ec2.Instance{
+			InstanceId: aws.String(ids[i]),
+			Tags:       []*ec2.Tag{{Key: aws.String(v1alpha5.ProvisionerNameLabelKey), Value: aws.String("default")}},
+		})
+	}
+	batcher := NewDescribeInstancesBatcher(ctx, ec2api)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var wg sync.WaitGroup
+		for _, id := range ids {
+			wg.Add(1)
+			go func(id string) {
+				defer wg.Done()
+				if _, err := batcher.DescribeInstance(ctx, id); err != nil {
+					b.Error(err)
+				}
+			}(id)
+		}
+		wg.Wait()
+	}
+	b.ReportMetric(float64(atomic.LoadInt64(&ec2api.calls))/float64(b.N), "calls/op")
+}
@@ -0,0 +1,159 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/samber/lo"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	awssettings "github.com/aws/karpenter/pkg/apis/config/settings"
+	awserrors "github.com/aws/karpenter/pkg/errors"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var _ = Describe("DescribeInstances Batching", func() {
+	var dib *DescribeInstancesBatcher
+
+	storeInstance := func(tags ...*ec2.Tag) string {
+		id := fmt.Sprintf("i-%s", coretest.RandomName())
+		fakeEC2API.Instances.Store(id, &ec2.Instance{
+			InstanceId: aws.String(id),
+			State:      &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)},
+			Tags:       tags,
+		})
+		return id
+	}
+	// describeConcurrently describes the instances at the same time, returning the results in the order of the IDs
+	describeConcurrently := func(ids ...string) ([]*ec2.Instance, []error) {
+		instances := make([]*ec2.Instance, len(ids))
+		errs := make([]error, len(ids))
+		var wg sync.WaitGroup
+		for i, id := range ids {
+			wg.Add(1)
+			go func(i int, id string) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				instances[i], errs[i] = dib.DescribeInstance(ctx, id)
+			}(i, id)
+		}
+		wg.Wait()
+		return instances, errs
+	}
+	karpenterTag := &ec2.Tag{Key: aws.String(v1alpha5.ProvisionerNameLabelKey), Value: aws.String("default")}
+
+	BeforeEach(func() {
+		fakeEC2API.Reset()
+		dib = NewDescribeInstancesBatcher(ctx, fakeEC2API)
+	})
+
+	It("should batch concurrent lookups into a single call", func() {
+		ids := lo.Times(5, func(int) string { return storeInstance(karpenterTag) })
+		instances, errs := describeConcurrently(ids...)
+		for i := range ids {
+			Expect(errs[i]).ToNot(HaveOccurred())
+			Expect(aws.StringValue(instances[i].InstanceId)).To(Equal(ids[i]))
+		}
+		Expect(fakeEC2API.CalledWithDescribeInstancesInput.Len()).To(Equal(1))
+		input := fakeEC2API.CalledWithDescribeInstancesInput.Pop()
+		Expect(aws.StringValueSlice(input.InstanceIds)).To(ConsistOf(ids))
+		Expect(input.Filters).To(ConsistOf(&ec2.Filter{
+			Name:   aws.String("tag-key"),
+			Values: aws.StringSlice([]string{v1alpha5.ProvisionerNameLabelKey}),
+		}))
+	})
+	It("should describe an instance that is requested more than once only once", func() {
+		id := storeInstance(karpenterTag)
+		instances, errs := describeConcurrently(id, id, id)
+		for i := range instances {
+			Expect(errs[i]).ToNot(HaveOccurred())
+			Expect(aws.StringValue(instances[i].InstanceId)).To(Equal(id))
+		}
+		Expect(fakeEC2API.CalledWithDescribeInstancesInput.Len()).To(Equal(1))
+		Expect(fakeEC2API.CalledWithDescribeInstancesInput.Pop().InstanceIds).To(HaveLen(1))
+	})
+	It("should split batches by the configured batch size", func() {
+		settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{DescribeInstancesBatchSize: lo.ToPtr(2)})
+		ctx = settingsStore.InjectSettings(ctx)
+		ids := lo.Times(5, func(int) string { return storeInstance(karpenterTag) })
+		_, errs := describeConcurrently(ids...)
+		for _, err := range errs {
+			Expect(err).ToNot(HaveOccurred())
+		}
+		Expect(fakeEC2API.CalledWithDescribeInstancesInput.Len()).To(Equal(3))
+		var described []string
+		for fakeEC2API.CalledWithDescribeInstancesInput.Len() > 0 {
+			input := fakeEC2API.CalledWithDescribeInstancesInput.Pop()
+			Expect(len(input.InstanceIds)).To(BeNumerically("<=", 2))
+			described = append(described, aws.StringValueSlice(input.InstanceIds)...)
+		}
+		Expect(described).To(ConsistOf(ids))
+	})
+	It("should return the instances that exist when others in the batch don't", func() {
+		ids := []string{storeInstance(karpenterTag), "i-missing", storeInstance(karpenterTag)}
+		instances, errs := describeConcurrently(ids...)
+		Expect(errs[0]).ToNot(HaveOccurred())
+		Expect(aws.StringValue(instances[0].InstanceId)).To(Equal(ids[0]))
+		Expect(awserrors.IsNotFound(errs[1])).To(BeTrue())
+		Expect(errs[2]).ToNot(HaveOccurred())
+		Expect(aws.StringValue(instances[2].InstanceId)).To(Equal(ids[2]))
+	})
+	It("should describe instances that aren't tagged by Karpenter without the tag filter", func() {
+		ids := []string{storeInstance(karpenterTag), storeInstance(&ec2.Tag{Key: aws.String("team"), Value: aws.String("a")})}
+		instances, errs := describeConcurrently(ids...)
+		for i := range ids {
+			Expect(errs[i]).ToNot(HaveOccurred())
+			Expect(aws.StringValue(instances[i].InstanceId)).To(Equal(ids[i]))
+		}
+		Expect(fakeEC2API.CalledWithDescribeInstancesInput.Len()).To(Equal(2))
+		input := fakeEC2API.CalledWithDescribeInstancesInput.Pop()
+		Expect(aws.StringValueSlice(input.InstanceIds)).To(ConsistOf(ids[1]))
+		Expect(input.Filters).To(BeEmpty())
+	})
+	It("should report instances that don't exist as not found", func() {
+		_, errs := describeConcurrently("i-missing")
+		Expect(awserrors.IsNotFound(errs[0])).To(BeTrue())
+	})
+	It("should describe the instances of other callers when a caller stops waiting", func() {
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err := dib.DescribeInstance(cancelled, storeInstance(karpenterTag))
+		Expect(err).To(MatchError(context.Canceled))
+		ids := lo.Times(3, func(int) string { return storeInstance(karpenterTag) })
+		instances, errs := describeConcurrently(ids...)
+		for i := range ids {
+			Expect(errs[i]).ToNot(HaveOccurred())
+			Expect(aws.StringValue(instances[i].InstanceId)).To(Equal(ids[i]))
+		}
+	})
+	It("should return any errors to callers", func() {
+		ids := lo.Times(3, func(int) string { return storeInstance(karpenterTag) })
+		fakeEC2API.NextError.Set(awserr.New("RequestLimitExceeded", "request limit exceeded", nil))
+		_, errs := describeConcurrently(ids...)
+		for _, err := range errs {
+			Expect(err).To(MatchError(ContainSubstring("request limit exceeded")))
+		}
+	})
+})
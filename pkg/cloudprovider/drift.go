@@ -0,0 +1,80 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	k8sClient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/cloudprovider"
+	"github.com/aws/karpenter-core/pkg/scheduling"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+)
+
+// IsDrifted returns true if the node was launched with an AMI other than the one that its node template currently
+// resolves to for its instance type, e.g. since a new AMI was published to the SSM parameter of its AMI family. Nodes
+// that don't record the AMI they were launched with, or that were launched from a launch template that's specified
+// directly, never drift. Neither do nodes while their node template falls back to its fallbackAMIIDs.
+func (c *CloudProvider) IsDrifted(ctx context.Context, node *v1.Node) (bool, error) {
+	launchAMI, ok := node.Annotations[v1alpha1.AnnotationAMIID]
+	if !ok {
+		return false, nil
+	}
+	provisioner := &v1alpha5.Provisioner{}
+	if err := c.kubeClient.Get(ctx, types.NamespacedName{Name: node.Labels[v1alpha5.ProvisionerNameLabelKey]}, provisioner); err != nil {
+		return false, k8sClient.IgnoreNotFound(err)
+	}
+	nodeTemplate, err := c.getNodeTemplate(ctx, provisioner.Spec.ProviderRef)
+	if err != nil {
+		return false, err
+	}
+	aws, err := c.getProvider(provisioner.Spec.Provider, nodeTemplate)
+	if err != nil {
+		return false, err
+	}
+	if aws.LaunchTemplateName != nil {
+		return false, nil
+	}
+	instanceTypes, err := c.instanceTypeProvider.Get(ctx, aws, kubeletConfiguration(provisioner.Spec.KubeletConfiguration, nodeTemplate))
+	if err != nil {
+		return false, err
+	}
+	instanceType, ok := lo.Find(instanceTypes, func(instanceType cloudprovider.InstanceType) bool {
+		return instanceType.Name() == node.Labels[v1.LabelInstanceTypeStable]
+	})
+	if !ok {
+		return false, nil
+	}
+	amis, err := c.instanceProvider.launchTemplateProvider.ResolveAMIs(ctx, aws, &cloudprovider.NodeRequest{
+		Template:            scheduling.NewNodeTemplate(provisioner),
+		InstanceTypeOptions: []cloudprovider.InstanceType{instanceType},
+	})
+	if err != nil {
+		return false, fmt.Errorf("resolving amis, %w", err)
+	}
+	if _, ok := amis[launchAMI]; ok || len(amis) == 0 {
+		return false, nil
+	}
+	if nodeTemplate != nil && lo.Some(lo.Keys(amis), nodeTemplate.Spec.FallbackAMIIDs) {
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,87 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var _ = Describe("Drift", func() {
+	var node *v1.Node
+
+	BeforeEach(func() {
+		fakeSSMAPI.GetParameterOutput = &ssm.GetParameterOutput{Parameter: &ssm.Parameter{Value: aws.String("ami-old")}}
+		node = coretest.Node(coretest.NodeOptions{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{
+					v1alpha5.ProvisionerNameLabelKey: provisioner.Name,
+					v1.LabelInstanceTypeStable:       "m5.large",
+				},
+				Annotations: map[string]string{v1alpha1.AnnotationAMIID: "ami-old"},
+			},
+		})
+	})
+	It("should not detect drift when the node runs the resolved AMI", func() {
+		ExpectApplied(ctx, env.Client, provisioner)
+		Expect(cloudProvider.IsDrifted(ctx, node)).To(BeFalse())
+	})
+	It("should detect drift when the SSM parameter resolves to a new AMI", func() {
+		ExpectApplied(ctx, env.Client, provisioner)
+		Expect(cloudProvider.IsDrifted(ctx, node)).To(BeFalse())
+
+		fakeSSMAPI.GetParameterOutput = &ssm.GetParameterOutput{Parameter: &ssm.Parameter{Value: aws.String("ami-new")}}
+		ssmCache.Flush()
+		Expect(cloudProvider.IsDrifted(ctx, node)).To(BeTrue())
+	})
+	It("should detect drift for the provider of a node template", func() {
+		nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{AWS: *provider})
+		provisioner = test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}})
+		node.Labels[v1alpha5.ProvisionerNameLabelKey] = provisioner.Name
+		ExpectApplied(ctx, env.Client, nodeTemplate, provisioner)
+
+		fakeSSMAPI.GetParameterOutput = &ssm.GetParameterOutput{Parameter: &ssm.Parameter{Value: aws.String("ami-new")}}
+		Expect(cloudProvider.IsDrifted(ctx, node)).To(BeTrue())
+	})
+	It("should not detect drift for nodes that don't record their AMI", func() {
+		ExpectApplied(ctx, env.Client, provisioner)
+		delete(node.Annotations, v1alpha1.AnnotationAMIID)
+		fakeSSMAPI.GetParameterOutput = &ssm.GetParameterOutput{Parameter: &ssm.Parameter{Value: aws.String("ami-new")}}
+		Expect(cloudProvider.IsDrifted(ctx, node)).To(BeFalse())
+	})
+	It("should not detect drift when the launch template is specified directly", func() {
+		provider.LaunchTemplateName = aws.String("test-launch-template")
+		provider.SecurityGroupSelector = nil
+		provisioner = test.Provisioner(coretest.ProvisionerOptions{Provider: provider})
+		node.Labels[v1alpha5.ProvisionerNameLabelKey] = provisioner.Name
+		ExpectApplied(ctx, env.Client, provisioner)
+		fakeSSMAPI.GetParameterOutput = &ssm.GetParameterOutput{Parameter: &ssm.Parameter{Value: aws.String("ami-new")}}
+		Expect(cloudProvider.IsDrifted(ctx, node)).To(BeFalse())
+	})
+	It("should not detect drift for nodes of deleted provisioners", func() {
+		fakeSSMAPI.GetParameterOutput = &ssm.GetParameterOutput{Parameter: &ssm.Parameter{Value: aws.String("ami-new")}}
+		Expect(cloudProvider.IsDrifted(ctx, node)).To(BeFalse())
+	})
+})
@@ -0,0 +1,89 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/events"
+)
+
+func InsufficientVCPUQuota(provisionerName string, capacityType string, instanceTypes []string) events.Event {
+	return events.Event{
+		InvolvedObject: &v1alpha5.Provisioner{ObjectMeta: metav1.ObjectMeta{Name: provisionerName}},
+		Type:           v1.EventTypeWarning,
+		Reason:         "InsufficientVCPUQuota",
+		Message:        fmt.Sprintf("Provisioner %s event: Skipped instance types %v since they exceed the available %s vCPU quota", provisionerName, instanceTypes, capacityType),
+		DedupeValues:   []string{provisionerName, capacityType},
+	}
+}
+
+func VCPULimitExceeded(provisionerName string, capacityType string, instanceTypes []string) events.Event {
+	return events.Event{
+		InvolvedObject: &v1alpha5.Provisioner{ObjectMeta: metav1.ObjectMeta{Name: provisionerName}},
+		Type:           v1.EventTypeWarning,
+		Reason:         "VCPULimitExceeded",
+		Message:        fmt.Sprintf("Provisioner %s event: Launching %v would exceed the %s vCPU limit of the account, request a service quota increase", provisionerName, instanceTypes, capacityType),
+		DedupeValues:   []string{provisionerName, capacityType},
+	}
+}
+
+func NoInstanceTypes(provisionerName string, reason string) events.Event {
+	return events.Event{
+		InvolvedObject: &v1alpha5.Provisioner{ObjectMeta: metav1.ObjectMeta{Name: provisionerName}},
+		Type:           v1.EventTypeWarning,
+		Reason:         "NoInstanceTypes",
+		Message:        fmt.Sprintf("Provisioner %s event: No instance types can be launched, %s", provisionerName, reason),
+		DedupeValues:   []string{provisionerName, reason},
+	}
+}
+
+func MaxPriceExceeded(provisionerName string, maxPrice string) events.Event {
+	return events.Event{
+		InvolvedObject: &v1alpha5.Provisioner{ObjectMeta: metav1.ObjectMeta{Name: provisionerName}},
+		Type:           v1.EventTypeWarning,
+		Reason:         "MaxPriceExceeded",
+		Message:        fmt.Sprintf("Provisioner %s event: No instance types can be launched, every offering costs more than the maxPrice of %s per hour of the node template", provisionerName, maxPrice),
+		DedupeValues:   []string{provisionerName, maxPrice},
+	}
+}
+
+func SpotUnavailable(provisionerName string, fallback bool) events.Event {
+	action := "waiting for spot capacity"
+	if fallback {
+		action = "launching on-demand capacity instead"
+	}
+	return events.Event{
+		InvolvedObject: &v1alpha5.Provisioner{ObjectMeta: metav1.ObjectMeta{Name: provisionerName}},
+		Type:           v1.EventTypeWarning,
+		Reason:         "SpotUnavailable",
+		Message:        fmt.Sprintf("Provisioner %s event: All spot offerings are unavailable after insufficient capacity errors, %s", provisionerName, action),
+		DedupeValues:   []string{provisionerName},
+	}
+}
+
+func EmptyFleet(provisionerName string, capacityType string) events.Event {
+	return events.Event{
+		InvolvedObject: &v1alpha5.Provisioner{ObjectMeta: metav1.ObjectMeta{Name: provisionerName}},
+		Type:           v1.EventTypeWarning,
+		Reason:         "EmptyFleet",
+		Message:        fmt.Sprintf("Provisioner %s event: CreateFleet launched no %s instances and reported no errors", provisionerName, capacityType),
+		DedupeValues:   []string{provisionerName, capacityType},
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/samber/lo"
+
+	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/cloudprovider"
+	"github.com/aws/karpenter-core/pkg/scheduling"
+)
+
+// InstanceTypeExplanation is whether an instance type can be launched for a provisioner and, if it can't, why
+type InstanceTypeExplanation struct {
+	InstanceType string   `json:"instanceType"`
+	Eligible     bool     `json:"eligible"`
+	Reasons      []string `json:"reasons,omitempty"`
+}
+
+func (e InstanceTypeExplanation) String() string {
+	if e.Eligible {
+		return fmt.Sprintf("instance type %s can be launched", e.InstanceType)
+	}
+	return fmt.Sprintf("instance type %s can't be launched, it %s", e.InstanceType, strings.Join(e.Reasons, " and it "))
+}
+
+// ExplainInstanceType explains why an instance type is or isn't eligible to be launched for the provisioner. It
+// resolves the instance type the same way that GetInstanceTypes does and then checks it against the requirements of
+// the provisioner like the scheduler does, so it's meant for debugging rather than for scheduling.
+func (c *CloudProvider) ExplainInstanceType(ctx context.Context, provisioner *v1alpha5.Provisioner, name string) (InstanceTypeExplanation, error) {
+	explanation := InstanceTypeExplanation{InstanceType: name}
+	nodeTemplate, err := c.getNodeTemplate(ctx, provisioner.Spec.ProviderRef)
+	if err != nil {
+		return explanation, err
+	}
+	aws, err := c.getProvider(provisioner.Spec.Provider, nodeTemplate)
+	if err != nil {
+		return explanation, err
+	}
+	instanceType, reason, err := c.instanceTypeProvider.Explain(ctx, aws, kubeletConfiguration(provisioner.Spec.KubeletConfiguration, nodeTemplate), name)
+	if err != nil {
+		return explanation, err
+	}
+	if reason != "" {
+		explanation.Reasons = []string{reason}
+		return explanation, nil
+	}
+	requirements := scheduling.NewNodeTemplate(provisioner).Requirements
+	explanation.Reasons = append(explainRequirements(instanceType, requirements), c.explainOfferings(instanceType, requirements)...)
+	explanation.Eligible = len(explanation.Reasons) == 0
+	return explanation, nil
+}
+
+// explainRequirements returns a reason for each requirement of the provisioner that the instance type doesn't satisfy
+func explainRequirements(instanceType cloudprovider.InstanceType, requirements scheduling.Requirements) []string {
+	var reasons []string
+	for _, key := range requirements.Keys().List() {
+		if instanceType.Requirements().Intersects(scheduling.NewRequirements(requirements.Get(key))) != nil {
+			reasons = append(reasons, fmt.Sprintf("doesn't satisfy the provisioner's requirement %s, since it has %s", requirements.Get(key), instanceType.Requirements().Get(key)))
+		}
+	}
+	return reasons
+}
+
+// explainOfferings returns why none of the offerings of the instance type in the zones and capacity types of the
+// provisioner can be launched, or nothing if one of them can
+func (c *CloudProvider) explainOfferings(instanceType cloudprovider.InstanceType, requirements scheduling.Requirements) []string {
+	offerings := lo.Filter(instanceType.Offerings(), func(offering cloudprovider.Offering, _ int) bool {
+		return isCompatibleOffering(offering, requirements)
+	})
+	if len(offerings) == 0 {
+		return []string{"isn't offered in the provisioner's zones and capacity types"}
+	}
+	if lo.SomeBy(offerings, func(offering cloudprovider.Offering) bool { return offering.Available }) {
+		return nil
+	}
+	unavailable := lo.Filter(offerings, func(offering cloudprovider.Offering, _ int) bool {
+		return c.instanceTypeProvider.unavailableOfferings.IsUnavailable(instanceType.Name(), offering.Zone, offering.CapacityType)
+	})
+	unpriced, _ := lo.Difference(offerings, unavailable)
+	var reasons []string
+	if len(unavailable) > 0 {
+		reasons = append(reasons, fmt.Sprintf("recently failed to launch with insufficient capacity as %s", prettyOfferings(unavailable)))
+	}
+	if len(unpriced) > 0 {
+		reasons = append(reasons, fmt.Sprintf("has no known price as %s", prettyOfferings(unpriced)))
+	}
+	return reasons
+}
+
+func prettyOfferings(offerings []cloudprovider.Offering) string {
+	return strings.Join(lo.Map(offerings, func(offering cloudprovider.Offering, _ int) string {
+		return fmt.Sprintf("%s in %s", offering.CapacityType, offering.Zone)
+	}), ", ")
+}
@@ -0,0 +1,82 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
+	awssettings "github.com/aws/karpenter/pkg/apis/config/settings"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var _ = Describe("Explain Instance Type", func() {
+	BeforeEach(func() {
+		provisioner.Spec.Requirements = []v1.NodeSelectorRequirement{
+			{Key: v1alpha5.LabelCapacityType, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.CapacityTypeSpot, v1alpha5.CapacityTypeOnDemand}},
+			{Key: v1.LabelTopologyZone, Operator: v1.NodeSelectorOpIn, Values: []string{"test-zone-1a"}},
+		}
+	})
+	It("should explain that an eligible instance type can be launched", func() {
+		explanation, err := cloudProvider.ExplainInstanceType(ctx, provisioner, "m5.large")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(explanation.Eligible).To(BeTrue())
+		Expect(explanation.Reasons).To(BeEmpty())
+	})
+	It("should explain that an unknown instance type isn't available", func() {
+		explanation, err := cloudProvider.ExplainInstanceType(ctx, provisioner, "m5.unknown")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(explanation.Eligible).To(BeFalse())
+		Expect(explanation.Reasons).To(ConsistOf(ContainSubstring("isn't available in region")))
+	})
+	It("should explain that an instance type is excluded by the settings", func() {
+		settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{
+			ExcludedInstanceTypes: []string{"m5.*"},
+		})
+		ctx = settingsStore.InjectSettings(ctx)
+		explanation, err := cloudProvider.ExplainInstanceType(ctx, provisioner, "m5.large")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(explanation.Eligible).To(BeFalse())
+		Expect(explanation.Reasons).To(ConsistOf(ContainSubstring("aws.excludedInstanceTypes")))
+	})
+	It("should explain that an instance type has the wrong architecture", func() {
+		provisioner.Spec.Requirements = append(provisioner.Spec.Requirements, v1.NodeSelectorRequirement{
+			Key: v1.LabelArchStable, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.ArchitectureArm64},
+		})
+		explanation, err := cloudProvider.ExplainInstanceType(ctx, provisioner, "m5.large")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(explanation.Eligible).To(BeFalse())
+		Expect(explanation.Reasons).To(ConsistOf(ContainSubstring(v1.LabelArchStable)))
+	})
+	It("should explain that an instance type isn't offered in the provisioner's zones", func() {
+		provisioner.Spec.Requirements[1].Values = []string{"test-zone-1d"}
+		explanation, err := cloudProvider.ExplainInstanceType(ctx, provisioner, "m5.large")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(explanation.Eligible).To(BeFalse())
+		Expect(explanation.Reasons).To(ContainElement(ContainSubstring("isn't offered")))
+	})
+	It("should explain that the offerings of an instance type recently failed with insufficient capacity", func() {
+		for _, capacityType := range []string{v1alpha5.CapacityTypeSpot, v1alpha5.CapacityTypeOnDemand} {
+			unavailableOfferingsCache.MarkUnavailable(ctx, "InsufficientInstanceCapacity", "m5.large", "test-zone-1a", capacityType)
+		}
+		explanation, err := cloudProvider.ExplainInstanceType(ctx, provisioner, "m5.large")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(explanation.Eligible).To(BeFalse())
+		Expect(explanation.Reasons).To(ConsistOf(ContainSubstring("insufficient capacity")))
+		Expect(explanation.String()).To(ContainSubstring("can't be launched"))
+	})
+})
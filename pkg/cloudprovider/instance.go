@@ -18,7 +18,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math/rand"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -27,6 +29,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
 	"github.com/samber/lo"
 	"go.uber.org/multierr"
 	v1 "k8s.io/api/core/v1"
@@ -36,6 +39,8 @@ import (
 
 	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
 	"github.com/aws/karpenter-core/pkg/cloudprovider"
+	"github.com/aws/karpenter-core/pkg/events"
+	"github.com/aws/karpenter-core/pkg/operator/injection"
 	"github.com/aws/karpenter-core/pkg/scheduling"
 	"github.com/aws/karpenter-core/pkg/utils/functional"
 	"github.com/aws/karpenter-core/pkg/utils/resources"
@@ -49,41 +54,80 @@ var (
 	instanceTypeFlexibilityThreshold = 5 // falling back to on-demand without flexibility risks insufficient capacity errors
 )
 
+const maxTagValueLength = 256 // EC2 rejects tag values longer than this
+
+const (
+	transientLaunchRetryDelay = 500 * time.Millisecond
+	transientLaunchAttempts   = 3
+	// maxResourceTags is the number of tags that EC2 allows on an instance, volume or fleet
+	maxResourceTags = 50
+)
+
 type InstanceProvider struct {
 	ec2api                 ec2iface.EC2API
 	instanceTypeProvider   *InstanceTypeProvider
 	subnetProvider         *SubnetProvider
 	launchTemplateProvider *LaunchTemplateProvider
+	quotaProvider          *QuotaProvider
 	createFleetBatcher     *CreateFleetBatcher
+	describeInstances      *DescribeInstancesBatcher
+	decisionRecorder       *DecisionRecorder
+	eventPublisher         *InstanceEventPublisher
+	recorder               events.Recorder
 }
 
-func NewInstanceProvider(ctx context.Context, ec2api ec2iface.EC2API, instanceTypeProvider *InstanceTypeProvider, subnetProvider *SubnetProvider, launchTemplateProvider *LaunchTemplateProvider) *InstanceProvider {
-	return &InstanceProvider{
+func NewInstanceProvider(ctx context.Context, ec2api ec2iface.EC2API, eventBridgeAPI eventbridgeiface.EventBridgeAPI, recorder events.Recorder, instanceTypeProvider *InstanceTypeProvider, subnetProvider *SubnetProvider, launchTemplateProvider *LaunchTemplateProvider, quotaProvider *QuotaProvider) *InstanceProvider {
+	p := &InstanceProvider{
 		ec2api:                 ec2api,
+		recorder:               recorder,
 		instanceTypeProvider:   instanceTypeProvider,
 		subnetProvider:         subnetProvider,
 		launchTemplateProvider: launchTemplateProvider,
-		createFleetBatcher:     NewCreateFleetBatcher(ctx, ec2api),
+		quotaProvider:          quotaProvider,
+		describeInstances:      NewDescribeInstancesBatcher(ctx, ec2api),
+		decisionRecorder:       NewDecisionRecorder(),
+		eventPublisher:         NewInstanceEventPublisher(eventBridgeAPI),
 	}
+	p.createFleetBatcher = NewCreateFleetBatcher(ctx, ec2api, p.terminateInstance)
+	return p
 }
 
 // Create an instance given the constraints.
 // instanceTypes should be sorted by priority for spot capacity type.
 // If spot is not used, the instanceTypes are not required to be sorted
 // because we are using ec2 fleet's lowest-price OD allocation strategy
-func (p *InstanceProvider) Create(ctx context.Context, provider *v1alpha1.AWS, nodeRequest *cloudprovider.NodeRequest) (*v1.Node, error) {
+func (p *InstanceProvider) Create(ctx context.Context, provider *v1alpha1.AWS, nodeRequest *cloudprovider.NodeRequest, versionTags map[string]string) (*v1.Node, error) {
+	if awssettings.FromContext(ctx).SpotUnavailableBehavior == awssettings.WaitForSpot && spotUnavailable(nodeRequest.Template.Requirements, nodeRequest.InstanceTypeOptions) {
+		return nil, fmt.Errorf("all spot offerings are unavailable, waiting for spot capacity rather than launching on-demand")
+	}
+	nodeRequest.InstanceTypeOptions = p.quotaProvider.Filter(ctx, nodeRequest, p.getCapacityType(provider, nodeRequest))
+	if len(nodeRequest.InstanceTypeOptions) == 0 {
+		return nil, fmt.Errorf("all instance types exceed the available vCPU quota or recently exceeded the vCPU limit")
+	}
 	nodeRequest.InstanceTypeOptions = p.prioritizeInstanceTypes(nodeRequest.InstanceTypeOptions)
 	if len(nodeRequest.InstanceTypeOptions) > MaxInstanceTypes {
 		nodeRequest.InstanceTypeOptions = nodeRequest.InstanceTypeOptions[0:MaxInstanceTypes]
 	}
 
-	id, err := p.launchInstance(ctx, provider, nodeRequest)
-	if awserrors.IsLaunchTemplateNotFound(err) {
-		// retry once if launch template is not found. This allows karpenter to generate a new LT if the
-		// cache was out-of-sync on the first try
-		id, err = p.launchInstance(ctx, provider, nodeRequest)
-	}
-	if err != nil {
+	var id *string
+	// Retry briefly if the launch template or subnet isn't visible to CreateFleet yet, since EC2 is eventually
+	// consistent right after they're created. Other invalid parameters fail fast.
+	if err := retry.Do(
+		func() (err error) {
+			id, err = p.launchInstance(ctx, provider, nodeRequest, versionTags)
+			if awserrors.IsLaunchTemplateNotFound(err) {
+				// retry once if launch template is not found. This allows karpenter to generate a new LT if the
+				// cache was out-of-sync on the first try
+				id, err = p.launchInstance(ctx, provider, nodeRequest, versionTags)
+			}
+			return err
+		},
+		retry.RetryIf(awserrors.IsTransientInvalidParameterValue),
+		retry.Delay(transientLaunchRetryDelay),
+		retry.Attempts(transientLaunchAttempts),
+		retry.LastErrorOnly(true),
+		retry.Context(ctx),
+	); err != nil {
 		return nil, err
 	}
 	// Get Instance with backoff retry since EC2 is eventually consistent
@@ -103,47 +147,108 @@ func (p *InstanceProvider) Create(ctx context.Context, provider *v1alpha1.AWS, n
 		aws.StringValue(instance.Placement.AvailabilityZone),
 		getCapacityType(instance),
 	)
+	p.tagInstanceName(ctx, provider, instance)
+	p.decisionRecorder.Record(ctx, nodeRequest, instance)
 
 	// Convert Instance to Node
-	return p.instanceToNode(ctx, instance, nodeRequest.InstanceTypeOptions), nil
+	node := p.instanceToNode(ctx, provider, instance, nodeRequest.InstanceTypeOptions)
+	p.eventPublisher.PublishLaunch(ctx, node, instance)
+	return node, nil
 }
 
+// Terminate terminates the instance of the node and confirms that it's shutting down. The termination finalizer of
+// the node is only removed once this succeeds, so an error keeps the node around until the instance can't leak.
+// Instances that are already terminated or no longer exist are treated as terminated.
 func (p *InstanceProvider) Terminate(ctx context.Context, node *v1.Node) error {
 	id, err := utils.ParseInstanceID(node)
 	if err != nil {
 		return fmt.Errorf("getting instance ID for node %s, %w", node.Name, err)
 	}
-	if _, err = p.ec2api.TerminateInstancesWithContext(ctx, &ec2.TerminateInstancesInput{
-		InstanceIds: []*string{id},
-	}); err != nil {
+	output, err := p.terminateInstance(ctx, id)
+	if err != nil {
 		if awserrors.IsNotFound(err) {
 			return nil
 		}
-		if _, errMsg := p.getInstance(ctx, aws.StringValue(id)); err != nil {
-			if awserrors.IsInstanceTerminated(errMsg) || awserrors.IsNotFound(errMsg) {
-				logging.FromContext(ctx).Debugf("Instance already terminated, %s", node.Name)
-				return nil
-			}
-			err = multierr.Append(err, errMsg)
+		if terminating, errMsg := p.isTerminating(ctx, aws.StringValue(id)); errMsg != nil || !terminating {
+			return fmt.Errorf("terminating instance %s, %w", node.Name, multierr.Append(err, errMsg))
 		}
-
-		return fmt.Errorf("terminating instance %s, %w", node.Name, err)
+		logging.FromContext(ctx).Debugf("Instance already terminated, %s", node.Name)
+		return nil
 	}
+	if lo.ContainsBy(output.TerminatingInstances, func(change *ec2.InstanceStateChange) bool {
+		return aws.StringValue(change.InstanceId) == aws.StringValue(id) && change.CurrentState != nil && isTerminatingState(aws.StringValue(change.CurrentState.Name))
+	}) {
+		p.eventPublisher.PublishTermination(ctx, node, aws.StringValue(id))
+		return nil
+	}
+	// The response didn't confirm the state change, so check the instance itself before letting the node go
+	terminating, err := p.isTerminating(ctx, aws.StringValue(id))
+	if err != nil {
+		return fmt.Errorf("confirming termination of instance %s, %w", node.Name, err)
+	}
+	if !terminating {
+		return fmt.Errorf("instance %s of node %s is not shutting down after terminating it", aws.StringValue(id), node.Name)
+	}
+	p.eventPublisher.PublishTermination(ctx, node, aws.StringValue(id))
 	return nil
 }
 
-func (p *InstanceProvider) launchInstance(ctx context.Context, provider *v1alpha1.AWS, nodeRequest *cloudprovider.NodeRequest) (*string, error) {
-	capacityType := p.getCapacityType(nodeRequest)
+// terminateInstance lifts the termination protection of instances launched with disableAPITermination before
+// terminating them again, since the protection guards against termination outside of Karpenter only
+func (p *InstanceProvider) terminateInstance(ctx context.Context, id *string) (*ec2.TerminateInstancesOutput, error) {
+	output, err := p.ec2api.TerminateInstancesWithContext(ctx, &ec2.TerminateInstancesInput{
+		InstanceIds: []*string{id},
+	})
+	if !awserrors.IsOperationNotPermitted(err) {
+		return output, err
+	}
+	logging.FromContext(ctx).Debugf("Disabling termination protection of instance %s", aws.StringValue(id))
+	if _, err := p.ec2api.ModifyInstanceAttributeWithContext(ctx, &ec2.ModifyInstanceAttributeInput{
+		InstanceId:            id,
+		DisableApiTermination: &ec2.AttributeBooleanValue{Value: aws.Bool(false)},
+	}); err != nil {
+		return nil, fmt.Errorf("disabling termination protection, %w", err)
+	}
+	return p.ec2api.TerminateInstancesWithContext(ctx, &ec2.TerminateInstancesInput{
+		InstanceIds: []*string{id},
+	})
+}
+
+// isTerminating returns true if the instance is shutting down, terminated or no longer exists
+func (p *InstanceProvider) isTerminating(ctx context.Context, id string) (bool, error) {
+	instance, err := p.describeInstances.DescribeInstance(ctx, id)
+	if awserrors.IsNotFound(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return instance.State != nil && isTerminatingState(aws.StringValue(instance.State.Name)), nil
+}
+
+func isTerminatingState(state string) bool {
+	return state == ec2.InstanceStateNameShuttingDown || state == ec2.InstanceStateNameTerminated
+}
+
+func (p *InstanceProvider) launchInstance(ctx context.Context, provider *v1alpha1.AWS, nodeRequest *cloudprovider.NodeRequest, versionTags map[string]string) (*string, error) {
+	capacityType := p.getCapacityType(provider, nodeRequest)
 	// Get Launch Template Configs, which may differ due to GPU or Architecture requirements
 	launchTemplateConfigs, err := p.getLaunchTemplateConfigs(ctx, provider, nodeRequest, capacityType)
 	if err != nil {
 		return nil, fmt.Errorf("getting launch template configs, %w", err)
 	}
-	if err := p.checkODFallback(nodeRequest, launchTemplateConfigs); err != nil {
+	if err := p.checkODFallback(provider, nodeRequest, launchTemplateConfigs); err != nil {
 		logging.FromContext(ctx).Warn(err.Error())
 	}
 	// Create fleet
-	tags := v1alpha1.MergeTags(ctx, awssettings.FromContext(ctx).Tags, provider.Tags, map[string]string{fmt.Sprintf("kubernetes.io/cluster/%s", awssettings.FromContext(ctx).ClusterName): "owned"})
+	launchNameTag, _ := nameTag(ctx, provider, map[string]string{"capacity-type": capacityType})
+	clusterTag := map[string]string{fmt.Sprintf("kubernetes.io/cluster/%s", awssettings.FromContext(ctx).ClusterName): "owned"}
+	tags := v1alpha1.MergeTags(ctx, launchNameTag, awssettings.FromContext(ctx).Tags, provider.Tags, versionTags, clusterTag)
+	if len(tags) > maxResourceTags && len(versionTags) > 0 {
+		// The version tags are informational, so they give way to the tags of the user rather than failing the launch
+		logging.FromContext(ctx).Warnf("Skipping version tags, instances can't have more than %d tags", maxResourceTags)
+		tags = v1alpha1.MergeTags(ctx, launchNameTag, awssettings.FromContext(ctx).Tags, provider.Tags, clusterTag)
+	}
 	createFleetInput := &ec2.CreateFleetInput{
 		Type:                  aws.String(ec2.FleetTypeInstant),
 		Context:               provider.Context,
@@ -159,13 +264,16 @@ func (p *InstanceProvider) launchInstance(ctx context.Context, provider *v1alpha
 		},
 	}
 	if capacityType == v1alpha5.CapacityTypeSpot {
-		createFleetInput.SpotOptions = &ec2.SpotOptionsRequest{AllocationStrategy: aws.String(ec2.SpotAllocationStrategyCapacityOptimizedPrioritized)}
+		createFleetInput.SpotOptions = &ec2.SpotOptionsRequest{AllocationStrategy: aws.String(allocationStrategy(provider, capacityType))}
 	} else {
-		createFleetInput.OnDemandOptions = &ec2.OnDemandOptionsRequest{AllocationStrategy: aws.String(ec2.FleetOnDemandAllocationStrategyLowestPrice)}
+		createFleetInput.OnDemandOptions = &ec2.OnDemandOptionsRequest{AllocationStrategy: aws.String(allocationStrategy(provider, capacityType))}
 	}
 
-	createFleetOutput, err := p.createFleetBatcher.CreateFleet(ctx, createFleetInput)
+	createFleetOutput, err := p.createFleet(ctx, createFleetInput)
 	if err != nil {
+		if awserrors.IsCreateFleetTimeout(err) {
+			return nil, err
+		}
 		if awserrors.IsLaunchTemplateNotFound(err) {
 			for _, lt := range launchTemplateConfigs {
 				p.launchTemplateProvider.Invalidate(ctx, aws.StringValue(lt.LaunchTemplateSpecification.LaunchTemplateName))
@@ -179,15 +287,95 @@ func (p *InstanceProvider) launchInstance(ctx context.Context, provider *v1alpha
 		return nil, fmt.Errorf("creating fleet %w", err)
 	}
 	p.updateUnavailableOfferingsCache(ctx, createFleetOutput.Errors, capacityType)
+	p.quotaProvider.MarkVCPULimitExceeded(ctx, nodeRequest, capacityType, createFleetOutput.Errors)
 	if len(createFleetOutput.Instances) == 0 || len(createFleetOutput.Instances[0].InstanceIds) == 0 {
+		// The fleet can come back without instances and without errors, e.g. when EC2 filtered out every override,
+		// which would otherwise leave the launch failing without a reason
+		if len(createFleetOutput.Errors) == 0 {
+			p.recorder.Publish(EmptyFleet(nodeRequest.Template.ProvisionerName, capacityType))
+			return nil, fmt.Errorf("creating fleet, fleet returned no instances and no errors for %d instance type(s) with capacity type %s",
+				len(nodeRequest.InstanceTypeOptions), capacityType)
+		}
+		// The fleet reports invalid parameters per override, so surface the ones caused by eventual consistency as
+		// an AWS error to let the launch be retried
+		if fleetErr, ok := lo.Find(createFleetOutput.Errors, awserrors.IsTransientInvalidParameterValueFleetError); ok {
+			return nil, multierr.Append(awserr.New(aws.StringValue(fleetErr.ErrorCode), aws.StringValue(fleetErr.ErrorMessage), nil), combineFleetErrors(createFleetOutput.Errors))
+		}
 		return nil, combineFleetErrors(createFleetOutput.Errors)
 	}
 	return createFleetOutput.Instances[0].InstanceIds[0], nil
 }
 
-func (p *InstanceProvider) checkODFallback(nodeRequest *cloudprovider.NodeRequest, launchTemplateConfigs []*ec2.FleetLaunchTemplateConfigRequest) error {
+// tagInstanceName applies the Name tag to a launched instance when the NameTagTemplate references variables that
+// are only known once the instance exists. Failing to tag isn't fatal since the instance has already been launched.
+func (p *InstanceProvider) tagInstanceName(ctx context.Context, provider *v1alpha1.AWS, instance *ec2.Instance) {
+	if _, ok := nameTag(ctx, provider, map[string]string{"capacity-type": getCapacityType(instance)}); ok {
+		return
+	}
+	tags, _ := nameTag(ctx, provider, map[string]string{
+		"capacity-type": getCapacityType(instance),
+		"instance-id":   aws.StringValue(instance.InstanceId),
+		"instance-type": aws.StringValue(instance.InstanceType),
+		"zone":          aws.StringValue(instance.Placement.AvailabilityZone),
+	})
+	if tags == nil {
+		return
+	}
+	if _, err := p.ec2api.CreateTagsWithContext(ctx, &ec2.CreateTagsInput{
+		Resources: []*string{instance.InstanceId},
+		Tags:      []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String(tags["Name"])}},
+	}); err != nil {
+		logging.FromContext(ctx).Errorf("Tagging instance %s with name %s, %s", aws.StringValue(instance.InstanceId), tags["Name"], err)
+	}
+}
+
+// nameTag renders the NameTagTemplate into a Name tag, returning false if the template references variables that
+// aren't in values. No tag is returned if there is no template, if the user has set a Name tag of their own, or if
+// the rendered name is longer than EC2 allows.
+func nameTag(ctx context.Context, provider *v1alpha1.AWS, values map[string]string) (map[string]string, bool) {
+	settings := awssettings.FromContext(ctx)
+	if settings.NameTagTemplate == "" {
+		return nil, true
+	}
+	if _, ok := settings.Tags["Name"]; ok {
+		return nil, true
+	}
+	if _, ok := provider.Tags["Name"]; ok {
+		return nil, true
+	}
+	name, unresolved := settings.RenderNameTagTemplate(lo.Assign(map[string]string{
+		"cluster":     settings.ClusterName,
+		"provisioner": injection.GetNamespacedName(ctx).Name,
+	}, values))
+	if len(unresolved) > 0 {
+		return nil, false
+	}
+	if len(name) > maxTagValueLength {
+		logging.FromContext(ctx).Errorf("Ignoring nameTagTemplate, rendered name %q is longer than %d characters", name, maxTagValueLength)
+		return nil, true
+	}
+	return map[string]string{"Name": name}, true
+}
+
+// createFleet calls CreateFleet, bounded by the configured timeout so that a hung call fails the launch rather than
+// stalling provisioning
+func (p *InstanceProvider) createFleet(ctx context.Context, createFleetInput *ec2.CreateFleetInput) (*ec2.CreateFleetOutput, error) {
+	timeout := awssettings.FromContext(ctx).CreateFleetTimeout.Duration
+	if timeout == 0 {
+		return p.createFleetBatcher.CreateFleet(ctx, createFleetInput)
+	}
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	createFleetOutput, err := p.createFleetBatcher.CreateFleet(timeoutCtx, createFleetInput)
+	if err != nil && errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		return nil, awserrors.CreateFleetTimeoutError{Err: fmt.Errorf("creating fleet, timed out after %s, %w", timeout, err)}
+	}
+	return createFleetOutput, err
+}
+
+func (p *InstanceProvider) checkODFallback(provider *v1alpha1.AWS, nodeRequest *cloudprovider.NodeRequest, launchTemplateConfigs []*ec2.FleetLaunchTemplateConfigRequest) error {
 	// only evaluate for on-demand fallback if the capacity type for the request is OD and both OD and spot are allowed in requirements
-	if p.getCapacityType(nodeRequest) != v1alpha5.CapacityTypeOnDemand || !nodeRequest.Template.Requirements.Get(v1alpha5.LabelCapacityType).Has(v1alpha5.CapacityTypeSpot) {
+	if p.getCapacityType(provider, nodeRequest) != v1alpha5.CapacityTypeOnDemand || !nodeRequest.Template.Requirements.Get(v1alpha5.LabelCapacityType).Has(v1alpha5.CapacityTypeSpot) {
 		return nil
 	}
 
@@ -213,6 +401,7 @@ func (p *InstanceProvider) getLaunchTemplateConfigs(ctx context.Context, provide
 	if err != nil {
 		return nil, fmt.Errorf("getting subnets, %w", err)
 	}
+	zonalSubnets := selectZonalSubnets(ctx, subnets)
 	var launchTemplateConfigs []*ec2.FleetLaunchTemplateConfigRequest
 	launchTemplates, err := p.launchTemplateProvider.Get(ctx, provider, nodeRequest, map[string]string{v1alpha5.LabelCapacityType: capacityType})
 	if err != nil {
@@ -220,34 +409,140 @@ func (p *InstanceProvider) getLaunchTemplateConfigs(ctx context.Context, provide
 	}
 	for launchTemplateName, instanceTypes := range launchTemplates {
 		launchTemplateConfig := &ec2.FleetLaunchTemplateConfigRequest{
-			Overrides: p.getOverrides(instanceTypes, subnets, nodeRequest.Template.Requirements.Get(v1.LabelTopologyZone), capacityType),
+			Overrides: p.getOverrides(provider, instanceTypes, zonalSubnets, nodeRequest.Template.Requirements.Get(v1.LabelTopologyZone), capacityType),
 			LaunchTemplateSpecification: &ec2.FleetLaunchTemplateSpecificationRequest{
 				LaunchTemplateName: aws.String(launchTemplateName),
 				Version:            aws.String("$Latest"),
 			},
 		}
+		if provider.PlacementGroup != nil {
+			for _, override := range launchTemplateConfig.Overrides {
+				override.Placement = &ec2.Placement{GroupName: aws.String(provider.PlacementGroup.Name)}
+			}
+		}
 		if len(launchTemplateConfig.Overrides) > 0 {
 			launchTemplateConfigs = append(launchTemplateConfigs, launchTemplateConfig)
 		}
 	}
+	if provider.PlacementGroup != nil && provider.PlacementGroup.Strategy == ec2.PlacementGroupStrategyCluster {
+		if launchTemplateConfigs, err = p.restrictToPlacementGroupZone(ctx, provider.PlacementGroup.Name, launchTemplateConfigs); err != nil {
+			return nil, err
+		}
+	}
 	if len(launchTemplateConfigs) == 0 {
 		return nil, fmt.Errorf("no capacity offerings are currently available given the constraints")
 	}
 	return launchTemplateConfigs, nil
 }
 
-// getOverrides creates and returns launch template overrides for the cross product of instanceTypeOptions and subnets (with subnets being constrained by
-// zones and the offerings in instanceTypeOptions)
-func (p *InstanceProvider) getOverrides(instanceTypeOptions []cloudprovider.InstanceType, subnets []*ec2.Subnet, zones *scheduling.Requirement, capacityType string) []*ec2.FleetLaunchTemplateOverridesRequest {
+// restrictToPlacementGroupZone restricts the overrides to a single zone, since a cluster placement group can't span
+// zones. That's the zone of the instances that are already in the placement group or, if it's empty, the zone with
+// the most overrides so that the launch is as flexible as possible.
+func (p *InstanceProvider) restrictToPlacementGroupZone(ctx context.Context, placementGroup string, launchTemplateConfigs []*ec2.FleetLaunchTemplateConfigRequest) ([]*ec2.FleetLaunchTemplateConfigRequest, error) {
+	zone, err := p.placementGroupZone(ctx, placementGroup)
+	if err != nil {
+		return nil, fmt.Errorf("getting the zone of placement group %s, %w", placementGroup, err)
+	}
+	if zone == "" {
+		overridesPerZone := map[string]int{}
+		for _, launchTemplateConfig := range launchTemplateConfigs {
+			for _, override := range launchTemplateConfig.Overrides {
+				overridesPerZone[aws.StringValue(override.AvailabilityZone)]++
+			}
+		}
+		for _, candidate := range lo.Keys(overridesPerZone) {
+			if zone == "" || overridesPerZone[candidate] > overridesPerZone[zone] || (overridesPerZone[candidate] == overridesPerZone[zone] && candidate < zone) {
+				zone = candidate
+			}
+		}
+	}
+	var restricted []*ec2.FleetLaunchTemplateConfigRequest
+	for _, launchTemplateConfig := range launchTemplateConfigs {
+		launchTemplateConfig.Overrides = lo.Filter(launchTemplateConfig.Overrides, func(override *ec2.FleetLaunchTemplateOverridesRequest, _ int) bool {
+			return aws.StringValue(override.AvailabilityZone) == zone
+		})
+		if len(launchTemplateConfig.Overrides) > 0 {
+			restricted = append(restricted, launchTemplateConfig)
+		}
+	}
+	if len(restricted) == 0 && len(launchTemplateConfigs) > 0 {
+		return nil, fmt.Errorf("no capacity offerings are currently available in zone %s of placement group %s", zone, placementGroup)
+	}
+	return restricted, nil
+}
+
+// placementGroupZone returns the zone of the pending or running instances in the placement group, or an empty string
+// if there are none
+func (p *InstanceProvider) placementGroupZone(ctx context.Context, placementGroup string) (string, error) {
+	var zone string
+	if err := p.ec2api.DescribeInstancesPagesWithContext(ctx, &ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{
+			{Name: aws.String("placement-group-name"), Values: aws.StringSlice([]string{placementGroup})},
+			{Name: aws.String("instance-state-name"), Values: aws.StringSlice([]string{ec2.InstanceStateNamePending, ec2.InstanceStateNameRunning})},
+		},
+	}, func(page *ec2.DescribeInstancesOutput, _ bool) bool {
+		for _, reservation := range page.Reservations {
+			for _, instance := range reservation.Instances {
+				if instance.Placement != nil && aws.StringValue(instance.Placement.AvailabilityZone) != "" {
+					zone = aws.StringValue(instance.Placement.AvailabilityZone)
+					return false
+				}
+			}
+		}
+		return true
+	}); err != nil {
+		return "", err
+	}
+	return zone, nil
+}
+
+// selectZonalSubnets returns the subnet to launch into for each zone. By default, the subnet with the most available
+// IP addresses is used. When SpreadSubnetsWithinZone is enabled, a subnet is picked at random, weighted by its available
+// IP addresses, so that successive launches are spread across the subnets of a zone instead of exhausting one of them.
+func selectZonalSubnets(ctx context.Context, subnets []*ec2.Subnet) map[string]*ec2.Subnet {
 	// sort subnets in ascending order of available IP addresses and populate map with most available subnet per AZ
 	zonalSubnets := map[string]*ec2.Subnet{}
-	sort.Slice(subnets, func(i, j int) bool {
+	sort.SliceStable(subnets, func(i, j int) bool {
 		return aws.Int64Value(subnets[i].AvailableIpAddressCount) < aws.Int64Value(subnets[j].AvailableIpAddressCount)
 	})
 	for _, subnet := range subnets {
 		zonalSubnets[*subnet.AvailabilityZone] = subnet
 	}
+	if !awssettings.FromContext(ctx).SpreadSubnetsWithinZone {
+		return zonalSubnets
+	}
+	for zone, subnets := range lo.GroupBy(subnets, func(s *ec2.Subnet) string { return aws.StringValue(s.AvailabilityZone) }) {
+		if subnet := weightedRandomSubnet(subnets); subnet != nil {
+			zonalSubnets[zone] = subnet
+		}
+	}
+	return zonalSubnets
+}
+
+// weightedRandomSubnet picks a subnet with a probability proportional to its available IP addresses. It returns nil
+// if none of the subnets have available IP addresses.
+func weightedRandomSubnet(subnets []*ec2.Subnet) *ec2.Subnet {
+	total := lo.SumBy(subnets, func(s *ec2.Subnet) int64 { return aws.Int64Value(s.AvailableIpAddressCount) })
+	if total <= 0 {
+		return nil
+	}
+	return weightedSubnet(subnets, rand.Int63n(total)) //nolint:gosec
+}
 
+// weightedSubnet returns the subnet whose share of the available IP addresses contains the nth address, or nil if n
+// is beyond the available IP addresses of every subnet.
+func weightedSubnet(subnets []*ec2.Subnet, n int64) *ec2.Subnet {
+	for _, subnet := range subnets {
+		if n -= aws.Int64Value(subnet.AvailableIpAddressCount); n < 0 {
+			return subnet
+		}
+	}
+	return nil
+}
+
+// getOverrides creates and returns launch template overrides for the cross product of instanceTypeOptions and subnets (with subnets being constrained by
+// zones and the offerings in instanceTypeOptions)
+func (p *InstanceProvider) getOverrides(provider *v1alpha1.AWS, instanceTypeOptions []cloudprovider.InstanceType, zonalSubnets map[string]*ec2.Subnet, zones *scheduling.Requirement, capacityType string) []*ec2.FleetLaunchTemplateOverridesRequest {
 	// Unwrap all the offerings to a flat slice that includes a pointer
 	// to the parent instance type name
 	type offeringWithParentName struct {
@@ -265,9 +560,20 @@ func (p *InstanceProvider) getOverrides(instanceTypeOptions []cloudprovider.Inst
 		unwrappedOfferings = append(unwrappedOfferings, ofs...)
 	}
 
-	// Sort all the potential offerings by each individual offering price
+	// Sort all the potential offerings by each individual offering price, weighted toward newer generations if they're
+	// preferred and toward spot or on-demand by the spot price bias
+	generationPrice := generationWeightedPrice(provider.PreferNewerGenerations, instanceTypeOptions)
+	capacityTypePrice := capacityTypeWeightedPrice(provider.SpotPriceBiasPercent)
+	weightedPrice := func(offering offeringWithParentName) float64 {
+		return generationPrice(offering.parentInstanceTypeName, capacityTypePrice(offering.CapacityType, offering.Price))
+	}
+	// Offerings of the same price are ordered by the zone preference of the provider
+	zoneRank := preferredZoneRank(provider.PreferredZones)
 	sort.Slice(unwrappedOfferings, func(i, j int) bool {
-		return unwrappedOfferings[i].Price < unwrappedOfferings[j].Price
+		if pi, pj := weightedPrice(unwrappedOfferings[i]), weightedPrice(unwrappedOfferings[j]); pi != pj {
+			return pi < pj
+		}
+		return zoneRank(unwrappedOfferings[i].Zone) < zoneRank(unwrappedOfferings[j].Zone)
 	})
 
 	var overrides []*ec2.FleetLaunchTemplateOverridesRequest
@@ -292,7 +598,8 @@ func (p *InstanceProvider) getOverrides(instanceTypeOptions []cloudprovider.Inst
 		// Add a priority for spot requests since we are using the capacity-optimized-prioritized spot allocation strategy
 		// to reduce the likelihood of getting an excessively large instance type.
 		// instanceTypeOptions are sorted by vcpus and memory so this prioritizes smaller instance types.
-		if capacityType == v1alpha5.CapacityTypeSpot {
+		// On-demand requests are prioritized too when newer generations or zones are preferred.
+		if capacityType == v1alpha5.CapacityTypeSpot || prioritizesOnDemand(provider) {
 			override.Priority = aws.Float64(float64(i))
 		}
 		overrides = append(overrides, override)
@@ -300,18 +607,21 @@ func (p *InstanceProvider) getOverrides(instanceTypeOptions []cloudprovider.Inst
 	return overrides
 }
 
-func (p *InstanceProvider) getInstance(ctx context.Context, id string) (*ec2.Instance, error) {
-	describeInstancesOutput, err := p.ec2api.DescribeInstancesWithContext(ctx, &ec2.DescribeInstancesInput{InstanceIds: aws.StringSlice([]string{id})})
-	if awserrors.IsNotFound(err) {
-		return nil, err
+// preferredZoneRank returns the rank of a zone in the preferred zones, where zones that aren't preferred rank last
+func preferredZoneRank(preferredZones []string) func(zone string) int {
+	return func(zone string) int {
+		if rank := lo.IndexOf(preferredZones, zone); rank >= 0 {
+			return rank
+		}
+		return len(preferredZones)
 	}
+}
+
+func (p *InstanceProvider) getInstance(ctx context.Context, id string) (*ec2.Instance, error) {
+	instance, err := p.describeInstances.DescribeInstance(ctx, id)
 	if err != nil {
-		return nil, fmt.Errorf("failed to describe ec2 instances, %w", err)
-	}
-	if len(describeInstancesOutput.Reservations) != 1 || len(describeInstancesOutput.Reservations[0].Instances) != 1 {
-		return nil, awserrors.InstanceTerminatedError{Err: fmt.Errorf("expected instance but got 0")}
+		return nil, err
 	}
-	instance := describeInstancesOutput.Reservations[0].Instances[0]
 	if *instance.State.Name == ec2.InstanceStateNameTerminated {
 		return nil, awserrors.InstanceTerminatedError{Err: fmt.Errorf("instance is in terminated state")}
 	}
@@ -324,7 +634,7 @@ func (p *InstanceProvider) getInstance(ctx context.Context, id string) (*ec2.Ins
 	return instance, nil
 }
 
-func (p *InstanceProvider) instanceToNode(ctx context.Context, instance *ec2.Instance, instanceTypes []cloudprovider.InstanceType) *v1.Node {
+func (p *InstanceProvider) instanceToNode(ctx context.Context, provider *v1alpha1.AWS, instance *ec2.Instance, instanceTypes []cloudprovider.InstanceType) *v1.Node {
 	for _, instanceType := range instanceTypes {
 		if instanceType.Name() == aws.StringValue(instance.InstanceType) {
 			nodeName := strings.ToLower(aws.StringValue(instance.PrivateDnsName))
@@ -344,8 +654,9 @@ func (p *InstanceProvider) instanceToNode(ctx context.Context, instance *ec2.Ins
 
 			return &v1.Node{
 				ObjectMeta: metav1.ObjectMeta{
-					Name:   nodeName,
-					Labels: labels,
+					Name:        nodeName,
+					Labels:      labels,
+					Annotations: lo.Assign(amiAnnotations(provider, instance), allocationAnnotations(ctx, provider, instance, instanceType)),
 				},
 				Spec: v1.NodeSpec{
 					ProviderID: fmt.Sprintf("aws:///%s/%s", aws.StringValue(instance.Placement.AvailabilityZone), aws.StringValue(instance.InstanceId)),
@@ -364,20 +675,38 @@ func (p *InstanceProvider) updateUnavailableOfferingsCache(ctx context.Context,
 	}
 }
 
-// getCapacityType selects spot if both constraints are flexible and there is an
-// available offering. The AWS Cloud Provider defaults to [ on-demand ], so spot
-// must be explicitly included in capacity type requirements.
-func (p *InstanceProvider) getCapacityType(nodeRequest *cloudprovider.NodeRequest) string {
-	if nodeRequest.Template.Requirements.Get(v1alpha5.LabelCapacityType).Has(v1alpha5.CapacityTypeSpot) {
-		for _, instanceType := range nodeRequest.InstanceTypeOptions {
-			for _, offering := range cloudprovider.AvailableOfferings(instanceType) {
-				if nodeRequest.Template.Requirements.Get(v1.LabelTopologyZone).Has(offering.Zone) && offering.CapacityType == v1alpha5.CapacityTypeSpot {
-					return v1alpha5.CapacityTypeSpot
-				}
+// getCapacityType returns spot if it's allowed and available. When on-demand is allowed as well and the provider has
+// a spot price bias, on-demand is returned instead if its cheapest offering is cheaper than the cheapest spot offering
+// after the bias is applied.
+func (p *InstanceProvider) getCapacityType(provider *v1alpha1.AWS, nodeRequest *cloudprovider.NodeRequest) string {
+	capacityTypes := nodeRequest.Template.Requirements.Get(v1alpha5.LabelCapacityType)
+	if !capacityTypes.Has(v1alpha5.CapacityTypeSpot) {
+		return v1alpha5.CapacityTypeOnDemand
+	}
+	weightedPrice := capacityTypeWeightedPrice(provider.SpotPriceBiasPercent)
+	cheapest := map[string]float64{}
+	for _, instanceType := range nodeRequest.InstanceTypeOptions {
+		for _, offering := range cloudprovider.AvailableOfferings(instanceType) {
+			if !nodeRequest.Template.Requirements.Get(v1.LabelTopologyZone).Has(offering.Zone) || !capacityTypes.Has(offering.CapacityType) {
+				continue
+			}
+			price := weightedPrice(offering.CapacityType, offering.Price)
+			if current, ok := cheapest[offering.CapacityType]; !ok || price < current {
+				cheapest[offering.CapacityType] = price
 			}
 		}
 	}
-	return v1alpha5.CapacityTypeOnDemand
+	spotPrice, ok := cheapest[v1alpha5.CapacityTypeSpot]
+	if !ok {
+		return v1alpha5.CapacityTypeOnDemand
+	}
+	if provider.SpotPriceBiasPercent == nil {
+		return v1alpha5.CapacityTypeSpot
+	}
+	if onDemandPrice, ok := cheapest[v1alpha5.CapacityTypeOnDemand]; ok && onDemandPrice < spotPrice {
+		return v1alpha5.CapacityTypeOnDemand
+	}
+	return v1alpha5.CapacityTypeSpot
 }
 
 // prioritizeInstanceTypes is used to eliminate less desirable instance types (like GPUs) from the list of possible instance types when
@@ -423,6 +752,53 @@ func combineFleetErrors(errors []*ec2.CreateFleetError) (errs error) {
 	return fmt.Errorf("with fleet error(s), %w", errs)
 }
 
+// amiAnnotations records the AMI that the instance booted from, and the AMI family of the node template, so that they
+// can be read from the node without describing the instance. The AMI family isn't known for a launch template that's
+// specified directly.
+func amiAnnotations(provider *v1alpha1.AWS, instance *ec2.Instance) map[string]string {
+	annotations := map[string]string{
+		v1alpha1.AnnotationAMIID: aws.StringValue(instance.ImageId),
+	}
+	if provider.LaunchTemplateName == nil {
+		annotations[v1alpha1.AnnotationAMIFamily] = lo.FromPtrOr(provider.AMIFamily, v1alpha1.AMIFamilyAL2)
+	}
+	return annotations
+}
+
+// allocationAnnotations records how the fleet picked the instance, so that the choice can be audited on the node. The
+// annotations are part of the node when it's created rather than patched onto it afterwards.
+func allocationAnnotations(ctx context.Context, provider *v1alpha1.AWS, instance *ec2.Instance, instanceType cloudprovider.InstanceType) map[string]string {
+	if !awssettings.FromContext(ctx).EnableAllocationAnnotations {
+		return nil
+	}
+	capacityType := getCapacityType(instance)
+	annotations := map[string]string{
+		v1alpha1.AnnotationAllocationStrategy: allocationStrategy(provider, capacityType),
+	}
+	if offering, ok := cloudprovider.GetOffering(instanceType, capacityType, aws.StringValue(instance.Placement.AvailabilityZone)); ok {
+		annotations[v1alpha1.AnnotationOfferingPrice] = strconv.FormatFloat(offering.Price, 'f', -1, 64)
+	}
+	return annotations
+}
+
+// allocationStrategy returns the strategy that the fleet picks an override with. On-demand overrides are prioritized
+// rather than picked by price when newer generations or zones are preferred, since the preferences order them.
+func allocationStrategy(provider *v1alpha1.AWS, capacityType string) string {
+	if capacityType == v1alpha5.CapacityTypeSpot {
+		return ec2.SpotAllocationStrategyCapacityOptimizedPrioritized
+	}
+	if prioritizesOnDemand(provider) {
+		return ec2.FleetOnDemandAllocationStrategyPrioritized
+	}
+	return ec2.FleetOnDemandAllocationStrategyLowestPrice
+}
+
+// prioritizesOnDemand returns true if on-demand overrides are launched in the order of their priority, which holds
+// the preferences of the provider, rather than by their price alone
+func prioritizesOnDemand(provider *v1alpha1.AWS) bool {
+	return provider.PreferNewerGenerations != nil || len(provider.PreferredZones) != 0
+}
+
 func getCapacityType(instance *ec2.Instance) string {
 	if instance.SpotInstanceRequestId != nil {
 		return v1alpha5.CapacityTypeSpot
@@ -0,0 +1,156 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+	"github.com/go-playground/validator/v10"
+	"golang.org/x/time/rate"
+	v1 "k8s.io/api/core/v1"
+	"knative.dev/pkg/logging"
+
+	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/operator/injection"
+	awssettings "github.com/aws/karpenter/pkg/apis/config/settings"
+)
+
+const (
+	// InstanceEventSource is the source of the events that are published to the instance event bus
+	InstanceEventSource = "karpenter.k8s.aws"
+
+	InstanceLaunchedDetailType   = "Karpenter Instance Launched"
+	InstanceTerminatedDetailType = "Karpenter Instance Terminated"
+
+	InstanceEventActionLaunch    = "Launch"
+	InstanceEventActionTerminate = "Terminate"
+)
+
+// InstanceEvent is the detail of the events that are published to the instance event bus when Karpenter launches or
+// terminates an instance
+type InstanceEvent struct {
+	Time         time.Time `json:"time" validate:"required"`
+	Action       string    `json:"action" validate:"required,oneof=Launch Terminate"`
+	Cluster      string    `json:"cluster" validate:"required"`
+	Provisioner  string    `json:"provisioner,omitempty"`
+	Node         string    `json:"node,omitempty"`
+	InstanceID   string    `json:"instanceID" validate:"required,startswith=i-"`
+	InstanceType string    `json:"instanceType,omitempty"`
+	CapacityType string    `json:"capacityType,omitempty" validate:"omitempty,oneof=spot on-demand"`
+	Zone         string    `json:"zone,omitempty"`
+}
+
+// InstanceEventPublisher publishes instance events to the EventBridge event bus that's configured in the settings.
+// Events are dropped beyond the configured rate, so that PutEvents calls stay bounded while nodes are launched or
+// terminated in bulk.
+type InstanceEventPublisher struct {
+	eventBridgeAPI eventbridgeiface.EventBridgeAPI
+	validate       *validator.Validate
+
+	mu        sync.Mutex
+	limiter   *rate.Limiter
+	perMinute int
+}
+
+func NewInstanceEventPublisher(eventBridgeAPI eventbridgeiface.EventBridgeAPI) *InstanceEventPublisher {
+	return &InstanceEventPublisher{
+		eventBridgeAPI: eventBridgeAPI,
+		validate:       validator.New(),
+	}
+}
+
+// PublishLaunch publishes the launch of the instance of the node. Failing to publish isn't fatal, since the instance
+// has already been launched.
+func (p *InstanceEventPublisher) PublishLaunch(ctx context.Context, node *v1.Node, instance *ec2.Instance) {
+	p.publish(ctx, InstanceLaunchedDetailType, InstanceEvent{
+		Action:       InstanceEventActionLaunch,
+		Provisioner:  injection.GetNamespacedName(ctx).Name,
+		Node:         node.Name,
+		InstanceID:   aws.StringValue(instance.InstanceId),
+		InstanceType: aws.StringValue(instance.InstanceType),
+		CapacityType: getCapacityType(instance),
+		Zone:         aws.StringValue(instance.Placement.AvailabilityZone),
+	})
+}
+
+// PublishTermination publishes the termination of the instance of the node. Failing to publish isn't fatal, since
+// the instance has already been terminated.
+func (p *InstanceEventPublisher) PublishTermination(ctx context.Context, node *v1.Node, instanceID string) {
+	p.publish(ctx, InstanceTerminatedDetailType, InstanceEvent{
+		Action:       InstanceEventActionTerminate,
+		Provisioner:  node.Labels[v1alpha5.ProvisionerNameLabelKey],
+		Node:         node.Name,
+		InstanceID:   instanceID,
+		InstanceType: node.Labels[v1.LabelInstanceTypeStable],
+		CapacityType: node.Labels[v1alpha5.LabelCapacityType],
+		Zone:         node.Labels[v1.LabelTopologyZone],
+	})
+}
+
+func (p *InstanceEventPublisher) publish(ctx context.Context, detailType string, event InstanceEvent) {
+	settings := awssettings.FromContext(ctx)
+	if settings.InstanceEventBus == "" || !p.allow(settings.InstanceEventsPerMinute) {
+		return
+	}
+	event.Time = time.Now().UTC()
+	event.Cluster = settings.ClusterName
+	if err := p.validate.Struct(event); err != nil {
+		logging.FromContext(ctx).Errorf("Validating instance event, %s", err)
+		return
+	}
+	raw, err := json.Marshal(event)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Marshaling instance event, %s", err)
+		return
+	}
+	output, err := p.eventBridgeAPI.PutEventsWithContext(ctx, &eventbridge.PutEventsInput{
+		Entries: []*eventbridge.PutEventsRequestEntry{{
+			EventBusName: aws.String(settings.InstanceEventBus),
+			Source:       aws.String(InstanceEventSource),
+			DetailType:   aws.String(detailType),
+			Detail:       aws.String(string(raw)),
+			Time:         aws.Time(event.Time),
+		}},
+	})
+	if err == nil && aws.Int64Value(output.FailedEntryCount) > 0 {
+		err = fmt.Errorf("event was not accepted")
+		if len(output.Entries) > 0 {
+			err = fmt.Errorf("%s, %s", aws.StringValue(output.Entries[0].ErrorCode), aws.StringValue(output.Entries[0].ErrorMessage))
+		}
+	}
+	if err != nil {
+		logging.FromContext(ctx).With("instance-id", event.InstanceID).Errorf("Publishing instance event to %s, %s", settings.InstanceEventBus, err)
+	}
+}
+
+// allow returns true if another event may be published within the rate. The limiter is replaced when the rate in
+// the settings changes.
+func (p *InstanceEventPublisher) allow(perMinute int) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.limiter == nil || p.perMinute != perMinute {
+		p.limiter = rate.NewLimiter(rate.Every(time.Minute/time.Duration(perMinute)), perMinute)
+		p.perMinute = perMinute
+	}
+	return p.limiter.Allow()
+}
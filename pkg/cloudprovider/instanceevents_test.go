@@ -0,0 +1,158 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+	awssettings "github.com/aws/karpenter/pkg/apis/config/settings"
+	"github.com/aws/karpenter/pkg/test"
+	"github.com/aws/karpenter/pkg/utils"
+)
+
+var _ = Describe("Instance Events", func() {
+	const eventBus = "arn:aws:events:us-west-2:111222333444:event-bus/karpenter"
+	var instance *ec2.Instance
+	var node *v1.Node
+
+	BeforeEach(func() {
+		instance = &ec2.Instance{
+			InstanceId:     aws.String(fmt.Sprintf("i-%s", coretest.RandomName())),
+			Placement:      &ec2.Placement{AvailabilityZone: aws.String("test-zone-1a")},
+			PrivateDnsName: aws.String("ip-10-0-0-1.ec2.internal"),
+			InstanceType:   aws.String("m5.large"),
+			State:          &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)},
+		}
+		fakeEC2API.Instances.Store(aws.StringValue(instance.InstanceId), instance)
+		node = coretest.Node(coretest.NodeOptions{
+			ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{
+				v1alpha5.ProvisionerNameLabelKey: "default",
+				v1.LabelInstanceTypeStable:       "m5.large",
+				v1alpha5.LabelCapacityType:       v1alpha5.CapacityTypeOnDemand,
+				v1.LabelTopologyZone:             "test-zone-1a",
+			}},
+			ProviderID: fmt.Sprintf("aws:///test-zone-1a/%s", aws.StringValue(instance.InstanceId)),
+		})
+		settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{
+			InstanceEventBus: lo.ToPtr(eventBus),
+		})
+		ctx = settingsStore.InjectSettings(ctx)
+	})
+	detailOf := func(entry *eventbridge.PutEventsRequestEntry) InstanceEvent {
+		event := InstanceEvent{}
+		Expect(json.Unmarshal([]byte(aws.StringValue(entry.Detail)), &event)).To(Succeed())
+		return event
+	}
+
+	It("should publish an event when an instance is launched", func() {
+		ExpectApplied(ctx, env.Client, provisioner)
+		pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+		node := ExpectScheduled(ctx, env.Client, pod)
+
+		Expect(fakeEventBridgeAPI.PutEventsBehavior.CalledWithInput.Len()).To(Equal(1))
+		input := fakeEventBridgeAPI.PutEventsBehavior.CalledWithInput.Pop()
+		Expect(input.Entries).To(HaveLen(1))
+		Expect(aws.StringValue(input.Entries[0].EventBusName)).To(Equal(eventBus))
+		Expect(aws.StringValue(input.Entries[0].Source)).To(Equal(InstanceEventSource))
+		Expect(aws.StringValue(input.Entries[0].DetailType)).To(Equal(InstanceLaunchedDetailType))
+
+		event := detailOf(input.Entries[0])
+		instanceID, err := utils.ParseInstanceID(node)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(event.Action).To(Equal(InstanceEventActionLaunch))
+		Expect(event.Cluster).To(Equal(awssettings.FromContext(ctx).ClusterName))
+		Expect(event.Provisioner).To(Equal(provisioner.Name))
+		Expect(event.Node).To(Equal(node.Name))
+		Expect(event.InstanceID).To(Equal(aws.StringValue(instanceID)))
+		Expect(event.InstanceType).To(Equal(node.Labels[v1.LabelInstanceTypeStable]))
+		Expect(event.CapacityType).To(Equal(v1alpha5.CapacityTypeOnDemand))
+		Expect(event.Zone).To(Equal(node.Labels[v1.LabelTopologyZone]))
+		Expect(event.Time).ToNot(BeZero())
+	})
+	It("should publish an event when an instance is terminated", func() {
+		Expect(cloudProvider.Delete(ctx, node)).To(Succeed())
+
+		Expect(fakeEventBridgeAPI.PutEventsBehavior.CalledWithInput.Len()).To(Equal(1))
+		input := fakeEventBridgeAPI.PutEventsBehavior.CalledWithInput.Pop()
+		Expect(aws.StringValue(input.Entries[0].DetailType)).To(Equal(InstanceTerminatedDetailType))
+		event := detailOf(input.Entries[0])
+		Expect(event.Action).To(Equal(InstanceEventActionTerminate))
+		Expect(event.Provisioner).To(Equal("default"))
+		Expect(event.Node).To(Equal(node.Name))
+		Expect(event.InstanceID).To(Equal(aws.StringValue(instance.InstanceId)))
+		Expect(event.InstanceType).To(Equal("m5.large"))
+		Expect(event.CapacityType).To(Equal(v1alpha5.CapacityTypeOnDemand))
+		Expect(event.Zone).To(Equal("test-zone-1a"))
+	})
+	It("should not publish an event when the instance fails to terminate", func() {
+		fakeEC2API.TerminateInstancesOutput.Set(&ec2.TerminateInstancesOutput{})
+		Expect(cloudProvider.Delete(ctx, node)).ToNot(Succeed())
+		Expect(fakeEventBridgeAPI.PutEventsBehavior.Calls()).To(Equal(0))
+	})
+	It("should not publish events without an event bus", func() {
+		settingsStore[awssettings.ContextKey] = test.Settings()
+		ctx = settingsStore.InjectSettings(ctx)
+		Expect(cloudProvider.Delete(ctx, node)).To(Succeed())
+		Expect(fakeEventBridgeAPI.PutEventsBehavior.Calls()).To(Equal(0))
+	})
+	It("should not fail termination when publishing fails", func() {
+		fakeEventBridgeAPI.PutEventsBehavior.Error.Set(fmt.Errorf("failed"))
+		Expect(cloudProvider.Delete(ctx, node)).To(Succeed())
+		Expect(fakeEventBridgeAPI.PutEventsBehavior.FailedCalls()).To(Equal(1))
+	})
+	Context("Publisher", func() {
+		It("should drop events beyond the configured rate", func() {
+			settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{
+				InstanceEventBus:        lo.ToPtr(eventBus),
+				InstanceEventsPerMinute: lo.ToPtr(2),
+			})
+			ctx = settingsStore.InjectSettings(ctx)
+			publisher := NewInstanceEventPublisher(fakeEventBridgeAPI)
+			for i := 0; i < 5; i++ {
+				publisher.PublishLaunch(ctx, node, instance)
+			}
+			Expect(fakeEventBridgeAPI.PutEventsBehavior.Calls()).To(Equal(2))
+		})
+		It("should not publish events that don't match the schema", func() {
+			publisher := NewInstanceEventPublisher(fakeEventBridgeAPI)
+			publisher.PublishTermination(ctx, node, "")
+			node.Labels[v1alpha5.LabelCapacityType] = "reserved"
+			publisher.PublishTermination(ctx, node, aws.StringValue(instance.InstanceId))
+			Expect(fakeEventBridgeAPI.PutEventsBehavior.Calls()).To(Equal(0))
+		})
+		It("should publish the event detail as a schema of known fields", func() {
+			NewInstanceEventPublisher(fakeEventBridgeAPI).PublishLaunch(ctx, node, instance)
+			Expect(fakeEventBridgeAPI.PutEventsBehavior.CalledWithInput.Len()).To(Equal(1))
+			fields := map[string]interface{}{}
+			input := fakeEventBridgeAPI.PutEventsBehavior.CalledWithInput.Pop()
+			Expect(json.Unmarshal([]byte(aws.StringValue(input.Entries[0].Detail)), &fields)).To(Succeed())
+			Expect(lo.Keys(fields)).To(ConsistOf("time", "action", "cluster", "node", "instanceID", "instanceType",
+				"capacityType", "zone"))
+		})
+	})
+})
@@ -40,6 +40,7 @@ import (
 
 const (
 	memoryAvailable = "memory.available"
+	nodefsAvailable = "nodefs.available"
 )
 
 var (
@@ -69,7 +70,7 @@ func NewInstanceType(ctx context.Context, info *ec2.InstanceTypeInfo, kc *v1alph
 
 	// Precompute to minimize memory/compute overhead
 	instanceType.resources = instanceType.computeResources(awssettings.FromContext(ctx).EnablePodENI)
-	instanceType.overhead = instanceType.computeOverhead(awssettings.FromContext(ctx).VMMemoryOverheadPercent, kc)
+	instanceType.overhead = instanceType.computeOverhead(awssettings.FromContext(ctx).VMMemoryOverheadPercent, kc, awssettings.FromContext(ctx).ReservedHeadroom)
 	instanceType.requirements = instanceType.computeRequirements()
 	return instanceType
 }
@@ -119,6 +120,13 @@ func (i *InstanceType) computeRequirements() scheduling.Requirements {
 		scheduling.NewRequirement(v1alpha1.LabelInstanceGPUManufacturer, v1.NodeSelectorOpDoesNotExist),
 		scheduling.NewRequirement(v1alpha1.LabelInstanceGPUCount, v1.NodeSelectorOpDoesNotExist),
 		scheduling.NewRequirement(v1alpha1.LabelInstanceGPUMemory, v1.NodeSelectorOpDoesNotExist),
+		scheduling.NewRequirement(v1alpha1.LabelInstancePlacementGroupStrategy, v1.NodeSelectorOpDoesNotExist),
+		scheduling.NewRequirement(v1alpha1.LabelInstanceTPMSupport, v1.NodeSelectorOpDoesNotExist),
+		scheduling.NewRequirement(v1alpha1.LabelInstanceEBSBandwidth, v1.NodeSelectorOpDoesNotExist),
+		scheduling.NewRequirement(v1alpha1.LabelInstanceNetworkCards, v1.NodeSelectorOpDoesNotExist),
+		scheduling.NewRequirement(v1alpha1.LabelInstanceMaxENIs, v1.NodeSelectorOpDoesNotExist),
+		scheduling.NewRequirement(v1alpha1.LabelInstanceIPsPerENI, v1.NodeSelectorOpDoesNotExist),
+		scheduling.NewRequirement(v1alpha1.LabelInstanceDedicatedHostSupport, v1.NodeSelectorOpDoesNotExist),
 		scheduling.NewRequirement(v1alpha1.LabelInstanceHypervisor, v1.NodeSelectorOpIn, aws.StringValue(i.Hypervisor)),
 	)
 	// Instance Type Labels
@@ -132,9 +140,41 @@ func (i *InstanceType) computeRequirements() scheduling.Requirements {
 		requirements.Get(v1alpha1.LabelInstanceFamily).Insert(instanceTypeParts[0])
 		requirements.Get(v1alpha1.LabelInstanceSize).Insert(instanceTypeParts[1])
 	}
+	// Virtualization Types, which must be compatible with the virtualization type of the AMI
+	if len(i.SupportedVirtualizationTypes) > 0 {
+		requirements.Add(scheduling.NewRequirement(v1alpha1.LabelInstanceVirtualizationType, v1.NodeSelectorOpIn, aws.StringValueSlice(i.SupportedVirtualizationTypes)...))
+	}
+	// Boot Modes, which must be compatible with the boot mode of the AMI (e.g. UEFI only images)
+	if len(i.SupportedBootModes) > 0 {
+		requirements.Add(scheduling.NewRequirement(v1alpha1.LabelInstanceBootMode, v1.NodeSelectorOpIn, aws.StringValueSlice(i.SupportedBootModes)...))
+	}
 	if i.InstanceStorageInfo != nil && aws.StringValue(i.InstanceStorageInfo.NvmeSupport) != ec2.EphemeralNvmeSupportUnsupported {
 		requirements[v1alpha1.LabelInstanceLocalNVME].Insert(fmt.Sprint(aws.Int64Value(i.InstanceStorageInfo.TotalSizeInGB)))
 	}
+	if i.PlacementGroupInfo != nil {
+		requirements.Get(v1alpha1.LabelInstancePlacementGroupStrategy).Insert(aws.StringValueSlice(i.PlacementGroupInfo.SupportedStrategies)...)
+	}
+	if supportsNitroTPM(i.InstanceTypeInfo) {
+		requirements.Get(v1alpha1.LabelInstanceTPMSupport).Insert(ec2.TpmSupportValuesV20)
+	}
+	// EBS Bandwidth in Mbps, which instance types that aren't EBS optimized don't report
+	if i.EbsInfo != nil && i.EbsInfo.EbsOptimizedInfo != nil && i.EbsInfo.EbsOptimizedInfo.MaximumBandwidthInMbps != nil {
+		requirements.Get(v1alpha1.LabelInstanceEBSBandwidth).Insert(fmt.Sprint(aws.Int64Value(i.EbsInfo.EbsOptimizedInfo.MaximumBandwidthInMbps)))
+	}
+	// Dedicated Hosts, which instance types must support to run on hosts that are allocated for licenses by socket or core
+	if aws.BoolValue(i.DedicatedHostsSupported) {
+		requirements.Get(v1alpha1.LabelInstanceDedicatedHostSupport).Insert("true")
+	}
+	// Network Interfaces, which are the same limits that the ENI limited max pods are calculated from
+	if i.NetworkInfo != nil {
+		if i.NetworkInfo.MaximumNetworkCards != nil {
+			requirements.Get(v1alpha1.LabelInstanceNetworkCards).Insert(fmt.Sprint(aws.Int64Value(i.NetworkInfo.MaximumNetworkCards)))
+		}
+		if i.NetworkInfo.MaximumNetworkInterfaces != nil && i.NetworkInfo.Ipv4AddressesPerInterface != nil {
+			requirements.Get(v1alpha1.LabelInstanceMaxENIs).Insert(fmt.Sprint(aws.Int64Value(i.NetworkInfo.MaximumNetworkInterfaces)))
+			requirements.Get(v1alpha1.LabelInstanceIPsPerENI).Insert(fmt.Sprint(aws.Int64Value(i.NetworkInfo.Ipv4AddressesPerInterface)))
+		}
+	}
 	// GPU Labels
 	if i.GpuInfo != nil && len(i.GpuInfo.Gpus) == 1 {
 		gpu := i.GpuInfo.Gpus[0]
@@ -178,8 +218,13 @@ func (i *InstanceType) memory() *resource.Quantity {
 	)
 }
 
-// Setting ephemeral-storage to be either the default value or what is defined in blockDeviceMappings
+// Setting ephemeral-storage to be either the default value, what is defined in blockDeviceMappings or, when the
+// NVMe instance store backs the kubelet, the size of the instance store
 func (i *InstanceType) ephemeralStorage() *resource.Quantity {
+	if aws.StringValue(i.provider.InstanceStorePolicy) == v1alpha1.InstanceStorePolicyRAID0 &&
+		i.InstanceStorageInfo != nil && aws.StringValue(i.InstanceStorageInfo.NvmeSupport) != ec2.EphemeralNvmeSupportUnsupported {
+		return resources.Quantity(fmt.Sprintf("%dG", aws.Int64Value(i.InstanceStorageInfo.TotalSizeInGB)))
+	}
 	if len(i.provider.BlockDeviceMappings) != 0 {
 		if aws.StringValue(i.provider.AMIFamily) == v1alpha1.AMIFamilyCustom {
 			// For Custom AMIFamily, use the volume size of the last defined block device mapping.
@@ -244,12 +289,14 @@ func (i *InstanceType) awsNeurons() *resource.Quantity {
 	return resources.Quantity(fmt.Sprint(count))
 }
 
-func (i *InstanceType) computeOverhead(vmMemOverhead float64, kc *v1alpha5.KubeletConfiguration) v1.ResourceList {
+// computeOverhead sums the kubelet reservations, the eviction threshold and the memory of the VM with the reserved
+// headroom, which isn't reserved on the node but keeps the scheduler from packing it to the edge
+func (i *InstanceType) computeOverhead(vmMemOverhead float64, kc *v1alpha5.KubeletConfiguration, headroom v1.ResourceList) v1.ResourceList {
 	srr := i.systemReservedResources(kc)
 	krr := i.kubeReservedResources(kc)
 	misc := i.miscResources(vmMemOverhead)
 	et := i.evictionThreshold(kc, misc[v1.ResourceMemory])
-	overhead := resources.Merge(srr, krr, et, misc)
+	overhead := resources.Merge(srr, krr, et, misc, headroom)
 
 	return overhead
 }
@@ -261,6 +308,14 @@ func (i *InstanceType) eniLimitedPods() int64 {
 	return *i.NetworkInfo.MaximumNetworkInterfaces*(*i.NetworkInfo.Ipv4AddressesPerInterface-1) + 2
 }
 
+// With prefix delegation, each secondary address of an ENI is a /28 prefix of 16 addresses instead. The number of
+// pods is capped at 110 on instance types with fewer than 30 vCPUs and at 250 otherwise.
+// https://github.com/awslabs/amazon-eks-ami/blob/master/files/max-pods-calculator.sh
+func (i *InstanceType) prefixDelegationPods() int64 {
+	pods := *i.NetworkInfo.MaximumNetworkInterfaces*((*i.NetworkInfo.Ipv4AddressesPerInterface-1)*16) + 2
+	return lo.Min([]int64{pods, lo.Ternary(ptr.Int64Value(i.VCpuInfo.DefaultVCpus) < 30, int64(110), int64(250))})
+}
+
 func (i *InstanceType) systemReservedResources(kc *v1alpha5.KubeletConfiguration) v1.ResourceList {
 	// default system-reserved resources: https://kubernetes.io/docs/tasks/administer-cluster/reserve-compute-resources/#system-reserved
 	resources := v1.ResourceList{
@@ -334,19 +389,29 @@ func (i *InstanceType) evictionThreshold(kc *v1alpha5.KubeletConfiguration, vmMe
 		evictionSignals = append(evictionSignals, kc.EvictionSoft)
 	}
 
+	// The node's capacity that percentage thresholds of each signal are relative to
+	totalMemory := i.resources.Memory().DeepCopy()
+	totalMemory.Sub(vmMemoryOverhead)
+	capacities := map[string]struct {
+		name     v1.ResourceName
+		quantity resource.Quantity
+	}{
+		memoryAvailable: {name: v1.ResourceMemory, quantity: totalMemory},
+		nodefsAvailable: {name: v1.ResourceEphemeralStorage, quantity: i.resources.StorageEphemeral().DeepCopy()},
+	}
 	for _, m := range evictionSignals {
 		temp := v1.ResourceList{}
-		if v, ok := m[memoryAvailable]; ok {
+		for signal, capacity := range capacities {
+			v, ok := m[signal]
+			if !ok {
+				continue
+			}
 			if strings.HasSuffix(v, "%") {
-				p := mustParsePercentage(v)
-
-				// Calculation is node.capacity * evictionHard[memory.available] if percentage
+				// Calculation is node.capacity * evictionHard[signal] if percentage
 				// From https://kubernetes.io/docs/concepts/scheduling-eviction/node-pressure-eviction/#eviction-signals
-				totalAllocatable := i.resources.Memory().DeepCopy()
-				totalAllocatable.Sub(vmMemoryOverhead)
-				temp[v1.ResourceMemory] = resource.MustParse(fmt.Sprint(math.Ceil(float64(totalAllocatable.Value()) / 100 * p)))
+				temp[capacity.name] = resource.MustParse(fmt.Sprint(math.Ceil(float64(capacity.quantity.Value()) / 100 * mustParsePercentage(v))))
 			} else {
-				temp[v1.ResourceMemory] = resource.MustParse(v)
+				temp[capacity.name] = resource.MustParse(v)
 			}
 		}
 		override = resources.MaxResources(override, temp)
@@ -368,6 +433,8 @@ func (i *InstanceType) computeMaxPods(ctx context.Context, kc *v1alpha5.KubeletC
 	switch {
 	case kc != nil && kc.MaxPods != nil:
 		mp = ptr.Int64(int64(ptr.Int32Value(kc.MaxPods)))
+	case lo.FromPtr(i.provider.PrefixDelegation):
+		mp = ptr.Int64(i.prefixDelegationPods())
 	case !awssettings.FromContext(ctx).EnableENILimitedPodDensity:
 		mp = ptr.Int64(110)
 	default:
@@ -400,3 +467,11 @@ func mustParsePercentage(v string) float64 {
 	}
 	return p
 }
+
+// supportsNitroTPM returns true if instances of the instance type can have a NitroTPM. DescribeInstanceTypes only
+// reports NitroTPM support in newer versions of the EC2 API, so it's derived from its prerequisites instead: the
+// Nitro hypervisor, which excludes bare metal instances, and UEFI boot.
+func supportsNitroTPM(info *ec2.InstanceTypeInfo) bool {
+	return aws.StringValue(info.Hypervisor) == ec2.InstanceTypeHypervisorNitro && !aws.BoolValue(info.BareMetal) &&
+		lo.Contains(aws.StringValueSlice(info.SupportedBootModes), ec2.BootModeTypeUefi)
+}
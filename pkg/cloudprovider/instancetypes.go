@@ -16,8 +16,15 @@ package cloudprovider
 
 import (
 	"context"
+	_ "embed"
+	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -33,20 +40,34 @@ import (
 	"github.com/mitchellh/hashstructure/v2"
 	"github.com/patrickmn/go-cache"
 	"github.com/samber/lo"
+	"go.uber.org/multierr"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/workqueue"
 	"knative.dev/pkg/logging"
 
 	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
 	"github.com/aws/karpenter-core/pkg/cloudprovider"
+	"github.com/aws/karpenter-core/pkg/scheduling"
 	"github.com/aws/karpenter-core/pkg/utils/functional"
 	"github.com/aws/karpenter-core/pkg/utils/pretty"
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
 )
 
+//go:embed zz_generated.instancetypes.json
+var staticInstanceTypesData []byte
+
 const (
 	InstanceTypesCacheKey           = "types"
 	InstanceTypeZonesCacheKeyPrefix = "zones:"
-	InstanceTypesAndZonesCacheTTL   = 5 * time.Minute
+	// RegionalCacheKeyPrefix prefixes the cache entries of the instance types (key: region:<region>:types) and zonal
+	// offerings (key: region:<region>:zones) that are discovered for each discovery region by RegionalOfferings
+	RegionalCacheKeyPrefix        = "region:"
+	InstanceTypesAndZonesCacheTTL = 5 * time.Minute
+	// DefaultGenerationPriceTolerancePercent is the price tolerance of a generation preference that doesn't set one
+	DefaultGenerationPriceTolerancePercent = 5
+	// fallbackPriceMemoryScale is larger than the memory of any instance type in MiB
+	fallbackPriceMemoryScale = 1e8
 )
 
 type InstanceTypeProvider struct {
@@ -58,9 +79,30 @@ type InstanceTypeProvider struct {
 	// Has one cache entry for all the instance types (key: InstanceTypesCacheKey)
 	// Has one cache entry for all the zones for each subnet selector (key: InstanceTypesZonesCacheKeyPrefix:<hash_of_selector>)
 	// Values cached *before* considering insufficient capacity errors from the unavailableOfferings cache.
-	cache                *cache.Cache
+	// Prices aren't cached, offerings are priced whenever instance types are resolved, so pricing updates apply to the
+	// next resolution without invalidating the cache and fetching the instance types and zones from EC2 again.
+	cache                *awscache.Metered
 	unavailableOfferings *awscache.UnavailableOfferings
 	cm                   *pretty.ChangeMonitor
+	// regions are the other regions whose offerings are discovered by RegionalOfferings, keyed by region
+	regions map[string]*regionalProviders
+}
+
+// regionalProviders are the EC2 client and pricing of a region whose offerings are discovered
+type regionalProviders struct {
+	ec2api ec2iface.EC2API
+	// newPricingProvider creates the pricing of the region when its offerings are first discovered, so that the
+	// prices of a region aren't polled until something asks for its offerings
+	newPricingProvider func() *PricingProvider
+	pricingProvider    *PricingProvider
+}
+
+// pricing returns the pricing of the region, creating it on first use. Callers must hold the InstanceTypeProvider lock.
+func (r *regionalProviders) pricing() *PricingProvider {
+	if r.pricingProvider == nil {
+		r.pricingProvider = r.newPricingProvider()
+	}
+	return r.pricingProvider
 }
 
 func NewInstanceTypeProvider(ctx context.Context, sess *session.Session, ec2api ec2iface.EC2API, subnetProvider *SubnetProvider,
@@ -75,16 +117,48 @@ func NewInstanceTypeProvider(ctx context.Context, sess *session.Session, ec2api
 			ec2api,
 			*sess.Config.Region,
 			awssettings.FromContext(ctx).IsolatedVPC,
+			awssettings.FromContext(ctx).PricingRefreshPeriod.Duration,
 			startAsync,
 		),
-		cache:                cache.New(InstanceTypesAndZonesCacheTTL, awscontext.CacheCleanupInterval),
+		cache:                awscache.NewMetered(awscache.InstanceTypesCacheName, cache.New(InstanceTypesAndZonesCacheTTL, awscontext.CacheCleanupInterval)),
 		unavailableOfferings: unavailableOfferingsCache,
 		cm:                   pretty.NewChangeMonitor(),
+		regions:              newRegionalProviders(ctx, sess, startAsync),
 	}
 }
 
+// newRegionalProviders creates clients for each of the instance type discovery regions other than the session's. The
+// pricing of each region is created lazily by RegionalOfferings.
+func newRegionalProviders(ctx context.Context, sess *session.Session, startAsync <-chan struct{}) map[string]*regionalProviders {
+	regions := map[string]*regionalProviders{}
+	for _, region := range awssettings.FromContext(ctx).InstanceTypeDiscoveryRegions {
+		if region == *sess.Config.Region {
+			continue
+		}
+		region, ec2api := region, ec2.New(sess, &aws.Config{Region: aws.String(region)})
+		regions[region] = &regionalProviders{
+			ec2api: ec2api,
+			newPricingProvider: func() *PricingProvider {
+				return NewPricingProvider(
+					logging.WithLogger(ctx, logging.FromContext(ctx).With("region", region)),
+					NewPricingAPI(sess, region),
+					ec2api,
+					region,
+					awssettings.FromContext(ctx).IsolatedVPC,
+					awssettings.FromContext(ctx).PricingRefreshPeriod.Duration,
+					startAsync,
+				)
+			},
+		}
+	}
+	return regions
+}
+
 // Get all instance type options
 func (p *InstanceTypeProvider) Get(ctx context.Context, provider *v1alpha1.AWS, kc *v1alpha5.KubeletConfiguration) ([]cloudprovider.InstanceType, error) {
+	if _, _, err := maxPrice(provider); err != nil {
+		return nil, err
+	}
 	p.Lock()
 	defer p.Unlock()
 	// Get InstanceTypes from EC2
@@ -100,13 +174,137 @@ func (p *InstanceTypeProvider) Get(ctx context.Context, provider *v1alpha1.AWS,
 	var result []cloudprovider.InstanceType
 
 	for _, i := range instanceTypes {
-		instanceTypeName := aws.StringValue(i.InstanceType)
-		instanceType := NewInstanceType(ctx, i, kc, p.region, provider, p.createOfferings(ctx, i, instanceTypeZones[instanceTypeName]))
-		result = append(result, instanceType)
+		if p.exclusion(ctx, i, provider) != "" {
+			continue
+		}
+		result = append(result, p.newInstanceType(ctx, i, provider, kc, instanceTypeZones))
 	}
 	return result, nil
 }
 
+// Explain returns the instance type with the given name as Get would, or why Get doesn't return it
+func (p *InstanceTypeProvider) Explain(ctx context.Context, provider *v1alpha1.AWS, kc *v1alpha5.KubeletConfiguration, name string) (*InstanceType, string, error) {
+	p.Lock()
+	defer p.Unlock()
+	instanceTypes, err := p.getInstanceTypes(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	info, ok := instanceTypes[name]
+	if !ok {
+		return nil, fmt.Sprintf("isn't available in region %s or isn't supported, e.g. since it has FPGAs", p.region), nil
+	}
+	if reason := p.exclusion(ctx, info, provider); reason != "" {
+		return nil, reason, nil
+	}
+	if _, _, err := maxPrice(provider); err != nil {
+		return nil, "", err
+	}
+	instanceTypeZones, err := p.getInstanceTypeZones(ctx, provider)
+	if err != nil {
+		return nil, "", err
+	}
+	return p.newInstanceType(ctx, info, provider, kc, instanceTypeZones), "", nil
+}
+
+// exclusionReasons returns why Get returns no instance types for the provider, from the filters that are configured
+// and exclude instance types
+func (p *InstanceTypeProvider) exclusionReasons(ctx context.Context, provider *v1alpha1.AWS) ([]string, error) {
+	p.Lock()
+	defer p.Unlock()
+	instanceTypes, err := p.getInstanceTypes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(instanceTypes) == 0 {
+		var reasons []string
+		if len(awssettings.FromContext(ctx).InstanceTypeFilters) != 0 {
+			reasons = append(reasons, "is excluded by the aws.instanceTypeFilters setting")
+		}
+		if awssettings.FromContext(ctx).InstanceTypeDataSource == awssettings.StaticInstanceTypeData {
+			reasons = append(reasons, "isn't in the static instance type dataset")
+		}
+		return reasons, nil
+	}
+	reasons := sets.NewString()
+	for _, info := range instanceTypes {
+		if reason := p.exclusion(ctx, info, provider); reason != "" {
+			reasons.Insert(reason)
+		}
+	}
+	return reasons.List(), nil
+}
+
+// exclusion returns why the instance type is never returned for the provider, regardless of the requirements of a
+// provisioner, or an empty string if it isn't excluded
+func (p *InstanceTypeProvider) exclusion(ctx context.Context, info *ec2.InstanceTypeInfo, provider *v1alpha1.AWS) string {
+	if p.excluded(ctx, aws.StringValue(info.InstanceType)) {
+		return "is excluded by the aws.excludedInstanceTypes setting"
+	}
+	if !supportsPlacementGroup(info, provider.PlacementGroup) {
+		return fmt.Sprintf("doesn't support the %q strategy of placement group %s", provider.PlacementGroup.Strategy, provider.PlacementGroup.Name)
+	}
+	if lo.FromPtr(provider.RequireNitroTPM) && !supportsNitroTPM(info) {
+		return "doesn't support NitroTPM, which requireNitroTPM requires"
+	}
+	return ""
+}
+
+func (p *InstanceTypeProvider) newInstanceType(ctx context.Context, info *ec2.InstanceTypeInfo, provider *v1alpha1.AWS, kc *v1alpha5.KubeletConfiguration,
+	instanceTypeZones map[string]sets.String) *InstanceType {
+	offerings := p.createOfferings(ctx, p.pricingProvider, info, instanceTypeZones[aws.StringValue(info.InstanceType)])
+	// Offerings of capacity types that the node template doesn't allow are dropped, so that they're never scheduled
+	if len(provider.CapacityTypes) != 0 {
+		offerings = lo.Filter(offerings, func(offering cloudprovider.Offering, _ int) bool {
+			return lo.Contains(provider.CapacityTypes, offering.CapacityType)
+		})
+	}
+	// Offerings above the maximum price of the node template are dropped as well, so that nodes never cost more
+	// Get and Explain fail on a malformed maxPrice before getting here
+	if price, ok, _ := maxPrice(provider); ok {
+		offerings = lo.Filter(offerings, func(offering cloudprovider.Offering, _ int) bool {
+			return offering.Price <= price
+		})
+	}
+	instanceType := NewInstanceType(ctx, info, kc, p.region, provider, offerings)
+	p.applyCapacityOverrides(ctx, instanceType)
+	return instanceType
+}
+
+// supportsPlacementGroup returns true if instances of the instance type can be launched into the placement group
+func supportsPlacementGroup(instanceType *ec2.InstanceTypeInfo, placementGroup *v1alpha1.PlacementGroup) bool {
+	if placementGroup == nil {
+		return true
+	}
+	return instanceType.PlacementGroupInfo != nil && lo.Contains(aws.StringValueSlice(instanceType.PlacementGroupInfo.SupportedStrategies), placementGroup.Strategy)
+}
+
+// applyCapacityOverrides replaces the advertised capacity of the instance type with any override configured for it,
+// preferring an override for the instance type name over one for its family. Overrides that exceed the physical
+// capacity of the instance type are ignored.
+func (p *InstanceTypeProvider) applyCapacityOverrides(ctx context.Context, instanceType *InstanceType) {
+	overrides := awssettings.FromContext(ctx).CapacityOverrides
+	override, ok := overrides[instanceType.Name()]
+	if !ok {
+		if override, ok = overrides[strings.Split(instanceType.Name(), ".")[0]]; !ok {
+			return
+		}
+	}
+	for resourceName, quantity := range override {
+		physical, ok := instanceType.resources[resourceName]
+		if !ok {
+			continue
+		}
+		if quantity.Cmp(physical) > 0 {
+			if p.cm.HasChanged(fmt.Sprintf("capacity-override/%s/%s", instanceType.Name(), resourceName), quantity.String()) {
+				logging.FromContext(ctx).With("instance-type", instanceType.Name()).Errorf("ignoring %s capacity override %s, exceeds physical capacity %s", resourceName, quantity.String(), physical.String())
+			}
+			continue
+		}
+		instanceType.resources[resourceName] = quantity
+	}
+}
+
 func (p *InstanceTypeProvider) LivenessProbe(req *http.Request) error {
 	p.Lock()
 	//nolint: staticcheck
@@ -120,8 +318,14 @@ func (p *InstanceTypeProvider) LivenessProbe(req *http.Request) error {
 	return nil
 }
 
-func (p *InstanceTypeProvider) createOfferings(ctx context.Context, instanceType *ec2.InstanceTypeInfo, zones sets.String) []cloudprovider.Offering {
+func (p *InstanceTypeProvider) createOfferings(ctx context.Context, pricingProvider *PricingProvider, instanceType *ec2.InstanceTypeInfo, zones sets.String) []cloudprovider.Offering {
 	offerings := []cloudprovider.Offering{}
+	// Without any prices, offerings would all be unavailable, so they're priced by their size instead to keep
+	// provisioning working, at the expense of cost optimization
+	hasPrices := pricingProvider.HasPrices()
+	if p.cm.HasChanged("pricing-unavailable/"+pricingProvider.region, hasPrices) && !hasPrices {
+		logging.FromContext(ctx).With("region", pricingProvider.region).Warnf("No pricing data is available, ranking instance types by vCPUs and memory instead of price, cost optimization is degraded")
+	}
 	for zone := range zones {
 		// while usage classes should be a distinct set, there's no guarantee of that
 		for capacityType := range sets.NewString(aws.StringValueSlice(instanceType.SupportedUsageClasses)...) {
@@ -131,13 +335,16 @@ func (p *InstanceTypeProvider) createOfferings(ctx context.Context, instanceType
 			var ok bool
 			switch capacityType {
 			case ec2.UsageClassTypeSpot:
-				price, ok = p.pricingProvider.SpotPrice(*instanceType.InstanceType, zone)
+				price, ok = pricingProvider.SpotPrice(*instanceType.InstanceType, zone)
 			case ec2.UsageClassTypeOnDemand:
-				price, ok = p.pricingProvider.OnDemandPrice(*instanceType.InstanceType)
+				price, ok = pricingProvider.OnDemandPrice(*instanceType.InstanceType)
 			default:
 				logging.FromContext(ctx).Errorf("Received unknown capacity type %s for instance type %s", capacityType, *instanceType.InstanceType)
 				continue
 			}
+			if !hasPrices {
+				price, ok = fallbackPrice(instanceType), true
+			}
 			available := !isUnavailable && ok
 			offerings = append(offerings, cloudprovider.Offering{
 				Zone:         zone,
@@ -150,12 +357,17 @@ func (p *InstanceTypeProvider) createOfferings(ctx context.Context, instanceType
 	return offerings
 }
 
+// fallbackPrice is the price of an instance type when no prices are known at all, which ranks instance types by their
+// vCPUs and then by their memory. Memory is scaled below a single vCPU so that it only breaks ties.
+func fallbackPrice(instanceType *ec2.InstanceTypeInfo) float64 {
+	return float64(aws.Int64Value(instanceType.VCpuInfo.DefaultVCpus)) + float64(aws.Int64Value(instanceType.MemoryInfo.SizeInMiB))/fallbackPriceMemoryScale
+}
+
 func (p *InstanceTypeProvider) getInstanceTypeZones(ctx context.Context, provider *v1alpha1.AWS) (map[string]sets.String, error) {
-	subnetSelectorHash, err := hashstructure.Hash(provider.SubnetSelector, hashstructure.FormatV2, nil)
+	cacheKey, err := instanceTypeZonesCacheKey(provider)
 	if err != nil {
-		return nil, fmt.Errorf("failed to hash the subnet selector: %w", err)
+		return nil, err
 	}
-	cacheKey := fmt.Sprintf("%s%016x", InstanceTypeZonesCacheKeyPrefix, subnetSelectorHash)
 	if cached, ok := p.cache.Get(cacheKey); ok {
 		return cached.(map[string]sets.String), nil
 	}
@@ -165,25 +377,38 @@ func (p *InstanceTypeProvider) getInstanceTypeZones(ctx context.Context, provide
 	if err != nil {
 		return nil, err
 	}
-	zones := sets.NewString(lo.Map(subnets, func(subnet *ec2.Subnet, _ int) string {
-		return aws.StringValue(subnet.AvailabilityZone)
-	})...)
+	// Offerings may be requested by zone name or zone ID, but are always recorded against the zone name since that's
+	// what subnets are matched to and nodes are labeled with
+	locationType := awssettings.FromContext(ctx).InstanceTypeOfferingsLocationType
+	zones := lo.SliceToMap(subnets, func(subnet *ec2.Subnet) (string, string) {
+		if locationType == ec2.LocationTypeAvailabilityZoneId {
+			return aws.StringValue(subnet.AvailabilityZoneId), aws.StringValue(subnet.AvailabilityZone)
+		}
+		return aws.StringValue(subnet.AvailabilityZone), aws.StringValue(subnet.AvailabilityZone)
+	})
 
-	// Get offerings from EC2
+	// Get offerings from EC2, fetching the offerings for each zone in parallel
 	instanceTypeZones := map[string]sets.String{}
-	if err := p.ec2api.DescribeInstanceTypeOfferingsPagesWithContext(ctx, &ec2.DescribeInstanceTypeOfferingsInput{LocationType: aws.String("availability-zone")},
-		func(output *ec2.DescribeInstanceTypeOfferingsOutput, lastPage bool) bool {
-			for _, offering := range output.InstanceTypeOfferings {
-				if zones.Has(aws.StringValue(offering.Location)) {
-					if _, ok := instanceTypeZones[aws.StringValue(offering.InstanceType)]; !ok {
-						instanceTypeZones[aws.StringValue(offering.InstanceType)] = sets.NewString()
-					}
-					instanceTypeZones[aws.StringValue(offering.InstanceType)].Insert(aws.StringValue(offering.Location))
-				}
+	var mu sync.Mutex
+	locations := lo.Keys(zones)
+	errs := make([]error, len(locations))
+	workqueue.ParallelizeUntil(ctx, awssettings.FromContext(ctx).InstanceTypeOfferingsParallelism, len(locations), func(i int) {
+		offerings, err := p.getZoneInstanceTypeOfferings(ctx, p.ec2api, locationType, locations[i])
+		if err != nil {
+			errs[i] = err
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, instanceType := range offerings {
+			if _, ok := instanceTypeZones[instanceType]; !ok {
+				instanceTypeZones[instanceType] = sets.NewString()
 			}
-			return true
-		}); err != nil {
-		return nil, fmt.Errorf("describing instance type zone offerings, %w", err)
+			instanceTypeZones[instanceType].Insert(zones[locations[i]])
+		}
+	})
+	if err = multierr.Combine(errs...); err != nil {
+		return nil, err
 	}
 	if p.cm.HasChanged("zonal-offerings", provider.SubnetSelector) {
 		logging.FromContext(ctx).Debugf("Discovered EC2 instance types zonal offerings for subnets %s", pretty.Concise(provider.SubnetSelector))
@@ -192,23 +417,212 @@ func (p *InstanceTypeProvider) getInstanceTypeZones(ctx context.Context, provide
 	return instanceTypeZones, nil
 }
 
-// getInstanceTypes retrieves all instance types from the ec2 DescribeInstanceTypes API using some opinionated filters
-func (p *InstanceTypeProvider) getInstanceTypes(ctx context.Context) (map[string]*ec2.InstanceTypeInfo, error) {
-	if cached, ok := p.cache.Get(InstanceTypesCacheKey); ok {
-		return cached.(map[string]*ec2.InstanceTypeInfo), nil
+// CachedOfferings returns the offerings of each instance type using only cached data, so that it never calls EC2. The
+// zones are those discovered for the provider's subnet selector or, if provider is nil, for any subnet selector. It
+// returns false if the instance types or their zonal offerings haven't been discovered yet.
+func (p *InstanceTypeProvider) CachedOfferings(ctx context.Context, provider *v1alpha1.AWS) (map[string][]cloudprovider.Offering, bool, error) {
+	cached, ok := p.cache.Get(InstanceTypesCacheKey)
+	if !ok {
+		return nil, false, nil
 	}
-	instanceTypes := map[string]*ec2.InstanceTypeInfo{}
-	if err := p.ec2api.DescribeInstanceTypesPagesWithContext(ctx, &ec2.DescribeInstanceTypesInput{
+	instanceTypes := cached.(map[string]*ec2.InstanceTypeInfo)
+	var zonesCacheKey string
+	if provider != nil {
+		var err error
+		if zonesCacheKey, err = instanceTypeZonesCacheKey(provider); err != nil {
+			return nil, false, err
+		}
+	}
+	found := false
+	instanceTypeZones := map[string]sets.String{}
+	for key, item := range p.cache.Items() {
+		if !strings.HasPrefix(key, InstanceTypeZonesCacheKeyPrefix) || (provider != nil && key != zonesCacheKey) {
+			continue
+		}
+		found = true
+		for instanceType, zones := range item.Object.(map[string]sets.String) {
+			instanceTypeZones[instanceType] = zones.Union(instanceTypeZones[instanceType])
+		}
+	}
+	if !found {
+		return nil, false, nil
+	}
+	result := map[string][]cloudprovider.Offering{}
+	for name, instanceType := range instanceTypes {
+		if p.excluded(ctx, name) {
+			continue
+		}
+		result[name] = p.createOfferings(ctx, p.pricingProvider, instanceType, instanceTypeZones[name])
+	}
+	return result, true, nil
+}
+
+// InvalidateInstanceType marks the offerings of the instance type in the zone unavailable and removes the zone from its
+// cached zonal offerings, so that a capacity constrained offering isn't launched until its offerings are discovered
+// again, e.g. once an insufficient capacity error is observed. The offerings are marked unavailable even if the
+// instance type isn't cached as offered in the zone, so that an expired cache entry can't rediscover them.
+func (p *InstanceTypeProvider) InvalidateInstanceType(ctx context.Context, instanceType, zone string) {
+	p.Lock()
+	defer p.Unlock()
+	for _, capacityType := range []string{v1alpha5.CapacityTypeSpot, v1alpha5.CapacityTypeOnDemand} {
+		p.unavailableOfferings.MarkUnavailable(ctx, "InstanceTypeInvalidated", instanceType, zone, capacityType)
+	}
+	for key, item := range p.cache.Items() {
+		if !strings.HasPrefix(key, InstanceTypeZonesCacheKeyPrefix) {
+			continue
+		}
+		instanceTypeZones := item.Object.(map[string]sets.String)
+		if !instanceTypeZones[instanceType].Has(zone) {
+			continue
+		}
+		// CachedOfferings reads the cached zones without the lock, so they're replaced rather than modified, keeping
+		// the expiration of the entry so that the zones are still discovered again when it expires
+		ttl := time.Until(time.Unix(0, item.Expiration))
+		if ttl <= 0 {
+			continue
+		}
+		updated := lo.Assign(instanceTypeZones)
+		updated[instanceType] = instanceTypeZones[instanceType].Difference(sets.NewString(zone))
+		p.cache.Set(key, updated, ttl)
+	}
+}
+
+// RegionalOfferings returns the offerings of each instance type in every available zone of one of the configured
+// instance type discovery regions, priced with the pricing of the region. The offerings of a region are discovered when
+// they're first requested and cached like those of the controller's region. It returns false if the region isn't a
+// discovery region.
+func (p *InstanceTypeProvider) RegionalOfferings(ctx context.Context, region string) (map[string][]cloudprovider.Offering, bool, error) {
+	p.Lock()
+	defer p.Unlock()
+	providers, ok := p.regions[region]
+	if !ok {
+		return nil, false, nil
+	}
+	instanceTypes, err := p.getRegionInstanceTypes(ctx, providers.ec2api, region, regionalCacheKey(region, "types"))
+	if err != nil {
+		return nil, false, fmt.Errorf("getting instance types in %s, %w", region, err)
+	}
+	instanceTypeZones, err := p.getRegionInstanceTypeZones(ctx, providers.ec2api, region)
+	if err != nil {
+		return nil, false, fmt.Errorf("getting instance type offerings in %s, %w", region, err)
+	}
+	result := map[string][]cloudprovider.Offering{}
+	for name, instanceType := range instanceTypes {
+		if p.excluded(ctx, name) {
+			continue
+		}
+		result[name] = p.createOfferings(ctx, providers.pricing(), instanceType, instanceTypeZones[name])
+	}
+	return result, true, nil
+}
+
+// getRegionInstanceTypeZones returns the zones that each instance type is offered in, across the available zones of
+// the region of the EC2 client
+func (p *InstanceTypeProvider) getRegionInstanceTypeZones(ctx context.Context, ec2api ec2iface.EC2API, region string) (map[string]sets.String, error) {
+	cacheKey := regionalCacheKey(region, "zones")
+	if cached, ok := p.cache.Get(cacheKey); ok {
+		return cached.(map[string]sets.String), nil
+	}
+	output, err := ec2api.DescribeAvailabilityZonesWithContext(ctx, &ec2.DescribeAvailabilityZonesInput{
+		Filters: []*ec2.Filter{{Name: aws.String("state"), Values: []*string{aws.String(ec2.AvailabilityZoneStateAvailable)}}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describing availability zones, %w", err)
+	}
+	locationType := awssettings.FromContext(ctx).InstanceTypeOfferingsLocationType
+	zones := lo.SliceToMap(output.AvailabilityZones, func(zone *ec2.AvailabilityZone) (string, string) {
+		if locationType == ec2.LocationTypeAvailabilityZoneId {
+			return aws.StringValue(zone.ZoneId), aws.StringValue(zone.ZoneName)
+		}
+		return aws.StringValue(zone.ZoneName), aws.StringValue(zone.ZoneName)
+	})
+	instanceTypeZones := map[string]sets.String{}
+	var mu sync.Mutex
+	locations := lo.Keys(zones)
+	errs := make([]error, len(locations))
+	workqueue.ParallelizeUntil(ctx, awssettings.FromContext(ctx).InstanceTypeOfferingsParallelism, len(locations), func(i int) {
+		offerings, err := p.getZoneInstanceTypeOfferings(ctx, ec2api, locationType, locations[i])
+		if err != nil {
+			errs[i] = err
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, instanceType := range offerings {
+			if _, ok := instanceTypeZones[instanceType]; !ok {
+				instanceTypeZones[instanceType] = sets.NewString()
+			}
+			instanceTypeZones[instanceType].Insert(zones[locations[i]])
+		}
+	})
+	if err = multierr.Combine(errs...); err != nil {
+		return nil, err
+	}
+	if p.cm.HasChanged("zonal-offerings/"+region, instanceTypeZones) {
+		logging.FromContext(ctx).With("region", region).Debugf("Discovered EC2 instance types zonal offerings for %d zones", len(zones))
+	}
+	p.cache.SetDefault(cacheKey, instanceTypeZones)
+	return instanceTypeZones, nil
+}
+
+func regionalCacheKey(region string, kind string) string {
+	return fmt.Sprintf("%s%s:%s", RegionalCacheKeyPrefix, region, kind)
+}
+
+func instanceTypeZonesCacheKey(provider *v1alpha1.AWS) (string, error) {
+	subnetSelectorHash, err := hashstructure.Hash(provider.SubnetSelector, hashstructure.FormatV2, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash the subnet selector: %w", err)
+	}
+	return fmt.Sprintf("%s%016x", InstanceTypeZonesCacheKeyPrefix, subnetSelectorHash), nil
+}
+
+// getZoneInstanceTypeOfferings returns the names of the instance types that are offered in the zone, which is a zone
+// name or a zone ID depending on the location type
+func (p *InstanceTypeProvider) getZoneInstanceTypeOfferings(ctx context.Context, ec2api ec2iface.EC2API, locationType string, zone string) ([]string, error) {
+	var instanceTypes []string
+	if err := ec2api.DescribeInstanceTypeOfferingsPagesWithContext(ctx, &ec2.DescribeInstanceTypeOfferingsInput{
+		LocationType: aws.String(locationType),
 		Filters: []*ec2.Filter{
 			{
-				Name:   aws.String("supported-virtualization-type"),
-				Values: []*string{aws.String("hvm")},
-			},
-			{
-				Name:   aws.String("processor-info.supported-architecture"),
-				Values: aws.StringSlice([]string{"x86_64", "arm64"}),
+				Name:   aws.String("location"),
+				Values: []*string{aws.String(zone)},
 			},
 		},
+	}, func(output *ec2.DescribeInstanceTypeOfferingsOutput, lastPage bool) bool {
+		for _, offering := range output.InstanceTypeOfferings {
+			instanceTypes = append(instanceTypes, aws.StringValue(offering.InstanceType))
+		}
+		return true
+	}); err != nil {
+		return nil, fmt.Errorf("describing instance type zone offerings for %s, %w", zone, err)
+	}
+	return instanceTypes, nil
+}
+
+// getInstanceTypes retrieves all instance types from the ec2 DescribeInstanceTypes API using some opinionated filters
+func (p *InstanceTypeProvider) getInstanceTypes(ctx context.Context) (map[string]*ec2.InstanceTypeInfo, error) {
+	return p.getRegionInstanceTypes(ctx, p.ec2api, p.region, InstanceTypesCacheKey)
+}
+
+// getRegionInstanceTypes retrieves the instance types of the region of the EC2 client, caching them at the key
+func (p *InstanceTypeProvider) getRegionInstanceTypes(ctx context.Context, ec2api ec2iface.EC2API, region string, cacheKey string) (map[string]*ec2.InstanceTypeInfo, error) {
+	if cached, ok := p.cache.Get(cacheKey); ok {
+		return cached.(map[string]*ec2.InstanceTypeInfo), nil
+	}
+	instanceTypes := map[string]*ec2.InstanceTypeInfo{}
+	if awssettings.FromContext(ctx).InstanceTypeDataSource == awssettings.StaticInstanceTypeData {
+		static, err := staticInstanceTypes()
+		if err != nil {
+			return nil, err
+		}
+		for _, instanceType := range static {
+			if p.filter(instanceType) && matchesDefaultFilters(instanceType) {
+				instanceTypes[aws.StringValue(instanceType.InstanceType)] = instanceType
+			}
+		}
+	} else if err := ec2api.DescribeInstanceTypesPagesWithContext(ctx, &ec2.DescribeInstanceTypesInput{
+		Filters: instanceTypeFilters(ctx),
 	}, func(page *ec2.DescribeInstanceTypesOutput, lastPage bool) bool {
 		for _, instanceType := range page.InstanceTypes {
 			if p.filter(instanceType) {
@@ -219,13 +633,167 @@ func (p *InstanceTypeProvider) getInstanceTypes(ctx context.Context) (map[string
 	}); err != nil {
 		return nil, fmt.Errorf("fetching instance types using ec2.DescribeInstanceTypes, %w", err)
 	}
-	if p.cm.HasChanged("instance-types", instanceTypes) {
-		logging.FromContext(ctx).Debugf("Discovered %d EC2 instance types", len(instanceTypes))
+	if p.cm.HasChanged("instance-types/"+region, instanceTypes) {
+		logging.FromContext(ctx).With("region", region).Debugf("Discovered %d EC2 instance types", len(instanceTypes))
 	}
-	p.cache.SetDefault(InstanceTypesCacheKey, instanceTypes)
+	p.cache.SetDefault(cacheKey, instanceTypes)
 	return instanceTypes, nil
 }
 
+// staticInstanceTypes returns the instance types of the embedded dataset, which hack/code/instancetypes_gen.go
+// generates from the DescribeInstanceTypes API without any filters
+func staticInstanceTypes() ([]*ec2.InstanceTypeInfo, error) {
+	var instanceTypes []*ec2.InstanceTypeInfo
+	if err := json.Unmarshal(staticInstanceTypesData, &instanceTypes); err != nil {
+		return nil, fmt.Errorf("unmarshaling static instance types, %w", err)
+	}
+	return instanceTypes, nil
+}
+
+// matchesDefaultFilters returns true if the instance type matches the opinionated filters of instanceTypeFilters,
+// which are applied to the static dataset since it isn't described with them
+func matchesDefaultFilters(instanceType *ec2.InstanceTypeInfo) bool {
+	return lo.Contains(aws.StringValueSlice(instanceType.SupportedVirtualizationTypes), "hvm") && instanceType.ProcessorInfo != nil &&
+		lo.Some(aws.StringValueSlice(instanceType.ProcessorInfo.SupportedArchitectures), []string{"x86_64", "arm64"})
+}
+
+// undiscoveredInstanceTypes returns the instance types that the requirements explicitly allow, but that weren't
+// discovered in the region of the controller
+func (p *InstanceTypeProvider) undiscoveredInstanceTypes(ctx context.Context, requirements scheduling.Requirements) ([]string, error) {
+	if !requirements.Has(v1.LabelInstanceTypeStable) || requirements.Get(v1.LabelInstanceTypeStable).Operator() != v1.NodeSelectorOpIn {
+		return nil, nil
+	}
+	instanceTypes, err := p.getInstanceTypes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	undiscovered := lo.Reject(requirements.Get(v1.LabelInstanceTypeStable).Values(), func(name string, _ int) bool {
+		_, ok := instanceTypes[name]
+		return ok
+	})
+	sort.Strings(undiscovered)
+	return undiscovered, nil
+}
+
+// instanceTypeFilters returns the opinionated DescribeInstanceTypes filters, followed by the aws.instanceTypeFilters
+// setting sorted by name
+func instanceTypeFilters(ctx context.Context) []*ec2.Filter {
+	filters := []*ec2.Filter{
+		{
+			Name:   aws.String("supported-virtualization-type"),
+			Values: []*string{aws.String("hvm")},
+		},
+		{
+			Name:   aws.String("processor-info.supported-architecture"),
+			Values: aws.StringSlice([]string{"x86_64", "arm64"}),
+		},
+	}
+	settingsFilters := awssettings.FromContext(ctx).InstanceTypeFilters
+	names := lo.Keys(settingsFilters)
+	sort.Strings(names)
+	for _, name := range names {
+		filters = append(filters, &ec2.Filter{Name: aws.String(name), Values: aws.StringSlice(settingsFilters[name])})
+	}
+	return filters
+}
+
+// instanceGeneration parses the category (e.g. "m") and generation (e.g. 6) from an instance type name like "m6i.large"
+func instanceGeneration(instanceType string) (category string, generation int, ok bool) {
+	parts := instanceTypeScheme.FindStringSubmatch(instanceType)
+	if len(parts) != 4 {
+		return "", 0, false
+	}
+	generation, err := strconv.Atoi(parts[3])
+	if err != nil {
+		return "", 0, false
+	}
+	return parts[1], generation, true
+}
+
+// generationWeightedPrice returns the price of an offering of an instance type, discounted by the price tolerance of
+// the preference for every generation that the instance type is newer than the oldest generation of its category
+// among the instance types. Ordering offerings by the weighted price keeps them cheapest first, except that a newer
+// generation is ordered ahead of an older one that's cheaper by less than the tolerance per generation. Prices aren't
+// weighted without a preference.
+func generationWeightedPrice(preference *v1alpha1.GenerationPreference, instanceTypes []cloudprovider.InstanceType) func(instanceType string, price float64) float64 {
+	if preference == nil {
+		return func(_ string, price float64) float64 { return price }
+	}
+	tolerance := float64(lo.FromPtrOr(preference.PriceTolerancePercent, DefaultGenerationPriceTolerancePercent)) / 100
+	oldest := map[string]int{}
+	for _, instanceType := range instanceTypes {
+		if category, generation, ok := instanceGeneration(instanceType.Name()); ok {
+			if current, found := oldest[category]; !found || generation < current {
+				oldest[category] = generation
+			}
+		}
+	}
+	return func(instanceType string, price float64) float64 {
+		category, generation, ok := instanceGeneration(instanceType)
+		if !ok {
+			return price
+		}
+		return price / math.Pow(1+tolerance, float64(generation-oldest[category]))
+	}
+}
+
+// capacityTypeWeightedPrice returns the price of an offering of a capacity type, with spot prices discounted by the
+// spot price bias so that spot and on-demand offerings are ranked by the discount that's assumed for spot rather than
+// by their raw prices. Prices aren't weighted without a bias.
+func capacityTypeWeightedPrice(bias *int64) func(capacityType string, price float64) float64 {
+	if bias == nil {
+		return func(_ string, price float64) float64 { return price }
+	}
+	discount := float64(*bias) / 100
+	return func(capacityType string, price float64) float64 {
+		if capacityType != v1alpha5.CapacityTypeSpot {
+			return price
+		}
+		return price * (1 - discount)
+	}
+}
+
+// maxPrice returns the maximum hourly price of the node template, if it has one, or an error if it isn't a positive
+// price, which the webhook rejects
+func maxPrice(provider *v1alpha1.AWS) (float64, bool, error) {
+	if provider.MaxPrice == nil {
+		return 0, false, nil
+	}
+	price, err := strconv.ParseFloat(*provider.MaxPrice, 64)
+	if err != nil || math.IsNaN(price) || math.IsInf(price, 0) || price <= 0 {
+		return 0, false, fmt.Errorf("maxPrice %q isn't a positive hourly price", *provider.MaxPrice)
+	}
+	return price, true, nil
+}
+
+// allowedCapacityTypes returns the capacity types that both the capacityTypes of the node template and the
+// requirements allow, or an error if they don't have any in common
+func allowedCapacityTypes(provider *v1alpha1.AWS, requirements scheduling.Requirements) (sets.String, error) {
+	allowed := sets.NewString(v1alpha5.CapacityTypeSpot, v1alpha5.CapacityTypeOnDemand)
+	if len(provider.CapacityTypes) != 0 {
+		allowed = allowed.Intersection(sets.NewString(provider.CapacityTypes...))
+	}
+	if requirements.Has(v1alpha5.LabelCapacityType) {
+		allowed = sets.NewString(lo.Filter(allowed.List(), func(capacityType string, _ int) bool {
+			return requirements.Get(v1alpha5.LabelCapacityType).Has(capacityType)
+		})...)
+	}
+	if allowed.Len() == 0 && len(provider.CapacityTypes) != 0 {
+		return nil, fmt.Errorf("the node template only allows the capacity types %v, which the capacity type requirement %s doesn't allow",
+			provider.CapacityTypes, requirements.Get(v1alpha5.LabelCapacityType))
+	}
+	return allowed, nil
+}
+
+// excluded returns true if the instance type matches any of the globally excluded instance type patterns
+func (p *InstanceTypeProvider) excluded(ctx context.Context, instanceType string) bool {
+	return lo.ContainsBy(awssettings.FromContext(ctx).ExcludedInstanceTypes, func(pattern string) bool {
+		// Patterns are validated when the settings are parsed, so a match error can't occur here
+		matched, _ := path.Match(pattern, instanceType)
+		return matched
+	})
+}
+
 // filter the instance types to include useful ones for Kubernetes
 func (p *InstanceTypeProvider) filter(instanceType *ec2.InstanceTypeInfo) bool {
 	if instanceType.FpgaInfo != nil {
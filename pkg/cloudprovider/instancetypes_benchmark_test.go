@@ -0,0 +1,85 @@
+//go:build test_performance
+
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/patrickmn/go-cache"
+	"github.com/samber/lo"
+
+	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	"github.com/aws/karpenter-core/pkg/utils/pretty"
+	awssettings "github.com/aws/karpenter/pkg/apis/config/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	awscache "github.com/aws/karpenter/pkg/cache"
+	awscontext "github.com/aws/karpenter/pkg/context"
+	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+// slowEC2API adds latency to the offerings call to simulate a round trip to EC2
+type slowEC2API struct {
+	*fake.EC2API
+	latency time.Duration
+}
+
+func (s *slowEC2API) DescribeInstanceTypeOfferingsPagesWithContext(ctx context.Context, input *ec2.DescribeInstanceTypeOfferingsInput, fn func(*ec2.DescribeInstanceTypeOfferingsOutput, bool) bool, opts ...request.Option) error {
+	time.Sleep(s.latency)
+	return s.EC2API.DescribeInstanceTypeOfferingsPagesWithContext(ctx, input, fn, opts...)
+}
+
+func BenchmarkInstanceTypeColdStartSerial(b *testing.B) {
+	benchmarkInstanceTypeColdStart(b, 1)
+}
+
+func BenchmarkInstanceTypeColdStartParallel(b *testing.B) {
+	benchmarkInstanceTypeColdStart(b, 5)
+}
+
+func benchmarkInstanceTypeColdStart(b *testing.B, parallelism int) {
+	ctx := coretest.SettingsStore{
+		awssettings.ContextKey: test.Settings(test.SettingOptions{InstanceTypeOfferingsParallelism: lo.ToPtr(parallelism)}),
+	}.InjectSettings(context.Background())
+	ec2api := &slowEC2API{EC2API: &fake.EC2API{}, latency: time.Millisecond * 100}
+	provider := &v1alpha1.AWS{SubnetSelector: map[string]string{"*": "*"}}
+	instanceTypeCache := cache.New(InstanceTypesAndZonesCacheTTL, awscontext.CacheCleanupInterval)
+	instanceTypeProvider := &InstanceTypeProvider{
+		ec2api: ec2api,
+		subnetProvider: &SubnetProvider{
+			ec2api: ec2api,
+			cache:  awscache.NewMetered(awscache.SubnetsCacheName, cache.New(awscontext.CacheTTL, awscontext.CacheCleanupInterval)),
+			cm:     pretty.NewChangeMonitor(),
+		},
+		cache:                awscache.NewMetered(awscache.InstanceTypesCacheName, instanceTypeCache),
+		pricingProvider:      NewPricingProvider(ctx, &fake.PricingAPI{}, ec2api, "", false, 12*time.Hour, make(chan struct{})),
+		unavailableOfferings: awscache.NewUnavailableOfferings(cache.New(awscache.UnavailableOfferingsTTL, awscontext.CacheCleanupInterval)),
+		cm:                   pretty.NewChangeMonitor(),
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		instanceTypeCache.Flush()
+		if _, err := instanceTypeProvider.Get(ctx, provider, &v1alpha5.KubeletConfiguration{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -16,7 +16,9 @@ package cloudprovider
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -26,16 +28,20 @@ import (
 	"github.com/samber/lo"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"knative.dev/pkg/ptr"
 
 	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
 	coretest "github.com/aws/karpenter-core/pkg/test"
+	"github.com/aws/karpenter-core/pkg/utils/pretty"
 	"github.com/aws/karpenter/pkg/test"
 
 	"github.com/aws/karpenter-core/pkg/cloudprovider"
+	corefake "github.com/aws/karpenter-core/pkg/cloudprovider/fake"
 	"github.com/aws/karpenter-core/pkg/controllers/provisioning"
+	"github.com/aws/karpenter-core/pkg/scheduling"
 	. "github.com/aws/karpenter-core/pkg/test/expectations"
 
 	awssettings "github.com/aws/karpenter/pkg/apis/config/settings"
@@ -48,19 +54,27 @@ var _ = Describe("Instance Types", func() {
 		ExpectApplied(ctx, env.Client, provisioner)
 		var pods []*v1.Pod
 		for key, value := range map[string]string{
-			v1alpha1.LabelInstanceHypervisor:      "nitro",
-			v1alpha1.LabelInstanceCategory:        "g",
-			v1alpha1.LabelInstanceFamily:          "g4dn",
-			v1alpha1.LabelInstanceGeneration:      "4",
-			v1alpha1.LabelInstanceSize:            "8xlarge",
-			v1alpha1.LabelInstanceCPU:             "32",
-			v1alpha1.LabelInstanceMemory:          "131072",
-			v1alpha1.LabelInstancePods:            "58",
-			v1alpha1.LabelInstanceGPUName:         "t4",
-			v1alpha1.LabelInstanceGPUManufacturer: "nvidia",
-			v1alpha1.LabelInstanceGPUCount:        "1",
-			v1alpha1.LabelInstanceGPUMemory:       "16384",
-			v1alpha1.LabelInstanceLocalNVME:       "900",
+			v1alpha1.LabelInstanceHypervisor:           "nitro",
+			v1alpha1.LabelInstanceVirtualizationType:   "hvm",
+			v1alpha1.LabelInstanceBootMode:             "uefi",
+			v1alpha1.LabelInstanceTPMSupport:           "v2.0",
+			v1alpha1.LabelInstanceCategory:             "g",
+			v1alpha1.LabelInstanceFamily:               "g4dn",
+			v1alpha1.LabelInstanceGeneration:           "4",
+			v1alpha1.LabelInstanceSize:                 "8xlarge",
+			v1alpha1.LabelInstanceCPU:                  "32",
+			v1alpha1.LabelInstanceMemory:               "131072",
+			v1alpha1.LabelInstancePods:                 "58",
+			v1alpha1.LabelInstanceGPUName:              "t4",
+			v1alpha1.LabelInstanceGPUManufacturer:      "nvidia",
+			v1alpha1.LabelInstanceGPUCount:             "1",
+			v1alpha1.LabelInstanceGPUMemory:            "16384",
+			v1alpha1.LabelInstanceLocalNVME:            "900",
+			v1alpha1.LabelInstanceEBSBandwidth:         "9500",
+			v1alpha1.LabelInstanceNetworkCards:         "1",
+			v1alpha1.LabelInstanceMaxENIs:              "4",
+			v1alpha1.LabelInstanceIPsPerENI:            "15",
+			v1alpha1.LabelInstanceDedicatedHostSupport: "true",
 		} {
 			pods = append(pods, coretest.UnschedulablePod(coretest.PodOptions{NodeSelector: map[string]string{key: value}}))
 		}
@@ -68,6 +82,230 @@ var _ = Describe("Instance Types", func() {
 			ExpectScheduled(ctx, env.Client, pod)
 		}
 	})
+	It("should launch instances with at least the required amount of local nvme storage", func() {
+		ExpectApplied(ctx, env.Client, provisioner)
+		pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod(coretest.PodOptions{
+			NodeRequirements: []v1.NodeSelectorRequirement{{Key: v1alpha1.LabelInstanceLocalNVME, Operator: v1.NodeSelectorOpGt, Values: []string{"500"}}},
+		}))[0]
+		node := ExpectScheduled(ctx, env.Client, pod)
+		Expect(node.Labels).To(HaveKeyWithValue(v1alpha1.LabelInstanceLocalNVME, "900"))
+		Expect(node.Labels).To(HaveKeyWithValue(v1.LabelInstanceTypeStable, "g4dn.8xlarge"))
+	})
+	It("should not launch instances when no instance type has enough local nvme storage", func() {
+		ExpectApplied(ctx, env.Client, provisioner)
+		pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod(coretest.PodOptions{
+			NodeRequirements: []v1.NodeSelectorRequirement{{Key: v1alpha1.LabelInstanceLocalNVME, Operator: v1.NodeSelectorOpGt, Values: []string{"1000"}}},
+		}))[0]
+		ExpectNotScheduled(ctx, env.Client, pod)
+	})
+	It("should not label instances without local nvme storage", func() {
+		ExpectApplied(ctx, env.Client, provisioner)
+		pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod(coretest.PodOptions{
+			NodeRequirements: []v1.NodeSelectorRequirement{{Key: v1alpha1.LabelInstanceLocalNVME, Operator: v1.NodeSelectorOpDoesNotExist}},
+		}))[0]
+		node := ExpectScheduled(ctx, env.Client, pod)
+		Expect(node.Labels).ToNot(HaveKey(v1alpha1.LabelInstanceLocalNVME))
+		Expect(fakeEC2API.CalledWithCreateFleetInput.Len()).To(Equal(1))
+		for _, ltc := range fakeEC2API.CalledWithCreateFleetInput.Pop().LaunchTemplateConfigs {
+			for _, ovr := range ltc.Overrides {
+				Expect(aws.StringValue(ovr.InstanceType)).ToNot(Equal("g4dn.8xlarge"))
+			}
+		}
+	})
+	It("should launch instances with at least the required amount of ebs bandwidth", func() {
+		ExpectApplied(ctx, env.Client, provisioner)
+		pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod(coretest.PodOptions{
+			NodeRequirements: []v1.NodeSelectorRequirement{{Key: v1alpha1.LabelInstanceEBSBandwidth, Operator: v1.NodeSelectorOpGt, Values: []string{"5000"}}},
+		}))[0]
+		node := ExpectScheduled(ctx, env.Client, pod)
+		Expect(node.Labels).To(HaveKeyWithValue(v1alpha1.LabelInstanceEBSBandwidth, "9500"))
+		Expect(node.Labels).To(HaveKeyWithValue(v1.LabelInstanceTypeStable, "g4dn.8xlarge"))
+	})
+	It("should not launch instances when no instance type has enough ebs bandwidth", func() {
+		ExpectApplied(ctx, env.Client, provisioner)
+		pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod(coretest.PodOptions{
+			NodeRequirements: []v1.NodeSelectorRequirement{{Key: v1alpha1.LabelInstanceEBSBandwidth, Operator: v1.NodeSelectorOpGt, Values: []string{"10000"}}},
+		}))[0]
+		ExpectNotScheduled(ctx, env.Client, pod)
+	})
+	It("should only label instance types that report their ebs bandwidth", func() {
+		instanceTypes, err := instanceTypeProvider.Get(ctx, provider, &v1alpha5.KubeletConfiguration{})
+		Expect(err).ToNot(HaveOccurred())
+		for _, instanceType := range instanceTypes {
+			bandwidth := instanceType.Requirements().Get(v1alpha1.LabelInstanceEBSBandwidth)
+			switch instanceType.Name() {
+			case "m5.large", "m5.xlarge":
+				Expect(bandwidth.Values()).To(ConsistOf("4750"))
+			case "g4dn.8xlarge":
+				Expect(bandwidth.Values()).To(ConsistOf("9500"))
+			default:
+				Expect(bandwidth.Operator()).To(Equal(v1.NodeSelectorOpDoesNotExist))
+			}
+		}
+	})
+	It("should launch instances with at least the required number of enis", func() {
+		ExpectApplied(ctx, env.Client, provisioner)
+		pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod(coretest.PodOptions{
+			NodeRequirements: []v1.NodeSelectorRequirement{
+				{Key: v1alpha1.LabelInstanceMaxENIs, Operator: v1.NodeSelectorOpGt, Values: []string{"3"}},
+				{Key: v1alpha1.LabelInstanceIPsPerENI, Operator: v1.NodeSelectorOpGt, Values: []string{"30"}},
+			},
+		}))[0]
+		node := ExpectScheduled(ctx, env.Client, pod)
+		Expect(node.Labels).To(HaveKeyWithValue(v1alpha1.LabelInstanceMaxENIs, "4"))
+		Expect(node.Labels).To(HaveKeyWithValue(v1alpha1.LabelInstanceIPsPerENI, "60"))
+	})
+	It("should label instance types with the enis that their max pods are calculated from", func() {
+		instanceTypes, err := instanceTypeProvider.Get(ctx, provider, &v1alpha5.KubeletConfiguration{})
+		Expect(err).ToNot(HaveOccurred())
+		for _, instanceType := range instanceTypes {
+			enis, err := strconv.ParseInt(instanceType.Requirements().Get(v1alpha1.LabelInstanceMaxENIs).Any(), 10, 64)
+			Expect(err).ToNot(HaveOccurred())
+			ipsPerENI, err := strconv.ParseInt(instanceType.Requirements().Get(v1alpha1.LabelInstanceIPsPerENI).Any(), 10, 64)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instanceType.Requirements().Get(v1alpha1.LabelInstancePods).Values()).To(ConsistOf(fmt.Sprint(enis*(ipsPerENI-1) + 2)))
+		}
+	})
+	It("should only label instance types that report their network cards", func() {
+		instanceTypes, err := instanceTypeProvider.Get(ctx, provider, &v1alpha5.KubeletConfiguration{})
+		Expect(err).ToNot(HaveOccurred())
+		for _, instanceType := range instanceTypes {
+			networkCards := instanceType.Requirements().Get(v1alpha1.LabelInstanceNetworkCards)
+			if instanceType.Name() == "g4dn.8xlarge" {
+				Expect(networkCards.Values()).To(ConsistOf("1"))
+			} else {
+				Expect(networkCards.Operator()).To(Equal(v1.NodeSelectorOpDoesNotExist))
+			}
+		}
+	})
+	It("should only label instance types that support dedicated hosts", func() {
+		instanceTypes, err := instanceTypeProvider.Get(ctx, provider, &v1alpha5.KubeletConfiguration{})
+		Expect(err).ToNot(HaveOccurred())
+		for _, instanceType := range instanceTypes {
+			dedicatedHostSupport := instanceType.Requirements().Get(v1alpha1.LabelInstanceDedicatedHostSupport)
+			switch instanceType.Name() {
+			case "m5.large", "m5.xlarge", "g4dn.8xlarge", "m5.metal":
+				Expect(dedicatedHostSupport.Values()).To(ConsistOf("true"))
+			default:
+				Expect(dedicatedHostSupport.Operator()).To(Equal(v1.NodeSelectorOpDoesNotExist))
+			}
+		}
+	})
+	It("should only launch instance types that support dedicated hosts when they're required", func() {
+		ExpectApplied(ctx, env.Client, provisioner)
+		pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod(coretest.PodOptions{
+			NodeRequirements: []v1.NodeSelectorRequirement{{Key: v1alpha1.LabelInstanceDedicatedHostSupport, Operator: v1.NodeSelectorOpExists}},
+		}))[0]
+		node := ExpectScheduled(ctx, env.Client, pod)
+		Expect(node.Labels).To(HaveKeyWithValue(v1alpha1.LabelInstanceDedicatedHostSupport, "true"))
+		Expect(fakeEC2API.CalledWithCreateFleetInput.Len()).To(Equal(1))
+		for _, ltc := range fakeEC2API.CalledWithCreateFleetInput.Pop().LaunchTemplateConfigs {
+			for _, ovr := range ltc.Overrides {
+				Expect(aws.StringValue(ovr.InstanceType)).To(BeElementOf("m5.large", "m5.xlarge", "g4dn.8xlarge", "m5.metal"))
+			}
+		}
+	})
+	Context("Placement Groups", func() {
+		It("should support the placement group strategy label", func() {
+			ExpectApplied(ctx, env.Client, provisioner)
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod(coretest.PodOptions{
+				NodeSelector: map[string]string{v1alpha1.LabelInstancePlacementGroupStrategy: "cluster"},
+			}))[0]
+			node := ExpectScheduled(ctx, env.Client, pod)
+			Expect(node.Labels).To(HaveKeyWithValue(v1alpha1.LabelInstancePlacementGroupStrategy, "cluster"))
+			Expect(node.Labels[v1alpha1.LabelInstanceFamily]).To(Equal("m5"))
+		})
+		It("should only launch instance types that support the strategy of the placement group", func() {
+			provider.PlacementGroup = &v1alpha1.PlacementGroup{Name: "my-placement-group", Strategy: "cluster"}
+			provisioner = test.Provisioner(coretest.ProvisionerOptions{Provider: provider})
+			ExpectApplied(ctx, env.Client, provisioner)
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateFleetInput.Len()).To(Equal(1))
+			for _, ltc := range fakeEC2API.CalledWithCreateFleetInput.Pop().LaunchTemplateConfigs {
+				for _, override := range ltc.Overrides {
+					Expect(aws.StringValue(override.InstanceType)).To(HavePrefix("m5."))
+					Expect(aws.StringValue(override.Placement.GroupName)).To(Equal("my-placement-group"))
+				}
+			}
+		})
+		It("should not launch instance types that don't support the strategy of the placement group", func() {
+			provider.PlacementGroup = &v1alpha1.PlacementGroup{Name: "my-placement-group", Strategy: "cluster"}
+			provisioner = test.Provisioner(coretest.ProvisionerOptions{Provider: provider})
+			ExpectApplied(ctx, env.Client, provisioner)
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod(coretest.PodOptions{
+				NodeSelector: map[string]string{v1.LabelInstanceTypeStable: "t3.large"},
+			}))[0]
+			ExpectNotScheduled(ctx, env.Client, pod)
+		})
+		It("should launch into a single zone when the cluster placement group is empty", func() {
+			provider.PlacementGroup = &v1alpha1.PlacementGroup{Name: "my-placement-group", Strategy: "cluster"}
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			zones := sets.NewString()
+			for _, ltc := range fakeEC2API.CalledWithCreateFleetInput.Pop().LaunchTemplateConfigs {
+				for _, override := range ltc.Overrides {
+					zones.Insert(aws.StringValue(override.AvailabilityZone))
+				}
+			}
+			Expect(zones.Len()).To(Equal(1))
+		})
+		It("should launch into the zone of the instances already in the cluster placement group", func() {
+			fakeEC2API.Instances.Store("i-in-placement-group", &ec2.Instance{
+				InstanceId: aws.String("i-in-placement-group"),
+				Placement:  &ec2.Placement{AvailabilityZone: aws.String("test-zone-1b"), GroupName: aws.String("my-placement-group")},
+				State:      &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)},
+			})
+			provider.PlacementGroup = &v1alpha1.PlacementGroup{Name: "my-placement-group", Strategy: "cluster"}
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			node := ExpectScheduled(ctx, env.Client, pod)
+			Expect(node.Labels).To(HaveKeyWithValue(v1.LabelTopologyZone, "test-zone-1b"))
+			for _, ltc := range fakeEC2API.CalledWithCreateFleetInput.Pop().LaunchTemplateConfigs {
+				for _, override := range ltc.Overrides {
+					Expect(aws.StringValue(override.AvailabilityZone)).To(Equal("test-zone-1b"))
+				}
+			}
+		})
+		It("should not launch into a cluster placement group outside of its zone", func() {
+			fakeEC2API.Instances.Store("i-in-placement-group", &ec2.Instance{
+				InstanceId: aws.String("i-in-placement-group"),
+				Placement:  &ec2.Placement{AvailabilityZone: aws.String("test-zone-1b"), GroupName: aws.String("my-placement-group")},
+				State:      &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)},
+			})
+			provider.PlacementGroup = &v1alpha1.PlacementGroup{Name: "my-placement-group", Strategy: "cluster"}
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod(coretest.PodOptions{
+				NodeSelector: map[string]string{v1.LabelTopologyZone: "test-zone-1a"},
+			}))[0]
+			ExpectNotScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateFleetInput.Len()).To(Equal(0))
+		})
+		It("should launch into every zone for a placement group that isn't a cluster", func() {
+			provider.PlacementGroup = &v1alpha1.PlacementGroup{Name: "my-placement-group", Strategy: "spread"}
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			zones := sets.NewString()
+			for _, ltc := range fakeEC2API.CalledWithCreateFleetInput.Pop().LaunchTemplateConfigs {
+				for _, override := range ltc.Overrides {
+					zones.Insert(aws.StringValue(override.AvailabilityZone))
+				}
+			}
+			Expect(zones.Len()).To(BeNumerically(">", 1))
+		})
+		It("should not set a placement group unless one is configured", func() {
+			ExpectApplied(ctx, env.Client, provisioner)
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			for _, ltc := range fakeEC2API.CalledWithCreateFleetInput.Pop().LaunchTemplateConfigs {
+				for _, override := range ltc.Overrides {
+					Expect(override.Placement).To(BeNil())
+				}
+			}
+		})
+	})
 	It("should not launch AWS Pod ENI on a t3", func() {
 		ExpectApplied(ctx, env.Client, provisioner)
 		for _, pod := range ExpectProvisioned(ctx, env.Client, recorder, controller, prov,
@@ -244,6 +482,31 @@ var _ = Describe("Instance Types", func() {
 		}
 	})
 
+	Context("Instance Store", func() {
+		It("should use the size of the instance store as ephemeral storage with the RAID0 policy", func() {
+			instanceInfo, err := instanceTypeProvider.getInstanceTypes(ctx)
+			Expect(err).To(BeNil())
+			provider.InstanceStorePolicy = aws.String(v1alpha1.InstanceStorePolicyRAID0)
+			it := NewInstanceType(ctx, instanceInfo["g4dn.8xlarge"], provisioner.Spec.KubeletConfiguration, "", provider, nil)
+			resources := it.Resources()
+			Expect(resources.StorageEphemeral().Cmp(resource.MustParse("900G"))).To(Equal(0))
+		})
+		It("should use the root volume as ephemeral storage for instance types without an instance store", func() {
+			instanceInfo, err := instanceTypeProvider.getInstanceTypes(ctx)
+			Expect(err).To(BeNil())
+			provider.InstanceStorePolicy = aws.String(v1alpha1.InstanceStorePolicyRAID0)
+			it := NewInstanceType(ctx, instanceInfo["m5.xlarge"], provisioner.Spec.KubeletConfiguration, "", provider, nil)
+			resources := it.Resources()
+			Expect(resources.StorageEphemeral().Cmp(resource.MustParse("20Gi"))).To(Equal(0))
+		})
+		It("should use the root volume as ephemeral storage without an instance store policy", func() {
+			instanceInfo, err := instanceTypeProvider.getInstanceTypes(ctx)
+			Expect(err).To(BeNil())
+			it := NewInstanceType(ctx, instanceInfo["g4dn.8xlarge"], provisioner.Spec.KubeletConfiguration, "", provider, nil)
+			resources := it.Resources()
+			Expect(resources.StorageEphemeral().Cmp(resource.MustParse("20Gi"))).To(Equal(0))
+		})
+	})
 	Context("KubeletConfiguration Overrides", func() {
 		BeforeEach(func() {
 			settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{
@@ -251,6 +514,89 @@ var _ = Describe("Instance Types", func() {
 			})
 			ctx = settingsStore.InjectSettings(ctx)
 		})
+		Context("Node Template Overrides", func() {
+			It("should compute overhead from the node template reserved resources and eviction thresholds", func() {
+				nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+					AWS: *provider,
+					SystemReserved: v1.ResourceList{
+						v1.ResourceCPU:    resource.MustParse("2"),
+						v1.ResourceMemory: resource.MustParse("20Gi"),
+					},
+					KubeReserved: v1.ResourceList{
+						v1.ResourceMemory: resource.MustParse("10Gi"),
+					},
+					EvictionThreshold: map[string]string{"memory.available": "1Gi"},
+				})
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				provisioner = test.Provisioner(coretest.ProvisionerOptions{
+					ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name},
+					Kubelet: &v1alpha5.KubeletConfiguration{
+						SystemReserved: v1.ResourceList{
+							v1.ResourceCPU: resource.MustParse("1"),
+						},
+					},
+				})
+				instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+				Expect(err).ToNot(HaveOccurred())
+				it, ok := lo.Find(instanceTypes, func(it cloudprovider.InstanceType) bool { return it.Name() == "m5.xlarge" })
+				Expect(ok).To(BeTrue())
+				overhead := it.Overhead()
+				Expect(overhead.Cpu().String()).To(Equal("2080m"))
+				Expect(overhead.Memory().Cmp(resource.MustParse("31Gi"))).To(Equal(0))
+			})
+			It("should compute overhead from the greater of the node template soft and the provisioner hard eviction thresholds", func() {
+				nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+					AWS: *provider,
+					SystemReserved: v1.ResourceList{
+						v1.ResourceMemory: resource.MustParse("20Gi"),
+					},
+					KubeReserved: v1.ResourceList{
+						v1.ResourceMemory: resource.MustParse("10Gi"),
+					},
+					EvictionSoft:            map[string]string{memoryAvailable: "2Gi"},
+					EvictionSoftGracePeriod: map[string]metav1.Duration{memoryAvailable: {Duration: time.Minute}},
+				})
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				provisioner = test.Provisioner(coretest.ProvisionerOptions{
+					ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name},
+					Kubelet: &v1alpha5.KubeletConfiguration{
+						EvictionHard: map[string]string{memoryAvailable: "1Gi"},
+					},
+				})
+				instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+				Expect(err).ToNot(HaveOccurred())
+				it, ok := lo.Find(instanceTypes, func(it cloudprovider.InstanceType) bool { return it.Name() == "m5.xlarge" })
+				Expect(ok).To(BeTrue())
+				overhead := it.Overhead()
+				Expect(overhead.Memory().Cmp(resource.MustParse("32Gi"))).To(Equal(0))
+			})
+			It("should keep provisioner reserved resources that the node template does not override", func() {
+				nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+					AWS: *provider,
+					SystemReserved: v1.ResourceList{
+						v1.ResourceMemory: resource.MustParse("20Gi"),
+					},
+				})
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				provisioner = test.Provisioner(coretest.ProvisionerOptions{
+					ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name},
+					Kubelet: &v1alpha5.KubeletConfiguration{
+						SystemReserved: v1.ResourceList{
+							v1.ResourceCPU: resource.MustParse("2"),
+						},
+					},
+				})
+				instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+				Expect(err).ToNot(HaveOccurred())
+				it, ok := lo.Find(instanceTypes, func(it cloudprovider.InstanceType) bool { return it.Name() == "m5.xlarge" })
+				Expect(ok).To(BeTrue())
+				overhead := it.Overhead()
+				Expect(overhead.Cpu().String()).To(Equal("2080m"))
+				Expect(overhead.Memory().String()).To(Equal("21473Mi"))
+				// The provisioner's kubelet configuration is left untouched
+				Expect(provisioner.Spec.KubeletConfiguration.SystemReserved).To(HaveLen(1))
+			})
+		})
 		Context("Reserved Resources", func() {
 			It("should override system reserved cpus when specified", func() {
 				instanceInfo, err := instanceTypeProvider.getInstanceTypes(ctx)
@@ -371,6 +717,36 @@ var _ = Describe("Instance Types", func() {
 				overhead := it.Overhead()
 				Expect(overhead.Memory().String()).To(Equal("30Gi"))
 			})
+			It("should reserve ephemeral storage for the nodefs eviction threshold (hard) when specified as a quantity", func() {
+				instanceInfo, err := instanceTypeProvider.getInstanceTypes(ctx)
+				Expect(err).To(BeNil())
+				provisioner = test.Provisioner(coretest.ProvisionerOptions{
+					Kubelet: &v1alpha5.KubeletConfiguration{
+						EvictionHard: map[string]string{
+							nodefsAvailable: "5Gi",
+						},
+					},
+				})
+				it := NewInstanceType(ctx, instanceInfo["m5.xlarge"], provisioner.Spec.KubeletConfiguration, "", provider, nil)
+				overhead := it.Overhead()
+				// 1Gi system reserved + 1Gi kube reserved + 5Gi eviction threshold
+				Expect(overhead.StorageEphemeral().Cmp(resource.MustParse("7Gi"))).To(Equal(0))
+			})
+			It("should reserve ephemeral storage for the nodefs eviction threshold (hard) when specified as a percentage value", func() {
+				instanceInfo, err := instanceTypeProvider.getInstanceTypes(ctx)
+				Expect(err).To(BeNil())
+				provisioner = test.Provisioner(coretest.ProvisionerOptions{
+					Kubelet: &v1alpha5.KubeletConfiguration{
+						EvictionHard: map[string]string{
+							nodefsAvailable: "10%",
+						},
+					},
+				})
+				it := NewInstanceType(ctx, instanceInfo["m5.xlarge"], provisioner.Spec.KubeletConfiguration, "", provider, nil)
+				overhead := it.Overhead()
+				// 1Gi system reserved + 1Gi kube reserved + 10% of the 20Gi root volume
+				Expect(overhead.StorageEphemeral().Cmp(resource.MustParse("4Gi"))).To(Equal(0))
+			})
 			It("should used default eviction threshold (hard) for memory when evictionHard not specified", func() {
 				instanceInfo, err := instanceTypeProvider.getInstanceTypes(ctx)
 				Expect(err).To(BeNil())
@@ -593,6 +969,50 @@ var _ = Describe("Instance Types", func() {
 				Expect(resources.Pods().Value()).To(BeNumerically("==", lo.Min([]int64{20, ptr.Int64Value(info.VCpuInfo.DefaultVCpus) * 4})))
 			}
 		})
+		It("should derive max-pods from the ENI prefixes when prefix delegation is enabled", func() {
+			instanceInfo, err := instanceTypeProvider.getInstanceTypes(ctx)
+			Expect(err).To(BeNil())
+			provider.PrefixDelegation = lo.ToPtr(true)
+			for name, expected := range map[string]int64{"m5.large": 110, "m5.xlarge": 110, "p3.8xlarge": 250, "m5.metal": 250} {
+				it := NewInstanceType(ctx, instanceInfo[name], provisioner.Spec.KubeletConfiguration, "", provider, nil)
+				resources := it.Resources()
+				Expect(resources.Pods().Value()).To(BeNumerically("==", expected), name)
+				Expect(resources.Pods().Value()).To(BeNumerically(">", it.eniLimitedPods()), name)
+			}
+		})
+		It("should derive max-pods from the ENI prefixes when prefix delegation is enabled and AWSENILimitedPodDensity is unset", func() {
+			settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{
+				EnableENILimitedPodDensity: lo.ToPtr(false),
+			})
+			ctx = settingsStore.InjectSettings(ctx)
+
+			instanceInfo, err := instanceTypeProvider.getInstanceTypes(ctx)
+			Expect(err).To(BeNil())
+			provider.PrefixDelegation = lo.ToPtr(true)
+			it := NewInstanceType(ctx, instanceInfo["m5.metal"], provisioner.Spec.KubeletConfiguration, "", provider, nil)
+			resources := it.Resources()
+			Expect(resources.Pods().Value()).To(BeNumerically("==", 250))
+		})
+		It("should prefer a user-defined max-pods over prefix delegation", func() {
+			instanceInfo, err := instanceTypeProvider.getInstanceTypes(ctx)
+			Expect(err).To(BeNil())
+			provider.PrefixDelegation = lo.ToPtr(true)
+			provisioner = test.Provisioner(coretest.ProvisionerOptions{Kubelet: &v1alpha5.KubeletConfiguration{MaxPods: ptr.Int32(10)}})
+			for _, info := range instanceInfo {
+				it := NewInstanceType(ctx, info, provisioner.Spec.KubeletConfiguration, "", provider, nil)
+				resources := it.Resources()
+				Expect(resources.Pods().Value()).To(BeNumerically("==", 10))
+			}
+		})
+		It("should take the minimum of pods-per-core and the prefix delegation max-pods", func() {
+			instanceInfo, err := instanceTypeProvider.getInstanceTypes(ctx)
+			Expect(err).To(BeNil())
+			provider.PrefixDelegation = lo.ToPtr(true)
+			provisioner = test.Provisioner(coretest.ProvisionerOptions{Kubelet: &v1alpha5.KubeletConfiguration{PodsPerCore: ptr.Int32(4)}})
+			it := NewInstanceType(ctx, instanceInfo["m5.xlarge"], provisioner.Spec.KubeletConfiguration, "", provider, nil)
+			resources := it.Resources()
+			Expect(resources.Pods().Value()).To(BeNumerically("==", 16))
+		})
 		It("should ignore pods-per-core when using Bottlerocket AMI", func() {
 			instanceInfo, err := instanceTypeProvider.getInstanceTypes(ctx)
 			Expect(err).To(BeNil())
@@ -801,64 +1221,699 @@ var _ = Describe("Instance Types", func() {
 			Expect(instanceTypeNames.Has("m5.xlarge"))
 		})
 	})
-	Context("CapacityType", func() {
-		It("should default to on-demand", func() {
-			ExpectApplied(ctx, env.Client, provisioner)
-			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
-			node := ExpectScheduled(ctx, env.Client, pod)
-			Expect(node.Labels).To(HaveKeyWithValue(v1alpha5.LabelCapacityType, v1alpha5.CapacityTypeOnDemand))
+	Context("Offerings", func() {
+		zonesByInstanceType := func(instanceTypes []cloudprovider.InstanceType) map[string]sets.String {
+			return lo.SliceToMap(instanceTypes, func(it cloudprovider.InstanceType) (string, sets.String) {
+				return it.Name(), sets.NewString(lo.Map(it.Offerings(), func(o cloudprovider.Offering, _ int) string { return o.Zone })...)
+			})
+		}
+		It("should discover the same zonal offerings when fetching zones serially and in parallel", func() {
+			// Build the expected zones from a single unfiltered call across all zones
+			expected := map[string]sets.String{}
+			Expect(fakeEC2API.DescribeInstanceTypeOfferingsPagesWithContext(ctx, &ec2.DescribeInstanceTypeOfferingsInput{}, func(output *ec2.DescribeInstanceTypeOfferingsOutput, _ bool) bool {
+				for _, offering := range output.InstanceTypeOfferings {
+					if _, ok := expected[aws.StringValue(offering.InstanceType)]; !ok {
+						expected[aws.StringValue(offering.InstanceType)] = sets.NewString()
+					}
+					expected[aws.StringValue(offering.InstanceType)].Insert(aws.StringValue(offering.Location))
+				}
+				return true
+			})).To(Succeed())
+
+			serialCtx := coretest.SettingsStore{
+				awssettings.ContextKey: test.Settings(test.SettingOptions{InstanceTypeOfferingsParallelism: lo.ToPtr(1)}),
+			}.InjectSettings(ctx)
+			serial, err := instanceTypeProvider.Get(serialCtx, provider, &v1alpha5.KubeletConfiguration{})
+			Expect(err).ToNot(HaveOccurred())
+
+			instanceTypeCache.Flush()
+			parallelCtx := coretest.SettingsStore{
+				awssettings.ContextKey: test.Settings(test.SettingOptions{InstanceTypeOfferingsParallelism: lo.ToPtr(3)}),
+			}.InjectSettings(ctx)
+			parallel, err := instanceTypeProvider.Get(parallelCtx, provider, &v1alpha5.KubeletConfiguration{})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(zonesByInstanceType(serial)).To(Equal(zonesByInstanceType(parallel)))
+			for name, zones := range zonesByInstanceType(parallel) {
+				if expectedZones, ok := expected[name]; ok {
+					Expect(zones.Equal(expectedZones)).To(BeTrue(), name)
+				} else {
+					Expect(zones.Len()).To(BeZero(), name)
+				}
+			}
 		})
-		It("should launch spot capacity if flexible to both spot and on demand", func() {
-			provisioner.Spec.Requirements = []v1.NodeSelectorRequirement{
-				{Key: v1alpha5.LabelCapacityType, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.CapacityTypeSpot, v1alpha5.CapacityTypeOnDemand}}}
-			ExpectApplied(ctx, env.Client, provisioner)
-			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
-			node := ExpectScheduled(ctx, env.Client, pod)
-			Expect(node.Labels).To(HaveKeyWithValue(v1alpha5.LabelCapacityType, v1alpha5.CapacityTypeSpot))
+		It("should return an error when fetching the offerings for a zone fails", func() {
+			_, err := instanceTypeProvider.Get(ctx, provider, &v1alpha5.KubeletConfiguration{})
+			Expect(err).ToNot(HaveOccurred())
+			// Only expire the zonal offerings so that the error is returned from the offerings call
+			for key := range instanceTypeCache.Items() {
+				if strings.HasPrefix(key, InstanceTypeZonesCacheKeyPrefix) {
+					instanceTypeCache.Delete(key)
+				}
+			}
+			fakeEC2API.NextError.Set(fmt.Errorf("failed"))
+			_, err = instanceTypeProvider.Get(ctx, provider, &v1alpha5.KubeletConfiguration{})
+			Expect(err).To(HaveOccurred())
 		})
-		It("should fail to launch capacity when there is no zonal availability for spot", func() {
-			now := time.Now()
-			fakeEC2API.DescribeSpotPriceHistoryOutput.Set(&ec2.DescribeSpotPriceHistoryOutput{
-				SpotPriceHistory: []*ec2.SpotPrice{
-					{
-						AvailabilityZone: aws.String("test-zone-1a"),
-						InstanceType:     aws.String("m5.large"),
-						SpotPrice:        aws.String("0.004"),
-						Timestamp:        &now,
-					},
-				},
+		Context("Location Type", func() {
+			requestedLocations := func(locationType string) []string {
+				var locations []string
+				for fakeEC2API.CalledWithDescribeInstanceTypeOfferingsInput.Len() > 0 {
+					input := fakeEC2API.CalledWithDescribeInstanceTypeOfferingsInput.Pop()
+					Expect(aws.StringValue(input.LocationType)).To(Equal(locationType))
+					for _, filter := range input.Filters {
+						locations = append(locations, aws.StringValueSlice(filter.Values)...)
+					}
+				}
+				return locations
+			}
+			It("should request offerings by zone name by default", func() {
+				_, err := instanceTypeProvider.Get(ctx, provider, &v1alpha5.KubeletConfiguration{})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(requestedLocations(ec2.LocationTypeAvailabilityZone)).To(ConsistOf("test-zone-1a", "test-zone-1b", "test-zone-1c"))
 			})
-			Expect(pricingProvider.updateSpotPricing(ctx)).To(Succeed())
-			Eventually(func() bool { return pricingProvider.SpotLastUpdated().After(now) }).Should(BeTrue())
+			It("should request offerings by zone ID and record them against zone names", func() {
+				byName, err := instanceTypeProvider.Get(ctx, provider, &v1alpha5.KubeletConfiguration{})
+				Expect(err).ToNot(HaveOccurred())
+				fakeEC2API.CalledWithDescribeInstanceTypeOfferingsInput.Reset()
 
-			provisioner.Spec.Requirements = []v1.NodeSelectorRequirement{
-				{Key: v1alpha5.LabelCapacityType, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.CapacityTypeSpot}},
-				{Key: v1.LabelInstanceTypeStable, Operator: v1.NodeSelectorOpIn, Values: []string{"m5.large"}},
-				{Key: v1.LabelTopologyZone, Operator: v1.NodeSelectorOpIn, Values: []string{"test-zone-1b"}},
-			}
+				instanceTypeCache.Flush()
+				zoneIDCtx := coretest.SettingsStore{
+					awssettings.ContextKey: test.Settings(test.SettingOptions{InstanceTypeOfferingsLocationType: lo.ToPtr(ec2.LocationTypeAvailabilityZoneId)}),
+				}.InjectSettings(ctx)
+				byID, err := instanceTypeProvider.Get(zoneIDCtx, provider, &v1alpha5.KubeletConfiguration{})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(requestedLocations(ec2.LocationTypeAvailabilityZoneId)).To(ConsistOf("testzone1a", "testzone1b", "testzone1c"))
+				Expect(zonesByInstanceType(byID)).To(Equal(zonesByInstanceType(byName)))
+				Expect(zonesByInstanceType(byID)["m5.large"].List()).To(ConsistOf("test-zone-1a", "test-zone-1b", "test-zone-1c"))
+			})
+		})
+		Context("Invalidation", func() {
+			It("should evict only the invalidated offering", func() {
+				_, err := instanceTypeProvider.Get(ctx, provider, &v1alpha5.KubeletConfiguration{})
+				Expect(err).ToNot(HaveOccurred())
+				fakeEC2API.CalledWithDescribeInstanceTypeOfferingsInput.Reset()
 
-			// Instance type with no zonal availability for spot shouldn't be scheduled
-			ExpectApplied(ctx, env.Client, provisioner)
-			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
-			ExpectNotScheduled(ctx, env.Client, pod)
+				instanceTypeProvider.InvalidateInstanceType(ctx, "m5.large", "test-zone-1a")
+				instanceTypes, err := instanceTypeProvider.Get(ctx, provider, &v1alpha5.KubeletConfiguration{})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(fakeEC2API.CalledWithDescribeInstanceTypeOfferingsInput.Len()).To(BeZero())
+				zones := zonesByInstanceType(instanceTypes)
+				Expect(zones["m5.large"].List()).To(ConsistOf("test-zone-1b", "test-zone-1c"))
+				Expect(zones["m5.xlarge"].Has("test-zone-1a")).To(BeTrue())
+			})
+			It("should mark the invalidated offering unavailable", func() {
+				_, err := instanceTypeProvider.Get(ctx, provider, &v1alpha5.KubeletConfiguration{})
+				Expect(err).ToNot(HaveOccurred())
+				instanceTypeProvider.InvalidateInstanceType(ctx, "m5.large", "test-zone-1a")
+				Expect(unavailableOfferingsCache.IsUnavailable("m5.large", "test-zone-1a", v1alpha5.CapacityTypeSpot)).To(BeTrue())
+				Expect(unavailableOfferingsCache.IsUnavailable("m5.large", "test-zone-1a", v1alpha5.CapacityTypeOnDemand)).To(BeTrue())
+				Expect(unavailableOfferingsCache.IsUnavailable("m5.large", "test-zone-1b", v1alpha5.CapacityTypeSpot)).To(BeFalse())
+			})
+			It("should discover the invalidated offering again once the zonal offerings expire", func() {
+				_, err := instanceTypeProvider.Get(ctx, provider, &v1alpha5.KubeletConfiguration{})
+				Expect(err).ToNot(HaveOccurred())
+				instanceTypeProvider.InvalidateInstanceType(ctx, "m5.large", "test-zone-1a")
+				instanceTypeCache.Flush()
+				instanceTypes, err := instanceTypeProvider.Get(ctx, provider, &v1alpha5.KubeletConfiguration{})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(zonesByInstanceType(instanceTypes)["m5.large"].List()).To(ConsistOf("test-zone-1a", "test-zone-1b", "test-zone-1c"))
+			})
+			It("should mark the offering unavailable even if it isn't cached", func() {
+				instanceTypeProvider.InvalidateInstanceType(ctx, "m5.large", "test-zone-1a")
+				Expect(unavailableOfferingsCache.IsUnavailable("m5.large", "test-zone-1a", v1alpha5.CapacityTypeSpot)).To(BeTrue())
+				Expect(unavailableOfferingsCache.IsUnavailable("m5.large", "test-zone-1a", v1alpha5.CapacityTypeOnDemand)).To(BeTrue())
+
+				instanceTypes, err := instanceTypeProvider.Get(ctx, provider, &v1alpha5.KubeletConfiguration{})
+				Expect(err).ToNot(HaveOccurred())
+				m5Large, ok := lo.Find(instanceTypes, func(it cloudprovider.InstanceType) bool { return it.Name() == "m5.large" })
+				Expect(ok).To(BeTrue())
+				Expect(lo.Map(cloudprovider.AvailableOfferings(m5Large), func(o cloudprovider.Offering, _ int) string { return o.Zone })).ToNot(ContainElement("test-zone-1a"))
+			})
+			It("should leave the cached offerings of other instance types and zones", func() {
+				_, err := instanceTypeProvider.Get(ctx, provider, &v1alpha5.KubeletConfiguration{})
+				Expect(err).ToNot(HaveOccurred())
+				instanceTypeProvider.InvalidateInstanceType(ctx, "m5.large", "test-zone-2a")
+				instanceTypeProvider.InvalidateInstanceType(ctx, "unknown.large", "test-zone-1a")
+				instanceTypes, err := instanceTypeProvider.Get(ctx, provider, &v1alpha5.KubeletConfiguration{})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(zonesByInstanceType(instanceTypes)["m5.large"].List()).To(ConsistOf("test-zone-1a", "test-zone-1b", "test-zone-1c"))
+				Expect(unavailableOfferingsCache.IsUnavailable("m5.large", "test-zone-1a", v1alpha5.CapacityTypeSpot)).To(BeFalse())
+			})
+			It("should be safe to invalidate concurrently", func() {
+				_, err := instanceTypeProvider.Get(ctx, provider, &v1alpha5.KubeletConfiguration{})
+				Expect(err).ToNot(HaveOccurred())
+				var wg sync.WaitGroup
+				for _, zone := range []string{"test-zone-1a", "test-zone-1b", "test-zone-1a", "test-zone-1b"} {
+					wg.Add(1)
+					go func(zone string) {
+						defer GinkgoRecover()
+						defer wg.Done()
+						instanceTypeProvider.InvalidateInstanceType(ctx, "m5.large", zone)
+					}(zone)
+				}
+				wg.Wait()
+				instanceTypes, err := instanceTypeProvider.Get(ctx, provider, &v1alpha5.KubeletConfiguration{})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(zonesByInstanceType(instanceTypes)["m5.large"].List()).To(ConsistOf("test-zone-1c"))
+			})
 		})
-		It("should succeed to launch spot instance when zonal availability exists", func() {
+	})
+	Context("Discovery Regions", func() {
+		var regionalEC2API *fake.EC2API
+		var regionalPricingProvider *PricingProvider
+		var pricingProvidersCreated int
+		BeforeEach(func() {
 			now := time.Now()
-			fakeEC2API.DescribeSpotPriceHistoryOutput.Set(&ec2.DescribeSpotPriceHistoryOutput{
-				SpotPriceHistory: []*ec2.SpotPrice{
-					{
-						AvailabilityZone: aws.String("test-zone-1a"),
-						InstanceType:     aws.String("m5.large"),
-						SpotPrice:        aws.String("0.004"),
-						Timestamp:        &now,
-					},
-				},
+			regionalEC2API = &fake.EC2API{}
+			regionalEC2API.DescribeAvailabilityZonesOutput.Set(&ec2.DescribeAvailabilityZonesOutput{AvailabilityZones: []*ec2.AvailabilityZone{
+				{ZoneName: aws.String("us-east-1a"), ZoneId: aws.String("use1-az1")},
+				{ZoneName: aws.String("us-east-1b"), ZoneId: aws.String("use1-az2")},
+			}})
+			regionalEC2API.DescribeInstanceTypeOfferingsOutput.Set(&ec2.DescribeInstanceTypeOfferingsOutput{InstanceTypeOfferings: []*ec2.InstanceTypeOffering{
+				{InstanceType: aws.String("m5.large"), Location: aws.String("us-east-1a")},
+				{InstanceType: aws.String("m5.large"), Location: aws.String("us-east-1b")},
+				{InstanceType: aws.String("m5.xlarge"), Location: aws.String("us-east-1b")},
+			}})
+			regionalEC2API.DescribeSpotPriceHistoryOutput.Set(&ec2.DescribeSpotPriceHistoryOutput{SpotPriceHistory: []*ec2.SpotPrice{
+				{AvailabilityZone: aws.String("us-east-1a"), InstanceType: aws.String("m5.large"), SpotPrice: aws.String("0.01"), Timestamp: &now},
+			}})
+			updateStart := time.Now()
+			regionalPricingProvider = NewPricingProvider(ctx, &fake.PricingAPI{}, regionalEC2API, "us-east-1", false, 12*time.Hour, make(chan struct{}))
+			Eventually(func() bool { return regionalPricingProvider.SpotLastUpdated().After(updateStart) }).Should(BeTrue())
+			pricingProvidersCreated = 0
+			instanceTypeProvider.regions = map[string]*regionalProviders{
+				"us-east-1": {ec2api: regionalEC2API, newPricingProvider: func() *PricingProvider {
+					pricingProvidersCreated++
+					return regionalPricingProvider
+				}},
+			}
+			DeferCleanup(func() { instanceTypeProvider.regions = nil })
+		})
+		zonesByInstanceType := func(offerings map[string][]cloudprovider.Offering) map[string][]string {
+			return lo.MapValues(offerings, func(o []cloudprovider.Offering, _ string) []string {
+				return lo.Uniq(lo.Map(o, func(offering cloudprovider.Offering, _ int) string { return offering.Zone }))
 			})
-			Expect(pricingProvider.updateSpotPricing(ctx)).To(Succeed())
-			Eventually(func() bool { return pricingProvider.SpotLastUpdated().After(now) }).Should(BeTrue())
-
-			// not restricting to the zone so we can get any zone
-			provisioner.Spec.Requirements = []v1.NodeSelectorRequirement{
+		}
+		It("should discover the offerings of a discovery region", func() {
+			offerings, ok, err := instanceTypeProvider.RegionalOfferings(ctx, "us-east-1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			regional := zonesByInstanceType(offerings)
+			Expect(regional["m5.large"]).To(ConsistOf("us-east-1a", "us-east-1b"))
+			Expect(regional["m5.xlarge"]).To(ConsistOf("us-east-1b"))
+			Expect(regional["c5.large"]).To(BeEmpty())
+		})
+		It("should not discover the offerings of a region that isn't a discovery region", func() {
+			_, ok, err := instanceTypeProvider.RegionalOfferings(ctx, "eu-west-1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ok).To(BeFalse())
+			Expect(pricingProvidersCreated).To(BeZero())
+		})
+		It("should price the offerings of a region with the pricing of the region", func() {
+			offerings, _, err := instanceTypeProvider.RegionalOfferings(ctx, "us-east-1")
+			Expect(err).ToNot(HaveOccurred())
+			offering, ok := lo.Find(offerings["m5.large"], func(o cloudprovider.Offering) bool {
+				return o.Zone == "us-east-1a" && o.CapacityType == v1alpha5.CapacityTypeSpot
+			})
+			Expect(ok).To(BeTrue())
+			Expect(offering.Price).To(BeNumerically("==", 0.01))
+			onDemand, ok := lo.Find(offerings["m5.large"], func(o cloudprovider.Offering) bool {
+				return o.Zone == "us-east-1a" && o.CapacityType == v1alpha5.CapacityTypeOnDemand
+			})
+			Expect(ok).To(BeTrue())
+			Expect(onDemand.Price).To(Equal(lo.Must(regionalPricingProvider.OnDemandPrice("m5.large"))))
+		})
+		It("should create the pricing of a region when its offerings are first discovered", func() {
+			_, err := instanceTypeProvider.Get(ctx, provider, &v1alpha5.KubeletConfiguration{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pricingProvidersCreated).To(BeZero())
+			_, _, err = instanceTypeProvider.RegionalOfferings(ctx, "us-east-1")
+			Expect(err).ToNot(HaveOccurred())
+			_, _, err = instanceTypeProvider.RegionalOfferings(ctx, "us-east-1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pricingProvidersCreated).To(Equal(1))
+		})
+		It("should cache the offerings of each region separately", func() {
+			_, _, err := instanceTypeProvider.RegionalOfferings(ctx, "us-east-1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instanceTypeCache.Items()).To(HaveKey(regionalCacheKey("us-east-1", "types")))
+			Expect(instanceTypeCache.Items()).To(HaveKey(regionalCacheKey("us-east-1", "zones")))
+			Expect(instanceTypeCache.Items()).ToNot(HaveKey(InstanceTypesCacheKey))
+
+			// Failures in a region are returned once its cached offerings expire
+			for key := range instanceTypeCache.Items() {
+				if strings.HasPrefix(key, RegionalCacheKeyPrefix+"us-east-1:") {
+					instanceTypeCache.Delete(key)
+				}
+			}
+			regionalEC2API.NextError.Set(fmt.Errorf("failed"))
+			_, _, err = instanceTypeProvider.RegionalOfferings(ctx, "us-east-1")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Context("Instance Type Filters", func() {
+		BeforeEach(func() {
+			settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{
+				InstanceTypeFilters: map[string][]string{"instance-type": {"m5.*"}, "current-generation": {"true"}},
+			})
+			ctx = settingsStore.InjectSettings(ctx)
+		})
+		It("should discover instance types with the filters of the settings", func() {
+			instanceTypes, err := instanceTypeProvider.Get(ctx, provider, &v1alpha5.KubeletConfiguration{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instanceTypes).ToNot(BeEmpty())
+			for _, instanceType := range instanceTypes {
+				Expect(instanceType.Name()).To(HavePrefix("m5."))
+			}
+			Expect(fakeEC2API.CalledWithDescribeInstanceTypesInput.Len()).To(Equal(1))
+			filters := fakeEC2API.CalledWithDescribeInstanceTypesInput.Pop().Filters
+			Expect(lo.Map(filters, func(filter *ec2.Filter, _ int) string { return aws.StringValue(filter.Name) })).To(Equal([]string{
+				"supported-virtualization-type", "processor-info.supported-architecture", "current-generation", "instance-type",
+			}))
+			Expect(aws.StringValueSlice(filters[3].Values)).To(Equal([]string{"m5.*"}))
+		})
+		It("should return the required instance types that the filters discover", func() {
+			provisioner.Spec.Requirements = []v1.NodeSelectorRequirement{
+				{Key: v1.LabelInstanceTypeStable, Operator: v1.NodeSelectorOpIn, Values: []string{"m5.large"}},
+			}
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+			Expect(err).ToNot(HaveOccurred())
+			_, ok := lo.Find(instanceTypes, func(it cloudprovider.InstanceType) bool { return it.Name() == "m5.large" })
+			Expect(ok).To(BeTrue())
+		})
+		It("should find the required instance types that the filters exclude", func() {
+			requirements := scheduling.NewRequirements(scheduling.NewRequirement(v1.LabelInstanceTypeStable, v1.NodeSelectorOpIn, "m5.large", "t3.large", "c6g.large"))
+			undiscovered, err := instanceTypeProvider.undiscoveredInstanceTypes(ctx, requirements)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(undiscovered).To(Equal([]string{"c6g.large", "t3.large"}))
+		})
+		It("should not find undiscovered instance types without an instance type requirement", func() {
+			undiscovered, err := instanceTypeProvider.undiscoveredInstanceTypes(ctx, scheduling.NewRequirements())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(undiscovered).To(BeEmpty())
+		})
+	})
+	Context("Static Instance Types", func() {
+		BeforeEach(func() {
+			settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{
+				InstanceTypeDataSource: lo.ToPtr(awssettings.StaticInstanceTypeData),
+			})
+			ctx = settingsStore.InjectSettings(ctx)
+		})
+		It("should describe instance types with the static dataset instead of DescribeInstanceTypes", func() {
+			instanceTypes, err := instanceTypeProvider.Get(ctx, provider, &v1alpha5.KubeletConfiguration{})
+			Expect(err).ToNot(HaveOccurred())
+			it, ok := lo.Find(instanceTypes, func(it cloudprovider.InstanceType) bool { return it.Name() == "m5.large" })
+			Expect(ok).To(BeTrue())
+			resources := it.Resources()
+			Expect(resources.Cpu().String()).To(Equal("2"))
+			Expect(resources.Memory().String()).To(Equal("8Gi"))
+			Expect(fakeEC2API.CalledWithDescribeInstanceTypesInput.Len()).To(Equal(0))
+		})
+		It("should offer the static instance types in the zones that EC2 offers them in", func() {
+			instanceTypes, err := instanceTypeProvider.Get(ctx, provider, &v1alpha5.KubeletConfiguration{})
+			Expect(err).ToNot(HaveOccurred())
+			it, ok := lo.Find(instanceTypes, func(it cloudprovider.InstanceType) bool { return it.Name() == "m5.large" })
+			Expect(ok).To(BeTrue())
+			Expect(lo.Uniq(lo.Map(it.Offerings(), func(o cloudprovider.Offering, _ int) string { return o.Zone }))).To(ConsistOf("test-zone-1a", "test-zone-1b", "test-zone-1c"))
+			Expect(fakeEC2API.CalledWithDescribeInstanceTypeOfferingsInput.Len()).ToNot(BeZero())
+		})
+		It("should launch nodes without calling DescribeInstanceTypes", func() {
+			prov = provisioning.NewProvisioner(ctx, env.Client, corev1.NewForConfigOrDie(env.Config), recorder, cloudProvider, cluster, coretest.SettingsStore{})
+			provisioningController := provisioning.NewController(env.Client, prov, recorder)
+			ExpectApplied(ctx, env.Client, provisioner)
+			pod := ExpectProvisioned(ctx, env.Client, recorder, provisioningController, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithDescribeInstanceTypesInput.Len()).To(Equal(0))
+		})
+		It("should find the required instance types that aren't in the static dataset", func() {
+			requirements := scheduling.NewRequirements(scheduling.NewRequirement(v1.LabelInstanceTypeStable, v1.NodeSelectorOpIn, "m5.large", "m99.large"))
+			undiscovered, err := instanceTypeProvider.undiscoveredInstanceTypes(ctx, requirements)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(undiscovered).To(Equal([]string{"m99.large"}))
+		})
+	})
+	Context("Excluded Instance Types", func() {
+		BeforeEach(func() {
+			settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{
+				ExcludedInstanceTypes: []string{"m5.*", "t3.large"},
+			})
+			ctx = settingsStore.InjectSettings(ctx)
+		})
+		It("should not return instance types that match an excluded pattern", func() {
+			instanceTypes, err := instanceTypeProvider.Get(ctx, provider, &v1alpha5.KubeletConfiguration{})
+			Expect(err).ToNot(HaveOccurred())
+			names := sets.NewString(lo.Map(instanceTypes, func(it cloudprovider.InstanceType, _ int) string { return it.Name() })...)
+			Expect(names.HasAny("m5.large", "m5.xlarge", "m5.metal", "t3.large")).To(BeFalse())
+			Expect(names.Has("c6g.large")).To(BeTrue())
+		})
+		It("should not launch an excluded instance type even when a provisioner requires it", func() {
+			prov = provisioning.NewProvisioner(ctx, env.Client, corev1.NewForConfigOrDie(env.Config), recorder, cloudProvider, cluster, coretest.SettingsStore{})
+			provisioningController := provisioning.NewController(env.Client, prov, recorder)
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{
+				Provider: provider,
+				Requirements: []v1.NodeSelectorRequirement{
+					{Key: v1.LabelInstanceTypeStable, Operator: v1.NodeSelectorOpIn, Values: []string{"m5.large"}},
+				},
+			}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, provisioningController, prov, coretest.UnschedulablePod())[0]
+			ExpectNotScheduled(ctx, env.Client, pod)
+		})
+	})
+	Context("No Instance Types", func() {
+		BeforeEach(func() {
+			recorder.Reset()
+		})
+		It("should publish an event when the provisioner's requirements eliminate all instance types", func() {
+			provisioner.Spec.Requirements = append(provisioner.Spec.Requirements, v1.NodeSelectorRequirement{
+				Key: v1.LabelInstanceTypeStable, Operator: v1.NodeSelectorOpIn, Values: []string{"m5.large"},
+			}, v1.NodeSelectorRequirement{
+				Key: v1.LabelTopologyZone, Operator: v1.NodeSelectorOpIn, Values: []string{"test-zone-1z"},
+			})
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(recorder.Calls("NoInstanceTypes")).To(Equal(1))
+			reason, err := cloudProvider.explainNoInstanceTypes(ctx, provisioner, provider, instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reason).To(ContainSubstring(v1.LabelTopologyZone))
+			Expect(reason).ToNot(ContainSubstring(v1.LabelInstanceTypeStable))
+		})
+		It("should publish an event when only the combination of the provisioner's requirements eliminates all instance types", func() {
+			provisioner.Spec.Requirements = append(provisioner.Spec.Requirements, v1.NodeSelectorRequirement{
+				Key: v1.LabelInstanceTypeStable, Operator: v1.NodeSelectorOpIn, Values: []string{"m5.large"},
+			}, v1.NodeSelectorRequirement{
+				Key: v1.LabelArchStable, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.ArchitectureArm64},
+			})
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(recorder.Calls("NoInstanceTypes")).To(Equal(1))
+			Expect(cloudProvider.explainNoInstanceTypes(ctx, provisioner, provider, instanceTypes)).To(ContainSubstring("combination"))
+		})
+		It("should publish an event when all instance types are excluded", func() {
+			settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{
+				ExcludedInstanceTypes: []string{"*"},
+			})
+			ctx = settingsStore.InjectSettings(ctx)
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instanceTypes).To(BeEmpty())
+			Expect(recorder.Calls("NoInstanceTypes")).To(Equal(1))
+			reason, err := cloudProvider.explainNoInstanceTypes(ctx, provisioner, provider, instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reason).To(Equal("every instance type is excluded by the aws.excludedInstanceTypes setting"))
+		})
+		It("should only name the filters that exclude instance types", func() {
+			provider.RequireNitroTPM = lo.ToPtr(true)
+			reason, err := cloudProvider.explainNoInstanceTypes(ctx, provisioner, provider, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reason).To(ContainSubstring("NitroTPM"))
+			Expect(reason).ToNot(ContainSubstring("aws.excludedInstanceTypes"))
+		})
+		It("should name the aws.instanceTypeFilters setting when it excludes all instance types", func() {
+			settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{
+				InstanceTypeFilters: map[string][]string{"instance-type": {"x9.nonexistent"}},
+			})
+			ctx = settingsStore.InjectSettings(ctx)
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instanceTypes).To(BeEmpty())
+			reason, err := cloudProvider.explainNoInstanceTypes(ctx, provisioner, provider, instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reason).To(Equal("every instance type is excluded by the aws.instanceTypeFilters setting"))
+		})
+		It("should name the capacityTypes of the node template when no offering is left", func() {
+			provider.CapacityTypes = []string{v1alpha1.CapacityTypeSpot}
+			provisioner = test.Provisioner(coretest.ProvisionerOptions{
+				Provider: provider,
+				Requirements: []v1.NodeSelectorRequirement{{
+					Key: v1alpha5.LabelCapacityType, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha1.CapacityTypeSpot},
+				}},
+			})
+			instanceTypes := []cloudprovider.InstanceType{corefake.NewInstanceType(corefake.InstanceTypeOptions{
+				Name:      "m5.large",
+				Offerings: []cloudprovider.Offering{{CapacityType: v1alpha1.CapacityTypeSpot, Zone: "test-zone-1a", Price: 0.1, Available: false}},
+			})}
+			reason, err := cloudProvider.explainNoInstanceTypes(ctx, provisioner, provider, instanceTypes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reason).To(ContainSubstring("capacityTypes [spot]"))
+		})
+		It("should not publish an event when an instance type can be launched", func() {
+			_, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(recorder.Calls("NoInstanceTypes")).To(Equal(0))
+		})
+	})
+	Context("Pricing Updates", func() {
+		It("should price offerings with updated prices without refreshing the instance types from EC2", func() {
+			// pricing isn't updated in the background in an isolated VPC, so that only the update below applies
+			isolatedPricingProvider := NewPricingProvider(ctx, fakePricingAPI, fakeEC2API, "", true, 12*time.Hour, make(chan struct{}))
+			isolatedInstanceTypeProvider := &InstanceTypeProvider{
+				ec2api:               fakeEC2API,
+				subnetProvider:       instanceTypeProvider.subnetProvider,
+				cache:                instanceTypeProvider.cache,
+				pricingProvider:      isolatedPricingProvider,
+				unavailableOfferings: unavailableOfferingsCache,
+				cm:                   pretty.NewChangeMonitor(),
+			}
+			spotPrice := func() float64 {
+				instanceTypes, err := isolatedInstanceTypeProvider.Get(ctx, provider, &v1alpha5.KubeletConfiguration{})
+				Expect(err).ToNot(HaveOccurred())
+				instanceType, ok := lo.Find(instanceTypes, func(it cloudprovider.InstanceType) bool { return it.Name() == "m5.large" })
+				Expect(ok).To(BeTrue())
+				offering, ok := cloudprovider.GetOffering(instanceType, v1alpha5.CapacityTypeSpot, "test-zone-1a")
+				Expect(ok).To(BeTrue())
+				return offering.Price
+			}
+			Expect(spotPrice()).ToNot(BeNumerically("==", 0.01))
+
+			now := time.Now()
+			fakeEC2API.DescribeSpotPriceHistoryOutput.Set(&ec2.DescribeSpotPriceHistoryOutput{
+				SpotPriceHistory: []*ec2.SpotPrice{
+					{AvailabilityZone: aws.String("test-zone-1a"), InstanceType: aws.String("m5.large"), SpotPrice: aws.String("0.01"), Timestamp: &now},
+				},
+			})
+			Expect(isolatedPricingProvider.updateSpotPricing(ctx)).To(Succeed())
+			// any call to EC2 would consume this error
+			fakeEC2API.NextError.Set(fmt.Errorf("unexpected call to EC2"))
+			Expect(spotPrice()).To(BeNumerically("==", 0.01))
+			Expect(fakeEC2API.NextError.IsNil()).To(BeFalse())
+		})
+	})
+	Context("Pricing Unavailable", func() {
+		It("should rank offerings by vcpus and then memory when no prices are known", func() {
+			unpricedInstanceTypeProvider := &InstanceTypeProvider{
+				ec2api:               fakeEC2API,
+				subnetProvider:       instanceTypeProvider.subnetProvider,
+				cache:                instanceTypeProvider.cache,
+				pricingProvider:      &PricingProvider{},
+				unavailableOfferings: unavailableOfferingsCache,
+				cm:                   pretty.NewChangeMonitor(),
+			}
+			instanceTypes, err := unpricedInstanceTypeProvider.Get(ctx, provider, &v1alpha5.KubeletConfiguration{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instanceTypes).ToNot(BeEmpty())
+			for _, instanceType := range instanceTypes {
+				Expect(instanceType.Offerings()).ToNot(BeEmpty())
+				for _, offering := range instanceType.Offerings() {
+					Expect(offering.Available).To(BeTrue())
+				}
+			}
+			size := func(instanceType cloudprovider.InstanceType) (int, int) {
+				cpu, err := strconv.Atoi(instanceType.Requirements().Get(v1alpha1.LabelInstanceCPU).Any())
+				Expect(err).ToNot(HaveOccurred())
+				memory, err := strconv.Atoi(instanceType.Requirements().Get(v1alpha1.LabelInstanceMemory).Any())
+				Expect(err).ToNot(HaveOccurred())
+				return cpu, memory
+			}
+			for _, a := range instanceTypes {
+				for _, b := range instanceTypes {
+					aCPU, aMemory := size(a)
+					bCPU, bMemory := size(b)
+					if aCPU < bCPU || (aCPU == bCPU && aMemory < bMemory) {
+						Expect(a.Offerings()[0].Price).To(BeNumerically("<", b.Offerings()[0].Price), "%s should be ranked ahead of %s", a.Name(), b.Name())
+					}
+				}
+			}
+		})
+	})
+	Context("Spot Unavailable", func() {
+		BeforeEach(func() {
+			recorder.Reset()
+			provisioner.Spec.Requirements = []v1.NodeSelectorRequirement{
+				{Key: v1alpha5.LabelCapacityType, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.CapacityTypeSpot, v1alpha5.CapacityTypeOnDemand}},
+				{Key: v1.LabelInstanceTypeStable, Operator: v1.NodeSelectorOpIn, Values: []string{"m5.large"}},
+				{Key: v1.LabelTopologyZone, Operator: v1.NodeSelectorOpIn, Values: []string{"test-zone-1a"}},
+			}
+		})
+		markSpotUnavailable := func() {
+			unavailableOfferingsCache.MarkUnavailable(ctx, "InsufficientInstanceCapacity", "m5.large", "test-zone-1a", v1alpha5.CapacityTypeSpot)
+		}
+		It("should publish an event when all spot offerings are unavailable", func() {
+			markSpotUnavailable()
+			_, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(recorder.Calls("SpotUnavailable")).To(Equal(1))
+		})
+		It("should not publish an event when a spot offering is available", func() {
+			_, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(recorder.Calls("SpotUnavailable")).To(Equal(0))
+		})
+		It("should not publish an event when the provisioner doesn't allow spot", func() {
+			markSpotUnavailable()
+			provisioner.Spec.Requirements[0].Values = []string{v1alpha5.CapacityTypeOnDemand}
+			_, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(recorder.Calls("SpotUnavailable")).To(Equal(0))
+		})
+		It("should launch on-demand capacity when all spot offerings are unavailable by default", func() {
+			markSpotUnavailable()
+			ExpectApplied(ctx, env.Client, provisioner)
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			node := ExpectScheduled(ctx, env.Client, pod)
+			Expect(node.Labels).To(HaveKeyWithValue(v1alpha5.LabelCapacityType, v1alpha5.CapacityTypeOnDemand))
+		})
+		It("should wait for spot capacity when all spot offerings are unavailable and configured to", func() {
+			settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{
+				SpotUnavailableBehavior: lo.ToPtr(awssettings.WaitForSpot),
+			})
+			ctx = settingsStore.InjectSettings(ctx)
+			markSpotUnavailable()
+			ExpectApplied(ctx, env.Client, provisioner)
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectNotScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateFleetInput.Len()).To(Equal(0))
+		})
+		It("should launch spot capacity once a spot offering is available again when configured to wait", func() {
+			settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{
+				SpotUnavailableBehavior: lo.ToPtr(awssettings.WaitForSpot),
+			})
+			ctx = settingsStore.InjectSettings(ctx)
+			ExpectApplied(ctx, env.Client, provisioner)
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			node := ExpectScheduled(ctx, env.Client, pod)
+			Expect(node.Labels).To(HaveKeyWithValue(v1alpha5.LabelCapacityType, v1alpha5.CapacityTypeSpot))
+		})
+	})
+	Context("Capacity Overrides", func() {
+		getResources := func(name string) v1.ResourceList {
+			instanceTypes, err := instanceTypeProvider.Get(ctx, provider, &v1alpha5.KubeletConfiguration{})
+			Expect(err).ToNot(HaveOccurred())
+			instanceType, ok := lo.Find(instanceTypes, func(it cloudprovider.InstanceType) bool { return it.Name() == name })
+			Expect(ok).To(BeTrue())
+			return instanceType.Resources()
+		}
+		It("should override the capacity of an instance type", func() {
+			settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{CapacityOverrides: map[string]v1.ResourceList{
+				"m5.large": {v1.ResourceMemory: resource.MustParse("6Gi"), v1.ResourcePods: resource.MustParse("20")},
+			}})
+			ctx = settingsStore.InjectSettings(ctx)
+			resources := getResources("m5.large")
+			Expect(resources.Memory().String()).To(Equal("6Gi"))
+			Expect(resources.Pods().String()).To(Equal("20"))
+			Expect(resources.Cpu().String()).To(Equal("2"))
+		})
+		It("should override the capacity of an instance family", func() {
+			settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{CapacityOverrides: map[string]v1.ResourceList{
+				"m5": {v1.ResourceCPU: resource.MustParse("1")},
+			}})
+			ctx = settingsStore.InjectSettings(ctx)
+			Expect(lo.ToPtr(getResources("m5.large")[v1.ResourceCPU]).String()).To(Equal("1"))
+			Expect(lo.ToPtr(getResources("m5.xlarge")[v1.ResourceCPU]).String()).To(Equal("1"))
+			Expect(lo.ToPtr(getResources("t3.large")[v1.ResourceCPU]).String()).To(Equal("2"))
+		})
+		It("should prefer an instance type override over an instance family override", func() {
+			settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{CapacityOverrides: map[string]v1.ResourceList{
+				"m5":       {v1.ResourceCPU: resource.MustParse("1")},
+				"m5.large": {v1.ResourceCPU: resource.MustParse("1500m")},
+			}})
+			ctx = settingsStore.InjectSettings(ctx)
+			Expect(lo.ToPtr(getResources("m5.large")[v1.ResourceCPU]).String()).To(Equal("1500m"))
+			Expect(lo.ToPtr(getResources("m5.xlarge")[v1.ResourceCPU]).String()).To(Equal("1"))
+		})
+		It("should ignore overrides that exceed the physical capacity", func() {
+			settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{CapacityOverrides: map[string]v1.ResourceList{
+				"m5.large": {v1.ResourceCPU: resource.MustParse("4"), v1.ResourceMemory: resource.MustParse("1Gi")},
+			}})
+			ctx = settingsStore.InjectSettings(ctx)
+			resources := getResources("m5.large")
+			Expect(resources.Cpu().String()).To(Equal("2"))
+			Expect(resources.Memory().String()).To(Equal("1Gi"))
+		})
+		It("should schedule pods against the overridden capacity", func() {
+			settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{CapacityOverrides: map[string]v1.ResourceList{
+				"m5": {v1.ResourceCPU: resource.MustParse("1")},
+			}})
+			ctx = settingsStore.InjectSettings(ctx)
+			prov = provisioning.NewProvisioner(ctx, env.Client, corev1.NewForConfigOrDie(env.Config), recorder, cloudProvider, cluster, coretest.SettingsStore{})
+			provisioningController := provisioning.NewController(env.Client, prov, recorder)
+			ExpectApplied(ctx, env.Client, provisioner)
+			pod := ExpectProvisioned(ctx, env.Client, recorder, provisioningController, prov, coretest.UnschedulablePod(coretest.PodOptions{
+				NodeSelector:         map[string]string{v1.LabelInstanceTypeStable: "m5.xlarge"},
+				ResourceRequirements: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("2")}},
+			}))[0]
+			ExpectNotScheduled(ctx, env.Client, pod)
+		})
+	})
+	Context("CapacityType", func() {
+		It("should default to on-demand", func() {
+			ExpectApplied(ctx, env.Client, provisioner)
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			node := ExpectScheduled(ctx, env.Client, pod)
+			Expect(node.Labels).To(HaveKeyWithValue(v1alpha5.LabelCapacityType, v1alpha5.CapacityTypeOnDemand))
+		})
+		It("should launch spot capacity if flexible to both spot and on demand", func() {
+			provisioner.Spec.Requirements = []v1.NodeSelectorRequirement{
+				{Key: v1alpha5.LabelCapacityType, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.CapacityTypeSpot, v1alpha5.CapacityTypeOnDemand}}}
+			ExpectApplied(ctx, env.Client, provisioner)
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			node := ExpectScheduled(ctx, env.Client, pod)
+			Expect(node.Labels).To(HaveKeyWithValue(v1alpha5.LabelCapacityType, v1alpha5.CapacityTypeSpot))
+		})
+		It("should fail to launch capacity when there is no zonal availability for spot", func() {
+			now := time.Now()
+			fakeEC2API.DescribeSpotPriceHistoryOutput.Set(&ec2.DescribeSpotPriceHistoryOutput{
+				SpotPriceHistory: []*ec2.SpotPrice{
+					{
+						AvailabilityZone: aws.String("test-zone-1a"),
+						InstanceType:     aws.String("m5.large"),
+						SpotPrice:        aws.String("0.004"),
+						Timestamp:        &now,
+					},
+				},
+			})
+			Expect(pricingProvider.updateSpotPricing(ctx)).To(Succeed())
+			Eventually(func() bool { return pricingProvider.SpotLastUpdated().After(now) }).Should(BeTrue())
+
+			provisioner.Spec.Requirements = []v1.NodeSelectorRequirement{
+				{Key: v1alpha5.LabelCapacityType, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.CapacityTypeSpot}},
+				{Key: v1.LabelInstanceTypeStable, Operator: v1.NodeSelectorOpIn, Values: []string{"m5.large"}},
+				{Key: v1.LabelTopologyZone, Operator: v1.NodeSelectorOpIn, Values: []string{"test-zone-1b"}},
+			}
+
+			// Instance type with no zonal availability for spot shouldn't be scheduled
+			ExpectApplied(ctx, env.Client, provisioner)
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectNotScheduled(ctx, env.Client, pod)
+		})
+		It("should succeed to launch spot instance when zonal availability exists", func() {
+			now := time.Now()
+			fakeEC2API.DescribeSpotPriceHistoryOutput.Set(&ec2.DescribeSpotPriceHistoryOutput{
+				SpotPriceHistory: []*ec2.SpotPrice{
+					{
+						AvailabilityZone: aws.String("test-zone-1a"),
+						InstanceType:     aws.String("m5.large"),
+						SpotPrice:        aws.String("0.004"),
+						Timestamp:        &now,
+					},
+				},
+			})
+			Expect(pricingProvider.updateSpotPricing(ctx)).To(Succeed())
+			Eventually(func() bool { return pricingProvider.SpotLastUpdated().After(now) }).Should(BeTrue())
+
+			// not restricting to the zone so we can get any zone
+			provisioner.Spec.Requirements = []v1.NodeSelectorRequirement{
 				{Key: v1alpha5.LabelCapacityType, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.CapacityTypeSpot}},
 				{Key: v1.LabelInstanceTypeStable, Operator: v1.NodeSelectorOpIn, Values: []string{"m5.large"}},
 			}
@@ -868,6 +1923,434 @@ var _ = Describe("Instance Types", func() {
 			node := ExpectScheduled(ctx, env.Client, pod)
 			Expect(node.Labels).To(HaveKeyWithValue(v1alpha5.ProvisionerNameLabelKey, provisioner.Name))
 		})
+		It("should not annotate the node with the allocation by default", func() {
+			ExpectApplied(ctx, env.Client, provisioner)
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			node := ExpectScheduled(ctx, env.Client, pod)
+			Expect(node.Annotations).ToNot(HaveKey(v1alpha1.AnnotationAllocationStrategy))
+			Expect(node.Annotations).ToNot(HaveKey(v1alpha1.AnnotationOfferingPrice))
+		})
+		Context("Allocation Annotations", func() {
+			var provisioningController *provisioning.Controller
+			BeforeEach(func() {
+				settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{
+					EnableAllocationAnnotations: lo.ToPtr(true),
+				})
+				ctx = settingsStore.InjectSettings(ctx)
+				prov = provisioning.NewProvisioner(ctx, env.Client, corev1.NewForConfigOrDie(env.Config), recorder, cloudProvider, cluster, coretest.SettingsStore{})
+				provisioningController = provisioning.NewController(env.Client, prov, recorder)
+			})
+			It("should annotate an on-demand node with the allocation strategy and the offering price", func() {
+				ExpectApplied(ctx, env.Client, provisioner)
+				pod := ExpectProvisioned(ctx, env.Client, recorder, provisioningController, prov, coretest.UnschedulablePod())[0]
+				node := ExpectScheduled(ctx, env.Client, pod)
+				Expect(node.Annotations).To(HaveKeyWithValue(v1alpha1.AnnotationAllocationStrategy, ec2.FleetOnDemandAllocationStrategyLowestPrice))
+
+				instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+				Expect(err).ToNot(HaveOccurred())
+				instanceType, ok := lo.Find(instanceTypes, func(it cloudprovider.InstanceType) bool { return it.Name() == node.Labels[v1.LabelInstanceTypeStable] })
+				Expect(ok).To(BeTrue())
+				offering, ok := cloudprovider.GetOffering(instanceType, v1alpha5.CapacityTypeOnDemand, node.Labels[v1.LabelTopologyZone])
+				Expect(ok).To(BeTrue())
+				Expect(node.Annotations).To(HaveKeyWithValue(v1alpha1.AnnotationOfferingPrice, fmt.Sprint(offering.Price)))
+			})
+			It("should annotate a spot node with the allocation strategy and the spot price", func() {
+				now := time.Now()
+				fakeEC2API.DescribeSpotPriceHistoryOutput.Set(&ec2.DescribeSpotPriceHistoryOutput{
+					SpotPriceHistory: []*ec2.SpotPrice{
+						{
+							AvailabilityZone: aws.String("test-zone-1a"),
+							InstanceType:     aws.String("m5.large"),
+							SpotPrice:        aws.String("0.004"),
+							Timestamp:        &now,
+						},
+					},
+				})
+				Expect(pricingProvider.updateSpotPricing(ctx)).To(Succeed())
+				Eventually(func() bool { return pricingProvider.SpotLastUpdated().After(now) }).Should(BeTrue())
+
+				provisioner.Spec.Requirements = []v1.NodeSelectorRequirement{
+					{Key: v1alpha5.LabelCapacityType, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.CapacityTypeSpot}},
+					{Key: v1.LabelInstanceTypeStable, Operator: v1.NodeSelectorOpIn, Values: []string{"m5.large"}},
+				}
+				ExpectApplied(ctx, env.Client, provisioner)
+				pod := ExpectProvisioned(ctx, env.Client, recorder, provisioningController, prov, coretest.UnschedulablePod())[0]
+				node := ExpectScheduled(ctx, env.Client, pod)
+				Expect(node.Annotations).To(HaveKeyWithValue(v1alpha1.AnnotationAllocationStrategy, ec2.SpotAllocationStrategyCapacityOptimizedPrioritized))
+				Expect(node.Annotations).To(HaveKeyWithValue(v1alpha1.AnnotationOfferingPrice, "0.004"))
+			})
+		})
+	})
+	Context("Generation Preference", func() {
+		instanceTypes := func(names ...string) []cloudprovider.InstanceType {
+			return lo.Map(names, func(name string, _ int) cloudprovider.InstanceType {
+				return &InstanceType{InstanceTypeInfo: &ec2.InstanceTypeInfo{InstanceType: aws.String(name)}}
+			})
+		}
+		It("should parse the category and generation from instance type names", func() {
+			for name, expected := range map[string]lo.Tuple2[string, int]{
+				"m5.large":      lo.T2("m", 5),
+				"m6i.xlarge":    lo.T2("m", 6),
+				"c6gn.16xlarge": lo.T2("c", 6),
+				"u-6tb1.metal":  lo.T2("u", 1),
+			} {
+				category, generation, ok := instanceGeneration(name)
+				Expect(ok).To(BeTrue(), name)
+				Expect(lo.T2(category, generation)).To(Equal(expected), name)
+			}
+			_, _, ok := instanceGeneration("unknown")
+			Expect(ok).To(BeFalse())
+		})
+		It("should not weight prices without a preference", func() {
+			weightedPrice := generationWeightedPrice(nil, instanceTypes("m5.large", "m6i.large"))
+			Expect(weightedPrice("m6i.large", 1.01)).To(Equal(1.01))
+			Expect(weightedPrice("m5.large", 1.0)).To(Equal(1.0))
+		})
+		It("should order newer generations first within the price tolerance", func() {
+			weightedPrice := generationWeightedPrice(&v1alpha1.GenerationPreference{PriceTolerancePercent: aws.Int64(10)}, instanceTypes("m5.large", "m6i.large", "m7i.large"))
+			Expect(weightedPrice("m6i.large", 1.09)).To(BeNumerically("<", weightedPrice("m5.large", 1.0)))
+			Expect(weightedPrice("m6i.large", 1.11)).To(BeNumerically(">", weightedPrice("m5.large", 1.0)))
+			// The tolerance compounds per generation
+			Expect(weightedPrice("m7i.large", 1.2)).To(BeNumerically("<", weightedPrice("m5.large", 1.0)))
+			Expect(weightedPrice("m7i.large", 1.22)).To(BeNumerically(">", weightedPrice("m5.large", 1.0)))
+		})
+		It("should default the price tolerance", func() {
+			weightedPrice := generationWeightedPrice(&v1alpha1.GenerationPreference{}, instanceTypes("m5.large", "m6i.large"))
+			Expect(weightedPrice("m6i.large", 1.04)).To(BeNumerically("<", weightedPrice("m5.large", 1.0)))
+			Expect(weightedPrice("m6i.large", 1.06)).To(BeNumerically(">", weightedPrice("m5.large", 1.0)))
+		})
+		It("should only compare generations within a category", func() {
+			weightedPrice := generationWeightedPrice(&v1alpha1.GenerationPreference{}, instanceTypes("m5.large", "c6g.large"))
+			Expect(weightedPrice("c6g.large", 1.01)).To(BeNumerically(">", weightedPrice("m5.large", 1.0)))
+		})
+		It("should prioritize on-demand overrides when newer generations are preferred", func() {
+			provider.PreferNewerGenerations = &v1alpha1.GenerationPreference{}
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateFleetInput.Len()).To(Equal(1))
+			input := fakeEC2API.CalledWithCreateFleetInput.Pop()
+			Expect(aws.StringValue(input.OnDemandOptions.AllocationStrategy)).To(Equal(ec2.FleetOnDemandAllocationStrategyPrioritized))
+			for _, override := range input.LaunchTemplateConfigs[0].Overrides {
+				Expect(override.Priority).ToNot(BeNil())
+			}
+		})
+		It("should launch on-demand capacity by lowest price by default", func() {
+			ExpectApplied(ctx, env.Client, provisioner)
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateFleetInput.Len()).To(Equal(1))
+			input := fakeEC2API.CalledWithCreateFleetInput.Pop()
+			Expect(aws.StringValue(input.OnDemandOptions.AllocationStrategy)).To(Equal(ec2.FleetOnDemandAllocationStrategyLowestPrice))
+			for _, override := range input.LaunchTemplateConfigs[0].Overrides {
+				Expect(override.Priority).To(BeNil())
+			}
+		})
+	})
+	Context("Preferred Zones", func() {
+		overrideZones := func(input *ec2.CreateFleetInput) []string {
+			return lo.Map(input.LaunchTemplateConfigs[0].Overrides, func(override *ec2.FleetLaunchTemplateOverridesRequest, _ int) string {
+				return aws.StringValue(override.AvailabilityZone)
+			})
+		}
+		It("should order offerings of the same price by the preferred zones", func() {
+			provider.PreferredZones = []string{"test-zone-1c", "test-zone-1a"}
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider, Requirements: []v1.NodeSelectorRequirement{
+				{Key: v1.LabelInstanceTypeStable, Operator: v1.NodeSelectorOpIn, Values: []string{"m5.large"}},
+			}}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateFleetInput.Len()).To(Equal(1))
+			input := fakeEC2API.CalledWithCreateFleetInput.Pop()
+			Expect(overrideZones(input)).To(Equal([]string{"test-zone-1c", "test-zone-1a", "test-zone-1b"}))
+			Expect(aws.StringValue(input.OnDemandOptions.AllocationStrategy)).To(Equal(ec2.FleetOnDemandAllocationStrategyPrioritized))
+			for _, override := range input.LaunchTemplateConfigs[0].Overrides {
+				Expect(override.Priority).ToNot(BeNil())
+			}
+		})
+		It("should order cheaper offerings in other zones ahead of the preferred zones", func() {
+			provider.PreferredZones = []string{"test-zone-1c"}
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider, Requirements: []v1.NodeSelectorRequirement{
+				{Key: v1.LabelInstanceTypeStable, Operator: v1.NodeSelectorOpIn, Values: []string{"m5.large", "m5.xlarge"}},
+			}}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateFleetInput.Len()).To(Equal(1))
+			overrides := fakeEC2API.CalledWithCreateFleetInput.Pop().LaunchTemplateConfigs[0].Overrides
+			Expect(aws.StringValue(overrides[0].InstanceType)).To(Equal("m5.large"))
+			Expect(aws.StringValue(overrides[0].AvailabilityZone)).To(Equal("test-zone-1c"))
+			// Every m5.large offering is cheaper than the m5.xlarge offering in the preferred zone
+			_, lastLarge, _ := lo.FindLastIndexOf(overrides, func(override *ec2.FleetLaunchTemplateOverridesRequest) bool {
+				return aws.StringValue(override.InstanceType) == "m5.large"
+			})
+			_, firstXLarge, ok := lo.FindIndexOf(overrides, func(override *ec2.FleetLaunchTemplateOverridesRequest) bool {
+				return aws.StringValue(override.InstanceType) == "m5.xlarge"
+			})
+			Expect(ok).To(BeTrue())
+			Expect(lastLarge).To(BeNumerically("<", firstXLarge))
+		})
+		It("should launch into the zones that the provisioner requires over the preferred zones", func() {
+			provider.PreferredZones = []string{"test-zone-1a"}
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider, Requirements: []v1.NodeSelectorRequirement{
+				{Key: v1.LabelTopologyZone, Operator: v1.NodeSelectorOpIn, Values: []string{"test-zone-1b"}},
+			}}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			node := ExpectScheduled(ctx, env.Client, pod)
+			Expect(node.Labels).To(HaveKeyWithValue(v1.LabelTopologyZone, "test-zone-1b"))
+		})
+		It("should rank zones that aren't preferred last", func() {
+			rank := preferredZoneRank([]string{"test-zone-1b", "test-zone-1a"})
+			Expect(rank("test-zone-1b")).To(Equal(0))
+			Expect(rank("test-zone-1a")).To(Equal(1))
+			Expect(rank("test-zone-1c")).To(Equal(2))
+			Expect(preferredZoneRank(nil)("test-zone-1a")).To(Equal(0))
+		})
+	})
+	Context("Spot Price Bias", func() {
+		var nodeRequest *cloudprovider.NodeRequest
+		BeforeEach(func() {
+			provisioner.Spec.Requirements = []v1.NodeSelectorRequirement{
+				{Key: v1alpha5.LabelCapacityType, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.CapacityTypeSpot, v1alpha5.CapacityTypeOnDemand}}}
+			nodeRequest = &cloudprovider.NodeRequest{Template: scheduling.NewNodeTemplate(provisioner)}
+		})
+		offerings := func(spotPrice float64, onDemandPrice float64) []cloudprovider.InstanceType {
+			return []cloudprovider.InstanceType{&InstanceType{
+				InstanceTypeInfo: &ec2.InstanceTypeInfo{InstanceType: aws.String("m5.large")},
+				offerings: []cloudprovider.Offering{
+					{Zone: "test-zone-1a", CapacityType: v1alpha5.CapacityTypeSpot, Price: spotPrice, Available: true},
+					{Zone: "test-zone-1a", CapacityType: v1alpha5.CapacityTypeOnDemand, Price: onDemandPrice, Available: true},
+				},
+			}}
+		}
+		It("should not weight prices without a bias", func() {
+			weightedPrice := capacityTypeWeightedPrice(nil)
+			Expect(weightedPrice(v1alpha5.CapacityTypeSpot, 1.0)).To(BeNumerically("==", 1.0))
+			Expect(weightedPrice(v1alpha5.CapacityTypeOnDemand, 1.0)).To(BeNumerically("==", 1.0))
+		})
+		It("should discount spot prices by a positive bias", func() {
+			weightedPrice := capacityTypeWeightedPrice(ptr.Int64(20))
+			Expect(weightedPrice(v1alpha5.CapacityTypeSpot, 1.0)).To(BeNumerically("~", 0.8))
+			Expect(weightedPrice(v1alpha5.CapacityTypeOnDemand, 1.0)).To(BeNumerically("==", 1.0))
+		})
+		It("should mark up spot prices by a negative bias", func() {
+			weightedPrice := capacityTypeWeightedPrice(ptr.Int64(-20))
+			Expect(weightedPrice(v1alpha5.CapacityTypeSpot, 1.0)).To(BeNumerically("~", 1.2))
+			Expect(weightedPrice(v1alpha5.CapacityTypeOnDemand, 1.0)).To(BeNumerically("==", 1.0))
+		})
+		It("should launch spot whenever it's available without a bias", func() {
+			nodeRequest.InstanceTypeOptions = offerings(1.1, 1.0)
+			Expect(cloudProvider.instanceProvider.getCapacityType(provider, nodeRequest)).To(Equal(v1alpha5.CapacityTypeSpot))
+		})
+		It("should compare prices as they are with a bias of 0", func() {
+			provider.SpotPriceBiasPercent = ptr.Int64(0)
+			nodeRequest.InstanceTypeOptions = offerings(0.9, 1.0)
+			Expect(cloudProvider.instanceProvider.getCapacityType(provider, nodeRequest)).To(Equal(v1alpha5.CapacityTypeSpot))
+			nodeRequest.InstanceTypeOptions = offerings(1.1, 1.0)
+			Expect(cloudProvider.instanceProvider.getCapacityType(provider, nodeRequest)).To(Equal(v1alpha5.CapacityTypeOnDemand))
+		})
+		It("should launch on-demand when spot isn't discounted by more than a negative bias", func() {
+			provider.SpotPriceBiasPercent = ptr.Int64(-20)
+			nodeRequest.InstanceTypeOptions = offerings(0.9, 1.0)
+			Expect(cloudProvider.instanceProvider.getCapacityType(provider, nodeRequest)).To(Equal(v1alpha5.CapacityTypeOnDemand))
+			nodeRequest.InstanceTypeOptions = offerings(0.8, 1.0)
+			Expect(cloudProvider.instanceProvider.getCapacityType(provider, nodeRequest)).To(Equal(v1alpha5.CapacityTypeSpot))
+		})
+		It("should launch spot when it's more expensive by less than a positive bias", func() {
+			provider.SpotPriceBiasPercent = ptr.Int64(20)
+			nodeRequest.InstanceTypeOptions = offerings(1.1, 1.0)
+			Expect(cloudProvider.instanceProvider.getCapacityType(provider, nodeRequest)).To(Equal(v1alpha5.CapacityTypeSpot))
+		})
+		It("should launch spot when on-demand isn't allowed, regardless of the bias", func() {
+			provider.SpotPriceBiasPercent = ptr.Int64(-100)
+			provisioner.Spec.Requirements = []v1.NodeSelectorRequirement{
+				{Key: v1alpha5.LabelCapacityType, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.CapacityTypeSpot}}}
+			nodeRequest = &cloudprovider.NodeRequest{Template: scheduling.NewNodeTemplate(provisioner), InstanceTypeOptions: offerings(0.9, 0.1)}
+			Expect(cloudProvider.instanceProvider.getCapacityType(provider, nodeRequest)).To(Equal(v1alpha5.CapacityTypeSpot))
+		})
+	})
+	Context("Capacity Types", func() {
+		capacityTypeRequirements := func(capacityTypes ...string) scheduling.Requirements {
+			return scheduling.NewRequirements(scheduling.NewRequirement(v1alpha5.LabelCapacityType, v1.NodeSelectorOpIn, capacityTypes...))
+		}
+		It("should allow the capacity types of the requirements without a restriction", func() {
+			allowed, err := allowedCapacityTypes(provider, capacityTypeRequirements(v1alpha5.CapacityTypeSpot, v1alpha5.CapacityTypeOnDemand))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(allowed.List()).To(ConsistOf(v1alpha5.CapacityTypeSpot, v1alpha5.CapacityTypeOnDemand))
+		})
+		It("should allow the capacity types of the node template without a capacity type requirement", func() {
+			provider.CapacityTypes = []string{v1alpha5.CapacityTypeOnDemand}
+			allowed, err := allowedCapacityTypes(provider, scheduling.NewRequirements())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(allowed.List()).To(ConsistOf(v1alpha5.CapacityTypeOnDemand))
+		})
+		It("should intersect the capacity types of the node template and the requirements", func() {
+			provider.CapacityTypes = []string{v1alpha5.CapacityTypeOnDemand}
+			allowed, err := allowedCapacityTypes(provider, capacityTypeRequirements(v1alpha5.CapacityTypeSpot, v1alpha5.CapacityTypeOnDemand))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(allowed.List()).To(ConsistOf(v1alpha5.CapacityTypeOnDemand))
+		})
+		It("should fail when the node template and the requirements don't allow a capacity type in common", func() {
+			provider.CapacityTypes = []string{v1alpha5.CapacityTypeOnDemand}
+			_, err := allowedCapacityTypes(provider, capacityTypeRequirements(v1alpha5.CapacityTypeSpot))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("node template only allows the capacity types [on-demand]"))
+		})
+		It("should only offer the capacity types of the node template", func() {
+			provider.CapacityTypes = []string{v1alpha5.CapacityTypeOnDemand}
+			instanceTypes, err := instanceTypeProvider.Get(ctx, provider, &v1alpha5.KubeletConfiguration{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instanceTypes).ToNot(BeEmpty())
+			for _, instanceType := range instanceTypes {
+				for _, offering := range instanceType.Offerings() {
+					Expect(offering.CapacityType).To(Equal(v1alpha5.CapacityTypeOnDemand))
+				}
+			}
+		})
+		It("should fail to get instance types for a provisioner that only allows other capacity types", func() {
+			provider.CapacityTypes = []string{v1alpha5.CapacityTypeOnDemand}
+			provisioner = test.Provisioner(coretest.ProvisionerOptions{
+				Provider:     provider,
+				Requirements: []v1.NodeSelectorRequirement{{Key: v1alpha5.LabelCapacityType, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.CapacityTypeSpot}}},
+			})
+			_, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(fmt.Sprintf("provisioner %s can't launch nodes", provisioner.Name)))
+		})
+		It("should launch on-demand when the node template doesn't allow spot", func() {
+			provider.CapacityTypes = []string{v1alpha5.CapacityTypeOnDemand}
+			provisioner = test.Provisioner(coretest.ProvisionerOptions{
+				Provider:     provider,
+				Requirements: []v1.NodeSelectorRequirement{{Key: v1alpha5.LabelCapacityType, Operator: v1.NodeSelectorOpIn, Values: []string{v1alpha5.CapacityTypeSpot, v1alpha5.CapacityTypeOnDemand}}},
+			})
+			ExpectApplied(ctx, env.Client, provisioner)
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			node := ExpectScheduled(ctx, env.Client, pod)
+			Expect(node.Labels).To(HaveKeyWithValue(v1alpha5.LabelCapacityType, v1alpha5.CapacityTypeOnDemand))
+		})
+	})
+	Context("Max Price", func() {
+		It("should only offer offerings at or below the max price", func() {
+			provider.MaxPrice = ptr.String("0.1")
+			instanceTypes, err := instanceTypeProvider.Get(ctx, provider, &v1alpha5.KubeletConfiguration{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instanceTypes).ToNot(BeEmpty())
+			offerings := lo.FlatMap(instanceTypes, func(it cloudprovider.InstanceType, _ int) []cloudprovider.Offering { return it.Offerings() })
+			Expect(offerings).ToNot(BeEmpty())
+			for _, offering := range offerings {
+				Expect(offering.Price).To(BeNumerically("<=", 0.1))
+			}
+		})
+		It("should offer every offering without a max price", func() {
+			instanceTypes, err := instanceTypeProvider.Get(ctx, provider, &v1alpha5.KubeletConfiguration{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lo.ContainsBy(instanceTypes, func(it cloudprovider.InstanceType) bool {
+				return lo.ContainsBy(it.Offerings(), func(offering cloudprovider.Offering) bool { return offering.Price > 0.1 })
+			})).To(BeTrue())
+		})
+		It("should launch an instance type within the max price", func() {
+			provider.MaxPrice = ptr.String("0.1")
+			provisioner = test.Provisioner(coretest.ProvisionerOptions{Provider: provider})
+			ExpectApplied(ctx, env.Client, provisioner)
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			node := ExpectScheduled(ctx, env.Client, pod)
+			provider.MaxPrice = nil
+			instanceTypes, err := instanceTypeProvider.Get(ctx, provider, &v1alpha5.KubeletConfiguration{})
+			Expect(err).ToNot(HaveOccurred())
+			instanceType, ok := lo.Find(instanceTypes, func(it cloudprovider.InstanceType) bool { return it.Name() == node.Labels[v1.LabelInstanceTypeStable] })
+			Expect(ok).To(BeTrue())
+			offering, ok := cloudprovider.GetOffering(instanceType, node.Labels[v1alpha5.LabelCapacityType], node.Labels[v1.LabelTopologyZone])
+			Expect(ok).To(BeTrue())
+			Expect(offering.Price).To(BeNumerically("<=", 0.1))
+		})
+		It("should fail to get instance types with a max price that isn't a positive price", func() {
+			provider.MaxPrice = ptr.String("$1")
+			_, err := instanceTypeProvider.Get(ctx, provider, &v1alpha5.KubeletConfiguration{})
+			Expect(err).To(MatchError(ContainSubstring("maxPrice")))
+		})
+		It("should publish an event when the max price eliminates every offering", func() {
+			provider.MaxPrice = ptr.String("0.0001")
+			provisioner = test.Provisioner(coretest.ProvisionerOptions{Provider: provider})
+			_, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(recorder.Calls("MaxPriceExceeded")).To(Equal(1))
+			Expect(recorder.Calls("NoInstanceTypes")).To(Equal(0))
+		})
+		It("should not blame the max price when offerings within it are available", func() {
+			provider.MaxPrice = ptr.String("1000")
+			provisioner = test.Provisioner(coretest.ProvisionerOptions{
+				Provider: provider,
+				Requirements: []v1.NodeSelectorRequirement{{
+					Key: v1.LabelTopologyZone, Operator: v1.NodeSelectorOpIn, Values: []string{"test-zone-1z"},
+				}},
+			})
+			_, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(recorder.Calls("MaxPriceExceeded")).To(Equal(0))
+			Expect(recorder.Calls("NoInstanceTypes")).To(Equal(1))
+		})
+	})
+	Context("Reserved Headroom", func() {
+		overheadOf := func(name string) v1.ResourceList {
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+			Expect(err).ToNot(HaveOccurred())
+			it, ok := lo.Find(instanceTypes, func(it cloudprovider.InstanceType) bool { return it.Name() == name })
+			Expect(ok).To(BeTrue())
+			return it.Overhead()
+		}
+		It("should add the reserved headroom to the overhead", func() {
+			overhead := overheadOf("m5.xlarge")
+			settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{
+				ReservedHeadroom: v1.ResourceList{
+					v1.ResourceCPU:              resource.MustParse("250m"),
+					v1.ResourceMemory:           resource.MustParse("512Mi"),
+					v1.ResourceEphemeralStorage: resource.MustParse("5Gi"),
+				},
+			})
+			ctx = settingsStore.InjectSettings(ctx)
+			withHeadroom := overheadOf("m5.xlarge")
+
+			cpu := overhead.Cpu()
+			cpu.Add(resource.MustParse("250m"))
+			Expect(withHeadroom.Cpu().Cmp(*cpu)).To(Equal(0))
+			memory := overhead.Memory()
+			memory.Add(resource.MustParse("512Mi"))
+			Expect(withHeadroom.Memory().Cmp(*memory)).To(Equal(0))
+			storage := overhead.StorageEphemeral()
+			storage.Add(resource.MustParse("5Gi"))
+			Expect(withHeadroom.StorageEphemeral().Cmp(*storage)).To(Equal(0))
+		})
+		It("should only add the resources of the reserved headroom", func() {
+			overhead := overheadOf("m5.xlarge")
+			settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{
+				ReservedHeadroom: v1.ResourceList{v1.ResourceMemory: resource.MustParse("1Gi")},
+			})
+			ctx = settingsStore.InjectSettings(ctx)
+			withHeadroom := overheadOf("m5.xlarge")
+			Expect(withHeadroom.Cpu().Cmp(*overhead.Cpu())).To(Equal(0))
+			Expect(withHeadroom.StorageEphemeral().Cmp(*overhead.StorageEphemeral())).To(Equal(0))
+			Expect(withHeadroom.Memory().Cmp(*overhead.Memory())).To(Equal(1))
+		})
+		It("should not schedule pods into the reserved headroom", func() {
+			provisioner.Spec.Requirements = append(provisioner.Spec.Requirements, v1.NodeSelectorRequirement{
+				Key: v1.LabelInstanceTypeStable, Operator: v1.NodeSelectorOpIn, Values: []string{"m5.xlarge"},
+			})
+			overhead := overheadOf("m5.xlarge")
+			// The pod fits into the allocatable capacity of an m5.xlarge without headroom, but not with a cpu of headroom
+			cpu := resource.MustParse("4")
+			cpu.Sub(*overhead.Cpu())
+			cpu.Sub(resource.MustParse("500m"))
+			pod := coretest.UnschedulablePod(coretest.PodOptions{
+				ResourceRequirements: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceCPU: cpu}},
+			})
+			settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{
+				ReservedHeadroom: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")},
+			})
+			ctx = settingsStore.InjectSettings(ctx)
+			prov = provisioning.NewProvisioner(ctx, env.Client, corev1.NewForConfigOrDie(env.Config), recorder, cloudProvider, cluster, coretest.SettingsStore{})
+			provisioningController := provisioning.NewController(env.Client, prov, recorder)
+			ExpectApplied(ctx, env.Client, provisioner)
+			ExpectNotScheduled(ctx, env.Client, ExpectProvisioned(ctx, env.Client, recorder, provisioningController, prov, pod)[0])
+		})
 	})
 	Context("Metadata Options", func() {
 		It("should default metadata options on generated launch template", func() {
@@ -16,6 +16,7 @@ package cloudprovider
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
@@ -39,15 +40,18 @@ import (
 	"github.com/aws/karpenter-core/pkg/utils/pretty"
 	awssettings "github.com/aws/karpenter/pkg/apis/config/settings"
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	awscache "github.com/aws/karpenter/pkg/cache"
 	"github.com/aws/karpenter/pkg/cloudprovider/amifamily"
 	awscontext "github.com/aws/karpenter/pkg/context"
 	awserrors "github.com/aws/karpenter/pkg/errors"
 )
 
 const (
-	launchTemplateNameFormat  = "Karpenter-%s-%s"
+	launchTemplateNameFormat  = "%s-%s-%s"
 	karpenterManagedTagKey    = "karpenter.k8s.aws/cluster"
+	launchTemplateHashTagKey  = "karpenter.k8s.aws/launch-template-hash"
 	kubernetesVersionCacheKey = "kubernetesVersion"
+	keyPairCacheKeyPrefix     = "keyPair:"
 )
 
 type LaunchTemplateProvider struct {
@@ -56,7 +60,7 @@ type LaunchTemplateProvider struct {
 	kubernetesInterface   kubernetes.Interface
 	amiFamily             *amifamily.Resolver
 	securityGroupProvider *SecurityGroupProvider
-	cache                 *cache.Cache
+	cache                 *awscache.Metered
 	caBundle              *string
 	cm                    *pretty.ChangeMonitor
 	kubeDNSIP             net.IP
@@ -68,7 +72,7 @@ func NewLaunchTemplateProvider(ctx context.Context, ec2api ec2iface.EC2API, kube
 		kubernetesInterface:   kubernetesInterface,
 		amiFamily:             amiFamily,
 		securityGroupProvider: securityGroupProvider,
-		cache:                 cache.New(awscontext.CacheTTL, awscontext.CacheCleanupInterval),
+		cache:                 awscache.NewMetered(awscache.LaunchTemplatesCacheName, cache.New(awscontext.CacheTTL, awscontext.CacheCleanupInterval)),
 		caBundle:              caBundle,
 		cm:                    pretty.NewChangeMonitor(),
 		kubeDNSIP:             kubeDNSIP,
@@ -86,12 +90,18 @@ func NewLaunchTemplateProvider(ctx context.Context, ec2api ec2iface.EC2API, kube
 	return l
 }
 
-func launchTemplateName(options *amifamily.LaunchTemplate) string {
+func launchTemplateName(ctx context.Context, options *amifamily.LaunchTemplate) string {
+	return fmt.Sprintf(launchTemplateNameFormat, awssettings.FromContext(ctx).LaunchTemplateNamePrefix, options.ClusterName, launchTemplateHash(options))
+}
+
+// launchTemplateHash is the hash of the resolved configuration of a launch template, which launch templates are
+// tagged with so that they can be reused when their name changes
+func launchTemplateHash(options *amifamily.LaunchTemplate) string {
 	hash, err := hashstructure.Hash(options, hashstructure.FormatV2, nil)
 	if err != nil {
 		panic(fmt.Sprintf("hashing launch template, %s", err))
 	}
-	return fmt.Sprintf(launchTemplateNameFormat, options.ClusterName, fmt.Sprint(hash))
+	return fmt.Sprint(hash)
 }
 
 func (p *LaunchTemplateProvider) Get(ctx context.Context, provider *v1alpha1.AWS, nodeRequest *cloudprovider.NodeRequest, additionalLabels map[string]string) (map[string][]cloudprovider.InstanceType, error) {
@@ -105,6 +115,9 @@ func (p *LaunchTemplateProvider) Get(ctx context.Context, provider *v1alpha1.AWS
 	if err != nil {
 		return nil, err
 	}
+	if err := p.validateKeyPair(ctx, provider.KeyName); err != nil {
+		return nil, err
+	}
 	// Get constrained security groups
 	securityGroupsIDs, err := p.securityGroupProvider.Get(ctx, provider)
 	if err != nil {
@@ -116,9 +129,16 @@ func (p *LaunchTemplateProvider) Get(ctx context.Context, provider *v1alpha1.AWS
 	}
 	resolvedLaunchTemplates, err := p.amiFamily.Resolve(ctx, provider, nodeRequest, &amifamily.Options{
 		ClusterName:             awssettings.FromContext(ctx).ClusterName,
-		ClusterEndpoint:         awssettings.FromContext(ctx).ClusterEndpoint,
+		ClusterEndpoint:         lo.FromPtrOr(provider.ClusterEndpoint, awssettings.FromContext(ctx).ClusterEndpoint),
 		AWSENILimitedPodDensity: awssettings.FromContext(ctx).EnableENILimitedPodDensity,
 		InstanceProfile:         instanceProfile,
+		InstanceStorePolicy:     provider.InstanceStorePolicy,
+		HostContainers:          provider.HostContainers,
+		Sysctls:                 provider.Sysctls,
+		ContainerLogMaxSize:     provider.ContainerLogMaxSize,
+		ContainerLogMaxFiles:    provider.ContainerLogMaxFiles,
+		ImagesToPull:            provider.ImagesToPull,
+		PostBootstrapCommand:    provider.PostBootstrapCommand,
 		SecurityGroupsIDs:       securityGroupsIDs,
 		Tags:                    lo.Assign(awssettings.FromContext(ctx).Tags, provider.Tags),
 		Labels:                  lo.Assign(nodeRequest.Template.Labels, additionalLabels),
@@ -141,23 +161,42 @@ func (p *LaunchTemplateProvider) Get(ctx context.Context, provider *v1alpha1.AWS
 	return launchTemplates, nil
 }
 
+// ResolveAMIs returns the AMIs that the instance types of the node request are currently launched with
+func (p *LaunchTemplateProvider) ResolveAMIs(ctx context.Context, provider *v1alpha1.AWS, nodeRequest *cloudprovider.NodeRequest) (map[string][]cloudprovider.InstanceType, error) {
+	kubeServerVersion, err := p.kubeServerVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return p.amiFamily.ResolveAMIs(ctx, provider, nodeRequest, &amifamily.Options{KubernetesVersion: kubeServerVersion})
+}
+
 func (p *LaunchTemplateProvider) ensureLaunchTemplate(ctx context.Context, options *amifamily.LaunchTemplate) (*ec2.LaunchTemplate, error) {
 	var launchTemplate *ec2.LaunchTemplate
-	name := launchTemplateName(options)
+	name := launchTemplateName(ctx, options)
 	// Read from cache
 	if launchTemplate, ok := p.cache.Get(name); ok {
 		p.cache.SetDefault(name, launchTemplate)
 		return launchTemplate.(*ec2.LaunchTemplate), nil
 	}
+	data, err := p.launchTemplateData(ctx, options)
+	if err != nil {
+		return nil, err
+	}
 	// Attempt to find an existing LT.
 	output, err := p.ec2api.DescribeLaunchTemplatesWithContext(ctx, &ec2.DescribeLaunchTemplatesInput{
 		LaunchTemplateNames: []*string{aws.String(name)},
 	})
-	// Create LT if one doesn't exist
+	// Reuse a managed LT with the same configuration, or create LT if one doesn't exist
 	if awserrors.IsNotFound(err) {
-		launchTemplate, err = p.createLaunchTemplate(ctx, options)
+		launchTemplate, err = p.findLaunchTemplate(ctx, options, data)
 		if err != nil {
-			return nil, fmt.Errorf("creating launch template, %w", err)
+			return nil, fmt.Errorf("finding launch template, %w", err)
+		}
+		if launchTemplate == nil {
+			launchTemplate, err = p.createLaunchTemplate(ctx, options, data)
+			if err != nil {
+				return nil, fmt.Errorf("creating launch template, %w", err)
+			}
 		}
 	} else if err != nil {
 		return nil, fmt.Errorf("describing launch templates, %w", err)
@@ -173,35 +212,128 @@ func (p *LaunchTemplateProvider) ensureLaunchTemplate(ctx context.Context, optio
 	return launchTemplate, nil
 }
 
-func (p *LaunchTemplateProvider) createLaunchTemplate(ctx context.Context, options *amifamily.LaunchTemplate) (*ec2.LaunchTemplate, error) {
+// findLaunchTemplate returns a launch template of the cluster that's tagged with the hash of the options, or nil if
+// there isn't one. Since hashes may collide, a launch template is only returned if its latest version has the data
+// that a launch template for the options would be created with.
+func (p *LaunchTemplateProvider) findLaunchTemplate(ctx context.Context, options *amifamily.LaunchTemplate, data *ec2.RequestLaunchTemplateData) (*ec2.LaunchTemplate, error) {
+	output, err := p.ec2api.DescribeLaunchTemplatesWithContext(ctx, &ec2.DescribeLaunchTemplatesInput{
+		Filters: []*ec2.Filter{
+			{Name: aws.String(fmt.Sprintf("tag:%s", karpenterManagedTagKey)), Values: []*string{aws.String(options.ClusterName)}},
+			{Name: aws.String(fmt.Sprintf("tag:%s", launchTemplateHashTagKey)), Values: []*string{aws.String(launchTemplateHash(options))}},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describing launch templates, %w", err)
+	}
+	for _, launchTemplate := range output.LaunchTemplates {
+		versions, err := p.ec2api.DescribeLaunchTemplateVersionsWithContext(ctx, &ec2.DescribeLaunchTemplateVersionsInput{
+			LaunchTemplateId: launchTemplate.LaunchTemplateId,
+			Versions:         []*string{aws.String("$Latest")},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("describing launch template versions, %w", err)
+		}
+		if len(versions.LaunchTemplateVersions) == 1 && launchTemplateDataMatches(data, versions.LaunchTemplateVersions[0].LaunchTemplateData) {
+			logging.FromContext(ctx).Debugf("Reusing launch template %s with the same configuration", aws.StringValue(launchTemplate.LaunchTemplateName))
+			return launchTemplate, nil
+		}
+		logging.FromContext(ctx).Debugf("Skipping launch template %s, its hash matches but its configuration differs", aws.StringValue(launchTemplate.LaunchTemplateName))
+	}
+	return nil, nil
+}
+
+// launchTemplateDataMatches returns true if every field that's set in the requested data has the same value in the
+// data of an existing launch template. Fields that EC2 defaults, and so are only set in the existing data, are ignored.
+func launchTemplateDataMatches(requested *ec2.RequestLaunchTemplateData, existing *ec2.ResponseLaunchTemplateData) bool {
+	var want, got interface{}
+	if err := json.Unmarshal(lo.Must(json.Marshal(requested)), &want); err != nil {
+		return false
+	}
+	if err := json.Unmarshal(lo.Must(json.Marshal(existing)), &got); err != nil {
+		return false
+	}
+	return jsonContains(got, want)
+}
+
+// jsonContains returns true if the decoded JSON value contains all the fields of the other, recursively
+func jsonContains(value, other interface{}) bool {
+	switch o := other.(type) {
+	case map[string]interface{}:
+		v, ok := value.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, field := range o {
+			if !jsonContains(v[key], field) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		// Lists like tags and security groups are compared regardless of their order
+		v, ok := value.([]interface{})
+		if !ok || len(v) != len(o) {
+			return false
+		}
+		matched := make([]bool, len(v))
+		for _, element := range o {
+			index := -1
+			for j := range v {
+				if !matched[j] && jsonContains(v[j], element) {
+					index = j
+					break
+				}
+			}
+			if index < 0 {
+				return false
+			}
+			matched[index] = true
+		}
+		return true
+	default:
+		return value == other
+	}
+}
+
+func (p *LaunchTemplateProvider) launchTemplateData(ctx context.Context, options *amifamily.LaunchTemplate) (*ec2.RequestLaunchTemplateData, error) {
 	userData, err := options.UserData.Script()
 	if err != nil {
 		return nil, err
 	}
-	output, err := p.ec2api.CreateLaunchTemplateWithContext(ctx, &ec2.CreateLaunchTemplateInput{
-		LaunchTemplateName: aws.String(launchTemplateName(options)),
-		LaunchTemplateData: &ec2.RequestLaunchTemplateData{
-			BlockDeviceMappings: p.blockDeviceMappings(options.BlockDeviceMappings),
-			IamInstanceProfile: &ec2.LaunchTemplateIamInstanceProfileSpecificationRequest{
-				Name: aws.String(options.InstanceProfile),
-			},
-			SecurityGroupIds: aws.StringSlice(options.SecurityGroupsIDs),
-			UserData:         aws.String(userData),
-			ImageId:          aws.String(options.AMIID),
-			MetadataOptions: &ec2.LaunchTemplateInstanceMetadataOptionsRequest{
-				HttpEndpoint:            options.MetadataOptions.HTTPEndpoint,
-				HttpProtocolIpv6:        options.MetadataOptions.HTTPProtocolIPv6,
-				HttpPutResponseHopLimit: options.MetadataOptions.HTTPPutResponseHopLimit,
-				HttpTokens:              options.MetadataOptions.HTTPTokens,
-			},
-			TagSpecifications: []*ec2.LaunchTemplateTagSpecificationRequest{
-				{ResourceType: aws.String(ec2.ResourceTypeNetworkInterface), Tags: v1alpha1.MergeTags(ctx, options.Tags)},
-			},
+	return &ec2.RequestLaunchTemplateData{
+		BlockDeviceMappings: p.blockDeviceMappings(options.BlockDeviceMappings),
+		IamInstanceProfile: &ec2.LaunchTemplateIamInstanceProfileSpecificationRequest{
+			Name: aws.String(options.InstanceProfile),
+		},
+		SecurityGroupIds:                  aws.StringSlice(options.SecurityGroupsIDs),
+		UserData:                          aws.String(userData),
+		ImageId:                           aws.String(options.AMIID),
+		KeyName:                           options.KeyName,
+		DisableApiTermination:             options.DisableAPITermination,
+		InstanceInitiatedShutdownBehavior: options.InstanceInitiatedShutdownBehavior,
+		MetadataOptions: &ec2.LaunchTemplateInstanceMetadataOptionsRequest{
+			HttpEndpoint:            options.MetadataOptions.HTTPEndpoint,
+			HttpProtocolIpv6:        options.MetadataOptions.HTTPProtocolIPv6,
+			HttpPutResponseHopLimit: options.MetadataOptions.HTTPPutResponseHopLimit,
+			HttpTokens:              options.MetadataOptions.HTTPTokens,
+		},
+		TagSpecifications: []*ec2.LaunchTemplateTagSpecificationRequest{
+			{ResourceType: aws.String(ec2.ResourceTypeNetworkInterface), Tags: v1alpha1.MergeTags(ctx, options.Tags)},
 		},
+	}, nil
+}
+
+func (p *LaunchTemplateProvider) createLaunchTemplate(ctx context.Context, options *amifamily.LaunchTemplate, data *ec2.RequestLaunchTemplateData) (*ec2.LaunchTemplate, error) {
+	output, err := p.ec2api.CreateLaunchTemplateWithContext(ctx, &ec2.CreateLaunchTemplateInput{
+		LaunchTemplateName: aws.String(launchTemplateName(ctx, options)),
+		LaunchTemplateData: data,
 		TagSpecifications: []*ec2.TagSpecification{
 			{
 				ResourceType: aws.String(ec2.ResourceTypeLaunchTemplate),
-				Tags:         v1alpha1.MergeTags(ctx, options.Tags, map[string]string{karpenterManagedTagKey: options.ClusterName}),
+				Tags: v1alpha1.MergeTags(ctx, options.Tags, map[string]string{
+					karpenterManagedTagKey:   options.ClusterName,
+					launchTemplateHashTagKey: launchTemplateHash(options),
+				}),
 			},
 		},
 	})
@@ -252,6 +384,12 @@ func (p *LaunchTemplateProvider) Invalidate(ctx context.Context, ltName string)
 	p.cache.OnEvicted(nil)
 	logging.FromContext(ctx).Debugf("Invalidating launch template \"%s\" in the cache because it no longer exists", ltName)
 	p.cache.Delete(ltName)
+	// Reused launch templates are cached by the name that they would have been created with
+	for key, item := range p.cache.Items() {
+		if lt, ok := item.Object.(*ec2.LaunchTemplate); ok && aws.StringValue(lt.LaunchTemplateName) == ltName {
+			p.cache.Delete(key)
+		}
+	}
 }
 
 // hydrateCache queries for existing Launch Templates created by Karpenter for the current cluster and adds to the LT cache.
@@ -274,7 +412,7 @@ func (p *LaunchTemplateProvider) hydrateCache(ctx context.Context) {
 
 func (p *LaunchTemplateProvider) cachedEvictedFunc(ctx context.Context) func(string, interface{}) {
 	return func(key string, lt interface{}) {
-		if key == kubernetesVersionCacheKey {
+		if key == kubernetesVersionCacheKey || strings.HasPrefix(key, keyPairCacheKeyPrefix) {
 			return
 		}
 		p.Lock()
@@ -283,6 +421,10 @@ func (p *LaunchTemplateProvider) cachedEvictedFunc(ctx context.Context) func(str
 			return
 		}
 		launchTemplate := lt.(*ec2.LaunchTemplate)
+		// A reused launch template is also cached under the name that it would have been created with
+		if p.cachedUnderAnotherKey(key, launchTemplate) {
+			return
+		}
 		if _, err := p.ec2api.DeleteLaunchTemplate(&ec2.DeleteLaunchTemplateInput{LaunchTemplateId: launchTemplate.LaunchTemplateId}); err != nil {
 			logging.FromContext(ctx).Errorf("Unable to delete launch template, %v", err)
 			return
@@ -291,6 +433,17 @@ func (p *LaunchTemplateProvider) cachedEvictedFunc(ctx context.Context) func(str
 	}
 }
 
+// cachedUnderAnotherKey returns true if a cache entry other than the key still holds the launch template
+func (p *LaunchTemplateProvider) cachedUnderAnotherKey(key string, launchTemplate *ec2.LaunchTemplate) bool {
+	for otherKey, item := range p.cache.Items() {
+		if other, ok := item.Object.(*ec2.LaunchTemplate); ok && otherKey != key &&
+			aws.StringValue(other.LaunchTemplateId) == aws.StringValue(launchTemplate.LaunchTemplateId) {
+			return true
+		}
+	}
+	return false
+}
+
 func (p *LaunchTemplateProvider) getInstanceProfile(ctx context.Context, provider *v1alpha1.AWS) (string, error) {
 	if provider.InstanceProfile != nil {
 		return aws.StringValue(provider.InstanceProfile), nil
@@ -302,6 +455,30 @@ func (p *LaunchTemplateProvider) getInstanceProfile(ctx context.Context, provide
 	return defaultProfile, nil
 }
 
+// validateKeyPair fails fast when the key pair doesn't exist, rather than with every launch. Key pairs that exist are
+// cached, since they are deleted rarely and EC2 rejects the launch when they are.
+func (p *LaunchTemplateProvider) validateKeyPair(ctx context.Context, keyName *string) error {
+	if keyName == nil {
+		return nil
+	}
+	if p.cm.HasChanged("key-name", aws.StringValue(keyName)) {
+		logging.FromContext(ctx).Warnf("Launching nodes that allow SSH access with key pair %s, anyone holding its private key can log in to them", aws.StringValue(keyName))
+	}
+	cacheKey := keyPairCacheKeyPrefix + aws.StringValue(keyName)
+	if _, ok := p.cache.Get(cacheKey); ok {
+		return nil
+	}
+	output, err := p.ec2api.DescribeKeyPairsWithContext(ctx, &ec2.DescribeKeyPairsInput{KeyNames: []*string{keyName}})
+	if err != nil && !awserrors.IsNotFound(err) {
+		return fmt.Errorf("describing key pair %s, %w", aws.StringValue(keyName), err)
+	}
+	if err != nil || len(output.KeyPairs) == 0 {
+		return fmt.Errorf("key pair %s not found", aws.StringValue(keyName))
+	}
+	p.cache.SetDefault(cacheKey, keyName)
+	return nil
+}
+
 func (p *LaunchTemplateProvider) kubeServerVersion(ctx context.Context) (string, error) {
 	if version, ok := p.cache.Get(kubernetesVersionCacheKey); ok {
 		return version.(string), nil
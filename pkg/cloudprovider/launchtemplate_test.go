@@ -29,6 +29,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ssm"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/samber/lo"
@@ -42,14 +43,18 @@ import (
 
 	"github.com/aws/karpenter-core/pkg/apis/config/settings"
 	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/cloudprovider"
 	"github.com/aws/karpenter-core/pkg/controllers/provisioning"
 	"github.com/aws/karpenter-core/pkg/operator/injection"
+	"github.com/aws/karpenter-core/pkg/scheduling"
 	coretest "github.com/aws/karpenter-core/pkg/test"
 	. "github.com/aws/karpenter-core/pkg/test/expectations"
+	"github.com/aws/karpenter-core/pkg/utils/project"
 	awssettings "github.com/aws/karpenter/pkg/apis/config/settings"
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
 	"github.com/aws/karpenter/pkg/cloudprovider/amifamily/bootstrap"
 	"github.com/aws/karpenter/pkg/test"
+	"github.com/aws/karpenter/pkg/utils"
 )
 
 var _ = Describe("LaunchTemplates", func() {
@@ -116,6 +121,31 @@ var _ = Describe("LaunchTemplates", func() {
 			Expect(*launchTemplate.LaunchTemplateName).To(Equal("test-launch-template"))
 			Expect(*launchTemplate.Version).To(Equal("$Latest"))
 		})
+		It("should name generated launch templates after the cluster with the default prefix", func() {
+			ExpectApplied(ctx, env.Client, provisioner)
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+			input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+			Expect(aws.StringValue(input.LaunchTemplateName)).To(MatchRegexp(`^Karpenter-test-cluster-[0-9]+$`))
+		})
+		It("should name generated launch templates with the configured prefix and tag them for the cluster", func() {
+			settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{
+				LaunchTemplateNamePrefix: aws.String("team-a/karpenter"),
+			})
+			ctx = settingsStore.InjectSettings(ctx)
+
+			ExpectApplied(ctx, env.Client, provisioner)
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+			input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+			Expect(aws.StringValue(input.LaunchTemplateName)).To(MatchRegexp(`^team-a/karpenter-test-cluster-[0-9]+$`))
+			Expect(len(aws.StringValue(input.LaunchTemplateName))).To(BeNumerically("<=", 128))
+			Expect(input.TagSpecifications[0].Tags).To(ContainElement(&ec2.Tag{Key: aws.String("karpenter.k8s.aws/cluster"), Value: aws.String("test-cluster")}))
+			Expect(aws.StringValue(fakeEC2API.CalledWithCreateFleetInput.Pop().LaunchTemplateConfigs[0].LaunchTemplateSpecification.LaunchTemplateName)).
+				To(Equal(aws.StringValue(input.LaunchTemplateName)))
+		})
 	})
 	Context("Cache", func() {
 		It("should use same launch template for equivalent constraints", func() {
@@ -170,6 +200,69 @@ var _ = Describe("LaunchTemplates", func() {
 			name2 := fakeEC2API.CalledWithCreateFleetInput.Pop().LaunchTemplateConfigs[0].LaunchTemplateSpecification.LaunchTemplateName
 			Expect(name1).To(Equal(name2))
 		})
+		It("should reuse an existing launch template with the same configuration", func() {
+			ExpectApplied(ctx, env.Client, provisioner)
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+			ltName := aws.StringValue(fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().LaunchTemplateName)
+			fakeEC2API.CalledWithCreateFleetInput.Reset()
+
+			// Renaming generated launch templates shouldn't create new ones for the same configuration
+			launchTemplateCache.Flush()
+			settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{
+				LaunchTemplateNamePrefix: aws.String("renamed"),
+			})
+			ctx = settingsStore.InjectSettings(ctx)
+			pod = ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(0))
+			Expect(fakeEC2API.CalledWithCreateFleetInput.Len()).To(Equal(1))
+			Expect(aws.StringValue(fakeEC2API.CalledWithCreateFleetInput.Pop().LaunchTemplateConfigs[0].LaunchTemplateSpecification.LaunchTemplateName)).
+				To(Equal(ltName))
+		})
+		It("should not delete a reused launch template while it's cached under another name", func() {
+			launchTemplateCache.OnEvicted(cloudProvider.instanceProvider.launchTemplateProvider.cachedEvictedFunc(ctx))
+			DeferCleanup(func() { launchTemplateCache.OnEvicted(nil) })
+			launchTemplate := &ec2.LaunchTemplate{LaunchTemplateId: aws.String("lt-123"), LaunchTemplateName: aws.String("Karpenter-test-cluster-123")}
+			// The cache is hydrated with the launch template under its own name, and it's reused under a new name
+			launchTemplateCache.Set("Karpenter-test-cluster-123", launchTemplate, time.Millisecond)
+			launchTemplateCache.SetDefault("renamed-test-cluster-123", launchTemplate)
+			Eventually(func() int {
+				launchTemplateCache.DeleteExpired()
+				return launchTemplateCache.ItemCount()
+			}).Should(Equal(1))
+			Expect(fakeEC2API.CalledWithDeleteLaunchTemplateInput.Len()).To(Equal(0))
+
+			// Once no other name holds the launch template, it's deleted when it's evicted
+			launchTemplateCache.Delete("renamed-test-cluster-123")
+			Expect(fakeEC2API.CalledWithDeleteLaunchTemplateInput.Len()).To(Equal(1))
+			Expect(aws.StringValue(fakeEC2API.CalledWithDeleteLaunchTemplateInput.Pop().LaunchTemplateId)).To(Equal("lt-123"))
+		})
+		It("should not reuse a launch template whose hash matches but whose configuration differs", func() {
+			ExpectApplied(ctx, env.Client, provisioner)
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+			ltName := aws.StringValue(fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().LaunchTemplateName)
+
+			// Simulate a collision by changing the image of the existing launch template
+			fakeEC2API.LaunchTemplateData.Range(func(_, data any) bool {
+				data.(*ec2.ResponseLaunchTemplateData).ImageId = aws.String("ami-collision")
+				return true
+			})
+			launchTemplateCache.Flush()
+			settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{
+				LaunchTemplateNamePrefix: aws.String("renamed"),
+			})
+			ctx = settingsStore.InjectSettings(ctx)
+			pod = ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+			input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+			Expect(aws.StringValue(input.LaunchTemplateName)).ToNot(Equal(ltName))
+			Expect(aws.StringValue(input.LaunchTemplateName)).To(HavePrefix("renamed-"))
+		})
 		It("should recover from an out-of-sync launch template cache", func() {
 			ExpectApplied(ctx, env.Client, provisioner)
 			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
@@ -228,6 +321,43 @@ var _ = Describe("LaunchTemplates", func() {
 			Expect(node.Labels).To(HaveKeyWithValue(v1alpha1.LabelInstanceAMIID, "ami-123"))
 		})
 	})
+	Context("Annotations", func() {
+		It("should annotate the node with the resolved AMI and the AMI family", func() {
+			fakeEC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+				{
+					ImageId:      aws.String("ami-123"),
+					Architecture: aws.String("x86_64"),
+					CreationDate: aws.String("2022-08-15T12:00:00Z"),
+				},
+			}})
+			provider.AMIFamily = aws.String(v1alpha1.AMIFamilyBottlerocket)
+			nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+				AMISelector: map[string]string{"karpenter.sh/discovery": "my-cluster"},
+				AWS:         *provider,
+			})
+			ExpectApplied(ctx, env.Client, nodeTemplate, test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			node := ExpectScheduled(ctx, env.Client, pod)
+			Expect(node.Annotations).To(HaveKeyWithValue(v1alpha1.AnnotationAMIID, "ami-123"))
+			Expect(node.Annotations).To(HaveKeyWithValue(v1alpha1.AnnotationAMIFamily, v1alpha1.AMIFamilyBottlerocket))
+		})
+		It("should annotate the node with the default AMI family", func() {
+			ExpectApplied(ctx, env.Client, provisioner)
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			node := ExpectScheduled(ctx, env.Client, pod)
+			Expect(node.Annotations).To(HaveKeyWithValue(v1alpha1.AnnotationAMIID, node.Labels[v1alpha1.LabelInstanceAMIID]))
+			Expect(node.Annotations).To(HaveKeyWithValue(v1alpha1.AnnotationAMIFamily, v1alpha1.AMIFamilyAL2))
+		})
+		It("should not annotate the node with an AMI family when the launch template is specified", func() {
+			provider.LaunchTemplateName = aws.String("test-launch-template")
+			provider.SecurityGroupSelector = nil
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			node := ExpectScheduled(ctx, env.Client, pod)
+			Expect(node.Annotations).To(HaveKey(v1alpha1.AnnotationAMIID))
+			Expect(node.Annotations).ToNot(HaveKey(v1alpha1.AnnotationAMIFamily))
+		})
+	})
 	Context("Tags", func() {
 		It("should tag with provisioner name", func() {
 			provisionerName := "the-provisioner"
@@ -299,6 +429,99 @@ var _ = Describe("LaunchTemplates", func() {
 			Expect(*createFleetInput.TagSpecifications[2].ResourceType).To(Equal(ec2.ResourceTypeFleet))
 			ExpectTags(createFleetInput.TagSpecifications[2].Tags, provider.Tags)
 		})
+		It("should merge tags inherited from a base template", func() {
+			base := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{AWS: v1alpha1.AWS{
+				SubnetSelector:        provider.SubnetSelector,
+				SecurityGroupSelector: provider.SecurityGroupSelector,
+				Tags:                  map[string]string{"team": "platform", "cost-center": "1234"},
+				LaunchTemplate: v1alpha1.LaunchTemplate{MetadataOptions: &v1alpha1.MetadataOptions{
+					HTTPTokens: aws.String(ec2.LaunchTemplateHttpTokensStateOptional),
+				}},
+			}})
+			nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+				BaseTemplateRef: &v1alpha1.BaseTemplateRef{Name: base.Name},
+				AWS:             v1alpha1.AWS{Tags: map[string]string{"team": "ml"}},
+			})
+			ExpectApplied(ctx, env.Client, base, nodeTemplate, test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateFleetInput.Len()).To(Equal(1))
+			createFleetInput := fakeEC2API.CalledWithCreateFleetInput.Pop()
+			ExpectTags(createFleetInput.TagSpecifications[0].Tags, map[string]string{"team": "ml", "cost-center": "1234"})
+
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+			input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+			Expect(*input.LaunchTemplateData.MetadataOptions.HttpTokens).To(Equal(ec2.LaunchTemplateHttpTokensStateOptional))
+		})
+		It("should render user data inherited from a base template", func() {
+			base := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+				AWS: v1alpha1.AWS{
+					SubnetSelector:        provider.SubnetSelector,
+					SecurityGroupSelector: provider.SecurityGroupSelector,
+				},
+				UserData: aws.String("#!/bin/bash\necho inherited-user-data"),
+			})
+			nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+				BaseTemplateRef: &v1alpha1.BaseTemplateRef{Name: base.Name},
+			})
+			ExpectApplied(ctx, env.Client, base, nodeTemplate, test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+			input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+			userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+			Expect(string(userData)).To(ContainSubstring("echo inherited-user-data"))
+		})
+		Context("Version Tags", func() {
+			BeforeEach(func() {
+				settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{
+					EnableVersionTags: lo.ToPtr(true),
+				})
+				ctx = settingsStore.InjectSettings(ctx)
+			})
+			It("should tag with the karpenter version", func() {
+				ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				createFleetInput := fakeEC2API.CalledWithCreateFleetInput.Pop()
+				for _, tagSpecification := range createFleetInput.TagSpecifications {
+					ExpectTags(tagSpecification.Tags, map[string]string{v1alpha1.TagKarpenterVersion: project.Version})
+					ExpectTagsNotFound(tagSpecification.Tags, map[string]string{v1alpha1.TagNodeTemplateGeneration: "1"})
+				}
+			})
+			It("should tag with the generation of the node template", func() {
+				nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{AWS: *provider})
+				ExpectApplied(ctx, env.Client, nodeTemplate, test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}}))
+				Expect(env.Client.Get(ctx, client.ObjectKeyFromObject(nodeTemplate), nodeTemplate)).To(Succeed())
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				createFleetInput := fakeEC2API.CalledWithCreateFleetInput.Pop()
+				ExpectTags(createFleetInput.TagSpecifications[0].Tags, map[string]string{
+					v1alpha1.TagKarpenterVersion:       project.Version,
+					v1alpha1.TagNodeTemplateGeneration: fmt.Sprint(nodeTemplate.Generation),
+				})
+			})
+			It("should not tag with the karpenter version when version tags are disabled", func() {
+				settingsStore[awssettings.ContextKey] = test.Settings()
+				ctx = settingsStore.InjectSettings(ctx)
+				ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				createFleetInput := fakeEC2API.CalledWithCreateFleetInput.Pop()
+				ExpectTagsNotFound(createFleetInput.TagSpecifications[0].Tags, map[string]string{v1alpha1.TagKarpenterVersion: project.Version})
+			})
+			It("should skip the version tags when they exceed the tag limit", func() {
+				// with the provisioner name, Name and cluster tags, the instances have the maximum of 50 tags
+				provider.Tags = lo.SliceToMap(lo.Range(47), func(i int) (string, string) { return fmt.Sprintf("tag%d", i), "value" })
+				ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				createFleetInput := fakeEC2API.CalledWithCreateFleetInput.Pop()
+				Expect(createFleetInput.TagSpecifications[0].Tags).To(HaveLen(50))
+				ExpectTags(createFleetInput.TagSpecifications[0].Tags, provider.Tags)
+				ExpectTagsNotFound(createFleetInput.TagSpecifications[0].Tags, map[string]string{v1alpha1.TagKarpenterVersion: project.Version})
+			})
+		})
 		It("should merge global tags into launch template and volume tags", func() {
 			provider.Tags = map[string]string{
 				"tag1": "tag1value",
@@ -374,6 +597,62 @@ var _ = Describe("LaunchTemplates", func() {
 			ExpectTags(createFleetInput.TagSpecifications[2].Tags, provider.Tags)
 			ExpectTagsNotFound(createFleetInput.TagSpecifications[0].Tags, settingsTags)
 		})
+		Context("Name Tag Template", func() {
+			setNameTagTemplate := func(template string) {
+				settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{NameTagTemplate: lo.ToPtr(template)})
+				ctx = settingsStore.InjectSettings(ctx)
+				prov = provisioning.NewProvisioner(injection.WithOptions(ctx, opts), env.Client, corev1.NewForConfigOrDie(env.Config), recorder, cloudProvider, cluster, settingsStore)
+				controller = provisioning.NewController(env.Client, prov, recorder)
+			}
+			It("should render the name tag at launch when all variables are known", func() {
+				setNameTagTemplate("{cluster}-{provisioner}-{capacity-type}")
+				ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider, ObjectMeta: metav1.ObjectMeta{Name: "the-provisioner"}}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateFleetInput.Len()).To(Equal(1))
+				createFleetInput := fakeEC2API.CalledWithCreateFleetInput.Pop()
+				for _, tagSpecification := range createFleetInput.TagSpecifications {
+					ExpectTags(tagSpecification.Tags, map[string]string{"Name": "test-cluster-the-provisioner-on-demand"})
+				}
+				Expect(fakeEC2API.CalledWithCreateTagsInput.Len()).To(Equal(0))
+			})
+			It("should tag the instance after launch when the template references the instance", func() {
+				setNameTagTemplate("karpenter-{provisioner}-{instance-id}")
+				ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider, ObjectMeta: metav1.ObjectMeta{Name: "the-provisioner"}}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				node := ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateFleetInput.Len()).To(Equal(1))
+				createFleetInput := fakeEC2API.CalledWithCreateFleetInput.Pop()
+				ExpectTags(createFleetInput.TagSpecifications[0].Tags, map[string]string{"Name": fmt.Sprintf("%s/%s", v1alpha5.ProvisionerNameLabelKey, "the-provisioner")})
+
+				Expect(fakeEC2API.CalledWithCreateTagsInput.Len()).To(Equal(1))
+				createTagsInput := fakeEC2API.CalledWithCreateTagsInput.Pop()
+				instanceID := lo.Must(utils.ParseInstanceID(node))
+				Expect(aws.StringValueSlice(createTagsInput.Resources)).To(ConsistOf(aws.StringValue(instanceID)))
+				ExpectTags(createTagsInput.Tags, map[string]string{"Name": fmt.Sprintf("karpenter-the-provisioner-%s", aws.StringValue(instanceID))})
+			})
+			It("should not override a user provided name tag", func() {
+				setNameTagTemplate("karpenter-{provisioner}-{instance-id}")
+				provider.Tags = map[string]string{"Name": "myname"}
+				ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateFleetInput.Len()).To(Equal(1))
+				createFleetInput := fakeEC2API.CalledWithCreateFleetInput.Pop()
+				ExpectTags(createFleetInput.TagSpecifications[0].Tags, provider.Tags)
+				Expect(fakeEC2API.CalledWithCreateTagsInput.Len()).To(Equal(0))
+			})
+			It("should ignore the template when the rendered name is too long", func() {
+				setNameTagTemplate(strings.Repeat("a", 256) + "-{provisioner}")
+				ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider, ObjectMeta: metav1.ObjectMeta{Name: "the-provisioner"}}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateFleetInput.Len()).To(Equal(1))
+				createFleetInput := fakeEC2API.CalledWithCreateFleetInput.Pop()
+				ExpectTags(createFleetInput.TagSpecifications[0].Tags, map[string]string{"Name": fmt.Sprintf("%s/%s", v1alpha5.ProvisionerNameLabelKey, "the-provisioner")})
+				Expect(fakeEC2API.CalledWithCreateTagsInput.Len()).To(Equal(0))
+			})
+		})
 	})
 	Context("Block Device Mappings", func() {
 		It("should default AL2 block device mappings", func() {
@@ -632,6 +911,77 @@ var _ = Describe("LaunchTemplates", func() {
 			ExpectScheduled(ctx, env.Client, pod)
 		})
 	})
+	Context("Key Name", func() {
+		It("should not set a key name by default", func() {
+			ExpectApplied(ctx, env.Client, provisioner)
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+			for fakeEC2API.CalledWithCreateLaunchTemplateInput.Len() > 0 {
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().LaunchTemplateData.KeyName).To(BeNil())
+			}
+		})
+		It("should set the key name of the launch template", func() {
+			provider.KeyName = aws.String("my-key-pair")
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+			for fakeEC2API.CalledWithCreateLaunchTemplateInput.Len() > 0 {
+				Expect(aws.StringValue(fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().LaunchTemplateData.KeyName)).To(Equal("my-key-pair"))
+			}
+		})
+		It("should not launch when the key pair doesn't exist", func() {
+			provider.KeyName = aws.String("my-key-pair")
+			fakeEC2API.DescribeKeyPairsOutput.Set(&ec2.DescribeKeyPairsOutput{})
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectNotScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(0))
+		})
+	})
+	Context("Termination Protection", func() {
+		It("should not disable API termination by default", func() {
+			ExpectApplied(ctx, env.Client, provisioner)
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+			for fakeEC2API.CalledWithCreateLaunchTemplateInput.Len() > 0 {
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().LaunchTemplateData.DisableApiTermination).To(BeNil())
+			}
+		})
+		It("should disable API termination in the launch template", func() {
+			provider.DisableAPITermination = aws.Bool(true)
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+			for fakeEC2API.CalledWithCreateLaunchTemplateInput.Len() > 0 {
+				Expect(aws.BoolValue(fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().LaunchTemplateData.DisableApiTermination)).To(BeTrue())
+			}
+		})
+	})
+	Context("Shutdown Behavior", func() {
+		It("should not set the shutdown behavior by default", func() {
+			ExpectApplied(ctx, env.Client, provisioner)
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+			for fakeEC2API.CalledWithCreateLaunchTemplateInput.Len() > 0 {
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().LaunchTemplateData.InstanceInitiatedShutdownBehavior).To(BeNil())
+			}
+		})
+		It("should set the shutdown behavior of the launch template", func() {
+			provider.InstanceInitiatedShutdownBehavior = aws.String(ec2.ShutdownBehaviorStop)
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+			for fakeEC2API.CalledWithCreateLaunchTemplateInput.Len() > 0 {
+				Expect(aws.StringValue(fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().LaunchTemplateData.InstanceInitiatedShutdownBehavior)).To(Equal(ec2.ShutdownBehaviorStop))
+			}
+		})
+	})
 	Context("AL2", func() {
 		It("should calculate memory overhead based on eni limited pods when ENI limited", func() {
 			settingsStore = coretest.SettingsStore{
@@ -746,6 +1096,48 @@ var _ = Describe("LaunchTemplates", func() {
 			Expect(string(userData)).To(ContainSubstring("--use-max-pods false"))
 			Expect(string(userData)).To(ContainSubstring("--max-pods=10"))
 		})
+		It("should specify --use-max-pods=false and the prefix delegation --max-pods when prefix delegation is enabled", func() {
+			provider.PrefixDelegation = lo.ToPtr(true)
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod(coretest.PodOptions{
+				NodeSelector: map[string]string{v1.LabelInstanceTypeStable: "m5.large"},
+			}))[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+			input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+			userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+			Expect(string(userData)).To(ContainSubstring("--use-max-pods false"))
+			Expect(string(userData)).To(ContainSubstring("--max-pods=110"))
+		})
+		It("should generate a launch template per max-pods value when prefix delegation is enabled", func() {
+			provider.PrefixDelegation = lo.ToPtr(true)
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod(coretest.PodOptions{
+				NodeRequirements: []v1.NodeSelectorRequirement{
+					{Key: v1.LabelInstanceTypeStable, Operator: v1.NodeSelectorOpIn, Values: []string{"m5.large", "m5.metal"}},
+				},
+			}))[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(2))
+			var userData []string
+			for fakeEC2API.CalledWithCreateLaunchTemplateInput.Len() > 0 {
+				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+				decoded, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+				userData = append(userData, string(decoded))
+			}
+			Expect(userData).To(ConsistOf(ContainSubstring("--max-pods=110"), ContainSubstring("--max-pods=250")))
+		})
+		It("should specify the user --max-pods over prefix delegation when user specifies maxPods in Provisioner", func() {
+			provider.PrefixDelegation = lo.ToPtr(true)
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider, Kubelet: &v1alpha5.KubeletConfiguration{MaxPods: aws.Int32(10)}}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+			input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+			userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+			Expect(string(userData)).To(ContainSubstring("--max-pods=10"))
+			Expect(string(userData)).NotTo(ContainSubstring("--max-pods=110"))
+		})
 		It("should specify --system-reserved when overriding system reserved values", func() {
 			provisioner = test.Provisioner(coretest.ProvisionerOptions{
 				Kubelet: &v1alpha5.KubeletConfiguration{
@@ -798,6 +1190,78 @@ var _ = Describe("LaunchTemplates", func() {
 				Expect(rem[:i]).To(ContainSubstring(fmt.Sprintf("%v=%v", k.String(), v.String())))
 			}
 		})
+		It("should specify the node template reserved resources and eviction thresholds", func() {
+			nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+				AWS: *provider,
+				SystemReserved: v1.ResourceList{
+					v1.ResourceCPU: resource.MustParse("500m"),
+				},
+				KubeReserved: v1.ResourceList{
+					v1.ResourceMemory: resource.MustParse("1Gi"),
+				},
+				EvictionThreshold: map[string]string{"memory.available": "500Mi"},
+			})
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{
+				ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name},
+				Kubelet: &v1alpha5.KubeletConfiguration{
+					SystemReserved: v1.ResourceList{
+						v1.ResourceCPU:    resource.MustParse("100m"),
+						v1.ResourceMemory: resource.MustParse("200Mi"),
+					},
+				},
+			}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+			input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+			userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+
+			arg := "--system-reserved="
+			i := strings.Index(string(userData), arg)
+			rem := string(userData)[(i + len(arg)):]
+			i = strings.Index(rem, " ")
+			Expect(rem[:i]).To(ContainSubstring("cpu=500m"))
+			Expect(rem[:i]).To(ContainSubstring("memory=200Mi"))
+			Expect(string(userData)).To(ContainSubstring("--kube-reserved=memory=1Gi"))
+			Expect(string(userData)).To(ContainSubstring("--eviction-hard=memory.available<500Mi"))
+		})
+		It("should specify the node template soft eviction thresholds and grace periods", func() {
+			nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+				AWS:                       *provider,
+				EvictionSoft:              map[string]string{"memory.available": "10%"},
+				EvictionSoftGracePeriod:   map[string]metav1.Duration{"memory.available": {Duration: time.Minute}},
+				EvictionMaxPodGracePeriod: aws.Int32(30),
+			})
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{
+				ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name},
+				Kubelet: &v1alpha5.KubeletConfiguration{
+					EvictionSoft:              map[string]string{"nodefs.available": "15%"},
+					EvictionSoftGracePeriod:   map[string]metav1.Duration{"nodefs.available": {Duration: time.Minute * 2}},
+					EvictionMaxPodGracePeriod: aws.Int32(10),
+				},
+			}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+			input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+			userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+
+			arg := "--eviction-soft="
+			i := strings.Index(string(userData), arg)
+			rem := string(userData)[(i + len(arg)):]
+			i = strings.Index(rem, " ")
+			Expect(rem[:i]).To(ContainSubstring("memory.available<10%"))
+			Expect(rem[:i]).To(ContainSubstring("nodefs.available<15%"))
+			arg = "--eviction-soft-grace-period="
+			i = strings.Index(string(userData), arg)
+			rem = string(userData)[(i + len(arg)):]
+			i = strings.Index(rem, " ")
+			Expect(rem[:i]).To(ContainSubstring("memory.available=1m0s"))
+			Expect(rem[:i]).To(ContainSubstring("nodefs.available=2m0s"))
+			Expect(string(userData)).To(ContainSubstring("--eviction-max-pod-grace-period=30"))
+		})
 		It("should pass eviction hard threshold values when specified", func() {
 			provisioner = test.Provisioner(coretest.ProvisionerOptions{
 				Kubelet: &v1alpha5.KubeletConfiguration{
@@ -942,37 +1406,311 @@ var _ = Describe("LaunchTemplates", func() {
 			userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
 			Expect(string(userData)).To(ContainSubstring("--container-runtime dockerd"))
 		})
-		It("should specify --container-runtime docker when using Neuron GPUs", func() {
+		It("should specify the container runtime of the node template over the provisionerSpec", func() {
+			nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+				AWS:              *provider,
+				ContainerRuntime: aws.String("dockerd"),
+			})
+			ExpectApplied(ctx, env.Client, nodeTemplate)
 			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{
-				Provider:     provider,
-				Requirements: []v1.NodeSelectorRequirement{{Key: v1alpha1.LabelInstanceCategory, Operator: v1.NodeSelectorOpExists}},
+				ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name},
+				Kubelet:     &v1alpha5.KubeletConfiguration{ContainerRuntime: aws.String("containerd")},
 			}))
-			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod(coretest.PodOptions{
-				ResourceRequirements: v1.ResourceRequirements{
-					Requests: map[v1.ResourceName]resource.Quantity{
-						v1.ResourceCPU:             resource.MustParse("1"),
-						v1alpha1.ResourceAWSNeuron: resource.MustParse("1"),
-					},
-					Limits: map[v1.ResourceName]resource.Quantity{
-						v1alpha1.ResourceAWSNeuron: resource.MustParse("1"),
-					},
-				},
-			}))[0]
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
 			ExpectScheduled(ctx, env.Client, pod)
 			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
 			input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
 			userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
-			Expect(string(userData)).To(ContainSubstring("--container-runtime docker"))
+			Expect(string(userData)).To(ContainSubstring("--container-runtime dockerd"))
 		})
-		It("should specify --container-runtime containerd when using Nvidia GPUs", func() {
+		It("should register with the node template registration taints (AL2)", func() {
+			nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+				AWS:                *provider,
+				RegistrationTaints: []v1.Taint{{Key: "example.com/not-ready", Value: "true", Effect: v1.TaintEffectNoSchedule}},
+			})
+			ExpectApplied(ctx, env.Client, nodeTemplate)
 			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{
-				Provider:     provider,
-				Requirements: []v1.NodeSelectorRequirement{{Key: v1alpha1.LabelInstanceCategory, Operator: v1.NodeSelectorOpExists}},
+				ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name},
+				Taints:      []v1.Taint{{Key: "foo", Value: "bar", Effect: v1.TaintEffectNoExecute}},
 			}))
 			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod(coretest.PodOptions{
-				ResourceRequirements: v1.ResourceRequirements{
-					Requests: map[v1.ResourceName]resource.Quantity{
-						v1.ResourceCPU:             resource.MustParse("1"),
+				Tolerations: []v1.Toleration{{Operator: v1.TolerationOpExists}},
+			}))[0]
+			node := ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+			input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+			userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+
+			arg := "--register-with-taints="
+			i := strings.Index(string(userData), arg)
+			rem := string(userData)[(i + len(arg)):]
+			i = strings.IndexAny(rem, " '")
+			Expect(rem[:i]).To(ContainSubstring("foo=bar:NoExecute"))
+			Expect(rem[:i]).To(ContainSubstring("example.com/not-ready=true:NoSchedule"))
+			Expect(node.Spec.Taints).To(ConsistOf(
+				v1.Taint{Key: "foo", Value: "bar", Effect: v1.TaintEffectNoExecute},
+				v1.Taint{Key: "example.com/not-ready", Value: "true", Effect: v1.TaintEffectNoSchedule},
+			))
+		})
+		It("should register with the node template registration taints (Bottlerocket)", func() {
+			provider.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+			nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+				AWS:                *provider,
+				RegistrationTaints: []v1.Taint{{Key: "example.com/not-ready", Value: "true", Effect: v1.TaintEffectNoSchedule}},
+			})
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{
+				ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name},
+				Taints:      []v1.Taint{{Key: "foo", Value: "bar", Effect: v1.TaintEffectNoExecute}},
+			}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod(coretest.PodOptions{
+				Tolerations: []v1.Toleration{{Operator: v1.TolerationOpExists}},
+			}))[0]
+			node := ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+			input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+			userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+			config := &bootstrap.BottlerocketConfig{}
+			Expect(config.UnmarshalTOML(userData)).To(Succeed())
+			Expect(config.Settings.Kubernetes.NodeTaints).To(Equal(map[string][]string{
+				"foo":                   {"bar:NoExecute"},
+				"example.com/not-ready": {"true:NoSchedule"},
+			}))
+			Expect(node.Spec.Taints).To(ContainElement(v1.Taint{Key: "example.com/not-ready", Value: "true", Effect: v1.TaintEffectNoSchedule}))
+		})
+		It("should bootstrap against the cluster endpoint setting by default", func() {
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+			input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+			userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+			Expect(string(userData)).To(ContainSubstring("--apiserver-endpoint 'https://test-cluster'"))
+		})
+		It("should bootstrap against the cluster endpoint of the provider (AL2)", func() {
+			provider.ClusterEndpoint = aws.String("https://private.example.com")
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+			input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+			userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+			Expect(string(userData)).To(ContainSubstring("--apiserver-endpoint 'https://private.example.com'"))
+			Expect(string(userData)).ToNot(ContainSubstring("https://test-cluster"))
+		})
+		It("should bootstrap against the cluster endpoint of the provider (Bottlerocket)", func() {
+			provider.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+			provider.ClusterEndpoint = aws.String("https://private.example.com")
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+			input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+			userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+			config := &bootstrap.BottlerocketConfig{}
+			Expect(config.UnmarshalTOML(userData)).To(Succeed())
+			Expect(aws.StringValue(config.Settings.Kubernetes.APIServer)).To(Equal("https://private.example.com"))
+		})
+		It("should prepare the instance store before the kubelet starts with the RAID0 instance store policy", func() {
+			provider.InstanceStorePolicy = aws.String(v1alpha1.InstanceStorePolicyRAID0)
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+			input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+			userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+			Expect(string(userData)).To(ContainSubstring(bootstrap.InstanceStoreKubeletDropIn))
+			Expect(string(userData)).To(ContainSubstring("Requires=" + bootstrap.InstanceStoreUnit))
+			Expect(strings.Index(string(userData), bootstrap.InstanceStoreKubeletDropIn)).To(BeNumerically("<", strings.Index(string(userData), "/etc/eks/bootstrap.sh")))
+		})
+		It("should not prepare the instance store without an instance store policy", func() {
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+			input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+			userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+			Expect(string(userData)).NotTo(ContainSubstring(bootstrap.InstanceStoreUnit))
+		})
+		It("should apply the sysctls before the kubelet starts", func() {
+			provider.Sysctls = map[string]string{"net.core.somaxconn": "4096", "net.ipv4.ip_local_port_range": "1024 65000"}
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+			input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+			userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+			Expect(string(userData)).To(ContainSubstring(fmt.Sprintf("cat <<'EOF' > %s\nnet.core.somaxconn = 4096\nnet.ipv4.ip_local_port_range = 1024 65000\nEOF\n", bootstrap.SysctlConfig)))
+			Expect(string(userData)).To(ContainSubstring("sysctl -p " + bootstrap.SysctlConfig))
+			Expect(strings.Index(string(userData), bootstrap.SysctlConfig)).To(BeNumerically("<", strings.Index(string(userData), "/etc/eks/bootstrap.sh")))
+		})
+		It("should apply the sysctls on Ubuntu", func() {
+			provider.AMIFamily = &v1alpha1.AMIFamilyUbuntu
+			provider.Sysctls = map[string]string{"vm.max_map_count": "262144"}
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+			input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+			userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+			Expect(string(userData)).To(ContainSubstring("vm.max_map_count = 262144\n"))
+			Expect(string(userData)).To(ContainSubstring("sysctl -p " + bootstrap.SysctlConfig))
+		})
+		It("should not write a sysctl config without sysctls", func() {
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+			input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+			userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+			Expect(string(userData)).NotTo(ContainSubstring(bootstrap.SysctlConfig))
+		})
+		It("should pull the images after the bootstrap script", func() {
+			provider.ImagesToPull = []string{"111122223333.dkr.ecr.us-west-2.amazonaws.com/app:v1", "public.ecr.aws/nginx/nginx:latest"}
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+			input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+			userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+			Expect(string(userData)).To(ContainSubstring("images=('111122223333.dkr.ecr.us-west-2.amazonaws.com/app:v1' 'public.ecr.aws/nginx/nginx:latest')\n"))
+			Expect(string(userData)).To(ContainSubstring("crictl --runtime-endpoint unix:///run/containerd/containerd.sock pull"))
+			Expect(string(userData)).To(ContainSubstring("After=network-online.target containerd.service\n"))
+			Expect(string(userData)).To(ContainSubstring("ExecStart=" + bootstrap.ImagePullScript))
+			Expect(strings.Index(string(userData), "systemctl start --no-block "+bootstrap.ImagePullUnit)).To(BeNumerically(">", strings.Index(string(userData), "/etc/eks/bootstrap.sh")))
+		})
+		It("should pull the images with docker with dockerd", func() {
+			provider.ImagesToPull = []string{"public.ecr.aws/nginx/nginx:latest"}
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{
+				Provider: provider,
+				Kubelet:  &v1alpha5.KubeletConfiguration{ContainerRuntime: aws.String("dockerd")},
+			}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+			input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+			userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+			Expect(string(userData)).To(ContainSubstring(`docker pull "${image}"`))
+			Expect(string(userData)).To(ContainSubstring("After=network-online.target docker.service\n"))
+			Expect(string(userData)).NotTo(ContainSubstring("crictl"))
+		})
+		It("should not install the image pull unit without images", func() {
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+			input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+			userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+			Expect(string(userData)).NotTo(ContainSubstring(bootstrap.ImagePullUnit))
+		})
+		It("should run the post bootstrap command as the final step", func() {
+			provider.ImagesToPull = []string{"public.ecr.aws/nginx/nginx:latest"}
+			provider.PostBootstrapCommand = &v1alpha1.PostBootstrapCommand{Command: `curl -sf -d "node=$(hostname)" 'https://cmdb.example.com/nodes'`}
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+			input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+			userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+			encoded := base64.StdEncoding.EncodeToString([]byte(provider.PostBootstrapCommand.Command))
+			Expect(string(userData)).To(ContainSubstring(fmt.Sprintf("echo '%s' | base64 -d > %s\n", encoded, bootstrap.PostBootstrapScript)))
+			Expect(string(userData)).To(ContainSubstring("if ! /bin/bash " + bootstrap.PostBootstrapScript + "; then\n"))
+			Expect(string(userData)).To(ContainSubstring("systemctl stop kubelet\n"))
+			commandIndex := strings.Index(string(userData), "base64 -d > "+bootstrap.PostBootstrapScript)
+			Expect(commandIndex).To(BeNumerically(">", strings.Index(string(userData), "/etc/eks/bootstrap.sh")))
+			Expect(commandIndex).To(BeNumerically(">", strings.Index(string(userData), "systemctl start --no-block "+bootstrap.ImagePullUnit)))
+		})
+		It("should log and ignore a failing post bootstrap command", func() {
+			provider.PostBootstrapCommand = &v1alpha1.PostBootstrapCommand{
+				Command:       "/opt/register.sh",
+				FailurePolicy: aws.String(v1alpha1.PostBootstrapCommandFailurePolicyIgnore),
+			}
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+			input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+			userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+			Expect(string(userData)).To(ContainSubstring("/bin/bash " + bootstrap.PostBootstrapScript + " || echo"))
+			Expect(string(userData)).NotTo(ContainSubstring("systemctl stop kubelet"))
+		})
+		It("should run the post bootstrap command after the custom user data", func() {
+			customUserData := aws.String("MIME-Version: 1.0\nContent-Type: multipart/mixed; boundary=\"BOUNDARY\"\n\n--BOUNDARY\nContent-Type: text/x-shellscript; charset=\"us-ascii\"\n\n#!/bin/bash\necho custom-user-data\n--BOUNDARY--\n")
+			provider.PostBootstrapCommand = &v1alpha1.PostBootstrapCommand{Command: "/opt/register.sh"}
+			nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{AWS: *provider, UserData: customUserData})
+			ExpectApplied(ctx, env.Client, nodeTemplate, test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+			input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+			userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+			Expect(strings.Index(string(userData), bootstrap.PostBootstrapScript)).To(BeNumerically(">", strings.Index(string(userData), "echo custom-user-data")))
+		})
+		It("should not run a post bootstrap command without one", func() {
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+			input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+			userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+			Expect(string(userData)).NotTo(ContainSubstring(bootstrap.PostBootstrapScript))
+		})
+		It("should pass the container log rotation to the kubelet", func() {
+			provider.ContainerLogMaxSize = aws.String("50Mi")
+			provider.ContainerLogMaxFiles = aws.Int32(3)
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+			input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+			userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+			Expect(string(userData)).To(ContainSubstring("--container-log-max-size=50Mi --container-log-max-files=3"))
+		})
+		It("should not pass the container log rotation to the kubelet with dockerd", func() {
+			provider.ContainerLogMaxSize = aws.String("50Mi")
+			provider.ContainerLogMaxFiles = aws.Int32(3)
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{
+				Provider: provider,
+				Kubelet:  &v1alpha5.KubeletConfiguration{ContainerRuntime: aws.String("dockerd")},
+			}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+			input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+			userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+			Expect(string(userData)).NotTo(ContainSubstring("--container-log-max"))
+		})
+		It("should specify --container-runtime docker when using Neuron GPUs", func() {
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{
+				Provider:     provider,
+				Requirements: []v1.NodeSelectorRequirement{{Key: v1alpha1.LabelInstanceCategory, Operator: v1.NodeSelectorOpExists}},
+			}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod(coretest.PodOptions{
+				ResourceRequirements: v1.ResourceRequirements{
+					Requests: map[v1.ResourceName]resource.Quantity{
+						v1.ResourceCPU:             resource.MustParse("1"),
+						v1alpha1.ResourceAWSNeuron: resource.MustParse("1"),
+					},
+					Limits: map[v1.ResourceName]resource.Quantity{
+						v1alpha1.ResourceAWSNeuron: resource.MustParse("1"),
+					},
+				},
+			}))[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+			input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+			userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+			Expect(string(userData)).To(ContainSubstring("--container-runtime docker"))
+		})
+		It("should specify --container-runtime containerd when using Nvidia GPUs", func() {
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{
+				Provider:     provider,
+				Requirements: []v1.NodeSelectorRequirement{{Key: v1alpha1.LabelInstanceCategory, Operator: v1.NodeSelectorOpExists}},
+			}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod(coretest.PodOptions{
+				ResourceRequirements: v1.ResourceRequirements{
+					Requests: map[v1.ResourceName]resource.Quantity{
+						v1.ResourceCPU:             resource.MustParse("1"),
 						v1alpha1.ResourceNVIDIAGPU: resource.MustParse("1"),
 					},
 					Limits: map[v1.ResourceName]resource.Quantity{
@@ -1109,6 +1847,28 @@ var _ = Describe("LaunchTemplates", func() {
 				// This will not be scheduled since userData cannot be generated for the prospective node.
 				ExpectNotScheduled(ctx, env.Client, pod)
 			})
+			It("should not bootstrap shell script user data", func() {
+				provider.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+				nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+					UserData: aws.String("#!/bin/bash\necho hello"),
+					AWS:      *provider,
+				})
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				newProvisioner := test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}})
+				ExpectApplied(ctx, env.Client, newProvisioner)
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectNotScheduled(ctx, env.Client, pod)
+			})
+			It("should reject user data in the format of other AMI families", func() {
+				content, _ := os.ReadFile("testdata/al2_userdata_input.golden")
+				for userData, format := range map[string]string{
+					"#!/bin/bash\necho hello": "a shell script",
+					string(content):           "a MIME multi-part archive",
+				} {
+					_, err := bootstrap.Bottlerocket{Options: bootstrap.Options{CustomUserData: aws.String(userData)}}.Script()
+					Expect(err).To(MatchError(ContainSubstring("the Bottlerocket AMI family expects TOML settings but the user data is " + format)))
+				}
+			})
 			It("should override system reserved values in user data", func() {
 				provider.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
 				nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
@@ -1205,6 +1965,23 @@ var _ = Describe("LaunchTemplates", func() {
 				Expect(config.Settings.Kubernetes.EvictionHard["nodefs.available"]).To(Equal("15%"))
 				Expect(config.Settings.Kubernetes.EvictionHard["nodefs.inodesFree"]).To(Equal("5%"))
 			})
+			It("should specify the prefix delegation max pods value when prefix delegation is enabled", func() {
+				bottlerocketProvider := provider.DeepCopy()
+				bottlerocketProvider.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+				bottlerocketProvider.PrefixDelegation = lo.ToPtr(true)
+				ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: bottlerocketProvider}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod(coretest.PodOptions{
+					NodeSelector: map[string]string{v1.LabelInstanceTypeStable: "m5.large"},
+				}))[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+				userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+				config := &bootstrap.BottlerocketConfig{}
+				Expect(config.UnmarshalTOML(userData)).To(Succeed())
+				Expect(config.Settings.Kubernetes.MaxPods).ToNot(BeNil())
+				Expect(*config.Settings.Kubernetes.MaxPods).To(BeNumerically("==", 110))
+			})
 			It("should specify max pods value when passing maxPods in configuration", func() {
 				bottlerocketProvider := provider.DeepCopy()
 				bottlerocketProvider.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
@@ -1219,56 +1996,220 @@ var _ = Describe("LaunchTemplates", func() {
 				Expect(config.Settings.Kubernetes.MaxPods).ToNot(BeNil())
 				Expect(*config.Settings.Kubernetes.MaxPods).To(BeNumerically("==", 10))
 			})
-		})
-		Context("AL2 Custom UserData", func() {
-			It("should merge in custom user data", func() {
-				settingsStore = coretest.SettingsStore{
-					settings.ContextKey: test.Settings(),
-					awssettings.ContextKey: test.Settings(test.SettingOptions{
-						EnableENILimitedPodDensity: lo.ToPtr(false),
-					}),
+			It("should render the host containers into user data", func() {
+				provider.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+				provider.HostContainers = &v1alpha1.HostContainers{
+					Admin:   &v1alpha1.HostContainer{Enabled: aws.Bool(true), Source: aws.String("111122223333.dkr.ecr.us-west-2.amazonaws.com/bottlerocket-admin:v0.9.4")},
+					Control: &v1alpha1.HostContainer{Enabled: aws.Bool(false)},
 				}
-				ctx = settingsStore.InjectSettings(ctx)
-
-				prov = provisioning.NewProvisioner(ctx, env.Client, corev1.NewForConfigOrDie(env.Config), recorder, cloudProvider, cluster, settingsStore)
-				controllerWithOpts := provisioning.NewController(env.Client, prov, recorder)
-
-				content, _ := os.ReadFile("testdata/al2_userdata_input.golden")
-				nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
-					UserData: aws.String(string(content)),
-					AWS:      *provider,
-				})
-				ExpectApplied(ctx, env.Client, nodeTemplate)
-				newProvisioner := test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}})
-				ExpectApplied(ctx, env.Client, newProvisioner)
-				pod := ExpectProvisioned(ctx, env.Client, recorder, controllerWithOpts, prov, coretest.UnschedulablePod())[0]
+				ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
 				ExpectScheduled(ctx, env.Client, pod)
 				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
 				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
 				userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
-				content, _ = os.ReadFile("testdata/al2_userdata_merged.golden")
-				expectedUserData := fmt.Sprintf(string(content), newProvisioner.Name)
-				Expect(expectedUserData).To(Equal(string(userData)))
+				config := &bootstrap.BottlerocketConfig{}
+				Expect(config.UnmarshalTOML(userData)).To(Succeed())
+				Expect(config.Settings.HostContainers).To(HaveLen(2))
+				Expect(config.Settings.HostContainers["admin"].Enabled).To(Equal(aws.Bool(true)))
+				Expect(config.Settings.HostContainers["admin"].Source).To(Equal(aws.String("111122223333.dkr.ecr.us-west-2.amazonaws.com/bottlerocket-admin:v0.9.4")))
+				Expect(config.Settings.HostContainers["control"].Enabled).To(Equal(aws.Bool(false)))
+				Expect(config.Settings.HostContainers["control"].Source).To(BeNil())
 			})
-			It("should handle empty custom user data", func() {
-				settingsStore = coretest.SettingsStore{
-					settings.ContextKey: test.Settings(),
-					awssettings.ContextKey: test.Settings(test.SettingOptions{
-						EnableENILimitedPodDensity: lo.ToPtr(false),
-					}),
+			It("should render an enabled control container with its image into user data", func() {
+				provider.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+				provider.HostContainers = &v1alpha1.HostContainers{
+					Admin:   &v1alpha1.HostContainer{Source: aws.String("111122223333.dkr.ecr.us-west-2.amazonaws.com/bottlerocket-admin:v0.9.4")},
+					Control: &v1alpha1.HostContainer{Enabled: aws.Bool(true), Source: aws.String("111122223333.dkr.ecr.us-west-2.amazonaws.com/bottlerocket-control:v0.7.0")},
 				}
-				ctx = settingsStore.InjectSettings(ctx)
-
-				prov = provisioning.NewProvisioner(ctx, env.Client, corev1.NewForConfigOrDie(env.Config), recorder, cloudProvider, cluster, settingsStore)
-				controllerWithOpts := provisioning.NewController(env.Client, prov, recorder)
-				nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
-					UserData: nil,
-					AWS:      *provider,
-				})
-				ExpectApplied(ctx, env.Client, nodeTemplate)
-				newProvisioner := test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}})
-				ExpectApplied(ctx, env.Client, newProvisioner)
-				pod := ExpectProvisioned(ctx, env.Client, recorder, controllerWithOpts, prov, coretest.UnschedulablePod())[0]
+				ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+				userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+				config := &bootstrap.BottlerocketConfig{}
+				Expect(config.UnmarshalTOML(userData)).To(Succeed())
+				Expect(config.Settings.HostContainers["admin"].Enabled).To(BeNil())
+				Expect(config.Settings.HostContainers["admin"].Source).To(Equal(aws.String("111122223333.dkr.ecr.us-west-2.amazonaws.com/bottlerocket-admin:v0.9.4")))
+				Expect(config.Settings.HostContainers["control"].Enabled).To(Equal(aws.Bool(true)))
+				Expect(config.Settings.HostContainers["control"].Source).To(Equal(aws.String("111122223333.dkr.ecr.us-west-2.amazonaws.com/bottlerocket-control:v0.7.0")))
+			})
+			It("should keep the bootstrap settings when rendering the host containers into custom user data", func() {
+				provider.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+				provider.HostContainers = &v1alpha1.HostContainers{
+					Control: &v1alpha1.HostContainer{Enabled: aws.Bool(false)},
+				}
+				nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+					UserData: aws.String(`
+[settings.kubernetes]
+cluster-name = "another-cluster"
+api-server = "https://another-cluster"
+
+[settings.host-containers.control]
+enabled = true
+superpowered = false
+`),
+					AWS: *provider,
+				})
+				ExpectApplied(ctx, env.Client, nodeTemplate, test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+				userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+				config := &bootstrap.BottlerocketConfig{}
+				Expect(config.UnmarshalTOML(userData)).To(Succeed())
+				Expect(config.Settings.Kubernetes.ClusterName).To(Equal(aws.String("test-cluster")))
+				Expect(config.Settings.Kubernetes.APIServer).To(Equal(aws.String("https://test-cluster")))
+				Expect(config.Settings.Kubernetes.ClusterCertificate).To(Equal(aws.String("ca-bundle")))
+				Expect(config.Settings.HostContainers["control"].Enabled).To(Equal(aws.Bool(false)))
+				Expect(config.Settings.HostContainers["control"].Superpowered).To(Equal(aws.Bool(false)))
+			})
+			It("should merge the host containers into custom user data", func() {
+				provider.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+				provider.HostContainers = &v1alpha1.HostContainers{
+					Admin: &v1alpha1.HostContainer{Enabled: aws.Bool(true)},
+				}
+				nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+					UserData: aws.String(`
+[settings.host-containers.admin]
+enabled = false
+superpowered = true
+user-data = "dGVzdA=="
+
+[settings.host-containers.custom]
+enabled = true
+source = "public.ecr.aws/example/custom:latest"
+`),
+					AWS: *provider,
+				})
+				ExpectApplied(ctx, env.Client, nodeTemplate, test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+				userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+				config := &bootstrap.BottlerocketConfig{}
+				Expect(config.UnmarshalTOML(userData)).To(Succeed())
+				Expect(config.Settings.HostContainers).To(HaveLen(2))
+				Expect(config.Settings.HostContainers["admin"].Enabled).To(Equal(aws.Bool(true)))
+				Expect(config.Settings.HostContainers["admin"].Superpowered).To(Equal(aws.Bool(true)))
+				Expect(config.Settings.HostContainers["admin"].UserData).To(Equal(aws.String("dGVzdA==")))
+				Expect(config.Settings.HostContainers["custom"].Source).To(Equal(aws.String("public.ecr.aws/example/custom:latest")))
+			})
+			It("should render the sysctls into user data", func() {
+				provider.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+				provider.Sysctls = map[string]string{"net.core.somaxconn": "4096"}
+				nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+					UserData: aws.String(`
+[settings.kernel]
+lockdown = "integrity"
+
+[settings.kernel.sysctl]
+"vm.max_map_count" = "262144"
+"net.core.somaxconn" = "1024"
+`),
+					AWS: *provider,
+				})
+				ExpectApplied(ctx, env.Client, nodeTemplate, test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+				userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+				config := &bootstrap.BottlerocketConfig{}
+				Expect(config.UnmarshalTOML(userData)).To(Succeed())
+				kernel := config.SettingsRaw["kernel"].(map[string]interface{})
+				Expect(kernel["lockdown"]).To(Equal("integrity"))
+				Expect(kernel["sysctl"]).To(Equal(map[string]interface{}{
+					"vm.max_map_count":   "262144",
+					"net.core.somaxconn": "4096",
+				}))
+			})
+			It("should render the container log rotation into user data", func() {
+				provider.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+				provider.ContainerLogMaxSize = aws.String("50Mi")
+				provider.ContainerLogMaxFiles = aws.Int32(3)
+				nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+					UserData: aws.String(`
+[settings.kubernetes]
+container-log-max-size = "10Mi"
+container-log-max-files = 10
+`),
+					AWS: *provider,
+				})
+				ExpectApplied(ctx, env.Client, nodeTemplate, test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+				userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+				config := &bootstrap.BottlerocketConfig{}
+				Expect(config.UnmarshalTOML(userData)).To(Succeed())
+				Expect(config.Settings.Kubernetes.ContainerLogMaxSize).To(Equal(aws.String("50Mi")))
+				Expect(config.Settings.Kubernetes.ContainerLogMaxFiles).To(Equal(aws.Int(3)))
+			})
+			It("should not render host containers that aren't configured", func() {
+				provider.AMIFamily = &v1alpha1.AMIFamilyBottlerocket
+				ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+				userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+				Expect(string(userData)).ToNot(ContainSubstring("host-containers"))
+			})
+		})
+		Context("AL2 Custom UserData", func() {
+			It("should merge in custom user data", func() {
+				settingsStore = coretest.SettingsStore{
+					settings.ContextKey: test.Settings(),
+					awssettings.ContextKey: test.Settings(test.SettingOptions{
+						EnableENILimitedPodDensity: lo.ToPtr(false),
+					}),
+				}
+				ctx = settingsStore.InjectSettings(ctx)
+
+				prov = provisioning.NewProvisioner(ctx, env.Client, corev1.NewForConfigOrDie(env.Config), recorder, cloudProvider, cluster, settingsStore)
+				controllerWithOpts := provisioning.NewController(env.Client, prov, recorder)
+
+				content, _ := os.ReadFile("testdata/al2_userdata_input.golden")
+				nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+					UserData: aws.String(string(content)),
+					AWS:      *provider,
+				})
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				newProvisioner := test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}})
+				ExpectApplied(ctx, env.Client, newProvisioner)
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controllerWithOpts, prov, coretest.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+				userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+				content, _ = os.ReadFile("testdata/al2_userdata_merged.golden")
+				expectedUserData := fmt.Sprintf(string(content), newProvisioner.Name)
+				Expect(expectedUserData).To(Equal(string(userData)))
+			})
+			It("should handle empty custom user data", func() {
+				settingsStore = coretest.SettingsStore{
+					settings.ContextKey: test.Settings(),
+					awssettings.ContextKey: test.Settings(test.SettingOptions{
+						EnableENILimitedPodDensity: lo.ToPtr(false),
+					}),
+				}
+				ctx = settingsStore.InjectSettings(ctx)
+
+				prov = provisioning.NewProvisioner(ctx, env.Client, corev1.NewForConfigOrDie(env.Config), recorder, cloudProvider, cluster, settingsStore)
+				controllerWithOpts := provisioning.NewController(env.Client, prov, recorder)
+				nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+					UserData: nil,
+					AWS:      *provider,
+				})
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				newProvisioner := test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}})
+				ExpectApplied(ctx, env.Client, newProvisioner)
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controllerWithOpts, prov, coretest.UnschedulablePod())[0]
 				ExpectScheduled(ctx, env.Client, pod)
 				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
 				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
@@ -1289,6 +2230,49 @@ var _ = Describe("LaunchTemplates", func() {
 				// This will not be scheduled since userData cannot be generated for the prospective node.
 				ExpectNotScheduled(ctx, env.Client, pod)
 			})
+			It("should merge in a custom shell script", func() {
+				nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+					UserData: aws.String("#!/bin/bash\necho hello"),
+					AWS:      *provider,
+				})
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				newProvisioner := test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}})
+				ExpectApplied(ctx, env.Client, newProvisioner)
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+				userData, _ := base64.StdEncoding.DecodeString(*input.LaunchTemplateData.UserData)
+				Expect(string(userData)).To(ContainSubstring("Content-Type: text/x-shellscript; charset=\"us-ascii\"\n\n#!/bin/bash\necho hello"))
+				Expect(strings.Index(string(userData), "echo hello")).To(BeNumerically("<", strings.Index(string(userData), "/etc/eks/bootstrap.sh")))
+			})
+			It("should not bootstrap TOML user data", func() {
+				nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+					UserData: aws.String("[settings.kubernetes]\nmax-pods = 10"),
+					AWS:      *provider,
+				})
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				newProvisioner := test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}})
+				ExpectApplied(ctx, env.Client, newProvisioner)
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectNotScheduled(ctx, env.Client, pod)
+			})
+			It("should not bootstrap TOML user data for Ubuntu", func() {
+				provider.AMIFamily = &v1alpha1.AMIFamilyUbuntu
+				nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+					UserData: aws.String("[settings.kubernetes]\nmax-pods = 10"),
+					AWS:      *provider,
+				})
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				newProvisioner := test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}})
+				ExpectApplied(ctx, env.Client, newProvisioner)
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectNotScheduled(ctx, env.Client, pod)
+			})
+			It("should reject user data in the format of Bottlerocket", func() {
+				_, err := bootstrap.EKS{Options: bootstrap.Options{CustomUserData: aws.String("[settings.kubernetes]\nmax-pods = 10")}}.Script()
+				Expect(err).To(MatchError(ContainSubstring("the AMI family expects a shell script or a MIME multi-part archive but the user data is TOML settings")))
+			})
 		})
 		Context("Custom AMI Selector", func() {
 			It("should use ami selector specified in AWSNodeTemplate", func() {
@@ -1312,6 +2296,299 @@ var _ = Describe("LaunchTemplates", func() {
 				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
 				Expect("ami-123").To(Equal(*input.LaunchTemplateData.ImageId))
 			})
+			It("should not launch an ami on instance types that don't support its virtualization type", func() {
+				nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+					AMISelector: map[string]string{"karpenter.sh/discovery": "my-cluster"},
+					AWS:         *provider,
+				})
+				fakeEC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+					{
+						ImageId:            aws.String("ami-123"),
+						Architecture:       aws.String("x86_64"),
+						VirtualizationType: aws.String(ec2.VirtualizationTypeParavirtual),
+						CreationDate:       aws.String("2022-08-15T12:00:00Z")},
+				}})
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectNotScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(0))
+			})
+			It("should choose an ami whose virtualization type is supported by the instance types", func() {
+				nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+					AMISelector: map[string]string{"karpenter.sh/discovery": "my-cluster"},
+					AWS:         *provider,
+				})
+				fakeEC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+					{
+						ImageId:            aws.String("ami-paravirtual"),
+						Architecture:       aws.String("x86_64"),
+						VirtualizationType: aws.String(ec2.VirtualizationTypeParavirtual),
+						CreationDate:       aws.String("2022-10-15T12:00:00Z")},
+					{
+						ImageId:            aws.String("ami-hvm"),
+						Architecture:       aws.String("x86_64"),
+						VirtualizationType: aws.String(ec2.VirtualizationTypeHvm),
+						CreationDate:       aws.String("2022-08-15T12:00:00Z")},
+				}})
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+				Expect(*input.LaunchTemplateData.ImageId).To(Equal("ami-hvm"))
+			})
+			It("should not launch a uefi ami on instance types that only support legacy bios", func() {
+				nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+					AMISelector: map[string]string{"karpenter.sh/discovery": "my-cluster"},
+					AWS:         *provider,
+				})
+				fakeEC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+					{
+						ImageId:      aws.String("ami-uefi"),
+						Architecture: aws.String("x86_64"),
+						BootMode:     aws.String(ec2.BootModeValuesUefi),
+						CreationDate: aws.String("2022-08-15T12:00:00Z")},
+				}})
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod(coretest.PodOptions{
+					NodeSelector: map[string]string{v1.LabelInstanceTypeStable: "p3.8xlarge"},
+				}))[0]
+				ExpectNotScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(0))
+			})
+			It("should only launch a uefi ami on instance types that support uefi", func() {
+				nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+					AMISelector: map[string]string{"karpenter.sh/discovery": "my-cluster"},
+					AWS:         *provider,
+				})
+				fakeEC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+					{
+						ImageId:      aws.String("ami-uefi"),
+						Architecture: aws.String("x86_64"),
+						BootMode:     aws.String(ec2.BootModeValuesUefi),
+						CreationDate: aws.String("2022-08-15T12:00:00Z")},
+				}})
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateFleetInput.Len()).To(Equal(1))
+				for _, ltc := range fakeEC2API.CalledWithCreateFleetInput.Pop().LaunchTemplateConfigs {
+					for _, override := range ltc.Overrides {
+						Expect(aws.StringValue(override.InstanceType)).ToNot(Equal("p3.8xlarge"))
+					}
+				}
+			})
+			It("should choose an ami whose boot mode is supported by the instance types", func() {
+				nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+					AMISelector: map[string]string{"karpenter.sh/discovery": "my-cluster"},
+					AWS:         *provider,
+				})
+				fakeEC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+					{
+						ImageId:      aws.String("ami-uefi"),
+						Architecture: aws.String("x86_64"),
+						BootMode:     aws.String(ec2.BootModeValuesUefi),
+						CreationDate: aws.String("2022-10-15T12:00:00Z")},
+					{
+						ImageId:      aws.String("ami-legacy-bios"),
+						Architecture: aws.String("x86_64"),
+						BootMode:     aws.String(ec2.BootModeValuesLegacyBios),
+						CreationDate: aws.String("2022-08-15T12:00:00Z")},
+				}})
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod(coretest.PodOptions{
+					NodeSelector: map[string]string{v1.LabelInstanceTypeStable: "p3.8xlarge"},
+				}))[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+				Expect(*input.LaunchTemplateData.ImageId).To(Equal("ami-legacy-bios"))
+			})
+			It("should not launch a NitroTPM ami on instance types that don't support NitroTPM", func() {
+				nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+					AMISelector: map[string]string{"karpenter.sh/discovery": "my-cluster"},
+					AWS:         *provider,
+				})
+				fakeEC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+					{
+						ImageId:      aws.String("ami-tpm"),
+						Architecture: aws.String("x86_64"),
+						BootMode:     aws.String(ec2.BootModeValuesUefi),
+						TpmSupport:   aws.String(ec2.TpmSupportValuesV20),
+						CreationDate: aws.String("2022-08-15T12:00:00Z")},
+				}})
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod(coretest.PodOptions{
+					NodeSelector: map[string]string{v1.LabelInstanceTypeStable: "m5.metal"},
+				}))[0]
+				ExpectNotScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(0))
+			})
+			Context("RequireNitroTPM", func() {
+				BeforeEach(func() {
+					provider.RequireNitroTPM = lo.ToPtr(true)
+				})
+				It("should only launch instance types that support NitroTPM from amis that enable it", func() {
+					nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+						AMISelector: map[string]string{"karpenter.sh/discovery": "my-cluster"},
+						AWS:         *provider,
+					})
+					fakeEC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+						{
+							ImageId:      aws.String("ami-no-tpm"),
+							Architecture: aws.String("x86_64"),
+							CreationDate: aws.String("2022-10-15T12:00:00Z")},
+						{
+							ImageId:      aws.String("ami-tpm"),
+							Architecture: aws.String("x86_64"),
+							BootMode:     aws.String(ec2.BootModeValuesUefi),
+							TpmSupport:   aws.String(ec2.TpmSupportValuesV20),
+							CreationDate: aws.String("2022-08-15T12:00:00Z")},
+					}})
+					ExpectApplied(ctx, env.Client, nodeTemplate)
+					ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}}))
+					pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+					ExpectScheduled(ctx, env.Client, pod)
+					Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+					Expect(*fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().LaunchTemplateData.ImageId).To(Equal("ami-tpm"))
+					Expect(fakeEC2API.CalledWithCreateFleetInput.Len()).To(Equal(1))
+					for _, ltc := range fakeEC2API.CalledWithCreateFleetInput.Pop().LaunchTemplateConfigs {
+						for _, override := range ltc.Overrides {
+							Expect(aws.StringValue(override.InstanceType)).ToNot(BeElementOf("p3.8xlarge", "m5.metal"))
+						}
+					}
+				})
+				It("should not launch when none of the amis enable NitroTPM", func() {
+					nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+						AMISelector: map[string]string{"karpenter.sh/discovery": "my-cluster"},
+						AWS:         *provider,
+					})
+					fakeEC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+						{
+							ImageId:      aws.String("ami-no-tpm"),
+							Architecture: aws.String("x86_64"),
+							CreationDate: aws.String("2022-10-15T12:00:00Z")},
+					}})
+					ExpectApplied(ctx, env.Client, nodeTemplate)
+					ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}}))
+					pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+					ExpectNotScheduled(ctx, env.Client, pod)
+					Expect(fakeEC2API.CalledWithCreateFleetInput.Len()).To(Equal(0))
+				})
+				It("should not launch without an amiSelector", func() {
+					ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+					pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+					ExpectNotScheduled(ctx, env.Client, pod)
+					Expect(fakeEC2API.CalledWithCreateFleetInput.Len()).To(Equal(0))
+				})
+			})
+			Context("Custom AMI Compatibility", func() {
+				var nodeTemplate *v1alpha1.AWSNodeTemplate
+				BeforeEach(func() {
+					provider.AMIFamily = &v1alpha1.AMIFamilyCustom
+					nodeTemplate = test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+						AMISelector: map[string]string{"karpenter.sh/discovery": "my-cluster"},
+						AWS:         *provider,
+					})
+				})
+				architectures := func(instanceTypes []cloudprovider.InstanceType) []string {
+					return lo.Uniq(lo.Map(instanceTypes, func(it cloudprovider.InstanceType, _ int) string {
+						return it.Requirements().Get(v1.LabelArchStable).Any()
+					}))
+				}
+				It("should only return arm64 instance types for an arm64 ami", func() {
+					fakeEC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+						{
+							ImageId:            aws.String("ami-arm64"),
+							Architecture:       aws.String("arm64"),
+							VirtualizationType: aws.String("hvm"),
+							CreationDate:       aws.String("2022-08-15T12:00:00Z")},
+					}})
+					provisioner := test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}})
+					ExpectApplied(ctx, env.Client, nodeTemplate, provisioner)
+					instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(instanceTypes).ToNot(BeEmpty())
+					Expect(architectures(instanceTypes)).To(ConsistOf(v1alpha5.ArchitectureArm64))
+				})
+				It("should only return amd64 instance types for an amd64 ami", func() {
+					fakeEC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+						{
+							ImageId:            aws.String("ami-amd64"),
+							Architecture:       aws.String("x86_64"),
+							VirtualizationType: aws.String("hvm"),
+							CreationDate:       aws.String("2022-08-15T12:00:00Z")},
+					}})
+					provisioner := test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}})
+					ExpectApplied(ctx, env.Client, nodeTemplate, provisioner)
+					instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(architectures(instanceTypes)).To(ConsistOf(v1alpha5.ArchitectureAmd64))
+				})
+				It("should return the instance types of every architecture that an ami is selected for", func() {
+					fakeEC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+						{
+							ImageId:      aws.String("ami-amd64"),
+							Architecture: aws.String("x86_64"),
+							CreationDate: aws.String("2022-08-15T12:00:00Z")},
+						{
+							ImageId:      aws.String("ami-arm64"),
+							Architecture: aws.String("arm64"),
+							CreationDate: aws.String("2022-08-15T12:00:00Z")},
+					}})
+					provisioner := test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}})
+					ExpectApplied(ctx, env.Client, nodeTemplate, provisioner)
+					instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(architectures(instanceTypes)).To(ConsistOf(v1alpha5.ArchitectureAmd64, v1alpha5.ArchitectureArm64))
+				})
+				It("should schedule arm64 pods onto the instance types of an arm64 ami", func() {
+					fakeEC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+						{
+							ImageId:      aws.String("ami-arm64"),
+							Architecture: aws.String("arm64"),
+							CreationDate: aws.String("2022-08-15T12:00:00Z")},
+					}})
+					ExpectApplied(ctx, env.Client, nodeTemplate, test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}}))
+					pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+					node := ExpectScheduled(ctx, env.Client, pod)
+					Expect(node.Labels).To(HaveKeyWithValue(v1.LabelArchStable, v1alpha5.ArchitectureArm64))
+					Expect(*fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().LaunchTemplateData.ImageId).To(Equal("ami-arm64"))
+				})
+				It("should return an error when no instance type supports the virtualization type of the amis", func() {
+					fakeEC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+						{
+							ImageId:            aws.String("ami-paravirtual"),
+							Architecture:       aws.String("x86_64"),
+							VirtualizationType: aws.String("paravirtual"),
+							CreationDate:       aws.String("2022-08-15T12:00:00Z")},
+					}})
+					provisioner := test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}})
+					ExpectApplied(ctx, env.Client, nodeTemplate, provisioner)
+					_, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+					Expect(err).To(MatchError(ContainSubstring("no instance types are compatible with the architecture or virtualization type of amis [ami-paravirtual]")))
+				})
+				It("should not constrain the instance types of other AMI families", func() {
+					fakeEC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+						{
+							ImageId:      aws.String("ami-arm64"),
+							Architecture: aws.String("arm64"),
+							CreationDate: aws.String("2022-08-15T12:00:00Z")},
+					}})
+					nodeTemplate.Spec.AMIFamily = &v1alpha1.AMIFamilyAL2
+					provisioner := test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}})
+					ExpectApplied(ctx, env.Client, nodeTemplate, provisioner)
+					instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(architectures(instanceTypes)).To(ConsistOf(v1alpha5.ArchitectureAmd64, v1alpha5.ArchitectureArm64))
+				})
+			})
 			It("should copy over userData untouched when AMIFamily is Custom", func() {
 				provider.AMIFamily = &v1alpha1.AMIFamilyCustom
 				nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
@@ -1361,14 +2638,41 @@ var _ = Describe("LaunchTemplates", func() {
 				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
 				ExpectScheduled(ctx, env.Client, pod)
 				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(2))
-				actualFilter := fakeEC2API.CalledWithDescribeImagesInput.Pop().Filters
+				describeImagesInput := fakeEC2API.CalledWithDescribeImagesInput.Pop()
 				expectedFilter := []*ec2.Filter{
 					{
 						Name:   aws.String("image-id"),
 						Values: aws.StringSlice([]string{"ami-123", "ami-456"}),
 					},
 				}
-				Expect(actualFilter).To(Equal(expectedFilter))
+				Expect(describeImagesInput.Filters).To(Equal(expectedFilter))
+				Expect(describeImagesInput.Owners).To(BeEmpty())
+			})
+			It("should scope the ami selector to the account and amazon by default", func() {
+				nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+					AMISelector: map[string]string{"foo": "bar"},
+					AWS:         *provider,
+				})
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				input := fakeEC2API.CalledWithDescribeImagesInput.Pop()
+				Expect(aws.StringValueSlice(input.Owners)).To(ConsistOf("self", "amazon"))
+				Expect(input.Filters).To(Equal([]*ec2.Filter{{Name: aws.String("tag:foo"), Values: aws.StringSlice([]string{"bar"})}}))
+			})
+			It("should scope the ami selector to the owners of the ami selector", func() {
+				nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+					AMISelector: map[string]string{"foo": "bar", "aws-owners": "self,123456789012"},
+					AWS:         *provider,
+				})
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				input := fakeEC2API.CalledWithDescribeImagesInput.Pop()
+				Expect(aws.StringValueSlice(input.Owners)).To(ConsistOf("self", "123456789012"))
+				Expect(input.Filters).To(Equal([]*ec2.Filter{{Name: aws.String("tag:foo"), Values: aws.StringSlice([]string{"bar"})}}))
 			})
 			It("should create multiple launch templates when multiple amis are discovered with non-equivalent requirements", func() {
 				fakeEC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
@@ -1445,6 +2749,139 @@ var _ = Describe("LaunchTemplates", func() {
 				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
 				Expect("ami-456").To(Equal(*input.LaunchTemplateData.ImageId))
 			})
+			It("should use the newest ami of each architecture that matches the name pattern", func() {
+				fakeEC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+					{
+						ImageId:      aws.String("ami-amd64-old"),
+						Name:         aws.String("myorg-eks-1.27-amd64-20230101"),
+						Architecture: aws.String("x86_64"),
+						CreationDate: aws.String("2023-01-01T12:00:00Z"),
+					},
+					{
+						ImageId:      aws.String("ami-amd64-new"),
+						Name:         aws.String("myorg-eks-1.27-amd64-20230301"),
+						Architecture: aws.String("x86_64"),
+						CreationDate: aws.String("2023-03-01T12:00:00Z"),
+					},
+					{
+						ImageId:      aws.String("ami-arm64-new"),
+						Name:         aws.String("myorg-eks-1.27-arm64-20230201"),
+						Architecture: aws.String("arm64"),
+						CreationDate: aws.String("2023-02-01T12:00:00Z"),
+					},
+					{
+						ImageId:      aws.String("ami-arm64-old"),
+						Name:         aws.String("myorg-eks-1.27-arm64-20221201"),
+						Architecture: aws.String("arm64"),
+						CreationDate: aws.String("2022-12-01T12:00:00Z"),
+					},
+				}})
+				nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+					AMISelector: map[string]string{"aws-name": "myorg-eks-1.27-*"},
+					AWS:         *provider,
+				})
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				input := fakeEC2API.CalledWithDescribeImagesInput.Pop()
+				Expect(input.Filters).To(Equal([]*ec2.Filter{{Name: aws.String("name"), Values: aws.StringSlice([]string{"myorg-eks-1.27-*"})}}))
+				Expect(aws.StringValueSlice(input.Owners)).To(ConsistOf("self", "amazon"))
+				imageIDs := sets.NewString()
+				for fakeEC2API.CalledWithCreateLaunchTemplateInput.Len() > 0 {
+					imageIDs.Insert(aws.StringValue(fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().LaunchTemplateData.ImageId))
+				}
+				Expect(imageIDs.List()).To(ConsistOf("ami-amd64-new", "ami-arm64-new"))
+			})
+			It("should filter by every name pattern of the ami selector", func() {
+				nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+					AMISelector: map[string]string{"aws-name": "myorg-eks-1.27-*, myorg-eks-1.28-*"},
+					AWS:         *provider,
+				})
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				input := fakeEC2API.CalledWithDescribeImagesInput.Pop()
+				Expect(input.Filters).To(Equal([]*ec2.Filter{{Name: aws.String("name"), Values: aws.StringSlice([]string{"myorg-eks-1.27-*", "myorg-eks-1.28-*"})}}))
+			})
+			It("should discover the amis of every ami selector term", func() {
+				fakeEC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+					{
+						ImageId:      aws.String("ami-123"),
+						Architecture: aws.String("x86_64"),
+						Tags:         []*ec2.Tag{{Key: aws.String(v1.LabelInstanceTypeStable), Value: aws.String("m5.large")}},
+						CreationDate: aws.String("2022-08-15T12:00:00Z"),
+					},
+					{
+						ImageId:      aws.String("ami-456"),
+						Architecture: aws.String("x86_64"),
+						Tags:         []*ec2.Tag{{Key: aws.String(v1.LabelInstanceTypeStable), Value: aws.String("m5.xlarge")}},
+						CreationDate: aws.String("2022-08-10T12:00:00Z"),
+					},
+				}})
+				nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+					AMISelectorTerms: []map[string]string{
+						{"karpenter.sh/discovery": "my-cluster"},
+						{"aws-ids": "ami-123,ami-456"},
+					},
+					AWS: *provider,
+				})
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithDescribeImagesInput.Len()).To(Equal(2))
+				filters := []string{
+					*fakeEC2API.CalledWithDescribeImagesInput.Pop().Filters[0].Name,
+					*fakeEC2API.CalledWithDescribeImagesInput.Pop().Filters[0].Name,
+				}
+				Expect(filters).To(ConsistOf("tag:karpenter.sh/discovery", "image-id"))
+				// AMIs that match several terms are only used once
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(2))
+				imageIDs := sets.NewString(
+					*fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().LaunchTemplateData.ImageId,
+					*fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().LaunchTemplateData.ImageId,
+				)
+				Expect(imageIDs.Equal(sets.NewString("ami-123", "ami-456"))).To(BeTrue())
+			})
+			It("should use the newest compatible ami across ami selector terms", func() {
+				fakeEC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+					{
+						ImageId:      aws.String("ami-123"),
+						Architecture: aws.String("x86_64"),
+						CreationDate: aws.String("2020-01-01T12:00:00Z"),
+					},
+					{
+						ImageId:      aws.String("ami-456"),
+						Architecture: aws.String("x86_64"),
+						CreationDate: aws.String("2021-01-01T12:00:00Z"),
+					},
+				}})
+				nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+					AMISelectorTerms: []map[string]string{
+						{"karpenter.sh/discovery": "my-cluster"},
+						{"Name": "my-ami"},
+					},
+					AWS: *provider,
+				})
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{
+					ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name},
+					Requirements: []v1.NodeSelectorRequirement{
+						{
+							Key:      v1.LabelArchStable,
+							Operator: v1.NodeSelectorOpIn,
+							Values:   []string{v1alpha5.ArchitectureAmd64},
+						},
+					},
+				}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+				Expect(*input.LaunchTemplateData.ImageId).To(Equal("ami-456"))
+			})
 
 			It("should fail if no amis match selector.", func() {
 				fakeEC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{}})
@@ -1476,6 +2913,82 @@ var _ = Describe("LaunchTemplates", func() {
 				ExpectNotScheduled(ctx, env.Client, pod)
 				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(0))
 			})
+			It("should not use amis whose deprecation time has passed", func() {
+				fakeEC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+					{
+						ImageId:         aws.String("ami-123"),
+						Architecture:    aws.String("x86_64"),
+						CreationDate:    aws.String("2022-01-01T12:00:00Z"),
+						DeprecationTime: aws.String("2022-06-01T12:00:00.000Z"),
+					},
+					{
+						ImageId:         aws.String("ami-456"),
+						Architecture:    aws.String("x86_64"),
+						CreationDate:    aws.String("2021-01-01T12:00:00Z"),
+						DeprecationTime: aws.String(time.Now().Add(time.Hour * 24 * 365).Format(time.RFC3339)),
+					},
+				}})
+				nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+					AMISelector: map[string]string{"karpenter.sh/discovery": "my-cluster"},
+					AWS:         *provider,
+				})
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				newProvisioner := test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}})
+				ExpectApplied(ctx, env.Client, newProvisioner)
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+				Expect(*input.LaunchTemplateData.ImageId).To(Equal("ami-456"))
+			})
+			It("should use deprecated amis when includeDeprecatedAMIs is set", func() {
+				fakeEC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+					{
+						ImageId:         aws.String("ami-123"),
+						Architecture:    aws.String("x86_64"),
+						CreationDate:    aws.String("2022-01-01T12:00:00Z"),
+						DeprecationTime: aws.String("2022-06-01T12:00:00.000Z"),
+					},
+					{
+						ImageId:      aws.String("ami-456"),
+						Architecture: aws.String("x86_64"),
+						CreationDate: aws.String("2021-01-01T12:00:00Z"),
+					},
+				}})
+				nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+					AMISelector:           map[string]string{"karpenter.sh/discovery": "my-cluster"},
+					IncludeDeprecatedAMIs: aws.Bool(true),
+					AWS:                   *provider,
+				})
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				newProvisioner := test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}})
+				ExpectApplied(ctx, env.Client, newProvisioner)
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(1))
+				input := fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop()
+				Expect(*input.LaunchTemplateData.ImageId).To(Equal("ami-123"))
+			})
+			It("should fail if all amis matching the selector are deprecated", func() {
+				fakeEC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+					{
+						ImageId:         aws.String("ami-123"),
+						Architecture:    aws.String("x86_64"),
+						CreationDate:    aws.String("2022-01-01T12:00:00Z"),
+						DeprecationTime: aws.String("2022-06-01T12:00:00.000Z"),
+					},
+				}})
+				nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+					AMISelector: map[string]string{"karpenter.sh/discovery": "my-cluster"},
+					AWS:         *provider,
+				})
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				newProvisioner := test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}})
+				ExpectApplied(ctx, env.Client, newProvisioner)
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectNotScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(Equal(0))
+			})
 			It("should choose amis from SSM if no selector specified in AWSNodeTemplate", func() {
 				nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
 					UserData: nil,
@@ -1490,6 +3003,205 @@ var _ = Describe("LaunchTemplates", func() {
 				Expect(*input.LaunchTemplateData.ImageId).To(ContainSubstring("test-ami"))
 			})
 		})
+		Context("AMI Changes", func() {
+			It("should record the ssm queries that the node template resolves amis from", func() {
+				nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{AWS: *provider})
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				queried := sets.NewString()
+				for fakeSSMAPI.CalledWithGetParameterInput.Len() > 0 {
+					queried.Insert(aws.StringValue(fakeSSMAPI.CalledWithGetParameterInput.Pop().Name))
+				}
+				Expect(ssmQueries.List(nodeTemplate.Name)).To(ConsistOf(queried.List()))
+				// changes are reported by the AWSNodeTemplate controller
+				Expect(recorder.Calls("AMIChanged")).To(BeZero())
+			})
+			It("should not record ssm queries for a node template with an amiSelector", func() {
+				fakeEC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+					{ImageId: aws.String("ami-123"), Architecture: aws.String("x86_64"), CreationDate: aws.String("2022-08-15T12:00:00Z")},
+				}})
+				nodeTemplate := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+					AMISelector: map[string]string{"karpenter.sh/discovery": "my-cluster"},
+					AWS:         *provider,
+				})
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(ssmQueries.List(nodeTemplate.Name)).To(BeEmpty())
+			})
+		})
+		Context("AMI Pinning", func() {
+			var nodeTemplate *v1alpha1.AWSNodeTemplate
+			getNodeTemplate := func() *v1alpha1.AWSNodeTemplate {
+				stored := &v1alpha1.AWSNodeTemplate{}
+				Expect(env.Client.Get(ctx, client.ObjectKeyFromObject(nodeTemplate), stored)).To(Succeed())
+				return stored
+			}
+			// pin launches once to record the ssm queries of the node template, and pins them to the ami like the
+			// AWSNodeTemplate controller would
+			pin := func(amiID string) {
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				nodeTemplate = getNodeTemplate()
+				nodeTemplate.Status.PinnedAMIs = lo.SliceToMap(ssmQueries.List(nodeTemplate.Name), func(ssmQuery string) (string, string) { return ssmQuery, amiID })
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				launchTemplateCache.Flush()
+				fakeEC2API.CalledWithCreateLaunchTemplateInput.Reset()
+			}
+			BeforeEach(func() {
+				nodeTemplate = test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+					PinAMI: aws.Bool(true),
+					AWS:    *provider,
+				})
+				fakeSSMAPI.GetParameterOutput = &ssm.GetParameterOutput{Parameter: &ssm.Parameter{Value: aws.String("ami-newer")}}
+			})
+			It("should launch with the amis pinned in the AWSNodeTemplate status", func() {
+				pin("ami-pinned")
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(*fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().LaunchTemplateData.ImageId).To(Equal("ami-pinned"))
+			})
+			It("should not write the AWSNodeTemplate status when launching", func() {
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				nodeTemplate = getNodeTemplate()
+				Expect(nodeTemplate.Status.PinnedAMIs).To(BeEmpty())
+				Expect(nodeTemplate.StatusConditions().GetCondition(v1alpha1.AMIReady)).To(BeNil())
+			})
+			It("should launch with the newest ami once the rollforward annotation changes", func() {
+				pin("ami-pinned")
+				nodeTemplate.Annotations = lo.Assign(nodeTemplate.Annotations, map[string]string{v1alpha1.AnnotationAMIRollforward: "1"})
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(*fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().LaunchTemplateData.ImageId).To(Equal("ami-newer"))
+			})
+			It("should not launch with the pinned amis when pinning is disabled", func() {
+				pin("ami-pinned")
+				nodeTemplate.Spec.PinAMI = nil
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(*fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().LaunchTemplateData.ImageId).To(Equal("ami-newer"))
+			})
+		})
+		Context("Fallback AMIs", func() {
+			var nodeTemplate *v1alpha1.AWSNodeTemplate
+			BeforeEach(func() {
+				nodeTemplate = test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+					FallbackAMIIDs: []string{"ami-fallback-amd64", "ami-fallback-arm64"},
+					AWS:            *provider,
+				})
+				fakeEC2API.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+					{
+						ImageId:      aws.String("ami-fallback-amd64"),
+						Architecture: aws.String("x86_64"),
+						CreationDate: aws.String("2022-08-15T12:00:00Z"),
+					},
+					{
+						ImageId:      aws.String("ami-fallback-arm64"),
+						Architecture: aws.String("arm64"),
+						CreationDate: aws.String("2022-08-15T12:00:00Z"),
+					},
+				}})
+				recorder.Reset()
+			})
+			It("should use the fallback amis when the default ami can't be resolved from SSM", func() {
+				fakeSSMAPI.WantErr = fmt.Errorf("ssm unavailable")
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(fakeEC2API.CalledWithCreateLaunchTemplateInput.Len()).To(BeNumerically(">=", 1))
+				actualImageIDs := sets.NewString()
+				for fakeEC2API.CalledWithCreateLaunchTemplateInput.Len() > 0 {
+					actualImageIDs.Insert(aws.StringValue(fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().LaunchTemplateData.ImageId))
+				}
+				Expect(actualImageIDs.List()).To(ConsistOf("ami-fallback-amd64", "ami-fallback-arm64"))
+				Expect(recorder.Calls("AMIFallback")).To(BeNumerically(">", 0))
+			})
+			It("should not use the fallback amis when SSM is available", func() {
+				fakeSSMAPI.GetParameterOutput = &ssm.GetParameterOutput{Parameter: &ssm.Parameter{Value: aws.String("ami-default")}}
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(*fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().LaunchTemplateData.ImageId).To(Equal("ami-default"))
+				Expect(recorder.Calls("AMIFallback")).To(BeZero())
+			})
+			It("should fail to launch when SSM fails and there are no fallback amis", func() {
+				fakeSSMAPI.WantErr = fmt.Errorf("ssm unavailable")
+				nodeTemplate.Spec.FallbackAMIIDs = nil
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectNotScheduled(ctx, env.Client, pod)
+			})
+			It("should fail without naming fallback amis when there is no node template or instance type", func() {
+				_, err := cloudProvider.instanceProvider.launchTemplateProvider.ResolveAMIs(ctx, provider, &cloudprovider.NodeRequest{
+					Template: scheduling.NewNodeTemplate(test.Provisioner(coretest.ProvisionerOptions{Provider: provider})),
+				})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).ToNot(ContainSubstring("fallback"))
+			})
+		})
+		Context("SSM Retries", func() {
+			var nodeTemplate *v1alpha1.AWSNodeTemplate
+			BeforeEach(func() {
+				nodeTemplate = test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{AWS: *provider})
+				fakeSSMAPI.GetParameterOutput = &ssm.GetParameterOutput{Parameter: &ssm.Parameter{Value: aws.String("ami-default")}}
+			})
+			It("should retry ssm lookups that are throttled", func() {
+				fakeSSMAPI.NextErrors = []error{
+					awserr.New("ThrottlingException", "Rate exceeded", nil),
+					awserr.New("ThrottlingException", "Rate exceeded", nil),
+				}
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+				Expect(*fakeEC2API.CalledWithCreateLaunchTemplateInput.Pop().LaunchTemplateData.ImageId).To(Equal("ami-default"))
+			})
+			It("should not retry ssm lookups that fail with a non-retryable error", func() {
+				fakeSSMAPI.WantErr = awserr.New(ssm.ErrCodeParameterNotFound, "not found", nil)
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectNotScheduled(ctx, env.Client, pod)
+				queries := sets.NewString()
+				calls := fakeSSMAPI.CalledWithGetParameterInput.Len()
+				for fakeSSMAPI.CalledWithGetParameterInput.Len() > 0 {
+					queries.Insert(aws.StringValue(fakeSSMAPI.CalledWithGetParameterInput.Pop().Name))
+				}
+				Expect(calls).To(Equal(queries.Len()))
+			})
+			It("should cache a persistent failure without calling SSM again", func() {
+				fakeSSMAPI.WantErr = awserr.New("ThrottlingException", "Rate exceeded", nil)
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{ProviderRef: &v1alpha5.ProviderRef{Name: nodeTemplate.Name}}))
+				pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectNotScheduled(ctx, env.Client, pod)
+
+				// failures are cached, so provisioning again doesn't call SSM
+				calls := fakeSSMAPI.CalledWithGetParameterInput.Len()
+				pod = ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectNotScheduled(ctx, env.Client, pod)
+				Expect(fakeSSMAPI.CalledWithGetParameterInput.Len()).To(Equal(calls))
+
+				// once SSM recovers and the failure has expired, nodes are launched again
+				fakeSSMAPI.WantErr = nil
+				ssmCache.Flush()
+				pod = ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+				ExpectScheduled(ctx, env.Client, pod)
+			})
+		})
 		Context("Kubelet Args", func() {
 			It("should specify the --dns-cluster-ip flag when clusterDNSIP is set", func() {
 				ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{
@@ -0,0 +1,173 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/logging"
+
+	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/cloudprovider"
+	"github.com/aws/karpenter-core/pkg/operator/settingsstore"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+)
+
+// OfferingsPath is the path on the metrics endpoint that the OfferingsHandler is served from
+const OfferingsPath = "/offerings"
+
+// OfferingsResponse is the body returned by the OfferingsHandler
+type OfferingsResponse struct {
+	InstanceTypes []InstanceTypeOfferings `json:"instanceTypes"`
+}
+
+type InstanceTypeOfferings struct {
+	Name      string     `json:"name"`
+	Offerings []Offering `json:"offerings"`
+}
+
+type Offering struct {
+	Zone         string  `json:"zone"`
+	CapacityType string  `json:"capacityType"`
+	Price        float64 `json:"price"`
+	Available    bool    `json:"available"`
+}
+
+// OfferingsHandler serves a read-only JSON view of the instance types and offerings discovered by the
+// InstanceTypeProvider, either for every provisioner or for the one named by the "provisioner" query parameter.
+// Responses for the controller's region are built from cached data only, so that callers can't drive additional EC2 or
+// pricing API calls. The "region" query parameter selects one of the instance type discovery regions instead, whose
+// offerings are only discovered for this API, at most once per cache TTL.
+type OfferingsHandler struct {
+	cloudProvider *CloudProvider
+	settingsStore settingsstore.Store
+}
+
+func NewOfferingsHandler(cloudProvider *CloudProvider, settingsStore settingsstore.Store) *OfferingsHandler {
+	return &OfferingsHandler{
+		cloudProvider: cloudProvider,
+		settingsStore: settingsStore,
+	}
+}
+
+func (h *OfferingsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx := h.settingsStore.InjectSettings(r.Context())
+
+	if region := r.URL.Query().Get("region"); region != "" && region != h.cloudProvider.instanceTypeProvider.region {
+		h.serveRegion(ctx, w, r, region)
+		return
+	}
+	var provider *v1alpha1.AWS
+	if name := r.URL.Query().Get("provisioner"); name != "" {
+		provisioner := &v1alpha5.Provisioner{}
+		if err := h.cloudProvider.kubeClient.Get(ctx, types.NamespacedName{Name: name}, provisioner); err != nil {
+			if errors.IsNotFound(err) {
+				http.Error(w, fmt.Sprintf("provisioner %q not found", name), http.StatusNotFound)
+				return
+			}
+			h.internalError(w, r, fmt.Errorf("getting provisioner, %w", err))
+			return
+		}
+		nodeTemplate, err := h.cloudProvider.getNodeTemplate(ctx, provisioner.Spec.ProviderRef)
+		if err != nil {
+			h.internalError(w, r, err)
+			return
+		}
+		if provider, err = h.cloudProvider.getProvider(provisioner.Spec.Provider, nodeTemplate); err != nil {
+			h.internalError(w, r, err)
+			return
+		}
+	}
+	offerings, ok, err := h.cloudProvider.instanceTypeProvider.CachedOfferings(ctx, provider)
+	if err != nil {
+		h.internalError(w, r, err)
+		return
+	}
+	if !ok {
+		http.Error(w, "instance type offerings have not been discovered yet", http.StatusServiceUnavailable)
+		return
+	}
+	h.write(ctx, w, offerings)
+}
+
+// serveRegion serves the offerings of an instance type discovery region. Provisioners launch into the controller's
+// region only, so they can't be combined with another region.
+func (h *OfferingsHandler) serveRegion(ctx context.Context, w http.ResponseWriter, r *http.Request, region string) {
+	if r.URL.Query().Get("provisioner") != "" {
+		http.Error(w, "provisioner can't be combined with a region other than the controller's", http.StatusBadRequest)
+		return
+	}
+	offerings, ok, err := h.cloudProvider.instanceTypeProvider.RegionalOfferings(ctx, region)
+	if err != nil {
+		h.internalError(w, r, err)
+		return
+	}
+	if !ok {
+		http.Error(w, fmt.Sprintf("region %q is not an instance type discovery region", region), http.StatusNotFound)
+		return
+	}
+	h.write(ctx, w, offerings)
+}
+
+func (h *OfferingsHandler) write(ctx context.Context, w http.ResponseWriter, offerings map[string][]cloudprovider.Offering) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(NewOfferingsResponse(offerings)); err != nil {
+		logging.FromContext(ctx).Errorf("Writing offerings response, %s", err)
+	}
+}
+
+func (h *OfferingsHandler) internalError(w http.ResponseWriter, r *http.Request, err error) {
+	logging.FromContext(r.Context()).Errorf("Serving offerings, %s", err)
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// NewOfferingsResponse converts offerings keyed by instance type name into a response, sorted so that it's stable
+// between requests
+func NewOfferingsResponse(offerings map[string][]cloudprovider.Offering) OfferingsResponse {
+	response := OfferingsResponse{InstanceTypes: []InstanceTypeOfferings{}}
+	for name, instanceTypeOfferings := range offerings {
+		result := InstanceTypeOfferings{Name: name, Offerings: []Offering{}}
+		for _, offering := range instanceTypeOfferings {
+			result.Offerings = append(result.Offerings, Offering{
+				Zone:         offering.Zone,
+				CapacityType: offering.CapacityType,
+				Price:        offering.Price,
+				Available:    offering.Available,
+			})
+		}
+		sort.Slice(result.Offerings, func(i, j int) bool {
+			if result.Offerings[i].Zone != result.Offerings[j].Zone {
+				return result.Offerings[i].Zone < result.Offerings[j].Zone
+			}
+			return result.Offerings[i].CapacityType < result.Offerings[j].CapacityType
+		})
+		response.InstanceTypes = append(response.InstanceTypes, result)
+	}
+	sort.Slice(response.InstanceTypes, func(i, j int) bool {
+		return response.InstanceTypes[i].Name < response.InstanceTypes[j].Name
+	})
+	return response
+}
@@ -0,0 +1,157 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/samber/lo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/aws/karpenter-core/pkg/cloudprovider"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+	awssettings "github.com/aws/karpenter/pkg/apis/config/settings"
+	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var _ = Describe("Offerings API", func() {
+	var handler *OfferingsHandler
+	serve := func(method string, target string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(method, target, nil))
+		return recorder
+	}
+	BeforeEach(func() {
+		handler = NewOfferingsHandler(cloudProvider, settingsStore)
+	})
+	It("should serialize offerings sorted by instance type, zone and capacity type", func() {
+		response := NewOfferingsResponse(map[string][]cloudprovider.Offering{
+			"m5.large": {
+				{Zone: "test-zone-1b", CapacityType: ec2.UsageClassTypeOnDemand, Price: 0.096, Available: true},
+				{Zone: "test-zone-1a", CapacityType: ec2.UsageClassTypeSpot, Price: 0.04, Available: false},
+				{Zone: "test-zone-1a", CapacityType: ec2.UsageClassTypeOnDemand, Price: 0.096, Available: true},
+			},
+			"c5.large": {},
+		})
+		raw, err := json.Marshal(response)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(raw)).To(MatchJSON(`{"instanceTypes":[
+			{"name":"c5.large","offerings":[]},
+			{"name":"m5.large","offerings":[
+				{"zone":"test-zone-1a","capacityType":"on-demand","price":0.096,"available":true},
+				{"zone":"test-zone-1a","capacityType":"spot","price":0.04,"available":false},
+				{"zone":"test-zone-1b","capacityType":"on-demand","price":0.096,"available":true}
+			]}
+		]}`))
+	})
+	It("should be unavailable before instance types have been discovered", func() {
+		Expect(serve(http.MethodGet, OfferingsPath).Code).To(Equal(http.StatusServiceUnavailable))
+	})
+	It("should only allow GET requests", func() {
+		Expect(serve(http.MethodPost, OfferingsPath).Code).To(Equal(http.StatusMethodNotAllowed))
+	})
+	It("should serve the discovered offerings without calling EC2", func() {
+		_, err := instanceTypeProvider.Get(ctx, provider, nil)
+		Expect(err).ToNot(HaveOccurred())
+		// any call to EC2 would consume this error
+		fakeEC2API.NextError.Set(fmt.Errorf("unexpected call to EC2"))
+
+		recorder := serve(http.MethodGet, OfferingsPath)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(fakeEC2API.NextError.IsNil()).To(BeFalse())
+
+		response := OfferingsResponse{}
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(Succeed())
+		Expect(response.InstanceTypes).ToNot(BeEmpty())
+		instanceType, ok := lo.Find(response.InstanceTypes, func(i InstanceTypeOfferings) bool { return i.Name == "m5.large" })
+		Expect(ok).To(BeTrue())
+		Expect(instanceType.Offerings).To(ContainElement(Offering{
+			Zone:         "test-zone-1a",
+			CapacityType: ec2.UsageClassTypeOnDemand,
+			Price:        lo.Must(pricingProvider.OnDemandPrice("m5.large")),
+			Available:    true,
+		}))
+	})
+	It("should omit excluded instance types", func() {
+		settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{ExcludedInstanceTypes: []string{"m5.*"}})
+		_, err := instanceTypeProvider.Get(ctx, provider, nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		recorder := serve(http.MethodGet, OfferingsPath)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		response := OfferingsResponse{}
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(Succeed())
+		Expect(lo.Map(response.InstanceTypes, func(i InstanceTypeOfferings, _ int) string { return i.Name })).ToNot(ContainElement("m5.large"))
+	})
+	It("should serve the offerings of a provisioner", func() {
+		ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider, ObjectMeta: metav1.ObjectMeta{Name: "the-provisioner"}}))
+		Expect(serve(http.MethodGet, OfferingsPath+"?provisioner=the-provisioner").Code).To(Equal(http.StatusServiceUnavailable))
+
+		_, err := instanceTypeProvider.Get(ctx, provider, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(serve(http.MethodGet, OfferingsPath+"?provisioner=the-provisioner").Code).To(Equal(http.StatusOK))
+	})
+	It("should return not found for an unknown provisioner", func() {
+		Expect(serve(http.MethodGet, OfferingsPath+"?provisioner=unknown").Code).To(Equal(http.StatusNotFound))
+	})
+	Context("Discovery Regions", func() {
+		var regionalEC2API *fake.EC2API
+		BeforeEach(func() {
+			regionalEC2API = &fake.EC2API{}
+			regionalEC2API.DescribeAvailabilityZonesOutput.Set(&ec2.DescribeAvailabilityZonesOutput{AvailabilityZones: []*ec2.AvailabilityZone{
+				{ZoneName: aws.String("us-east-1a"), ZoneId: aws.String("use1-az1")},
+			}})
+			regionalEC2API.DescribeInstanceTypeOfferingsOutput.Set(&ec2.DescribeInstanceTypeOfferingsOutput{InstanceTypeOfferings: []*ec2.InstanceTypeOffering{
+				{InstanceType: aws.String("m5.large"), Location: aws.String("us-east-1a")},
+			}})
+			instanceTypeProvider.regions = map[string]*regionalProviders{
+				"us-east-1": {ec2api: regionalEC2API, newPricingProvider: func() *PricingProvider {
+					return NewPricingProvider(ctx, &fake.PricingAPI{}, regionalEC2API, "us-east-1", false, 12*time.Hour, make(chan struct{}))
+				}},
+			}
+			DeferCleanup(func() { instanceTypeProvider.regions = nil })
+		})
+		It("should serve the offerings of a discovery region", func() {
+			recorder := serve(http.MethodGet, OfferingsPath+"?region=us-east-1")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			response := OfferingsResponse{}
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(Succeed())
+			instanceType, ok := lo.Find(response.InstanceTypes, func(i InstanceTypeOfferings) bool { return i.Name == "m5.large" })
+			Expect(ok).To(BeTrue())
+			Expect(lo.Uniq(lo.Map(instanceType.Offerings, func(o Offering, _ int) string { return o.Zone }))).To(ConsistOf("us-east-1a"))
+			// the offerings of the controller's region aren't discovered for another region
+			Expect(fakeEC2API.CalledWithDescribeInstanceTypeOfferingsInput.Len()).To(BeZero())
+		})
+		It("should serve the cached offerings of the controller's region when it's requested by name", func() {
+			Expect(serve(http.MethodGet, OfferingsPath+"?region="+instanceTypeProvider.region).Code).To(Equal(http.StatusServiceUnavailable))
+		})
+		It("should return not found for a region that isn't a discovery region", func() {
+			Expect(serve(http.MethodGet, OfferingsPath+"?region=eu-west-1").Code).To(Equal(http.StatusNotFound))
+		})
+		It("should reject a provisioner in another region", func() {
+			Expect(serve(http.MethodGet, OfferingsPath+"?region=us-east-1&provisioner=default").Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+})
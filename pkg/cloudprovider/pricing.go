@@ -74,9 +74,6 @@ func newZonalPricing(defaultPrice float64) zonalPricing {
 	return z
 }
 
-// pricingUpdatePeriod is how often we try to update our pricing information after the initial update on startup
-const pricingUpdatePeriod = 12 * time.Hour
-
 // NewPricingAPI returns a pricing API configured based on a particular region
 func NewPricingAPI(sess *session.Session, region string) pricingiface.PricingAPI {
 	if sess == nil {
@@ -92,7 +89,8 @@ func NewPricingAPI(sess *session.Session, region string) pricingiface.PricingAPI
 	return pricing.New(sess, &aws.Config{Region: aws.String(pricingAPIRegion)})
 }
 
-func NewPricingProvider(ctx context.Context, pricing pricingiface.PricingAPI, ec2Api ec2iface.EC2API, region string, isolatedVPC bool, startAsync <-chan struct{}) *PricingProvider {
+func NewPricingProvider(ctx context.Context, pricing pricingiface.PricingAPI, ec2Api ec2iface.EC2API, region string, isolatedVPC bool,
+	refreshPeriod time.Duration, startAsync <-chan struct{}) *PricingProvider {
 	p := &PricingProvider{
 		region:             region,
 		onDemandUpdateTime: initialPriceUpdate,
@@ -122,7 +120,7 @@ func NewPricingProvider(ctx context.Context, pricing pricingiface.PricingAPI, ec
 			}
 			// if it took many hours to be elected leader, we want to re-fetch pricing before we start our periodic
 			// polling
-			if time.Since(startup) > pricingUpdatePeriod {
+			if time.Since(startup) > refreshPeriod {
 				p.updatePricing(ctx)
 			}
 
@@ -130,7 +128,7 @@ func NewPricingProvider(ctx context.Context, pricing pricingiface.PricingAPI, ec
 				select {
 				case <-ctx.Done():
 					return
-				case <-time.After(pricingUpdatePeriod):
+				case <-time.After(refreshPeriod):
 					p.updatePricing(ctx)
 				}
 			}
@@ -146,6 +144,13 @@ func (p *PricingProvider) InstanceTypes() []string {
 	return lo.Union(lo.Keys(p.onDemandPrices), lo.Keys(p.spotPrices))
 }
 
+// HasPrices returns true if either a spot or on-demand price is known for any instance type
+func (p *PricingProvider) HasPrices() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.onDemandPrices) != 0 || len(p.spotPrices) != 0
+}
+
 // OnDemandLastUpdated returns the time that the on-demand pricing was last updated
 func (p *PricingProvider) OnDemandLastUpdated() time.Time {
 	p.mu.RLock()
@@ -15,6 +15,7 @@ limitations under the License.
 package cloudprovider
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -35,14 +36,14 @@ var _ = Describe("Pricing", func() {
 	})
 	It("should return static on-demand data if pricing API fails", func() {
 		fakePricingAPI.NextError.Set(fmt.Errorf("failed"))
-		p := NewPricingProvider(ctx, fakePricingAPI, fakeEC2API, "", false, make(chan struct{}))
+		p := NewPricingProvider(ctx, fakePricingAPI, fakeEC2API, "", false, 12*time.Hour, make(chan struct{}))
 		price, ok := p.OnDemandPrice("c5.large")
 		Expect(ok).To(BeTrue())
 		Expect(price).To(BeNumerically(">", 0))
 	})
 	It("should return static spot data if EC2 describeSpotPriceHistory API fails", func() {
 		fakePricingAPI.NextError.Set(fmt.Errorf("failed"))
-		p := NewPricingProvider(ctx, fakePricingAPI, fakeEC2API, "", false, make(chan struct{}))
+		p := NewPricingProvider(ctx, fakePricingAPI, fakeEC2API, "", false, 12*time.Hour, make(chan struct{}))
 		price, ok := p.SpotPrice("c5.large", "test-zone-1a")
 		Expect(ok).To(BeTrue())
 		Expect(price).To(BeNumerically(">", 0))
@@ -57,7 +58,7 @@ var _ = Describe("Pricing", func() {
 			},
 		})
 		updateStart := time.Now()
-		p := NewPricingProvider(ctx, fakePricingAPI, fakeEC2API, "", false, make(chan struct{}))
+		p := NewPricingProvider(ctx, fakePricingAPI, fakeEC2API, "", false, 12*time.Hour, make(chan struct{}))
 		Eventually(func() bool { return p.OnDemandLastUpdated().After(updateStart) }).Should(BeTrue())
 
 		price, ok := p.OnDemandPrice("c98.large")
@@ -68,6 +69,22 @@ var _ = Describe("Pricing", func() {
 		Expect(ok).To(BeTrue())
 		Expect(price).To(BeNumerically("==", 1.23))
 	})
+	It("should refresh on-demand pricing on the refresh period", func() {
+		fakePricingAPI.GetProductsOutput.Set(&pricing.GetProductsOutput{
+			PriceList: []aws.JSONValue{fake.NewOnDemandPrice("c98.large", 1.20)},
+		})
+		refreshCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		startAsync := make(chan struct{})
+		close(startAsync)
+		p := NewPricingProvider(refreshCtx, fakePricingAPI, fakeEC2API, "", false, 100*time.Millisecond, startAsync)
+		Eventually(func() float64 { price, _ := p.OnDemandPrice("c98.large"); return price }).Should(BeNumerically("==", 1.20))
+
+		fakePricingAPI.GetProductsOutput.Set(&pricing.GetProductsOutput{
+			PriceList: []aws.JSONValue{fake.NewOnDemandPrice("c98.large", 1.50)},
+		})
+		Eventually(func() float64 { price, _ := p.OnDemandPrice("c98.large"); return price }).Should(BeNumerically("==", 1.50))
+	})
 	It("should update spot pricing with response from the pricing API", func() {
 		now := time.Now()
 		fakeEC2API.DescribeSpotPriceHistoryOutput.Set(&ec2.DescribeSpotPriceHistoryOutput{
@@ -105,7 +122,7 @@ var _ = Describe("Pricing", func() {
 			},
 		})
 		updateStart := time.Now()
-		p := NewPricingProvider(ctx, fakePricingAPI, fakeEC2API, "", false, make(chan struct{}))
+		p := NewPricingProvider(ctx, fakePricingAPI, fakeEC2API, "", false, 12*time.Hour, make(chan struct{}))
 		Eventually(func() bool { return p.SpotLastUpdated().After(updateStart) }).Should(BeTrue())
 
 		price, ok := p.SpotPrice("c98.large", "test-zone-1b")
@@ -141,7 +158,7 @@ var _ = Describe("Pricing", func() {
 			},
 		})
 		updateStart := time.Now()
-		p := NewPricingProvider(ctx, fakePricingAPI, fakeEC2API, "", false, make(chan struct{}))
+		p := NewPricingProvider(ctx, fakePricingAPI, fakeEC2API, "", false, 12*time.Hour, make(chan struct{}))
 		Eventually(func() bool { return p.SpotLastUpdated().After(updateStart) }).Should(BeTrue())
 
 		price, ok := p.SpotPrice("c98.large", "test-zone-1a")
@@ -170,7 +187,7 @@ var _ = Describe("Pricing", func() {
 			},
 		})
 		updateStart := time.Now()
-		p := NewPricingProvider(ctx, fakePricingAPI, fakeEC2API, "", false, make(chan struct{}))
+		p := NewPricingProvider(ctx, fakePricingAPI, fakeEC2API, "", false, 12*time.Hour, make(chan struct{}))
 		Eventually(func() bool { return p.SpotLastUpdated().After(updateStart) }).Should(BeTrue())
 
 		_, ok := p.SpotPrice("c99.large", "test-zone-1b")
@@ -198,7 +215,7 @@ var _ = Describe("Pricing", func() {
 				fake.NewOnDemandPrice("c99.large", 1.23),
 			},
 		})
-		p := NewPricingProvider(ctx, fakePricingAPI, fakeEC2API, "", false, make(chan struct{}))
+		p := NewPricingProvider(ctx, fakePricingAPI, fakeEC2API, "", false, 12*time.Hour, make(chan struct{}))
 		Eventually(func() bool { return p.OnDemandLastUpdated().After(updateStart) }, 5*time.Second).Should(BeTrue())
 		inp := fakeEC2API.DescribeSpotPriceHistoryInput.Clone()
 		Expect(lo.Map(inp.ProductDescriptions, func(x *string, _ int) string { return *x })).
@@ -0,0 +1,275 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+	"github.com/aws/aws-sdk-go/service/servicequotas/servicequotasiface"
+	"github.com/patrickmn/go-cache"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"knative.dev/pkg/logging"
+
+	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/cloudprovider"
+	"github.com/aws/karpenter-core/pkg/events"
+	"github.com/aws/karpenter-core/pkg/utils/pretty"
+	awssettings "github.com/aws/karpenter/pkg/apis/config/settings"
+	awscache "github.com/aws/karpenter/pkg/cache"
+	awscontext "github.com/aws/karpenter/pkg/context"
+	awserrors "github.com/aws/karpenter/pkg/errors"
+)
+
+const (
+	// QuotasCacheTTL is how long vCPU quotas are cached. Quotas change rarely, so they are refreshed infrequently.
+	QuotasCacheTTL = time.Hour
+	// QuotaUsageCacheTTL is how long the vCPU usage of the account is cached. Usage changes with every launch, so it
+	// is only cached long enough to avoid describing every instance in the account for each launch in a batch.
+	QuotaUsageCacheTTL = time.Minute
+
+	quotasCacheKey             = "quotas"
+	quotaUsageCacheKey         = "usage"
+	vcpuLimitExceededKeyPrefix = "vcpu-limit-exceeded"
+)
+
+// vCPU quotas are applied to groups of instance families. Each group has an On-Demand quota and a Spot quota.
+// https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/ec2-on-demand-instances.html#ec2-on-demand-instances-limits
+var quotaCodes = map[string]map[string]string{
+	"standard": {v1alpha5.CapacityTypeOnDemand: "L-1216C47A", v1alpha5.CapacityTypeSpot: "L-34B43A08"},
+	"f":        {v1alpha5.CapacityTypeOnDemand: "L-74FC7D96", v1alpha5.CapacityTypeSpot: "L-88CF9481"},
+	"g":        {v1alpha5.CapacityTypeOnDemand: "L-DB2E81BA", v1alpha5.CapacityTypeSpot: "L-3819A6DF"},
+	"inf":      {v1alpha5.CapacityTypeOnDemand: "L-1945791B", v1alpha5.CapacityTypeSpot: "L-B5D1601B"},
+	"p":        {v1alpha5.CapacityTypeOnDemand: "L-417A185B", v1alpha5.CapacityTypeSpot: "L-7212CCBC"},
+	"x":        {v1alpha5.CapacityTypeOnDemand: "L-7295265B", v1alpha5.CapacityTypeSpot: "L-E3A00192"},
+}
+
+// quotaKey identifies the vCPU quota for a group of instance families and a capacity type
+type quotaKey struct {
+	group        string
+	capacityType string
+}
+
+// QuotaProvider checks the account's vCPU service quotas before launching so that instance types that can't be
+// launched without exceeding a quota aren't attempted. Quotas are only checked when aws.enableQuotaCheck is set,
+// since listing them requires the servicequotas:ListServiceQuotas permission.
+type QuotaProvider struct {
+	sync.Mutex
+	servicequotasapi servicequotasiface.ServiceQuotasAPI
+	ec2api           ec2iface.EC2API
+	recorder         events.Recorder
+	cache            *awscache.Metered
+	cm               *pretty.ChangeMonitor
+}
+
+func NewQuotaProvider(servicequotasapi servicequotasiface.ServiceQuotasAPI, ec2api ec2iface.EC2API, recorder events.Recorder) *QuotaProvider {
+	return &QuotaProvider{
+		servicequotasapi: servicequotasapi,
+		ec2api:           ec2api,
+		recorder:         recorder,
+		cache:            awscache.NewMetered(awscache.QuotasCacheName, cache.New(QuotasCacheTTL, awscontext.CacheCleanupInterval)),
+		cm:               pretty.NewChangeMonitor(),
+	}
+}
+
+// Filter removes the instance types that recently failed to launch with VcpuLimitExceeded, as well as those that
+// would exceed the remaining vCPU quota for the capacity type. Quotas that can't be retrieved don't filter anything,
+// since EC2 still enforces them at launch.
+func (p *QuotaProvider) Filter(ctx context.Context, nodeRequest *cloudprovider.NodeRequest, capacityType string) []cloudprovider.InstanceType {
+	instanceTypes := lo.Reject(nodeRequest.InstanceTypeOptions, func(it cloudprovider.InstanceType, _ int) bool {
+		vcpus, ok := p.cache.Get(vcpuLimitExceededKey(it.Name(), capacityType))
+		return ok && aws.Int64Value(it.(*InstanceType).VCpuInfo.DefaultVCpus) >= vcpus.(int64)
+	})
+	if len(instanceTypes) < len(nodeRequest.InstanceTypeOptions) {
+		logging.FromContext(ctx).Debugf("Skipping %d instance types which recently exceeded the %s vCPU limit", len(nodeRequest.InstanceTypeOptions)-len(instanceTypes), capacityType)
+	}
+	if !awssettings.FromContext(ctx).EnableQuotaCheck {
+		return instanceTypes
+	}
+	headroom, err := p.headroom(ctx)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Checking vCPU quotas, %s", err)
+		return instanceTypes
+	}
+	var skipped []string
+	instanceTypes = lo.Filter(instanceTypes, func(it cloudprovider.InstanceType, _ int) bool {
+		available, ok := headroom[quotaKey{group: quotaGroup(it.Name()), capacityType: capacityType}]
+		// quotas count the physical vCPUs of the instance, regardless of any capacity overrides
+		if !ok || aws.Int64Value(it.(*InstanceType).VCpuInfo.DefaultVCpus) <= available {
+			return true
+		}
+		skipped = append(skipped, it.Name())
+		return false
+	})
+	if len(skipped) > 0 {
+		logging.FromContext(ctx).Debugf("Skipping instance types %v which exceed the available %s vCPU quota", skipped, capacityType)
+		p.recorder.Publish(InsufficientVCPUQuota(nodeRequest.Template.ProvisionerName, capacityType, skipped))
+	}
+	return instanceTypes
+}
+
+// MarkVCPULimitExceeded backs off the instance types that failed to launch with VcpuLimitExceeded for the
+// VCPULimitBackoff. Instance types that share the vCPU quota are skipped too, but only if they have at least as many
+// vCPUs as the smallest one that failed, since smaller instance types may still fit in the remaining quota.
+func (p *QuotaProvider) MarkVCPULimitExceeded(ctx context.Context, nodeRequest *cloudprovider.NodeRequest, capacityType string, fleetErrs []*ec2.CreateFleetError) {
+	instanceTypes := sets.NewString()
+	for _, err := range fleetErrs {
+		if awserrors.IsVCPULimitExceeded(err) && err.LaunchTemplateAndOverrides != nil && err.LaunchTemplateAndOverrides.Overrides != nil {
+			instanceTypes.Insert(aws.StringValue(err.LaunchTemplateAndOverrides.Overrides.InstanceType))
+		}
+	}
+	if instanceTypes.Len() == 0 {
+		return
+	}
+	if backoff := awssettings.FromContext(ctx).VCPULimitBackoff.Duration; backoff > 0 {
+		p.Lock()
+		for _, it := range nodeRequest.InstanceTypeOptions {
+			if !instanceTypes.Has(it.Name()) {
+				continue
+			}
+			key := vcpuLimitExceededKey(it.Name(), capacityType)
+			vcpus := aws.Int64Value(it.(*InstanceType).VCpuInfo.DefaultVCpus)
+			if backedOff, ok := p.cache.Get(key); ok && backedOff.(int64) < vcpus {
+				continue
+			}
+			p.cache.Set(key, vcpus, backoff)
+		}
+		p.Unlock()
+	}
+	logging.FromContext(ctx).Warnf("Launching %v would exceed the %s vCPU limit of the account, request a service quota increase", instanceTypes.List(), capacityType)
+	p.recorder.Publish(VCPULimitExceeded(nodeRequest.Template.ProvisionerName, capacityType, instanceTypes.List()))
+}
+
+// headroom returns the number of vCPUs that can still be launched for each quota
+func (p *QuotaProvider) headroom(ctx context.Context) (map[quotaKey]int64, error) {
+	quotas, err := p.getQuotas(ctx)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := p.getUsage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	headroom := map[quotaKey]int64{}
+	for key, quota := range quotas {
+		headroom[key] = lo.Max([]int64{quota - usage[key], 0})
+	}
+	return headroom, nil
+}
+
+func (p *QuotaProvider) getQuotas(ctx context.Context) (map[quotaKey]int64, error) {
+	p.Lock()
+	defer p.Unlock()
+	if quotas, ok := p.cache.Get(quotasCacheKey); ok {
+		return quotas.(map[quotaKey]int64), nil
+	}
+	keys := map[string]quotaKey{}
+	for group, codes := range quotaCodes {
+		for capacityType, code := range codes {
+			keys[code] = quotaKey{group: group, capacityType: capacityType}
+		}
+	}
+	quotas := map[quotaKey]int64{}
+	if err := p.servicequotasapi.ListServiceQuotasPagesWithContext(ctx, &servicequotas.ListServiceQuotasInput{
+		ServiceCode: aws.String("ec2"),
+	}, func(output *servicequotas.ListServiceQuotasOutput, _ bool) bool {
+		for _, quota := range output.Quotas {
+			if key, ok := keys[aws.StringValue(quota.QuotaCode)]; ok {
+				quotas[key] = int64(aws.Float64Value(quota.Value))
+			}
+		}
+		return true
+	}); err != nil {
+		return nil, fmt.Errorf("listing service quotas, %w", err)
+	}
+	if p.cm.HasChanged(quotasCacheKey, quotas) {
+		logging.FromContext(ctx).Debugf("Discovered %d vCPU quotas", len(quotas))
+	}
+	p.cache.SetDefault(quotasCacheKey, quotas)
+	return quotas, nil
+}
+
+// getUsage sums the vCPUs of the pending and running instances in the account for each quota
+func (p *QuotaProvider) getUsage(ctx context.Context) (map[quotaKey]int64, error) {
+	p.Lock()
+	defer p.Unlock()
+	if usage, ok := p.cache.Get(quotaUsageCacheKey); ok {
+		return usage.(map[quotaKey]int64), nil
+	}
+	usage := map[quotaKey]int64{}
+	if err := p.ec2api.DescribeInstancesPagesWithContext(ctx, &ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{{
+			Name:   aws.String("instance-state-name"),
+			Values: aws.StringSlice([]string{ec2.InstanceStateNamePending, ec2.InstanceStateNameRunning}),
+		}},
+	}, func(output *ec2.DescribeInstancesOutput, _ bool) bool {
+		for _, reservation := range output.Reservations {
+			for _, instance := range reservation.Instances {
+				if instance.CpuOptions == nil {
+					continue
+				}
+				key := quotaKey{group: quotaGroup(aws.StringValue(instance.InstanceType)), capacityType: v1alpha5.CapacityTypeOnDemand}
+				if aws.StringValue(instance.InstanceLifecycle) == ec2.InstanceLifecycleTypeSpot {
+					key.capacityType = v1alpha5.CapacityTypeSpot
+				}
+				usage[key] += aws.Int64Value(instance.CpuOptions.CoreCount) * aws.Int64Value(instance.CpuOptions.ThreadsPerCore)
+			}
+		}
+		return true
+	}); err != nil {
+		return nil, fmt.Errorf("describing instances, %w", err)
+	}
+	p.cache.Set(quotaUsageCacheKey, usage, QuotaUsageCacheTTL)
+	return usage, nil
+}
+
+// vcpuLimitExceededKey is shared by instance types with the same vCPU quota, and holds the smallest number of vCPUs
+// that failed to launch. Families whose quota isn't known back off on their own.
+func vcpuLimitExceededKey(instanceType string, capacityType string) string {
+	group := quotaGroup(instanceType)
+	if group == "" {
+		group = strings.Split(instanceType, ".")[0]
+	}
+	return fmt.Sprintf("%s/%s/%s", vcpuLimitExceededKeyPrefix, group, capacityType)
+}
+
+// quotaGroup returns the group of instance families that share a vCPU quota with the instance type
+func quotaGroup(instanceType string) string {
+	family := strings.Split(instanceType, ".")[0]
+	switch {
+	case strings.HasPrefix(family, "inf"):
+		return "inf"
+	case strings.HasPrefix(family, "vt"):
+		return "g"
+	case strings.HasPrefix(family, "dl"), strings.HasPrefix(family, "trn"), strings.HasPrefix(family, "hpc"), strings.HasPrefix(family, "u-"):
+		// these families have their own quotas, which aren't checked
+		return ""
+	case family == "":
+		return ""
+	case strings.ContainsAny(family[:1], "fgpx"):
+		return family[:1]
+	case strings.ContainsAny(family[:1], "acdhimrtz"):
+		return "standard"
+	}
+	return ""
+}
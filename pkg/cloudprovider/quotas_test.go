@@ -0,0 +1,264 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	corev1alpha5 "github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/cloudprovider"
+	"github.com/aws/karpenter-core/pkg/controllers/provisioning"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+	awssettings "github.com/aws/karpenter/pkg/apis/config/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/fake"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var _ = Describe("Quotas", func() {
+	var pod *v1.Pod
+	runningInstance := func(instanceType string, coreCount int64) *ec2.Instance {
+		return &ec2.Instance{
+			InstanceId:   aws.String(coretest.RandomName()),
+			InstanceType: aws.String(instanceType),
+			State:        &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)},
+			CpuOptions:   &ec2.CpuOptions{CoreCount: aws.Int64(coreCount), ThreadsPerCore: aws.Int64(2)},
+		}
+	}
+	launchedVCPUs := func() []int64 {
+		instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+		Expect(err).ToNot(HaveOccurred())
+		vcpus := lo.SliceToMap(instanceTypes, func(it cloudprovider.InstanceType) (string, int64) {
+			return it.Name(), aws.Int64Value(it.(*InstanceType).VCpuInfo.DefaultVCpus)
+		})
+		Expect(fakeEC2API.CalledWithCreateFleetInput.Len()).To(Equal(1))
+		var result []int64
+		for _, ltc := range fakeEC2API.CalledWithCreateFleetInput.Pop().LaunchTemplateConfigs {
+			for _, override := range ltc.Overrides {
+				result = append(result, vcpus[aws.StringValue(override.InstanceType)])
+			}
+		}
+		return result
+	}
+	BeforeEach(func() {
+		settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{
+			EnableQuotaCheck: lo.ToPtr(true),
+		})
+		ctx = settingsStore.InjectSettings(ctx)
+		prov = provisioning.NewProvisioner(ctx, env.Client, corev1.NewForConfigOrDie(env.Config), recorder, cloudProvider, cluster, coretest.SettingsStore{})
+		controller = provisioning.NewController(env.Client, prov, recorder)
+		recorder.Reset()
+
+		fakeServiceQuotasAPI.ListServiceQuotasBehavior.Output.Set(&servicequotas.ListServiceQuotasOutput{
+			Quotas: []*servicequotas.ServiceQuota{
+				fake.NewServiceQuota("L-1216C47A", 12), // Running On-Demand Standard instances
+				fake.NewServiceQuota("L-34B43A08", 64), // All Standard Spot Instance Requests
+			},
+		})
+		pod = coretest.UnschedulablePod(coretest.PodOptions{
+			ResourceRequirements: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")}},
+		})
+	})
+	It("should skip instance types that exceed the remaining vCPU quota", func() {
+		instance := runningInstance("m5.2xlarge", 4)
+		fakeEC2API.Instances.Store(aws.StringValue(instance.InstanceId), instance)
+		ExpectApplied(ctx, env.Client, provisioner)
+		ExpectProvisioned(ctx, env.Client, recorder, controller, prov, pod)
+		ExpectScheduled(ctx, env.Client, pod)
+		for _, vcpus := range launchedVCPUs() {
+			Expect(vcpus).To(BeNumerically("<=", 4))
+		}
+		Expect(recorder.Calls("InsufficientVCPUQuota")).To(Equal(1))
+	})
+	It("should not launch when every instance type exceeds the remaining vCPU quota", func() {
+		instance := runningInstance("m5.3xlarge", 6)
+		fakeEC2API.Instances.Store(aws.StringValue(instance.InstanceId), instance)
+		pod.Spec.NodeSelector = map[string]string{v1alpha1.LabelInstanceCategory: "m"}
+		ExpectApplied(ctx, env.Client, provisioner)
+		ExpectProvisioned(ctx, env.Client, recorder, controller, prov, pod)
+		ExpectNotScheduled(ctx, env.Client, pod)
+		Expect(fakeEC2API.CalledWithCreateFleetInput.Len()).To(Equal(0))
+		Expect(recorder.Calls("InsufficientVCPUQuota")).To(Equal(1))
+	})
+	It("should only count usage against the quota of the same capacity type", func() {
+		instance := runningInstance("m5.3xlarge", 6)
+		instance.InstanceLifecycle = aws.String(ec2.InstanceLifecycleTypeSpot)
+		fakeEC2API.Instances.Store(aws.StringValue(instance.InstanceId), instance)
+		ExpectApplied(ctx, env.Client, provisioner)
+		ExpectProvisioned(ctx, env.Client, recorder, controller, prov, pod)
+		ExpectScheduled(ctx, env.Client, pod)
+		for _, vcpus := range launchedVCPUs() {
+			Expect(vcpus).To(BeNumerically("<=", 12))
+		}
+	})
+	It("should not filter instance types whose quota isn't known", func() {
+		fakeServiceQuotasAPI.ListServiceQuotasBehavior.Output.Set(&servicequotas.ListServiceQuotasOutput{})
+		ExpectApplied(ctx, env.Client, provisioner)
+		ExpectProvisioned(ctx, env.Client, recorder, controller, prov, pod)
+		ExpectScheduled(ctx, env.Client, pod)
+		Expect(recorder.Calls("InsufficientVCPUQuota")).To(Equal(0))
+	})
+	It("should launch when quotas can't be retrieved", func() {
+		fakeServiceQuotasAPI.ListServiceQuotasBehavior.Error.Set(errors.New("access denied"))
+		ExpectApplied(ctx, env.Client, provisioner)
+		ExpectProvisioned(ctx, env.Client, recorder, controller, prov, pod)
+		ExpectScheduled(ctx, env.Client, pod)
+	})
+	It("should cache quotas", func() {
+		ExpectApplied(ctx, env.Client, provisioner)
+		ExpectProvisioned(ctx, env.Client, recorder, controller, prov, pod)
+		ExpectScheduled(ctx, env.Client, pod)
+		secondPod := coretest.UnschedulablePod()
+		ExpectProvisioned(ctx, env.Client, recorder, controller, prov, secondPod)
+		ExpectScheduled(ctx, env.Client, secondPod)
+		Expect(fakeServiceQuotasAPI.ListServiceQuotasBehavior.Calls()).To(Equal(1))
+	})
+	It("should not check quotas unless enabled", func() {
+		settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{
+			EnableQuotaCheck: lo.ToPtr(false),
+		})
+		ctx = settingsStore.InjectSettings(ctx)
+		prov = provisioning.NewProvisioner(ctx, env.Client, corev1.NewForConfigOrDie(env.Config), recorder, cloudProvider, cluster, coretest.SettingsStore{})
+		controller = provisioning.NewController(env.Client, prov, recorder)
+		ExpectApplied(ctx, env.Client, provisioner)
+		ExpectProvisioned(ctx, env.Client, recorder, controller, prov, pod)
+		ExpectScheduled(ctx, env.Client, pod)
+		Expect(fakeServiceQuotasAPI.ListServiceQuotasBehavior.Calls()).To(Equal(0))
+	})
+})
+
+var _ = Describe("VcpuLimitExceeded", func() {
+	vcpuLimitExceeded := func(instanceType string, zone string) *ec2.CreateFleetOutput {
+		return &ec2.CreateFleetOutput{Errors: []*ec2.CreateFleetError{{
+			ErrorCode:    aws.String("VcpuLimitExceeded"),
+			ErrorMessage: aws.String("You have requested more vCPU capacity than your current vCPU limit allows"),
+			LaunchTemplateAndOverrides: &ec2.LaunchTemplateAndOverridesResponse{
+				LaunchTemplateSpecification: &ec2.FleetLaunchTemplateSpecification{LaunchTemplateName: aws.String("my-template")},
+				Overrides: &ec2.FleetLaunchTemplateOverrides{
+					InstanceType:     aws.String(instanceType),
+					AvailabilityZone: aws.String(zone),
+				},
+			},
+		}}}
+	}
+	BeforeEach(func() {
+		recorder.Reset()
+	})
+	It("should not mark offerings as unavailable", func() {
+		fakeEC2API.CreateFleetOutput.Set(vcpuLimitExceeded("m5.large", "test-zone-1a"))
+		ExpectApplied(ctx, env.Client, provisioner)
+		pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+		ExpectNotScheduled(ctx, env.Client, pod)
+		Expect(unavailableOfferingsCache.IsUnavailable("m5.large", "test-zone-1a", corev1alpha5.CapacityTypeOnDemand)).To(BeFalse())
+		Expect(recorder.Calls("VCPULimitExceeded")).To(Equal(1))
+	})
+	It("should back off instance types that share the vCPU quota and are at least as large", func() {
+		fakeEC2API.CreateFleetOutput.Set(vcpuLimitExceeded("m5.large", "test-zone-1a"))
+		ExpectApplied(ctx, env.Client, provisioner)
+		pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+		ExpectNotScheduled(ctx, env.Client, pod)
+
+		fakeEC2API.CreateFleetOutput.Reset()
+		fakeEC2API.CalledWithCreateFleetInput.Reset()
+		pod = ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+		node := ExpectScheduled(ctx, env.Client, pod)
+		Expect(quotaGroup(node.Labels[v1.LabelInstanceTypeStable])).ToNot(Equal("standard"))
+		for _, ltc := range fakeEC2API.CalledWithCreateFleetInput.Pop().LaunchTemplateConfigs {
+			for _, override := range ltc.Overrides {
+				Expect(quotaGroup(aws.StringValue(override.InstanceType))).ToNot(Equal("standard"))
+			}
+		}
+	})
+	It("should not back off smaller instance types that share the vCPU quota", func() {
+		fakeEC2API.CreateFleetOutput.Set(vcpuLimitExceeded("m5.xlarge", "test-zone-1a"))
+		ExpectApplied(ctx, env.Client, provisioner)
+		pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod(coretest.PodOptions{
+			NodeSelector: map[string]string{v1.LabelInstanceTypeStable: "m5.xlarge"},
+		}))[0]
+		ExpectNotScheduled(ctx, env.Client, pod)
+
+		fakeEC2API.CreateFleetOutput.Reset()
+		pod = ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod(coretest.PodOptions{
+			NodeSelector: map[string]string{v1.LabelInstanceTypeStable: "m5.xlarge"},
+		}))[0]
+		ExpectNotScheduled(ctx, env.Client, pod)
+		pod = ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod(coretest.PodOptions{
+			NodeSelector: map[string]string{v1.LabelInstanceTypeStable: "m5.large"},
+		}))[0]
+		ExpectScheduled(ctx, env.Client, pod)
+	})
+	It("should not back off the vCPU quota of another capacity type", func() {
+		fakeEC2API.CreateFleetOutput.Set(vcpuLimitExceeded("m5.large", "test-zone-1a"))
+		ExpectApplied(ctx, env.Client, provisioner)
+		pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+		ExpectNotScheduled(ctx, env.Client, pod)
+
+		fakeEC2API.CreateFleetOutput.Reset()
+		provisioner.Spec.Requirements = []v1.NodeSelectorRequirement{{Key: corev1alpha5.LabelCapacityType, Operator: v1.NodeSelectorOpIn, Values: []string{corev1alpha5.CapacityTypeSpot}}}
+		ExpectApplied(ctx, env.Client, provisioner)
+		pod = ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod(coretest.PodOptions{
+			NodeSelector: map[string]string{v1.LabelInstanceTypeStable: "m5.large"},
+		}))[0]
+		ExpectScheduled(ctx, env.Client, pod)
+	})
+	It("should retry immediately when vcpuLimitBackoff is disabled", func() {
+		settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{
+			VCPULimitBackoff: lo.ToPtr(time.Duration(0)),
+		})
+		ctx = settingsStore.InjectSettings(ctx)
+		prov = provisioning.NewProvisioner(ctx, env.Client, corev1.NewForConfigOrDie(env.Config), recorder, cloudProvider, cluster, coretest.SettingsStore{})
+		controller = provisioning.NewController(env.Client, prov, recorder)
+		fakeEC2API.CreateFleetOutput.Set(vcpuLimitExceeded("m5.large", "test-zone-1a"))
+		ExpectApplied(ctx, env.Client, provisioner)
+		pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+		ExpectNotScheduled(ctx, env.Client, pod)
+		Expect(recorder.Calls("VCPULimitExceeded")).To(Equal(1))
+
+		fakeEC2API.CreateFleetOutput.Reset()
+		pod = ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod(coretest.PodOptions{
+			NodeSelector: map[string]string{v1.LabelInstanceTypeStable: "m5.large"},
+		}))[0]
+		ExpectScheduled(ctx, env.Client, pod)
+	})
+})
+
+var _ = Describe("Quota Groups", func() {
+	DescribeTable("should group instance families by their vCPU quota",
+		func(instanceType string, group string) {
+			Expect(quotaGroup(instanceType)).To(Equal(group))
+		},
+		Entry("general purpose", "m5.large", "standard"),
+		Entry("burstable", "t3.micro", "standard"),
+		Entry("graphics", "g4dn.xlarge", "g"),
+		Entry("video transcoding", "vt1.3xlarge", "g"),
+		Entry("inferentia", "inf1.xlarge", "inf"),
+		Entry("accelerated", "p3.2xlarge", "p"),
+		Entry("memory optimized", "x1e.xlarge", "x"),
+		Entry("fpga", "f1.2xlarge", "f"),
+		Entry("unchecked families", "dl1.24xlarge", ""),
+	)
+})
@@ -29,13 +29,14 @@ import (
 	"github.com/aws/karpenter-core/pkg/utils/functional"
 	"github.com/aws/karpenter-core/pkg/utils/pretty"
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	awscache "github.com/aws/karpenter/pkg/cache"
 	awscontext "github.com/aws/karpenter/pkg/context"
 )
 
 type SecurityGroupProvider struct {
 	sync.Mutex
 	ec2api ec2iface.EC2API
-	cache  *cache.Cache
+	cache  *awscache.Metered
 	cm     *pretty.ChangeMonitor
 }
 
@@ -43,7 +44,7 @@ func NewSecurityGroupProvider(ec2api ec2iface.EC2API) *SecurityGroupProvider {
 	return &SecurityGroupProvider{
 		ec2api: ec2api,
 		cm:     pretty.NewChangeMonitor(),
-		cache:  cache.New(awscontext.CacheTTL, awscontext.CacheCleanupInterval),
+		cache:  awscache.NewMetered(awscache.SecurityGroupsCacheName, cache.New(awscontext.CacheTTL, awscontext.CacheCleanupInterval)),
 	}
 }
 
@@ -67,6 +68,13 @@ func (p *SecurityGroupProvider) Get(ctx context.Context, provider *v1alpha1.AWS)
 	return securityGroupIds, nil
 }
 
+// List returns the security groups that the provider selects
+func (p *SecurityGroupProvider) List(ctx context.Context, provider *v1alpha1.AWS) ([]*ec2.SecurityGroup, error) {
+	p.Lock()
+	defer p.Unlock()
+	return p.getSecurityGroups(ctx, p.getFilters(provider))
+}
+
 func (p *SecurityGroupProvider) getFilters(provider *v1alpha1.AWS) []*ec2.Filter {
 	filters := []*ec2.Filter{}
 	for key, value := range provider.SecurityGroupSelector {
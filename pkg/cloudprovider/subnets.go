@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"sort"
 	"sync"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -29,14 +30,16 @@ import (
 
 	"github.com/aws/karpenter-core/pkg/utils/functional"
 	"github.com/aws/karpenter-core/pkg/utils/pretty"
+	awssettings "github.com/aws/karpenter/pkg/apis/config/settings"
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	awscache "github.com/aws/karpenter/pkg/cache"
 	awscontext "github.com/aws/karpenter/pkg/context"
 )
 
 type SubnetProvider struct {
 	sync.Mutex
 	ec2api ec2iface.EC2API
-	cache  *cache.Cache
+	cache  *awscache.Metered
 	cm     *pretty.ChangeMonitor
 }
 
@@ -44,7 +47,7 @@ func NewSubnetProvider(ec2api ec2iface.EC2API) *SubnetProvider {
 	return &SubnetProvider{
 		ec2api: ec2api,
 		cm:     pretty.NewChangeMonitor(),
-		cache:  cache.New(awscontext.CacheTTL, awscontext.CacheCleanupInterval),
+		cache:  awscache.NewMetered(awscache.SubnetsCacheName, cache.New(awscontext.CacheTTL, awscontext.CacheCleanupInterval)),
 	}
 }
 
@@ -59,19 +62,34 @@ func (p *SubnetProvider) Get(ctx context.Context, provider *v1alpha1.AWS) ([]*ec
 	if subnets, ok := p.cache.Get(fmt.Sprint(hash)); ok {
 		return subnets.([]*ec2.Subnet), nil
 	}
-	output, err := p.ec2api.DescribeSubnetsWithContext(ctx, &ec2.DescribeSubnetsInput{Filters: filters})
-	if err != nil {
+	settings := awssettings.FromContext(ctx)
+	// Only the subnets that are kept are held on to while paging, so that selectors that match thousands of subnets
+	// don't bloat memory or the overrides of CreateFleet requests
+	var subnets []*ec2.Subnet
+	matched := 0
+	if err := p.ec2api.DescribeSubnetsPagesWithContext(ctx, &ec2.DescribeSubnetsInput{
+		Filters:    filters,
+		MaxResults: aws.Int64(int64(settings.DescribeSubnetsPageSize)),
+	}, func(output *ec2.DescribeSubnetsOutput, _ bool) bool {
+		matched += len(output.Subnets)
+		subnets = limitSubnets(append(subnets, output.Subnets...), settings.MaxSubnetsPerSelector)
+		return true
+	}); err != nil {
 		return nil, fmt.Errorf("describing subnets %s, %w", pretty.Concise(filters), err)
 	}
-	if len(output.Subnets) == 0 {
+	if len(subnets) == 0 {
 		return nil, fmt.Errorf("no subnets matched selector %v", provider.SubnetSelector)
 	}
-	p.cache.SetDefault(fmt.Sprint(hash), output.Subnets)
-	subnetLog := prettySubnets(output.Subnets)
+	if matched > len(subnets) && p.cm.HasChanged(fmt.Sprintf("subnets-limited-%d", hash), matched) {
+		logging.FromContext(ctx).Warnf("Subnet selector %v matched %d subnets, discovering the %d with the most available ip addresses, see aws.maxSubnetsPerSelector",
+			provider.SubnetSelector, matched, len(subnets))
+	}
+	p.cache.SetDefault(fmt.Sprint(hash), subnets)
+	subnetLog := prettySubnets(subnets)
 	if p.cm.HasChanged("subnets", subnetLog) {
 		logging.FromContext(ctx).Debugf("Discovered subnets: %s", subnetLog)
 	}
-	return output.Subnets, nil
+	return subnets, nil
 }
 
 func (p *SubnetProvider) LivenessProbe(req *http.Request) error {
@@ -91,6 +109,13 @@ func getFilters(provider *v1alpha1.AWS) []*ec2.Filter {
 				Name:   aws.String("subnet-id"),
 				Values: aws.StringSlice(filterValues),
 			})
+		} else if key == "aws-owners" {
+			// Subnets that are shared with the account through AWS RAM are owned by another account, whose tags on
+			// them aren't visible to the account, so they're selected by their owner instead
+			filters = append(filters, &ec2.Filter{
+				Name:   aws.String("owner-id"),
+				Values: aws.StringSlice(functional.SplitCommaSeparatedString(value)),
+			})
 		} else if value == "*" {
 			filters = append(filters, &ec2.Filter{
 				Name:   aws.String("tag-key"),
@@ -103,9 +128,29 @@ func getFilters(provider *v1alpha1.AWS) []*ec2.Filter {
 			})
 		}
 	}
+	// The filters are the cache key of the discovered subnets, which includes the subnet IDs and ID prefixes, so they're
+	// ordered independently of the iteration order of the selector
+	sort.Slice(filters, func(i, j int) bool {
+		return aws.StringValue(filters[i].Name) < aws.StringValue(filters[j].Name)
+	})
 	return filters
 }
 
+// limitSubnets keeps the limit subnets with the most available ip addresses. Ties are broken by subnet ID, so that the
+// same subnets are kept regardless of the order in which EC2 returns them.
+func limitSubnets(subnets []*ec2.Subnet, limit int) []*ec2.Subnet {
+	if len(subnets) <= limit {
+		return subnets
+	}
+	sort.SliceStable(subnets, func(i, j int) bool {
+		if iIPs, jIPs := aws.Int64Value(subnets[i].AvailableIpAddressCount), aws.Int64Value(subnets[j].AvailableIpAddressCount); iIPs != jIPs {
+			return iIPs > jIPs
+		}
+		return aws.StringValue(subnets[i].SubnetId) < aws.StringValue(subnets[j].SubnetId)
+	})
+	return subnets[:limit]
+}
+
 func prettySubnets(subnets []*ec2.Subnet) []string {
 	names := []string{}
 	for _, subnet := range subnets {
@@ -15,15 +15,21 @@ limitations under the License.
 package cloudprovider
 
 import (
+	"fmt"
+
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/samber/lo"
 	v1 "k8s.io/api/core/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 
 	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/controllers/provisioning"
 	coretest "github.com/aws/karpenter-core/pkg/test"
 	. "github.com/aws/karpenter-core/pkg/test/expectations"
+	awssettings "github.com/aws/karpenter/pkg/apis/config/settings"
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
 	"github.com/aws/karpenter/pkg/fake"
 	"github.com/aws/karpenter/pkg/test"
@@ -67,6 +73,59 @@ var _ = Describe("Subnets", func() {
 		createFleetInput := fakeEC2API.CalledWithCreateFleetInput.Pop()
 		Expect(fake.SubnetsFromFleetRequest(createFleetInput)).To(ConsistOf("test-subnet-2"))
 	})
+	Context("Spread Within Zone", func() {
+		var subnets []*ec2.Subnet
+		BeforeEach(func() {
+			settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{
+				SpreadSubnetsWithinZone: lo.ToPtr(true),
+			})
+			ctx = settingsStore.InjectSettings(ctx)
+			subnets = []*ec2.Subnet{
+				{SubnetId: aws.String("test-subnet-1"), AvailabilityZone: aws.String("test-zone-1a"), AvailableIpAddressCount: aws.Int64(100),
+					Tags: []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String("test-subnet-1")}}},
+				{SubnetId: aws.String("test-subnet-2"), AvailabilityZone: aws.String("test-zone-1a"), AvailableIpAddressCount: aws.Int64(300),
+					Tags: []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String("test-subnet-2")}}},
+				{SubnetId: aws.String("test-subnet-3"), AvailabilityZone: aws.String("test-zone-1a"), AvailableIpAddressCount: aws.Int64(0),
+					Tags: []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String("test-subnet-3")}}},
+				{SubnetId: aws.String("test-subnet-4"), AvailabilityZone: aws.String("test-zone-1b"), AvailableIpAddressCount: aws.Int64(50),
+					Tags: []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String("test-subnet-4")}}},
+			}
+		})
+		It("should spread launches across the subnets of a zone weighted by available IP addresses", func() {
+			zonalSubnets := selectZonalSubnets(ctx, subnets)
+			Expect(zonalSubnets).To(HaveLen(2))
+			Expect(aws.StringValue(zonalSubnets["test-zone-1a"].SubnetId)).To(Or(Equal("test-subnet-1"), Equal("test-subnet-2")))
+			Expect(aws.StringValue(zonalSubnets["test-zone-1b"].SubnetId)).To(Equal("test-subnet-4"))
+
+			zonalSubnets1a := lo.Filter(subnets, func(s *ec2.Subnet, _ int) bool { return aws.StringValue(s.AvailabilityZone) == "test-zone-1a" })
+			for n := int64(0); n < 100; n++ {
+				Expect(aws.StringValue(weightedSubnet(zonalSubnets1a, n).SubnetId)).To(Equal("test-subnet-1"))
+			}
+			for n := int64(100); n < 400; n++ {
+				Expect(aws.StringValue(weightedSubnet(zonalSubnets1a, n).SubnetId)).To(Equal("test-subnet-2"))
+			}
+			Expect(weightedSubnet(zonalSubnets1a, 400)).To(BeNil())
+		})
+		It("should fall back to the subnet with the most available IP addresses when every subnet is full", func() {
+			for _, subnet := range subnets {
+				subnet.AvailableIpAddressCount = aws.Int64(0)
+			}
+			zonalSubnets := selectZonalSubnets(ctx, subnets)
+			Expect(zonalSubnets).To(HaveLen(2))
+			Expect(aws.StringValue(zonalSubnets["test-zone-1a"].SubnetId)).To(Equal("test-subnet-3"))
+			Expect(aws.StringValue(zonalSubnets["test-zone-1b"].SubnetId)).To(Equal("test-subnet-4"))
+		})
+		It("should launch into a single subnet per zone", func() {
+			prov = provisioning.NewProvisioner(ctx, env.Client, corev1.NewForConfigOrDie(env.Config), recorder, cloudProvider, cluster, coretest.SettingsStore{})
+			controller = provisioning.NewController(env.Client, prov, recorder)
+			fakeEC2API.DescribeSubnetsOutput.Set(&ec2.DescribeSubnetsOutput{Subnets: subnets})
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod(coretest.PodOptions{NodeSelector: map[string]string{v1.LabelTopologyZone: "test-zone-1a"}}))[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			createFleetInput := fakeEC2API.CalledWithCreateFleetInput.Pop()
+			Expect(fake.SubnetsFromFleetRequest(createFleetInput)).To(Or(ConsistOf("test-subnet-1"), ConsistOf("test-subnet-2")))
+		})
+	})
 	It("should launch instances into subnets that are excluded by another provisioner", func() {
 		fakeEC2API.DescribeSubnetsOutput.Set(&ec2.DescribeSubnetsOutput{Subnets: []*ec2.Subnet{
 			{SubnetId: aws.String("test-subnet-1"), AvailabilityZone: aws.String("test-zone-1a"), AvailableIpAddressCount: aws.Int64(10),
@@ -121,6 +180,137 @@ var _ = Describe("Subnets", func() {
 			"subnet-test2",
 		))
 	})
+	Context("Subnet ID Prefixes", func() {
+		BeforeEach(func() {
+			fakeEC2API.DescribeSubnetsOutput.Set(&ec2.DescribeSubnetsOutput{Subnets: []*ec2.Subnet{
+				{SubnetId: aws.String("subnet-0abc1"), AvailabilityZone: aws.String("test-zone-1a"), AvailableIpAddressCount: aws.Int64(100),
+					Tags: []*ec2.Tag{{Key: aws.String("team"), Value: aws.String("ml")}}},
+				{SubnetId: aws.String("subnet-0abc2"), AvailabilityZone: aws.String("test-zone-1b"), AvailableIpAddressCount: aws.Int64(100)},
+				{SubnetId: aws.String("subnet-0def1"), AvailabilityZone: aws.String("test-zone-1c"), AvailableIpAddressCount: aws.Int64(100),
+					Tags: []*ec2.Tag{{Key: aws.String("team"), Value: aws.String("ml")}}},
+			}})
+		})
+		It("should discover subnets by ID prefix", func() {
+			provider.SubnetSelector = map[string]string{"aws-ids": "subnet-0abc*"}
+			subnets, err := cloudProvider.instanceProvider.subnetProvider.Get(ctx, provider)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lo.Map(subnets, func(subnet *ec2.Subnet, _ int) string { return aws.StringValue(subnet.SubnetId) })).To(ConsistOf("subnet-0abc1", "subnet-0abc2"))
+		})
+		It("should discover subnets by ID prefixes alongside IDs", func() {
+			provider.SubnetSelector = map[string]string{"aws-ids": "subnet-0abc*,subnet-0def1"}
+			subnets, err := cloudProvider.instanceProvider.subnetProvider.Get(ctx, provider)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lo.Map(subnets, func(subnet *ec2.Subnet, _ int) string { return aws.StringValue(subnet.SubnetId) })).To(ConsistOf("subnet-0abc1", "subnet-0abc2", "subnet-0def1"))
+		})
+		It("should discover subnets by ID prefix intersected with tags", func() {
+			provider.SubnetSelector = map[string]string{"aws-ids": "subnet-0abc*", "team": "ml"}
+			subnets, err := cloudProvider.instanceProvider.subnetProvider.Get(ctx, provider)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lo.Map(subnets, func(subnet *ec2.Subnet, _ int) string { return aws.StringValue(subnet.SubnetId) })).To(ConsistOf("subnet-0abc1"))
+		})
+		It("should not reuse the subnets discovered for another ID prefix", func() {
+			provider.SubnetSelector = map[string]string{"aws-ids": "subnet-0abc*"}
+			subnets, err := cloudProvider.instanceProvider.subnetProvider.Get(ctx, provider)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(subnets).To(HaveLen(2))
+
+			provider.SubnetSelector = map[string]string{"aws-ids": "subnet-0def*"}
+			subnets, err = cloudProvider.instanceProvider.subnetProvider.Get(ctx, provider)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lo.Map(subnets, func(subnet *ec2.Subnet, _ int) string { return aws.StringValue(subnet.SubnetId) })).To(ConsistOf("subnet-0def1"))
+		})
+		It("should fail when no subnet matches the ID prefix", func() {
+			provider.SubnetSelector = map[string]string{"aws-ids": "subnet-0xyz*"}
+			_, err := cloudProvider.instanceProvider.subnetProvider.Get(ctx, provider)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Context("Shared Subnets", func() {
+		BeforeEach(func() {
+			// Subnets shared through AWS RAM are owned by another account, and its tags on them aren't visible
+			fakeEC2API.DescribeSubnetsOutput.Set(&ec2.DescribeSubnetsOutput{Subnets: []*ec2.Subnet{
+				{SubnetId: aws.String("subnet-owned"), AvailabilityZone: aws.String("test-zone-1a"), AvailableIpAddressCount: aws.Int64(100),
+					OwnerId: aws.String("111122223333"), Tags: []*ec2.Tag{{Key: aws.String("foo"), Value: aws.String("bar")}}},
+				{SubnetId: aws.String("subnet-shared1"), AvailabilityZone: aws.String("test-zone-1a"), AvailableIpAddressCount: aws.Int64(100),
+					OwnerId: aws.String("444455556666")},
+				{SubnetId: aws.String("subnet-shared2"), AvailabilityZone: aws.String("test-zone-1b"), AvailableIpAddressCount: aws.Int64(100),
+					OwnerId: aws.String("444455556666")},
+			}})
+		})
+		It("should discover subnets shared by another account by owner", func() {
+			provider.SubnetSelector = map[string]string{"aws-owners": "444455556666"}
+			subnets, err := cloudProvider.instanceProvider.subnetProvider.Get(ctx, provider)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lo.Map(subnets, func(subnet *ec2.Subnet, _ int) string { return aws.StringValue(subnet.SubnetId) })).To(ConsistOf("subnet-shared1", "subnet-shared2"))
+		})
+		It("should discover shared subnets alongside owned subnets", func() {
+			provider.SubnetSelector = map[string]string{"aws-ids": "subnet-owned,subnet-shared2"}
+			subnets, err := cloudProvider.instanceProvider.subnetProvider.Get(ctx, provider)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lo.Map(subnets, func(subnet *ec2.Subnet, _ int) string { return aws.StringValue(subnet.SubnetId) })).To(ConsistOf("subnet-owned", "subnet-shared2"))
+		})
+		It("should launch instances into shared subnets", func() {
+			provider.SubnetSelector = map[string]string{"aws-owners": "444455556666"}
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			createFleetInput := fakeEC2API.CalledWithCreateFleetInput.Pop()
+			Expect(fake.SubnetsFromFleetRequest(createFleetInput)).To(ConsistOf("subnet-shared1", "subnet-shared2"))
+		})
+	})
+	Context("Limits", func() {
+		BeforeEach(func() {
+			// 30 subnets with distinct available ips, from subnet-0 with the fewest to subnet-29 with the most
+			fakeEC2API.DescribeSubnetsOutput.Set(&ec2.DescribeSubnetsOutput{Subnets: lo.Map(lo.Range(30), func(i int, _ int) *ec2.Subnet {
+				return &ec2.Subnet{SubnetId: aws.String(fmt.Sprintf("subnet-%d", i)), AvailabilityZone: aws.String("test-zone-1a"),
+					AvailableIpAddressCount: aws.Int64(int64(i * 10)), Tags: []*ec2.Tag{{Key: aws.String("foo"), Value: aws.String("bar")}}}
+			})})
+			provider.SubnetSelector = map[string]string{"foo": "bar"}
+		})
+		It("should discover every subnet across pages when the selector matches fewer subnets than the limit", func() {
+			settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{
+				DescribeSubnetsPageSize: lo.ToPtr(5),
+			})
+			ctx = settingsStore.InjectSettings(ctx)
+			subnets, err := cloudProvider.instanceProvider.subnetProvider.Get(ctx, provider)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(subnets).To(HaveLen(30))
+		})
+		It("should discover the subnets with the most available ips when the selector matches more subnets than the limit", func() {
+			settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{
+				DescribeSubnetsPageSize: lo.ToPtr(5),
+				MaxSubnetsPerSelector:   lo.ToPtr(3),
+			})
+			ctx = settingsStore.InjectSettings(ctx)
+			subnets, err := cloudProvider.instanceProvider.subnetProvider.Get(ctx, provider)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lo.Map(subnets, func(subnet *ec2.Subnet, _ int) string { return aws.StringValue(subnet.SubnetId) })).To(ConsistOf("subnet-29", "subnet-28", "subnet-27"))
+		})
+		It("should break ties between subnets by subnet id", func() {
+			fakeEC2API.DescribeSubnetsOutput.Set(&ec2.DescribeSubnetsOutput{Subnets: lo.Map([]string{"subnet-d", "subnet-b", "subnet-c", "subnet-a"}, func(id string, _ int) *ec2.Subnet {
+				return &ec2.Subnet{SubnetId: aws.String(id), AvailabilityZone: aws.String("test-zone-1a"), AvailableIpAddressCount: aws.Int64(100),
+					Tags: []*ec2.Tag{{Key: aws.String("foo"), Value: aws.String("bar")}}}
+			})})
+			settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{
+				MaxSubnetsPerSelector: lo.ToPtr(2),
+			})
+			ctx = settingsStore.InjectSettings(ctx)
+			subnets, err := cloudProvider.instanceProvider.subnetProvider.Get(ctx, provider)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lo.Map(subnets, func(subnet *ec2.Subnet, _ int) string { return aws.StringValue(subnet.SubnetId) })).To(ConsistOf("subnet-a", "subnet-b"))
+		})
+		It("should only launch instances into the discovered subnets", func() {
+			settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{
+				MaxSubnetsPerSelector: lo.ToPtr(2),
+			})
+			ctx = settingsStore.InjectSettings(ctx)
+			ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectScheduled(ctx, env.Client, pod)
+			createFleetInput := fakeEC2API.CalledWithCreateFleetInput.Pop()
+			Expect(fake.SubnetsFromFleetRequest(createFleetInput)).To(ConsistOf("subnet-29"))
+		})
+	})
 	It("should discover subnets by IDs intersected with tags", func() {
 		provider.SubnetSelector = map[string]string{"aws-ids": "subnet-test2", "foo": "bar"}
 		ExpectApplied(ctx, env.Client, test.Provisioner(coretest.ProvisionerOptions{Provider: provider}))
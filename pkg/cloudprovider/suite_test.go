@@ -23,7 +23,10 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/patrickmn/go-cache"
+	"github.com/samber/lo"
 	v1 "k8s.io/api/core/v1"
 	clock "k8s.io/utils/clock/testing"
 	"knative.dev/pkg/ptr"
@@ -34,11 +37,13 @@ import (
 
 	"github.com/aws/karpenter-core/pkg/apis/config/settings"
 	corev1alpha5 "github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/cloudprovider"
 	"github.com/aws/karpenter-core/pkg/controllers/provisioning"
 	"github.com/aws/karpenter-core/pkg/controllers/state"
 	"github.com/aws/karpenter-core/pkg/operator/injection"
 	"github.com/aws/karpenter-core/pkg/operator/options"
 	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	"github.com/aws/karpenter-core/pkg/scheduling"
 	coretest "github.com/aws/karpenter-core/pkg/test"
 	. "github.com/aws/karpenter-core/pkg/test/expectations"
 	"github.com/aws/karpenter-core/pkg/utils/pretty"
@@ -48,6 +53,7 @@ import (
 	awscache "github.com/aws/karpenter/pkg/cache"
 	"github.com/aws/karpenter/pkg/cloudprovider/amifamily"
 	awscontext "github.com/aws/karpenter/pkg/context"
+	awserrors "github.com/aws/karpenter/pkg/errors"
 	"github.com/aws/karpenter/pkg/test"
 
 	"github.com/aws/karpenter/pkg/fake"
@@ -62,12 +68,18 @@ var securityGroupCache *cache.Cache
 var subnetCache *cache.Cache
 var ssmCache *cache.Cache
 var ec2Cache *cache.Cache
+var ssmQueriesCache *cache.Cache
+var ssmQueries *awscache.SSMQueries
 var internalUnavailableOfferingsCache *cache.Cache
 var unavailableOfferingsCache *awscache.UnavailableOfferings
 var instanceTypeCache *cache.Cache
+var quotaCache *cache.Cache
 var instanceTypeProvider *InstanceTypeProvider
 var fakeEC2API *fake.EC2API
+var fakeSSMAPI *fake.SSMAPI
 var fakePricingAPI *fake.PricingAPI
+var fakeServiceQuotasAPI *fake.ServiceQuotasAPI
+var fakeEventBridgeAPI *fake.EventBridgeAPI
 var prov *provisioning.Provisioner
 var controller *provisioning.Controller
 var cloudProvider *CloudProvider
@@ -101,44 +113,58 @@ var _ = BeforeSuite(func() {
 	subnetCache = cache.New(awscontext.CacheTTL, awscontext.CacheCleanupInterval)
 	ssmCache = cache.New(awscontext.CacheTTL, awscontext.CacheCleanupInterval)
 	ec2Cache = cache.New(awscontext.CacheTTL, awscontext.CacheCleanupInterval)
+	ssmQueriesCache = cache.New(awscache.SSMQueriesTTL, awscontext.CacheCleanupInterval)
+	ssmQueries = awscache.NewSSMQueries(ssmQueriesCache)
 	instanceTypeCache = cache.New(InstanceTypesAndZonesCacheTTL, awscontext.CacheCleanupInterval)
+	quotaCache = cache.New(QuotasCacheTTL, awscontext.CacheCleanupInterval)
+	recorder = coretest.NewEventRecorder()
 	fakeEC2API = &fake.EC2API{}
+	fakeSSMAPI = &fake.SSMAPI{}
 	fakePricingAPI = &fake.PricingAPI{}
-	pricingProvider = NewPricingProvider(ctx, fakePricingAPI, fakeEC2API, "", false, make(chan struct{}))
+	fakeServiceQuotasAPI = &fake.ServiceQuotasAPI{}
+	fakeEventBridgeAPI = &fake.EventBridgeAPI{}
+	pricingProvider = NewPricingProvider(ctx, fakePricingAPI, fakeEC2API, "", false, 12*time.Hour, make(chan struct{}))
 	subnetProvider := &SubnetProvider{
 		ec2api: fakeEC2API,
-		cache:  subnetCache,
+		cache:  awscache.NewMetered(awscache.SubnetsCacheName, subnetCache),
 		cm:     pretty.NewChangeMonitor(),
 	}
 	instanceTypeProvider = &InstanceTypeProvider{
 		ec2api:               fakeEC2API,
 		subnetProvider:       subnetProvider,
-		cache:                instanceTypeCache,
+		cache:                awscache.NewMetered(awscache.InstanceTypesCacheName, instanceTypeCache),
 		pricingProvider:      pricingProvider,
 		unavailableOfferings: unavailableOfferingsCache,
 		cm:                   pretty.NewChangeMonitor(),
 	}
 	securityGroupProvider := &SecurityGroupProvider{
 		ec2api: fakeEC2API,
-		cache:  securityGroupCache,
+		cache:  awscache.NewMetered(awscache.SecurityGroupsCacheName, securityGroupCache),
 		cm:     pretty.NewChangeMonitor(),
 	}
 	cloudProvider = &CloudProvider{
 		instanceTypeProvider: instanceTypeProvider,
-		instanceProvider: NewInstanceProvider(ctx, fakeEC2API, instanceTypeProvider, subnetProvider, &LaunchTemplateProvider{
+		instanceProvider: NewInstanceProvider(ctx, fakeEC2API, fakeEventBridgeAPI, recorder, instanceTypeProvider, subnetProvider, &LaunchTemplateProvider{
 			ec2api:                fakeEC2API,
-			amiFamily:             amifamily.New(env.Client, fake.SSMAPI{}, fakeEC2API, ssmCache, ec2Cache),
+			amiFamily:             amifamily.New(env.Client, recorder, fakeSSMAPI, fakeEC2API, ssmCache, ec2Cache, ssmQueries),
 			kubernetesInterface:   env.KubernetesInterface,
 			securityGroupProvider: securityGroupProvider,
-			cache:                 launchTemplateCache,
+			cache:                 awscache.NewMetered(awscache.LaunchTemplatesCacheName, launchTemplateCache),
 			caBundle:              ptr.String("ca-bundle"),
 			cm:                    pretty.NewChangeMonitor(),
+		}, &QuotaProvider{
+			servicequotasapi: fakeServiceQuotasAPI,
+			ec2api:           fakeEC2API,
+			recorder:         recorder,
+			cache:            awscache.NewMetered(awscache.QuotasCacheName, quotaCache),
+			cm:               pretty.NewChangeMonitor(),
 		}),
 		kubeClient: env.Client,
+		recorder:   recorder,
+		cm:         pretty.NewChangeMonitor(),
 	}
 	fakeClock = clock.NewFakeClock(time.Now())
 	cluster = state.NewCluster(ctx, fakeClock, env.Client, cloudProvider)
-	recorder = coretest.NewEventRecorder()
 	prov = provisioning.NewProvisioner(ctx, env.Client, env.KubernetesInterface.CoreV1(), recorder, cloudProvider, cluster, coretest.SettingsStore{})
 	controller = provisioning.NewController(env.Client, prov, recorder)
 
@@ -173,14 +199,19 @@ var _ = BeforeEach(func() {
 	})
 
 	fakeEC2API.Reset()
+	fakeSSMAPI.Reset()
 	fakePricingAPI.Reset()
+	fakeServiceQuotasAPI.Reset()
+	fakeEventBridgeAPI.Reset()
 	launchTemplateCache.Flush()
 	securityGroupCache.Flush()
 	subnetCache.Flush()
 	internalUnavailableOfferingsCache.Flush()
 	ssmCache.Flush()
 	ec2Cache.Flush()
+	ssmQueriesCache.Flush()
 	instanceTypeCache.Flush()
+	quotaCache.Flush()
 	cloudProvider.instanceProvider.launchTemplateProvider.kubeDNSIP = net.ParseIP("10.0.100.10")
 })
 
@@ -235,6 +266,135 @@ var _ = Describe("Allocation", func() {
 			Expect(createFleetInput.Context).To(BeNil())
 		})
 	})
+	Context("CreateFleet Timeout", func() {
+		var nodeRequest *cloudprovider.NodeRequest
+		BeforeEach(func() {
+			settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{
+				CreateFleetTimeout: lo.ToPtr(500 * time.Millisecond),
+			})
+			ctx = settingsStore.InjectSettings(ctx)
+			provisioner.SetDefaults(ctx)
+			ExpectApplied(ctx, env.Client, provisioner)
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+			Expect(err).ToNot(HaveOccurred())
+			nodeRequest = &cloudprovider.NodeRequest{Template: scheduling.NewNodeTemplate(provisioner), InstanceTypeOptions: instanceTypes}
+		})
+		It("should fail the launch with a timeout error when CreateFleet hangs", func() {
+			fakeEC2API.CreateFleetDelay.Set(lo.ToPtr(time.Second))
+			_, err := cloudProvider.Create(ctx, nodeRequest)
+			Expect(err).To(HaveOccurred())
+			Expect(awserrors.IsCreateFleetTimeout(err)).To(BeTrue())
+			// the hung call is cancelled by the same timeout, so it doesn't launch an instance that no node tracks
+			Consistently(fakeEC2API.CalledWithCreateFleetInput.Len, time.Second).Should(BeZero())
+		})
+		It("should not hold up later launches behind a hung CreateFleet", func() {
+			fakeEC2API.CreateFleetDelay.Set(lo.ToPtr(time.Minute))
+			_, err := cloudProvider.Create(ctx, nodeRequest)
+			Expect(awserrors.IsCreateFleetTimeout(err)).To(BeTrue())
+			fakeEC2API.CreateFleetDelay.Reset()
+			node, err := cloudProvider.Create(ctx, nodeRequest)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(node).ToNot(BeNil())
+		})
+		It("should not mark any offerings as unavailable when CreateFleet times out", func() {
+			fakeEC2API.CreateFleetDelay.Set(lo.ToPtr(time.Second))
+			_, err := cloudProvider.Create(ctx, nodeRequest)
+			Expect(awserrors.IsCreateFleetTimeout(err)).To(BeTrue())
+			Expect(internalUnavailableOfferingsCache.ItemCount()).To(BeZero())
+		})
+		It("should launch when CreateFleet completes within the timeout", func() {
+			fakeEC2API.CreateFleetDelay.Set(lo.ToPtr(10 * time.Millisecond))
+			node, err := cloudProvider.Create(ctx, nodeRequest)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(node).ToNot(BeNil())
+		})
+		It("should not time out when the timeout is disabled", func() {
+			settingsStore[awssettings.ContextKey] = test.Settings(test.SettingOptions{
+				CreateFleetTimeout: lo.ToPtr(time.Duration(0)),
+			})
+			ctx = settingsStore.InjectSettings(ctx)
+			fakeEC2API.CreateFleetDelay.Set(lo.ToPtr(time.Second))
+			node, err := cloudProvider.Create(ctx, nodeRequest)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(node).ToNot(BeNil())
+		})
+	})
+	Context("CreateFleet Invalid Parameter Values", func() {
+		var nodeRequest *cloudprovider.NodeRequest
+		BeforeEach(func() {
+			provisioner.SetDefaults(ctx)
+			ExpectApplied(ctx, env.Client, provisioner)
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+			Expect(err).ToNot(HaveOccurred())
+			nodeRequest = &cloudprovider.NodeRequest{Template: scheduling.NewNodeTemplate(provisioner), InstanceTypeOptions: instanceTypes}
+		})
+		It("should retry when the launch template doesn't exist yet", func() {
+			fakeEC2API.NextError.Set(awserr.New("InvalidParameterValue", "The specified launch template, with template ID lt-0123456789abcdef0, does not exist.", nil))
+			node, err := cloudProvider.Create(ctx, nodeRequest)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(node).ToNot(BeNil())
+			Expect(fakeEC2API.CalledWithCreateFleetInput.Len()).To(Equal(1))
+		})
+		It("should retry fleet errors for a subnet that doesn't exist yet until the attempts run out", func() {
+			fakeEC2API.CreateFleetOutput.Set(&ec2.CreateFleetOutput{Errors: []*ec2.CreateFleetError{{
+				ErrorCode:    aws.String("InvalidParameterValue"),
+				ErrorMessage: aws.String("The subnet ID 'subnet-test1' does not exist"),
+			}}})
+			_, err := cloudProvider.Create(ctx, nodeRequest)
+			Expect(err).To(HaveOccurred())
+			Expect(awserrors.IsTransientInvalidParameterValue(err)).To(BeTrue())
+			Expect(fakeEC2API.CalledWithCreateFleetInput.Len()).To(Equal(3))
+		})
+		It("should fail fast on fleet errors for other invalid parameter values", func() {
+			fakeEC2API.CreateFleetOutput.Set(&ec2.CreateFleetOutput{Errors: []*ec2.CreateFleetError{{
+				ErrorCode:    aws.String("InvalidParameterValue"),
+				ErrorMessage: aws.String("User data is limited to 16384 bytes"),
+			}}})
+			_, err := cloudProvider.Create(ctx, nodeRequest)
+			Expect(err).To(HaveOccurred())
+			Expect(awserrors.IsTransientInvalidParameterValue(err)).To(BeFalse())
+			Expect(fakeEC2API.CalledWithCreateFleetInput.Len()).To(Equal(1))
+		})
+		It("should fail fast on other invalid parameter values", func() {
+			fakeEC2API.NextError.Set(awserr.New("InvalidParameterValue", "Value (foo) for parameter instanceType is invalid.", nil))
+			_, err := cloudProvider.Create(ctx, nodeRequest)
+			Expect(err).To(HaveOccurred())
+			Expect(awserrors.IsTransientInvalidParameterValue(err)).To(BeFalse())
+			Expect(fakeEC2API.CalledWithCreateFleetInput.Len()).To(BeZero())
+		})
+	})
+	Context("Empty CreateFleet Result", func() {
+		var nodeRequest *cloudprovider.NodeRequest
+		BeforeEach(func() {
+			recorder.Reset()
+			provisioner.SetDefaults(ctx)
+			ExpectApplied(ctx, env.Client, provisioner)
+			instanceTypes, err := cloudProvider.GetInstanceTypes(ctx, provisioner)
+			Expect(err).ToNot(HaveOccurred())
+			nodeRequest = &cloudprovider.NodeRequest{Template: scheduling.NewNodeTemplate(provisioner), InstanceTypeOptions: instanceTypes}
+		})
+		It("should fail the launch when the fleet returns no instances and no errors", func() {
+			fakeEC2API.CreateFleetOutput.Set(&ec2.CreateFleetOutput{FleetId: aws.String("fleet-empty")})
+			node, err := cloudProvider.Create(ctx, nodeRequest)
+			Expect(err).To(MatchError(ContainSubstring("fleet returned no instances and no errors")))
+			Expect(node).To(BeNil())
+			Expect(fakeEC2API.CalledWithCreateFleetInput.Len()).To(Equal(1))
+			Expect(recorder.Calls("EmptyFleet")).To(Equal(1))
+		})
+		It("should fail the launch when the fleet returns a reservation without instances", func() {
+			fakeEC2API.CreateFleetOutput.Set(&ec2.CreateFleetOutput{FleetId: aws.String("fleet-empty"), Instances: []*ec2.CreateFleetInstance{{}}})
+			node, err := cloudProvider.Create(ctx, nodeRequest)
+			Expect(err).To(MatchError(ContainSubstring("fleet returned no instances and no errors")))
+			Expect(node).To(BeNil())
+			Expect(recorder.Calls("EmptyFleet")).To(Equal(1))
+		})
+		It("should not schedule pods onto an empty fleet", func() {
+			fakeEC2API.CreateFleetOutput.Set(&ec2.CreateFleetOutput{FleetId: aws.String("fleet-empty")})
+			pod := ExpectProvisioned(ctx, env.Client, recorder, controller, prov, coretest.UnschedulablePod())[0]
+			ExpectNotScheduled(ctx, env.Client, pod)
+			Expect(recorder.Calls("EmptyFleet")).To(BeNumerically(">=", 1))
+		})
+	})
 })
 
 func RelativeToRoot(path string) string {
@@ -0,0 +1,140 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudprovider
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/controllers/termination"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+)
+
+var _ = Describe("Termination", func() {
+	var instance *ec2.Instance
+	var node *v1.Node
+	var terminationController *termination.Controller
+
+	BeforeEach(func() {
+		instance = &ec2.Instance{
+			InstanceId:     aws.String(fmt.Sprintf("i-%s", coretest.RandomName())),
+			Placement:      &ec2.Placement{AvailabilityZone: aws.String("test-zone-1a")},
+			PrivateDnsName: aws.String("ip-10-0-0-1.ec2.internal"),
+			InstanceType:   aws.String("m5.large"),
+			State:          &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)},
+			Tags:           []*ec2.Tag{{Key: aws.String(v1alpha5.ProvisionerNameLabelKey), Value: aws.String("default")}},
+		}
+		fakeEC2API.Instances.Store(aws.StringValue(instance.InstanceId), instance)
+		node = coretest.Node(coretest.NodeOptions{
+			ObjectMeta: metav1.ObjectMeta{Finalizers: []string{v1alpha5.TerminationFinalizer}},
+			ProviderID: fmt.Sprintf("aws:///test-zone-1a/%s", aws.StringValue(instance.InstanceId)),
+		})
+		terminationController = termination.NewController(ctx, fakeClock, env.Client, env.KubernetesInterface.CoreV1(), recorder, cloudProvider)
+	})
+	instanceState := func() string {
+		stored, ok := fakeEC2API.Instances.Load(aws.StringValue(instance.InstanceId))
+		Expect(ok).To(BeTrue())
+		return aws.StringValue(stored.(*ec2.Instance).State.Name)
+	}
+
+	It("should terminate the instance before removing the finalizer", func() {
+		ExpectApplied(ctx, env.Client, node)
+		Expect(env.Client.Delete(ctx, node)).To(Succeed())
+		ExpectReconcileSucceeded(ctx, terminationController, client.ObjectKeyFromObject(node))
+		ExpectNotFound(ctx, env.Client, node)
+
+		Expect(fakeEC2API.CalledWithTerminateInstancesInput.Len()).To(Equal(1))
+		Expect(aws.StringValueSlice(fakeEC2API.CalledWithTerminateInstancesInput.Pop().InstanceIds)).To(ConsistOf(aws.StringValue(instance.InstanceId)))
+		Expect(instanceState()).To(Equal(ec2.InstanceStateNameShuttingDown))
+	})
+	It("should keep the finalizer while the instance fails to terminate", func() {
+		fakeEC2API.NextError.Set(awserr.New("InternalError", "internal error", nil))
+		ExpectApplied(ctx, env.Client, node)
+		Expect(env.Client.Delete(ctx, node)).To(Succeed())
+		ExpectReconcileFailed(ctx, terminationController, client.ObjectKeyFromObject(node))
+		Expect(ExpectNodeExists(ctx, env.Client, node.Name).Finalizers).To(ContainElement(v1alpha5.TerminationFinalizer))
+		Expect(instanceState()).To(Equal(ec2.InstanceStateNameRunning))
+
+		// The termination is retried until it succeeds
+		ExpectReconcileSucceeded(ctx, terminationController, client.ObjectKeyFromObject(node))
+		ExpectNotFound(ctx, env.Client, node)
+		Expect(instanceState()).To(Equal(ec2.InstanceStateNameShuttingDown))
+	})
+	It("should keep the finalizer when the instance isn't confirmed to be shutting down", func() {
+		fakeEC2API.TerminateInstancesOutput.Set(&ec2.TerminateInstancesOutput{})
+		ExpectApplied(ctx, env.Client, node)
+		Expect(env.Client.Delete(ctx, node)).To(Succeed())
+		ExpectReconcileFailed(ctx, terminationController, client.ObjectKeyFromObject(node))
+		Expect(ExpectNodeExists(ctx, env.Client, node.Name).Finalizers).To(ContainElement(v1alpha5.TerminationFinalizer))
+
+		fakeEC2API.TerminateInstancesOutput.Reset()
+		ExpectReconcileSucceeded(ctx, terminationController, client.ObjectKeyFromObject(node))
+		ExpectNotFound(ctx, env.Client, node)
+	})
+	It("should confirm termination by describing the instance when the response doesn't include it", func() {
+		fakeEC2API.TerminateInstancesOutput.Set(&ec2.TerminateInstancesOutput{})
+		instance.State = &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameShuttingDown)}
+		Expect(cloudProvider.Delete(ctx, node)).To(Succeed())
+	})
+	It("should keep the finalizer when an instance that isn't tagged by Karpenter is still running", func() {
+		fakeEC2API.TerminateInstancesOutput.Set(&ec2.TerminateInstancesOutput{})
+		instance.Tags = nil
+		Expect(cloudProvider.Delete(ctx, node)).ToNot(Succeed())
+	})
+	It("should succeed when the instance is already terminated", func() {
+		instance.State = &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameTerminated)}
+		Expect(cloudProvider.Delete(ctx, node)).To(Succeed())
+		Expect(instanceState()).To(Equal(ec2.InstanceStateNameTerminated))
+		// Terminating again is idempotent
+		Expect(cloudProvider.Delete(ctx, node)).To(Succeed())
+	})
+	It("should succeed when the instance doesn't exist", func() {
+		fakeEC2API.Instances.Delete(aws.StringValue(instance.InstanceId))
+		Expect(cloudProvider.Delete(ctx, node)).To(Succeed())
+		Expect(fakeEC2API.CalledWithTerminateInstancesInput.Len()).To(Equal(1))
+	})
+	It("should lift the termination protection of the instance before terminating it", func() {
+		fakeEC2API.TerminationProtectedInstances.Store(aws.StringValue(instance.InstanceId), true)
+		ExpectApplied(ctx, env.Client, node)
+		Expect(env.Client.Delete(ctx, node)).To(Succeed())
+		ExpectReconcileSucceeded(ctx, terminationController, client.ObjectKeyFromObject(node))
+		ExpectNotFound(ctx, env.Client, node)
+
+		Expect(fakeEC2API.CalledWithModifyInstanceAttributeInput.Len()).To(Equal(1))
+		input := fakeEC2API.CalledWithModifyInstanceAttributeInput.Pop()
+		Expect(aws.StringValue(input.InstanceId)).To(Equal(aws.StringValue(instance.InstanceId)))
+		Expect(aws.BoolValue(input.DisableApiTermination.Value)).To(BeFalse())
+		Expect(instanceState()).To(Equal(ec2.InstanceStateNameShuttingDown))
+	})
+	It("should not modify instances without termination protection", func() {
+		Expect(cloudProvider.Delete(ctx, node)).To(Succeed())
+		Expect(fakeEC2API.CalledWithModifyInstanceAttributeInput.Len()).To(Equal(0))
+	})
+	It("should succeed when terminating fails but the instance is already terminated", func() {
+		instance.State = &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameTerminated)}
+		fakeEC2API.NextError.Set(awserr.New("IncorrectInstanceState", "the instance is not in a state from which it can be terminated", nil))
+		Expect(cloudProvider.Delete(ctx, node)).To(Succeed())
+	})
+})
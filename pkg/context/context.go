@@ -52,6 +52,7 @@ type Context struct {
 
 	Session                   *session.Session
 	UnavailableOfferingsCache *awscache.UnavailableOfferings
+	SSMQueriesCache           *awscache.SSMQueries
 }
 
 func NewOrDie(ctx cloudprovider.Context) Context {
@@ -72,6 +73,7 @@ func NewOrDie(ctx cloudprovider.Context) Context {
 		Context:                   ctx,
 		Session:                   sess,
 		UnavailableOfferingsCache: awscache.NewUnavailableOfferings(cache.New(awscache.UnavailableOfferingsTTL, CacheCleanupInterval)),
+		SSMQueriesCache:           awscache.NewSSMQueries(cache.New(awscache.SSMQueriesTTL, CacheCleanupInterval)),
 	}
 }
 
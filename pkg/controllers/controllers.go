@@ -15,22 +15,32 @@ limitations under the License.
 package controllers
 
 import (
+	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/eventbridge"
 	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/patrickmn/go-cache"
 
 	"github.com/aws/karpenter-core/pkg/operator/controller"
+	"github.com/aws/karpenter/pkg/cloudprovider"
+	"github.com/aws/karpenter/pkg/cloudprovider/amifamily"
 	awscontext "github.com/aws/karpenter/pkg/context"
+	"github.com/aws/karpenter/pkg/controllers/drift"
 	"github.com/aws/karpenter/pkg/controllers/interruption"
 	"github.com/aws/karpenter/pkg/controllers/nodetemplate"
 	"github.com/aws/karpenter/pkg/controllers/providers"
 )
 
-func NewControllers(ctx awscontext.Context) []controller.Controller {
+func NewControllers(ctx awscontext.Context, cloudProvider *cloudprovider.CloudProvider) []controller.Controller {
 	sqsProvider := providers.NewSQS(sqs.New(ctx.Session))
 	eventBridgeProvider := providers.NewEventBridge(eventbridge.New(ctx.Session), sqsProvider)
+	ec2api := ec2.New(ctx.Session)
 
 	return []controller.Controller{
-		nodetemplate.NewController(ctx.KubeClient, sqsProvider, eventBridgeProvider),
+		nodetemplate.NewController(ctx.KubeClient, ctx.Clock, ctx.EventRecorder, sqsProvider, eventBridgeProvider, cloudprovider.NewSubnetProvider(ec2api), cloudprovider.NewSecurityGroupProvider(ec2api),
+			amifamily.NewAMIProvider(ctx.KubeClient, ctx.EventRecorder, ssm.New(ctx.Session), ec2api, cache.New(awscontext.CacheTTL, awscontext.CacheCleanupInterval), cache.New(awscontext.CacheTTL, awscontext.CacheCleanupInterval), ctx.SSMQueriesCache),
+			ctx.SSMQueriesCache),
 		interruption.NewController(ctx.KubeClient, ctx.Clock, ctx.EventRecorder, sqsProvider, ctx.UnavailableOfferingsCache),
+		drift.NewController(ctx.KubeClient, ctx.Clock, ctx.EventRecorder, cloudProvider),
 	}
 }
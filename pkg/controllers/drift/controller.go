@@ -0,0 +1,142 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package drift
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/clock"
+	"knative.dev/pkg/logging"
+	controllerruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/events"
+	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
+	"github.com/aws/karpenter/pkg/apis/config/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+)
+
+const (
+	Name = "drift"
+
+	// ConditionTypeAMIDrifted is the node condition that's true while the node runs an AMI other than the one that its
+	// node template currently resolves to
+	ConditionTypeAMIDrifted v1.NodeConditionType = "AMIDrifted"
+
+	// The resolved AMIs change when SSM parameters or AMIs change, which doesn't trigger an event for the node
+	pollingPeriod = 5 * time.Minute
+)
+
+// Controller marks the nodes whose AMI drifted from the AMI that their node template currently resolves to with the
+// AMIDrifted condition. The cloud provider detects the drift, and the controller only surfaces it. It doesn't delete
+// drifted nodes, so that replacing them is left to core's deprovisioning, which honors do-not-evict pods and
+// do-not-consolidate nodes.
+type Controller struct {
+	kubeClient    client.Client
+	clk           clock.Clock
+	recorder      events.Recorder
+	cloudProvider DriftDetector
+}
+
+// DriftDetector detects whether a node drifted from its node template, which the cloud provider implements
+type DriftDetector interface {
+	IsDrifted(context.Context, *v1.Node) (bool, error)
+}
+
+func NewController(kubeClient client.Client, clk clock.Clock, recorder events.Recorder, cloudProvider DriftDetector) *Controller {
+	return &Controller{
+		kubeClient:    kubeClient,
+		clk:           clk,
+		recorder:      recorder,
+		cloudProvider: cloudProvider,
+	}
+}
+
+func (c *Controller) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	if !settings.FromContext(ctx).EnableAMIDriftDetection {
+		return reconcile.Result{}, nil
+	}
+	ctx = logging.WithLogger(ctx, logging.FromContext(ctx).Named(Name).With("node", req.Name))
+	stored := &v1.Node{}
+	if err := c.kubeClient.Get(ctx, req.NamespacedName, stored); err != nil {
+		return reconcile.Result{}, client.IgnoreNotFound(err)
+	}
+	if _, ok := stored.Labels[v1alpha5.ProvisionerNameLabelKey]; !ok || !stored.DeletionTimestamp.IsZero() {
+		return reconcile.Result{}, nil
+	}
+	drifted, err := c.cloudProvider.IsDrifted(ctx, stored)
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("detecting drift, %w", err)
+	}
+	if err := c.updateCondition(ctx, stored, drifted); err != nil {
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{RequeueAfter: pollingPeriod}, nil
+}
+
+// updateCondition sets the AMIDrifted condition of the node. Nodes that never drifted don't get the condition.
+func (c *Controller) updateCondition(ctx context.Context, stored *v1.Node, drifted bool) error {
+	existing, ok := lo.Find(stored.Status.Conditions, func(condition v1.NodeCondition) bool {
+		return condition.Type == ConditionTypeAMIDrifted
+	})
+	status := lo.Ternary(drifted, v1.ConditionTrue, v1.ConditionFalse)
+	if (!ok && !drifted) || (ok && existing.Status == status) {
+		return nil
+	}
+	now := metav1.NewTime(c.clk.Now())
+	condition := v1.NodeCondition{
+		Type:               ConditionTypeAMIDrifted,
+		Status:             status,
+		LastHeartbeatTime:  now,
+		LastTransitionTime: now,
+		Reason:             lo.Ternary(drifted, "AMIDrifted", "AMIResolved"),
+		Message: lo.Ternary(drifted,
+			fmt.Sprintf("Node was launched with ami %s, which its node template no longer resolves to", stored.Annotations[v1alpha1.AnnotationAMIID]),
+			"Node runs the ami that its node template resolves to"),
+	}
+	node := stored.DeepCopy()
+	node.Status.Conditions = append(lo.Reject(node.Status.Conditions, func(condition v1.NodeCondition, _ int) bool {
+		return condition.Type == ConditionTypeAMIDrifted
+	}), condition)
+	if err := c.kubeClient.Status().Patch(ctx, node, client.MergeFrom(stored)); err != nil {
+		return fmt.Errorf("patching node status, %w", err)
+	}
+	if drifted {
+		logging.FromContext(ctx).Infof("Marked node with ami %s as drifted", node.Annotations[v1alpha1.AnnotationAMIID])
+		c.recorder.Publish(NodeAMIDrifted(node))
+	}
+	*stored = *node
+	return nil
+}
+
+func (c *Controller) Builder(_ context.Context, m manager.Manager) corecontroller.Builder {
+	return controllerruntime.
+		NewControllerManagedBy(m).
+		Named(Name).
+		For(&v1.Node{})
+}
+
+func (c *Controller) LivenessProbe(_ *http.Request) error {
+	return nil
+}
@@ -0,0 +1,34 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package drift
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/aws/karpenter-core/pkg/events"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+)
+
+func NodeAMIDrifted(node *v1.Node) events.Event {
+	return events.Event{
+		InvolvedObject: node,
+		Type:           v1.EventTypeNormal,
+		Reason:         "AMIDrifted",
+		Message:        fmt.Sprintf("Node %s event: Node was launched with ami %s, which its node template no longer resolves to", node.Name, node.Annotations[v1alpha1.AnnotationAMIID]),
+		DedupeValues:   []string{node.Name},
+	}
+}
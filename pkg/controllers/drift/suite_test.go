@@ -0,0 +1,173 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package drift_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clock "k8s.io/utils/clock/testing"
+	. "knative.dev/pkg/logging/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	coresettings "github.com/aws/karpenter-core/pkg/apis/config/settings"
+	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/operator/scheme"
+	coretest "github.com/aws/karpenter-core/pkg/test"
+	. "github.com/aws/karpenter-core/pkg/test/expectations"
+	"github.com/aws/karpenter/pkg/apis"
+	"github.com/aws/karpenter/pkg/apis/config/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/controllers/drift"
+	"github.com/aws/karpenter/pkg/test"
+)
+
+var ctx context.Context
+var env *coretest.Environment
+var fakeClock *clock.FakeClock
+var recorder *coretest.EventRecorder
+var driftDetector *fakeDriftDetector
+var controller *drift.Controller
+
+// fakeDriftDetector reports every node as drifted or not, depending on drifted
+type fakeDriftDetector struct {
+	drifted bool
+	calls   int
+}
+
+func (f *fakeDriftDetector) IsDrifted(_ context.Context, _ *v1.Node) (bool, error) {
+	f.calls++
+	return f.drifted, nil
+}
+
+func TestAPIs(t *testing.T) {
+	ctx = TestContextWithLogger(t)
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "AWSDrift")
+}
+
+var _ = BeforeSuite(func() {
+	env = coretest.NewEnvironment(scheme.Scheme, apis.CRDs...)
+	fakeClock = clock.NewFakeClock(time.Now())
+	recorder = coretest.NewEventRecorder()
+})
+
+var _ = AfterSuite(func() {
+	Expect(env.Stop()).To(Succeed(), "Failed to stop environment")
+})
+
+var _ = BeforeEach(func() {
+	driftDetector = &fakeDriftDetector{}
+	controller = drift.NewController(env.Client, fakeClock, recorder, driftDetector)
+	settingsStore := coretest.SettingsStore{
+		coresettings.ContextKey: coretest.Settings(),
+		settings.ContextKey: test.Settings(test.SettingOptions{
+			EnableAMIDriftDetection: lo.ToPtr(true),
+		}),
+	}
+	ctx = settingsStore.InjectSettings(ctx)
+})
+
+var _ = AfterEach(func() {
+	recorder.Reset()
+	ExpectCleanedUp(ctx, env.Client)
+})
+
+var _ = Describe("AMI Drift", func() {
+	var node *v1.Node
+	getCondition := func() (v1.NodeCondition, bool) {
+		stored := ExpectNodeExists(ctx, env.Client, node.Name)
+		return lo.Find(stored.Status.Conditions, func(condition v1.NodeCondition) bool {
+			return condition.Type == drift.ConditionTypeAMIDrifted
+		})
+	}
+	BeforeEach(func() {
+		node = coretest.Node(coretest.NodeOptions{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels:      map[string]string{v1alpha5.ProvisionerNameLabelKey: "default"},
+				Annotations: map[string]string{v1alpha1.AnnotationAMIID: "ami-old"},
+			},
+		})
+	})
+	It("should mark a drifted node with the AMIDrifted condition without deleting it", func() {
+		driftDetector.drifted = true
+		ExpectApplied(ctx, env.Client, node)
+		result := ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(node))
+		Expect(result.RequeueAfter).ToNot(BeZero())
+
+		condition, ok := getCondition()
+		Expect(ok).To(BeTrue())
+		Expect(condition.Status).To(Equal(v1.ConditionTrue))
+		Expect(condition.Reason).To(Equal("AMIDrifted"))
+		Expect(recorder.Calls("AMIDrifted")).To(Equal(1))
+		Expect(ExpectNodeExists(ctx, env.Client, node.Name).DeletionTimestamp.IsZero()).To(BeTrue())
+	})
+	It("should not add the condition to a node that never drifted", func() {
+		ExpectApplied(ctx, env.Client, node)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(node))
+		_, ok := getCondition()
+		Expect(ok).To(BeFalse())
+	})
+	It("should flip the condition to false once the node no longer drifts", func() {
+		driftDetector.drifted = true
+		ExpectApplied(ctx, env.Client, node)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(node))
+
+		driftDetector.drifted = false
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(node))
+		condition, ok := getCondition()
+		Expect(ok).To(BeTrue())
+		Expect(condition.Status).To(Equal(v1.ConditionFalse))
+		Expect(condition.Reason).To(Equal("AMIResolved"))
+		Expect(recorder.Calls("AMIDrifted")).To(Equal(1))
+	})
+	It("should not update the condition while it's unchanged", func() {
+		driftDetector.drifted = true
+		ExpectApplied(ctx, env.Client, node)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(node))
+		condition, _ := getCondition()
+
+		fakeClock.Step(time.Hour)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(node))
+		unchanged, _ := getCondition()
+		Expect(unchanged.LastTransitionTime.Time).To(BeTemporally("==", condition.LastTransitionTime.Time))
+		Expect(recorder.Calls("AMIDrifted")).To(Equal(1))
+	})
+	It("should not detect drift or requeue when drift detection is disabled", func() {
+		ctx = coretest.SettingsStore{
+			settings.ContextKey: test.Settings(test.SettingOptions{EnableAMIDriftDetection: lo.ToPtr(false)}),
+		}.InjectSettings(ctx)
+		driftDetector.drifted = true
+		ExpectApplied(ctx, env.Client, node)
+		result := ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(node))
+		Expect(result.RequeueAfter).To(BeZero())
+		Expect(driftDetector.calls).To(BeZero())
+		_, ok := getCondition()
+		Expect(ok).To(BeFalse())
+	})
+	It("should ignore nodes that aren't owned by a provisioner", func() {
+		driftDetector.drifted = true
+		node.Labels = nil
+		ExpectApplied(ctx, env.Client, node)
+		ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(node))
+		Expect(driftDetector.calls).To(BeZero())
+	})
+})
@@ -17,14 +17,20 @@ package interruption
 import (
 	"context"
 	"fmt"
+	"math"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/avast/retry-go"
+	"github.com/aws/aws-sdk-go/aws"
 	sqsapi "github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/samber/lo"
 	"go.uber.org/multierr"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/client-go/util/workqueue"
 	"k8s.io/utils/clock"
 	"knative.dev/pkg/logging"
@@ -41,7 +47,7 @@ import (
 	"github.com/aws/karpenter/pkg/controllers/interruption/messages"
 	"github.com/aws/karpenter/pkg/controllers/interruption/messages/statechange"
 	"github.com/aws/karpenter/pkg/controllers/providers"
-	"github.com/aws/karpenter/pkg/errors"
+	awserrors "github.com/aws/karpenter/pkg/errors"
 	"github.com/aws/karpenter/pkg/utils"
 
 	"github.com/aws/karpenter-core/pkg/events"
@@ -63,6 +69,15 @@ const (
 	NoAction       Action = "NoAction"
 )
 
+const (
+	// Node lookups are retried when the api-server fails transiently, so that a message isn't left in the queue or
+	// acted on without knowing whether its nodes exist
+	nodeLookupRetryDelay = 500 * time.Millisecond
+	nodeLookupAttempts   = 3
+	// Throttled receives back off exponentially from receiveBackoffBase, up to aws.interruptionReceiveMaxBackoff
+	receiveBackoffBase = time.Second
+)
+
 // Controller is an AWS interruption controller.
 // It continually polls an SQS queue for events from aws.ec2 and aws.health that
 // trigger node health events or node spot interruption/rebalance events.
@@ -73,6 +88,10 @@ type Controller struct {
 	sqsProvider               *providers.SQS
 	unavailableOfferingsCache *cache.UnavailableOfferings
 	parser                    *EventParser
+
+	// throttledReceives is the number of receives that were throttled in a row. It's only accessed by Reconcile, which
+	// the singleton never runs concurrently.
+	throttledReceives int
 }
 
 func NewController(kubeClient client.Client, clk clock.Clock, recorder events.Recorder,
@@ -101,8 +120,16 @@ func (c *Controller) Reconcile(ctx context.Context, _ reconcile.Request) (reconc
 	}
 	sqsMessages, err := c.sqsProvider.GetSQSMessages(ctx)
 	if err != nil {
+		// Throttling is expected under load, so it backs off on its own schedule rather than the error backoff of the
+		// singleton, which keeps growing and would delay receiving long after the throttling clears
+		if awserrors.IsThrottled(err) {
+			backoff := c.receiveBackoff(ctx)
+			logging.FromContext(ctx).With("backoff", backoff).Debugf("Receiving messages from queue was throttled")
+			return reconcile.Result{RequeueAfter: backoff}, nil
+		}
 		return reconcile.Result{}, fmt.Errorf("getting messages from queue, %w", err)
 	}
+	c.throttledReceives = 0
 	if len(sqsMessages) == 0 {
 		return reconcile.Result{}, nil
 	}
@@ -111,20 +138,85 @@ func (c *Controller) Reconcile(ctx context.Context, _ reconcile.Request) (reconc
 		return reconcile.Result{}, fmt.Errorf("making instance id map, %w", err)
 	}
 	errs := make([]error, len(sqsMessages))
+	msgs := make([]messages.Message, len(sqsMessages))
 	workqueue.ParallelizeUntil(ctx, 10, len(sqsMessages), func(i int) {
-		msg, e := c.parseMessage(sqsMessages[i])
+		msg, e := c.parseMessage(ctx, sqsMessages[i])
 		if e != nil {
 			// If we fail to parse, then we should delete the message but still log the error
 			logging.FromContext(ctx).Errorf("parsing message, %v", e)
 			errs[i] = c.deleteMessage(ctx, sqsMessages[i])
 			return
 		}
-		if e = c.handleMessage(ctx, instanceIDMap, msg); e != nil {
-			errs[i] = fmt.Errorf("handling message, %w", e)
+		// Messages of ignored types are handled elsewhere, e.g. by another tool, so we drop them without acting
+		if c.isIgnored(ctx, msg) {
+			logging.FromContext(ctx).With("messageKind", msg.Kind(), "instanceIDs", msg.EC2InstanceIDs()).Debugf("Skipping ignored message")
+			errs[i] = c.finishMessage(ctx, sqsMessages[i], msg)
 			return
 		}
-		errs[i] = c.deleteMessage(ctx, sqsMessages[i])
+		// Messages that are older than the max age are no longer actionable, so we drop them without acting
+		if c.isStale(ctx, msg) {
+			logging.FromContext(ctx).With("messageKind", msg.Kind(), "messageTime", msg.StartTime()).Debugf("Skipping stale message")
+			errs[i] = c.finishMessage(ctx, sqsMessages[i], msg)
+			return
+		}
+		// Messages that don't match any nodes are left in the queue without being deleted, so that they're received
+		// again after the visibility timeout, in case their nodes haven't registered yet
+		if c.shouldRequeueUnmatched(ctx, instanceIDMap, msg) {
+			logging.FromContext(ctx).With("messageKind", msg.Kind(), "instanceIDs", msg.EC2InstanceIDs()).Debugf("Requeuing message without matching nodes")
+			return
+		}
+		msgs[i] = msg
 	})
+	// Messages are handled one drain tier at a time. Draining happens asynchronously once a node is deleted, so the
+	// messages of later tiers are left in the queue, to be received again after the visibility timeout, until the
+	// nodes of every earlier tier are gone
+	tiers := map[int][]int{}
+	for i, msg := range msgs {
+		if msg == nil {
+			continue
+		}
+		tier, e := c.drainTier(ctx, instanceIDMap, msg)
+		if e != nil {
+			errs[i] = fmt.Errorf("getting drain tier, %w", e)
+			continue
+		}
+		tiers[tier] = append(tiers[tier], i)
+	}
+	blockingTier, err := c.drainingTier(ctx, instanceIDMap)
+	if err != nil {
+		return reconcile.Result{}, fmt.Errorf("getting drain tier of draining nodes, %w", err)
+	}
+	order := lo.Keys(tiers)
+	sort.Ints(order)
+	for _, tier := range order {
+		indices := tiers[tier]
+		if tier > blockingTier {
+			for _, i := range indices {
+				logging.FromContext(ctx).With("messageKind", msgs[i].Kind(), "instanceIDs", msgs[i].EC2InstanceIDs()).Debugf("Requeuing message until the nodes of earlier drain tiers are gone")
+			}
+			continue
+		}
+		pending := make([]bool, len(indices))
+		workqueue.ParallelizeUntil(ctx, 10, len(indices), func(j int) {
+			i := indices[j]
+			draining, e := c.handleMessage(ctx, instanceIDMap, msgs[i])
+			if e != nil {
+				errs[i] = fmt.Errorf("handling message, %w", e)
+				pending[j] = true
+				return
+			}
+			// Keep the message hidden while nodes are still draining so that it doesn't get handled a second time
+			if draining {
+				errs[i] = c.extendMessageVisibility(ctx, sqsMessages[i])
+				pending[j] = true
+				return
+			}
+			errs[i] = c.finishMessage(ctx, sqsMessages[i], msgs[i])
+		})
+		if lo.Contains(pending, true) {
+			blockingTier = lo.Min([]int{blockingTier, tier})
+		}
+	}
 	return reconcile.Result{}, multierr.Combine(errs...)
 }
 
@@ -137,21 +229,119 @@ func (c *Controller) LivenessProbe(_ *http.Request) error {
 	return nil
 }
 
-// parseMessage parses the passed SQS message into an internal Message interface
-func (c *Controller) parseMessage(raw *sqsapi.Message) (messages.Message, error) {
+// receiveBackoff returns how long to wait before receiving again after a throttled receive. The wait doubles with
+// every throttled receive in a row, up to aws.interruptionReceiveMaxBackoff.
+func (c *Controller) receiveBackoff(ctx context.Context) time.Duration {
+	maxBackoff := settings.FromContext(ctx).InterruptionReceiveMaxBackoff.Duration
+	backoff := receiveBackoffBase
+	for i := 0; i < c.throttledReceives && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	c.throttledReceives++
+	return lo.Ternary(backoff > maxBackoff, maxBackoff, backoff)
+}
+
+// parseMessage opens the envelope of the passed SQS message and parses the event inside into an internal Message interface
+func (c *Controller) parseMessage(ctx context.Context, raw *sqsapi.Message) (messages.Message, error) {
 	// No message to parse in this case
 	if raw == nil || raw.Body == nil {
 		return nil, fmt.Errorf("message or message body is nil")
 	}
-	msg, err := c.parser.Parse(*raw.Body)
+	body, err := EnvelopeFromContext(ctx).Open(*raw.Body)
+	if err != nil {
+		return nil, fmt.Errorf("opening sqs message envelope, %w", err)
+	}
+	msg, err := c.parser.Parse(body)
 	if err != nil {
 		return nil, fmt.Errorf("parsing sqs message, %w", err)
 	}
 	return msg, nil
 }
 
+// isIgnored returns true if the type of the message is listed in aws.interruptionIgnoredMessageTypes
+func (c *Controller) isIgnored(ctx context.Context, msg messages.Message) bool {
+	return lo.Contains(settings.FromContext(ctx).InterruptionIgnoredMessageTypes, strings.TrimSuffix(string(msg.Kind()), "Kind"))
+}
+
+// isStale returns true if the event in the message happened longer ago than the configured max message age
+func (c *Controller) isStale(ctx context.Context, msg messages.Message) bool {
+	maxAge := settings.FromContext(ctx).InterruptionMessageMaxAge.Duration
+	return maxAge > 0 && c.clk.Since(msg.StartTime()) > maxAge
+}
+
+// shouldRequeueUnmatched returns true if none of the instances in the message are nodes owned by a Provisioner and
+// unmatched messages are requeued, until the grace period has passed since the event, for nodes that register late.
+// Otherwise, the message is handled without any nodes to act on and deleted.
+func (c *Controller) shouldRequeueUnmatched(ctx context.Context, instanceIDMap map[string]*v1.Node, msg messages.Message) bool {
+	s := settings.FromContext(ctx)
+	if s.InterruptionUnmatchedNodeBehavior != settings.RequeueMessage || c.clk.Since(msg.StartTime()) >= s.InterruptionUnmatchedNodeGracePeriod.Duration {
+		return false
+	}
+	return !lo.SomeBy(msg.EC2InstanceIDs(), func(instanceID string) bool {
+		_, ok := instanceIDMap[instanceID]
+		return ok
+	})
+}
+
+// drainTier returns the tier that the message is handled in. Nodes running pods of a priority class listed in
+// aws.interruptionDrainLastPriorityClasses are drained after all other nodes, in the order that the classes are
+// listed, and a message is handled in the latest tier of any of its involved nodes.
+func (c *Controller) drainTier(ctx context.Context, instanceIDMap map[string]*v1.Node, msg messages.Message) (int, error) {
+	if len(settings.FromContext(ctx).InterruptionDrainLastPriorityClasses) == 0 || actionForMessage(msg) == NoAction {
+		return 0, nil
+	}
+	tier := 0
+	for _, instanceID := range msg.EC2InstanceIDs() {
+		node, ok := instanceIDMap[instanceID]
+		if !ok {
+			continue
+		}
+		nodeTier, err := c.nodeTier(ctx, node)
+		if err != nil {
+			return 0, err
+		}
+		tier = lo.Max([]int{tier, nodeTier})
+	}
+	return tier, nil
+}
+
+// drainingTier returns the earliest drain tier of the nodes that are still draining after their deletion, which holds
+// back the messages of every later tier
+func (c *Controller) drainingTier(ctx context.Context, instanceIDMap map[string]*v1.Node) (int, error) {
+	tier := math.MaxInt
+	if len(settings.FromContext(ctx).InterruptionDrainLastPriorityClasses) == 0 {
+		return tier, nil
+	}
+	for _, node := range instanceIDMap {
+		if node.DeletionTimestamp.IsZero() {
+			continue
+		}
+		nodeTier, err := c.nodeTier(ctx, node)
+		if err != nil {
+			return 0, err
+		}
+		tier = lo.Min([]int{tier, nodeTier})
+	}
+	return tier, nil
+}
+
+// nodeTier returns the drain tier of the node, which is the latest tier of the priority classes of the pods on it
+func (c *Controller) nodeTier(ctx context.Context, node *v1.Node) (int, error) {
+	priorityClasses := settings.FromContext(ctx).InterruptionDrainLastPriorityClasses
+	pods := &v1.PodList{}
+	if err := c.kubeClient.List(ctx, pods, client.MatchingFields{"spec.nodeName": node.Name}); err != nil {
+		return 0, fmt.Errorf("listing pods on node %s, %w", node.Name, err)
+	}
+	tier := 0
+	for i := range pods.Items {
+		tier = lo.Max([]int{tier, lo.IndexOf(priorityClasses, pods.Items[i].Spec.PriorityClassName) + 1})
+	}
+	return tier, nil
+}
+
 // handleMessage takes an action against every node involved in the message that is owned by a Provisioner
-func (c *Controller) handleMessage(ctx context.Context, instanceIDMap map[string]*v1.Node, msg messages.Message) (err error) {
+// It returns true if any of the involved nodes are still draining after the action was taken
+func (c *Controller) handleMessage(ctx context.Context, instanceIDMap map[string]*v1.Node, msg messages.Message) (draining bool, err error) {
 	ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("messageKind", msg.Kind()))
 	receivedMessages.WithLabelValues(string(msg.Kind())).Inc()
 
@@ -161,17 +351,34 @@ func (c *Controller) handleMessage(ctx context.Context, instanceIDMap map[string
 		if !ok {
 			continue
 		}
-		if e := c.handleNode(ctx, msg, node); e != nil {
+		nodeDraining, e := c.handleNode(ctx, msg, node)
+		if e != nil {
 			failedNodeNames = append(failedNodeNames, node.Name)
 			err = multierr.Append(err, e)
 		}
+		draining = draining || nodeDraining
 	}
 	messageLatency.Observe(time.Since(msg.StartTime()).Seconds())
 	if err != nil {
-		return fmt.Errorf("failed to act on nodes [%s%s], %w",
+		return false, fmt.Errorf("failed to act on nodes [%s%s], %w",
 			strings.Join(lo.Slice(failedNodeNames, 0, 3), ","),
 			lo.Ternary(len(failedNodeNames) > 3, "...", ""), err)
 	}
+	return draining, nil
+}
+
+// finishMessage deletes the message once it's been processed and records how long it took from being sent to the
+// queue until then, including the time spent waiting for its nodes to drain. Messages without a SentTimestamp
+// attribute aren't recorded.
+func (c *Controller) finishMessage(ctx context.Context, raw *sqsapi.Message, msg messages.Message) error {
+	if err := c.deleteMessage(ctx, raw); err != nil {
+		return err
+	}
+	sentTimestamp, err := strconv.ParseInt(aws.StringValue(raw.Attributes[sqsapi.MessageSystemAttributeNameSentTimestamp]), 10, 64)
+	if err != nil {
+		return nil
+	}
+	messageQueueLatency.WithLabelValues(string(msg.Kind())).Observe(c.clk.Since(time.UnixMilli(sentTimestamp)).Seconds())
 	return nil
 }
 
@@ -184,12 +391,34 @@ func (c *Controller) deleteMessage(ctx context.Context, msg *sqsapi.Message) err
 	return nil
 }
 
+// extendMessageVisibility hides the passed SQS message for longer each time it's received, up to the SQS maximum
+func (c *Controller) extendMessageVisibility(ctx context.Context, msg *sqsapi.Message) error {
+	receiveCount, err := strconv.Atoi(aws.StringValue(msg.Attributes[sqsapi.MessageSystemAttributeNameApproximateReceiveCount]))
+	if err != nil || receiveCount < 1 {
+		receiveCount = 1
+	}
+	timeout := providers.DefaultVisibilityTimeout
+	for i := 0; i < receiveCount && timeout < providers.MaxVisibilityTimeout; i++ {
+		timeout *= 2
+	}
+	if err = c.sqsProvider.ChangeMessageVisibility(ctx, msg, timeout); err != nil {
+		return fmt.Errorf("extending sqs message visibility, %w", err)
+	}
+	return nil
+}
+
 // handleNode retrieves the action for the message and then performs the appropriate action against the node
-func (c *Controller) handleNode(ctx context.Context, msg messages.Message, node *v1.Node) error {
+// It returns true if the node is still draining after the action was taken
+func (c *Controller) handleNode(ctx context.Context, msg messages.Message, node *v1.Node) (bool, error) {
 	action := actionForMessage(msg)
 	ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("node", node.Name))
 	ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("action", string(action)))
 
+	// The node was already deleted by a previous delivery of this message and is waiting for its drain to finish
+	if action != NoAction && !node.DeletionTimestamp.IsZero() {
+		return true, nil
+	}
+
 	// Record metric and event for this action
 	c.notifyForMessage(msg, node)
 	actionsPerformed.WithLabelValues(string(action)).Inc()
@@ -203,15 +432,37 @@ func (c *Controller) handleNode(ctx context.Context, msg messages.Message, node
 		}
 	}
 	if action != NoAction {
-		return c.deleteNode(ctx, node)
+		if err := c.deleteNode(ctx, node); err != nil {
+			return false, err
+		}
+		return c.isDraining(ctx, node)
 	}
-	return nil
+	return false, nil
+}
+
+// isDraining checks whether the node still exists and is held by a finalizer after its deletion
+func (c *Controller) isDraining(ctx context.Context, node *v1.Node) (bool, error) {
+	stored := &v1.Node{}
+	if err := retry.Do(
+		func() error { return c.kubeClient.Get(ctx, client.ObjectKeyFromObject(node), stored) },
+		retry.RetryIf(isTransientAPIServerError),
+		retry.Delay(nodeLookupRetryDelay),
+		retry.Attempts(nodeLookupAttempts),
+		retry.LastErrorOnly(true),
+		retry.Context(ctx),
+	); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("getting node, %w", err)
+	}
+	return !stored.DeletionTimestamp.IsZero(), nil
 }
 
 // deleteNode removes the node from the api-server
 func (c *Controller) deleteNode(ctx context.Context, node *v1.Node) error {
 	if err := c.kubeClient.Delete(ctx, node); err != nil {
-		if errors.IsNotFound(err) {
+		if apierrors.IsNotFound(err) {
 			return nil
 		}
 		return fmt.Errorf("deleting the node on interruption message, %w", err)
@@ -251,7 +502,14 @@ func (c *Controller) notifyForMessage(msg messages.Message, n *v1.Node) {
 func (c *Controller) makeInstanceIDMap(ctx context.Context) (map[string]*v1.Node, error) {
 	m := map[string]*v1.Node{}
 	nodeList := &v1.NodeList{}
-	if err := c.kubeClient.List(ctx, nodeList); err != nil {
+	if err := retry.Do(
+		func() error { return c.kubeClient.List(ctx, nodeList) },
+		retry.RetryIf(isTransientAPIServerError),
+		retry.Delay(nodeLookupRetryDelay),
+		retry.Attempts(nodeLookupAttempts),
+		retry.LastErrorOnly(true),
+		retry.Context(ctx),
+	); err != nil {
 		return nil, fmt.Errorf("listing nodes, %w", err)
 	}
 	for i := range nodeList.Items {
@@ -269,6 +527,12 @@ func (c *Controller) makeInstanceIDMap(ctx context.Context) (map[string]*v1.Node
 	return m, nil
 }
 
+// isTransientAPIServerError returns true if the api-server failed in a way that's likely to succeed when retried
+func isTransientAPIServerError(err error) bool {
+	return apierrors.IsServerTimeout(err) || apierrors.IsTimeout(err) || apierrors.IsTooManyRequests(err) ||
+		apierrors.IsServiceUnavailable(err) || apierrors.IsInternalError(err) || apierrors.IsUnexpectedServerError(err)
+}
+
 func actionForMessage(msg messages.Message) Action {
 	switch msg.Kind() {
 	case messages.ScheduledChangeKind, messages.SpotInterruptionKind, messages.StateChangeKind:
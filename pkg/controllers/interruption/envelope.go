@@ -0,0 +1,82 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package interruption
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/samber/lo"
+
+	"github.com/aws/karpenter/pkg/apis/config/settings"
+)
+
+// Envelope extracts the EventBridge event from the body of a message that was delivered to the queue
+type Envelope interface {
+	Open(body string) (string, error)
+}
+
+// DefaultEnvelope is the body of a message that EventBridge delivers to the queue without an input transformer,
+// which is the event itself
+type DefaultEnvelope struct{}
+
+func (DefaultEnvelope) Open(body string) (string, error) {
+	return body, nil
+}
+
+// PathEnvelope holds the event in the field at the path of nested fields of the body, e.g. the output of an input
+// transformer with a template of {"event": <aws.events.event>} holds it at ["event"]. A field along the path that
+// holds JSON as a string, like the Message of an SNS notification, is decoded before the path is followed further.
+type PathEnvelope struct {
+	Path []string
+}
+
+func (e PathEnvelope) Open(body string) (string, error) {
+	raw := json.RawMessage(body)
+	for i, field := range e.Path {
+		raw = decodeJSONString(raw)
+		fields := map[string]json.RawMessage{}
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return "", fmt.Errorf("unmarshalling %s as an object, %w",
+				lo.Ternary(i == 0, "the message", fmt.Sprintf("field %q of the message", strings.Join(e.Path[:i], "."))), err)
+		}
+		value, ok := fields[field]
+		if !ok {
+			return "", fmt.Errorf("message has no field %q", strings.Join(e.Path[:i+1], "."))
+		}
+		raw = value
+	}
+	return string(decodeJSONString(raw)), nil
+}
+
+// decodeJSONString returns the contents of a JSON string, or the raw JSON when it isn't a string
+func decodeJSONString(raw json.RawMessage) json.RawMessage {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return raw
+	}
+	return json.RawMessage(s)
+}
+
+// EnvelopeFromContext returns the envelope of the messages in the queue, as configured by aws.interruptionEventPath
+func EnvelopeFromContext(ctx context.Context) Envelope {
+	path := settings.FromContext(ctx).InterruptionEventPath
+	if path == "" {
+		return DefaultEnvelope{}
+	}
+	return PathEnvelope{Path: strings.Split(path, ".")}
+}
@@ -50,6 +50,7 @@ import (
 	awscontext "github.com/aws/karpenter/pkg/context"
 	"github.com/aws/karpenter/pkg/controllers/interruption"
 	"github.com/aws/karpenter/pkg/controllers/interruption/events"
+	"github.com/aws/karpenter/pkg/controllers/interruption/messages"
 	"github.com/aws/karpenter/pkg/controllers/nodetemplate"
 	"github.com/aws/karpenter/pkg/controllers/providers"
 	"github.com/aws/karpenter/pkg/test"
@@ -127,7 +128,7 @@ func benchmarkNotificationController(b *testing.B, messageCount int) {
 	// Set-up the controllers
 	interruptionController := interruption.NewController(env.Client, fakeClock, recorder, providers.sqsProvider, unavailableOfferingsCache)
 
-	messages, nodes := makeDiverseMessagesAndNodes(messageCount)
+	msgs, nodes := makeDiverseMessagesAndNodes(messageCount)
 
 	logging.FromContext(ctx).Infof("Provisioning %d nodes", messageCount)
 	if err := provisionNodes(ctx, env.Client, nodes); err != nil {
@@ -136,7 +137,7 @@ func benchmarkNotificationController(b *testing.B, messageCount int) {
 	logging.FromContext(ctx).Infof("Completed provisioning %d nodes", messageCount)
 
 	logging.FromContext(ctx).Infof("Provisioning %d messages into the SQS Queue", messageCount)
-	if err := providers.provisionMessages(ctx, messages...); err != nil {
+	if err := providers.provisionMessages(ctx, msgs...); err != nil {
 		b.Fatalf("provisioning messages, %v", err)
 	}
 	logging.FromContext(ctx).Infof("Completed provisioning %d messages into the SQS Queue", messageCount)
@@ -195,7 +196,7 @@ func newProviders() providerSet {
 }
 
 func (p *providerSet) makeInfrastructure(ctx context.Context) error {
-	infraReconciler := nodetemplate.NewInfrastructureReconciler(p.kubeClient, p.sqsProvider, p.eventBridgeProvider)
+	infraReconciler := nodetemplate.NewInfrastructureReconciler(p.kubeClient, fakeClock, p.sqsProvider, p.eventBridgeProvider)
 	if err := infraReconciler.CreateInfrastructure(ctx); err != nil {
 		return fmt.Errorf("creating infrastructure, %w", err)
 	}
@@ -209,17 +210,17 @@ func (p *providerSet) makeInfrastructure(ctx context.Context) error {
 }
 
 func (p *providerSet) cleanupInfrastructure(ctx context.Context) error {
-	infraReconciler := nodetemplate.NewInfrastructureReconciler(p.kubeClient, p.sqsProvider, p.eventBridgeProvider)
+	infraReconciler := nodetemplate.NewInfrastructureReconciler(p.kubeClient, fakeClock, p.sqsProvider, p.eventBridgeProvider)
 	if err := infraReconciler.DeleteInfrastructure(ctx); err != nil {
 		return fmt.Errorf("deleting infrastructure, %w", err)
 	}
 	return nil
 }
 
-func (p *providerSet) provisionMessages(ctx context.Context, messages ...interface{}) error {
-	errs := make([]error, len(messages))
-	workqueue.ParallelizeUntil(ctx, 20, len(messages), func(i int) {
-		_, err := p.sqsProvider.SendMessage(ctx, messages[i])
+func (p *providerSet) provisionMessages(ctx context.Context, msgs ...messages.Message) error {
+	errs := make([]error, len(msgs))
+	workqueue.ParallelizeUntil(ctx, 20, len(msgs), func(i int) {
+		_, err := p.sqsProvider.SendMessage(ctx, msgs[i])
 		errs[i] = err
 	})
 	return multierr.Combine(errs...)
@@ -255,29 +256,29 @@ func provisionNodes(ctx context.Context, kubeClient client.Client, nodes []*v1.N
 	return multierr.Combine(errs...)
 }
 
-func makeDiverseMessagesAndNodes(count int) ([]interface{}, []*v1.Node) {
-	var messages []interface{}
+func makeDiverseMessagesAndNodes(count int) ([]messages.Message, []*v1.Node) {
+	var msgs []messages.Message
 	var nodes []*v1.Node
 
 	newMessages, newNodes := makeScheduledChangeMessagesAndNodes(count / 3)
-	messages = append(messages, newMessages...)
+	msgs = append(msgs, newMessages...)
 	nodes = append(nodes, newNodes...)
 
 	newMessages, newNodes = makeSpotInterruptionMessagesAndNodes(count / 3)
-	messages = append(messages, newMessages...)
+	msgs = append(msgs, newMessages...)
 	nodes = append(nodes, newNodes...)
 
-	newMessages, newNodes = makeStateChangeMessagesAndNodes(count-len(messages), []string{
+	newMessages, newNodes = makeStateChangeMessagesAndNodes(count-len(msgs), []string{
 		"stopping", "stopped", "shutting-down", "terminated",
 	})
-	messages = append(messages, newMessages...)
+	msgs = append(msgs, newMessages...)
 	nodes = append(nodes, newNodes...)
 
-	return messages, nodes
+	return msgs, nodes
 }
 
-func makeScheduledChangeMessagesAndNodes(count int) ([]interface{}, []*v1.Node) {
-	var msgs []interface{}
+func makeScheduledChangeMessagesAndNodes(count int) ([]messages.Message, []*v1.Node) {
+	var msgs []messages.Message
 	var nodes []*v1.Node
 	for i := 0; i < count; i++ {
 		instanceID := makeInstanceID()
@@ -294,8 +295,8 @@ func makeScheduledChangeMessagesAndNodes(count int) ([]interface{}, []*v1.Node)
 	return msgs, nodes
 }
 
-func makeStateChangeMessagesAndNodes(count int, states []string) ([]interface{}, []*v1.Node) {
-	var msgs []interface{}
+func makeStateChangeMessagesAndNodes(count int, states []string) ([]messages.Message, []*v1.Node) {
+	var msgs []messages.Message
 	var nodes []*v1.Node
 	for i := 0; i < count; i++ {
 		state := states[r.Intn(len(states))]
@@ -313,8 +314,8 @@ func makeStateChangeMessagesAndNodes(count int, states []string) ([]interface{},
 	return msgs, nodes
 }
 
-func makeSpotInterruptionMessagesAndNodes(count int) ([]interface{}, []*v1.Node) {
-	var msgs []interface{}
+func makeSpotInterruptionMessagesAndNodes(count int) ([]messages.Message, []*v1.Node) {
+	var msgs []messages.Message
 	var nodes []*v1.Node
 	for i := 0; i < count; i++ {
 		instanceID := makeInstanceID()
@@ -26,6 +26,16 @@ type Message struct {
 	Detail Detail `json:"detail"`
 }
 
+// NewMessage returns a rebalance recommendation for the instance
+func NewMessage(region, accountID, instanceID string) Message {
+	return Message{
+		Metadata: messages.NewMetadata(Parser{}, region, accountID, instanceID),
+		Detail: Detail{
+			InstanceID: instanceID,
+		},
+	}
+}
+
 type Detail struct {
 	InstanceID string `json:"instance-id"`
 }
@@ -26,6 +26,22 @@ type Message struct {
 	Detail Detail `json:"detail"`
 }
 
+// NewMessage returns an AWS Health event for scheduled EC2 maintenance that affects the instances
+func NewMessage(region, accountID string, instanceIDs ...string) Message {
+	entities := make([]AffectedEntity, len(instanceIDs))
+	for i, id := range instanceIDs {
+		entities[i] = AffectedEntity{EntityValue: id}
+	}
+	return Message{
+		Metadata: messages.NewMetadata(Parser{}, region, accountID, instanceIDs...),
+		Detail: Detail{
+			Service:           acceptedService,
+			EventTypeCategory: acceptedEventTypeCategory,
+			AffectedEntities:  entities,
+		},
+	}
+}
+
 func (m Message) EC2InstanceIDs() []string {
 	ids := make([]string, len(m.Detail.AffectedEntities))
 	for i, entity := range m.Detail.AffectedEntities {
@@ -26,6 +26,18 @@ type Message struct {
 	Detail Detail `json:"detail"`
 }
 
+// NewMessage returns a spot interruption warning for the instance, as EventBridge delivers it two minutes before the
+// instance is terminated
+func NewMessage(region, accountID, instanceID string) Message {
+	return Message{
+		Metadata: messages.NewMetadata(Parser{}, region, accountID, instanceID),
+		Detail: Detail{
+			InstanceID:     instanceID,
+			InstanceAction: "terminate",
+		},
+	}
+}
+
 type Detail struct {
 	InstanceID     string `json:"instance-id"`
 	InstanceAction string `json:"instance-action"`
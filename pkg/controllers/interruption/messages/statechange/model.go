@@ -26,6 +26,18 @@ type Message struct {
 	Detail Detail `json:"detail"`
 }
 
+// NewMessage returns a notification that the instance changed to the state. Only the "stopping", "stopped",
+// "shutting-down" and "terminated" states are acted on.
+func NewMessage(region, accountID, instanceID, state string) Message {
+	return Message{
+		Metadata: messages.NewMetadata(Parser{}, region, accountID, instanceID),
+		Detail: Detail{
+			InstanceID: instanceID,
+			State:      state,
+		},
+	}
+}
+
 type Detail struct {
 	InstanceID string `json:"instance-id"`
 	State      string `json:"state"`
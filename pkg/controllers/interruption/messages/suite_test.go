@@ -0,0 +1,111 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package messages_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/samber/lo"
+
+	"github.com/aws/karpenter/pkg/controllers/interruption"
+	"github.com/aws/karpenter/pkg/controllers/interruption/messages"
+	"github.com/aws/karpenter/pkg/controllers/interruption/messages/rebalancerecommendation"
+	"github.com/aws/karpenter/pkg/controllers/interruption/messages/scheduledchange"
+	"github.com/aws/karpenter/pkg/controllers/interruption/messages/spotinterruption"
+	"github.com/aws/karpenter/pkg/controllers/interruption/messages/statechange"
+)
+
+const (
+	region    = "us-west-2"
+	accountID = "000000000000"
+)
+
+var parser *interruption.EventParser
+
+func TestMessages(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Messages")
+}
+
+var _ = BeforeSuite(func() {
+	parser = interruption.NewEventParser(interruption.DefaultParsers...)
+})
+
+// ExpectParsedBack marshals the message as it's sent to the queue and returns the message that it's parsed back as
+func ExpectParsedBack(msg messages.Message) messages.Message {
+	ExpectWithOffset(1, parser.Validate(msg)).To(Succeed())
+	parsed, err := parser.Parse(string(lo.Must(json.Marshal(msg))))
+	ExpectWithOffset(1, err).ToNot(HaveOccurred())
+	return parsed
+}
+
+var _ = Describe("Messages", func() {
+	It("should build metadata for the instances in the region and account", func() {
+		metadata := messages.NewMetadata(spotinterruption.Parser{}, region, accountID, "i-0123456789abcdef0", "i-0123456789abcdef1")
+		Expect(metadata.Source).To(Equal("aws.ec2"))
+		Expect(metadata.DetailType).To(Equal("EC2 Spot Instance Interruption Warning"))
+		Expect(metadata.Version).To(Equal("0"))
+		Expect(metadata.Account).To(Equal(accountID))
+		Expect(metadata.Region).To(Equal(region))
+		Expect(metadata.ID).ToNot(BeEmpty())
+		Expect(metadata.Time.IsZero()).To(BeFalse())
+		Expect(metadata.Resources).To(Equal([]string{
+			"arn:aws:ec2:us-west-2:000000000000:instance/i-0123456789abcdef0",
+			"arn:aws:ec2:us-west-2:000000000000:instance/i-0123456789abcdef1",
+		}))
+	})
+	It("should build spot interruption messages that parse back", func() {
+		msg := spotinterruption.NewMessage(region, accountID, "i-0123456789abcdef0")
+		parsed := ExpectParsedBack(msg)
+		Expect(parsed).To(Equal(msg))
+		Expect(parsed.Kind()).To(Equal(messages.SpotInterruptionKind))
+		Expect(parsed.EC2InstanceIDs()).To(Equal([]string{"i-0123456789abcdef0"}))
+	})
+	It("should build rebalance recommendation messages that parse back", func() {
+		msg := rebalancerecommendation.NewMessage(region, accountID, "i-0123456789abcdef0")
+		parsed := ExpectParsedBack(msg)
+		Expect(parsed).To(Equal(msg))
+		Expect(parsed.Kind()).To(Equal(messages.RebalanceRecommendationKind))
+		Expect(parsed.EC2InstanceIDs()).To(Equal([]string{"i-0123456789abcdef0"}))
+	})
+	It("should build state change messages that parse back", func() {
+		for _, state := range []string{"stopping", "stopped", "shutting-down", "terminated"} {
+			msg := statechange.NewMessage(region, accountID, "i-0123456789abcdef0", state)
+			parsed := ExpectParsedBack(msg)
+			Expect(parsed).To(Equal(msg))
+			Expect(parsed.Kind()).To(Equal(messages.StateChangeKind))
+			Expect(parsed.EC2InstanceIDs()).To(Equal([]string{"i-0123456789abcdef0"}))
+		}
+	})
+	It("should build scheduled change messages that parse back", func() {
+		msg := scheduledchange.NewMessage(region, accountID, "i-0123456789abcdef0", "i-0123456789abcdef1")
+		parsed := ExpectParsedBack(msg)
+		Expect(parsed).To(Equal(msg))
+		Expect(parsed.Kind()).To(Equal(messages.ScheduledChangeKind))
+		Expect(parsed.EC2InstanceIDs()).To(Equal([]string{"i-0123456789abcdef0", "i-0123456789abcdef1"}))
+	})
+	It("should fail validation for a message that isn't acted on", func() {
+		msg := statechange.NewMessage(region, accountID, "i-0123456789abcdef0", "running")
+		Expect(parser.Validate(msg)).To(MatchError(ContainSubstring("parsed as NoOpKind")))
+	})
+	It("should fail validation for a message without the metadata of its event", func() {
+		msg := spotinterruption.NewMessage(region, accountID, "i-0123456789abcdef0")
+		msg.Source = "aws.health"
+		Expect(parser.Validate(msg)).To(HaveOccurred())
+	})
+})
@@ -15,7 +15,10 @@ limitations under the License.
 package messages
 
 import (
+	"fmt"
 	"time"
+
+	"k8s.io/apimachinery/pkg/util/uuid"
 )
 
 type Parser interface {
@@ -56,3 +59,23 @@ type Metadata struct {
 func (m Metadata) StartTime() time.Time {
 	return m.Time
 }
+
+// NewMetadata returns the metadata of an event that is handled by the parser, as EventBridge delivers it for the
+// instances in the region and account. The event gets a random ID and happens now, to the second in UTC like the
+// time of EventBridge events.
+func NewMetadata(parser Parser, region, accountID string, instanceIDs ...string) Metadata {
+	resources := make([]string, len(instanceIDs))
+	for i, id := range instanceIDs {
+		resources[i] = fmt.Sprintf("arn:aws:ec2:%s:%s:instance/%s", region, accountID, id)
+	}
+	return Metadata{
+		Account:    accountID,
+		DetailType: parser.DetailType(),
+		ID:         string(uuid.NewUUID()),
+		Region:     region,
+		Resources:  resources,
+		Source:     parser.Source(),
+		Time:       time.Now().UTC().Truncate(time.Second),
+		Version:    parser.Version(),
+	}
+}
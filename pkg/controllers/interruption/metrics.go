@@ -55,6 +55,16 @@ var (
 			Buckets:   metrics.DurationBuckets(),
 		},
 	)
+	messageQueueLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: interruptionSubsystem,
+			Name:      "message_queue_latency_time_seconds",
+			Help:      "Length of time between a message being sent to the SQS queue and the controller finishing processing it. Labeled by message type.",
+			Buckets:   metrics.DurationBuckets(),
+		},
+		[]string{messageTypeLabel},
+	)
 	actionsPerformed = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: metrics.Namespace,
@@ -67,5 +77,5 @@ var (
 )
 
 func init() {
-	crmetrics.Registry.MustRegister(receivedMessages, deletedMessages, messageLatency, actionsPerformed)
+	crmetrics.Registry.MustRegister(receivedMessages, deletedMessages, messageLatency, messageQueueLatency, actionsPerformed)
 }
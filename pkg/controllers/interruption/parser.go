@@ -17,6 +17,7 @@ package interruption
 import (
 	"encoding/json"
 	"fmt"
+	"reflect"
 
 	"github.com/samber/lo"
 
@@ -91,3 +92,23 @@ func (p EventParser) Parse(msg string) (messages.Message, error) {
 	}
 	return noop.Message{Metadata: md}, nil
 }
+
+// Validate checks that the message is parsed back as the same kind of message for the same instances once it's
+// marshaled, as it would be when it's sent to the queue
+func (p EventParser) Validate(msg messages.Message) error {
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("marshaling the message as json, %w", err)
+	}
+	parsed, err := p.Parse(string(raw))
+	if err != nil {
+		return err
+	}
+	if parsed.Kind() != msg.Kind() {
+		return fmt.Errorf("message of kind %s is parsed as %s", msg.Kind(), parsed.Kind())
+	}
+	if !reflect.DeepEqual(parsed.EC2InstanceIDs(), msg.EC2InstanceIDs()) {
+		return fmt.Errorf("message for instances %v is parsed for instances %v", msg.EC2InstanceIDs(), parsed.EC2InstanceIDs())
+	}
+	return nil
+}
@@ -28,8 +28,11 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/patrickmn/go-cache"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/samber/lo"
 	v1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/uuid"
@@ -37,6 +40,7 @@ import (
 	. "knative.dev/pkg/logging/testing"
 	_ "knative.dev/pkg/system/testing"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	coresettings "github.com/aws/karpenter-core/pkg/apis/config/settings"
 	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
@@ -64,8 +68,6 @@ const (
 	defaultAccountID  = "000000000000"
 	defaultInstanceID = "i-08c6fdb11e28c8c90"
 	defaultRegion     = "us-west-2"
-	ec2Source         = "aws.ec2"
-	healthSource      = "aws.health"
 )
 
 var ctx context.Context
@@ -122,6 +124,7 @@ var _ = BeforeEach(func() {
 var _ = AfterEach(func() {
 	sqsapi.Reset()
 	eventbridgeapi.Reset()
+	recorder.Reset()
 	ExpectCleanedUp(ctx, env.Client)
 	ExpectDeleted(ctx, env.Client, nodeTemplate)
 })
@@ -209,6 +212,107 @@ var _ = Describe("AWSInterruption", func() {
 			ExpectNotFound(ctx, env.Client, lo.Map(nodes, func(n *v1.Node, _ int) client.Object { return n })...)
 			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(100))
 		})
+		It("should drain nodes running the drain-last priority classes after all other nodes", func() {
+			ctx = coretest.SettingsStore{
+				coresettings.ContextKey: coretest.Settings(),
+				settings.ContextKey: test.Settings(test.SettingOptions{
+					EnableInterruptionHandling:           lo.ToPtr(true),
+					InterruptionDrainLastPriorityClasses: []string{"high-priority", "highest-priority"},
+				}),
+			}.InjectSettings(ctx)
+			priorityClasses := []*schedulingv1.PriorityClass{
+				{ObjectMeta: metav1.ObjectMeta{Name: "high-priority"}, Value: 1000},
+				{ObjectMeta: metav1.ObjectMeta{Name: "highest-priority"}, Value: 2000},
+			}
+			for _, priorityClass := range priorityClasses {
+				ExpectApplied(ctx, env.Client, priorityClass)
+			}
+			// Each node runs a pod of the priority class, and the messages are received in the reverse of the drain order
+			instanceIDs := map[string][]string{}
+			var raw []*sqs.Message
+			for _, priorityClassName := range []string{"highest-priority", "high-priority", ""} {
+				for i := 0; i < 3; i++ {
+					instanceID := makeInstanceID()
+					node := coretest.Node(coretest.NodeOptions{
+						ObjectMeta: metav1.ObjectMeta{
+							Labels: map[string]string{
+								v1alpha5.ProvisionerNameLabelKey: "default",
+							},
+						},
+						ProviderID: makeProviderID(instanceID),
+					})
+					ExpectApplied(ctx, env.Client, node, coretest.Pod(coretest.PodOptions{NodeName: node.Name, PriorityClassName: priorityClassName}))
+					instanceIDs[priorityClassName] = append(instanceIDs[priorityClassName], instanceID)
+					raw = append(raw, &sqs.Message{
+						Body:          aws.String(string(lo.Must(json.Marshal(spotInterruptionMessage(instanceID))))),
+						MessageId:     aws.String(string(uuid.NewUUID())),
+						ReceiptHandle: aws.String(instanceID),
+					})
+				}
+			}
+			sqsapi.ReceiveMessageBehavior.Output.Set(&sqs.ReceiveMessageOutput{Messages: raw})
+
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(9))
+			var deleted []string
+			for sqsapi.DeleteMessageBehavior.CalledWithInput.Len() > 0 {
+				deleted = append([]string{aws.StringValue(sqsapi.DeleteMessageBehavior.CalledWithInput.Pop().ReceiptHandle)}, deleted...)
+			}
+			Expect(deleted[0:3]).To(ConsistOf(instanceIDs[""]))
+			Expect(deleted[3:6]).To(ConsistOf(instanceIDs["high-priority"]))
+			Expect(deleted[6:9]).To(ConsistOf(instanceIDs["highest-priority"]))
+			for _, priorityClass := range priorityClasses {
+				ExpectDeleted(ctx, env.Client, priorityClass)
+			}
+		})
+		It("should leave the messages of later drain tiers in the queue until the nodes of earlier tiers are gone", func() {
+			ctx = coretest.SettingsStore{
+				coresettings.ContextKey: coretest.Settings(),
+				settings.ContextKey: test.Settings(test.SettingOptions{
+					EnableInterruptionHandling:           lo.ToPtr(true),
+					InterruptionDrainLastPriorityClasses: []string{"high-priority"},
+				}),
+			}.InjectSettings(ctx)
+			priorityClass := &schedulingv1.PriorityClass{ObjectMeta: metav1.ObjectMeta{Name: "high-priority"}, Value: 1000}
+			ExpectApplied(ctx, env.Client, priorityClass)
+			// the node of the earlier tier is held by its finalizer while it drains
+			first := coretest.Node(coretest.NodeOptions{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:     map[string]string{v1alpha5.ProvisionerNameLabelKey: "default"},
+					Finalizers: []string{v1alpha5.TerminationFinalizer},
+				},
+				ProviderID: makeProviderID(defaultInstanceID),
+			})
+			lastInstanceID := makeInstanceID()
+			last := coretest.Node(coretest.NodeOptions{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{v1alpha5.ProvisionerNameLabelKey: "default"},
+				},
+				ProviderID: makeProviderID(lastInstanceID),
+			})
+			ExpectApplied(ctx, env.Client, first, last, coretest.Pod(coretest.PodOptions{NodeName: last.Name, PriorityClassName: "high-priority"}))
+			ExpectMessagesCreated(spotInterruptionMessage(defaultInstanceID), spotInterruptionMessage(lastInstanceID))
+
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(ExpectNodeExists(ctx, env.Client, first.Name).DeletionTimestamp.IsZero()).To(BeFalse())
+			Expect(ExpectNodeExists(ctx, env.Client, last.Name).DeletionTimestamp.IsZero()).To(BeTrue())
+			Expect(sqsapi.ChangeVisibilityBehavior.SuccessfulCalls()).To(Equal(1))
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(0))
+
+			// the message of the later tier is received again while the node of the earlier tier still drains
+			ExpectMessagesCreated(spotInterruptionMessage(lastInstanceID))
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(ExpectNodeExists(ctx, env.Client, last.Name).DeletionTimestamp.IsZero()).To(BeTrue())
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(0))
+
+			// once the node of the earlier tier is gone, the node of the later tier is drained
+			ExpectFinalizersRemoved(ctx, env.Client, first)
+			ExpectNotFound(ctx, env.Client, first)
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			ExpectNotFound(ctx, env.Client, last)
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
+			ExpectDeleted(ctx, env.Client, priorityClass)
+		})
 		It("should not delete a node when not owned by provisioner", func() {
 			node := coretest.Node(coretest.NodeOptions{
 				ProviderID: makeProviderID(string(uuid.NewUUID())),
@@ -272,16 +376,524 @@ var _ = Describe("AWSInterruption", func() {
 			// Expect a t3.large in coretest-zone-1a to be added to the ICE cache
 			Expect(unavailableOfferingsCache.IsUnavailable("t3.large", "coretest-zone-1a", v1alpha1.CapacityTypeSpot)).To(BeTrue())
 		})
+		It("should extend the message visibility while the node is draining", func() {
+			node := coretest.Node(coretest.NodeOptions{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						v1alpha5.ProvisionerNameLabelKey: "default",
+					},
+					Finalizers: []string{v1alpha5.TerminationFinalizer},
+				},
+				ProviderID: makeProviderID(defaultInstanceID),
+			})
+			ExpectMessagesCreated(spotInterruptionMessage(defaultInstanceID))
+			ExpectApplied(ctx, env.Client, node)
+
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(ExpectNodeExists(ctx, env.Client, node.Name).DeletionTimestamp.IsZero()).To(BeFalse())
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(0))
+			Expect(sqsapi.ChangeVisibilityBehavior.SuccessfulCalls()).To(Equal(1))
+			Expect(aws.Int64Value(sqsapi.ChangeVisibilityBehavior.CalledWithInput.Pop().VisibilityTimeout)).To(BeNumerically("==", 40))
+		})
+		It("should not act on a node again when it is still draining from a previous delivery of the message", func() {
+			node := coretest.Node(coretest.NodeOptions{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						v1alpha5.ProvisionerNameLabelKey: "default",
+					},
+					Finalizers: []string{v1alpha5.TerminationFinalizer},
+				},
+				ProviderID: makeProviderID(defaultInstanceID),
+			})
+			ExpectApplied(ctx, env.Client, node)
+			Expect(env.Client.Delete(ctx, node)).To(Succeed())
+			sqsapi.ReceiveMessageBehavior.Output.Set(&sqs.ReceiveMessageOutput{
+				Messages: []*sqs.Message{
+					{
+						Body:      aws.String(string(lo.Must(json.Marshal(spotInterruptionMessage(defaultInstanceID))))),
+						MessageId: aws.String(string(uuid.NewUUID())),
+						Attributes: map[string]*string{
+							sqs.MessageSystemAttributeNameApproximateReceiveCount: aws.String("3"),
+						},
+					},
+				},
+			})
+
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(recorder.Calls("InstanceSpotInterrupted")).To(Equal(0))
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(0))
+			Expect(sqsapi.ChangeVisibilityBehavior.SuccessfulCalls()).To(Equal(1))
+			Expect(aws.Int64Value(sqsapi.ChangeVisibilityBehavior.CalledWithInput.Pop().VisibilityTimeout)).To(BeNumerically("==", 160))
+		})
+		It("should cap the message visibility extension at the SQS maximum", func() {
+			node := coretest.Node(coretest.NodeOptions{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						v1alpha5.ProvisionerNameLabelKey: "default",
+					},
+					Finalizers: []string{v1alpha5.TerminationFinalizer},
+				},
+				ProviderID: makeProviderID(defaultInstanceID),
+			})
+			ExpectApplied(ctx, env.Client, node)
+			Expect(env.Client.Delete(ctx, node)).To(Succeed())
+			sqsapi.ReceiveMessageBehavior.Output.Set(&sqs.ReceiveMessageOutput{
+				Messages: []*sqs.Message{
+					{
+						Body:      aws.String(string(lo.Must(json.Marshal(spotInterruptionMessage(defaultInstanceID))))),
+						MessageId: aws.String(string(uuid.NewUUID())),
+						Attributes: map[string]*string{
+							sqs.MessageSystemAttributeNameApproximateReceiveCount: aws.String("100"),
+						},
+					},
+				},
+			})
+
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(aws.Int64Value(sqsapi.ChangeVisibilityBehavior.CalledWithInput.Pop().VisibilityTimeout)).To(BeNumerically("==", providers.MaxVisibilityTimeout.Seconds()))
+		})
+		It("should delete a message without acting on the node when the message is older than the max age", func() {
+			ctx = coretest.SettingsStore{
+				coresettings.ContextKey: coretest.Settings(),
+				settings.ContextKey: test.Settings(test.SettingOptions{
+					EnableInterruptionHandling: lo.ToPtr(true),
+					InterruptionMessageMaxAge:  lo.ToPtr(time.Minute * 10),
+				}),
+			}.InjectSettings(ctx)
+			node := coretest.Node(coretest.NodeOptions{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						v1alpha5.ProvisionerNameLabelKey: "default",
+					},
+				},
+				ProviderID: makeProviderID(defaultInstanceID),
+			})
+			msg := spotInterruptionMessage(defaultInstanceID)
+			ExpectMessagesCreated(msg)
+			ExpectApplied(ctx, env.Client, node)
+			fakeClock.SetTime(msg.Time.Add(time.Minute * 11))
+
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			ExpectNodeExists(ctx, env.Client, node.Name)
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
+		})
+		It("should act on a message that is younger than the max age", func() {
+			ctx = coretest.SettingsStore{
+				coresettings.ContextKey: coretest.Settings(),
+				settings.ContextKey: test.Settings(test.SettingOptions{
+					EnableInterruptionHandling: lo.ToPtr(true),
+					InterruptionMessageMaxAge:  lo.ToPtr(time.Minute * 10),
+				}),
+			}.InjectSettings(ctx)
+			node := coretest.Node(coretest.NodeOptions{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						v1alpha5.ProvisionerNameLabelKey: "default",
+					},
+				},
+				ProviderID: makeProviderID(defaultInstanceID),
+			})
+			msg := spotInterruptionMessage(defaultInstanceID)
+			ExpectMessagesCreated(msg)
+			ExpectApplied(ctx, env.Client, node)
+			fakeClock.SetTime(msg.Time.Add(time.Minute * 9))
+
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			ExpectNotFound(ctx, env.Client, node)
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
+		})
+		Context("Ignored Message Types", func() {
+			var node *v1.Node
+			BeforeEach(func() {
+				ctx = coretest.SettingsStore{
+					coresettings.ContextKey: coretest.Settings(),
+					settings.ContextKey: test.Settings(test.SettingOptions{
+						EnableInterruptionHandling:      lo.ToPtr(true),
+						InterruptionIgnoredMessageTypes: []string{"SpotInterruption", "RebalanceRecommendation"},
+					}),
+				}.InjectSettings(ctx)
+				node = coretest.Node(coretest.NodeOptions{
+					ObjectMeta: metav1.ObjectMeta{
+						Labels: map[string]string{
+							v1alpha5.ProvisionerNameLabelKey: "default",
+							v1.LabelTopologyZone:             "coretest-zone-1a",
+							v1.LabelInstanceTypeStable:       "t3.large",
+							v1alpha5.LabelCapacityType:       v1alpha1.CapacityTypeSpot,
+						},
+					},
+					ProviderID: makeProviderID(defaultInstanceID),
+				})
+			})
+			It("should delete a message of an ignored type without acting on the node", func() {
+				ExpectMessagesCreated(spotInterruptionMessage(defaultInstanceID))
+				ExpectApplied(ctx, env.Client, node)
+
+				ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+				ExpectNodeExists(ctx, env.Client, node.Name)
+				Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
+				Expect(unavailableOfferingsCache.IsUnavailable("t3.large", "coretest-zone-1a", v1alpha1.CapacityTypeSpot)).To(BeFalse())
+			})
+			It("should act on a message of a type that isn't ignored", func() {
+				ExpectMessagesCreated(stateChangeMessage(defaultInstanceID, "stopping"))
+				ExpectApplied(ctx, env.Client, node)
+
+				ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+				ExpectNotFound(ctx, env.Client, node)
+				Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
+			})
+			It("should only act on the messages of types that aren't ignored when both are received", func() {
+				onDemandNode := coretest.Node(coretest.NodeOptions{
+					ObjectMeta: metav1.ObjectMeta{
+						Labels: map[string]string{
+							v1alpha5.ProvisionerNameLabelKey: "default",
+							v1alpha5.LabelCapacityType:       v1alpha1.CapacityTypeOnDemand,
+						},
+					},
+					ProviderID: makeProviderID("i-0123456789abcdef1"),
+				})
+				ExpectMessagesCreated(spotInterruptionMessage(defaultInstanceID), scheduledChangeMessage("i-0123456789abcdef1"))
+				ExpectApplied(ctx, env.Client, node, onDemandNode)
+
+				ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+				ExpectNodeExists(ctx, env.Client, node.Name)
+				ExpectNotFound(ctx, env.Client, onDemandNode)
+				Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(2))
+			})
+		})
+		It("should delete a message without matching nodes immediately by default", func() {
+			msg := spotInterruptionMessage(defaultInstanceID)
+			ExpectMessagesCreated(msg)
+			fakeClock.SetTime(msg.Time.Add(time.Second))
+
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
+		})
+		It("should requeue a message without matching nodes until the grace period has passed", func() {
+			ctx = coretest.SettingsStore{
+				coresettings.ContextKey: coretest.Settings(),
+				settings.ContextKey: test.Settings(test.SettingOptions{
+					EnableInterruptionHandling:           lo.ToPtr(true),
+					InterruptionUnmatchedNodeBehavior:    lo.ToPtr(settings.RequeueMessage),
+					InterruptionUnmatchedNodeGracePeriod: lo.ToPtr(time.Minute * 2),
+				}),
+			}.InjectSettings(ctx)
+			msg := spotInterruptionMessage(defaultInstanceID)
+			ExpectMessagesCreated(msg)
+			fakeClock.SetTime(msg.Time.Add(time.Minute))
+
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(0))
+
+			fakeClock.SetTime(msg.Time.Add(time.Minute * 3))
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
+		})
+		It("should act on a requeued message once its node registers within the grace period", func() {
+			ctx = coretest.SettingsStore{
+				coresettings.ContextKey: coretest.Settings(),
+				settings.ContextKey: test.Settings(test.SettingOptions{
+					EnableInterruptionHandling:           lo.ToPtr(true),
+					InterruptionUnmatchedNodeBehavior:    lo.ToPtr(settings.RequeueMessage),
+					InterruptionUnmatchedNodeGracePeriod: lo.ToPtr(time.Minute * 2),
+				}),
+			}.InjectSettings(ctx)
+			msg := spotInterruptionMessage(defaultInstanceID)
+			ExpectMessagesCreated(msg)
+			fakeClock.SetTime(msg.Time.Add(time.Second * 10))
+
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(0))
+
+			node := coretest.Node(coretest.NodeOptions{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						v1alpha5.ProvisionerNameLabelKey: "default",
+					},
+				},
+				ProviderID: makeProviderID(defaultInstanceID),
+			})
+			ExpectApplied(ctx, env.Client, node)
+			fakeClock.SetTime(msg.Time.Add(time.Minute))
+
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			ExpectNotFound(ctx, env.Client, node)
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
+		})
+	})
+	Context("Metrics", func() {
+		var sent time.Time
+		BeforeEach(func() {
+			sent = time.Now().Truncate(time.Millisecond)
+			fakeClock.SetTime(sent.Add(time.Second * 30))
+		})
+		It("should record the queue latency of a message by its type", func() {
+			node := coretest.Node(coretest.NodeOptions{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						v1alpha5.ProvisionerNameLabelKey: "default",
+					},
+				},
+				ProviderID: makeProviderID(defaultInstanceID),
+			})
+			ExpectApplied(ctx, env.Client, node)
+			ExpectMessagesSentAt(sent, 1, scheduledChangeMessage(defaultInstanceID))
+			count, sum := ExpectQueueLatency(messages.ScheduledChangeKind)
+
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			ExpectNotFound(ctx, env.Client, node)
+			newCount, newSum := ExpectQueueLatency(messages.ScheduledChangeKind)
+			Expect(newCount).To(Equal(count + 1))
+			Expect(newSum - sum).To(BeNumerically("~", 30))
+		})
+		It("should record the queue latency of a message when its node isn't found", func() {
+			ExpectMessagesSentAt(sent, 1, spotInterruptionMessage(defaultInstanceID))
+			count, sum := ExpectQueueLatency(messages.SpotInterruptionKind)
+
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
+			newCount, newSum := ExpectQueueLatency(messages.SpotInterruptionKind)
+			Expect(newCount).To(Equal(count + 1))
+			Expect(newSum - sum).To(BeNumerically("~", 30))
+		})
+		It("should record the queue latency of a message of an ignored type", func() {
+			ctx = coretest.SettingsStore{
+				coresettings.ContextKey: coretest.Settings(),
+				settings.ContextKey: test.Settings(test.SettingOptions{
+					EnableInterruptionHandling:      lo.ToPtr(true),
+					InterruptionIgnoredMessageTypes: []string{"ScheduledChange"},
+				}),
+			}.InjectSettings(ctx)
+			ExpectMessagesSentAt(sent, 1, scheduledChangeMessage(defaultInstanceID))
+			count, _ := ExpectQueueLatency(messages.ScheduledChangeKind)
+
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			newCount, _ := ExpectQueueLatency(messages.ScheduledChangeKind)
+			Expect(newCount).To(Equal(count + 1))
+		})
+		It("should not record the queue latency of a message while its nodes are draining", func() {
+			node := coretest.Node(coretest.NodeOptions{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						v1alpha5.ProvisionerNameLabelKey: "default",
+					},
+					Finalizers: []string{v1alpha5.TerminationFinalizer},
+				},
+				ProviderID: makeProviderID(defaultInstanceID),
+			})
+			ExpectApplied(ctx, env.Client, node)
+			ExpectMessagesSentAt(sent, 1, spotInterruptionMessage(defaultInstanceID))
+			count, _ := ExpectQueueLatency(messages.SpotInterruptionKind)
+
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(sqsapi.ChangeVisibilityBehavior.SuccessfulCalls()).To(Equal(1))
+			newCount, _ := ExpectQueueLatency(messages.SpotInterruptionKind)
+			Expect(newCount).To(Equal(count))
+		})
+		It("should record the queue latency of a message once its nodes have drained", func() {
+			ExpectMessagesSentAt(sent, 2, spotInterruptionMessage(defaultInstanceID))
+			count, sum := ExpectQueueLatency(messages.SpotInterruptionKind)
+			fakeClock.SetTime(sent.Add(time.Minute * 5))
+
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
+			newCount, newSum := ExpectQueueLatency(messages.SpotInterruptionKind)
+			Expect(newCount).To(Equal(count + 1))
+			Expect(newSum - sum).To(BeNumerically("~", 300))
+		})
+		It("should not record the queue latency of a message without a sent timestamp", func() {
+			ExpectMessagesCreated(stateChangeMessage(defaultInstanceID, "stopping"))
+			count, _ := ExpectQueueLatency(messages.StateChangeKind)
+
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
+			newCount, _ := ExpectQueueLatency(messages.StateChangeKind)
+			Expect(newCount).To(Equal(count))
+		})
+	})
+	Context("Message Envelopes", func() {
+		var node *v1.Node
+		BeforeEach(func() {
+			node = coretest.Node(coretest.NodeOptions{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						v1alpha5.ProvisionerNameLabelKey: "default",
+					},
+				},
+				ProviderID: makeProviderID(defaultInstanceID),
+			})
+			ExpectApplied(ctx, env.Client, node)
+		})
+		withEventPath := func(path string) {
+			ctx = coretest.SettingsStore{
+				coresettings.ContextKey: coretest.Settings(),
+				settings.ContextKey: test.Settings(test.SettingOptions{
+					EnableInterruptionHandling: lo.ToPtr(true),
+					InterruptionEventPath:      lo.ToPtr(path),
+				}),
+			}.InjectSettings(ctx)
+		}
+		It("should handle events that an input transformer placed in a custom envelope", func() {
+			withEventPath("detail.event")
+			ExpectMessagesCreated(map[string]interface{}{
+				"cluster": "test-cluster",
+				"detail": map[string]interface{}{
+					"event": spotInterruptionMessage(defaultInstanceID),
+				},
+			})
+
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			ExpectNotFound(ctx, env.Client, node)
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
+		})
+		It("should handle events that are held as a JSON string in the envelope", func() {
+			withEventPath("Message")
+			ExpectMessagesCreated(map[string]interface{}{
+				"Type":    "Notification",
+				"Message": string(lo.Must(json.Marshal(spotInterruptionMessage(defaultInstanceID)))),
+			})
+
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			ExpectNotFound(ctx, env.Client, node)
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
+		})
+		It("should delete a message without acting on the node when the envelope doesn't hold an event", func() {
+			withEventPath("event")
+			ExpectMessagesCreated(spotInterruptionMessage(defaultInstanceID))
+
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			ExpectNodeExists(ctx, env.Client, node.Name)
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
+		})
+	})
+	Context("Sending Messages", func() {
+		It("should send messages that are received and parsed as the message that was sent", func() {
+			parser := interruption.NewEventParser(interruption.DefaultParsers...)
+			for _, msg := range []messages.Message{
+				spotInterruptionMessage(defaultInstanceID),
+				scheduledChangeMessage(defaultInstanceID),
+				stateChangeMessage(defaultInstanceID, "stopping"),
+			} {
+				id, err := sqsProvider.SendMessage(ctx, msg)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(id).ToNot(BeEmpty())
+				Expect(sqsapi.SendMessageBehavior.SuccessfulCalls()).To(Equal(1))
+				sqsapi.ReceiveMessageBehavior.Output.Set(&sqs.ReceiveMessageOutput{
+					Messages: []*sqs.Message{{
+						Body:      sqsapi.SendMessageBehavior.CalledWithInput.Pop().MessageBody,
+						MessageId: aws.String(id),
+					}},
+				})
+
+				received, err := sqsProvider.GetSQSMessages(ctx)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(received).To(HaveLen(1))
+				parsed, err := parser.Parse(aws.StringValue(received[0].Body))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(parsed.Kind()).To(Equal(msg.Kind()))
+				Expect(parsed.EC2InstanceIDs()).To(Equal(msg.EC2InstanceIDs()))
+				Expect(parsed.StartTime()).To(BeTemporally("==", msg.StartTime()))
+				sqsapi.Reset()
+			}
+		})
 	})
 	Context("Error Handling", func() {
 		It("should send an error on polling when AccessDenied", func() {
 			sqsapi.ReceiveMessageBehavior.Error.Set(awsErrWithCode(errors.AccessDeniedCode), fake.MaxCalls(0))
 			ExpectReconcileFailed(ctx, controller, types.NamespacedName{})
 		})
+		It("should back off exponentially without an error when receiving is throttled", func() {
+			sqsapi.ReceiveMessageBehavior.Error.Set(awsErrWithCode("RequestThrottled"), fake.MaxCalls(0))
+			Expect(ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{}).RequeueAfter).To(Equal(time.Second))
+			Expect(ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{}).RequeueAfter).To(Equal(time.Second * 2))
+			Expect(ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{}).RequeueAfter).To(Equal(time.Second * 4))
+			Expect(sqsapi.ReceiveMessageBehavior.FailedCalls()).To(Equal(3))
+		})
+		It("should cap the backoff at the interruptionReceiveMaxBackoff", func() {
+			ctx = coretest.SettingsStore{
+				coresettings.ContextKey: coretest.Settings(),
+				settings.ContextKey: test.Settings(test.SettingOptions{
+					EnableInterruptionHandling:    lo.ToPtr(true),
+					InterruptionReceiveMaxBackoff: lo.ToPtr(time.Second * 3),
+				}),
+			}.InjectSettings(ctx)
+			sqsapi.ReceiveMessageBehavior.Error.Set(awsErrWithCode("RequestThrottled"), fake.MaxCalls(0))
+			Expect(ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{}).RequeueAfter).To(Equal(time.Second))
+			Expect(ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{}).RequeueAfter).To(Equal(time.Second * 2))
+			Expect(ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{}).RequeueAfter).To(Equal(time.Second * 3))
+			Expect(ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{}).RequeueAfter).To(Equal(time.Second * 3))
+		})
+		It("should process messages and reset the backoff once receiving is no longer throttled", func() {
+			node := coretest.Node(coretest.NodeOptions{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						v1alpha5.ProvisionerNameLabelKey: "default",
+					},
+				},
+				ProviderID: makeProviderID(defaultInstanceID),
+			})
+			ExpectMessagesCreated(spotInterruptionMessage(defaultInstanceID))
+			ExpectApplied(ctx, env.Client, node)
+
+			sqsapi.ReceiveMessageBehavior.Error.Set(awsErrWithCode("RequestThrottled"), fake.MaxCalls(2))
+			Expect(ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{}).RequeueAfter).To(Equal(time.Second))
+			Expect(ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{}).RequeueAfter).To(Equal(time.Second * 2))
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			ExpectNotFound(ctx, env.Client, node)
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
+
+			sqsapi.ReceiveMessageBehavior.Error.Reset()
+			sqsapi.ReceiveMessageBehavior.Error.Set(awsErrWithCode("RequestThrottled"))
+			Expect(ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{}).RequeueAfter).To(Equal(time.Second))
+		})
 		It("should send an error on polling when QueueDeletedRecently", func() {
 			sqsapi.GetQueueURLBehavior.Error.Set(awsErrWithCode(sqs.ErrCodeQueueDeletedRecently), fake.MaxCalls(0))
 			ExpectReconcileFailed(ctx, controller, types.NamespacedName{})
 		})
+		It("should retry listing nodes when the api-server fails transiently", func() {
+			node := coretest.Node(coretest.NodeOptions{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						v1alpha5.ProvisionerNameLabelKey: "default",
+					},
+				},
+				ProviderID: makeProviderID(defaultInstanceID),
+			})
+			ExpectMessagesCreated(spotInterruptionMessage(defaultInstanceID))
+			ExpectApplied(ctx, env.Client, node)
+
+			kubeClient := &failingListClient{Client: env.Client, err: apierrors.NewServiceUnavailable("unavailable"), failures: 2}
+			controller = interruption.NewController(kubeClient, fakeClock, recorder, sqsProvider, unavailableOfferingsCache)
+			ExpectReconcileSucceeded(ctx, controller, types.NamespacedName{})
+			ExpectNotFound(ctx, env.Client, node)
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(1))
+		})
+		It("should keep the message when the api-server keeps failing to list nodes", func() {
+			node := coretest.Node(coretest.NodeOptions{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						v1alpha5.ProvisionerNameLabelKey: "default",
+					},
+				},
+				ProviderID: makeProviderID(defaultInstanceID),
+			})
+			ExpectMessagesCreated(spotInterruptionMessage(defaultInstanceID))
+			ExpectApplied(ctx, env.Client, node)
+
+			kubeClient := &failingListClient{Client: env.Client, err: apierrors.NewServiceUnavailable("unavailable"), failures: 10}
+			controller = interruption.NewController(kubeClient, fakeClock, recorder, sqsProvider, unavailableOfferingsCache)
+			ExpectReconcileFailed(ctx, controller, types.NamespacedName{})
+			Expect(env.Client.Get(ctx, client.ObjectKeyFromObject(node), &v1.Node{})).To(Succeed())
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(0))
+		})
+		It("should not retry listing nodes when the api-server error isn't transient", func() {
+			ExpectMessagesCreated(spotInterruptionMessage(defaultInstanceID))
+
+			kubeClient := &failingListClient{Client: env.Client, err: apierrors.NewForbidden(v1.Resource("nodes"), "", fmt.Errorf("")), failures: 1}
+			controller = interruption.NewController(kubeClient, fakeClock, recorder, sqsProvider, unavailableOfferingsCache)
+			ExpectReconcileFailed(ctx, controller, types.NamespacedName{})
+			Expect(kubeClient.calls).To(Equal(1))
+			Expect(sqsapi.DeleteMessageBehavior.SuccessfulCalls()).To(Equal(0))
+		})
 	})
 })
 
@@ -299,76 +911,77 @@ func ExpectMessagesCreated(messages ...interface{}) {
 	)
 }
 
+// ExpectMessagesSentAt creates SQS messages with a SentTimestamp attribute of the passed time, which have been received
+// the passed number of times, including this time
+func ExpectMessagesSentAt(sent time.Time, receiveCount int, messages ...interface{}) {
+	raw := lo.Map(messages, func(m interface{}, _ int) *sqs.Message {
+		return &sqs.Message{
+			Body:      aws.String(string(lo.Must(json.Marshal(m)))),
+			MessageId: aws.String(string(uuid.NewUUID())),
+			Attributes: map[string]*string{
+				sqs.MessageSystemAttributeNameSentTimestamp:           aws.String(fmt.Sprint(sent.UnixMilli())),
+				sqs.MessageSystemAttributeNameApproximateReceiveCount: aws.String(fmt.Sprint(receiveCount)),
+			},
+		}
+	})
+	sqsapi.ReceiveMessageBehavior.Output.Set(
+		&sqs.ReceiveMessageOutput{
+			Messages: raw,
+		},
+	)
+}
+
+// ExpectQueueLatency returns the sample count and sum of the queue latency histogram for the message kind
+func ExpectQueueLatency(kind messages.Kind) (uint64, float64) {
+	families, err := crmetrics.Registry.Gather()
+	ExpectWithOffset(1, err).ToNot(HaveOccurred())
+	family, ok := lo.Find(families, func(f *dto.MetricFamily) bool {
+		return f.GetName() == "karpenter_interruption_message_queue_latency_time_seconds"
+	})
+	if !ok {
+		return 0, 0
+	}
+	metric, ok := lo.Find(family.GetMetric(), func(m *dto.Metric) bool {
+		return lo.ContainsBy(m.GetLabel(), func(l *dto.LabelPair) bool {
+			return l.GetName() == "message_type" && l.GetValue() == string(kind)
+		})
+	})
+	if !ok {
+		return 0, 0
+	}
+	return metric.GetHistogram().GetSampleCount(), metric.GetHistogram().GetSampleSum()
+}
+
+// failingListClient fails the first calls to List with the passed error and then passes calls through to the Client
+type failingListClient struct {
+	client.Client
+	err      error
+	failures int
+	calls    int
+}
+
+func (c *failingListClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	c.calls++
+	if c.calls <= c.failures {
+		return c.err
+	}
+	return c.Client.List(ctx, list, opts...)
+}
+
 func awsErrWithCode(code string) awserr.Error {
 	return awserr.New(code, "", fmt.Errorf(""))
 }
 
 func spotInterruptionMessage(involvedInstanceID string) spotinterruption.Message {
-	return spotinterruption.Message{
-		Metadata: messages.Metadata{
-			Version:    "0",
-			Account:    defaultAccountID,
-			DetailType: "EC2 Spot Instance Interruption Warning",
-			ID:         string(uuid.NewUUID()),
-			Region:     defaultRegion,
-			Resources: []string{
-				fmt.Sprintf("arn:aws:ec2:%s:instance/%s", defaultRegion, involvedInstanceID),
-			},
-			Source: ec2Source,
-			Time:   time.Now(),
-		},
-		Detail: spotinterruption.Detail{
-			InstanceID:     involvedInstanceID,
-			InstanceAction: "terminate",
-		},
-	}
+	return spotinterruption.NewMessage(defaultRegion, defaultAccountID, involvedInstanceID)
 }
 
 func stateChangeMessage(involvedInstanceID, state string) statechange.Message {
-	return statechange.Message{
-		Metadata: messages.Metadata{
-			Version:    "0",
-			Account:    defaultAccountID,
-			DetailType: "EC2 Instance State-change Notification",
-			ID:         string(uuid.NewUUID()),
-			Region:     defaultRegion,
-			Resources: []string{
-				fmt.Sprintf("arn:aws:ec2:%s:instance/%s", defaultRegion, involvedInstanceID),
-			},
-			Source: ec2Source,
-			Time:   time.Now(),
-		},
-		Detail: statechange.Detail{
-			InstanceID: involvedInstanceID,
-			State:      state,
-		},
-	}
+	return statechange.NewMessage(defaultRegion, defaultAccountID, involvedInstanceID, state)
 }
 
 func scheduledChangeMessage(involvedInstanceID string) scheduledchange.Message {
-	return scheduledchange.Message{
-		Metadata: messages.Metadata{
-			Version:    "0",
-			Account:    defaultAccountID,
-			DetailType: "AWS Health Event",
-			ID:         string(uuid.NewUUID()),
-			Region:     defaultRegion,
-			Resources: []string{
-				fmt.Sprintf("arn:aws:ec2:%s:instance/%s", defaultRegion, involvedInstanceID),
-			},
-			Source: healthSource,
-			Time:   time.Now(),
-		},
-		Detail: scheduledchange.Detail{
-			Service:           "EC2",
-			EventTypeCategory: "scheduledChange",
-			AffectedEntities: []scheduledchange.AffectedEntity{
-				{
-					EntityValue: involvedInstanceID,
-				},
-			},
-		},
-	}
+	return scheduledchange.NewMessage(defaultRegion, defaultAccountID, involvedInstanceID)
 }
 
 func makeProviderID(instanceID string) string {
@@ -0,0 +1,150 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodetemplate
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/samber/lo"
+	"go.uber.org/multierr"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"knative.dev/pkg/logging"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/aws/karpenter-core/pkg/events"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	awscache "github.com/aws/karpenter/pkg/cache"
+	"github.com/aws/karpenter/pkg/cloudprovider/amifamily"
+	awscontext "github.com/aws/karpenter/pkg/context"
+)
+
+// amiRecheckPeriod is how often the default AMIs are resolved from SSM again, since new AMIs are published to SSM
+// without the AWSNodeTemplate changing
+const amiRecheckPeriod = 5 * time.Minute
+
+// AMIReconciler resolves the SSM queries that nodes of the AWSNodeTemplate were launched with again. It pins their
+// AMIs and records whether they could be resolved in the status of the AWSNodeTemplate, and publishes an AMIChanged
+// event when the default AMI of a query changes. Launching nodes only reads the pinned AMIs and records the queries,
+// so that it doesn't write to the AWSNodeTemplate.
+type AMIReconciler struct {
+	kubeClient  client.Client
+	amiProvider *amifamily.AMIProvider
+	ssmQueries  *awscache.SSMQueries
+	recorder    events.Recorder
+	// key: <nodeTemplate>, value: the AMI that each SSM query last resolved to
+	resolved *cache.Cache
+}
+
+func NewAMIReconciler(kubeClient client.Client, amiProvider *amifamily.AMIProvider, ssmQueries *awscache.SSMQueries, recorder events.Recorder) *AMIReconciler {
+	return &AMIReconciler{
+		kubeClient:  kubeClient,
+		amiProvider: amiProvider,
+		ssmQueries:  ssmQueries,
+		recorder:    recorder,
+		resolved:    cache.New(awscache.SSMQueriesTTL, awscontext.CacheCleanupInterval),
+	}
+}
+
+func (r *AMIReconciler) Reconcile(ctx context.Context, nodeTemplate *v1alpha1.AWSNodeTemplate) (reconcile.Result, error) {
+	if !nodeTemplate.DeletionTimestamp.IsZero() {
+		r.ssmQueries.Delete(nodeTemplate.Name)
+		r.resolved.Delete(nodeTemplate.Name)
+		return reconcile.Result{}, nil
+	}
+	merged, ok := mergedTemplate(ctx, r.kubeClient, nodeTemplate)
+	if !ok {
+		return reconcile.Result{}, nil
+	}
+	// Only the default AMIs are resolved from SSM
+	if len(merged.Spec.AMISelectors()) != 0 || merged.Spec.LaunchTemplateName != nil {
+		return reconcile.Result{}, nil
+	}
+	pinning := lo.FromPtr(merged.Spec.PinAMI)
+	rollforward := nodeTemplate.Annotations[v1alpha1.AnnotationAMIRollforward] != nodeTemplate.Status.AMIRollforward
+	ssmQueries := r.ssmQueries.List(nodeTemplate.Name)
+	if pinning {
+		// A rollforward replaces the pins of queries that nodes haven't been launched with recently as well
+		ssmQueries = lo.Uniq(append(ssmQueries, lo.Keys(nodeTemplate.Status.PinnedAMIs)...))
+		sort.Strings(ssmQueries)
+	}
+	if len(ssmQueries) == 0 {
+		return reconcile.Result{RequeueAfter: amiRecheckPeriod}, nil
+	}
+	resolved, ssmErrs := r.resolve(ctx, nodeTemplate, ssmQueries)
+	// Launches don't resolve the queries whose AMIs are pinned, so they can't fail
+	if pinning && !rollforward {
+		ssmErrs = lo.OmitByKeys(ssmErrs, lo.Keys(nodeTemplate.Status.PinnedAMIs))
+	}
+	if len(ssmErrs) == 0 {
+		nodeTemplate.StatusConditions().MarkTrue(v1alpha1.AMIReady)
+	} else {
+		// The queries are sorted, so that the message is stable
+		var errs error
+		for _, ssmQuery := range ssmQueries {
+			errs = multierr.Append(errs, ssmErrs[ssmQuery])
+		}
+		nodeTemplate.StatusConditions().MarkFalse(v1alpha1.AMIReady, "SSMResolutionFailed", "%s", errs)
+	}
+	// A rollforward waits until every query could be resolved, nodes are launched with the default AMIs until then
+	if pinning && (!rollforward || len(ssmErrs) == 0) {
+		r.pin(ctx, nodeTemplate, resolved, rollforward)
+	}
+	return reconcile.Result{RequeueAfter: amiRecheckPeriod}, nil
+}
+
+// resolve returns the AMIs that the SSM queries resolve to, and publishes an AMIChanged event for each query whose
+// AMI changed since it was last resolved
+func (r *AMIReconciler) resolve(ctx context.Context, nodeTemplate *v1alpha1.AWSNodeTemplate, ssmQueries []string) (map[string]string, map[string]error) {
+	previous := map[string]string{}
+	if stored, ok := r.resolved.Get(nodeTemplate.Name); ok {
+		previous = stored.(map[string]string)
+	}
+	resolved := map[string]string{}
+	ssmErrs := map[string]error{}
+	for _, ssmQuery := range ssmQueries {
+		amiID, err := r.amiProvider.Resolve(ctx, ssmQuery)
+		if err != nil {
+			ssmErrs[ssmQuery] = err
+			continue
+		}
+		if previousAMIID, ok := previous[ssmQuery]; ok && previousAMIID != amiID {
+			logging.FromContext(ctx).With("awsnodetemplate", nodeTemplate.Name).Infof("Resolved ami for query %q changed from %s to %s", ssmQuery, previousAMIID, amiID)
+			r.recorder.Publish(amifamily.AMIChanged(nodeTemplate, ssmQuery, previousAMIID, amiID))
+		}
+		resolved[ssmQuery] = amiID
+	}
+	// The previous AMIs of queries that failed are kept, so that the change is still reported once SSM recovers
+	r.resolved.SetDefault(nodeTemplate.Name, lo.Assign(lo.PickByKeys(previous, lo.Keys(ssmErrs)), resolved))
+	return resolved, ssmErrs
+}
+
+// pin records the resolved AMIs of the queries that aren't pinned yet in the AWSNodeTemplate status. A rollforward
+// replaces all previously pinned AMIs.
+func (r *AMIReconciler) pin(ctx context.Context, nodeTemplate *v1alpha1.AWSNodeTemplate, resolved map[string]string, rollforward bool) {
+	pinned := nodeTemplate.Status.PinnedAMIs
+	if rollforward {
+		nodeTemplate.Status.PinnedAMIs = resolved
+		nodeTemplate.Status.AMIRollforward = nodeTemplate.Annotations[v1alpha1.AnnotationAMIRollforward]
+	} else {
+		nodeTemplate.Status.PinnedAMIs = lo.Assign(resolved, pinned)
+	}
+	if !equality.Semantic.DeepEqual(pinned, nodeTemplate.Status.PinnedAMIs) {
+		logging.FromContext(ctx).With("awsnodetemplate", nodeTemplate.Name).Infof("Pinned amis %v", lo.Uniq(lo.Values(nodeTemplate.Status.PinnedAMIs)))
+	}
+}
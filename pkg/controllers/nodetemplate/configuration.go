@@ -0,0 +1,168 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodetemplate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/mitchellh/hashstructure/v2"
+	"github.com/samber/lo"
+	"knative.dev/pkg/logging"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/aws/karpenter-core/pkg/utils/pretty"
+	awssettings "github.com/aws/karpenter/pkg/apis/config/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/cloudprovider"
+	"github.com/aws/karpenter/pkg/cloudprovider/amifamily"
+)
+
+// configurationRecheckPeriod is how often the effective configuration is resolved again, since the subnets, security
+// groups and AMIs that the selectors match can change in EC2 without the AWSNodeTemplate changing
+const configurationRecheckPeriod = 5 * time.Minute
+
+// ConfigurationReconciler records the configuration that nodes are launched with in the status of the
+// AWSNodeTemplate, so that the result of merging its base templates and resolving its selectors can be inspected
+type ConfigurationReconciler struct {
+	kubeClient            client.Client
+	subnetProvider        *cloudprovider.SubnetProvider
+	securityGroupProvider *cloudprovider.SecurityGroupProvider
+	amiProvider           *amifamily.AMIProvider
+	cm                    *pretty.ChangeMonitor
+}
+
+func NewConfigurationReconciler(kubeClient client.Client, subnetProvider *cloudprovider.SubnetProvider,
+	securityGroupProvider *cloudprovider.SecurityGroupProvider, amiProvider *amifamily.AMIProvider) *ConfigurationReconciler {
+	return &ConfigurationReconciler{
+		kubeClient:            kubeClient,
+		subnetProvider:        subnetProvider,
+		securityGroupProvider: securityGroupProvider,
+		amiProvider:           amiProvider,
+		cm:                    pretty.NewChangeMonitor(),
+	}
+}
+
+func (r *ConfigurationReconciler) Reconcile(ctx context.Context, nodeTemplate *v1alpha1.AWSNodeTemplate) (reconcile.Result, error) {
+	if !nodeTemplate.DeletionTimestamp.IsZero() {
+		return reconcile.Result{}, nil
+	}
+	merged, ok := mergedTemplate(ctx, r.kubeClient, nodeTemplate)
+	if !ok {
+		return reconcile.Result{}, nil
+	}
+	configuration, err := r.resolve(ctx, merged)
+	if err != nil {
+		// The last configuration is kept, since the failure is likely transient
+		logging.FromContext(ctx).With("awsnodetemplate", nodeTemplate.Name).Errorf("Resolving the effective configuration, %s", err)
+		return reconcile.Result{RequeueAfter: time.Minute}, nil
+	}
+	// The status is only patched when it changes, so only log the configuration when it changes as well
+	if r.cm.HasChanged(nodeTemplate.Name, configuration) {
+		logging.FromContext(ctx).With("awsnodetemplate", nodeTemplate.Name).Debugf("Resolved effective configuration %s", pretty.Concise(configuration))
+	}
+	nodeTemplate.Status.EffectiveConfiguration = configuration
+	if err = r.updateAMISelectionCondition(ctx, nodeTemplate, merged); err != nil {
+		logging.FromContext(ctx).With("awsnodetemplate", nodeTemplate.Name).Errorf("Checking the amis for ambiguity, %s", err)
+		return reconcile.Result{RequeueAfter: time.Minute}, nil
+	}
+	return reconcile.Result{RequeueAfter: configurationRecheckPeriod}, nil
+}
+
+// updateAMISelectionCondition records whether any of the AMIs that the amiSelector of the merged AWSNodeTemplate selects
+// have the same requirements, e.g. "ami-a" and "ami-b" for arm64 without a tag that tells them apart, in which case
+// only the newest of them is launched. The condition is removed from AWSNodeTemplates that don't select AMIs.
+func (r *ConfigurationReconciler) updateAMISelectionCondition(ctx context.Context, nodeTemplate *v1alpha1.AWSNodeTemplate, merged *v1alpha1.AWSNodeTemplate) error {
+	if len(merged.Spec.AMISelectors()) == 0 || merged.Spec.LaunchTemplateName != nil {
+		return nodeTemplate.StatusConditions().ClearCondition(v1alpha1.AMISelectionUnambiguous)
+	}
+	ambiguous, err := r.amiProvider.Ambiguous(ctx, merged)
+	if err != nil {
+		return err
+	}
+	if len(ambiguous) == 0 {
+		nodeTemplate.StatusConditions().MarkTrue(v1alpha1.AMISelectionUnambiguous)
+		return nil
+	}
+	nodeTemplate.StatusConditions().MarkFalse(v1alpha1.AMISelectionUnambiguous, "AmbiguousAMIs", "%s", strings.Join(lo.Map(ambiguous, func(amiIDs []string, _ int) string {
+		return fmt.Sprintf("amis %s have the same requirements, %s is used since it's the newest", strings.Join(amiIDs, ", "), amiIDs[0])
+	}), "; "))
+	return nil
+}
+
+// resolve returns the effective configuration of the merged AWSNodeTemplate. The IDs are sorted, so that the status
+// doesn't change when EC2 returns them in a different order.
+func (r *ConfigurationReconciler) resolve(ctx context.Context, nodeTemplate *v1alpha1.AWSNodeTemplate) (*v1alpha1.EffectiveConfiguration, error) {
+	provider := &nodeTemplate.Spec.AWS
+	configuration := &v1alpha1.EffectiveConfiguration{
+		AMIFamily:          lo.FromPtrOr(provider.AMIFamily, v1alpha1.AMIFamilyAL2),
+		LaunchTemplateName: lo.FromPtr(provider.LaunchTemplateName),
+	}
+	if provider.SubnetSelector != nil {
+		subnets, err := r.subnetProvider.Get(ctx, provider)
+		if err != nil {
+			return nil, err
+		}
+		configuration.Subnets = lo.Map(subnets, func(subnet *ec2.Subnet, _ int) string { return aws.StringValue(subnet.SubnetId) })
+		sort.Strings(configuration.Subnets)
+	}
+	// The rest of the configuration is part of a launch template that's specified directly
+	if provider.LaunchTemplateName != nil {
+		return configuration, nil
+	}
+	securityGroups, err := r.securityGroupProvider.List(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+	configuration.SecurityGroups = lo.Map(securityGroups, func(securityGroup *ec2.SecurityGroup, _ int) string { return aws.StringValue(securityGroup.GroupId) })
+	sort.Strings(configuration.SecurityGroups)
+	if configuration.AMIs, err = r.amis(ctx, nodeTemplate); err != nil {
+		return nil, err
+	}
+	configuration.InstanceProfile = lo.FromPtrOr(provider.InstanceProfile, awssettings.FromContext(ctx).DefaultInstanceProfile)
+	configuration.BlockDeviceMappings = provider.BlockDeviceMappings
+	if configuration.BlockDeviceMappings == nil {
+		configuration.BlockDeviceMappings = amifamily.GetAMIFamily(provider.AMIFamily, &amifamily.Options{}).DefaultBlockDeviceMappings()
+	}
+	// The user data may contain secrets, so it's only recorded as a hash
+	if nodeTemplate.Spec.UserData != nil {
+		hash, err := hashstructure.Hash(*nodeTemplate.Spec.UserData, hashstructure.FormatV2, nil)
+		if err != nil {
+			return nil, err
+		}
+		configuration.UserDataHash = fmt.Sprint(hash)
+	}
+	return configuration, nil
+}
+
+// amis returns the AMIs selected by the amiSelector or, without one, the pinned AMIs. The default AMIs that aren't
+// pinned depend on the instance types and are resolved when nodes are launched.
+func (r *ConfigurationReconciler) amis(ctx context.Context, nodeTemplate *v1alpha1.AWSNodeTemplate) ([]string, error) {
+	if len(nodeTemplate.Spec.AMISelectors()) != 0 {
+		return r.amiProvider.Discover(ctx, nodeTemplate)
+	}
+	if !lo.FromPtr(nodeTemplate.Spec.PinAMI) || len(nodeTemplate.Status.PinnedAMIs) == 0 {
+		return nil, nil
+	}
+	amis := lo.Uniq(lo.Values(nodeTemplate.Status.PinnedAMIs))
+	sort.Strings(amis)
+	return amis, nil
+}
@@ -22,17 +22,24 @@ import (
 	"github.com/samber/lo"
 	"go.uber.org/multierr"
 	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/utils/clock"
 	"knative.dev/pkg/logging"
 	controllerruntime "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
+	"github.com/aws/karpenter-core/pkg/events"
 	corecontroller "github.com/aws/karpenter-core/pkg/operator/controller"
 	"github.com/aws/karpenter-core/pkg/operator/scheme"
 	"github.com/aws/karpenter-core/pkg/utils/result"
 	"github.com/aws/karpenter/pkg/apis"
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	awscache "github.com/aws/karpenter/pkg/cache"
+	"github.com/aws/karpenter/pkg/cloudprovider"
+	"github.com/aws/karpenter/pkg/cloudprovider/amifamily"
 	"github.com/aws/karpenter/pkg/controllers/providers"
 )
 
@@ -49,13 +56,22 @@ type Controller struct {
 	kubeClient     client.Client
 	finalizer      *FinalizerReconciler
 	infrastructure *InfrastructureReconciler
+	inheritance    *InheritanceReconciler
+	network        *NetworkReconciler
+	configuration  *ConfigurationReconciler
+	ami            *AMIReconciler
 }
 
-func NewController(kubeClient client.Client, sqsProvider *providers.SQS, eventBridgeProvider *providers.EventBridge) *Controller {
+func NewController(kubeClient client.Client, clk clock.Clock, recorder events.Recorder, sqsProvider *providers.SQS, eventBridgeProvider *providers.EventBridge,
+	subnetProvider *cloudprovider.SubnetProvider, securityGroupProvider *cloudprovider.SecurityGroupProvider, amiProvider *amifamily.AMIProvider, ssmQueries *awscache.SSMQueries) *Controller {
 	return &Controller{
 		kubeClient:     kubeClient,
 		finalizer:      NewFinalizerReconciler(),
-		infrastructure: NewInfrastructureReconciler(kubeClient, sqsProvider, eventBridgeProvider),
+		infrastructure: NewInfrastructureReconciler(kubeClient, clk, sqsProvider, eventBridgeProvider),
+		inheritance:    NewInheritanceReconciler(kubeClient),
+		network:        NewNetworkReconciler(kubeClient, subnetProvider, securityGroupProvider),
+		configuration:  NewConfigurationReconciler(kubeClient, subnetProvider, securityGroupProvider, amiProvider),
+		ami:            NewAMIReconciler(kubeClient, amiProvider, ssmQueries, recorder),
 	}
 }
 
@@ -74,6 +90,10 @@ func (c *Controller) Reconcile(ctx context.Context, req reconcile.Request) (reco
 		Reconcile(context.Context, *v1alpha1.AWSNodeTemplate) (reconcile.Result, error)
 	}{
 		c.infrastructure,
+		c.inheritance,
+		c.network,
+		c.configuration,
+		c.ami,
 		c.finalizer,
 	} {
 		res, err := r.Reconcile(ctx, nodeTemplate)
@@ -84,7 +104,13 @@ func (c *Controller) Reconcile(ctx context.Context, req reconcile.Request) (reco
 	if errs != nil {
 		return reconcile.Result{}, errs
 	}
-	if !equality.Semantic.DeepEqual(nodeTemplate, stored) {
+	// The status is a subresource, so it's patched separately from the rest of the AWSNodeTemplate
+	if !equality.Semantic.DeepEqual(nodeTemplate.Status, stored.Status) {
+		if err := c.kubeClient.Status().Patch(ctx, nodeTemplate.DeepCopy(), client.MergeFrom(stored)); err != nil {
+			return reconcile.Result{}, fmt.Errorf("patching AWSNodeTemplate status, %w", err)
+		}
+	}
+	if !equality.Semantic.DeepEqual(nodeTemplate.ObjectMeta, stored.ObjectMeta) || !equality.Semantic.DeepEqual(nodeTemplate.Spec, stored.Spec) {
 		if err := c.kubeClient.Patch(ctx, nodeTemplate, client.MergeFrom(stored)); err != nil {
 			return reconcile.Result{}, fmt.Errorf("patching AWSNodeTemplate, %w", err)
 		}
@@ -96,7 +122,11 @@ func (c *Controller) Builder(_ context.Context, m manager.Manager) corecontrolle
 	return controllerruntime.
 		NewControllerManagedBy(m).
 		Named(Name).
-		For(&v1alpha1.AWSNodeTemplate{})
+		For(&v1alpha1.AWSNodeTemplate{}).
+		Watches(
+			&source.Kind{Type: &v1alpha1.AWSNodeTemplate{}},
+			handler.EnqueueRequestsFromMapFunc(c.inheritance.inheritingTemplates),
+		)
 }
 
 func (c *Controller) LivenessProbe(_ *http.Request) error {
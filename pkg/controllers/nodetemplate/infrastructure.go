@@ -16,11 +16,13 @@ package nodetemplate
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"go.uber.org/multierr"
 	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/clock"
 	"knative.dev/pkg/logging"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -29,20 +31,31 @@ import (
 	awssettings "github.com/aws/karpenter/pkg/apis/config/settings"
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
 	"github.com/aws/karpenter/pkg/controllers/providers"
-	"github.com/aws/karpenter/pkg/errors"
+	awserrors "github.com/aws/karpenter/pkg/errors"
 )
 
 type InfrastructureReconciler struct {
 	kubeClient          client.Client
+	clk                 clock.Clock
 	sqsProvider         *providers.SQS
 	eventBridgeProvider *providers.EventBridge
 
 	lastInfrastructureReconcile time.Time // Keeps track of the last reconcile time for infra, so we don't keep calling APIs
 }
 
-func NewInfrastructureReconciler(kubeClient client.Client, sqsProvider *providers.SQS, eventBridgeProvider *providers.EventBridge) *InfrastructureReconciler {
+// queueAttributesError is returned when the queue exists, but its attributes couldn't be configured
+type queueAttributesError struct {
+	error
+}
+
+func (e queueAttributesError) Unwrap() error {
+	return e.error
+}
+
+func NewInfrastructureReconciler(kubeClient client.Client, clk clock.Clock, sqsProvider *providers.SQS, eventBridgeProvider *providers.EventBridge) *InfrastructureReconciler {
 	return &InfrastructureReconciler{
 		kubeClient:          kubeClient,
+		clk:                 clk,
 		sqsProvider:         sqsProvider,
 		eventBridgeProvider: eventBridgeProvider,
 	}
@@ -52,6 +65,7 @@ func NewInfrastructureReconciler(kubeClient client.Client, sqsProvider *provider
 // the infrastructure by ref-counting when the last AWSNodeTemplate is removed
 func (i *InfrastructureReconciler) Reconcile(ctx context.Context, nodeTemplate *v1alpha1.AWSNodeTemplate) (reconcile.Result, error) {
 	if !awssettings.FromContext(ctx).EnableInterruptionHandling {
+		nodeTemplate.StatusConditions().MarkTrueWithReason(v1alpha1.QueueReady, "InterruptionHandlingDisabled", "Interruption handling is disabled")
 		// TODO: Implement an alerting mechanism for settings updates; until then, just poll
 		return reconcile.Result{RequeueAfter: time.Second * 10}, nil
 	}
@@ -60,27 +74,59 @@ func (i *InfrastructureReconciler) Reconcile(ctx context.Context, nodeTemplate *
 		return reconcile.Result{}, err
 	}
 	if !nodeTemplate.DeletionTimestamp.IsZero() && len(list.Items) == 1 {
-		if err := i.DeleteInfrastructure(ctx); err != nil {
+		if err := measureReconcile(ctx, i.DeleteInfrastructure); err != nil {
 			return reconcile.Result{}, err
 		}
 		i.lastInfrastructureReconcile = time.Time{}
 		return reconcile.Result{}, nil
 	} else if len(list.Items) >= 1 {
-		if i.lastInfrastructureReconcile.Add(time.Minute * 5).Before(time.Now()) {
-			if err := i.CreateInfrastructure(ctx); err != nil {
-				if errors.IsRecentlyDeleted(err) {
+		// The infrastructure is re-verified periodically, so that it's recreated if it's changed or deleted out of band
+		if !i.clk.Now().Before(i.lastInfrastructureReconcile.Add(awssettings.FromContext(ctx).InterruptionInfrastructureInterval.Duration)) {
+			if err := measureReconcile(ctx, i.CreateInfrastructure); err != nil {
+				nodeTemplate.StatusConditions().MarkFalse(v1alpha1.QueueReady, "QueueNotReconciled", "%s", err)
+				if awserrors.IsRecentlyDeleted(err) {
 					logging.FromContext(ctx).Errorf("Interruption queue recently deleted, retrying after one minute")
 					return reconcile.Result{RequeueAfter: time.Minute}, nil
 				}
+				// A queue whose attributes aren't configured is retried on the next reconcile, rather than with
+				// backoff, so that the condition is recorded on the node template
+				if errors.As(err, &queueAttributesError{}) {
+					nodeTemplate.StatusConditions().MarkFalse(v1alpha1.QueueReady, "AttributesNotConfigured", "%s", err)
+					logging.FromContext(ctx).Errorf("Configuring the interruption queue attributes, %s", err)
+					return reconcile.Result{RequeueAfter: time.Second * 10}, nil
+				}
 				return reconcile.Result{}, err
 			}
-			i.lastInfrastructureReconcile = time.Now()
+			i.lastInfrastructureReconcile = i.clk.Now()
 		}
+		// The infrastructure is only re-verified periodically, and is retried until the last verification succeeded
+		nodeTemplate.StatusConditions().MarkTrue(v1alpha1.QueueReady)
 	}
 	// TODO: Implement an alerting mechanism for settings updates; until then, just poll
 	return reconcile.Result{RequeueAfter: time.Second * 10}, nil
 }
 
+// measureReconcile records the duration and the result of creating or deleting the infrastructure
+func measureReconcile(ctx context.Context, fn func(context.Context) error) error {
+	defer metrics.Measure(infrastructureReconcileDuration)()
+	err := fn(ctx)
+	infrastructureReconciles.WithLabelValues(reconcileResult(err)).Inc()
+	return err
+}
+
+func reconcileResult(err error) string {
+	switch {
+	case err == nil:
+		return resultSuccess
+	case awserrors.IsAccessDenied(err):
+		return resultAccessDenied
+	case awserrors.IsRecentlyDeleted(err):
+		return resultQueueDeletedRecently
+	default:
+		return resultError
+	}
+}
+
 // CreateInfrastructure provisions an SQS queue and EventBridge rules to enable interruption handling
 func (i *InfrastructureReconciler) CreateInfrastructure(ctx context.Context) error {
 	defer metrics.Measure(infrastructureCreateDuration)()
@@ -129,10 +175,17 @@ func (i *InfrastructureReconciler) ensureQueue(ctx context.Context) error {
 		if err := i.sqsProvider.CreateQueue(ctx); err != nil {
 			return fmt.Errorf("creating the SQS interruption queue with policy, %w", err)
 		}
+	} else if err := i.sqsProvider.TagQueue(ctx); err != nil {
+		// The queue is only tagged on creation, so reconcile the tags of an existing queue in case they've drifted
+		return fmt.Errorf("tagging the SQS interruption queue, %w", err)
 	}
-	// Always attempt to set the queue attributes, even after creation to help set the queue policy
+	// Always attempt to set the queue attributes, even after creation to help set the queue policy. The queue is only
+	// ready once the attributes are confirmed, so that a queue that's created without them is configured on retry.
 	if err := i.sqsProvider.SetQueueAttributes(ctx, nil); err != nil {
-		return fmt.Errorf("setting queue attributes for interruption queue, %w", err)
+		return queueAttributesError{fmt.Errorf("setting queue attributes for interruption queue, %w", err)}
+	}
+	if err := i.sqsProvider.ConfirmQueueAttributes(ctx); err != nil {
+		return queueAttributesError{fmt.Errorf("confirming queue attributes for interruption queue, %w", err)}
 	}
 	return nil
 }
@@ -150,6 +203,10 @@ func (i *InfrastructureReconciler) ensureEventBridge(ctx context.Context) error
 	if err := i.eventBridgeProvider.CreateRules(ctx); err != nil {
 		return fmt.Errorf("creating EventBridge interruption rules, %w", err)
 	}
+	// Rules that older versions created under a rule type that's since been renamed or removed still deliver events
+	if err := i.eventBridgeProvider.DeleteOrphanedRules(ctx); err != nil {
+		return fmt.Errorf("deleting orphaned EventBridge interruption rules, %w", err)
+	}
 	return nil
 }
 
@@ -0,0 +1,79 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodetemplate
+
+import (
+	"context"
+
+	"github.com/samber/lo"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	nodetemplateutil "github.com/aws/karpenter/pkg/utils/nodetemplate"
+)
+
+// InheritanceReconciler resolves the base templates of the AWSNodeTemplate and records in its status whether the
+// merged settings are usable to launch nodes
+type InheritanceReconciler struct {
+	kubeClient client.Client
+}
+
+func NewInheritanceReconciler(kubeClient client.Client) *InheritanceReconciler {
+	return &InheritanceReconciler{
+		kubeClient: kubeClient,
+	}
+}
+
+func (r *InheritanceReconciler) Reconcile(ctx context.Context, nodeTemplate *v1alpha1.AWSNodeTemplate) (reconcile.Result, error) {
+	if !nodeTemplate.DeletionTimestamp.IsZero() {
+		return reconcile.Result{}, nil
+	}
+	merged := nodeTemplate.DeepCopy()
+	if err := nodetemplateutil.Resolve(ctx, r.kubeClient, merged); err != nil {
+		nodeTemplate.StatusConditions().MarkFalse(v1alpha1.BaseTemplateResolved, "ResolutionFailed", "%s", err)
+		return reconcile.Result{}, nil
+	}
+	// A template on its own is validated on admission, but the merged settings of the base templates may conflict
+	if err := merged.Validate(ctx); err != nil {
+		nodeTemplate.StatusConditions().MarkFalse(v1alpha1.BaseTemplateResolved, "InvalidMergedSettings", "%s", err)
+		return reconcile.Result{}, nil
+	}
+	nodeTemplate.StatusConditions().MarkTrue(v1alpha1.BaseTemplateResolved)
+	return reconcile.Result{}, nil
+}
+
+// mergedTemplate returns a copy of the AWSNodeTemplate with the settings of its base templates merged into its spec.
+// It returns false if the base templates can't be resolved, which the InheritanceReconciler reports in the
+// BaseTemplateResolved condition, so that the other reconcilers skip the AWSNodeTemplate until they can be resolved.
+func mergedTemplate(ctx context.Context, kubeClient client.Client, nodeTemplate *v1alpha1.AWSNodeTemplate) (*v1alpha1.AWSNodeTemplate, bool) {
+	merged := nodeTemplate.DeepCopy()
+	if err := nodetemplateutil.Resolve(ctx, kubeClient, merged); err != nil {
+		return nil, false
+	}
+	return merged, true
+}
+
+// inheritingTemplates returns the requests for all AWSNodeTemplates that inherit from a base template, so that their
+// merged settings are revalidated when any of the templates in their chain changes
+func (r *InheritanceReconciler) inheritingTemplates(_ client.Object) []reconcile.Request {
+	nodeTemplateList := &v1alpha1.AWSNodeTemplateList{}
+	if err := r.kubeClient.List(context.Background(), nodeTemplateList); err != nil {
+		return nil
+	}
+	return lo.FilterMap(nodeTemplateList.Items, func(nodeTemplate v1alpha1.AWSNodeTemplate, _ int) (reconcile.Request, bool) {
+		return reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&nodeTemplate)}, nodeTemplate.Spec.BaseTemplateRef != nil
+	})
+}
@@ -21,7 +21,15 @@ import (
 	"github.com/aws/karpenter-core/pkg/metrics"
 )
 
-const interruptionSubsystem = "interruption"
+const (
+	interruptionSubsystem = "interruption"
+	resultLabel           = "result"
+
+	resultSuccess              = "success"
+	resultAccessDenied         = "access_denied"
+	resultQueueDeletedRecently = "queue_deleted_recently"
+	resultError                = "error"
+)
 
 var (
 	infrastructureCreateDuration = prometheus.NewHistogram(
@@ -42,8 +50,26 @@ var (
 			Buckets:   metrics.DurationBuckets(),
 		},
 	)
+	infrastructureReconcileDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: interruptionSubsystem,
+			Name:      "infrastructure_reconcile_time_seconds",
+			Help:      "Length of time to reconcile infrastructure, whether it was created or deleted.",
+			Buckets:   metrics.DurationBuckets(),
+		},
+	)
+	infrastructureReconciles = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: interruptionSubsystem,
+			Name:      "infrastructure_reconciles",
+			Help:      "Count of infrastructure reconciles. Broken down by result, one of success, access_denied, queue_deleted_recently or error.",
+		},
+		[]string{resultLabel},
+	)
 )
 
 func init() {
-	crmetrics.Registry.MustRegister(infrastructureCreateDuration, infrastructureDeleteDuration)
+	crmetrics.Registry.MustRegister(infrastructureCreateDuration, infrastructureDeleteDuration, infrastructureReconcileDuration, infrastructureReconciles)
 }
@@ -0,0 +1,146 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodetemplate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/samber/lo"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	awssettings "github.com/aws/karpenter/pkg/apis/config/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/cloudprovider"
+)
+
+// networkRecheckPeriod is how often the VPCs of the subnets and security groups are checked again, since they can
+// change in EC2 without the AWSNodeTemplate changing
+const networkRecheckPeriod = 5 * time.Minute
+
+// NetworkReconciler records in the status of the AWSNodeTemplate whether its subnets and security groups belong to a
+// single VPC. Nodes launched into a subnet of another VPC than their security groups or the cluster can't join it.
+type NetworkReconciler struct {
+	kubeClient            client.Client
+	subnetProvider        *cloudprovider.SubnetProvider
+	securityGroupProvider *cloudprovider.SecurityGroupProvider
+}
+
+func NewNetworkReconciler(kubeClient client.Client, subnetProvider *cloudprovider.SubnetProvider, securityGroupProvider *cloudprovider.SecurityGroupProvider) *NetworkReconciler {
+	return &NetworkReconciler{
+		kubeClient:            kubeClient,
+		subnetProvider:        subnetProvider,
+		securityGroupProvider: securityGroupProvider,
+	}
+}
+
+func (r *NetworkReconciler) Reconcile(ctx context.Context, nodeTemplate *v1alpha1.AWSNodeTemplate) (reconcile.Result, error) {
+	if !nodeTemplate.DeletionTimestamp.IsZero() {
+		return reconcile.Result{}, nil
+	}
+	merged, ok := mergedTemplate(ctx, r.kubeClient, nodeTemplate)
+	if !ok {
+		return reconcile.Result{}, nil
+	}
+	vpcs, err := r.vpcs(ctx, &merged.Spec.AWS)
+	if err != nil {
+		nodeTemplate.StatusConditions().MarkFalse(v1alpha1.NetworkReady, "ResolutionFailed", "%s", err)
+		return reconcile.Result{RequeueAfter: time.Minute}, nil
+	}
+	if err := validateVPCs(vpcs, awssettings.FromContext(ctx).ClusterVPCID); err != nil {
+		nodeTemplate.StatusConditions().MarkFalse(v1alpha1.NetworkReady, "VPCMismatch", "%s", err)
+		return reconcile.Result{RequeueAfter: networkRecheckPeriod}, nil
+	}
+	if err := r.validatePreferredZones(ctx, &merged.Spec.AWS); err != nil {
+		nodeTemplate.StatusConditions().MarkFalse(v1alpha1.NetworkReady, "PreferredZoneNotFound", "%s", err)
+		return reconcile.Result{RequeueAfter: networkRecheckPeriod}, nil
+	}
+	nodeTemplate.StatusConditions().MarkTrue(v1alpha1.NetworkReady)
+	return reconcile.Result{RequeueAfter: networkRecheckPeriod}, nil
+}
+
+// validatePreferredZones returns an error if any of the preferred zones doesn't have a selected subnet, since nodes
+// can't be launched into it. Subnets of a launch template that's specified directly aren't known.
+func (r *NetworkReconciler) validatePreferredZones(ctx context.Context, provider *v1alpha1.AWS) error {
+	if len(provider.PreferredZones) == 0 || provider.SubnetSelector == nil {
+		return nil
+	}
+	subnets, err := r.subnetProvider.Get(ctx, provider)
+	if err != nil {
+		return err
+	}
+	return validatePreferredZones(subnets, provider.PreferredZones)
+}
+
+func validatePreferredZones(subnets []*ec2.Subnet, preferredZones []string) error {
+	zones := lo.Map(subnets, func(subnet *ec2.Subnet, _ int) string { return aws.StringValue(subnet.AvailabilityZone) })
+	if missing := lo.Without(preferredZones, zones...); len(missing) > 0 {
+		return fmt.Errorf("preferred zones %s don't have any of the selected subnets", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// vpcs maps the VPCs of the selected subnets and security groups to their IDs. Security groups of a launch template
+// that's specified directly aren't known, so only its subnets are considered.
+func (r *NetworkReconciler) vpcs(ctx context.Context, provider *v1alpha1.AWS) (map[string][]string, error) {
+	vpcs := map[string][]string{}
+	if provider.SubnetSelector != nil {
+		subnets, err := r.subnetProvider.Get(ctx, provider)
+		if err != nil {
+			return nil, err
+		}
+		for _, subnet := range subnets {
+			vpcs[aws.StringValue(subnet.VpcId)] = append(vpcs[aws.StringValue(subnet.VpcId)], aws.StringValue(subnet.SubnetId))
+		}
+	}
+	if provider.SecurityGroupSelector != nil {
+		securityGroups, err := r.securityGroupProvider.List(ctx, provider)
+		if err != nil {
+			return nil, err
+		}
+		for _, securityGroup := range securityGroups {
+			vpcs[aws.StringValue(securityGroup.VpcId)] = append(vpcs[aws.StringValue(securityGroup.VpcId)], aws.StringValue(securityGroup.GroupId))
+		}
+	}
+	return vpcs, nil
+}
+
+func validateVPCs(vpcs map[string][]string, clusterVPCID string) error {
+	if len(vpcs) > 1 {
+		return fmt.Errorf("subnets and security groups span VPCs, %s", prettyVPCs(vpcs))
+	}
+	if clusterVPCID == "" {
+		return nil
+	}
+	if outside := lo.OmitByKeys(vpcs, []string{clusterVPCID}); len(outside) > 0 {
+		return fmt.Errorf("subnets and security groups aren't in the cluster VPC %s, %s", clusterVPCID, prettyVPCs(outside))
+	}
+	return nil
+}
+
+func prettyVPCs(vpcs map[string][]string) string {
+	entries := lo.MapToSlice(vpcs, func(vpc string, ids []string) string {
+		sort.Strings(ids)
+		return fmt.Sprintf("%s (%s)", vpc, strings.Join(ids, ", "))
+	})
+	sort.Strings(entries)
+	return strings.Join(entries, ", ")
+}
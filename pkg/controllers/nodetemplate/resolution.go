@@ -0,0 +1,106 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodetemplate
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	awssettings "github.com/aws/karpenter/pkg/apis/config/settings"
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	"github.com/aws/karpenter/pkg/cloudprovider"
+	"github.com/aws/karpenter/pkg/cloudprovider/amifamily"
+	nodetemplateutil "github.com/aws/karpenter/pkg/utils/nodetemplate"
+)
+
+// ResolutionValidator rejects AWSNodeTemplates whose subnets, security groups, AMIs or instance profile can't be
+// resolved, or whose preferred zones don't have any of their subnets, when they're admitted, if
+// aws.nodeTemplateValidation is Strict. Otherwise, AWSNodeTemplates are admitted and the failures are surfaced in their
+// status conditions by the Controller.
+type ResolutionValidator struct {
+	kubeClient            client.Client
+	subnetProvider        *cloudprovider.SubnetProvider
+	securityGroupProvider *cloudprovider.SecurityGroupProvider
+	amiProvider           *amifamily.AMIProvider
+}
+
+func NewResolutionValidator(kubeClient client.Client, subnetProvider *cloudprovider.SubnetProvider,
+	securityGroupProvider *cloudprovider.SecurityGroupProvider, amiProvider *amifamily.AMIProvider) *ResolutionValidator {
+	return &ResolutionValidator{
+		kubeClient:            kubeClient,
+		subnetProvider:        subnetProvider,
+		securityGroupProvider: securityGroupProvider,
+		amiProvider:           amiProvider,
+	}
+}
+
+// ValidateUnstructured validates the AWSNodeTemplate of an admission request
+func (v *ResolutionValidator) ValidateUnstructured(ctx context.Context, u *unstructured.Unstructured) error {
+	nodeTemplate := &v1alpha1.AWSNodeTemplate{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, nodeTemplate); err != nil {
+		return fmt.Errorf("converting AWSNodeTemplate, %w", err)
+	}
+	return v.Validate(ctx, nodeTemplate)
+}
+
+// Validate returns why the AWSNodeTemplate can't be resolved, after merging its base templates
+func (v *ResolutionValidator) Validate(ctx context.Context, nodeTemplate *v1alpha1.AWSNodeTemplate) error {
+	if awssettings.FromContext(ctx).NodeTemplateValidation != awssettings.StrictValidation {
+		return nil
+	}
+	merged := nodeTemplate.DeepCopy()
+	if err := nodetemplateutil.Resolve(ctx, v.kubeClient, merged); err != nil {
+		return fmt.Errorf("resolving base templates, %w", err)
+	}
+	provider := &merged.Spec.AWS
+	if provider.SubnetSelector != nil {
+		subnets, err := v.subnetProvider.Get(ctx, provider)
+		if err != nil {
+			return fmt.Errorf("resolving subnets, %w", err)
+		}
+		if err := validatePreferredZones(subnets, provider.PreferredZones); err != nil {
+			return err
+		}
+	}
+	// The rest of the configuration is part of a launch template that's specified directly
+	if provider.LaunchTemplateName != nil {
+		return nil
+	}
+	securityGroups, err := v.securityGroupProvider.List(ctx, provider)
+	if err != nil {
+		return fmt.Errorf("resolving security groups, %w", err)
+	}
+	if len(securityGroups) == 0 {
+		return fmt.Errorf("no security groups matched selector %v", provider.SecurityGroupSelector)
+	}
+	if len(merged.Spec.AMISelectors()) != 0 {
+		amis, err := v.amiProvider.Discover(ctx, merged)
+		if err != nil {
+			return fmt.Errorf("resolving amis, %w", err)
+		}
+		if len(amis) == 0 {
+			return fmt.Errorf("no amis matched amiSelector")
+		}
+	}
+	if lo.FromPtr(provider.InstanceProfile) == "" && awssettings.FromContext(ctx).DefaultInstanceProfile == "" {
+		return fmt.Errorf("neither spec.instanceProfile nor aws.defaultInstanceProfile is specified")
+	}
+	return nil
+}
@@ -22,15 +22,25 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/eventbridge"
 	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/ssm"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/patrickmn/go-cache"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	clock "k8s.io/utils/clock/testing"
+	knativeapis "knative.dev/pkg/apis"
 	. "knative.dev/pkg/logging/testing"
 	_ "knative.dev/pkg/system/testing"
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	coresettings "github.com/aws/karpenter-core/pkg/apis/config/settings"
 	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
@@ -40,6 +50,10 @@ import (
 	"github.com/aws/karpenter/pkg/apis"
 	"github.com/aws/karpenter/pkg/apis/config/settings"
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+	awscache "github.com/aws/karpenter/pkg/cache"
+	"github.com/aws/karpenter/pkg/cloudprovider"
+	"github.com/aws/karpenter/pkg/cloudprovider/amifamily"
+	awscontext "github.com/aws/karpenter/pkg/context"
 	"github.com/aws/karpenter/pkg/controllers/nodetemplate"
 	"github.com/aws/karpenter/pkg/controllers/providers"
 	"github.com/aws/karpenter/pkg/errors"
@@ -53,6 +67,12 @@ var sqsapi *fake.SQSAPI
 var sqsProvider *providers.SQS
 var eventbridgeapi *fake.EventBridgeAPI
 var eventBridgeProvider *providers.EventBridge
+var ec2api *fake.EC2API
+var ssmapi *fake.SSMAPI
+var ssmCache *cache.Cache
+var ssmQueries *awscache.SSMQueries
+var recorder *coretest.EventRecorder
+var fakeClock *clock.FakeClock
 var controller *nodetemplate.Controller
 
 func TestAPIs(t *testing.T) {
@@ -67,6 +87,9 @@ var _ = BeforeSuite(func() {
 	eventbridgeapi = &fake.EventBridgeAPI{}
 	sqsProvider = providers.NewSQS(sqsapi)
 	eventBridgeProvider = providers.NewEventBridge(eventbridgeapi, sqsProvider)
+	ec2api = &fake.EC2API{}
+	ssmapi = &fake.SSMAPI{}
+	recorder = coretest.NewEventRecorder()
 })
 
 var _ = AfterSuite(func() {
@@ -74,7 +97,11 @@ var _ = AfterSuite(func() {
 })
 
 var _ = BeforeEach(func() {
-	controller = nodetemplate.NewController(env.Client, sqsProvider, eventBridgeProvider)
+	fakeClock = clock.NewFakeClock(time.Now())
+	ssmCache = cache.New(awscontext.CacheTTL, awscontext.CacheCleanupInterval)
+	ssmQueries = awscache.NewSSMQueries(cache.New(awscache.SSMQueriesTTL, awscontext.CacheCleanupInterval))
+	controller = nodetemplate.NewController(env.Client, fakeClock, recorder, sqsProvider, eventBridgeProvider, cloudprovider.NewSubnetProvider(ec2api), cloudprovider.NewSecurityGroupProvider(ec2api),
+		amifamily.NewAMIProvider(env.Client, recorder, ssmapi, ec2api, ssmCache, cache.New(awscontext.CacheTTL, awscontext.CacheCleanupInterval), ssmQueries), ssmQueries)
 	settingsStore := coretest.SettingsStore{
 		coresettings.ContextKey: test.Settings(),
 		settings.ContextKey: test.Settings(test.SettingOptions{
@@ -87,6 +114,9 @@ var _ = BeforeEach(func() {
 var _ = AfterEach(func() {
 	sqsapi.Reset()
 	eventbridgeapi.Reset()
+	ec2api.Reset()
+	ssmapi.Reset()
+	recorder.Reset()
 	ExpectCleanedUp(ctx, env.Client)
 })
 
@@ -138,6 +168,307 @@ var _ = Describe("AWSNodeTemplate", func() {
 				Expect(result.RequeueAfter).To(Equal(time.Minute))
 				Expect(sqsapi.CreateQueueBehavior.FailedCalls()).To(Equal(1))
 			})
+			It("should apply the interruption infrastructure tags to the queue and the rules", func() {
+				ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
+					EnableInterruptionHandling:     lo.ToPtr(true),
+					Tags:                           map[string]string{"team": "platform"},
+					InterruptionInfrastructureTags: map[string]string{"cost-center": "1234", v1alpha5.DiscoveryTagKey: "other-cluster"},
+				}))
+				sqsapi.GetQueueURLBehavior.Error.Set(awsErrWithCode(sqs.ErrCodeQueueDoesNotExist), fake.MaxCalls(1)) // This mocks the queue not existing
+
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(provider))
+
+				Expect(sqsapi.CreateQueueBehavior.CalledWithInput.Len()).To(Equal(1))
+				Expect(aws.StringValueMap(sqsapi.CreateQueueBehavior.CalledWithInput.Pop().Tags)).To(Equal(map[string]string{
+					"team":                   "platform",
+					"cost-center":            "1234",
+					v1alpha5.DiscoveryTagKey: settings.FromContext(ctx).ClusterName,
+					v1alpha5.ManagedByTagKey: settings.FromContext(ctx).ClusterName,
+				}))
+				Expect(sqsapi.TagQueueBehavior.Calls()).To(Equal(0))
+				Expect(eventbridgeapi.PutRuleBehavior.CalledWithInput.Len()).To(Equal(4))
+				Expect(eventbridgeapi.TagResourceBehavior.CalledWithInput.Len()).To(Equal(4))
+				for eventbridgeapi.TagResourceBehavior.CalledWithInput.Len() > 0 {
+					Expect(eventBridgeTags(eventbridgeapi.TagResourceBehavior.CalledWithInput.Pop().Tags)).To(Equal(map[string]string{
+						"team":                   "platform",
+						"cost-center":            "1234",
+						v1alpha5.DiscoveryTagKey: settings.FromContext(ctx).ClusterName,
+						v1alpha5.ManagedByTagKey: settings.FromContext(ctx).ClusterName,
+					}))
+				}
+				for eventbridgeapi.PutRuleBehavior.CalledWithInput.Len() > 0 {
+					Expect(eventBridgeTags(eventbridgeapi.PutRuleBehavior.CalledWithInput.Pop().Tags)).To(HaveKeyWithValue("cost-center", "1234"))
+				}
+			})
+			It("should reconcile the tags of a queue that already exists", func() {
+				ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
+					EnableInterruptionHandling:     lo.ToPtr(true),
+					InterruptionInfrastructureTags: map[string]string{"cost-center": "1234"},
+				}))
+
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(provider))
+
+				Expect(sqsapi.CreateQueueBehavior.Calls()).To(Equal(0))
+				Expect(sqsapi.TagQueueBehavior.CalledWithInput.Len()).To(Equal(1))
+				Expect(aws.StringValueMap(sqsapi.TagQueueBehavior.CalledWithInput.Pop().Tags)).To(HaveKeyWithValue("cost-center", "1234"))
+				Expect(eventbridgeapi.TagResourceBehavior.SuccessfulCalls()).To(Equal(4))
+			})
+			It("should update the targets of rules that point at a stale queue", func() {
+				sqsapi.GetQueueAttributesBehavior.Output.Set(&sqs.GetQueueAttributesOutput{
+					Attributes: map[string]*string{
+						sqs.QueueAttributeNameQueueArn: aws.String("arn:aws:sqs:us-west-2:000000000000:Karpenter-Queue-Recreated"),
+					},
+				})
+				eventbridgeapi.ListTargetsByRuleBehavior.Output.Set(&eventbridge.ListTargetsByRuleOutput{
+					Targets: []*eventbridge.Target{
+						{
+							Id:  aws.String(providers.QueueTargetID),
+							Arn: aws.String("arn:aws:sqs:us-west-2:000000000000:Karpenter-Queue"),
+						},
+					},
+				})
+
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(provider))
+
+				Expect(eventbridgeapi.PutTargetsBehavior.CalledWithInput.Len()).To(Equal(4))
+				for eventbridgeapi.PutTargetsBehavior.CalledWithInput.Len() > 0 {
+					input := eventbridgeapi.PutTargetsBehavior.CalledWithInput.Pop()
+					Expect(input.Targets).To(HaveLen(1))
+					Expect(aws.StringValue(input.Targets[0].Id)).To(Equal(providers.QueueTargetID))
+					Expect(aws.StringValue(input.Targets[0].Arn)).To(Equal("arn:aws:sqs:us-west-2:000000000000:Karpenter-Queue-Recreated"))
+				}
+			})
+			It("should not update the targets of rules that point at the queue", func() {
+				queueARN, err := sqsProvider.DiscoverQueueARN(ctx)
+				Expect(err).ToNot(HaveOccurred())
+				eventbridgeapi.ListTargetsByRuleBehavior.Output.Set(&eventbridge.ListTargetsByRuleOutput{
+					Targets: []*eventbridge.Target{
+						{
+							Id:  aws.String(providers.QueueTargetID),
+							Arn: aws.String(queueARN),
+						},
+					},
+				})
+
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(provider))
+
+				Expect(eventbridgeapi.ListTargetsByRuleBehavior.SuccessfulCalls()).To(Equal(4))
+				Expect(eventbridgeapi.PutTargetsBehavior.Calls()).To(Equal(0))
+			})
+			It("should delete orphaned rules that were created by older versions", func() {
+				legacyRule := "Karpenter-ScheduledMaintenanceRule-bcdfghjklmnpqrstvwxz2456789"
+				eventbridgeapi.ListRulesBehavior.Output.Set(&eventbridge.ListRulesOutput{
+					Rules: []*eventbridge.Rule{
+						{
+							Name: aws.String(providers.DefaultRules[providers.ScheduledChangedRule].Name),
+							Arn:  aws.String("test-arn1"),
+						},
+						{
+							Name: aws.String(legacyRule),
+							Arn:  aws.String("test-arn2"),
+						},
+						{
+							// Custom rules that don't follow the naming convention of Karpenter's rules are left alone
+							Name: aws.String("Karpenter-custom-alerts"),
+							Arn:  aws.String("test-arn3"),
+						},
+					},
+				})
+				eventbridgeapi.ListTagsForResourceBehavior.Output.Set(&eventbridge.ListTagsForResourceOutput{
+					Tags: []*eventbridge.Tag{
+						{
+							Key:   aws.String(v1alpha5.DiscoveryTagKey),
+							Value: aws.String(settings.FromContext(ctx).ClusterName),
+						},
+					},
+				})
+				eventbridgeapi.ListTargetsByRuleBehavior.Output.Set(&eventbridge.ListTargetsByRuleOutput{
+					Targets: []*eventbridge.Target{
+						{
+							Id:  aws.String(providers.QueueTargetID),
+							Arn: aws.String("arn:aws:sqs:us-west-2:000000000000:Karpenter-Queue"),
+						},
+					},
+				})
+
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(provider))
+
+				Expect(eventbridgeapi.PutRuleBehavior.SuccessfulCalls()).To(Equal(4))
+				Expect(eventbridgeapi.DeleteRuleBehavior.CalledWithInput.Len()).To(Equal(1))
+				Expect(aws.StringValue(eventbridgeapi.DeleteRuleBehavior.CalledWithInput.Pop().Name)).To(Equal(legacyRule))
+				Expect(eventbridgeapi.RemoveTargetsBehavior.CalledWithInput.Len()).To(Equal(1))
+				Expect(aws.StringValue(eventbridgeapi.RemoveTargetsBehavior.CalledWithInput.Pop().Rule)).To(Equal(legacyRule))
+			})
+			It("should not delete orphaned rules that target more than the queue", func() {
+				eventbridgeapi.ListRulesBehavior.Output.Set(&eventbridge.ListRulesOutput{
+					Rules: []*eventbridge.Rule{
+						{
+							Name: aws.String("Karpenter-ScheduledMaintenanceRule-bcdfghjklmnpqrstvwxz2456789"),
+							Arn:  aws.String("test-arn1"),
+						},
+					},
+				})
+				eventbridgeapi.ListTagsForResourceBehavior.Output.Set(&eventbridge.ListTagsForResourceOutput{
+					Tags: []*eventbridge.Tag{
+						{
+							Key:   aws.String(v1alpha5.DiscoveryTagKey),
+							Value: aws.String(settings.FromContext(ctx).ClusterName),
+						},
+					},
+				})
+				eventbridgeapi.ListTargetsByRuleBehavior.Output.Set(&eventbridge.ListTargetsByRuleOutput{
+					Targets: []*eventbridge.Target{
+						{
+							Id:  aws.String(providers.QueueTargetID),
+							Arn: aws.String("arn:aws:sqs:us-west-2:000000000000:Karpenter-Queue"),
+						},
+						{
+							Id:  aws.String("alerting"),
+							Arn: aws.String("arn:aws:sns:us-west-2:000000000000:alerts"),
+						},
+					},
+				})
+
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(provider))
+				Expect(eventbridgeapi.DeleteRuleBehavior.Calls()).To(Equal(0))
+			})
+			It("should fail to reconcile when the queue can't be tagged", func() {
+				sqsapi.TagQueueBehavior.Error.Set(awsErrWithCode(errors.AccessDeniedCode), fake.MaxCalls(0))
+
+				ExpectReconcileFailed(ctx, controller, client.ObjectKeyFromObject(provider))
+				Expect(sqsapi.TagQueueBehavior.FailedCalls()).To(Equal(1))
+				Expect(eventbridgeapi.PutRuleBehavior.Calls()).To(Equal(0))
+			})
+			It("should not re-verify the infrastructure before the interval elapses", func() {
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(provider))
+				Expect(eventbridgeapi.PutRuleBehavior.SuccessfulCalls()).To(Equal(4))
+
+				fakeClock.Step(time.Minute * 4)
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(provider))
+				Expect(eventbridgeapi.PutRuleBehavior.SuccessfulCalls()).To(Equal(4))
+			})
+			It("should re-verify the infrastructure once the interval elapses", func() {
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(provider))
+				Expect(sqsapi.CreateQueueBehavior.SuccessfulCalls()).To(Equal(0))
+				Expect(eventbridgeapi.PutRuleBehavior.SuccessfulCalls()).To(Equal(4))
+
+				// Simulating the queue being deleted out of band
+				sqsapi.GetQueueURLBehavior.Error.Set(awsErrWithCode(sqs.ErrCodeQueueDoesNotExist), fake.MaxCalls(1))
+				fakeClock.Step(time.Minute * 5)
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(provider))
+				Expect(sqsapi.CreateQueueBehavior.SuccessfulCalls()).To(Equal(1))
+				Expect(eventbridgeapi.PutRuleBehavior.SuccessfulCalls()).To(Equal(8))
+			})
+			It("should re-verify the infrastructure on the configured interval", func() {
+				ctx = settings.ToContext(ctx, test.Settings(test.SettingOptions{
+					EnableInterruptionHandling:         lo.ToPtr(true),
+					InterruptionInfrastructureInterval: lo.ToPtr(time.Minute),
+				}))
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(provider))
+				Expect(eventbridgeapi.PutRuleBehavior.SuccessfulCalls()).To(Equal(4))
+
+				fakeClock.Step(time.Minute)
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(provider))
+				Expect(eventbridgeapi.PutRuleBehavior.SuccessfulCalls()).To(Equal(8))
+			})
+			It("should mark the queue ready once its attributes are configured", func() {
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(provider))
+				Expect(expectNodeTemplate(provider).StatusConditions().GetCondition(v1alpha1.QueueReady).IsTrue()).To(BeTrue())
+			})
+			It("should retry setting the queue attributes on the next reconcile when they fail after creation", func() {
+				sqsapi.GetQueueURLBehavior.Error.Set(awsErrWithCode(sqs.ErrCodeQueueDoesNotExist), fake.MaxCalls(1)) // This mocks the queue not existing
+				sqsapi.SetQueueAttributesBehavior.Error.Set(awsErrWithCode(errors.AccessDeniedCode), fake.MaxCalls(1))
+
+				result := ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(provider))
+				Expect(result.RequeueAfter).To(Equal(time.Second * 10))
+				Expect(sqsapi.CreateQueueBehavior.SuccessfulCalls()).To(Equal(1))
+				Expect(eventbridgeapi.PutRuleBehavior.Calls()).To(Equal(0))
+				condition := expectNodeTemplate(provider).StatusConditions().GetCondition(v1alpha1.QueueReady)
+				Expect(condition.IsFalse()).To(BeTrue())
+				Expect(condition.Reason).To(Equal("AttributesNotConfigured"))
+
+				// The queue isn't created again, but its attributes are set on the next reconcile
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(provider))
+				Expect(sqsapi.CreateQueueBehavior.SuccessfulCalls()).To(Equal(1))
+				Expect(sqsapi.SetQueueAttributesBehavior.SuccessfulCalls()).To(Equal(1))
+				Expect(eventbridgeapi.PutRuleBehavior.SuccessfulCalls()).To(Equal(4))
+				Expect(expectNodeTemplate(provider).StatusConditions().GetCondition(v1alpha1.QueueReady).IsTrue()).To(BeTrue())
+			})
+			It("should not mark the queue ready when its attributes can't be confirmed", func() {
+				sqsapi.GetQueueAttributesBehavior.Output.Set(&sqs.GetQueueAttributesOutput{
+					Attributes: map[string]*string{
+						sqs.QueueAttributeNameQueueArn:               aws.String("arn:aws:sqs:us-west-2:000000000000:Karpenter-Queue"),
+						sqs.QueueAttributeNameMessageRetentionPeriod: aws.String("345600"),
+					},
+				})
+
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(provider))
+				Expect(eventbridgeapi.PutRuleBehavior.Calls()).To(Equal(0))
+				condition := expectNodeTemplate(provider).StatusConditions().GetCondition(v1alpha1.QueueReady)
+				Expect(condition.IsFalse()).To(BeTrue())
+				Expect(condition.Message).To(ContainSubstring(sqs.QueueAttributeNameMessageRetentionPeriod))
+				Expect(condition.Message).ToNot(ContainSubstring(sqs.QueueAttributeNamePolicy))
+			})
+			It("should mark the queue ready when interruption handling is disabled", func() {
+				ctx = coretest.SettingsStore{
+					coresettings.ContextKey: test.Settings(),
+					settings.ContextKey:     test.Settings(),
+				}.InjectSettings(ctx)
+
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(provider))
+				condition := expectNodeTemplate(provider).StatusConditions().GetCondition(v1alpha1.QueueReady)
+				Expect(condition.IsTrue()).To(BeTrue())
+				Expect(condition.Reason).To(Equal("InterruptionHandlingDisabled"))
+			})
+		})
+		Context("Metrics", func() {
+			var provider *v1alpha1.AWSNodeTemplate
+			BeforeEach(func() {
+				provider = test.AWSNodeTemplate()
+				ExpectApplied(ctx, env.Client, provider)
+				sqsapi.GetQueueURLBehavior.Error.Set(awsErrWithCode(sqs.ErrCodeQueueDoesNotExist), fake.MaxCalls(0)) // This mocks the queue not existing
+			})
+			AfterEach(func() {
+				ExpectFinalizersRemoved(ctx, env.Client, provider)
+				ExpectDeleted(ctx, env.Client, provider)
+			})
+			It("should count a successful reconcile and measure its duration", func() {
+				successes := ExpectReconcilesValue("success")
+				durations := ExpectReconcileDurationCount()
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(provider))
+				Expect(ExpectReconcilesValue("success")).To(Equal(successes + 1))
+				Expect(ExpectReconcileDurationCount()).To(Equal(durations + 1))
+			})
+			It("should count a reconcile that is denied access", func() {
+				accessDenied := ExpectReconcilesValue("access_denied")
+				sqsapi.CreateQueueBehavior.Error.Set(awsErrWithCode(errors.AccessDeniedCode), fake.MaxCalls(0))
+				ExpectReconcileFailed(ctx, controller, client.ObjectKeyFromObject(provider))
+				Expect(ExpectReconcilesValue("access_denied")).To(Equal(accessDenied + 1))
+				sqsapi.CreateQueueBehavior.Reset()
+			})
+			It("should count a reconcile of a queue that was recently deleted", func() {
+				recentlyDeleted := ExpectReconcilesValue("queue_deleted_recently")
+				sqsapi.CreateQueueBehavior.Error.Set(awsErrWithCode(sqs.ErrCodeQueueDeletedRecently), fake.MaxCalls(0))
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(provider))
+				Expect(ExpectReconcilesValue("queue_deleted_recently")).To(Equal(recentlyDeleted + 1))
+				sqsapi.CreateQueueBehavior.Reset()
+			})
+			It("should count a reconcile that fails with any other error", func() {
+				failures := ExpectReconcilesValue("error")
+				sqsapi.CreateQueueBehavior.Error.Set(awsErrWithCode("InternalError"), fake.MaxCalls(0))
+				ExpectReconcileFailed(ctx, controller, client.ObjectKeyFromObject(provider))
+				Expect(ExpectReconcilesValue("error")).To(Equal(failures + 1))
+				sqsapi.CreateQueueBehavior.Reset()
+			})
+			It("should not count reconciles when interruption handling is disabled", func() {
+				successes := ExpectReconcilesValue("success")
+				ctx = coretest.SettingsStore{
+					coresettings.ContextKey: test.Settings(),
+					settings.ContextKey:     test.Settings(),
+				}.InjectSettings(ctx)
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(provider))
+				Expect(ExpectReconcilesValue("success")).To(Equal(successes))
+			})
 		})
 		Context("Deletion", func() {
 			It("should cleanup the infrastructure when the last AWSNodeTemplate is removed", func() {
@@ -344,8 +675,531 @@ var _ = Describe("AWSNodeTemplate", func() {
 			})
 		})
 	})
+	Context("Network", func() {
+		var nodeTemplate *v1alpha1.AWSNodeTemplate
+		BeforeEach(func() {
+			nodeTemplate = test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{AWS: v1alpha1.AWS{
+				SubnetSelector:        map[string]string{"*": "*"},
+				SecurityGroupSelector: map[string]string{"*": "*"},
+			}})
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+		})
+		AfterEach(func() {
+			ExpectFinalizersRemoved(ctx, env.Client, nodeTemplate)
+			ExpectDeleted(ctx, env.Client, nodeTemplate)
+		})
+		It("should be ready when the subnets and security groups are in the same VPC", func() {
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			Expect(expectNodeTemplate(nodeTemplate).StatusConditions().GetCondition(v1alpha1.NetworkReady).IsTrue()).To(BeTrue())
+		})
+		It("should not be ready when the subnets span VPCs", func() {
+			ec2api.DescribeSubnetsOutput.Set(&ec2.DescribeSubnetsOutput{Subnets: []*ec2.Subnet{
+				{SubnetId: aws.String("subnet-test1"), VpcId: aws.String("vpc-test1"), Tags: []*ec2.Tag{{Key: aws.String("foo"), Value: aws.String("bar")}}},
+				{SubnetId: aws.String("subnet-test2"), VpcId: aws.String("vpc-test2"), Tags: []*ec2.Tag{{Key: aws.String("foo"), Value: aws.String("bar")}}},
+			}})
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			condition := expectNodeTemplate(nodeTemplate).StatusConditions().GetCondition(v1alpha1.NetworkReady)
+			Expect(condition.IsFalse()).To(BeTrue())
+			Expect(condition.Reason).To(Equal("VPCMismatch"))
+			Expect(condition.Message).To(ContainSubstring("vpc-test2 (subnet-test2)"))
+		})
+		It("should not be ready when the security groups are in another VPC than the subnets", func() {
+			ec2api.DescribeSecurityGroupsOutput.Set(&ec2.DescribeSecurityGroupsOutput{SecurityGroups: []*ec2.SecurityGroup{
+				{GroupId: aws.String("sg-test1"), VpcId: aws.String("vpc-test2"), Tags: []*ec2.Tag{{Key: aws.String("foo"), Value: aws.String("bar")}}},
+			}})
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			condition := expectNodeTemplate(nodeTemplate).StatusConditions().GetCondition(v1alpha1.NetworkReady)
+			Expect(condition.IsFalse()).To(BeTrue())
+			Expect(condition.Message).To(ContainSubstring("vpc-test2 (sg-test1)"))
+		})
+		It("should not be ready when the subnets and security groups aren't in the cluster VPC", func() {
+			ctx = coretest.SettingsStore{
+				coresettings.ContextKey: test.Settings(),
+				settings.ContextKey:     test.Settings(test.SettingOptions{ClusterVPCID: lo.ToPtr("vpc-cluster")}),
+			}.InjectSettings(ctx)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			condition := expectNodeTemplate(nodeTemplate).StatusConditions().GetCondition(v1alpha1.NetworkReady)
+			Expect(condition.IsFalse()).To(BeTrue())
+			Expect(condition.Message).To(ContainSubstring("cluster VPC vpc-cluster"))
+		})
+		It("should be ready when the subnets and security groups are in the cluster VPC", func() {
+			ctx = coretest.SettingsStore{
+				coresettings.ContextKey: test.Settings(),
+				settings.ContextKey:     test.Settings(test.SettingOptions{ClusterVPCID: lo.ToPtr("vpc-test1")}),
+			}.InjectSettings(ctx)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			Expect(expectNodeTemplate(nodeTemplate).StatusConditions().GetCondition(v1alpha1.NetworkReady).IsTrue()).To(BeTrue())
+		})
+		It("should not be ready when no subnets match", func() {
+			ec2api.DescribeSubnetsOutput.Set(&ec2.DescribeSubnetsOutput{})
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			condition := expectNodeTemplate(nodeTemplate).StatusConditions().GetCondition(v1alpha1.NetworkReady)
+			Expect(condition.IsFalse()).To(BeTrue())
+			Expect(condition.Reason).To(Equal("ResolutionFailed"))
+		})
+		It("should be ready when the preferred zones have subnets", func() {
+			nodeTemplate.Spec.PreferredZones = []string{"test-zone-1b", "test-zone-1a"}
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			Expect(expectNodeTemplate(nodeTemplate).StatusConditions().GetCondition(v1alpha1.NetworkReady).IsTrue()).To(BeTrue())
+		})
+		It("should not be ready when a preferred zone doesn't have any subnets", func() {
+			nodeTemplate.Spec.PreferredZones = []string{"test-zone-1a", "test-zone-9z"}
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			condition := expectNodeTemplate(nodeTemplate).StatusConditions().GetCondition(v1alpha1.NetworkReady)
+			Expect(condition.IsFalse()).To(BeTrue())
+			Expect(condition.Reason).To(Equal("PreferredZoneNotFound"))
+			Expect(condition.Message).To(ContainSubstring("test-zone-9z"))
+			Expect(condition.Message).ToNot(ContainSubstring("test-zone-1a"))
+		})
+	})
+	Context("Resolution Validation", func() {
+		var validator *nodetemplate.ResolutionValidator
+		var nodeTemplate *v1alpha1.AWSNodeTemplate
+		strict := func(options ...test.SettingOptions) {
+			options = append(options, test.SettingOptions{NodeTemplateValidation: lo.ToPtr(settings.StrictValidation)})
+			ctx = settings.ToContext(ctx, test.Settings(options...))
+		}
+		BeforeEach(func() {
+			validator = nodetemplate.NewResolutionValidator(env.Client, cloudprovider.NewSubnetProvider(ec2api), cloudprovider.NewSecurityGroupProvider(ec2api),
+				amifamily.NewAMIProvider(env.Client, coretest.NewEventRecorder(), &fake.SSMAPI{}, ec2api, cache.New(awscontext.CacheTTL, awscontext.CacheCleanupInterval), cache.New(awscontext.CacheTTL, awscontext.CacheCleanupInterval), ssmQueries))
+			nodeTemplate = test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{AWS: v1alpha1.AWS{
+				SubnetSelector:        map[string]string{"*": "*"},
+				SecurityGroupSelector: map[string]string{"*": "*"},
+			}})
+		})
+		It("should admit node templates that can't be resolved and surface the failure in their status when lenient", func() {
+			ec2api.DescribeSubnetsOutput.Set(&ec2.DescribeSubnetsOutput{})
+			Expect(validator.Validate(ctx, nodeTemplate)).To(Succeed())
+
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			condition := expectNodeTemplate(nodeTemplate).StatusConditions().GetCondition(v1alpha1.NetworkReady)
+			Expect(condition.IsFalse()).To(BeTrue())
+			Expect(condition.Reason).To(Equal("ResolutionFailed"))
+			ExpectFinalizersRemoved(ctx, env.Client, nodeTemplate)
+			ExpectDeleted(ctx, env.Client, nodeTemplate)
+		})
+		It("should admit node templates that can be resolved when strict", func() {
+			strict()
+			Expect(validator.Validate(ctx, nodeTemplate)).To(Succeed())
+		})
+		It("should reject node templates whose subnets can't be resolved when strict", func() {
+			strict()
+			ec2api.DescribeSubnetsOutput.Set(&ec2.DescribeSubnetsOutput{})
+			Expect(validator.Validate(ctx, nodeTemplate)).To(MatchError(ContainSubstring("resolving subnets")))
+		})
+		It("should reject node templates whose security groups can't be resolved when strict", func() {
+			strict()
+			ec2api.DescribeSecurityGroupsOutput.Set(&ec2.DescribeSecurityGroupsOutput{})
+			Expect(validator.Validate(ctx, nodeTemplate)).To(MatchError(ContainSubstring("no security groups matched selector")))
+		})
+		It("should reject node templates whose amis can't be resolved when strict", func() {
+			strict()
+			nodeTemplate.Spec.AMISelector = map[string]string{"aws-ids": "ami-123"}
+			ec2api.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{})
+			Expect(validator.Validate(ctx, nodeTemplate)).ToNot(Succeed())
+		})
+		It("should reject node templates without an instance profile when strict", func() {
+			strict(test.SettingOptions{DefaultInstanceProfile: lo.ToPtr("")})
+			Expect(validator.Validate(ctx, nodeTemplate)).To(MatchError(ContainSubstring("instanceProfile")))
+			nodeTemplate.Spec.InstanceProfile = aws.String("karpenter")
+			Expect(validator.Validate(ctx, nodeTemplate)).To(Succeed())
+		})
+		It("should reject node templates whose preferred zones don't have any subnets when strict", func() {
+			strict()
+			nodeTemplate.Spec.PreferredZones = []string{"test-zone-9z"}
+			Expect(validator.Validate(ctx, nodeTemplate)).To(MatchError(ContainSubstring("preferred zones test-zone-9z")))
+		})
+		It("should reject node templates whose base template doesn't exist when strict", func() {
+			strict()
+			nodeTemplate.Spec.BaseTemplateRef = &v1alpha1.BaseTemplateRef{Name: "missing"}
+			Expect(validator.Validate(ctx, nodeTemplate)).To(MatchError(ContainSubstring("resolving base templates")))
+		})
+		It("should validate node templates of admission requests", func() {
+			strict()
+			ec2api.DescribeSubnetsOutput.Set(&ec2.DescribeSubnetsOutput{})
+			raw, err := runtime.DefaultUnstructuredConverter.ToUnstructured(nodeTemplate)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(validator.ValidateUnstructured(ctx, &unstructured.Unstructured{Object: raw})).To(MatchError(ContainSubstring("resolving subnets")))
+		})
+	})
+	Context("Inheritance", func() {
+		var base, child *v1alpha1.AWSNodeTemplate
+		BeforeEach(func() {
+			base = test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{AWS: v1alpha1.AWS{
+				SubnetSelector:        map[string]string{"*": "*"},
+				SecurityGroupSelector: map[string]string{"*": "*"},
+				Tags:                  map[string]string{"team": "platform"},
+			}})
+			child = test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{
+				BaseTemplateRef: &v1alpha1.BaseTemplateRef{Name: base.Name},
+				AWS:             v1alpha1.AWS{Tags: map[string]string{"team": "ml"}},
+			})
+		})
+		AfterEach(func() {
+			for _, nodeTemplate := range []*v1alpha1.AWSNodeTemplate{base, child} {
+				if err := env.Client.Get(ctx, client.ObjectKeyFromObject(nodeTemplate), &v1alpha1.AWSNodeTemplate{}); err == nil {
+					ExpectFinalizersRemoved(ctx, env.Client, nodeTemplate)
+				}
+			}
+			ExpectDeleted(ctx, env.Client, base, child)
+		})
+		It("should resolve the base template", func() {
+			ExpectApplied(ctx, env.Client, base, child)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(child))
+
+			child = expectNodeTemplate(child)
+			Expect(child.StatusConditions().GetCondition(v1alpha1.BaseTemplateResolved).IsTrue()).To(BeTrue())
+			// The merged settings are only resolved at launch and aren't written back to the template
+			Expect(child.Spec.SubnetSelector).To(BeEmpty())
+		})
+		It("should mark templates without a base template as resolved", func() {
+			ExpectApplied(ctx, env.Client, base, child)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(base))
+
+			Expect(expectNodeTemplate(base).StatusConditions().GetCondition(v1alpha1.BaseTemplateResolved).IsTrue()).To(BeTrue())
+		})
+		It("should fail to resolve a base template that doesn't exist", func() {
+			ExpectApplied(ctx, env.Client, child)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(child))
+
+			condition := expectNodeTemplate(child).StatusConditions().GetCondition(v1alpha1.BaseTemplateResolved)
+			Expect(condition.IsFalse()).To(BeTrue())
+			Expect(condition.Reason).To(Equal("ResolutionFailed"))
+		})
+		It("should fail to resolve base templates that reference each other in a cycle", func() {
+			base.Spec.BaseTemplateRef = &v1alpha1.BaseTemplateRef{Name: child.Name}
+			ExpectApplied(ctx, env.Client, base, child)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(child))
+
+			condition := expectNodeTemplate(child).StatusConditions().GetCondition(v1alpha1.BaseTemplateResolved)
+			Expect(condition.IsFalse()).To(BeTrue())
+			Expect(condition.Message).To(ContainSubstring("cycle"))
+		})
+		It("should fail when the merged settings conflict", func() {
+			base.Spec.LaunchTemplateName = aws.String("my-launch-template")
+			base.Spec.SecurityGroupSelector = nil
+			child.Spec.UserData = aws.String("#!/bin/bash")
+			ExpectApplied(ctx, env.Client, base, child)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(child))
+
+			condition := expectNodeTemplate(child).StatusConditions().GetCondition(v1alpha1.BaseTemplateResolved)
+			Expect(condition.IsFalse()).To(BeTrue())
+			Expect(condition.Reason).To(Equal("InvalidMergedSettings"))
+		})
+		It("should resolve the base template once it's created", func() {
+			ExpectApplied(ctx, env.Client, child)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(child))
+			Expect(expectNodeTemplate(child).StatusConditions().GetCondition(v1alpha1.BaseTemplateResolved).IsFalse()).To(BeTrue())
+
+			ExpectApplied(ctx, env.Client, base)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(child))
+			Expect(expectNodeTemplate(child).StatusConditions().GetCondition(v1alpha1.BaseTemplateResolved).IsTrue()).To(BeTrue())
+		})
+	})
+	Context("Effective Configuration", func() {
+		var nodeTemplate *v1alpha1.AWSNodeTemplate
+		BeforeEach(func() {
+			nodeTemplate = test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{AWS: v1alpha1.AWS{
+				SubnetSelector:        map[string]string{"*": "*"},
+				SecurityGroupSelector: map[string]string{"*": "*"},
+			}})
+		})
+		AfterEach(func() {
+			ExpectFinalizersRemoved(ctx, env.Client, nodeTemplate)
+			ExpectDeleted(ctx, env.Client, nodeTemplate)
+		})
+		It("should record the resolved subnets, security groups and defaults", func() {
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			configuration := expectNodeTemplate(nodeTemplate).Status.EffectiveConfiguration
+			Expect(configuration).ToNot(BeNil())
+			Expect(configuration.AMIFamily).To(Equal(v1alpha1.AMIFamilyAL2))
+			Expect(configuration.Subnets).To(Equal([]string{"subnet-test1", "subnet-test2", "subnet-test3"}))
+			Expect(configuration.SecurityGroups).To(Equal([]string{"sg-test1", "sg-test2", "sg-test3"}))
+			Expect(configuration.InstanceProfile).To(Equal("test-instance-profile"))
+			Expect(configuration.BlockDeviceMappings).To(HaveLen(1))
+			Expect(aws.StringValue(configuration.BlockDeviceMappings[0].DeviceName)).To(Equal("/dev/xvda"))
+			Expect(configuration.AMIs).To(BeEmpty())
+			Expect(configuration.UserDataHash).To(BeEmpty())
+		})
+		It("should record the amis selected by the amiSelector", func() {
+			nodeTemplate.Spec.AMISelector = map[string]string{"foo": "bar"}
+			ec2api.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+				{ImageId: aws.String("ami-456"), Architecture: aws.String("x86_64"), CreationDate: aws.String("2022-08-15T12:00:00Z")},
+				{ImageId: aws.String("ami-123"), Architecture: aws.String("arm64"), CreationDate: aws.String("2022-08-15T12:00:00Z")},
+			}})
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			Expect(expectNodeTemplate(nodeTemplate).Status.EffectiveConfiguration.AMIs).To(Equal([]string{"ami-123", "ami-456"}))
+		})
+		Context("AMI Selection", func() {
+			BeforeEach(func() {
+				nodeTemplate.Spec.AMISelector = map[string]string{"foo": "bar"}
+			})
+			expectAMISelectionCondition := func() *knativeapis.Condition {
+				return expectNodeTemplate(nodeTemplate).StatusConditions().GetCondition(v1alpha1.AMISelectionUnambiguous)
+			}
+			It("should mark the amis unambiguous when each architecture has its own ami", func() {
+				ec2api.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+					{ImageId: aws.String("ami-amd64"), Architecture: aws.String("x86_64"), CreationDate: aws.String("2022-08-15T12:00:00Z")},
+					{ImageId: aws.String("ami-arm64"), Architecture: aws.String("arm64"), CreationDate: aws.String("2022-08-15T12:00:00Z")},
+				}})
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+				Expect(expectAMISelectionCondition().IsTrue()).To(BeTrue())
+			})
+			It("should mark the amis ambiguous when two amis have the same architecture", func() {
+				ec2api.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+					{ImageId: aws.String("ami-amd64"), Architecture: aws.String("x86_64"), CreationDate: aws.String("2022-08-15T12:00:00Z")},
+					{ImageId: aws.String("ami-arm64-old"), Architecture: aws.String("arm64"), CreationDate: aws.String("2022-08-10T12:00:00Z")},
+					{ImageId: aws.String("ami-arm64-new"), Architecture: aws.String("arm64"), CreationDate: aws.String("2022-08-15T12:00:00Z")},
+				}})
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+				condition := expectAMISelectionCondition()
+				Expect(condition.IsFalse()).To(BeTrue())
+				Expect(condition.Reason).To(Equal("AmbiguousAMIs"))
+				Expect(condition.Message).To(Equal("amis ami-arm64-new, ami-arm64-old have the same requirements, ami-arm64-new is used since it's the newest"))
+				Expect(condition.Severity).To(Equal(knativeapis.ConditionSeverityInfo))
+			})
+			It("should not mark amis of the same architecture ambiguous when their tags tell them apart", func() {
+				ec2api.DescribeImagesOutput.Set(&ec2.DescribeImagesOutput{Images: []*ec2.Image{
+					{ImageId: aws.String("ami-amd64"), Architecture: aws.String("x86_64"), CreationDate: aws.String("2022-08-15T12:00:00Z")},
+					{ImageId: aws.String("ami-amd64-gpu"), Architecture: aws.String("x86_64"), CreationDate: aws.String("2022-08-15T12:00:00Z"),
+						Tags: []*ec2.Tag{{Key: aws.String(v1.LabelInstanceTypeStable), Value: aws.String("p3.8xlarge")}}},
+				}})
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+				Expect(expectAMISelectionCondition().IsTrue()).To(BeTrue())
+			})
+			It("should not record the condition without an amiSelector", func() {
+				nodeTemplate.Spec.AMISelector = nil
+				ExpectApplied(ctx, env.Client, nodeTemplate)
+				ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+				Expect(expectAMISelectionCondition()).To(BeNil())
+			})
+		})
+		It("should record the pinned amis", func() {
+			nodeTemplate.Spec.PinAMI = aws.Bool(true)
+			nodeTemplate.Status.PinnedAMIs = map[string]string{
+				"/aws/service/eks/optimized-ami/1.24/amazon-linux-2/recommended/image_id":       "ami-amd64",
+				"/aws/service/eks/optimized-ami/1.24/amazon-linux-2-arm64/recommended/image_id": "ami-arm64",
+			}
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			Expect(expectNodeTemplate(nodeTemplate).Status.EffectiveConfiguration.AMIs).To(Equal([]string{"ami-amd64", "ami-arm64"}))
+		})
+		It("should only record a hash of the user data", func() {
+			nodeTemplate.Spec.UserData = aws.String("#!/bin/bash\nexport SECRET=hunter2")
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			configuration := expectNodeTemplate(nodeTemplate).Status.EffectiveConfiguration
+			Expect(configuration.UserDataHash).ToNot(BeEmpty())
+			Expect(configuration.UserDataHash).ToNot(ContainSubstring("hunter2"))
+		})
+		It("should only record the subnets and launch template when using a launch template", func() {
+			nodeTemplate.Spec.SecurityGroupSelector = nil
+			nodeTemplate.Spec.LaunchTemplateName = aws.String("my-launch-template")
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			configuration := expectNodeTemplate(nodeTemplate).Status.EffectiveConfiguration
+			Expect(configuration.LaunchTemplateName).To(Equal("my-launch-template"))
+			Expect(configuration.Subnets).To(Equal([]string{"subnet-test1", "subnet-test2", "subnet-test3"}))
+			Expect(configuration.SecurityGroups).To(BeEmpty())
+			Expect(configuration.BlockDeviceMappings).To(BeEmpty())
+		})
+		It("should record the settings inherited from the base template", func() {
+			base := test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{AWS: v1alpha1.AWS{
+				AMIFamily:             aws.String(v1alpha1.AMIFamilyBottlerocket),
+				InstanceProfile:       aws.String("base-instance-profile"),
+				SubnetSelector:        map[string]string{"*": "*"},
+				SecurityGroupSelector: map[string]string{"*": "*"},
+			}})
+			nodeTemplate = test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{BaseTemplateRef: &v1alpha1.BaseTemplateRef{Name: base.Name}})
+			ExpectApplied(ctx, env.Client, base, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			configuration := expectNodeTemplate(nodeTemplate).Status.EffectiveConfiguration
+			Expect(configuration.AMIFamily).To(Equal(v1alpha1.AMIFamilyBottlerocket))
+			Expect(configuration.InstanceProfile).To(Equal("base-instance-profile"))
+			// Bottlerocket has a control volume and a data volume by default
+			Expect(configuration.BlockDeviceMappings).To(HaveLen(2))
+		})
+		It("should keep the last configuration when resolving it fails", func() {
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			Expect(expectNodeTemplate(nodeTemplate).Status.EffectiveConfiguration.Subnets).To(HaveLen(3))
+
+			ec2api.DescribeSubnetsOutput.Set(&ec2.DescribeSubnetsOutput{})
+			nodeTemplate = expectNodeTemplate(nodeTemplate)
+			nodeTemplate.Spec.SubnetSelector = map[string]string{"foo": "baz"}
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			Expect(expectNodeTemplate(nodeTemplate).Status.EffectiveConfiguration.Subnets).To(HaveLen(3))
+		})
+	})
+	Context("AMIs", func() {
+		const ssmQuery = "/aws/service/eks/optimized-ami/1.24/amazon-linux-2/recommended/image_id"
+		var nodeTemplate *v1alpha1.AWSNodeTemplate
+		BeforeEach(func() {
+			nodeTemplate = test.AWSNodeTemplate(v1alpha1.AWSNodeTemplateSpec{AWS: v1alpha1.AWS{
+				SubnetSelector:        map[string]string{"*": "*"},
+				SecurityGroupSelector: map[string]string{"*": "*"},
+			}})
+			ssmapi.GetParameterOutput = &ssm.GetParameterOutput{Parameter: &ssm.Parameter{Value: aws.String("ami-old")}}
+		})
+		It("should publish an event when the ami resolved for a recorded ssm query changes", func() {
+			ssmQueries.Record(nodeTemplate.Name, ssmQuery)
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			Expect(recorder.Calls("AMIChanged")).To(BeZero())
+
+			ssmapi.GetParameterOutput = &ssm.GetParameterOutput{Parameter: &ssm.Parameter{Value: aws.String("ami-new")}}
+			ssmCache.Flush()
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			Expect(recorder.Calls("AMIChanged")).To(Equal(1))
+			ExpectFinalizersRemoved(ctx, env.Client, nodeTemplate)
+			ExpectDeleted(ctx, env.Client, nodeTemplate)
+		})
+		It("should not publish an event when the ami resolved for a recorded ssm query is unchanged", func() {
+			ssmQueries.Record(nodeTemplate.Name, ssmQuery)
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			ssmCache.Flush()
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			Expect(recorder.Calls("AMIChanged")).To(BeZero())
+			ExpectFinalizersRemoved(ctx, env.Client, nodeTemplate)
+			ExpectDeleted(ctx, env.Client, nodeTemplate)
+		})
+		It("should not resolve amis for a node template that hasn't launched nodes", func() {
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			Expect(ssmapi.CalledWithGetParameterInput.Len()).To(BeZero())
+			ExpectFinalizersRemoved(ctx, env.Client, nodeTemplate)
+			ExpectDeleted(ctx, env.Client, nodeTemplate)
+		})
+		It("should forget the ssm queries of a deleted node template", func() {
+			ssmQueries.Record(nodeTemplate.Name, ssmQuery)
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+
+			Expect(env.Client.Delete(ctx, nodeTemplate)).To(Succeed())
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			Expect(ssmQueries.List(nodeTemplate.Name)).To(BeEmpty())
+		})
+		It("should pin the amis of the recorded ssm queries when pinning is enabled", func() {
+			nodeTemplate.Spec.PinAMI = aws.Bool(true)
+			ssmQueries.Record(nodeTemplate.Name, ssmQuery)
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			Expect(expectNodeTemplate(nodeTemplate).Status.PinnedAMIs).To(Equal(map[string]string{ssmQuery: "ami-old"}))
+			ExpectFinalizersRemoved(ctx, env.Client, nodeTemplate)
+			ExpectDeleted(ctx, env.Client, nodeTemplate)
+		})
+		It("should keep the pinned amis when the ami resolved for an ssm query changes", func() {
+			nodeTemplate.Spec.PinAMI = aws.Bool(true)
+			ssmQueries.Record(nodeTemplate.Name, ssmQuery)
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+
+			ssmapi.GetParameterOutput = &ssm.GetParameterOutput{Parameter: &ssm.Parameter{Value: aws.String("ami-new")}}
+			ssmCache.Flush()
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			Expect(expectNodeTemplate(nodeTemplate).Status.PinnedAMIs).To(Equal(map[string]string{ssmQuery: "ami-old"}))
+			ExpectFinalizersRemoved(ctx, env.Client, nodeTemplate)
+			ExpectDeleted(ctx, env.Client, nodeTemplate)
+		})
+		It("should replace the pinned amis when the rollforward annotation changes", func() {
+			nodeTemplate.Spec.PinAMI = aws.Bool(true)
+			ssmQueries.Record(nodeTemplate.Name, ssmQuery)
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+
+			ssmapi.GetParameterOutput = &ssm.GetParameterOutput{Parameter: &ssm.Parameter{Value: aws.String("ami-new")}}
+			ssmCache.Flush()
+			nodeTemplate = expectNodeTemplate(nodeTemplate)
+			nodeTemplate.Annotations = lo.Assign(nodeTemplate.Annotations, map[string]string{v1alpha1.AnnotationAMIRollforward: "1"})
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			nodeTemplate = expectNodeTemplate(nodeTemplate)
+			Expect(nodeTemplate.Status.PinnedAMIs).To(Equal(map[string]string{ssmQuery: "ami-new"}))
+			Expect(nodeTemplate.Status.AMIRollforward).To(Equal("1"))
+			ExpectFinalizersRemoved(ctx, env.Client, nodeTemplate)
+			ExpectDeleted(ctx, env.Client, nodeTemplate)
+		})
+		It("should not pin amis when pinning is disabled", func() {
+			ssmQueries.Record(nodeTemplate.Name, ssmQuery)
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			Expect(expectNodeTemplate(nodeTemplate).Status.PinnedAMIs).To(BeEmpty())
+			ExpectFinalizersRemoved(ctx, env.Client, nodeTemplate)
+			ExpectDeleted(ctx, env.Client, nodeTemplate)
+		})
+		It("should mark the amis as not ready while an ssm query can't be resolved", func() {
+			ssmapi.WantErr = fmt.Errorf("ssm is unavailable")
+			ssmQueries.Record(nodeTemplate.Name, ssmQuery)
+			ExpectApplied(ctx, env.Client, nodeTemplate)
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			condition := expectNodeTemplate(nodeTemplate).StatusConditions().GetCondition(v1alpha1.AMIReady)
+			Expect(condition.IsFalse()).To(BeTrue())
+			Expect(condition.Reason).To(Equal("SSMResolutionFailed"))
+
+			ssmapi.WantErr = nil
+			ssmCache.Flush()
+			ExpectReconcileSucceeded(ctx, controller, client.ObjectKeyFromObject(nodeTemplate))
+			Expect(expectNodeTemplate(nodeTemplate).StatusConditions().GetCondition(v1alpha1.AMIReady).IsTrue()).To(BeTrue())
+			ExpectFinalizersRemoved(ctx, env.Client, nodeTemplate)
+			ExpectDeleted(ctx, env.Client, nodeTemplate)
+		})
+	})
 })
 
+func expectNodeTemplate(nodeTemplate *v1alpha1.AWSNodeTemplate) *v1alpha1.AWSNodeTemplate {
+	stored := &v1alpha1.AWSNodeTemplate{}
+	Expect(env.Client.Get(ctx, client.ObjectKeyFromObject(nodeTemplate), stored)).To(Succeed())
+	return stored
+}
+
+// ExpectReconcilesValue returns the count of infrastructure reconciles with the result, or 0 if there weren't any yet
+func ExpectReconcilesValue(result string) float64 {
+	metric, ok := findMetric("karpenter_interruption_infrastructure_reconciles", "result", result)
+	if !ok {
+		return 0
+	}
+	return metric.GetCounter().GetValue()
+}
+
+// ExpectReconcileDurationCount returns the number of infrastructure reconciles whose duration was measured
+func ExpectReconcileDurationCount() uint64 {
+	metric, ok := findMetric("karpenter_interruption_infrastructure_reconcile_time_seconds", "", "")
+	if !ok {
+		return 0
+	}
+	return metric.GetHistogram().GetSampleCount()
+}
+
+func findMetric(name, labelName, labelValue string) (*dto.Metric, bool) {
+	families, err := crmetrics.Registry.Gather()
+	ExpectWithOffset(2, err).ToNot(HaveOccurred())
+	family, ok := lo.Find(families, func(f *dto.MetricFamily) bool { return f.GetName() == name })
+	if !ok {
+		return nil, false
+	}
+	return lo.Find(family.GetMetric(), func(m *dto.Metric) bool {
+		return labelName == "" || lo.ContainsBy(m.GetLabel(), func(l *dto.LabelPair) bool {
+			return l.GetName() == labelName && l.GetValue() == labelValue
+		})
+	})
+}
+
 func awsErrWithCode(code string) awserr.Error {
 	return awserr.New(code, "", fmt.Errorf(""))
 }
+
+func eventBridgeTags(tags []*eventbridge.Tag) map[string]string {
+	return lo.SliceToMap(tags, func(t *eventbridge.Tag) (string, string) {
+		return aws.StringValue(t.Key), aws.StringValue(t.Value)
+	})
+}
@@ -27,8 +27,10 @@ import (
 	"go.uber.org/multierr"
 	"k8s.io/apimachinery/pkg/util/rand"
 	"k8s.io/client-go/util/workqueue"
+	"knative.dev/pkg/logging"
 
 	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/utils/atomic"
 	"github.com/aws/karpenter/pkg/apis/config/settings"
 	awserrors "github.com/aws/karpenter/pkg/errors"
 )
@@ -79,6 +81,10 @@ type Rule struct {
 
 const QueueTargetID = "KarpenterEventQueue"
 
+// generatedRuleNamePattern matches the names that Karpenter generates for its rules, e.g.
+// "Karpenter-RebalanceRule-<random suffix>"
+var generatedRuleNamePattern = regexp.MustCompile(`^Karpenter-[a-zA-Z]+Rule-[a-z0-9]+$`)
+
 func (er Rule) addQueueTarget(queueARN string) Rule {
 	er.Target = Target{
 		ID:  QueueTargetID,
@@ -114,7 +120,9 @@ func NewEventBridge(eb eventbridgeiface.EventBridgeAPI, sqsProvider *SQS) *Event
 }
 
 func (eb *EventBridge) CreateRules(ctx context.Context) error {
-	queueARN, err := eb.sqsProvider.queueARN.TryGet(ctx)
+	// The queue may have been recreated with a new ARN since the ARN was cached, so it's resolved again to make sure
+	// that the rules target the current queue
+	queueARN, err := eb.sqsProvider.queueARN.TryGet(ctx, atomic.IgnoreCacheOption)
 	if err != nil {
 		return fmt.Errorf("resolving queue arn, %w", err)
 	}
@@ -127,30 +135,67 @@ func (eb *EventBridge) CreateRules(ctx context.Context) error {
 	})
 	errs := make([]error, len(rules))
 	workqueue.ParallelizeUntil(ctx, len(rules), len(rules), func(i int) {
-		_, err := eb.client.PutRuleWithContext(ctx, &eventbridge.PutRuleInput{
+		out, err := eb.client.PutRuleWithContext(ctx, &eventbridge.PutRuleInput{
 			Name:         aws.String(rules[i].Name),
 			EventPattern: aws.String(string(rules[i].Pattern.Serialize())),
 			Tags:         eb.getTags(ctx),
 		})
 		if err != nil {
 			errs[i] = multierr.Append(errs[i], err)
+		} else if err = eb.tagRule(ctx, out.RuleArn); err != nil {
+			errs[i] = multierr.Append(errs[i], err)
 		}
-		_, err = eb.client.PutTargetsWithContext(ctx, &eventbridge.PutTargetsInput{
-			Rule: aws.String(rules[i].Name),
-			Targets: []*eventbridge.Target{
-				{
-					Id:  aws.String(rules[i].Target.ID),
-					Arn: aws.String(rules[i].Target.ARN),
-				},
-			},
-		})
-		if err != nil {
+		if err = eb.ensureTarget(ctx, rules[i]); err != nil {
 			errs[i] = multierr.Append(errs[i], err)
 		}
 	})
 	return multierr.Combine(errs...)
 }
 
+// ensureTarget points the rule at its target, unless the rule already targets it. Rules that still target a queue
+// that has since been recreated are updated, so that events aren't lost.
+func (eb *EventBridge) ensureTarget(ctx context.Context, rule Rule) error {
+	out, err := eb.client.ListTargetsByRuleWithContext(ctx, &eventbridge.ListTargetsByRuleInput{
+		Rule: aws.String(rule.Name),
+	})
+	if err != nil && !awserrors.IsNotFound(err) {
+		return fmt.Errorf("listing targets, %w", err)
+	}
+	if out != nil {
+		target, ok := lo.Find(out.Targets, func(t *eventbridge.Target) bool { return aws.StringValue(t.Id) == rule.Target.ID })
+		if ok && aws.StringValue(target.Arn) == rule.Target.ARN {
+			return nil
+		}
+		if ok {
+			logging.FromContext(ctx).With("rule", rule.Name, "target", aws.StringValue(target.Arn)).Infof("Updating the rule to target the current interruption queue")
+		}
+	}
+	if _, err = eb.client.PutTargetsWithContext(ctx, &eventbridge.PutTargetsInput{
+		Rule: aws.String(rule.Name),
+		Targets: []*eventbridge.Target{
+			{
+				Id:  aws.String(rule.Target.ID),
+				Arn: aws.String(rule.Target.ARN),
+			},
+		},
+	}); err != nil {
+		return fmt.Errorf("putting targets, %w", err)
+	}
+	return nil
+}
+
+// tagRule applies the tags to an existing rule, since PutRule only tags the rules that it creates. This overwrites
+// the values of tags that have drifted.
+func (eb *EventBridge) tagRule(ctx context.Context, ruleARN *string) error {
+	if _, err := eb.client.TagResourceWithContext(ctx, &eventbridge.TagResourceInput{
+		ResourceARN: ruleARN,
+		Tags:        eb.getTags(ctx),
+	}); err != nil {
+		return fmt.Errorf("tagging rule, %w", err)
+	}
+	return nil
+}
+
 func (eb *EventBridge) DiscoverRules(ctx context.Context) (map[string]Rule, error) {
 	m := map[string]Rule{}
 	output, err := eb.client.ListRulesWithContext(ctx, &eventbridge.ListRulesInput{
@@ -165,7 +210,10 @@ func (eb *EventBridge) DiscoverRules(ctx context.Context) (map[string]Rule, erro
 		})
 		// If we get access denied, that means the tag-based policy didn't allow us to get the tags from the rule
 		// which means it isn't a rule that we created for this cluster anyways
-		if err != nil && !awserrors.IsAccessDenied(err) {
+		if err != nil {
+			if awserrors.IsAccessDenied(err) {
+				continue
+			}
 			return nil, fmt.Errorf("describing rules, %w", err)
 		}
 		for _, tag := range out.Tags {
@@ -193,26 +241,61 @@ func (eb *EventBridge) DeleteRules(ctx context.Context) error {
 	rules := lo.Values(out)
 	errs := make([]error, len(rules))
 	workqueue.ParallelizeUntil(ctx, len(rules), len(rules), func(i int) {
-		targetInput := &eventbridge.RemoveTargetsInput{
-			Ids:  []*string{aws.String(QueueTargetID)},
-			Rule: aws.String(rules[i].Name),
-		}
-		_, err := eb.client.RemoveTargetsWithContext(ctx, targetInput)
-		if err != nil && !awserrors.IsNotFound(err) {
-			errs[i] = err
+		errs[i] = eb.deleteRule(ctx, rules[i].Name)
+	})
+	return multierr.Combine(errs...)
+}
+
+// DeleteOrphanedRules deletes the rules that older versions of Karpenter created for the cluster with a rule type
+// that's no longer in DefaultRules, since they keep delivering events to the queue. Only rules that follow the naming
+// convention of the rules that Karpenter creates and that target nothing but the queue are deleted, so that rules
+// that users added are left alone.
+func (eb *EventBridge) DeleteOrphanedRules(ctx context.Context) error {
+	existing, err := eb.DiscoverRules(ctx)
+	if err != nil {
+		return fmt.Errorf("discovering existing rules, %w", err)
+	}
+	orphans := lo.Filter(lo.Values(lo.OmitByKeys(existing, lo.Keys(DefaultRules))), func(r Rule, _ int) bool {
+		return generatedRuleNamePattern.MatchString(r.Name)
+	})
+	errs := make([]error, len(orphans))
+	workqueue.ParallelizeUntil(ctx, len(orphans), len(orphans), func(i int) {
+		out, err := eb.client.ListTargetsByRuleWithContext(ctx, &eventbridge.ListTargetsByRuleInput{
+			Rule: aws.String(orphans[i].Name),
+		})
+		if err != nil {
+			if !awserrors.IsNotFound(err) {
+				errs[i] = fmt.Errorf("listing targets, %w", err)
+			}
 			return
 		}
-		ruleInput := &eventbridge.DeleteRuleInput{
-			Name: aws.String(rules[i].Name),
-		}
-		_, err = eb.client.DeleteRuleWithContext(ctx, ruleInput)
-		if err != nil && !awserrors.IsNotFound(err) {
-			errs[i] = err
+		if !lo.EveryBy(out.Targets, func(t *eventbridge.Target) bool { return aws.StringValue(t.Id) == QueueTargetID }) {
+			return
 		}
+		logging.FromContext(ctx).With("rule", orphans[i].Name).Infof("Deleting an interruption rule that's no longer used")
+		errs[i] = eb.deleteRule(ctx, orphans[i].Name)
 	})
 	return multierr.Combine(errs...)
 }
 
+// deleteRule removes the queue target from the rule and deletes it. Rules that no longer exist are treated as deleted.
+func (eb *EventBridge) deleteRule(ctx context.Context, name string) error {
+	_, err := eb.client.RemoveTargetsWithContext(ctx, &eventbridge.RemoveTargetsInput{
+		Ids:  []*string{aws.String(QueueTargetID)},
+		Rule: aws.String(name),
+	})
+	if err != nil && !awserrors.IsNotFound(err) {
+		return err
+	}
+	_, err = eb.client.DeleteRuleWithContext(ctx, &eventbridge.DeleteRuleInput{
+		Name: aws.String(name),
+	})
+	if err != nil && !awserrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
 // mergeRules merges the existing rules with the default rules based on the rule type
 func (eb *EventBridge) mergeRules(existing map[string]Rule) map[string]Rule {
 	rules := lo.Assign(DefaultRules)
@@ -225,7 +308,11 @@ func (eb *EventBridge) mergeRules(existing map[string]Rule) map[string]Rule {
 	return rules
 }
 
+// getTags returns the tags of the rules. The discovery and managed-by tags take precedence over the configured tags,
+// so that the rules are always cleaned up by their discovery tag.
 func (eb *EventBridge) getTags(ctx context.Context) []*eventbridge.Tag {
+	tags := lo.OmitByKeys(lo.Assign(settings.FromContext(ctx).Tags, settings.FromContext(ctx).InterruptionInfrastructureTags),
+		[]string{v1alpha5.DiscoveryTagKey, v1alpha5.ManagedByTagKey})
 	return append(
 		[]*eventbridge.Tag{
 			{
@@ -237,7 +324,7 @@ func (eb *EventBridge) getTags(ctx context.Context) []*eventbridge.Tag {
 				Value: aws.String(settings.FromContext(ctx).ClusterName),
 			},
 		},
-		lo.MapToSlice(settings.FromContext(ctx).Tags, func(k, v string) *eventbridge.Tag {
+		lo.MapToSlice(tags, func(k, v string) *eventbridge.Tag {
 			return &eventbridge.Tag{
 				Key:   aws.String(k),
 				Value: aws.String(v),
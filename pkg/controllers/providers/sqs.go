@@ -18,6 +18,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/sqs"
@@ -27,9 +31,17 @@ import (
 	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
 	"github.com/aws/karpenter-core/pkg/utils/atomic"
 	"github.com/aws/karpenter/pkg/apis/config/settings"
+	"github.com/aws/karpenter/pkg/controllers/interruption/messages"
 	awserrors "github.com/aws/karpenter/pkg/errors"
 )
 
+const (
+	// DefaultVisibilityTimeout is the visibility timeout applied to messages when they are received from the queue
+	DefaultVisibilityTimeout = time.Second * 20
+	// MaxVisibilityTimeout is the maximum visibility timeout that SQS allows for a message
+	MaxVisibilityTimeout = time.Hour * 12
+)
+
 type queuePolicy struct {
 	Version   string                 `json:"Version"`
 	ID        string                 `json:"Id"`
@@ -106,6 +118,23 @@ func (s *SQS) CreateQueue(ctx context.Context) error {
 	return nil
 }
 
+// TagQueue applies the tags to the existing queue, overwriting the values of tags that have drifted. Tags that are no
+// longer configured are left on the queue, since they can't be told apart from tags that were applied out-of-band.
+func (s *SQS) TagQueue(ctx context.Context) error {
+	queueURL, err := s.DiscoverQueueURL(ctx)
+	if err != nil {
+		return fmt.Errorf("fetching queue url, %w", err)
+	}
+	_, err = s.client.TagQueueWithContext(ctx, &sqs.TagQueueInput{
+		QueueUrl: aws.String(queueURL),
+		Tags:     s.getTags(ctx),
+	})
+	if err != nil {
+		return fmt.Errorf("tagging queue, %w", err)
+	}
+	return nil
+}
+
 func (s *SQS) SetQueueAttributes(ctx context.Context, attributeOverrides map[string]*string) error {
 	queueURL, err := s.DiscoverQueueURL(ctx)
 	if err != nil {
@@ -129,6 +158,34 @@ func (s *SQS) SetQueueAttributes(ctx context.Context, attributeOverrides map[str
 	return nil
 }
 
+// ConfirmQueueAttributes returns an error if the attributes of the queue don't match the attributes that
+// SetQueueAttributes applies, e.g. since setting them failed after the queue was created
+func (s *SQS) ConfirmQueueAttributes(ctx context.Context) error {
+	queueURL, err := s.DiscoverQueueURL(ctx)
+	if err != nil {
+		return fmt.Errorf("fetching queue url, %w", err)
+	}
+	expected, err := s.getQueueAttributes(ctx)
+	if err != nil {
+		return fmt.Errorf("marshaling queue attributes, %w", err)
+	}
+	ret, err := s.client.GetQueueAttributesWithContext(ctx, &sqs.GetQueueAttributesInput{
+		AttributeNames: aws.StringSlice(lo.Keys(expected)),
+		QueueUrl:       aws.String(queueURL),
+	})
+	if err != nil {
+		return fmt.Errorf("getting queue attributes, %w", err)
+	}
+	mismatched := lo.Filter(lo.Keys(expected), func(name string, _ int) bool {
+		return !queueAttributeMatches(name, aws.StringValue(expected[name]), aws.StringValue(ret.Attributes[name]))
+	})
+	if len(mismatched) > 0 {
+		sort.Strings(mismatched)
+		return fmt.Errorf("queue attributes %s aren't configured", strings.Join(mismatched, ", "))
+	}
+	return nil
+}
+
 func (s *SQS) QueueExists(ctx context.Context) (bool, error) {
 	_, err := s.queueURL.TryGet(ctx, atomic.IgnoreCacheOption)
 	if err != nil {
@@ -156,10 +213,11 @@ func (s *SQS) GetSQSMessages(ctx context.Context) ([]*sqs.Message, error) {
 
 	input := &sqs.ReceiveMessageInput{
 		MaxNumberOfMessages: aws.Int64(10),
-		VisibilityTimeout:   aws.Int64(20), // Seconds
+		VisibilityTimeout:   aws.Int64(int64(DefaultVisibilityTimeout.Seconds())),
 		WaitTimeSeconds:     aws.Int64(20), // Seconds, maximum for long polling
 		AttributeNames: []*string{
 			aws.String(sqs.MessageSystemAttributeNameSentTimestamp),
+			aws.String(sqs.MessageSystemAttributeNameApproximateReceiveCount),
 		},
 		MessageAttributeNames: []*string{
 			aws.String(sqs.QueueAttributeNameAll),
@@ -175,10 +233,20 @@ func (s *SQS) GetSQSMessages(ctx context.Context) ([]*sqs.Message, error) {
 	return result.Messages, nil
 }
 
-func (s *SQS) SendMessage(ctx context.Context, body interface{}) (string, error) {
-	raw, err := json.Marshal(body)
+// SendMessage sends the message to the queue as the JSON body of an EventBridge event, the same way that EventBridge
+// delivers the events that the interruption controller handles. This allows synthetic interruption events to be
+// injected, e.g. to validate interruption handling. The supported event shapes are
+//   - spotinterruption.Message: "EC2 Spot Instance Interruption Warning" from aws.ec2
+//   - rebalancerecommendation.Message: "EC2 Instance Rebalance Recommendation" from aws.ec2
+//   - statechange.Message: "EC2 Instance State-change Notification" from aws.ec2
+//   - scheduledchange.Message: "AWS Health Event" from aws.health
+//
+// The Metadata of the message must carry the source, detail type and version of its event, or it's parsed as a no-op.
+// SendMessage returns the ID that SQS assigned to the message.
+func (s *SQS) SendMessage(ctx context.Context, msg messages.Message) (string, error) {
+	raw, err := json.Marshal(msg)
 	if err != nil {
-		return "", fmt.Errorf("marshaling the passed body as json, %w", err)
+		return "", fmt.Errorf("marshaling the passed message as json, %w", err)
 	}
 	queueURL, err := s.DiscoverQueueURL(ctx)
 	if err != nil {
@@ -188,7 +256,7 @@ func (s *SQS) SendMessage(ctx context.Context, body interface{}) (string, error)
 		MessageBody: aws.String(string(raw)),
 		QueueUrl:    aws.String(queueURL),
 	}
-	result, err := s.client.SendMessage(input)
+	result, err := s.client.SendMessageWithContext(ctx, input)
 	if err != nil {
 		return "", fmt.Errorf("sending messages to sqs queue, %w", err)
 	}
@@ -213,6 +281,27 @@ func (s *SQS) DeleteSQSMessage(ctx context.Context, msg *sqs.Message) error {
 	return nil
 }
 
+// ChangeMessageVisibility extends the time that the passed message stays hidden from other receivers of the queue.
+// The timeout is capped at the maximum visibility timeout that SQS allows.
+func (s *SQS) ChangeMessageVisibility(ctx context.Context, msg *sqs.Message, timeout time.Duration) error {
+	queueURL, err := s.DiscoverQueueURL(ctx)
+	if err != nil {
+		return fmt.Errorf("fetching queue url, %w", err)
+	}
+	if timeout > MaxVisibilityTimeout {
+		timeout = MaxVisibilityTimeout
+	}
+	input := &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(queueURL),
+		ReceiptHandle:     msg.ReceiptHandle,
+		VisibilityTimeout: aws.Int64(int64(timeout.Seconds())),
+	}
+	if _, err = s.client.ChangeMessageVisibilityWithContext(ctx, input); err != nil {
+		return fmt.Errorf("changing sqs message visibility, %w", err)
+	}
+	return nil
+}
+
 func (s *SQS) DeleteQueue(ctx context.Context) error {
 	queueURL, err := s.DiscoverQueueURL(ctx)
 	if err != nil {
@@ -244,6 +333,21 @@ func (s *SQS) getQueueAttributes(ctx context.Context) (map[string]*string, error
 	}, nil
 }
 
+// queueAttributeMatches compares policies semantically, since SQS doesn't preserve the formatting of the policy
+func queueAttributeMatches(name, expected, actual string) bool {
+	if name != sqs.QueueAttributeNamePolicy {
+		return expected == actual
+	}
+	var expectedPolicy, actualPolicy interface{}
+	if err := json.Unmarshal([]byte(expected), &expectedPolicy); err != nil {
+		return false
+	}
+	if err := json.Unmarshal([]byte(actual), &actualPolicy); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(expectedPolicy, actualPolicy)
+}
+
 func (s *SQS) getQueuePolicy(ctx context.Context) (*queuePolicy, error) {
 	queueARN, err := s.DiscoverQueueARN(ctx)
 	if err != nil {
@@ -273,6 +377,9 @@ func (s *SQS) getTags(ctx context.Context) map[string]*string {
 		lo.MapEntries(settings.FromContext(ctx).Tags, func(k, v string) (string, *string) {
 			return k, lo.ToPtr(v)
 		}),
+		lo.MapEntries(settings.FromContext(ctx).InterruptionInfrastructureTags, func(k, v string) (string, *string) {
+			return k, lo.ToPtr(v)
+		}),
 		map[string]*string{
 			v1alpha5.DiscoveryTagKey: aws.String(settings.FromContext(ctx).ClusterName),
 			v1alpha5.ManagedByTagKey: aws.String(settings.FromContext(ctx).ClusterName),
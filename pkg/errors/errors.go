@@ -16,8 +16,11 @@ package errors
 
 import (
 	"errors"
+	"strings"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/eventbridge"
 	"github.com/aws/aws-sdk-go/service/sqs"
@@ -26,6 +29,9 @@ import (
 
 const (
 	launchTemplateNotFoundCode = "InvalidLaunchTemplateName.NotFoundException"
+	operationNotPermittedCode  = "OperationNotPermitted"
+	invalidParameterValueCode  = "InvalidParameterValue"
+	vcpuLimitExceededCode      = "VcpuLimitExceeded"
 	AccessDeniedCode           = "AccessDenied"
 	AccessDeniedExceptionCode  = "AccessDeniedException"
 )
@@ -34,6 +40,7 @@ var (
 	// This is not an exhaustive list, add to it as needed
 	notFoundErrorCodes = sets.NewString(
 		"InvalidInstanceID.NotFound",
+		"InvalidKeyPair.NotFound",
 		launchTemplateNotFoundCode,
 		sqs.ErrCodeQueueDoesNotExist,
 		(&eventbridge.ResourceNotFoundException{}).Code(),
@@ -42,7 +49,6 @@ var (
 	unfulfillableCapacityErrorCodes = sets.NewString(
 		"InsufficientInstanceCapacity",
 		"MaxSpotInstanceCountExceeded",
-		"VcpuLimitExceeded",
 		"UnfulfillableCapacity",
 		"Unsupported",
 	)
@@ -53,6 +59,12 @@ var (
 	recentlyDeletedErrorCodes = sets.NewString(
 		sqs.ErrCodeQueueDeletedRecently,
 	)
+	// transientInvalidParameterResources are the resources that CreateFleet may report as invalid parameter values
+	// while they don't exist yet, since EC2 is eventually consistent right after they're created
+	transientInvalidParameterResources = []string{
+		"launch template",
+		"subnet",
+	}
 )
 
 type InstanceTerminatedError struct {
@@ -71,6 +83,28 @@ func IsInstanceTerminated(err error) bool {
 	return errors.As(err, &itErr)
 }
 
+// CreateFleetTimeoutError is returned when a CreateFleet call does not complete within the configured timeout.
+// A timeout says nothing about the capacity of the requested offerings, so it is kept distinct from capacity errors.
+type CreateFleetTimeoutError struct {
+	Err error
+}
+
+func (e CreateFleetTimeoutError) Error() string {
+	return e.Err.Error()
+}
+
+func (e CreateFleetTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+func IsCreateFleetTimeout(err error) bool {
+	if err == nil {
+		return false
+	}
+	var timeoutErr CreateFleetTimeoutError
+	return errors.As(err, &timeoutErr)
+}
+
 // IsNotFound returns true if the err is an AWS error (even if it's
 // wrapped) and is a known to mean "not found" (as opposed to a more
 // serious or unexpected error)
@@ -112,6 +146,34 @@ func IsRecentlyDeleted(err error) bool {
 	return false
 }
 
+// IsRetryable returns true if the error is an AWS error (even if it's
+// wrapped) that is known to be throttling or otherwise transient, so that
+// the request can be retried
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var awsError awserr.Error
+	if errors.As(err, &awsError) {
+		return request.IsErrorThrottle(awsError) || request.IsErrorRetryable(awsError)
+	}
+	return false
+}
+
+// IsThrottled returns true if the error is an AWS error (even if it's
+// wrapped) that means the request was throttled, e.g. since the request
+// rate of the API was exceeded
+func IsThrottled(err error) bool {
+	if err == nil {
+		return false
+	}
+	var awsError awserr.Error
+	if errors.As(err, &awsError) {
+		return request.IsErrorThrottle(awsError)
+	}
+	return false
+}
+
 // IsUnfulfillableCapacity returns true if the Fleet err means
 // capacity is temporarily unavailable for launching.
 // This could be due to account limits, insufficient ec2 capacity, etc.
@@ -119,6 +181,13 @@ func IsUnfulfillableCapacity(err *ec2.CreateFleetError) bool {
 	return unfulfillableCapacityErrorCodes.Has(*err.ErrorCode)
 }
 
+// IsVCPULimitExceeded returns true if the Fleet err means that launching
+// would exceed the vCPU service quota of the account. Unlike capacity
+// shortages, retrying won't succeed until the quota is increased.
+func IsVCPULimitExceeded(err *ec2.CreateFleetError) bool {
+	return aws.StringValue(err.ErrorCode) == vcpuLimitExceededCode
+}
+
 func IsLaunchTemplateNotFound(err error) bool {
 	if err == nil {
 		return false
@@ -129,3 +198,52 @@ func IsLaunchTemplateNotFound(err error) bool {
 	}
 	return false
 }
+
+// IsOperationNotPermitted returns true if the error is an AWS error (even if it's wrapped) that means an attribute of
+// the resource prevents the operation, e.g. terminating an instance whose termination protection is enabled
+func IsOperationNotPermitted(err error) bool {
+	if err == nil {
+		return false
+	}
+	var awsError awserr.Error
+	if errors.As(err, &awsError) {
+		return awsError.Code() == operationNotPermittedCode
+	}
+	return false
+}
+
+// IsTransientInvalidParameterValue returns true if the error is an AWS error (even if it's wrapped) that reports an
+// invalid parameter value because a launch template or subnet doesn't exist yet. These resolve once EC2 is consistent,
+// unlike other invalid parameter values, which fail every time.
+func IsTransientInvalidParameterValue(err error) bool {
+	if err == nil {
+		return false
+	}
+	var awsError awserr.Error
+	if errors.As(err, &awsError) {
+		return isTransientInvalidParameterValue(awsError.Code(), awsError.Message())
+	}
+	return false
+}
+
+// IsTransientInvalidParameterValueFleetError returns true if the Fleet err reports an invalid parameter value because
+// a launch template or subnet doesn't exist yet
+func IsTransientInvalidParameterValueFleetError(err *ec2.CreateFleetError) bool {
+	return isTransientInvalidParameterValue(aws.StringValue(err.ErrorCode), aws.StringValue(err.ErrorMessage))
+}
+
+func isTransientInvalidParameterValue(code string, message string) bool {
+	if code != invalidParameterValueCode {
+		return false
+	}
+	message = strings.ToLower(message)
+	if !strings.Contains(message, "does not exist") && !strings.Contains(message, "not found") {
+		return false
+	}
+	for _, resource := range transientInvalidParameterResources {
+		if strings.Contains(message, resource) {
+			return true
+		}
+	}
+	return false
+}
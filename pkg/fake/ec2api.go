@@ -16,9 +16,13 @@ package fake
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"path"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/Pallinder/go-randomdata"
 	"github.com/aws/aws-sdk-go/aws"
@@ -27,6 +31,7 @@ import (
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/util/sets"
 
 	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
 
@@ -43,24 +48,36 @@ type CapacityPool struct {
 // EC2Behavior must be reset between tests otherwise tests will
 // pollute each other.
 type EC2Behavior struct {
-	DescribeInstancesOutput             AtomicPtr[ec2.DescribeInstancesOutput]
-	DescribeImagesOutput                AtomicPtr[ec2.DescribeImagesOutput]
-	DescribeLaunchTemplatesOutput       AtomicPtr[ec2.DescribeLaunchTemplatesOutput]
-	DescribeSubnetsOutput               AtomicPtr[ec2.DescribeSubnetsOutput]
-	DescribeSecurityGroupsOutput        AtomicPtr[ec2.DescribeSecurityGroupsOutput]
-	DescribeInstanceTypesOutput         AtomicPtr[ec2.DescribeInstanceTypesOutput]
-	DescribeInstanceTypeOfferingsOutput AtomicPtr[ec2.DescribeInstanceTypeOfferingsOutput]
-	DescribeAvailabilityZonesOutput     AtomicPtr[ec2.DescribeAvailabilityZonesOutput]
-	DescribeSpotPriceHistoryInput       AtomicPtr[ec2.DescribeSpotPriceHistoryInput]
-	DescribeSpotPriceHistoryOutput      AtomicPtr[ec2.DescribeSpotPriceHistoryOutput]
-	CalledWithCreateFleetInput          AtomicPtrSlice[ec2.CreateFleetInput]
-	CalledWithCreateLaunchTemplateInput AtomicPtrSlice[ec2.CreateLaunchTemplateInput]
-	CreateFleetOutput                   AtomicPtr[ec2.CreateFleetOutput]
-	CalledWithDescribeImagesInput       AtomicPtrSlice[ec2.DescribeImagesInput]
-	Instances                           sync.Map
-	LaunchTemplates                     sync.Map
-	InsufficientCapacityPools           atomic.Slice[CapacityPool]
-	NextError                           AtomicError
+	DescribeInstancesOutput                      AtomicPtr[ec2.DescribeInstancesOutput]
+	DescribeImagesOutput                         AtomicPtr[ec2.DescribeImagesOutput]
+	DescribeKeyPairsOutput                       AtomicPtr[ec2.DescribeKeyPairsOutput]
+	DescribeLaunchTemplatesOutput                AtomicPtr[ec2.DescribeLaunchTemplatesOutput]
+	DescribeSubnetsOutput                        AtomicPtr[ec2.DescribeSubnetsOutput]
+	DescribeSecurityGroupsOutput                 AtomicPtr[ec2.DescribeSecurityGroupsOutput]
+	DescribeInstanceTypesOutput                  AtomicPtr[ec2.DescribeInstanceTypesOutput]
+	DescribeInstanceTypeOfferingsOutput          AtomicPtr[ec2.DescribeInstanceTypeOfferingsOutput]
+	DescribeAvailabilityZonesOutput              AtomicPtr[ec2.DescribeAvailabilityZonesOutput]
+	DescribeSpotPriceHistoryInput                AtomicPtr[ec2.DescribeSpotPriceHistoryInput]
+	DescribeSpotPriceHistoryOutput               AtomicPtr[ec2.DescribeSpotPriceHistoryOutput]
+	CalledWithCreateFleetInput                   AtomicPtrSlice[ec2.CreateFleetInput]
+	CalledWithCreateLaunchTemplateInput          AtomicPtrSlice[ec2.CreateLaunchTemplateInput]
+	CalledWithDeleteLaunchTemplateInput          AtomicPtrSlice[ec2.DeleteLaunchTemplateInput]
+	CreateFleetOutput                            AtomicPtr[ec2.CreateFleetOutput]
+	CreateFleetDelay                             AtomicPtr[time.Duration]
+	CalledWithDescribeImagesInput                AtomicPtrSlice[ec2.DescribeImagesInput]
+	CalledWithCreateTagsInput                    AtomicPtrSlice[ec2.CreateTagsInput]
+	CalledWithDescribeInstanceTypeOfferingsInput AtomicPtrSlice[ec2.DescribeInstanceTypeOfferingsInput]
+	CalledWithDescribeInstanceTypesInput         AtomicPtrSlice[ec2.DescribeInstanceTypesInput]
+	CalledWithTerminateInstancesInput            AtomicPtrSlice[ec2.TerminateInstancesInput]
+	CalledWithDescribeInstancesInput             AtomicPtrSlice[ec2.DescribeInstancesInput]
+	CalledWithModifyInstanceAttributeInput       AtomicPtrSlice[ec2.ModifyInstanceAttributeInput]
+	TerminateInstancesOutput                     AtomicPtr[ec2.TerminateInstancesOutput]
+	Instances                                    sync.Map
+	LaunchTemplates                              sync.Map
+	LaunchTemplateData                           sync.Map
+	TerminationProtectedInstances                sync.Map
+	InsufficientCapacityPools                    atomic.Slice[CapacityPool]
+	NextError                                    AtomicError
 }
 
 type EC2API struct {
@@ -76,6 +93,7 @@ var DefaultSupportedUsageClasses = aws.StringSlice([]string{"on-demand", "spot"}
 func (e *EC2API) Reset() {
 	e.DescribeInstancesOutput.Reset()
 	e.DescribeImagesOutput.Reset()
+	e.DescribeKeyPairsOutput.Reset()
 	e.DescribeLaunchTemplatesOutput.Reset()
 	e.DescribeSubnetsOutput.Reset()
 	e.DescribeSecurityGroupsOutput.Reset()
@@ -83,9 +101,18 @@ func (e *EC2API) Reset() {
 	e.DescribeInstanceTypeOfferingsOutput.Reset()
 	e.DescribeAvailabilityZonesOutput.Reset()
 	e.CreateFleetOutput.Reset()
+	e.CreateFleetDelay.Reset()
 	e.CalledWithCreateFleetInput.Reset()
 	e.CalledWithCreateLaunchTemplateInput.Reset()
+	e.CalledWithDeleteLaunchTemplateInput.Reset()
 	e.CalledWithDescribeImagesInput.Reset()
+	e.CalledWithCreateTagsInput.Reset()
+	e.CalledWithDescribeInstanceTypeOfferingsInput.Reset()
+	e.CalledWithDescribeInstanceTypesInput.Reset()
+	e.CalledWithTerminateInstancesInput.Reset()
+	e.CalledWithDescribeInstancesInput.Reset()
+	e.CalledWithModifyInstanceAttributeInput.Reset()
+	e.TerminateInstancesOutput.Reset()
 	e.DescribeSpotPriceHistoryInput.Reset()
 	e.DescribeSpotPriceHistoryOutput.Reset()
 	e.Instances.Range(func(k, v any) bool {
@@ -96,12 +123,27 @@ func (e *EC2API) Reset() {
 		e.LaunchTemplates.Delete(k)
 		return true
 	})
+	e.LaunchTemplateData.Range(func(k, v any) bool {
+		e.LaunchTemplateData.Delete(k)
+		return true
+	})
+	e.TerminationProtectedInstances.Range(func(k, v any) bool {
+		e.TerminationProtectedInstances.Delete(k)
+		return true
+	})
 	e.InsufficientCapacityPools.Reset()
 	e.NextError.Reset()
 }
 
 // nolint: gocyclo
-func (e *EC2API) CreateFleetWithContext(_ context.Context, input *ec2.CreateFleetInput, _ ...request.Option) (*ec2.CreateFleetOutput, error) {
+func (e *EC2API) CreateFleetWithContext(ctx context.Context, input *ec2.CreateFleetInput, _ ...request.Option) (*ec2.CreateFleetOutput, error) {
+	if !e.CreateFleetDelay.IsNil() {
+		select {
+		case <-ctx.Done():
+			return nil, awserr.New(request.CanceledErrorCode, "request context canceled", ctx.Err())
+		case <-time.After(*e.CreateFleetDelay.Clone()):
+		}
+	}
 	if !e.NextError.IsNil() {
 		defer e.NextError.Reset()
 		return nil, e.NextError.Get()
@@ -148,15 +190,20 @@ func (e *EC2API) CreateFleetWithContext(_ context.Context, input *ec2.CreateFlee
 			instanceState := ec2.InstanceStateNameRunning
 			for i := 0; i < int(*input.TargetCapacitySpecification.TotalTargetCapacity); i++ {
 				instance := &ec2.Instance{
-					ImageId:               aws.String(*amiID),
-					InstanceId:            aws.String(test.RandomName()),
-					Placement:             &ec2.Placement{AvailabilityZone: input.LaunchTemplateConfigs[0].Overrides[0].AvailabilityZone},
+					ImageId:    aws.String(*amiID),
+					InstanceId: aws.String(test.RandomName()),
+					Placement: &ec2.Placement{
+						AvailabilityZone: input.LaunchTemplateConfigs[0].Overrides[0].AvailabilityZone,
+						GroupName:        lo.FromPtr(input.LaunchTemplateConfigs[0].Overrides[0].Placement).GroupName,
+					},
 					PrivateDnsName:        aws.String(randomdata.IpV4Address()),
 					InstanceType:          input.LaunchTemplateConfigs[0].Overrides[0].InstanceType,
+					SubnetId:              input.LaunchTemplateConfigs[0].Overrides[0].SubnetId,
 					SpotInstanceRequestId: spotInstanceRequestID,
 					State: &ec2.InstanceState{
 						Name: &instanceState,
 					},
+					Tags: instanceTags(input.TagSpecifications),
 				}
 				e.Instances.Store(*instance.InstanceId, instance)
 				instanceIds = append(instanceIds, instance.InstanceId)
@@ -189,11 +236,115 @@ func (e *EC2API) CreateLaunchTemplateWithContext(_ context.Context, input *ec2.C
 		return nil, e.NextError.Get()
 	}
 	e.CalledWithCreateLaunchTemplateInput.Add(input)
-	launchTemplate := &ec2.LaunchTemplate{LaunchTemplateName: input.LaunchTemplateName}
-	e.LaunchTemplates.Store(input.LaunchTemplateName, launchTemplate)
+	launchTemplate := &ec2.LaunchTemplate{
+		LaunchTemplateId:   aws.String(test.RandomName()),
+		LaunchTemplateName: input.LaunchTemplateName,
+	}
+	for _, tagSpecification := range input.TagSpecifications {
+		if aws.StringValue(tagSpecification.ResourceType) == ec2.ResourceTypeLaunchTemplate {
+			launchTemplate.Tags = append(launchTemplate.Tags, tagSpecification.Tags...)
+		}
+	}
+	// The data of a launch template version has the same fields as the data that it's created with
+	data := &ec2.ResponseLaunchTemplateData{}
+	if err := json.Unmarshal(lo.Must(json.Marshal(input.LaunchTemplateData)), data); err != nil {
+		return nil, err
+	}
+	e.LaunchTemplates.Store(aws.StringValue(input.LaunchTemplateName), launchTemplate)
+	e.LaunchTemplateData.Store(aws.StringValue(launchTemplate.LaunchTemplateId), data)
 	return &ec2.CreateLaunchTemplateOutput{LaunchTemplate: launchTemplate}, nil
 }
 
+func (e *EC2API) DeleteLaunchTemplate(input *ec2.DeleteLaunchTemplateInput) (*ec2.DeleteLaunchTemplateOutput, error) {
+	if !e.NextError.IsNil() {
+		defer e.NextError.Reset()
+		return nil, e.NextError.Get()
+	}
+	e.CalledWithDeleteLaunchTemplateInput.Add(input)
+	e.LaunchTemplates.Range(func(name, launchTemplate any) bool {
+		if aws.StringValue(launchTemplate.(*ec2.LaunchTemplate).LaunchTemplateId) == aws.StringValue(input.LaunchTemplateId) {
+			e.LaunchTemplates.Delete(name)
+		}
+		return true
+	})
+	return &ec2.DeleteLaunchTemplateOutput{}, nil
+}
+
+func (e *EC2API) CreateTagsWithContext(_ context.Context, input *ec2.CreateTagsInput, _ ...request.Option) (*ec2.CreateTagsOutput, error) {
+	if !e.NextError.IsNil() {
+		defer e.NextError.Reset()
+		return nil, e.NextError.Get()
+	}
+	e.CalledWithCreateTagsInput.Add(input)
+	return &ec2.CreateTagsOutput{}, nil
+}
+
+// TerminateInstancesWithContext moves the stored instances to the shutting-down state, leaving instances that are
+// already terminated as they are
+func (e *EC2API) TerminateInstancesWithContext(_ context.Context, input *ec2.TerminateInstancesInput, _ ...request.Option) (*ec2.TerminateInstancesOutput, error) {
+	if !e.NextError.IsNil() {
+		defer e.NextError.Reset()
+		return nil, e.NextError.Get()
+	}
+	e.CalledWithTerminateInstancesInput.Add(input)
+	if !e.TerminateInstancesOutput.IsNil() {
+		return e.TerminateInstancesOutput.Clone(), nil
+	}
+	output := &ec2.TerminateInstancesOutput{}
+	for _, instanceID := range input.InstanceIds {
+		if _, ok := e.TerminationProtectedInstances.Load(*instanceID); ok {
+			return nil, awserr.New("OperationNotPermitted", fmt.Sprintf("The instance '%s' may not be terminated. Modify its 'disableApiTermination' instance attribute and try again.", *instanceID), nil)
+		}
+	}
+	for _, instanceID := range input.InstanceIds {
+		stored, ok := e.Instances.Load(*instanceID)
+		if !ok {
+			return nil, instanceNotFoundError(*instanceID)
+		}
+		instance := *stored.(*ec2.Instance)
+		previousState := instance.State
+		if aws.StringValue(previousState.Name) != ec2.InstanceStateNameTerminated {
+			instance.State = &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameShuttingDown)}
+			e.Instances.Store(*instanceID, &instance)
+		}
+		output.TerminatingInstances = append(output.TerminatingInstances, &ec2.InstanceStateChange{
+			InstanceId:    instance.InstanceId,
+			PreviousState: previousState,
+			CurrentState:  instance.State,
+		})
+	}
+	return output, nil
+}
+
+func (e *EC2API) ModifyInstanceAttributeWithContext(_ context.Context, input *ec2.ModifyInstanceAttributeInput, _ ...request.Option) (*ec2.ModifyInstanceAttributeOutput, error) {
+	if !e.NextError.IsNil() {
+		defer e.NextError.Reset()
+		return nil, e.NextError.Get()
+	}
+	e.CalledWithModifyInstanceAttributeInput.Add(input)
+	if input.DisableApiTermination != nil {
+		if aws.BoolValue(input.DisableApiTermination.Value) {
+			e.TerminationProtectedInstances.Store(aws.StringValue(input.InstanceId), true)
+		} else {
+			e.TerminationProtectedInstances.Delete(aws.StringValue(input.InstanceId))
+		}
+	}
+	return &ec2.ModifyInstanceAttributeOutput{}, nil
+}
+
+func instanceTags(tagSpecifications []*ec2.TagSpecification) []*ec2.Tag {
+	for _, tagSpecification := range tagSpecifications {
+		if aws.StringValue(tagSpecification.ResourceType) == ec2.ResourceTypeInstance {
+			return tagSpecification.Tags
+		}
+	}
+	return nil
+}
+
+func instanceNotFoundError(instanceID string) error {
+	return awserr.New("InvalidInstanceID.NotFound", fmt.Sprintf("The instance ID '%s' does not exist", instanceID), nil)
+}
+
 func (e *EC2API) DescribeInstancesWithContext(_ context.Context, input *ec2.DescribeInstancesInput, _ ...request.Option) (*ec2.DescribeInstancesOutput, error) {
 	if !e.NextError.IsNil() {
 		defer e.NextError.Reset()
@@ -205,7 +356,10 @@ func (e *EC2API) DescribeInstancesWithContext(_ context.Context, input *ec2.Desc
 
 	instances := []*ec2.Instance{}
 	for _, instanceID := range input.InstanceIds {
-		instance, _ := e.Instances.Load(*instanceID)
+		instance, ok := e.Instances.Load(*instanceID)
+		if !ok {
+			return nil, instanceNotFoundError(*instanceID)
+		}
 		instances = append(instances, instance.(*ec2.Instance))
 	}
 
@@ -214,6 +368,63 @@ func (e *EC2API) DescribeInstancesWithContext(_ context.Context, input *ec2.Desc
 	}, nil
 }
 
+// DescribeInstancesPagesWithContext returns a page per instance, so that callers have to handle pagination. Like
+// EC2, it fails if any of the requested instance IDs doesn't exist. Of the filters, only tag-key, placement-group-name
+// and instance-state-name are supported.
+func (e *EC2API) DescribeInstancesPagesWithContext(_ context.Context, input *ec2.DescribeInstancesInput, fn func(*ec2.DescribeInstancesOutput, bool) bool, _ ...request.Option) error {
+	if !e.NextError.IsNil() {
+		defer e.NextError.Reset()
+		return e.NextError.Get()
+	}
+	e.CalledWithDescribeInstancesInput.Add(input)
+	if !e.DescribeInstancesOutput.IsNil() {
+		fn(e.DescribeInstancesOutput.Clone(), true)
+		return nil
+	}
+	instances := []*ec2.Instance{}
+	if len(input.InstanceIds) > 0 {
+		for _, instanceID := range input.InstanceIds {
+			instance, ok := e.Instances.Load(*instanceID)
+			if !ok {
+				return instanceNotFoundError(*instanceID)
+			}
+			instances = append(instances, instance.(*ec2.Instance))
+		}
+	} else {
+		e.Instances.Range(func(_, instance any) bool {
+			instances = append(instances, instance.(*ec2.Instance))
+			return true
+		})
+	}
+	for _, filter := range input.Filters {
+		values := aws.StringValueSlice(filter.Values)
+		switch aws.StringValue(filter.Name) {
+		case "tag-key":
+			instances = lo.Filter(instances, func(instance *ec2.Instance, _ int) bool {
+				return lo.ContainsBy(instance.Tags, func(tag *ec2.Tag) bool { return lo.Contains(values, aws.StringValue(tag.Key)) })
+			})
+		case "placement-group-name":
+			instances = lo.Filter(instances, func(instance *ec2.Instance, _ int) bool {
+				return instance.Placement != nil && lo.Contains(values, aws.StringValue(instance.Placement.GroupName))
+			})
+		case "instance-state-name":
+			instances = lo.Filter(instances, func(instance *ec2.Instance, _ int) bool {
+				return instance.State != nil && lo.Contains(values, aws.StringValue(instance.State.Name))
+			})
+		}
+	}
+	if len(instances) == 0 {
+		fn(&ec2.DescribeInstancesOutput{}, true)
+		return nil
+	}
+	for i, instance := range instances {
+		if !fn(&ec2.DescribeInstancesOutput{Reservations: []*ec2.Reservation{{Instances: []*ec2.Instance{instance}}}}, i == len(instances)-1) {
+			return nil
+		}
+	}
+	return nil
+}
+
 func (e *EC2API) DescribeImagesWithContext(_ context.Context, input *ec2.DescribeImagesInput, _ ...request.Option) (*ec2.DescribeImagesOutput, error) {
 	if !e.NextError.IsNil() {
 		defer e.NextError.Reset()
@@ -244,17 +455,74 @@ func (e *EC2API) DescribeLaunchTemplatesWithContext(_ context.Context, input *ec
 	output := &ec2.DescribeLaunchTemplatesOutput{}
 	e.LaunchTemplates.Range(func(key, value interface{}) bool {
 		launchTemplate := value.(*ec2.LaunchTemplate)
-		if lo.Contains(aws.StringValueSlice(input.LaunchTemplateNames), aws.StringValue(launchTemplate.LaunchTemplateName)) {
+		if len(input.LaunchTemplateNames) > 0 && !lo.Contains(aws.StringValueSlice(input.LaunchTemplateNames), aws.StringValue(launchTemplate.LaunchTemplateName)) {
+			return true
+		}
+		if Filter(input.Filters, aws.StringValue(launchTemplate.LaunchTemplateId), "", launchTemplate.Tags) {
 			output.LaunchTemplates = append(output.LaunchTemplates, launchTemplate)
 		}
 		return true
 	})
-	if len(output.LaunchTemplates) == 0 {
+	// Only lookups by name fail when nothing is found
+	if len(input.LaunchTemplateNames) > 0 && len(output.LaunchTemplates) == 0 {
 		return nil, awserr.New("InvalidLaunchTemplateName.NotFoundException", "not found", nil)
 	}
 	return output, nil
 }
 
+func (e *EC2API) DescribeLaunchTemplateVersionsWithContext(_ context.Context, input *ec2.DescribeLaunchTemplateVersionsInput, _ ...request.Option) (*ec2.DescribeLaunchTemplateVersionsOutput, error) {
+	if !e.NextError.IsNil() {
+		defer e.NextError.Reset()
+		return nil, e.NextError.Get()
+	}
+	data, ok := e.LaunchTemplateData.Load(aws.StringValue(input.LaunchTemplateId))
+	if !ok {
+		return nil, awserr.New("InvalidLaunchTemplateId.NotFound", "not found", nil)
+	}
+	return &ec2.DescribeLaunchTemplateVersionsOutput{
+		LaunchTemplateVersions: []*ec2.LaunchTemplateVersion{{
+			LaunchTemplateId:   input.LaunchTemplateId,
+			LaunchTemplateData: data.(*ec2.ResponseLaunchTemplateData),
+			VersionNumber:      aws.Int64(1),
+		}},
+	}, nil
+}
+
+func (e *EC2API) DescribeKeyPairsWithContext(_ context.Context, input *ec2.DescribeKeyPairsInput, _ ...request.Option) (*ec2.DescribeKeyPairsOutput, error) {
+	if !e.NextError.IsNil() {
+		defer e.NextError.Reset()
+		return nil, e.NextError.Get()
+	}
+	if !e.DescribeKeyPairsOutput.IsNil() {
+		return e.DescribeKeyPairsOutput.Clone(), nil
+	}
+	return &ec2.DescribeKeyPairsOutput{
+		KeyPairs: lo.Map(input.KeyNames, func(keyName *string, _ int) *ec2.KeyPairInfo {
+			return &ec2.KeyPairInfo{KeyName: keyName, KeyPairId: aws.String(test.RandomName())}
+		}),
+	}, nil
+}
+
+// DescribeSubnetsPagesWithContext returns the subnets of DescribeSubnetsWithContext in pages of MaxResults subnets, so
+// that callers have to handle pagination.
+func (e *EC2API) DescribeSubnetsPagesWithContext(ctx context.Context, input *ec2.DescribeSubnetsInput, fn func(*ec2.DescribeSubnetsOutput, bool) bool, opts ...request.Option) error {
+	output, err := e.DescribeSubnetsWithContext(ctx, input, opts...)
+	if err != nil {
+		return err
+	}
+	if aws.Int64Value(input.MaxResults) <= 0 || len(output.Subnets) == 0 {
+		fn(output, true)
+		return nil
+	}
+	pages := lo.Chunk(output.Subnets, int(aws.Int64Value(input.MaxResults)))
+	for i, page := range pages {
+		if !fn(&ec2.DescribeSubnetsOutput{Subnets: page}, i == len(pages)-1) {
+			return nil
+		}
+	}
+	return nil
+}
+
 func (e *EC2API) DescribeSubnetsWithContext(ctx context.Context, input *ec2.DescribeSubnetsInput, opts ...request.Option) (*ec2.DescribeSubnetsOutput, error) {
 	if !e.NextError.IsNil() {
 		defer e.NextError.Reset()
@@ -268,7 +536,9 @@ func (e *EC2API) DescribeSubnetsWithContext(ctx context.Context, input *ec2.Desc
 	subnets := []*ec2.Subnet{
 		{
 			SubnetId:                aws.String("subnet-test1"),
+			VpcId:                   aws.String("vpc-test1"),
 			AvailabilityZone:        aws.String("test-zone-1a"),
+			AvailabilityZoneId:      aws.String("testzone1a"),
 			AvailableIpAddressCount: aws.Int64(100),
 			Tags: []*ec2.Tag{
 				{Key: aws.String("Name"), Value: aws.String("test-subnet-1")},
@@ -277,7 +547,9 @@ func (e *EC2API) DescribeSubnetsWithContext(ctx context.Context, input *ec2.Desc
 		},
 		{
 			SubnetId:                aws.String("subnet-test2"),
+			VpcId:                   aws.String("vpc-test1"),
 			AvailabilityZone:        aws.String("test-zone-1b"),
+			AvailabilityZoneId:      aws.String("testzone1b"),
 			AvailableIpAddressCount: aws.Int64(100),
 			Tags: []*ec2.Tag{
 				{Key: aws.String("Name"), Value: aws.String("test-subnet-2")},
@@ -286,7 +558,9 @@ func (e *EC2API) DescribeSubnetsWithContext(ctx context.Context, input *ec2.Desc
 		},
 		{
 			SubnetId:                aws.String("subnet-test3"),
+			VpcId:                   aws.String("vpc-test1"),
 			AvailabilityZone:        aws.String("test-zone-1c"),
+			AvailabilityZoneId:      aws.String("testzone1c"),
 			AvailableIpAddressCount: aws.Int64(100),
 			Tags: []*ec2.Tag{
 				{Key: aws.String("Name"), Value: aws.String("test-subnet-3")},
@@ -312,6 +586,7 @@ func (e *EC2API) DescribeSecurityGroupsWithContext(ctx context.Context, input *e
 	sgs := []*ec2.SecurityGroup{
 		{
 			GroupId: aws.String("sg-test1"),
+			VpcId:   aws.String("vpc-test1"),
 			Tags: []*ec2.Tag{
 				{Key: aws.String("Name"), Value: aws.String("test-security-group-1")},
 				{Key: aws.String("foo"), Value: aws.String("bar")},
@@ -319,6 +594,7 @@ func (e *EC2API) DescribeSecurityGroupsWithContext(ctx context.Context, input *e
 		},
 		{
 			GroupId: aws.String("sg-test2"),
+			VpcId:   aws.String("vpc-test1"),
 			Tags: []*ec2.Tag{
 				{Key: aws.String("Name"), Value: aws.String("test-security-group-2")},
 				{Key: aws.String("foo"), Value: aws.String("bar")},
@@ -326,6 +602,7 @@ func (e *EC2API) DescribeSecurityGroupsWithContext(ctx context.Context, input *e
 		},
 		{
 			GroupId: aws.String("sg-test3"),
+			VpcId:   aws.String("vpc-test1"),
 			Tags: []*ec2.Tag{
 				{Key: aws.String("Name"), Value: aws.String("test-security-group-3")},
 				{Key: aws.String("TestTag")},
@@ -351,24 +628,30 @@ func (e *EC2API) DescribeAvailabilityZonesWithContext(context.Context, *ec2.Desc
 	}}, nil
 }
 
-func (e *EC2API) DescribeInstanceTypesPagesWithContext(_ context.Context, _ *ec2.DescribeInstanceTypesInput, fn func(*ec2.DescribeInstanceTypesOutput, bool) bool, _ ...request.Option) error {
+func (e *EC2API) DescribeInstanceTypesPagesWithContext(_ context.Context, input *ec2.DescribeInstanceTypesInput, fn func(*ec2.DescribeInstanceTypesOutput, bool) bool, _ ...request.Option) error {
 	if !e.NextError.IsNil() {
 		defer e.NextError.Reset()
 		return e.NextError.Get()
 	}
+	e.CalledWithDescribeInstanceTypesInput.Add(input)
 	if !e.DescribeInstanceTypesOutput.IsNil() {
-		fn(e.DescribeInstanceTypesOutput.Clone(), false)
+		fn(filterInstanceTypes(e.DescribeInstanceTypesOutput.Clone(), input.Filters), false)
 		return nil
 	}
-	fn(&ec2.DescribeInstanceTypesOutput{
+	fn(filterInstanceTypes(&ec2.DescribeInstanceTypesOutput{
 		InstanceTypes: []*ec2.InstanceTypeInfo{
 			{
 				InstanceType:                  aws.String("t3.large"),
 				SupportedUsageClasses:         DefaultSupportedUsageClasses,
 				SupportedVirtualizationTypes:  []*string{aws.String("hvm")},
+				SupportedBootModes:            aws.StringSlice([]string{"legacy-bios", "uefi"}),
 				BurstablePerformanceSupported: aws.Bool(true),
 				BareMetal:                     aws.Bool(false),
+				DedicatedHostsSupported:       aws.Bool(false),
 				Hypervisor:                    aws.String("nitro"),
+				PlacementGroupInfo: &ec2.PlacementGroupInfo{
+					SupportedStrategies: aws.StringSlice([]string{"partition", "spread"}),
+				},
 				ProcessorInfo: &ec2.ProcessorInfo{
 					SupportedArchitectures: aws.StringSlice([]string{"x86_64"}),
 				},
@@ -388,9 +671,14 @@ func (e *EC2API) DescribeInstanceTypesPagesWithContext(_ context.Context, _ *ec2
 				InstanceType:                  aws.String("m5.large"),
 				SupportedUsageClasses:         DefaultSupportedUsageClasses,
 				SupportedVirtualizationTypes:  []*string{aws.String("hvm")},
+				SupportedBootModes:            aws.StringSlice([]string{"legacy-bios", "uefi"}),
 				BurstablePerformanceSupported: aws.Bool(false),
 				BareMetal:                     aws.Bool(false),
+				DedicatedHostsSupported:       aws.Bool(true),
 				Hypervisor:                    aws.String("nitro"),
+				PlacementGroupInfo: &ec2.PlacementGroupInfo{
+					SupportedStrategies: aws.StringSlice([]string{"cluster", "partition", "spread"}),
+				},
 				ProcessorInfo: &ec2.ProcessorInfo{
 					SupportedArchitectures: aws.StringSlice([]string{"x86_64"}),
 				},
@@ -401,6 +689,13 @@ func (e *EC2API) DescribeInstanceTypesPagesWithContext(_ context.Context, _ *ec2
 				MemoryInfo: &ec2.MemoryInfo{
 					SizeInMiB: aws.Int64(8 * 1024),
 				},
+				EbsInfo: &ec2.EbsInfo{
+					EbsOptimizedSupport: aws.String(ec2.EbsOptimizedSupportDefault),
+					EbsOptimizedInfo: &ec2.EbsOptimizedInfo{
+						BaselineBandwidthInMbps: aws.Int64(650),
+						MaximumBandwidthInMbps:  aws.Int64(4750),
+					},
+				},
 				NetworkInfo: &ec2.NetworkInfo{
 					MaximumNetworkInterfaces:  aws.Int64(3),
 					Ipv4AddressesPerInterface: aws.Int64(30),
@@ -410,9 +705,14 @@ func (e *EC2API) DescribeInstanceTypesPagesWithContext(_ context.Context, _ *ec2
 				InstanceType:                  aws.String("m5.xlarge"),
 				SupportedUsageClasses:         DefaultSupportedUsageClasses,
 				SupportedVirtualizationTypes:  []*string{aws.String("hvm")},
+				SupportedBootModes:            aws.StringSlice([]string{"legacy-bios", "uefi"}),
 				BurstablePerformanceSupported: aws.Bool(false),
 				BareMetal:                     aws.Bool(false),
+				DedicatedHostsSupported:       aws.Bool(true),
 				Hypervisor:                    aws.String("nitro"),
+				PlacementGroupInfo: &ec2.PlacementGroupInfo{
+					SupportedStrategies: aws.StringSlice([]string{"cluster", "partition", "spread"}),
+				},
 				ProcessorInfo: &ec2.ProcessorInfo{
 					SupportedArchitectures: aws.StringSlice([]string{"x86_64"}),
 				},
@@ -423,6 +723,13 @@ func (e *EC2API) DescribeInstanceTypesPagesWithContext(_ context.Context, _ *ec2
 				MemoryInfo: &ec2.MemoryInfo{
 					SizeInMiB: aws.Int64(16 * 1024),
 				},
+				EbsInfo: &ec2.EbsInfo{
+					EbsOptimizedSupport: aws.String(ec2.EbsOptimizedSupportDefault),
+					EbsOptimizedInfo: &ec2.EbsOptimizedInfo{
+						BaselineBandwidthInMbps: aws.Int64(1150),
+						MaximumBandwidthInMbps:  aws.Int64(4750),
+					},
+				},
 				NetworkInfo: &ec2.NetworkInfo{
 					MaximumNetworkInterfaces:  aws.Int64(4),
 					Ipv4AddressesPerInterface: aws.Int64(15),
@@ -432,6 +739,7 @@ func (e *EC2API) DescribeInstanceTypesPagesWithContext(_ context.Context, _ *ec2
 				InstanceType:                  aws.String("p3.8xlarge"),
 				SupportedUsageClasses:         DefaultSupportedUsageClasses,
 				SupportedVirtualizationTypes:  []*string{aws.String("hvm")},
+				SupportedBootModes:            aws.StringSlice([]string{"legacy-bios"}),
 				BurstablePerformanceSupported: aws.Bool(false),
 				BareMetal:                     aws.Bool(false),
 				Hypervisor:                    aws.String("xen"),
@@ -464,8 +772,10 @@ func (e *EC2API) DescribeInstanceTypesPagesWithContext(_ context.Context, _ *ec2
 				InstanceType:                  aws.String("g4dn.8xlarge"),
 				SupportedUsageClasses:         DefaultSupportedUsageClasses,
 				SupportedVirtualizationTypes:  []*string{aws.String("hvm")},
+				SupportedBootModes:            aws.StringSlice([]string{"legacy-bios", "uefi"}),
 				BurstablePerformanceSupported: aws.Bool(false),
 				BareMetal:                     aws.Bool(false),
+				DedicatedHostsSupported:       aws.Bool(true),
 				Hypervisor:                    aws.String("nitro"),
 				ProcessorInfo: &ec2.ProcessorInfo{
 					SupportedArchitectures: aws.StringSlice([]string{"x86_64"}),
@@ -487,7 +797,15 @@ func (e *EC2API) DescribeInstanceTypesPagesWithContext(_ context.Context, _ *ec2
 						},
 					}},
 				},
+				EbsInfo: &ec2.EbsInfo{
+					EbsOptimizedSupport: aws.String(ec2.EbsOptimizedSupportDefault),
+					EbsOptimizedInfo: &ec2.EbsOptimizedInfo{
+						BaselineBandwidthInMbps: aws.Int64(9500),
+						MaximumBandwidthInMbps:  aws.Int64(9500),
+					},
+				},
 				NetworkInfo: &ec2.NetworkInfo{
+					MaximumNetworkCards:       aws.Int64(1),
 					MaximumNetworkInterfaces:  aws.Int64(4),
 					Ipv4AddressesPerInterface: aws.Int64(15),
 				},
@@ -500,6 +818,7 @@ func (e *EC2API) DescribeInstanceTypesPagesWithContext(_ context.Context, _ *ec2
 				InstanceType:                  aws.String("c6g.large"),
 				SupportedUsageClasses:         DefaultSupportedUsageClasses,
 				SupportedVirtualizationTypes:  []*string{aws.String("hvm")},
+				SupportedBootModes:            aws.StringSlice([]string{"uefi"}),
 				BurstablePerformanceSupported: aws.Bool(false),
 				BareMetal:                     aws.Bool(false),
 				Hypervisor:                    aws.String("nitro"),
@@ -522,6 +841,7 @@ func (e *EC2API) DescribeInstanceTypesPagesWithContext(_ context.Context, _ *ec2
 				InstanceType:                  aws.String("inf1.2xlarge"),
 				SupportedUsageClasses:         DefaultSupportedUsageClasses,
 				SupportedVirtualizationTypes:  []*string{aws.String("hvm")},
+				SupportedBootModes:            aws.StringSlice([]string{"legacy-bios", "uefi"}),
 				BurstablePerformanceSupported: aws.Bool(false),
 				BareMetal:                     aws.Bool(false),
 				Hypervisor:                    aws.String("nitro"),
@@ -549,6 +869,7 @@ func (e *EC2API) DescribeInstanceTypesPagesWithContext(_ context.Context, _ *ec2
 				InstanceType:                  aws.String("inf1.6xlarge"),
 				SupportedUsageClasses:         DefaultSupportedUsageClasses,
 				SupportedVirtualizationTypes:  []*string{aws.String("hvm")},
+				SupportedBootModes:            aws.StringSlice([]string{"legacy-bios", "uefi"}),
 				BurstablePerformanceSupported: aws.Bool(false),
 				BareMetal:                     aws.Bool(false),
 				Hypervisor:                    aws.String("nitro"),
@@ -576,9 +897,14 @@ func (e *EC2API) DescribeInstanceTypesPagesWithContext(_ context.Context, _ *ec2
 				InstanceType:                  aws.String("m5.metal"),
 				SupportedUsageClasses:         DefaultSupportedUsageClasses,
 				SupportedVirtualizationTypes:  []*string{aws.String("hvm")},
+				SupportedBootModes:            aws.StringSlice([]string{"legacy-bios", "uefi"}),
 				BurstablePerformanceSupported: aws.Bool(false),
 				BareMetal:                     aws.Bool(true),
+				DedicatedHostsSupported:       aws.Bool(true),
 				Hypervisor:                    nil,
+				PlacementGroupInfo: &ec2.PlacementGroupInfo{
+					SupportedStrategies: aws.StringSlice([]string{"cluster", "partition", "spread"}),
+				},
 				ProcessorInfo: &ec2.ProcessorInfo{
 					SupportedArchitectures: aws.StringSlice([]string{"x86_64"}),
 				},
@@ -595,20 +921,38 @@ func (e *EC2API) DescribeInstanceTypesPagesWithContext(_ context.Context, _ *ec2
 				},
 			},
 		},
-	}, false)
+	}, input.Filters), false)
 	return nil
 }
 
-func (e *EC2API) DescribeInstanceTypeOfferingsPagesWithContext(_ context.Context, _ *ec2.DescribeInstanceTypeOfferingsInput, fn func(*ec2.DescribeInstanceTypeOfferingsOutput, bool) bool, _ ...request.Option) error {
+// filterInstanceTypes applies the instance-type filter, whose values may contain * wildcards like in EC2
+func filterInstanceTypes(output *ec2.DescribeInstanceTypesOutput, filters []*ec2.Filter) *ec2.DescribeInstanceTypesOutput {
+	for _, filter := range filters {
+		if aws.StringValue(filter.Name) != "instance-type" {
+			continue
+		}
+		patterns := aws.StringValueSlice(filter.Values)
+		output.InstanceTypes = lo.Filter(output.InstanceTypes, func(instanceType *ec2.InstanceTypeInfo, _ int) bool {
+			return lo.ContainsBy(patterns, func(pattern string) bool {
+				matched, _ := path.Match(pattern, aws.StringValue(instanceType.InstanceType))
+				return matched
+			})
+		})
+	}
+	return output
+}
+
+func (e *EC2API) DescribeInstanceTypeOfferingsPagesWithContext(_ context.Context, input *ec2.DescribeInstanceTypeOfferingsInput, fn func(*ec2.DescribeInstanceTypeOfferingsOutput, bool) bool, _ ...request.Option) error {
 	if !e.NextError.IsNil() {
 		defer e.NextError.Reset()
 		return e.NextError.Get()
 	}
+	e.CalledWithDescribeInstanceTypeOfferingsInput.Add(input)
 	if !e.DescribeInstanceTypeOfferingsOutput.IsNil() {
-		fn(e.DescribeInstanceTypeOfferingsOutput.Clone(), false)
+		fn(filterInstanceTypeOfferings(e.DescribeInstanceTypeOfferingsOutput.Clone(), input.Filters), false)
 		return nil
 	}
-	fn(&ec2.DescribeInstanceTypeOfferingsOutput{
+	output := &ec2.DescribeInstanceTypeOfferingsOutput{
 		InstanceTypeOfferings: []*ec2.InstanceTypeOffering{
 			{
 				InstanceType: aws.String("m5.large"),
@@ -691,10 +1035,31 @@ func (e *EC2API) DescribeInstanceTypeOfferingsPagesWithContext(_ context.Context
 				Location:     aws.String("test-zone-1c"),
 			},
 		},
-	}, false)
+	}
+	if aws.StringValue(input.LocationType) == ec2.LocationTypeAvailabilityZoneId {
+		// zone IDs are the zone names without dashes, consistent with DescribeAvailabilityZones and DescribeSubnets
+		for _, offering := range output.InstanceTypeOfferings {
+			offering.Location = aws.String(strings.ReplaceAll(aws.StringValue(offering.Location), "-", ""))
+		}
+	}
+	fn(filterInstanceTypeOfferings(output, input.Filters), false)
 	return nil
 }
 
+// filterInstanceTypeOfferings applies the location filter that is used to request the offerings of a single zone
+func filterInstanceTypeOfferings(output *ec2.DescribeInstanceTypeOfferingsOutput, filters []*ec2.Filter) *ec2.DescribeInstanceTypeOfferingsOutput {
+	for _, filter := range filters {
+		if aws.StringValue(filter.Name) != "location" {
+			continue
+		}
+		locations := sets.NewString(aws.StringValueSlice(filter.Values)...)
+		output.InstanceTypeOfferings = lo.Filter(output.InstanceTypeOfferings, func(offering *ec2.InstanceTypeOffering, _ int) bool {
+			return locations.Has(aws.StringValue(offering.Location))
+		})
+	}
+	return output
+}
+
 func (e *EC2API) DescribeSpotPriceHistoryPagesWithContext(_ aws.Context, in *ec2.DescribeSpotPriceHistoryInput, fn func(*ec2.DescribeSpotPriceHistoryOutput, bool) bool, opts ...request.Option) error {
 	e.DescribeSpotPriceHistoryInput.Set(in)
 	if !e.NextError.IsNil() {
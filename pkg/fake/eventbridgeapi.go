@@ -17,11 +17,14 @@ package fake
 import (
 	"context"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/eventbridge"
 	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
 )
 
+const dummyRuleARN = "arn:aws:events:us-west-2:000000000000:rule/Karpenter-Rule"
+
 // EventBridgeBehavior must be reset between tests otherwise tests will
 // pollute each other.
 type EventBridgeBehavior struct {
@@ -31,6 +34,9 @@ type EventBridgeBehavior struct {
 	ListTagsForResourceBehavior MockedFunction[eventbridge.ListTagsForResourceInput, eventbridge.ListTagsForResourceOutput]
 	DeleteRuleBehavior          MockedFunction[eventbridge.DeleteRuleInput, eventbridge.DeleteRuleOutput]
 	RemoveTargetsBehavior       MockedFunction[eventbridge.RemoveTargetsInput, eventbridge.RemoveTargetsOutput]
+	TagResourceBehavior         MockedFunction[eventbridge.TagResourceInput, eventbridge.TagResourceOutput]
+	ListTargetsByRuleBehavior   MockedFunction[eventbridge.ListTargetsByRuleInput, eventbridge.ListTargetsByRuleOutput]
+	PutEventsBehavior           MockedFunction[eventbridge.PutEventsInput, eventbridge.PutEventsOutput]
 }
 
 type EventBridgeAPI struct {
@@ -44,13 +50,18 @@ func (eb *EventBridgeAPI) Reset() {
 	eb.PutRuleBehavior.Reset()
 	eb.PutTargetsBehavior.Reset()
 	eb.ListRulesBehavior.Reset()
+	eb.ListTagsForResourceBehavior.Reset()
 	eb.DeleteRuleBehavior.Reset()
 	eb.RemoveTargetsBehavior.Reset()
+	eb.TagResourceBehavior.Reset()
+	eb.ListTargetsByRuleBehavior.Reset()
+	eb.PutEventsBehavior.Reset()
 }
 
-// TODO: Create a dummy rule ARN for the default that is returned from this function
 func (eb *EventBridgeAPI) PutRuleWithContext(_ context.Context, input *eventbridge.PutRuleInput, _ ...request.Option) (*eventbridge.PutRuleOutput, error) {
-	return eb.PutRuleBehavior.Invoke(input)
+	return eb.PutRuleBehavior.WithDefault(&eventbridge.PutRuleOutput{
+		RuleArn: aws.String(dummyRuleARN),
+	}).Invoke(input)
 }
 
 // TODO: Create a default response that returns failed entries
@@ -73,3 +84,17 @@ func (eb *EventBridgeAPI) DeleteRuleWithContext(_ context.Context, input *eventb
 func (eb *EventBridgeAPI) RemoveTargetsWithContext(_ context.Context, input *eventbridge.RemoveTargetsInput, _ ...request.Option) (*eventbridge.RemoveTargetsOutput, error) {
 	return eb.RemoveTargetsBehavior.Invoke(input)
 }
+
+func (eb *EventBridgeAPI) TagResourceWithContext(_ context.Context, input *eventbridge.TagResourceInput, _ ...request.Option) (*eventbridge.TagResourceOutput, error) {
+	return eb.TagResourceBehavior.Invoke(input)
+}
+
+func (eb *EventBridgeAPI) ListTargetsByRuleWithContext(_ context.Context, input *eventbridge.ListTargetsByRuleInput, _ ...request.Option) (*eventbridge.ListTargetsByRuleOutput, error) {
+	return eb.ListTargetsByRuleBehavior.Invoke(input)
+}
+
+func (eb *EventBridgeAPI) PutEventsWithContext(_ context.Context, input *eventbridge.PutEventsInput, _ ...request.Option) (*eventbridge.PutEventsOutput, error) {
+	return eb.PutEventsBehavior.WithDefault(&eventbridge.PutEventsOutput{
+		FailedEntryCount: aws.Int64(0),
+	}).Invoke(input)
+}
@@ -0,0 +1,59 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+	"github.com/aws/aws-sdk-go/service/servicequotas/servicequotasiface"
+)
+
+// ServiceQuotasBehavior must be reset between tests otherwise tests will
+// pollute each other.
+type ServiceQuotasBehavior struct {
+	ListServiceQuotasBehavior MockedFunction[servicequotas.ListServiceQuotasInput, servicequotas.ListServiceQuotasOutput]
+}
+
+type ServiceQuotasAPI struct {
+	servicequotasiface.ServiceQuotasAPI
+	ServiceQuotasBehavior
+}
+
+// Reset must be called between tests otherwise tests will pollute
+// each other.
+func (s *ServiceQuotasAPI) Reset() {
+	s.ListServiceQuotasBehavior.Reset()
+}
+
+func (s *ServiceQuotasAPI) ListServiceQuotasPagesWithContext(_ context.Context, input *servicequotas.ListServiceQuotasInput, fn func(*servicequotas.ListServiceQuotasOutput, bool) bool, _ ...request.Option) error {
+	output, err := s.ListServiceQuotasBehavior.Invoke(input)
+	if err != nil {
+		return err
+	}
+	fn(output, true)
+	return nil
+}
+
+// NewServiceQuota returns an EC2 service quota with the given code and value
+func NewServiceQuota(code string, value float64) *servicequotas.ServiceQuota {
+	return &servicequotas.ServiceQuota{
+		ServiceCode: aws.String("ec2"),
+		QuotaCode:   aws.String(code),
+		Value:       aws.Float64(value),
+	}
+}
@@ -16,15 +16,18 @@ package fake
 
 import (
 	"context"
+	"sync"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/samber/lo"
 )
 
 const (
-	dummyQueueURL = "https://sqs.us-west-2.amazonaws.com/000000000000/Karpenter-cluster-Queue"
+	dummyQueueURL  = "https://sqs.us-west-2.amazonaws.com/000000000000/Karpenter-cluster-Queue"
+	dummyMessageID = "00000000-0000-0000-0000-000000000000"
 )
 
 // SQSBehavior must be reset between tests otherwise tests will
@@ -35,13 +38,19 @@ type SQSBehavior struct {
 	GetQueueAttributesBehavior MockedFunction[sqs.GetQueueAttributesInput, sqs.GetQueueAttributesOutput]
 	SetQueueAttributesBehavior MockedFunction[sqs.SetQueueAttributesInput, sqs.SetQueueAttributesOutput]
 	ReceiveMessageBehavior     MockedFunction[sqs.ReceiveMessageInput, sqs.ReceiveMessageOutput]
+	SendMessageBehavior        MockedFunction[sqs.SendMessageInput, sqs.SendMessageOutput]
 	DeleteMessageBehavior      MockedFunction[sqs.DeleteMessageInput, sqs.DeleteMessageOutput]
+	ChangeVisibilityBehavior   MockedFunction[sqs.ChangeMessageVisibilityInput, sqs.ChangeMessageVisibilityOutput]
 	DeleteQueueBehavior        MockedFunction[sqs.DeleteQueueInput, sqs.DeleteQueueOutput]
+	TagQueueBehavior           MockedFunction[sqs.TagQueueInput, sqs.TagQueueOutput]
 }
 
 type SQSAPI struct {
 	sqsiface.SQSAPI
 	SQSBehavior
+
+	mu              sync.Mutex
+	queueAttributes map[string]*string // The attributes that were set on the queue, which GetQueueAttributes returns
 }
 
 // Reset must be called between tests otherwise tests will pollute
@@ -52,8 +61,15 @@ func (s *SQSAPI) Reset() {
 	s.GetQueueAttributesBehavior.Reset()
 	s.SetQueueAttributesBehavior.Reset()
 	s.ReceiveMessageBehavior.Reset()
+	s.SendMessageBehavior.Reset()
 	s.DeleteMessageBehavior.Reset()
+	s.ChangeVisibilityBehavior.Reset()
 	s.DeleteQueueBehavior.Reset()
+	s.TagQueueBehavior.Reset()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queueAttributes = nil
 }
 
 func (s *SQSAPI) CreateQueueWithContext(_ context.Context, input *sqs.CreateQueueInput, _ ...request.Option) (*sqs.CreateQueueOutput, error) {
@@ -70,25 +86,54 @@ func (s *SQSAPI) GetQueueUrlWithContext(_ context.Context, input *sqs.GetQueueUr
 }
 
 func (s *SQSAPI) GetQueueAttributesWithContext(_ context.Context, input *sqs.GetQueueAttributesInput, _ ...request.Option) (*sqs.GetQueueAttributesOutput, error) {
-	return s.GetQueueAttributesBehavior.WithDefault(&sqs.GetQueueAttributesOutput{
+	output, err := s.GetQueueAttributesBehavior.WithDefault(&sqs.GetQueueAttributesOutput{
 		Attributes: map[string]*string{
 			sqs.QueueAttributeNameQueueArn: aws.String("arn:aws:sqs:us-west-2:000000000000:Karpenter-Queue"),
 		},
 	}).Invoke(input)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// The attributes that were set on the queue are returned along with the output, which takes precedence
+	output.Attributes = lo.Assign(s.queueAttributes, output.Attributes)
+	return output, nil
 }
 
 func (s *SQSAPI) SetQueueAttributesWithContext(_ context.Context, input *sqs.SetQueueAttributesInput, _ ...request.Option) (*sqs.SetQueueAttributesOutput, error) {
-	return s.SetQueueAttributesBehavior.Invoke(input)
+	output, err := s.SetQueueAttributesBehavior.Invoke(input)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queueAttributes = lo.Assign(s.queueAttributes, input.Attributes)
+	return output, nil
 }
 
 func (s *SQSAPI) ReceiveMessageWithContext(_ context.Context, input *sqs.ReceiveMessageInput, _ ...request.Option) (*sqs.ReceiveMessageOutput, error) {
 	return s.ReceiveMessageBehavior.Invoke(input)
 }
 
+func (s *SQSAPI) SendMessageWithContext(_ context.Context, input *sqs.SendMessageInput, _ ...request.Option) (*sqs.SendMessageOutput, error) {
+	return s.SendMessageBehavior.WithDefault(&sqs.SendMessageOutput{
+		MessageId: aws.String(dummyMessageID),
+	}).Invoke(input)
+}
+
 func (s *SQSAPI) DeleteMessageWithContext(_ context.Context, input *sqs.DeleteMessageInput, _ ...request.Option) (*sqs.DeleteMessageOutput, error) {
 	return s.DeleteMessageBehavior.Invoke(input)
 }
 
+func (s *SQSAPI) ChangeMessageVisibilityWithContext(_ context.Context, input *sqs.ChangeMessageVisibilityInput, _ ...request.Option) (*sqs.ChangeMessageVisibilityOutput, error) {
+	return s.ChangeVisibilityBehavior.Invoke(input)
+}
+
 func (s *SQSAPI) DeleteQueueWithContext(_ context.Context, input *sqs.DeleteQueueInput, _ ...request.Option) (*sqs.DeleteQueueOutput, error) {
 	return s.DeleteQueueBehavior.Invoke(input)
 }
+
+func (s *SQSAPI) TagQueueWithContext(_ context.Context, input *sqs.TagQueueInput, _ ...request.Option) (*sqs.TagQueueOutput, error) {
+	return s.TagQueueBehavior.Invoke(input)
+}
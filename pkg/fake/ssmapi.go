@@ -17,6 +17,7 @@ package fake
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	"github.com/mitchellh/hashstructure/v2"
 
@@ -30,9 +31,33 @@ type SSMAPI struct {
 	ssmiface.SSMAPI
 	GetParameterOutput *ssm.GetParameterOutput
 	WantErr            error
+	// NextErrors are returned by successive calls, one per call, before WantErr or GetParameterOutput are considered
+	NextErrors                  []error
+	CalledWithGetParameterInput AtomicPtrSlice[ssm.GetParameterInput]
+	mu                          sync.Mutex
 }
 
-func (a SSMAPI) GetParameterWithContext(ctx context.Context, input *ssm.GetParameterInput, opts ...request.Option) (*ssm.GetParameterOutput, error) {
+// Reset must be called between tests otherwise tests will pollute
+// each other.
+func (a *SSMAPI) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.GetParameterOutput = nil
+	a.WantErr = nil
+	a.NextErrors = nil
+	a.CalledWithGetParameterInput.Reset()
+}
+
+func (a *SSMAPI) GetParameterWithContext(ctx context.Context, input *ssm.GetParameterInput, opts ...request.Option) (*ssm.GetParameterOutput, error) {
+	a.CalledWithGetParameterInput.Add(input)
+	a.mu.Lock()
+	if len(a.NextErrors) > 0 {
+		err := a.NextErrors[0]
+		a.NextErrors = a.NextErrors[1:]
+		a.mu.Unlock()
+		return nil, err
+	}
+	a.mu.Unlock()
 	if a.WantErr != nil {
 		return nil, a.WantErr
 	}
@@ -39,7 +39,7 @@ func SubnetsFromFleetRequest(createFleetInput *ec2.CreateFleetInput) []string {
 // Filters are chained with a logical "AND"
 func FilterDescribeSecurtyGroups(sgs []*ec2.SecurityGroup, filters []*ec2.Filter) []*ec2.SecurityGroup {
 	return lo.Filter(sgs, func(group *ec2.SecurityGroup, _ int) bool {
-		return Filter(filters, *group.GroupId, group.Tags)
+		return Filter(filters, *group.GroupId, aws.StringValue(group.OwnerId), group.Tags)
 	})
 }
 
@@ -47,16 +47,24 @@ func FilterDescribeSecurtyGroups(sgs []*ec2.SecurityGroup, filters []*ec2.Filter
 // Filters are chained with a logical "AND"
 func FilterDescribeSubnets(subnets []*ec2.Subnet, filters []*ec2.Filter) []*ec2.Subnet {
 	return lo.Filter(subnets, func(subnet *ec2.Subnet, _ int) bool {
-		return Filter(filters, *subnet.SubnetId, subnet.Tags)
+		return Filter(filters, *subnet.SubnetId, aws.StringValue(subnet.OwnerId), subnet.Tags)
 	})
 }
 
-func Filter(filters []*ec2.Filter, id string, tags []*ec2.Tag) bool {
+func Filter(filters []*ec2.Filter, id string, owner string, tags []*ec2.Tag) bool {
 	return lo.EveryBy(filters, func(filter *ec2.Filter) bool {
 		switch filterName := aws.StringValue(filter.Name); {
 		case filterName == "subnet-id" || filterName == "group-id":
 			for _, val := range filter.Values {
-				if id == aws.StringValue(val) {
+				// Like EC2, a trailing wildcard matches every ID with the prefix
+				value := aws.StringValue(val)
+				if id == value || (strings.HasSuffix(value, "*") && strings.HasPrefix(id, strings.TrimSuffix(value, "*"))) {
+					return true
+				}
+			}
+		case filterName == "owner-id":
+			for _, val := range filter.Values {
+				if owner == aws.StringValue(val) {
 					return true
 				}
 			}
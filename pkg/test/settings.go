@@ -16,24 +16,68 @@ package test
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/imdario/mergo"
 	"github.com/samber/lo"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	awssettings "github.com/aws/karpenter/pkg/apis/config/settings"
 )
 
 type SettingOptions struct {
-	ClusterName                *string
-	ClusterEndpoint            *string
-	DefaultInstanceProfile     *string
-	EnablePodENI               *bool
-	EnableENILimitedPodDensity *bool
-	IsolatedVPC                *bool
-	NodeNameConvention         *awssettings.NodeNameConvention
-	VMMemoryOverheadPercent    *float64
-	EnableInterruptionHandling *bool
-	Tags                       map[string]string
+	ClusterName                          *string
+	ClusterEndpoint                      *string
+	ClusterVPCID                         *string
+	DefaultInstanceProfile               *string
+	NodeTemplateValidation               *awssettings.NodeTemplateValidation
+	LaunchTemplateNamePrefix             *string
+	EnablePodENI                         *bool
+	EnableENILimitedPodDensity           *bool
+	IsolatedVPC                          *bool
+	NodeNameConvention                   *awssettings.NodeNameConvention
+	VMMemoryOverheadPercent              *float64
+	EnableInterruptionHandling           *bool
+	InterruptionMessageMaxAge            *time.Duration
+	InterruptionUnmatchedNodeBehavior    *awssettings.UnmatchedNodeBehavior
+	InterruptionUnmatchedNodeGracePeriod *time.Duration
+	InterruptionDrainLastPriorityClasses []string
+	InterruptionIgnoredMessageTypes      []string
+	InterruptionEventPath                *string
+	InterruptionReceiveMaxBackoff        *time.Duration
+	InterruptionInfrastructureInterval   *time.Duration
+	CreateFleetTimeout                   *time.Duration
+	CreateFleetBatchSize                 *int
+	SSMRetryAttempts                     *int
+	SSMRetryDelay                        *time.Duration
+	NameTagTemplate                      *string
+	EnableOfferingsAPI                   *bool
+	EnableQuotaCheck                     *bool
+	VCPULimitBackoff                     *time.Duration
+	SpreadSubnetsWithinZone              *bool
+	SpotUnavailableBehavior              *awssettings.SpotUnavailableBehavior
+	EnableAllocationAnnotations          *bool
+	EnableAMIDriftDetection              *bool
+	ProvisioningDecisionSink             *string
+	ProvisioningDecisionsPerMinute       *int
+	InstanceEventBus                     *string
+	InstanceEventsPerMinute              *int
+	DescribeInstancesBatchSize           *int
+	DescribeSubnetsPageSize              *int
+	MaxSubnetsPerSelector                *int
+	PricingRefreshPeriod                 *time.Duration
+	EnableVersionTags                    *bool
+	InstanceTypeDataSource               *awssettings.InstanceTypeDataSource
+	InstanceTypeOfferingsParallelism     *int
+	InstanceTypeOfferingsLocationType    *string
+	InstanceTypeDiscoveryRegions         []string
+	CapacityOverrides                    map[string]v1.ResourceList
+	ReservedHeadroom                     v1.ResourceList
+	ExcludedInstanceTypes                []string
+	InstanceTypeFilters                  map[string][]string
+	Tags                                 map[string]string
+	InterruptionInfrastructureTags       map[string]string
 }
 
 func Settings(overrides ...SettingOptions) awssettings.Settings {
@@ -44,15 +88,56 @@ func Settings(overrides ...SettingOptions) awssettings.Settings {
 		}
 	}
 	return awssettings.Settings{
-		ClusterName:                lo.FromPtrOr(options.ClusterName, "test-cluster"),
-		ClusterEndpoint:            lo.FromPtrOr(options.ClusterEndpoint, "https://test-cluster"),
-		DefaultInstanceProfile:     lo.FromPtrOr(options.DefaultInstanceProfile, "test-instance-profile"),
-		EnablePodENI:               lo.FromPtrOr(options.EnablePodENI, true),
-		EnableENILimitedPodDensity: lo.FromPtrOr(options.EnableENILimitedPodDensity, true),
-		IsolatedVPC:                lo.FromPtrOr(options.IsolatedVPC, false),
-		NodeNameConvention:         lo.FromPtrOr(options.NodeNameConvention, awssettings.IPName),
-		VMMemoryOverheadPercent:    lo.FromPtrOr(options.VMMemoryOverheadPercent, 0.075),
-		EnableInterruptionHandling: lo.FromPtrOr(options.EnableInterruptionHandling, false),
-		Tags:                       options.Tags,
+		ClusterName:                          lo.FromPtrOr(options.ClusterName, "test-cluster"),
+		ClusterEndpoint:                      lo.FromPtrOr(options.ClusterEndpoint, "https://test-cluster"),
+		ClusterVPCID:                         lo.FromPtrOr(options.ClusterVPCID, ""),
+		DefaultInstanceProfile:               lo.FromPtrOr(options.DefaultInstanceProfile, "test-instance-profile"),
+		NodeTemplateValidation:               lo.FromPtrOr(options.NodeTemplateValidation, awssettings.LenientValidation),
+		LaunchTemplateNamePrefix:             lo.FromPtrOr(options.LaunchTemplateNamePrefix, "Karpenter"),
+		EnablePodENI:                         lo.FromPtrOr(options.EnablePodENI, true),
+		EnableENILimitedPodDensity:           lo.FromPtrOr(options.EnableENILimitedPodDensity, true),
+		IsolatedVPC:                          lo.FromPtrOr(options.IsolatedVPC, false),
+		NodeNameConvention:                   lo.FromPtrOr(options.NodeNameConvention, awssettings.IPName),
+		VMMemoryOverheadPercent:              lo.FromPtrOr(options.VMMemoryOverheadPercent, 0.075),
+		EnableInterruptionHandling:           lo.FromPtrOr(options.EnableInterruptionHandling, false),
+		InterruptionMessageMaxAge:            metav1.Duration{Duration: lo.FromPtrOr(options.InterruptionMessageMaxAge, 0)},
+		InterruptionUnmatchedNodeBehavior:    lo.FromPtrOr(options.InterruptionUnmatchedNodeBehavior, awssettings.DeleteMessage),
+		InterruptionUnmatchedNodeGracePeriod: metav1.Duration{Duration: lo.FromPtrOr(options.InterruptionUnmatchedNodeGracePeriod, 2*time.Minute)},
+		InterruptionDrainLastPriorityClasses: options.InterruptionDrainLastPriorityClasses,
+		InterruptionIgnoredMessageTypes:      options.InterruptionIgnoredMessageTypes,
+		InterruptionEventPath:                lo.FromPtrOr(options.InterruptionEventPath, ""),
+		InterruptionReceiveMaxBackoff:        metav1.Duration{Duration: lo.FromPtrOr(options.InterruptionReceiveMaxBackoff, time.Minute)},
+		InterruptionInfrastructureInterval:   metav1.Duration{Duration: lo.FromPtrOr(options.InterruptionInfrastructureInterval, 5*time.Minute)},
+		CreateFleetTimeout:                   metav1.Duration{Duration: lo.FromPtrOr(options.CreateFleetTimeout, time.Minute)},
+		CreateFleetBatchSize:                 lo.FromPtrOr(options.CreateFleetBatchSize, 1000),
+		SSMRetryAttempts:                     lo.FromPtrOr(options.SSMRetryAttempts, 3),
+		SSMRetryDelay:                        metav1.Duration{Duration: lo.FromPtrOr(options.SSMRetryDelay, time.Millisecond)},
+		NameTagTemplate:                      lo.FromPtrOr(options.NameTagTemplate, ""),
+		EnableOfferingsAPI:                   lo.FromPtrOr(options.EnableOfferingsAPI, false),
+		EnableQuotaCheck:                     lo.FromPtrOr(options.EnableQuotaCheck, false),
+		VCPULimitBackoff:                     metav1.Duration{Duration: lo.FromPtrOr(options.VCPULimitBackoff, 5*time.Minute)},
+		SpreadSubnetsWithinZone:              lo.FromPtrOr(options.SpreadSubnetsWithinZone, false),
+		SpotUnavailableBehavior:              lo.FromPtrOr(options.SpotUnavailableBehavior, awssettings.FallbackToOnDemand),
+		EnableAllocationAnnotations:          lo.FromPtrOr(options.EnableAllocationAnnotations, false),
+		EnableAMIDriftDetection:              lo.FromPtrOr(options.EnableAMIDriftDetection, false),
+		ProvisioningDecisionSink:             lo.FromPtrOr(options.ProvisioningDecisionSink, ""),
+		ProvisioningDecisionsPerMinute:       lo.FromPtrOr(options.ProvisioningDecisionsPerMinute, 60),
+		InstanceEventBus:                     lo.FromPtrOr(options.InstanceEventBus, ""),
+		InstanceEventsPerMinute:              lo.FromPtrOr(options.InstanceEventsPerMinute, 60),
+		DescribeInstancesBatchSize:           lo.FromPtrOr(options.DescribeInstancesBatchSize, 1000),
+		DescribeSubnetsPageSize:              lo.FromPtrOr(options.DescribeSubnetsPageSize, 1000),
+		MaxSubnetsPerSelector:                lo.FromPtrOr(options.MaxSubnetsPerSelector, 500),
+		PricingRefreshPeriod:                 metav1.Duration{Duration: lo.FromPtrOr(options.PricingRefreshPeriod, 12*time.Hour)},
+		EnableVersionTags:                    lo.FromPtrOr(options.EnableVersionTags, false),
+		InstanceTypeDataSource:               lo.FromPtrOr(options.InstanceTypeDataSource, awssettings.APIInstanceTypeData),
+		InstanceTypeOfferingsParallelism:     lo.FromPtrOr(options.InstanceTypeOfferingsParallelism, 5),
+		InstanceTypeOfferingsLocationType:    lo.FromPtrOr(options.InstanceTypeOfferingsLocationType, "availability-zone"),
+		InstanceTypeDiscoveryRegions:         options.InstanceTypeDiscoveryRegions,
+		CapacityOverrides:                    options.CapacityOverrides,
+		ReservedHeadroom:                     options.ReservedHeadroom,
+		ExcludedInstanceTypes:                options.ExcludedInstanceTypes,
+		InstanceTypeFilters:                  options.InstanceTypeFilters,
+		Tags:                                 options.Tags,
+		InterruptionInfrastructureTags:       options.InterruptionInfrastructureTags,
 	}
 }
@@ -0,0 +1,60 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodetemplate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/aws/karpenter/pkg/apis/v1alpha1"
+)
+
+// Get retrieves the AWSNodeTemplate with the settings that it inherits from its base templates merged into its spec
+func Get(ctx context.Context, kubeClient client.Client, name string) (*v1alpha1.AWSNodeTemplate, error) {
+	nodeTemplate := &v1alpha1.AWSNodeTemplate{}
+	if err := kubeClient.Get(ctx, types.NamespacedName{Name: name}, nodeTemplate); err != nil {
+		return nil, err
+	}
+	if err := Resolve(ctx, kubeClient, nodeTemplate); err != nil {
+		return nil, err
+	}
+	return nodeTemplate, nil
+}
+
+// Resolve follows the chain of base templates of the AWSNodeTemplate and merges their settings into its spec, with
+// templates closer to the AWSNodeTemplate taking precedence. It fails if a base template doesn't exist or if the
+// chain references a template twice.
+func Resolve(ctx context.Context, kubeClient client.Client, nodeTemplate *v1alpha1.AWSNodeTemplate) error {
+	chain := []string{nodeTemplate.Name}
+	for ref := nodeTemplate.Spec.BaseTemplateRef; ref != nil; {
+		for _, name := range chain {
+			if name == ref.Name {
+				return fmt.Errorf("base templates reference each other in a cycle, %s", strings.Join(append(chain, ref.Name), " -> "))
+			}
+		}
+		chain = append(chain, ref.Name)
+		base := &v1alpha1.AWSNodeTemplate{}
+		if err := kubeClient.Get(ctx, types.NamespacedName{Name: ref.Name}, base); err != nil {
+			return fmt.Errorf("getting base template %q, %w", ref.Name, err)
+		}
+		nodeTemplate.Spec.Inherit(&base.Spec)
+		ref = base.Spec.BaseTemplateRef
+	}
+	return nil
+}
@@ -17,23 +17,35 @@ package webhooks
 import (
 	"context"
 
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/patrickmn/go-cache"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"knative.dev/pkg/configmap"
 	"knative.dev/pkg/controller"
 	knativeinjection "knative.dev/pkg/injection"
+	"knative.dev/pkg/webhook"
 	"knative.dev/pkg/webhook/resourcesemantics"
 	"knative.dev/pkg/webhook/resourcesemantics/defaulting"
 	"knative.dev/pkg/webhook/resourcesemantics/validation"
 
 	corev1alpha5 "github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
+	"github.com/aws/karpenter-core/pkg/operator/settingsstore"
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
 	"github.com/aws/karpenter/pkg/apis/v1alpha5"
+	"github.com/aws/karpenter/pkg/cloudprovider"
+	"github.com/aws/karpenter/pkg/cloudprovider/amifamily"
+	awscontext "github.com/aws/karpenter/pkg/context"
+	"github.com/aws/karpenter/pkg/controllers/nodetemplate"
 )
 
-func NewWebhooks() []knativeinjection.ControllerConstructor {
+func NewWebhooks(ctx awscontext.Context, settingsStore settingsstore.Store) []knativeinjection.ControllerConstructor {
+	ec2api := ec2.New(ctx.Session)
+	resolutionValidator := nodetemplate.NewResolutionValidator(ctx.KubeClient, cloudprovider.NewSubnetProvider(ec2api), cloudprovider.NewSecurityGroupProvider(ec2api),
+		amifamily.NewAMIProvider(ctx.KubeClient, ctx.EventRecorder, ssm.New(ctx.Session), ec2api, cache.New(awscontext.CacheTTL, awscontext.CacheCleanupInterval), cache.New(awscontext.CacheTTL, awscontext.CacheCleanupInterval), ctx.SSMQueriesCache))
 	return []knativeinjection.ControllerConstructor{
 		NewCRDDefaultingWebhook,
-		NewCRDValidationWebhook,
+		NewCRDValidationWebhook(resolutionValidator, settingsStore),
 	}
 }
 
@@ -47,14 +59,22 @@ func NewCRDDefaultingWebhook(ctx context.Context, w configmap.Watcher) *controll
 	)
 }
 
-func NewCRDValidationWebhook(ctx context.Context, w configmap.Watcher) *controller.Impl {
-	return validation.NewAdmissionController(ctx,
-		"validation.webhook.karpenter.k8s.aws",
-		"/validate/karpenter.k8s.aws",
-		Resources,
-		func(ctx context.Context) context.Context { return ctx },
-		true,
-	)
+// NewCRDValidationWebhook validates the resources, and resolves AWSNodeTemplates with the validator so that
+// AWSNodeTemplates that can't be resolved are rejected when aws.nodeTemplateValidation is Strict
+func NewCRDValidationWebhook(resolutionValidator *nodetemplate.ResolutionValidator, settingsStore settingsstore.Store) knativeinjection.ControllerConstructor {
+	return func(ctx context.Context, w configmap.Watcher) *controller.Impl {
+		return validation.NewAdmissionController(ctx,
+			"validation.webhook.karpenter.k8s.aws",
+			"/validate/karpenter.k8s.aws",
+			Resources,
+			// Settings are read from the store for each admission request, so that changes apply without a restart
+			settingsStore.InjectSettings,
+			true,
+			map[schema.GroupVersionKind]validation.Callback{
+				v1alpha1.SchemeGroupVersion.WithKind("AWSNodeTemplate"): validation.NewCallback(resolutionValidator.ValidateUnstructured, webhook.Create, webhook.Update),
+			},
+		)
+	}
 }
 
 var Resources = map[schema.GroupVersionKind]resourcesemantics.GenericCRD{
@@ -25,6 +25,8 @@ import (
 	. "github.com/onsi/gomega"    //nolint:revive,stylecheck
 	"go.uber.org/multierr"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/aws/karpenter/pkg/controllers/interruption/messages"
 )
 
 func (env *Environment) ExpectInstance(nodeName string) Assertion {
@@ -91,14 +93,14 @@ func (env *Environment) EventuallyExpectQueueCreated() {
 	}).Should(Succeed())
 }
 
-func (env *Environment) ExpectMessagesCreated(msgs ...interface{}) {
+func (env *Environment) ExpectMessagesCreated(msgs ...messages.Message) {
 	wg := &sync.WaitGroup{}
 	mu := &sync.Mutex{}
 
 	var err error
 	for _, msg := range msgs {
 		wg.Add(1)
-		go func(m interface{}) {
+		go func(m messages.Message) {
 			defer wg.Done()
 			defer GinkgoRecover()
 			_, e := env.SQSProvider.SendMessage(env.Environment.Context, m)
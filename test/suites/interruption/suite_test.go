@@ -27,14 +27,12 @@ import (
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
-	"k8s.io/apimachinery/pkg/util/uuid"
 	"knative.dev/pkg/ptr"
 
 	"github.com/aws/karpenter-core/pkg/apis/provisioning/v1alpha5"
 	"github.com/aws/karpenter-core/pkg/test"
 	"github.com/aws/karpenter/pkg/apis/config/settings"
 	"github.com/aws/karpenter/pkg/apis/v1alpha1"
-	"github.com/aws/karpenter/pkg/controllers/interruption/messages"
 	"github.com/aws/karpenter/pkg/controllers/interruption/messages/scheduledchange"
 	awstest "github.com/aws/karpenter/pkg/test"
 	"github.com/aws/karpenter/test/pkg/environment/aws"
@@ -255,38 +253,12 @@ var _ = Describe("Interruption", Label("AWS"), func() {
 		instanceID := parseProviderID(node.Spec.ProviderID)
 
 		By("Creating a scheduled change health event in the SQS message queue")
-		env.ExpectMessagesCreated(scheduledChangeMessage(env.Region, "000000000000", instanceID))
+		env.ExpectMessagesCreated(scheduledchange.NewMessage(env.Region, "000000000000", instanceID))
 		env.EventuallyExpectNotFoundAssertion(node).WithTimeout(time.Minute) // shorten the timeout since we should react faster
 		env.EventuallyExpectHealthyPodCount(selector, 1)
 	})
 })
 
-func scheduledChangeMessage(region, accountID, involvedInstanceID string) scheduledchange.Message {
-	return scheduledchange.Message{
-		Metadata: messages.Metadata{
-			Version:    "0",
-			Account:    accountID,
-			DetailType: "AWS Health Event",
-			ID:         string(uuid.NewUUID()),
-			Region:     region,
-			Resources: []string{
-				fmt.Sprintf("arn:aws:ec2:%s:instance/%s", region, involvedInstanceID),
-			},
-			Source: "aws.health",
-			Time:   time.Now(),
-		},
-		Detail: scheduledchange.Detail{
-			Service:           "EC2",
-			EventTypeCategory: "scheduledChange",
-			AffectedEntities: []scheduledchange.AffectedEntity{
-				{
-					EntityValue: involvedInstanceID,
-				},
-			},
-		},
-	}
-}
-
 func parseProviderID(pid string) string {
 	r := regexp.MustCompile(`aws:///(?P<AZ>.*)/(?P<InstanceID>.*)`)
 	matches := r.FindStringSubmatch(pid)
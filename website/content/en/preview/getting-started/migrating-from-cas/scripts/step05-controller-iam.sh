@@ -31,11 +31,14 @@ echo '{
                 "ec2:DescribeSubnets",
                 "ec2:DescribeSecurityGroups",
                 "ec2:DescribeLaunchTemplates",
+                "ec2:DescribeLaunchTemplateVersions",
+                "ec2:DescribeKeyPairs",
                 "ec2:DescribeInstances",
                 "ec2:DescribeInstanceTypes",
                 "ec2:DescribeInstanceTypeOfferings",
                 "ec2:DescribeAvailabilityZones",
                 "ec2:DeleteLaunchTemplate",
+                "ec2:ModifyInstanceAttribute",
                 "ec2:CreateTags",
                 "ec2:CreateLaunchTemplate",
                 "ec2:CreateFleet",